@@ -0,0 +1,271 @@
+package apitests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"avenue/backend/db"
+	"avenue/backend/sdk"
+)
+
+// sharedDrive is what populateDrive puts in a user's drive.
+type sharedDrive struct {
+	Folder, Empty sdk.FolderItem
+	Root, Nested  sdk.File
+	// FileToken and FolderToken are share links to Root and Folder.
+	FileToken, FolderToken string
+}
+
+// populateDrive gives the user behind h a file at the root, a folder with
+// a file in it, an empty folder, and a share link to the first file and to
+// the folder.
+func populateDrive(t *testing.T, client *sdk.Client, h http.Header) sharedDrive {
+	t.Helper()
+	requireSharing(t, client, h, false)
+	requireSharing(t, client, h, true)
+
+	var d sharedDrive
+	d.Folder = createFolder(t, client, h, "docs", "")
+	d.Empty = createFolder(t, client, h, "empty", "")
+	d.Root = upload(t, client, h, "root.txt", "at the root", "")
+	d.Nested = upload(t, client, h, "nested.txt", "in a folder", d.Folder.UUID)
+
+	fileLink, err := client.CreateShareLink(h, d.Root.UUID, sdk.CreateShareLinkRequest{})
+	if err != nil {
+		t.Fatalf("CreateShareLink: %v", err)
+	}
+	folderLink, err := client.CreateFolderShareLink(h, d.Folder.UUID, sdk.CreateShareLinkRequest{})
+	if err != nil {
+		t.Fatalf("CreateFolderShareLink: %v", err)
+	}
+	d.FileToken, d.FolderToken = fileLink.Token, folderLink.Token
+	return d
+}
+
+// wantDeleted checks that u's account is gone after a deletion that
+// reported summary: the user can't log in or use their session, the
+// share links in d are dead, and the deletion was audited.
+func wantDeleted(t *testing.T, client *sdk.Client, u testUser, d sharedDrive, summary sdk.AccountDeletionSummary, mode string) {
+	t.Helper()
+	want := sdk.AccountDeletionSummary{UserID: u.ID, Mode: mode, Files: 2, Folders: 2, SharesRevoked: 2}
+	if summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+	if _, err := client.PingSession(u.Header); err == nil {
+		t.Error("the deleted user's session still works")
+	}
+	if _, err := client.Login(http.Header{}, sdk.LoginRequest{Email: u.Email, Password: u.Password}); err == nil {
+		t.Error("a deleted account could still log in")
+	}
+	if _, err := client.GetShareLinkMeta(nil, d.FileToken); err == nil {
+		t.Error("a share link to a deleted account's file still works")
+	}
+	if _, err := client.GetSharedFolderContents(nil, d.FolderToken); err == nil {
+		t.Error("a share link to a deleted account's folder still works")
+	}
+	if actions := auditActions(t, u.ID); hermetic != nil && !slices.Contains(actions, db.AuditAccountDeleted) {
+		t.Errorf("audit log for user %d = %v, want %s", u.ID, actions, db.AuditAccountDeleted)
+	}
+}
+
+// auditActions returns the actions in the audit log against userID, oldest
+// first. The log has no API, so it's read straight from the in-process
+// server's database; against AVENUE_TEST_BASE_URL it returns nil.
+func auditActions(t *testing.T, userID int64) []string {
+	t.Helper()
+	if hermetic == nil {
+		return nil
+	}
+	var actions []string
+	if err := db.DB.Select(&actions, `SELECT action FROM audit_log WHERE target_user_id = $1 ORDER BY id`, userID); err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	return actions
+}
+
+func TestAccountExport(t *testing.T) {
+	requirePostgres(t)
+	client, u := newUser(t)
+	_, adminH := adminClient(t)
+	d := populateDrive(t, client, u.Header)
+
+	for name, export := range map[string]func() (*http.Response, error){
+		"own":   func() (*http.Response, error) { return client.ExportAccount(u.Header) },
+		"admin": func() (*http.Response, error) { return client.AdminExportAccount(adminH, fmt.Sprint(u.ID)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := export()
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			b := readAll(t, resp)
+			zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatalf("open zip: %v", err)
+			}
+			entries := map[string]string{}
+			for _, f := range zr.File {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatalf("open %s: %v", f.Name, err)
+				}
+				content, err := io.ReadAll(rc)
+				_ = rc.Close()
+				if err != nil {
+					t.Fatalf("read %s: %v", f.Name, err)
+				}
+				entries[f.Name] = string(content)
+			}
+
+			for name, want := range map[string]string{
+				"files/root.txt":                         "at the root",
+				"files/" + d.Folder.Name + "/nested.txt": "in a folder",
+				"files/" + d.Empty.Name + "/":            "",
+			} {
+				if got, ok := entries[name]; !ok || got != want {
+					t.Errorf("export entry %s = %q (present %v), want %q", name, got, ok, want)
+				}
+			}
+
+			var manifest sdk.ExportManifest
+			if err := json.Unmarshal([]byte(entries["manifest.json"]), &manifest); err != nil {
+				t.Fatalf("manifest.json: %v", err)
+			}
+			if manifest.User.ID != u.ID || len(manifest.Files) != 2 || len(manifest.Folders) != 2 || len(manifest.Errors) != 0 {
+				t.Errorf("manifest has user %d, %d files, %d folders and errors %v; want user %d, 2 files, 2 folders and no errors",
+					manifest.User.ID, len(manifest.Files), len(manifest.Folders), manifest.Errors, u.ID)
+			}
+			if !slices.ContainsFunc(manifest.Files, func(f sdk.ExportFile) bool {
+				return f.UUID == d.Nested.UUID && f.Folder == d.Folder.Name && entries[f.ArchivePath] == "in a folder"
+			}) {
+				t.Errorf("manifest files = %+v, want nested.txt in %s", manifest.Files, d.Folder.Name)
+			}
+			if !slices.ContainsFunc(manifest.FileShares, func(l sdk.ShareLinkWithFileName) bool { return l.Token == d.FileToken }) {
+				t.Errorf("manifest file shares = %+v, want %s", manifest.FileShares, d.FileToken)
+			}
+			if !slices.ContainsFunc(manifest.FolderShares, func(l sdk.ShareFolderLink) bool { return l.Token == d.FolderToken }) {
+				t.Errorf("manifest folder shares = %+v, want %s", manifest.FolderShares, d.FolderToken)
+			}
+		})
+	}
+	if actions := auditActions(t, u.ID); hermetic != nil && !slices.Contains(actions, db.AuditAccountExport) {
+		t.Errorf("audit log for user %d = %v, want %s", u.ID, actions, db.AuditAccountExport)
+	}
+	if _, err := client.AdminExportAccount(u.Header, fmt.Sprint(u.ID)); err == nil {
+		t.Error("AdminExportAccount succeeded for a non-admin")
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	requirePostgres(t)
+	client, u := newUser(t)
+	d := populateDrive(t, client, u.Header)
+	other, err := client.Login(http.Header{}, sdk.LoginRequest{Email: u.Email, Password: u.Password})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	otherH := http.Header{"Authorization": []string{"Token " + other.SessionID}}
+
+	if _, err := client.DeleteAccount(u.Header, sdk.DeleteAccountRequest{ConfirmEmail: "someone@else.com", Password: u.Password}); err == nil {
+		t.Error("DeleteAccount succeeded with the wrong confirmation email")
+	}
+	if _, err := client.DeleteAccount(u.Header, sdk.DeleteAccountRequest{ConfirmEmail: u.Email, Password: "not-the-password"}); err == nil {
+		t.Error("DeleteAccount succeeded with the wrong password")
+	}
+	if _, err := client.PingSession(u.Header); err != nil {
+		t.Fatalf("a refused deletion still revoked the session: %v", err)
+	}
+
+	summary, err := client.DeleteAccount(u.Header, sdk.DeleteAccountRequest{ConfirmEmail: u.Email, Password: u.Password})
+	if err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+	wantDeleted(t, client, u, d, summary, sdk.AccountDeletionTrash)
+	if _, err := client.PingSession(otherH); err == nil {
+		t.Error("the deleted user's other session still works")
+	}
+}
+
+func TestAdminDeleteAccount(t *testing.T) {
+	requirePostgres(t)
+	client, h := adminClient(t)
+	_, u := newUser(t)
+	_, bystander := newUser(t)
+	d := populateDrive(t, client, u.Header)
+	req := sdk.DeleteAccountRequest{ConfirmEmail: u.Email, Purge: true}
+
+	if _, err := client.AdminDeleteAccount(bystander.Header, fmt.Sprint(u.ID), req); err == nil {
+		t.Error("AdminDeleteAccount succeeded for a non-admin")
+	}
+	if _, err := client.AdminDeleteAccount(h, fmt.Sprint(u.ID), sdk.DeleteAccountRequest{ConfirmEmail: bystander.Email}); err == nil {
+		t.Error("AdminDeleteAccount succeeded with another user's email")
+	}
+
+	summary, err := client.AdminDeleteAccount(h, fmt.Sprint(u.ID), req)
+	if err != nil {
+		t.Fatalf("AdminDeleteAccount: %v", err)
+	}
+	wantDeleted(t, client, u, d, summary, sdk.AccountDeletionPurge)
+	if _, err := client.AdminDeleteAccount(h, fmt.Sprint(u.ID), req); err == nil {
+		t.Error("AdminDeleteAccount deleted the same account twice")
+	}
+}
+
+// TestDeleteLastAdmin makes a second admin the only one by demoting every
+// other, and checks they can't delete themselves. The other admins are
+// promoted again when it ends.
+func TestDeleteLastAdmin(t *testing.T) {
+	requirePostgres(t)
+	client, adminH := adminClient(t)
+	password := "Sup3rSecretPassw0rd!"
+	second, err := client.CreateUser(adminH, sdk.CreateUserRequest{
+		Email:     uniqueName("apitest-admin") + "@example.com",
+		Password:  &password,
+		FirstName: "API",
+		LastName:  "Admin",
+		IsAdmin:   true,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = client.AdminDeleteAccount(adminH, fmt.Sprint(second.ID), sdk.DeleteAccountRequest{ConfirmEmail: second.Email, Purge: true})
+	})
+	login, err := client.Login(http.Header{}, sdk.LoginRequest{Email: second.Email, Password: password})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	secondH := http.Header{"Authorization": []string{"Token " + login.SessionID}}
+
+	users, err := client.GetUsers(adminH)
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	for _, u := range users {
+		if !u.IsAdmin || u.ID == second.ID {
+			continue
+		}
+		promote, demote := true, false
+		if _, err := client.UpdateProfileByID(secondH, fmt.Sprint(u.ID), sdk.UpdateProfileRequest{ID: u.ID, FirstName: &u.FirstName, LastName: &u.LastName, IsAdmin: &demote}); err != nil {
+			t.Fatalf("demote admin %d: %v", u.ID, err)
+		}
+		t.Cleanup(func() {
+			if _, err := client.UpdateProfileByID(secondH, fmt.Sprint(u.ID), sdk.UpdateProfileRequest{ID: u.ID, FirstName: &u.FirstName, LastName: &u.LastName, IsAdmin: &promote}); err != nil {
+				t.Errorf("promote admin %d again: %v", u.ID, err)
+			}
+		})
+	}
+
+	if _, err := client.DeleteAccount(secondH, sdk.DeleteAccountRequest{ConfirmEmail: second.Email, Password: password}); err == nil {
+		t.Fatal("DeleteAccount deleted the last admin")
+	}
+	if _, err := client.PingSession(secondH); err != nil {
+		t.Errorf("the last admin's session stopped working after a refused deletion: %v", err)
+	}
+}
@@ -1,7 +1,14 @@
 package apitests
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
 	"testing"
 
 	"avenue/backend/sdk"
@@ -45,3 +52,351 @@ func TestDashboard(t *testing.T) {
 		t.Errorf("expected a positive MaxFileSize, got %d", dashboard.MaxFileSize)
 	}
 }
+
+func TestPingSession(t *testing.T) {
+	client, h := authedClient(t)
+
+	if _, err := client.PingSession(h); err != nil {
+		t.Fatalf("PingSession: %v", err)
+	}
+
+	var apiErr *sdk.APIError
+	_, err := client.PingSession(http.Header{})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("PingSession without a session: err = %v, want a 401", err)
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	client := testClient(t)
+
+	b, err := client.OpenAPISpec(http.Header{})
+	if err != nil {
+		t.Fatalf("OpenAPISpec: %v", err)
+	}
+	var doc struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if doc.OpenAPI == "" || doc.Paths["/v1/folder/list/{folderID}"] == nil {
+		t.Errorf("spec is missing its version or the folder listing: %.200s", b)
+	}
+}
+
+func TestOIDCLoginURL(t *testing.T) {
+	client := testClient(t)
+
+	// No SSO provider is set up under this name, so the server answers
+	// instead of redirecting to one.
+	resp, err := http.Get(client.OIDCLoginURL(uniqueName("no-such-provider"), "/profile"))
+	if err != nil {
+		t.Fatalf("GET OIDCLoginURL: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("logging in with an unknown provider: status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	client := testClient(t)
+
+	// Unknown emails are accepted too, so as not to give away which
+	// accounts exist.
+	if err := client.ForgotPassword(http.Header{}, uniqueName("nobody")+"@example.com"); err != nil {
+		t.Errorf("ForgotPassword: %v", err)
+	}
+	if err := client.ResetPassword(http.Header{}, uniqueName("bogus-token"), "An0therPassw0rd!"); err == nil {
+		t.Error("expected ResetPassword with a bogus token to fail, got nil error")
+	}
+}
+
+// requireOIDC skips the calling test unless the in-process server is
+// running with the mock IdP as its "mock" provider. SSO logins keep their
+// state and identities in Postgres.
+func requireOIDC(t *testing.T) {
+	t.Helper()
+	if idp == nil {
+		t.Skip("skipping: needs the in-process server's mock IdP")
+	}
+	requirePostgres(t)
+}
+
+// browser stands in for a web browser during SSO logins: it keeps cookies,
+// and follows redirects through the IdP and the OIDC routes but stops at
+// the first one back into the app, so tests can see where a login lands.
+type browser struct {
+	*http.Client
+	app *url.URL
+}
+
+func newBrowser(t *testing.T) *browser {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := url.Parse(baseURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &browser{
+		app: app,
+		Client: &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Host == app.Host && !strings.HasPrefix(req.URL.Path, "/auth/oidc/") {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// get visits u and returns where it lands, with the body already closed.
+func (b *browser) get(t *testing.T, u string) *http.Response {
+	t.Helper()
+
+	resp, err := b.Get(u)
+	if err != nil {
+		t.Fatalf("GET %s: %v", u, err)
+	}
+	_ = resp.Body.Close()
+	return resp
+}
+
+// step visits u without following its redirect and returns where it
+// points.
+func (b *browser) step(t *testing.T, u string) string {
+	t.Helper()
+
+	c := *b.Client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := c.Get(u)
+	if err != nil {
+		t.Fatalf("GET %s: %v", u, err)
+	}
+	_ = resp.Body.Close()
+	next, err := resp.Location()
+	if err != nil {
+		t.Fatalf("GET %s: status = %d, want a redirect: %v", u, resp.StatusCode, err)
+	}
+	return next.String()
+}
+
+// login logs in with a password, replacing the browser's session.
+func (b *browser) login(t *testing.T, email, password string) {
+	t.Helper()
+
+	body, err := json.Marshal(sdk.LoginRequest{Email: email, Password: password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := b.Post(baseURL()+"/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("log in as %s: %v", email, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("log in as %s: status = %d, want 200", email, resp.StatusCode)
+	}
+}
+
+// session returns the headers that authenticate as the browser's session
+// cookie, or nil if it has none.
+func (b *browser) session() http.Header {
+	for _, c := range b.Jar.Cookies(b.app) {
+		if c.Name == "session_id" {
+			return http.Header{"Authorization": []string{"Token " + c.Value}}
+		}
+	}
+	return nil
+}
+
+// linkIdentity starts linking a provider's identity to the browser's
+// session the way the profile page does, and returns the IdP URL to visit.
+func (b *browser) linkIdentity(t *testing.T, provider string) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, baseURL()+"/v1/user/identities/"+provider+"/link", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range b.session() {
+		req.Header[k] = v
+	}
+	resp, err := b.Do(req)
+	if err != nil {
+		t.Fatalf("start linking %s: %v", provider, err)
+	}
+	defer resp.Body.Close()
+	var link sdk.V1IdentityLinkResponse
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("start linking %s: status = %d, want 200", provider, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&link); err != nil {
+		t.Fatalf("start linking %s: %v", provider, err)
+	}
+	return link.URL
+}
+
+// ssoUser returns the account b's SSO login landed on, purging it when the
+// test ends.
+func ssoUser(t *testing.T, b *browser) sdk.User {
+	t.Helper()
+
+	client, adminH := adminClient(t)
+	h := b.session()
+	if h == nil {
+		t.Fatal("SSO login did not set a session cookie")
+	}
+	u, err := client.GetProfile(h)
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = client.AdminDeleteAccount(adminH, fmt.Sprint(u.ID), sdk.DeleteAccountRequest{ConfirmEmail: u.Email, Purge: true})
+	})
+	return u
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	requireOIDC(t)
+	client := testClient(t)
+
+	email := uniqueName("sso") + "@example.com"
+	idp.SetClaims(map[string]any{
+		"sub":            uniqueName("subject"),
+		"email":          email,
+		"email_verified": true,
+		"given_name":     "Single",
+		"family_name":    "SignOn",
+	})
+
+	b := newBrowser(t)
+	resp := b.get(t, client.OIDCLoginURL("mock", "/files"))
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/files" {
+		t.Fatalf("SSO login landed on %d %q, want a redirect to /files", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	u := ssoUser(t, b)
+	if u.Email != email || u.FirstName != "Single" || u.LastName != "SignOn" || u.IsAdmin {
+		t.Errorf("provisioned user = %+v, want a non-admin %s named Single SignOn", u, email)
+	}
+
+	// Logging in again finds the same account through its linked identity.
+	again := newBrowser(t)
+	again.get(t, client.OIDCLoginURL("mock", "/files"))
+	h := again.session()
+	if h == nil {
+		t.Fatal("second SSO login did not set a session cookie")
+	}
+	if u2, err := client.GetProfile(h); err != nil || u2.ID != u.ID {
+		t.Errorf("second SSO login: user = %d, %v, want %d", u2.ID, err, u.ID)
+	}
+
+	idents, err := client.ListIdentities(h)
+	if err != nil {
+		t.Fatalf("ListIdentities: %v", err)
+	}
+	if len(idents) != 1 || idents[0].Provider != "mock" || idents[0].Email != email {
+		t.Errorf("identities = %+v, want one from mock for %s", idents, email)
+	}
+}
+
+func TestOIDCLoginDoesNotTakeOverAccount(t *testing.T) {
+	requireOIDC(t)
+	client, u := newUser(t)
+
+	idp.SetClaims(map[string]any{
+		"sub":            uniqueName("subject"),
+		"email":          u.Email,
+		"email_verified": true,
+	})
+
+	b := newBrowser(t)
+	if resp := b.get(t, client.OIDCLoginURL("mock", "/files")); resp.StatusCode != http.StatusConflict {
+		t.Errorf("SSO login with an existing account's email: status = %d, want 409", resp.StatusCode)
+	}
+	if b.session() != nil {
+		t.Error("SSO login with an existing account's email set a session cookie")
+	}
+	if idents, err := client.ListIdentities(u.Header); err != nil || len(idents) != 0 {
+		t.Errorf("identities of %s = %+v, %v, want none", u.Email, idents, err)
+	}
+}
+
+func TestOIDCCallbackNeedsStateCookie(t *testing.T) {
+	requireOIDC(t)
+	client := testClient(t)
+
+	idp.SetClaims(map[string]any{
+		"sub":            uniqueName("subject"),
+		"email":          uniqueName("sso") + "@example.com",
+		"email_verified": true,
+	})
+
+	started := newBrowser(t)
+	callback := started.step(t, started.step(t, client.OIDCLoginURL("mock", "/files")))
+
+	// A callback URL opened in another browser, e.g. one it was sent to,
+	// doesn't log that browser in.
+	other := newBrowser(t)
+	if resp := other.get(t, callback); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("callback in another browser: status = %d, want 400", resp.StatusCode)
+	}
+	if other.session() != nil {
+		t.Error("callback in another browser set a session cookie")
+	}
+
+	// The browser that started the login can still finish it.
+	resp := started.get(t, callback)
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/files" {
+		t.Fatalf("callback in the starting browser landed on %d %q, want a redirect to /files", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	ssoUser(t, started)
+}
+
+func TestOIDCLinkNeedsLinkingUser(t *testing.T) {
+	requireOIDC(t)
+	client, owner := newUser(t)
+	_, other := newUser(t)
+
+	idp.SetClaims(map[string]any{
+		"sub":            uniqueName("subject"),
+		"email":          owner.Email,
+		"email_verified": true,
+	})
+
+	b := newBrowser(t)
+	b.login(t, owner.Email, owner.Password)
+	authURL := b.linkIdentity(t, "mock")
+
+	// Someone else logs in in the same browser before the link finishes.
+	b.login(t, other.Email, other.Password)
+	if resp := b.get(t, authURL); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("finishing the link as another user: status = %d, want 403", resp.StatusCode)
+	}
+	for _, u := range []testUser{owner, other} {
+		if idents, err := client.ListIdentities(u.Header); err != nil || len(idents) != 0 {
+			t.Errorf("identities of %s = %+v, %v, want none", u.Email, idents, err)
+		}
+	}
+
+	b.login(t, owner.Email, owner.Password)
+	resp := b.get(t, b.linkIdentity(t, "mock"))
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/profile" {
+		t.Fatalf("link landed on %d %q, want a redirect to /profile", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	idents, err := client.ListIdentities(owner.Header)
+	if err != nil {
+		t.Fatalf("ListIdentities: %v", err)
+	}
+	if len(idents) != 1 || idents[0].Provider != "mock" {
+		t.Errorf("identities = %+v, want one from mock", idents)
+	}
+}
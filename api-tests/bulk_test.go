@@ -16,7 +16,7 @@ func TestBulkDeleteAndRestore(t *testing.T) {
 	if err := client.CreateFolder(h, folderName, ""); err != nil {
 		t.Fatalf("CreateFolder: %v", err)
 	}
-	if err := client.UploadFile(h, fileName, strings.NewReader("bulk test content"), ""); err != nil {
+	if _, err := client.UploadFile(h, fileName, strings.NewReader("bulk test content"), ""); err != nil {
 		t.Fatalf("UploadFile: %v", err)
 	}
 
@@ -39,7 +39,7 @@ func TestBulkDeleteAndRestore(t *testing.T) {
 		t.Fatalf("expected both test items gone from root listing after bulk delete")
 	}
 
-	trash, err := client.ListTrash(h, 1, 200)
+	trash, err := client.ListTrash(h, sdk.ListOptions{Limit: 200})
 	if err != nil {
 		t.Fatalf("ListTrash: %v", err)
 	}
@@ -65,9 +65,7 @@ func TestBulkDeleteAndRestore(t *testing.T) {
 	if _, err := client.DeleteFolder(h, folder.UUID); err != nil {
 		t.Fatalf("DeleteFolder (cleanup): %v", err)
 	}
-	if err := client.PurgeFolder(h, folder.UUID); err != nil {
-		t.Fatalf("PurgeFolder (cleanup): %v", err)
-	}
+	purgeFolder(t, client, h, folder.UUID)
 	if err := client.DeleteFile(h, file.UUID); err != nil {
 		t.Fatalf("DeleteFile (cleanup): %v", err)
 	}
@@ -89,7 +87,7 @@ func TestBulkMove(t *testing.T) {
 	if err := client.CreateFolder(h, srcName, ""); err != nil {
 		t.Fatalf("CreateFolder (src): %v", err)
 	}
-	if err := client.UploadFile(h, fileName, strings.NewReader("bulk move test content"), ""); err != nil {
+	if _, err := client.UploadFile(h, fileName, strings.NewReader("bulk move test content"), ""); err != nil {
 		t.Fatalf("UploadFile: %v", err)
 	}
 
@@ -114,7 +112,7 @@ func TestBulkMove(t *testing.T) {
 		t.Fatalf("expected moved items gone from root listing after bulk move")
 	}
 
-	contents, err := client.ListFolderContents(h, dest.UUID, 1, 200)
+	contents, err := client.ListFolderContents(h, dest.UUID, sdk.ListOptions{Limit: 200})
 	if err != nil {
 		t.Fatalf("ListFolderContents (dest): %v", err)
 	}
@@ -134,7 +132,5 @@ func TestBulkMove(t *testing.T) {
 	if _, err := client.DeleteFolder(h, dest.UUID); err != nil {
 		t.Fatalf("DeleteFolder (cleanup): %v", err)
 	}
-	if err := client.PurgeFolder(h, dest.UUID); err != nil {
-		t.Fatalf("PurgeFolder (cleanup): %v", err)
-	}
+	purgeFolder(t, client, h, dest.UUID)
 }
@@ -1,15 +1,22 @@
 package apitests
 
 import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
 	"strings"
 	"testing"
+
+	"avenue/backend/sdk"
 )
 
 func TestFileLifecycle(t *testing.T) {
 	client, h := authedClient(t)
 	name := uniqueName("file") + ".txt"
 
-	if err := client.UploadFile(h, name, strings.NewReader("hello from api-tests"), ""); err != nil {
+	if _, err := client.UploadFile(h, name, strings.NewReader("hello from api-tests"), ""); err != nil {
 		t.Fatalf("UploadFile: %v", err)
 	}
 
@@ -38,7 +45,7 @@ func TestFileLifecycle(t *testing.T) {
 		t.Fatalf("trashed file %q still present in root listing", newName)
 	}
 
-	trash, err := client.ListTrash(h, 1, 200)
+	trash, err := client.ListTrash(h, sdk.ListOptions{Limit: 200})
 	if err != nil {
 		t.Fatalf("ListTrash: %v", err)
 	}
@@ -47,7 +54,7 @@ func TestFileLifecycle(t *testing.T) {
 	}
 
 	// Restore it.
-	if err := client.RestoreFile(h, file.UUID); err != nil {
+	if _, err := client.RestoreFile(h, file.UUID); err != nil {
 		t.Fatalf("RestoreFile: %v", err)
 	}
 	if findItem(listRoot(t, client, h), "file", newName) == nil {
@@ -62,3 +69,85 @@ func TestFileLifecycle(t *testing.T) {
 		t.Fatalf("PurgeFile (cleanup): %v", err)
 	}
 }
+
+// readAll reads and closes resp's body, failing the test if it can't.
+func readAll(t *testing.T, resp *http.Response) []byte {
+	t.Helper()
+	defer func() { _ = resp.Body.Close() }()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	return b
+}
+
+// zipNames returns the names of the entries in the zip archive b.
+func zipNames(t *testing.T, b []byte) []string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	var out []string
+	for _, f := range zr.File {
+		out = append(out, f.Name)
+	}
+	return out
+}
+
+func TestFileMoveAndDownload(t *testing.T) {
+	client, h := authedClient(t)
+	from := createFolder(t, client, h, "move-from", "")
+	to := createFolder(t, client, h, "move-to", "")
+
+	const content = "downloaded intact"
+	file := upload(t, client, h, uniqueName("file")+".txt", content, from.UUID)
+	if file.UUID == "" || file.FileSize != int64(len(content)) {
+		t.Fatalf("UploadFile = %+v, want a UUID and a size of %d", file, len(content))
+	}
+
+	files, err := client.ListFiles(h)
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	if !slices.ContainsFunc(files, func(f sdk.File) bool { return f.UUID == file.UUID }) {
+		t.Errorf("uploaded file %s not in ListFiles", file.Name)
+	}
+
+	resp, err := client.DownloadFile(h, file.UUID)
+	if err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if got := string(readAll(t, resp)); got != content {
+		t.Errorf("downloaded %q, want %q", got, content)
+	}
+
+	if err := client.MoveFile(h, file.UUID, to.UUID); err != nil {
+		t.Fatalf("MoveFile: %v", err)
+	}
+	contents, err := client.ListFolderContents(h, to.UUID, sdk.ListOptions{})
+	if err != nil {
+		t.Fatalf("ListFolderContents: %v", err)
+	}
+	if findItem(contents.Items, "file", file.Name) == nil {
+		t.Fatalf("moved file %q not found in its new folder", file.Name)
+	}
+
+	// One loose file plus the folder it now lives in: the archive holds it
+	// once for each.
+	loose := upload(t, client, h, uniqueName("loose")+".txt", "loose", from.UUID)
+	resp, err = client.DownloadFilesZip(h, sdk.DownloadFilesZipRequest{
+		FileIDs:   []string{loose.UUID},
+		FolderIDs: []string{to.UUID},
+	})
+	if err != nil {
+		t.Fatalf("DownloadFilesZip: %v", err)
+	}
+	entries := zipNames(t, readAll(t, resp))
+	hasSuffix := func(suffix string) bool {
+		return slices.ContainsFunc(entries, func(name string) bool { return strings.HasSuffix(name, suffix) })
+	}
+	if !hasSuffix(loose.Name) || !hasSuffix(to.Name+"/"+file.Name) {
+		t.Errorf("zip entries = %v, want %s and %s/%s", entries, loose.Name, to.Name, file.Name)
+	}
+}
@@ -1,7 +1,10 @@
 package apitests
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strings"
 	"testing"
 
 	"avenue/backend/sdk"
@@ -19,13 +22,58 @@ func findItem(items []sdk.FolderItem, itemType, name string) *sdk.FolderItem {
 
 func listRoot(t *testing.T, client *sdk.Client, h http.Header) []sdk.FolderItem {
 	t.Helper()
-	contents, err := client.ListFolderContents(h, "", 1, 200)
+	contents, err := client.ListRootFolderContents(h, sdk.ListOptions{Limit: 200})
 	if err != nil {
-		t.Fatalf("ListFolderContents: %v", err)
+		t.Fatalf("ListRootFolderContents: %v", err)
 	}
 	return contents.Items
 }
 
+// createFolder creates a uniquely-named folder in parent ("" for the root)
+// and returns it. It's trashed and purged, with everything in it, when the
+// test ends.
+func createFolder(t *testing.T, client *sdk.Client, h http.Header, prefix, parent string) sdk.FolderItem {
+	t.Helper()
+	name := uniqueName(prefix)
+	if err := client.CreateFolder(h, name, parent); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+
+	for item, err := range as(client, h).FolderItems(context.Background(), parent, sdk.ListOptions{Type: sdk.FolderItemTypeFolder}) {
+		if err != nil {
+			t.Fatalf("FolderItems: %v", err)
+		}
+		if item.Name == name {
+			t.Cleanup(func() {
+				_, _ = client.DeleteFolder(h, item.UUID)
+				_, _ = client.PurgeFolder(h, item.UUID)
+			})
+			return item
+		}
+	}
+	t.Fatalf("created folder %q not found in its parent", name)
+	return sdk.FolderItem{}
+}
+
+// upload uploads content as name into parent, failing the test if it can't.
+func upload(t *testing.T, client *sdk.Client, h http.Header, name, content, parent string) sdk.File {
+	t.Helper()
+	file, err := client.UploadFile(h, name, strings.NewReader(content), parent)
+	if err != nil {
+		t.Fatalf("UploadFile(%s): %v", name, err)
+	}
+	return file
+}
+
+// names returns the names of items, in order.
+func names(items []sdk.FolderItem) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = item.Name
+	}
+	return out
+}
+
 func TestFolderLifecycle(t *testing.T) {
 	client, h := authedClient(t)
 	name := uniqueName("folder")
@@ -56,7 +104,7 @@ func TestFolderLifecycle(t *testing.T) {
 		t.Fatalf("trashed folder %q still present in root listing", newName)
 	}
 
-	trash, err := client.ListTrash(h, 1, 200)
+	trash, err := client.ListTrash(h, sdk.ListOptions{Limit: 200})
 	if err != nil {
 		t.Fatalf("ListTrash: %v", err)
 	}
@@ -65,7 +113,7 @@ func TestFolderLifecycle(t *testing.T) {
 	}
 
 	// Restore it.
-	if err := client.RestoreFolder(h, folder.UUID); err != nil {
+	if _, err := client.RestoreFolder(h, folder.UUID); err != nil {
 		t.Fatalf("RestoreFolder: %v", err)
 	}
 	if findItem(listRoot(t, client, h), "folder", newName) == nil {
@@ -76,9 +124,7 @@ func TestFolderLifecycle(t *testing.T) {
 	if _, err := client.DeleteFolder(h, folder.UUID); err != nil {
 		t.Fatalf("DeleteFolder (cleanup): %v", err)
 	}
-	if err := client.PurgeFolder(h, folder.UUID); err != nil {
-		t.Fatalf("PurgeFolder (cleanup): %v", err)
-	}
+	purgeFolder(t, client, h, folder.UUID)
 }
 
 func TestFolderListSortByName(t *testing.T) {
@@ -99,12 +145,12 @@ func TestFolderListSortByName(t *testing.T) {
 		for _, name := range []string{first, second} {
 			if item := findItem(listRoot(t, client, h), "folder", name); item != nil {
 				_, _ = client.DeleteFolder(h, item.UUID)
-				_ = client.PurgeFolder(h, item.UUID)
+				_, _ = client.PurgeFolder(h, item.UUID)
 			}
 		}
 	})
 
-	contents, err := client.ListFolderContents(h, "", 1, 200)
+	contents, err := client.ListFolderContents(h, "", sdk.ListOptions{Limit: 200})
 	if err != nil {
 		t.Fatalf("ListFolderContents: %v", err)
 	}
@@ -127,3 +173,122 @@ func TestFolderListSortByName(t *testing.T) {
 		t.Errorf("expected %q (idx %d) to sort before %q (idx %d) in default (name asc) order", first, firstIdx, second, secondIdx)
 	}
 }
+
+func TestFolderListOptions(t *testing.T) {
+	client, h := authedClient(t)
+	folder := createFolder(t, client, h, "list-options", "")
+
+	sub := createFolder(t, client, h, "sub", folder.UUID)
+	upload(t, client, h, "small.txt", "s", folder.UUID)
+	upload(t, client, h, "large.txt", "large file", folder.UUID)
+
+	files, err := client.ListFolderContents(h, folder.UUID, sdk.ListOptions{
+		Type:   sdk.FolderItemTypeFile,
+		SortBy: sdk.FolderSortBySize,
+		Sort:   sdk.SortDesc,
+	})
+	if err != nil {
+		t.Fatalf("ListFolderContents (files by size): %v", err)
+	}
+	if got := strings.Join(names(files.Items), ","); got != "large.txt,small.txt" {
+		t.Errorf("files by size, descending = %s, want large.txt,small.txt", got)
+	}
+
+	folders, err := client.ListFolderContents(h, folder.UUID, sdk.ListOptions{Type: sdk.FolderItemTypeFolder})
+	if err != nil {
+		t.Fatalf("ListFolderContents (folders): %v", err)
+	}
+	if len(folders.Items) != 1 || folders.Items[0].UUID != sub.UUID {
+		t.Errorf("folders = %v, want only %s", names(folders.Items), sub.Name)
+	}
+
+	page, err := client.ListFolderContents(h, folder.UUID, sdk.ListOptions{Page: 2, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListFolderContents (page 2): %v", err)
+	}
+	if page.Total != 3 || len(page.Items) != 1 {
+		t.Errorf("page 2 of 2 = %v of %d, want 1 item of 3", names(page.Items), page.Total)
+	}
+
+	var all []sdk.FolderItem
+	for item, err := range as(client, h).FolderItems(context.Background(), folder.UUID, sdk.ListOptions{Limit: 1, SortBy: sdk.FolderSortByName}) {
+		if err != nil {
+			t.Fatalf("FolderItems: %v", err)
+		}
+		all = append(all, item)
+	}
+	// Folders come first, then files by name.
+	want := sub.Name + ",large.txt,small.txt"
+	if got := strings.Join(names(all), ","); got != want {
+		t.Errorf("FolderItems = %s, want %s", got, want)
+	}
+
+	root, err := client.ListRootFolderContents(h, sdk.ListOptions{Type: sdk.FolderItemTypeFile, Limit: 1})
+	if err != nil {
+		t.Fatalf("ListRootFolderContents: %v", err)
+	}
+	for _, item := range root.Items {
+		if item.Type != sdk.FolderItemTypeFile {
+			t.Errorf("root listing of files has a %s: %s", item.Type, item.Name)
+		}
+	}
+}
+
+func TestSearchFolder(t *testing.T) {
+	client, h := authedClient(t)
+	folder := createFolder(t, client, h, "search", "")
+
+	sub := createFolder(t, client, h, "old-reports", folder.UUID)
+	upload(t, client, h, "report.txt", "q3", folder.UUID)
+	upload(t, client, h, "summary-report.txt", "q4", folder.UUID)
+
+	files, err := client.SearchFiles(h, folder.UUID, "rep")
+	if err != nil {
+		t.Fatalf("SearchFiles: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "report.txt" {
+		t.Errorf("SearchFiles(rep) = %+v, want only report.txt", files)
+	}
+
+	items, err := client.SearchFolder(h, folder.UUID, "REP")
+	if err != nil {
+		t.Fatalf("SearchFolder: %v", err)
+	}
+	// The folder matches anywhere in its name, ignoring case; files only
+	// match by prefix.
+	if got := strings.Join(names(items), ","); got != sub.Name {
+		t.Errorf("SearchFolder(REP) = %s, want %s", got, sub.Name)
+	}
+
+	items, err = client.SearchFolder(h, folder.UUID, "rep")
+	if err != nil {
+		t.Fatalf("SearchFolder: %v", err)
+	}
+	if got := strings.Join(names(items), ","); got != sub.Name+",report.txt" {
+		t.Errorf("SearchFolder(rep) = %s, want %s,report.txt", got, sub.Name)
+	}
+}
+
+func TestFolderQuota(t *testing.T) {
+	client, h := authedClient(t)
+	folder := createFolder(t, client, h, "quota", "")
+
+	quotas, err := client.SetFolderQuota(h, folder.UUID, 4)
+	if err != nil {
+		t.Fatalf("SetFolderQuota: %v", err)
+	}
+	if len(quotas) == 0 || quotas[0].Quota != 4 {
+		t.Fatalf("SetFolderQuota = %+v, want the folder's own quota of 4 first", quotas)
+	}
+
+	_, err = client.UploadFile(h, "big.txt", strings.NewReader("too big"), folder.UUID)
+	if !errors.Is(err, sdk.ErrQuotaExceeded) {
+		t.Errorf("upload over the folder quota: err = %v, want ErrQuotaExceeded", err)
+	}
+	upload(t, client, h, "ok.txt", "fits", folder.UUID)
+
+	if _, err := client.SetFolderQuota(h, folder.UUID, 0); err != nil {
+		t.Fatalf("SetFolderQuota (remove): %v", err)
+	}
+	upload(t, client, h, "big.txt", "too big", folder.UUID)
+}
@@ -3,30 +3,88 @@
 // network using the sdk.Client — the same client the CLI and any future
 // tooling would use — rather than calling handlers/db functions directly.
 //
-// These tests need a live server to talk to. Bring one up with:
+// By default they start the server in-process with package handlertest,
+// on the in-memory store, so they run hermetically as part of
+// `go test ./...`; tests of routes that need Postgres skip themselves
+// unless AVENUE_TEST_DB_HOST names a Postgres server for handlertest to
+// create a throwaway database on. To test a server that's already running
+// instead, e.g. one brought up with:
 //
 //	docker-compose up -d
 //
-// then run:
+// run:
 //
-//	go test ./api-tests/... -v
+//	AVENUE_TEST_BASE_URL=http://localhost:8080 go test ./api-tests/... -v
 //
-// If no server is reachable at the configured base URL, every test skips
-// itself (rather than failing) so `go test ./...` stays safe to run without
-// one. See README.md in this directory for the full list of env vars.
+// If no server is reachable at that URL, every test skips itself (rather
+// than failing). See README.md in this directory for the full list of env
+// vars.
 package apitests
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"avenue/backend/auth"
+	"avenue/backend/auth/authtest"
+	"avenue/backend/handlers/handlertest"
 	"avenue/backend/sdk"
 )
 
+// hermetic is the in-process server the tests run against when
+// AVENUE_TEST_BASE_URL is unset, or nil when they run against that URL.
+var hermetic *handlertest.Server
+
+// idp is the mock identity provider the in-process server offers as the
+// "mock" SSO provider, or nil when running against AVENUE_TEST_BASE_URL.
+var idp *authtest.IdP
+
+// mailbox holds the mail the in-process server sends, or is nil when
+// running against AVENUE_TEST_BASE_URL.
+var mailbox *handlertest.Mailbox
+
+func TestMain(m *testing.M) {
+	if os.Getenv("AVENUE_TEST_BASE_URL") == "" {
+		idp = authtest.NewIdP("avenue")
+		provider, err := auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+			ID:        "mock",
+			IssuerURL: idp.Issuer(),
+			ClientID:  idp.ClientID,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "api-tests: set up mock IdP: %v\n", err)
+			os.Exit(1)
+		}
+		mailbox = handlertest.NewMailbox()
+		srv, err := handlertest.Start(handlertest.Options{
+			Postgres: handlertest.PostgresFromEnv(),
+			OIDC:     []*auth.OIDCProvider{provider},
+			Email:    mailbox,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "api-tests: start server: %v\n", err)
+			os.Exit(1)
+		}
+		hermetic = srv
+	}
+
+	code := m.Run()
+	if hermetic != nil {
+		if err := hermetic.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "api-tests: stop server: %v\n", err)
+		}
+		idp.Close()
+	}
+	os.Exit(code)
+}
+
 func getenv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -35,7 +93,49 @@ func getenv(key, fallback string) string {
 }
 
 func baseURL() string {
-	return getenv("AVENUE_TEST_BASE_URL", "http://localhost:8080")
+	if hermetic != nil {
+		return hermetic.URL
+	}
+	return os.Getenv("AVENUE_TEST_BASE_URL")
+}
+
+// hasPostgres reports whether the server under test keeps its data in
+// Postgres: always for a running server, and for the in-process one when
+// AVENUE_TEST_DB_HOST is set. The in-memory store lacks what package db
+// alone keeps, such as the job queue.
+func hasPostgres() bool {
+	return hermetic == nil || hermetic.Postgres
+}
+
+// requirePostgres skips the calling test unless hasPostgres.
+func requirePostgres(t *testing.T) {
+	t.Helper()
+	if !hasPostgres() {
+		t.Skip("skipping: needs Postgres; set AVENUE_TEST_DB_HOST to run it in-process")
+	}
+}
+
+// requireMailbox skips the calling test unless the server's mail goes to
+// mailbox, which takes the in-process server on Postgres.
+func requireMailbox(t *testing.T) {
+	t.Helper()
+	if mailbox == nil {
+		t.Skip("skipping: needs the in-process server's mailbox")
+	}
+	requirePostgres(t)
+}
+
+// purgeFolder permanently deletes a trashed folder the test made. Purges
+// run on the job queue, so on the in-memory store it's left for the
+// server to throw away with everything else.
+func purgeFolder(t *testing.T, client *sdk.Client, h http.Header, folderID string) {
+	t.Helper()
+	if !hasPostgres() {
+		return
+	}
+	if _, err := client.PurgeFolder(h, folderID); err != nil {
+		t.Fatalf("PurgeFolder (cleanup): %v", err)
+	}
 }
 
 // uniqueName returns a name unlikely to collide with anything already in the
@@ -53,7 +153,10 @@ func testClient(t *testing.T) *sdk.Client {
 	t.Helper()
 
 	client := sdk.NewClient(baseURL())
-	if _, err := client.Ping(http.Header{}); err != nil {
+	// Probe without retries: a server that isn't running won't turn up.
+	probe := *client
+	probe.Retry = sdk.RetryPolicy{}
+	if _, err := probe.Ping(http.Header{}); err != nil {
 		t.Skipf("skipping: could not reach Avenue server at %s: %v", baseURL(), err)
 	}
 	return client
@@ -112,3 +215,106 @@ func authedClient(t *testing.T) (*sdk.Client, http.Header) {
 
 	return client, h
 }
+
+// admin is the admin session shared by every test in the run: the server
+// only lets an email log in a few times in a while, so it's logged in once
+// and left to expire.
+var admin struct {
+	once sync.Once
+	h    http.Header
+	err  error
+}
+
+// adminClient returns a client plus the Authorization header for an admin:
+// the in-process server's root user, or else whoever logs in with
+// AVENUE_TEST_ADMIN_EMAIL / AVENUE_TEST_ADMIN_PASSWORD (defaulting to the
+// root user docker-compose seeds). Tests calling it skip themselves if that
+// account can't log in or isn't an admin.
+func adminClient(t *testing.T) (*sdk.Client, http.Header) {
+	t.Helper()
+
+	client := testClient(t)
+	admin.once.Do(func() {
+		if hermetic != nil {
+			admin.h = hermetic.Admin.Header
+			return
+		}
+		email := getenv("AVENUE_TEST_ADMIN_EMAIL", "admin@example.com")
+		password := getenv("AVENUE_TEST_ADMIN_PASSWORD", "password")
+
+		login, err := client.Login(http.Header{}, sdk.LoginRequest{Email: email, Password: password})
+		if err != nil {
+			admin.err = fmt.Errorf("could not log in as %s: %w", email, err)
+			return
+		}
+		h := http.Header{"Authorization": []string{"Token " + login.SessionID}}
+		me, err := client.GetProfile(h)
+		if err != nil {
+			admin.err = fmt.Errorf("GetProfile: %w", err)
+			return
+		}
+		if !me.IsAdmin {
+			admin.err = fmt.Errorf("%s isn't an admin", email)
+			return
+		}
+		admin.h = h
+	})
+	if admin.err != nil {
+		t.Skipf("skipping: %v (set AVENUE_TEST_ADMIN_EMAIL/AVENUE_TEST_ADMIN_PASSWORD to match an admin account)", admin.err)
+	}
+	return client, admin.h
+}
+
+// as returns a copy of client that authenticates with the session in h,
+// for the calls that take a context rather than headers, such as the
+// iterators.
+func as(client *sdk.Client, h http.Header) *sdk.Client {
+	c := *client
+	c.Auth = sdk.TokenAuth(strings.TrimPrefix(h.Get("Authorization"), "Token "))
+	return &c
+}
+
+// testUser is a throwaway account made by newUser.
+type testUser struct {
+	sdk.User
+	Password string
+	// Header authenticates as the user.
+	Header http.Header
+}
+
+// newUser has an admin create a fresh, randomly-named user and logs in as
+// them, for tests that change the account itself (its password, sessions,
+// or existence) and so can't share one. The account is purged when the test
+// ends, if it's still there; account deletion needs Postgres, so on the
+// in-memory store it's left for the server to throw away.
+func newUser(t *testing.T) (*sdk.Client, testUser) {
+	t.Helper()
+
+	client, adminH := adminClient(t)
+	password := "Sup3rSecretPassw0rd!"
+	u, err := client.CreateUser(adminH, sdk.CreateUserRequest{
+		Email:     uniqueName("apitest") + "@example.com",
+		Password:  &password,
+		FirstName: "API",
+		LastName:  "Test",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	t.Cleanup(func() {
+		if !hasPostgres() {
+			return
+		}
+		_, _ = client.AdminDeleteAccount(adminH, fmt.Sprint(u.ID), sdk.DeleteAccountRequest{ConfirmEmail: u.Email, Purge: true})
+	})
+
+	login, err := client.Login(http.Header{}, sdk.LoginRequest{Email: u.Email, Password: password})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	return client, testUser{
+		User:     u,
+		Password: password,
+		Header:   http.Header{"Authorization": []string{"Token " + login.SessionID}},
+	}
+}
@@ -0,0 +1,270 @@
+package apitests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+// waitFor polls done every so often until it reports true, failing the test
+// if that takes more than 30 seconds.
+func waitFor(t *testing.T, what string, done func() (bool, error)) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		ok, err := done()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", what, err)
+		}
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gave up waiting for %s", what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// waitForJob waits for a queued job to finish, failing the test unless it
+// succeeds.
+func waitForJob(t *testing.T, client *sdk.Client, h http.Header, jobID int64) sdk.Job {
+	t.Helper()
+	var job sdk.Job
+	waitFor(t, "job to finish", func() (bool, error) {
+		var err error
+		job, err = client.GetJob(h, jobID)
+		return job.Finished(), err
+	})
+	if job.Status != sdk.JobStatusDone {
+		t.Fatalf("job %d %s: %s", job.ID, job.Status, job.Error)
+	}
+	return job
+}
+
+func TestArchiveJob(t *testing.T) {
+	client, h := authedClient(t)
+	folder := createFolder(t, client, h, "archive", "")
+	file := upload(t, client, h, "inside.txt", "archived", folder.UUID)
+
+	job, err := client.CreateArchiveJob(h, sdk.DownloadFilesZipRequest{FolderIDs: []string{folder.UUID}})
+	if err != nil {
+		t.Fatalf("CreateArchiveJob: %v", err)
+	}
+	if job.Kind != sdk.JobKindArchive {
+		t.Errorf("job kind = %s, want %s", job.Kind, sdk.JobKindArchive)
+	}
+	waitForJob(t, client, h, job.ID)
+
+	resp, err := client.DownloadJobArchive(h, job.ID)
+	if err != nil {
+		t.Fatalf("DownloadJobArchive: %v", err)
+	}
+	entries := zipNames(t, readAll(t, resp))
+	if !slices.Contains(entries, folder.Name+"/"+file.Name) {
+		t.Errorf("archive entries = %v, want %s/%s", entries, folder.Name, file.Name)
+	}
+
+	listed, err := client.ListJobs(h, sdk.JobStatusDone, 1, 50)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if !slices.ContainsFunc(listed.Jobs, func(j sdk.Job) bool { return j.ID == job.ID }) {
+		t.Errorf("finished job %d not in ListJobs(done)", job.ID)
+	}
+	found := false
+	for j, err := range as(client, h).Jobs(context.Background(), "", 1) {
+		if err != nil {
+			t.Fatalf("Jobs: %v", err)
+		}
+		if j.ID == job.ID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("job %d not found by the Jobs iterator", job.ID)
+	}
+
+	var apiErr *sdk.APIError
+	_, err = client.CancelJob(h, job.ID)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("CancelJob on a finished job: err = %v, want a 409", err)
+	}
+}
+
+func TestBulkCopy(t *testing.T) {
+	requirePostgres(t)
+	client, h := authedClient(t)
+	src := createFolder(t, client, h, "copy-src", "")
+	dest := createFolder(t, client, h, "copy-dest", "")
+	file := upload(t, client, h, "copied.txt", "copy me", src.UUID)
+
+	job, err := client.BulkCopy(h, sdk.BulkCopyRequest{
+		FileIDs:   []string{file.UUID},
+		FolderIDs: []string{src.UUID},
+		Parent:    dest.UUID,
+	})
+	if err != nil {
+		t.Fatalf("BulkCopy: %v", err)
+	}
+	if job.Kind != sdk.JobKindCopy {
+		t.Errorf("job kind = %s, want %s", job.Kind, sdk.JobKindCopy)
+	}
+	job = waitForJob(t, client, h, job.ID)
+	var result sdk.CopyResult
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		t.Fatalf("decode copy result: %v", err)
+	}
+	if result.Files != 2 {
+		t.Errorf("copy result = %+v, want 2 files copied", result)
+	}
+
+	contents, err := client.ListFolderContents(h, dest.UUID, sdk.ListOptions{})
+	if err != nil {
+		t.Fatalf("ListFolderContents: %v", err)
+	}
+	if findItem(contents.Items, "file", file.Name) == nil || findItem(contents.Items, "folder", src.Name) == nil {
+		t.Errorf("destination holds %v, want a copy of %s and of %s", names(contents.Items), file.Name, src.Name)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	client, h := authedClient(t)
+	folder := createFolder(t, client, h, "extract", "")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"one.txt", "nested/two.txt"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := client.UploadFile(h, "bundle.zip", &buf, folder.UUID)
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	job, err := client.ExtractArchive(h, archive.UUID, sdk.ExtractArchiveRequest{Folder: folder.UUID})
+	if err != nil {
+		t.Fatalf("ExtractArchive: %v", err)
+	}
+	if job.Kind != sdk.JobKindExtract {
+		t.Errorf("job kind = %s, want %s", job.Kind, sdk.JobKindExtract)
+	}
+	job = waitForJob(t, client, h, job.ID)
+	var result sdk.ExtractResult
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		t.Fatalf("decode extraction result: %v", err)
+	}
+	if result.Entries != 2 {
+		t.Errorf("extraction result = %+v, want 2 entries", result)
+	}
+
+	listed, err := client.ListJobs(h, sdk.JobStatusDone, 1, 50)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if !slices.ContainsFunc(listed.Jobs, func(j sdk.Job) bool { return j.ID == job.ID }) {
+		t.Errorf("extraction job %d not in ListJobs(done)", job.ID)
+	}
+	contents, err := client.ListFolderContents(h, folder.UUID, sdk.ListOptions{})
+	if err != nil {
+		t.Fatalf("ListFolderContents: %v", err)
+	}
+	if findItem(contents.Items, "file", "one.txt") == nil || findItem(contents.Items, "folder", "nested") == nil {
+		t.Errorf("folder holds %v, want one.txt and nested", names(contents.Items))
+	}
+
+	// An archive that climbs out of the folder fails the job.
+	buf.Reset()
+	zw = zip.NewWriter(&buf)
+	if _, err := zw.Create("../escape.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	hostile, err := client.UploadFile(h, "hostile.zip", &buf, folder.UUID)
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	job, err = client.ExtractArchive(h, hostile.UUID, sdk.ExtractArchiveRequest{Folder: folder.UUID})
+	wantJobFailed(t, client, h, "ExtractArchive of a zip-slip archive", job, err, "invalid archive")
+
+	// So does one that won't fit in the folder.
+	if _, err := client.SetFolderQuota(h, folder.UUID, 1); err != nil {
+		t.Fatalf("SetFolderQuota: %v", err)
+	}
+	job, err = client.ExtractArchive(h, archive.UUID, sdk.ExtractArchiveRequest{Folder: folder.UUID})
+	wantJobFailed(t, client, h, "ExtractArchive past a folder quota", job, err, "quota")
+}
+
+// wantJobFailed checks that the job a request started failed with an error
+// containing msg, whether the request answered with the failure or with a
+// job still running.
+func wantJobFailed(t *testing.T, client *sdk.Client, h http.Header, what string, job sdk.Job, err error, msg string) {
+	t.Helper()
+	var apiErr *sdk.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode != http.StatusInternalServerError || !strings.Contains(apiErr.Error_, msg) {
+			t.Errorf("%s: got %d %q, want a 500 about %s", what, apiErr.StatusCode, apiErr.Error_, msg)
+		}
+		return
+	case err != nil:
+		t.Fatalf("%s: %v", what, err)
+	}
+	waitFor(t, "job to finish", func() (bool, error) {
+		var err error
+		job, err = client.GetJob(h, job.ID)
+		return job.Finished(), err
+	})
+	if job.Status != sdk.JobStatusFailed || !strings.Contains(job.Error, msg) {
+		t.Errorf("%s: job %d is %s with error %q, want failed about %s", what, job.ID, job.Status, job.Error, msg)
+	}
+}
+
+func TestAdminJobs(t *testing.T) {
+	client, h := adminClient(t)
+	_, u := newUser(t)
+
+	// Whether or not the server allows imports, a path that climbs out of
+	// the import directory is refused.
+	if _, err := client.AdminImport(h, sdk.ImportRequest{UserID: u.ID, Path: "../.."}); err == nil {
+		t.Error("AdminImport accepted a path outside the import directory")
+	}
+
+	file := upload(t, client, u.Header, "mine.txt", "mine", "")
+	userJob, err := client.CreateArchiveJob(u.Header, sdk.DownloadFilesZipRequest{FileIDs: []string{file.UUID}})
+	if err != nil {
+		t.Fatalf("CreateArchiveJob: %v", err)
+	}
+	listed, err := client.AdminListJobs(h, "", 1, 100)
+	if err != nil {
+		t.Fatalf("AdminListJobs: %v", err)
+	}
+	if !slices.ContainsFunc(listed.Jobs, func(j sdk.Job) bool { return j.ID == userJob.ID }) {
+		t.Errorf("another user's job %d not in AdminListJobs", userJob.ID)
+	}
+	if _, err := client.AdminListJobs(u.Header, "", 1, 10); err == nil {
+		t.Error("AdminListJobs succeeded for a non-admin")
+	}
+	if _, err := client.ListJobs(h, "bogus", 1, 10); err == nil {
+		t.Error("ListJobs accepted an unknown status")
+	}
+}
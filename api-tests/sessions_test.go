@@ -0,0 +1,81 @@
+package apitests
+
+import (
+	"net/http"
+	"testing"
+
+	"avenue/backend/sdk"
+)
+
+func TestSessions(t *testing.T) {
+	client, u := newUser(t)
+
+	login := func() http.Header {
+		t.Helper()
+		resp, err := client.Login(http.Header{}, sdk.LoginRequest{Email: u.Email, Password: u.Password})
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		return http.Header{"Authorization": []string{"Token " + resp.SessionID}}
+	}
+	second, third := login(), login()
+
+	sessions, err := client.ListSessions(u.Header)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("ListSessions = %d sessions, want 3", len(sessions))
+	}
+	var current int64
+	for _, s := range sessions {
+		if s.IsCurrent {
+			if current != 0 {
+				t.Fatal("more than one session is marked current")
+			}
+			current = s.ID
+		}
+	}
+	if current == 0 {
+		t.Fatal("no session is marked current")
+	}
+	if err := client.RevokeSession(u.Header, current); err == nil {
+		t.Error("RevokeSession revoked the session making the request")
+	}
+
+	// Revoke the second login by finding the one session it sees as current.
+	seen, err := client.ListSessions(second)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	for _, s := range seen {
+		if s.IsCurrent {
+			if err := client.RevokeSession(u.Header, s.ID); err != nil {
+				t.Fatalf("RevokeSession: %v", err)
+			}
+		}
+	}
+	if _, err := client.PingSession(second); err == nil {
+		t.Error("PingSession succeeded with a revoked session")
+	}
+	if _, err := client.PingSession(third); err != nil {
+		t.Fatalf("PingSession: %v", err)
+	}
+
+	if err := client.RevokeOtherSessions(u.Header); err != nil {
+		t.Fatalf("RevokeOtherSessions: %v", err)
+	}
+	if _, err := client.PingSession(third); err == nil {
+		t.Error("PingSession succeeded after RevokeOtherSessions")
+	}
+	if _, err := client.PingSession(u.Header); err != nil {
+		t.Fatalf("RevokeOtherSessions revoked the current session: %v", err)
+	}
+
+	if _, err := client.Logout(u.Header); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := client.PingSession(u.Header); err == nil {
+		t.Error("PingSession succeeded after Logout")
+	}
+}
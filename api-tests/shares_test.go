@@ -0,0 +1,233 @@
+package apitests
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+// requireSharing skips the test unless the server has file or folder
+// sharing turned on, per folders.
+func requireSharing(t *testing.T, client *sdk.Client, h http.Header, folders bool) {
+	t.Helper()
+	dashboard, err := client.DashboardInfo(h)
+	if err != nil {
+		t.Fatalf("DashboardInfo: %v", err)
+	}
+	if folders && !dashboard.FolderSharingEnabled {
+		t.Skip("folder sharing is disabled on this server")
+	}
+	if !folders && !dashboard.FileSharingEnabled {
+		t.Skip("file sharing is disabled on this server")
+	}
+}
+
+func TestFileShares(t *testing.T) {
+	client, h := authedClient(t)
+	requireSharing(t, client, h, false)
+
+	folder := createFolder(t, client, h, "file-shares", "")
+	prefix := uniqueName("share")
+	first := upload(t, client, h, prefix+"-b.txt", "shared contents", folder.UUID)
+	second := upload(t, client, h, prefix+"-a.txt", "also shared", folder.UUID)
+
+	link, err := client.CreateShareLink(h, first.UUID, sdk.CreateShareLinkRequest{})
+	if err != nil {
+		t.Fatalf("CreateShareLink: %v", err)
+	}
+	t.Cleanup(func() { _ = client.RevokeShareLink(h, link.Token) })
+	time.Sleep(10 * time.Millisecond) // so the links' creation times differ
+	other, err := client.CreateShareLink(h, second.UUID, sdk.CreateShareLinkRequest{})
+	if err != nil {
+		t.Fatalf("CreateShareLink: %v", err)
+	}
+	t.Cleanup(func() { _ = client.RevokeShareLink(h, other.Token) })
+	past := time.Now().Add(-time.Hour)
+	expired, err := client.CreateShareLink(h, first.UUID, sdk.CreateShareLinkRequest{ExpiresAt: &past})
+	if err != nil {
+		t.Fatalf("CreateShareLink(expired): %v", err)
+	}
+	t.Cleanup(func() { _ = client.RevokeShareLink(h, expired.Token) })
+
+	// The public endpoints don't need a session.
+	meta, err := client.GetShareLinkMeta(nil, link.Token)
+	if err != nil {
+		t.Fatalf("GetShareLinkMeta: %v", err)
+	}
+	if meta.FileName != first.Name || meta.FileSize != int64(len("shared contents")) {
+		t.Errorf("share meta = %+v, want %s of %d bytes", meta, first.Name, len("shared contents"))
+	}
+	resp, err := client.DownloadSharedFile(nil, link.Token)
+	if err != nil {
+		t.Fatalf("DownloadSharedFile: %v", err)
+	}
+	if got := string(readAll(t, resp)); got != "shared contents" {
+		t.Errorf("shared download = %q, want %q", got, "shared contents")
+	}
+	if _, err := client.GetShareLinkMeta(nil, expired.Token); err == nil {
+		t.Error("GetShareLinkMeta succeeded for an expired link")
+	}
+
+	fileShares, err := client.ListFileShares(h, first.UUID)
+	if err != nil {
+		t.Fatalf("ListFileShares: %v", err)
+	}
+	if !slices.ContainsFunc(fileShares, func(l sdk.ShareLink) bool { return l.Token == link.Token }) {
+		t.Errorf("ListFileShares is missing %s", link.Token)
+	}
+
+	tokens := func(links []sdk.ShareLinkWithFileName) string {
+		var out []string
+		for _, l := range links {
+			if strings.HasPrefix(l.FileName, prefix) {
+				out = append(out, l.Token)
+			}
+		}
+		return strings.Join(out, ",")
+	}
+	active, err := client.ListUserShares(h)
+	if err != nil {
+		t.Fatalf("ListUserShares: %v", err)
+	}
+	if got := tokens(active); !strings.Contains(got, link.Token) || !strings.Contains(got, other.Token) || strings.Contains(got, expired.Token) {
+		t.Errorf("ListUserShares = %s, want %s and %s but not %s", got, link.Token, other.Token, expired.Token)
+	}
+	old, err := client.ListExpiredUserShares(h)
+	if err != nil {
+		t.Fatalf("ListExpiredUserShares: %v", err)
+	}
+	if got := tokens(old); got != expired.Token {
+		t.Errorf("ListExpiredUserShares = %s, want %s", got, expired.Token)
+	}
+
+	tests := []struct {
+		name   string
+		filter sdk.ShareFilter
+		want   string
+	}{
+		{"by name", sdk.ShareFilter{Name: prefix, SortBy: sdk.ShareSortByName}, other.Token + "," + link.Token},
+		{"by date, descending", sdk.ShareFilter{Name: strings.ToUpper(prefix), Sort: sdk.SortDesc}, other.Token + "," + link.Token},
+		{"one name", sdk.ShareFilter{Name: prefix + "-b"}, link.Token},
+		{"expired", sdk.ShareFilter{Expired: true, Name: prefix}, expired.Token},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.FilterUserShares(h, tt.filter)
+			if err != nil {
+				t.Fatalf("FilterUserShares: %v", err)
+			}
+			if tokens(got) != tt.want {
+				t.Errorf("FilterUserShares(%+v) = %s, want %s", tt.filter, tokens(got), tt.want)
+			}
+		})
+	}
+
+	if err := client.RevokeShareLink(h, link.Token); err != nil {
+		t.Fatalf("RevokeShareLink: %v", err)
+	}
+	if _, err := client.DownloadSharedFile(nil, link.Token); err == nil {
+		t.Error("DownloadSharedFile succeeded after the link was revoked")
+	}
+}
+
+func TestFolderShares(t *testing.T) {
+	client, h := authedClient(t)
+	requireSharing(t, client, h, true)
+
+	folder := createFolder(t, client, h, "shared", "")
+	sub := createFolder(t, client, h, "sub", folder.UUID)
+	top := upload(t, client, h, "top.txt", "top level", folder.UUID)
+	upload(t, client, h, "deep.txt", "deeper", sub.UUID)
+
+	link, err := client.CreateFolderShareLink(h, folder.UUID, sdk.CreateShareLinkRequest{AllowUpload: true})
+	if err != nil {
+		t.Fatalf("CreateFolderShareLink: %v", err)
+	}
+	t.Cleanup(func() { _ = client.RevokeShareFolderLink(h, link.Token) })
+	past := time.Now().Add(-time.Hour)
+	expired, err := client.CreateFolderShareLink(h, folder.UUID, sdk.CreateShareLinkRequest{ExpiresAt: &past})
+	if err != nil {
+		t.Fatalf("CreateFolderShareLink(expired): %v", err)
+	}
+	t.Cleanup(func() { _ = client.RevokeShareFolderLink(h, expired.Token) })
+
+	contents, err := client.GetSharedFolderContents(nil, link.Token)
+	if err != nil {
+		t.Fatalf("GetSharedFolderContents: %v", err)
+	}
+	if contents.FolderName != folder.Name || !contents.AllowUpload || len(contents.Files) != 1 || len(contents.Folders) != 1 {
+		t.Errorf("shared folder = %+v, want %s with one file, one folder and uploads allowed", contents, folder.Name)
+	}
+	subContents, err := client.BrowseSharedSubFolder(nil, link.Token, sub.UUID)
+	if err != nil {
+		t.Fatalf("BrowseSharedSubFolder: %v", err)
+	}
+	if len(subContents.Files) != 1 || subContents.Files[0].Name != "deep.txt" {
+		t.Errorf("shared subfolder files = %+v, want deep.txt", subContents.Files)
+	}
+
+	resp, err := client.DownloadSharedFolderFile(nil, link.Token, top.UUID)
+	if err != nil {
+		t.Fatalf("DownloadSharedFolderFile: %v", err)
+	}
+	if got := string(readAll(t, resp)); got != "top level" {
+		t.Errorf("shared folder download = %q, want %q", got, "top level")
+	}
+
+	if err := client.UploadToSharedFolder(nil, link.Token, "dropped.txt", strings.NewReader("from outside"), sub.UUID); err != nil {
+		t.Fatalf("UploadToSharedFolder: %v", err)
+	}
+	subContents, err = client.BrowseSharedSubFolder(nil, link.Token, sub.UUID)
+	if err != nil {
+		t.Fatalf("BrowseSharedSubFolder: %v", err)
+	}
+	if len(subContents.Files) != 2 {
+		t.Errorf("shared subfolder holds %d files after an upload, want 2", len(subContents.Files))
+	}
+	if err := client.UploadToSharedFolder(nil, expired.Token, "nope.txt", strings.NewReader("nope"), ""); err == nil {
+		t.Error("UploadToSharedFolder succeeded through an expired link")
+	}
+
+	has := func(links []sdk.ShareFolderLink, token string) bool {
+		return slices.ContainsFunc(links, func(l sdk.ShareFolderLink) bool { return l.Token == token })
+	}
+	folderShares, err := client.ListFolderShares(h, folder.UUID)
+	if err != nil {
+		t.Fatalf("ListFolderShares: %v", err)
+	}
+	if !has(folderShares, link.Token) {
+		t.Errorf("ListFolderShares is missing %s", link.Token)
+	}
+	active, err := client.ListUserFolderShares(h)
+	if err != nil {
+		t.Fatalf("ListUserFolderShares: %v", err)
+	}
+	if !has(active, link.Token) || has(active, expired.Token) {
+		t.Errorf("ListUserFolderShares should hold %s but not %s", link.Token, expired.Token)
+	}
+	old, err := client.ListExpiredUserFolderShares(h)
+	if err != nil {
+		t.Fatalf("ListExpiredUserFolderShares: %v", err)
+	}
+	if !has(old, expired.Token) || has(old, link.Token) {
+		t.Errorf("ListExpiredUserFolderShares should hold %s but not %s", expired.Token, link.Token)
+	}
+	filtered, err := client.FilterUserFolderShares(h, sdk.ShareFilter{Name: strings.ToUpper(folder.Name)})
+	if err != nil {
+		t.Fatalf("FilterUserFolderShares: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Token != link.Token {
+		t.Errorf("FilterUserFolderShares(%s) = %+v, want just %s", folder.Name, filtered, link.Token)
+	}
+
+	if err := client.RevokeShareFolderLink(h, link.Token); err != nil {
+		t.Fatalf("RevokeShareFolderLink: %v", err)
+	}
+	if _, err := client.GetSharedFolderContents(nil, link.Token); err == nil {
+		t.Error("GetSharedFolderContents succeeded after the link was revoked")
+	}
+}
@@ -0,0 +1,96 @@
+package apitests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"avenue/backend/sdk"
+)
+
+func TestTrashListOptions(t *testing.T) {
+	client, h := authedClient(t)
+	folder := createFolder(t, client, h, "trash-options", "")
+
+	big := upload(t, client, h, uniqueName("b-big")+".txt", "a bigger file", folder.UUID)
+	small := upload(t, client, h, uniqueName("a-small")+".txt", "tiny", folder.UUID)
+	for _, f := range []sdk.File{big, small} {
+		if err := client.DeleteFile(h, f.UUID); err != nil {
+			t.Fatalf("DeleteFile: %v", err)
+		}
+	}
+
+	// Other tests may be trashing things too, so only the relative order
+	// of these two is checked.
+	order := func(opts sdk.ListOptions) string {
+		t.Helper()
+		var got []string
+		for item, err := range as(client, h).TrashItems(context.Background(), opts) {
+			if err != nil {
+				t.Fatalf("TrashItems: %v", err)
+			}
+			if item.UUID == big.UUID || item.UUID == small.UUID {
+				got = append(got, item.Name)
+			}
+		}
+		return strings.Join(got, ",")
+	}
+	if got, want := order(sdk.ListOptions{SortBy: sdk.TrashSortByName}), small.Name+","+big.Name; got != want {
+		t.Errorf("trash by name = %s, want %s", got, want)
+	}
+	if got, want := order(sdk.ListOptions{SortBy: sdk.TrashSortBySize, Sort: sdk.SortDesc, Limit: 5}), big.Name+","+small.Name; got != want {
+		t.Errorf("trash by size, descending = %s, want %s", got, want)
+	}
+
+	trash, err := client.ListTrash(h, sdk.ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	if len(trash.Items) != 1 || trash.Total < 2 {
+		t.Errorf("ListTrash(limit 1) = %d items of %d, want 1 of at least 2", len(trash.Items), trash.Total)
+	}
+
+	restored, err := client.RestoreFile(h, small.UUID)
+	if err != nil {
+		t.Fatalf("RestoreFile: %v", err)
+	}
+	if restored.Total >= trash.Total {
+		t.Errorf("after restoring a file the trash holds %d items, want fewer than %d", restored.Total, trash.Total)
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	// Emptying the trash can't be limited to what the test made, so it
+	// runs as a throwaway user.
+	client, u := newUser(t)
+	h := u.Header
+
+	if err := client.CreateFolder(h, "doomed", ""); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	folder := findItem(listRoot(t, client, h), "folder", "doomed")
+	if folder == nil {
+		t.Fatal("created folder not found in root listing")
+	}
+	upload(t, client, h, "doomed.txt", "doomed", folder.UUID)
+	if _, err := client.DeleteFolder(h, folder.UUID); err != nil {
+		t.Fatalf("DeleteFolder: %v", err)
+	}
+
+	job, err := client.EmptyTrash(h)
+	if err != nil {
+		t.Fatalf("EmptyTrash: %v", err)
+	}
+	waitForJob(t, client, h, job.ID)
+
+	trash, err := client.ListTrash(h, sdk.ListOptions{})
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	if trash.Total != 0 {
+		t.Errorf("trash holds %d items after being emptied, want 0", trash.Total)
+	}
+	if _, err := client.RestoreFolder(h, folder.UUID); err == nil {
+		t.Error("restored a folder from an emptied trash")
+	}
+}
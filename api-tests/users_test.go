@@ -0,0 +1,406 @@
+package apitests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"avenue/backend/email"
+	"avenue/backend/sdk"
+)
+
+func TestProfile(t *testing.T) {
+	client, u := newUser(t)
+	_, adminH := adminClient(t)
+	h := u.Header
+	id := fmt.Sprint(u.ID)
+
+	me, err := client.GetProfile(h)
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	if me.ID != u.ID || me.Email != u.Email {
+		t.Errorf("GetProfile = %d %s, want %d %s", me.ID, me.Email, u.ID, u.Email)
+	}
+
+	first, last := "Renamed", "Person"
+	updated, err := client.UpdateProfile(h, sdk.UpdateProfileRequest{ID: u.ID, FirstName: &first, LastName: &last})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if updated.FirstName != first || updated.LastName != last {
+		t.Errorf("UpdateProfile = %s %s, want %s %s", updated.FirstName, updated.LastName, first, last)
+	}
+
+	// The server wants both names on every update.
+	quota := int64(1 << 20)
+	updated, err = client.UpdateProfileByID(adminH, id, sdk.UpdateProfileRequest{FirstName: &first, LastName: &last, Quota: &quota})
+	if err != nil {
+		t.Fatalf("UpdateProfileByID: %v", err)
+	}
+	if updated.ID != u.ID || updated.Quota != quota {
+		t.Errorf("UpdateProfileByID = user %d with quota %d, want user %d with %d", updated.ID, updated.Quota, u.ID, quota)
+	}
+	status, err := client.GetQuotaStatus(h)
+	if err != nil {
+		t.Fatalf("GetQuotaStatus: %v", err)
+	}
+	if status.Quota != quota {
+		t.Errorf("GetQuotaStatus quota = %d, want %d", status.Quota, quota)
+	}
+
+	if _, err := client.UpdateProfileByID(h, id, sdk.UpdateProfileRequest{ID: 1, FirstName: &first, LastName: &last}); err == nil {
+		t.Error("UpdateProfileByID accepted a body for a different user than the path")
+	}
+	if _, err := client.UpdateProfileByID(h, id, sdk.UpdateProfileRequest{FirstName: &first, LastName: &last, Quota: new(int64)}); err != nil {
+		t.Fatalf("UpdateProfileByID(own quota): %v", err)
+	}
+	if me, _ = client.GetProfile(h); me.Quota != quota {
+		t.Errorf("a non-admin changed their own quota to %d", me.Quota)
+	}
+}
+
+func TestNotificationsAndIdentities(t *testing.T) {
+	requirePostgres(t)
+	client, u := newUser(t)
+	h := u.Header
+
+	if _, err := client.ListNotifications(h); err != nil {
+		t.Fatalf("ListNotifications: %v", err)
+	}
+	if err := client.MarkAllNotificationsRead(h); err != nil {
+		t.Fatalf("MarkAllNotificationsRead: %v", err)
+	}
+	notifications, err := client.ListNotifications(h)
+	if err != nil {
+		t.Fatalf("ListNotifications: %v", err)
+	}
+	if notifications.Unread != 0 {
+		t.Errorf("%d notifications unread after MarkAllNotificationsRead", notifications.Unread)
+	}
+	if err := client.MarkNotificationRead(h, 1<<40); !errors.Is(err, sdk.ErrNotFound) {
+		t.Errorf("MarkNotificationRead(bogus) = %v, want ErrNotFound", err)
+	}
+
+	identities, err := client.ListIdentities(h)
+	if err != nil {
+		t.Fatalf("ListIdentities: %v", err)
+	}
+	if len(identities) != 0 {
+		t.Errorf("a password-only user has %d identities, want 0", len(identities))
+	}
+	if _, err := client.LinkIdentity(h, "no-such-provider", "/settings"); !errors.Is(err, sdk.ErrNotFound) {
+		t.Errorf("LinkIdentity(unknown provider) = %v, want ErrNotFound", err)
+	}
+	if err := client.UnlinkIdentity(h, 1<<40); err == nil {
+		t.Error("UnlinkIdentity succeeded for an identity that doesn't exist")
+	}
+
+	groups, err := client.ListMyGroups(h)
+	if err != nil {
+		t.Fatalf("ListMyGroups: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("a new user is in %d groups, want 0", len(groups))
+	}
+}
+
+func TestUpdatePassword(t *testing.T) {
+	client, u := newUser(t)
+	other, err := client.Login(http.Header{}, sdk.LoginRequest{Email: u.Email, Password: u.Password})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	otherH := http.Header{"Authorization": []string{"Token " + other.SessionID}}
+
+	if _, err := client.UpdatePassword(u.Header, "not-the-password", "An0therPassw0rd!"); err == nil {
+		t.Error("UpdatePassword succeeded with the wrong current password")
+	}
+	if _, err := client.UpdatePassword(u.Header, u.Password, "An0therPassw0rd!"); err != nil {
+		t.Fatalf("UpdatePassword: %v", err)
+	}
+	if _, err := client.PingSession(otherH); err == nil {
+		t.Error("another session survived a password change")
+	}
+	if _, err := client.Login(http.Header{}, sdk.LoginRequest{Email: u.Email, Password: "An0therPassw0rd!"}); err != nil {
+		t.Errorf("Login with the new password: %v", err)
+	}
+}
+
+func TestAdminUsers(t *testing.T) {
+	client, h := adminClient(t)
+	_, u := newUser(t)
+	id := fmt.Sprint(u.ID)
+
+	users, err := client.GetUsers(h)
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	if !slices.ContainsFunc(users, func(x sdk.User) bool { return x.ID == u.ID }) {
+		t.Errorf("GetUsers is missing user %d", u.ID)
+	}
+	if _, err := client.GetUsers(u.Header); err == nil {
+		t.Error("GetUsers succeeded for a non-admin")
+	}
+
+	// The server may or may not have email configured.
+	var apiErr *sdk.APIError
+	if err := client.AdminSendPasswordReset(h, id); err != nil && (!errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable) {
+		t.Errorf("AdminSendPasswordReset: %v", err)
+	}
+
+	config, err := client.AdminGetConfig(h)
+	if err != nil {
+		t.Fatalf("AdminGetConfig: %v", err)
+	}
+	if len(config.Config) == 0 {
+		t.Error("AdminGetConfig returned no config")
+	}
+
+	requirePostgres(t)
+	me, err := client.GetProfile(h)
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	if _, err := client.AdminDeleteAccount(h, fmt.Sprint(me.ID), sdk.DeleteAccountRequest{ConfirmEmail: me.Email}); err == nil {
+		t.Error("AdminDeleteAccount let an admin delete their own account")
+	}
+	summary, err := client.AdminDeleteAccount(h, id, sdk.DeleteAccountRequest{ConfirmEmail: u.Email})
+	if err != nil {
+		t.Fatalf("AdminDeleteAccount: %v", err)
+	}
+	if summary.UserID != u.ID {
+		t.Errorf("AdminDeleteAccount deleted user %d, want %d", summary.UserID, u.ID)
+	}
+}
+
+func TestEmailTemplates(t *testing.T) {
+	client, h := adminClient(t)
+
+	templates, err := client.ListEmailTemplates(h)
+	if err != nil {
+		t.Fatalf("ListEmailTemplates: %v", err)
+	}
+	if len(templates.Templates) == 0 {
+		t.Fatal("ListEmailTemplates returned no templates")
+	}
+	name := templates.Templates[0]
+
+	preview, err := client.PreviewEmailTemplate(h, name)
+	if err != nil {
+		t.Fatalf("PreviewEmailTemplate: %v", err)
+	}
+	if preview.Subject == "" || preview.Text == "" {
+		t.Errorf("PreviewEmailTemplate(%s) = %+v, want a subject and a text body", name, preview)
+	}
+	for format, want := range map[string]string{sdk.EmailFormatHTML: preview.HTML, sdk.EmailFormatText: preview.Text} {
+		if want == "" {
+			continue // not every template has an html body
+		}
+		got, err := client.RenderEmailTemplate(h, name, format)
+		if err != nil {
+			t.Fatalf("RenderEmailTemplate(%s): %v", format, err)
+		}
+		if got != want {
+			t.Errorf("RenderEmailTemplate(%s) doesn't match the preview's body", format)
+		}
+	}
+	if _, err := client.PreviewEmailTemplate(h, "no-such-template"); !errors.Is(err, sdk.ErrNotFound) {
+		t.Errorf("PreviewEmailTemplate(unknown) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	requirePostgres(t)
+	client, h := adminClient(t)
+
+	emails, err := client.ListOutboxEmails(h, "", 1, 10)
+	if err != nil {
+		t.Fatalf("ListOutboxEmails: %v", err)
+	}
+	if _, err := client.ListOutboxEmails(h, "bogus", 1, 10); err == nil {
+		t.Error("ListOutboxEmails accepted an unknown status")
+	}
+	if _, err := client.GetOutboxEmail(h, 1<<40); !errors.Is(err, sdk.ErrNotFound) {
+		t.Errorf("GetOutboxEmail(bogus) = %v, want ErrNotFound", err)
+	}
+	if len(emails.Emails) == 0 {
+		return
+	}
+
+	email, err := client.GetOutboxEmail(h, emails.Emails[0].ID)
+	if err != nil {
+		t.Fatalf("GetOutboxEmail: %v", err)
+	}
+	if email.ID != emails.Emails[0].ID || email.To == "" {
+		t.Errorf("GetOutboxEmail = %+v, want email %d", email, emails.Emails[0].ID)
+	}
+	if email.Status == sdk.EmailStatusSent || email.Status == sdk.EmailStatusDead {
+		var apiErr *sdk.APIError
+		if err := client.RetryOutboxEmail(h, email.ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+			t.Errorf("RetryOutboxEmail on a %s email = %v, want a 409", email.Status, err)
+		}
+	}
+}
+
+// resetToken finds the token in a password reset link.
+var resetToken = regexp.MustCompile(`token=(\w+)`)
+
+// waitForEmail waits until the outbox holds an email to the address to
+// with a dedup key of kind that done accepts, and returns it.
+func waitForEmail(t *testing.T, client *sdk.Client, h http.Header, to, kind string, done func(sdk.OutboxEmail) bool) sdk.OutboxEmail {
+	t.Helper()
+	var found sdk.OutboxEmail
+	waitFor(t, kind+" email to "+to, func() (bool, error) {
+		page, err := client.ListOutboxEmails(h, "", 1, 100)
+		if err != nil {
+			return false, err
+		}
+		for _, e := range page.Emails {
+			if e.To == to && strings.HasPrefix(e.DedupKey, kind+":") && done(e) {
+				found = e
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	return found
+}
+
+func TestOutboxDelivery(t *testing.T) {
+	requireMailbox(t)
+	client, h := adminClient(t)
+	_, u := newUser(t)
+	var apiErr *sdk.APIError
+
+	// A delivered email can't be sent again, and its dedup key doesn't
+	// give the reset token away.
+	if err := client.ForgotPassword(http.Header{}, u.Email); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	sent := waitForEmail(t, client, h, u.Email, "forgot_password", func(e sdk.OutboxEmail) bool {
+		return e.Status == sdk.EmailStatusSent
+	})
+	delivered := mailbox.Messages(u.Email)
+	if len(delivered) != 1 {
+		t.Fatalf("delivered %d emails to %s, want 1", len(delivered), u.Email)
+	}
+	token := resetToken.FindStringSubmatch(delivered[0].Text)
+	if token == nil {
+		t.Fatalf("reset email has no reset link: %q", delivered[0].Text)
+	}
+	sum := sha256.Sum256([]byte(token[1]))
+	if want := "forgot_password:" + hex.EncodeToString(sum[:]); sent.DedupKey != want {
+		t.Errorf("dedup key = %q, want %q", sent.DedupKey, want)
+	}
+	if err := client.RetryOutboxEmail(h, sent.ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("RetryOutboxEmail on a sent email = %v, want a 409", err)
+	}
+
+	// A failed attempt waits to be retried, which an admin can do straight
+	// away.
+	mailbox.Fail(u.Email, errors.New("mailbox unavailable"))
+	if err := client.ForgotPassword(http.Header{}, u.Email); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	failed := waitForEmail(t, client, h, u.Email, "forgot_password", func(e sdk.OutboxEmail) bool {
+		return e.Status == sdk.EmailStatusPending && e.Attempts == 1 && e.LastError != ""
+	})
+	mailbox.Fail(u.Email, nil)
+	if err := client.RetryOutboxEmail(h, failed.ID); err != nil {
+		t.Fatalf("RetryOutboxEmail: %v", err)
+	}
+	waitForEmail(t, client, h, u.Email, "forgot_password", func(e sdk.OutboxEmail) bool {
+		return e.ID == failed.ID && e.Status == sdk.EmailStatusSent
+	})
+	if n := len(mailbox.Messages(u.Email)); n != 2 {
+		t.Errorf("delivered %d emails to %s after the retry, want 2", n, u.Email)
+	}
+
+	// Mail that can never be delivered is dead straight away, and can't be
+	// retried once its body is gone.
+	mailbox.Fail(u.Email, email.ErrInvalidAddress)
+	if err := client.ForgotPassword(http.Header{}, u.Email); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	dead := waitForEmail(t, client, h, u.Email, "forgot_password", func(e sdk.OutboxEmail) bool {
+		return e.Status == sdk.EmailStatusDead
+	})
+	if dead.LastError == "" || dead.SentAt != nil {
+		t.Errorf("dead email = %+v, want a last error and no sent time", dead)
+	}
+	if err := client.RetryOutboxEmail(h, dead.ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("RetryOutboxEmail on a dead email = %v, want a 409", err)
+	}
+}
+
+func TestAdminGroups(t *testing.T) {
+	requirePostgres(t)
+	client, h := adminClient(t)
+	_, u := newUser(t)
+
+	group, err := client.AdminCreateGroup(h, sdk.GroupRequest{Name: uniqueName("group"), Quota: 1 << 30})
+	if err != nil {
+		t.Fatalf("AdminCreateGroup: %v", err)
+	}
+	t.Cleanup(func() { _ = client.AdminDeleteGroup(h, group.ID) })
+
+	renamed := uniqueName("renamed")
+	if group, err = client.AdminUpdateGroup(h, group.ID, sdk.GroupRequest{Name: renamed, Quota: 1 << 31}); err != nil {
+		t.Fatalf("AdminUpdateGroup: %v", err)
+	}
+	if group.Name != renamed || group.Quota != 1<<31 {
+		t.Errorf("AdminUpdateGroup = %+v, want %s with a quota of %d", group, renamed, int64(1<<31))
+	}
+
+	if err := client.AdminAddGroupMember(h, group.ID, u.ID); err != nil {
+		t.Fatalf("AdminAddGroupMember: %v", err)
+	}
+	members, err := client.AdminListGroupMembers(h, group.ID)
+	if err != nil {
+		t.Fatalf("AdminListGroupMembers: %v", err)
+	}
+	if len(members) != 1 || members[0].ID != u.ID {
+		t.Errorf("AdminListGroupMembers = %+v, want just user %d", members, u.ID)
+	}
+	mine, err := client.ListMyGroups(u.Header)
+	if err != nil {
+		t.Fatalf("ListMyGroups: %v", err)
+	}
+	if len(mine) != 1 || mine[0].ID != group.ID {
+		t.Errorf("ListMyGroups = %+v, want just group %d", mine, group.ID)
+	}
+	got, err := client.AdminGetGroup(h, group.ID)
+	if err != nil {
+		t.Fatalf("AdminGetGroup: %v", err)
+	}
+	if got.Members != 1 {
+		t.Errorf("AdminGetGroup members = %d, want 1", got.Members)
+	}
+	all, err := client.AdminListGroups(h)
+	if err != nil {
+		t.Fatalf("AdminListGroups: %v", err)
+	}
+	if !slices.ContainsFunc(all, func(g sdk.Group) bool { return g.ID == group.ID }) {
+		t.Errorf("AdminListGroups is missing group %d", group.ID)
+	}
+
+	if err := client.AdminRemoveGroupMember(h, group.ID, u.ID); err != nil {
+		t.Fatalf("AdminRemoveGroupMember: %v", err)
+	}
+	if mine, _ = client.ListMyGroups(u.Header); len(mine) != 0 {
+		t.Errorf("ListMyGroups = %+v after being removed, want none", mine)
+	}
+	if err := client.AdminDeleteGroup(h, group.ID); err != nil {
+		t.Fatalf("AdminDeleteGroup: %v", err)
+	}
+	if _, err := client.AdminGetGroup(h, group.ID); !errors.Is(err, sdk.ErrNotFound) {
+		t.Errorf("AdminGetGroup after delete = %v, want ErrNotFound", err)
+	}
+}
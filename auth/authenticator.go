@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials is returned when a login/password pair is
+	// rejected. It deliberately doesn't say whether the user exists.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrLoginDisabled is returned when the credentials are valid but the
+	// account isn't allowed to log in.
+	ErrLoginDisabled = errors.New("this account is not allowed to log in")
+)
+
+// Authenticator verifies a login/password pair and returns the Avenue user
+// it belongs to.
+type Authenticator interface {
+	Authenticate(ctx context.Context, login, password string) (sdk.User, error)
+}
+
+// PasswordAuthenticator checks the bcrypt hash stored in users.password.
+// This is the default backend.
+type PasswordAuthenticator struct {
+	// Users looks up the account being logged in to. Nil means the
+	// database.
+	Users interface {
+		GetUserByEmail(email string) (sdk.User, error)
+	}
+}
+
+func (a PasswordAuthenticator) Authenticate(_ context.Context, email, password string) (sdk.User, error) {
+	getUser := db.GetUserByEmail
+	if a.Users != nil {
+		getUser = a.Users.GetUserByEmail
+	}
+	user, err := getUser(email)
+	if err != nil {
+		return sdk.User{}, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return sdk.User{}, ErrInvalidCredentials
+	}
+
+	if !user.CanLogin {
+		return sdk.User{}, ErrLoginDisabled
+	}
+
+	return user, nil
+}
+
+// Chain tries each authenticator in order and returns the first success,
+// e.g. LDAP first with local passwords as a fallback for break-glass
+// accounts. ErrLoginDisabled stops the chain so a backend that has
+// positively identified the user can't be bypassed by a later one.
+type Chain []Authenticator
+
+func (ch Chain) Authenticate(ctx context.Context, login, password string) (sdk.User, error) {
+	for _, a := range ch {
+		u, err := a.Authenticate(ctx, login, password)
+		if err == nil || errors.Is(err, ErrLoginDisabled) {
+			return u, err
+		}
+		if !errors.Is(err, ErrInvalidCredentials) {
+			logger.Warnf("auth: %T: %v", a, err)
+		}
+	}
+	return sdk.User{}, ErrInvalidCredentials
+}
+
+// WithoutPasswords returns a minus any PasswordAuthenticator, for when
+// local password login is turned off but directory backends such as LDAP
+// should keep working. ok is false if that leaves nothing.
+func WithoutPasswords(a Authenticator) (_ Authenticator, ok bool) {
+	switch a := a.(type) {
+	case nil, PasswordAuthenticator:
+		return nil, false
+	case Chain:
+		var rest Chain
+		for _, b := range a {
+			if b, ok := WithoutPasswords(b); ok {
+				rest = append(rest, b)
+			}
+		}
+		if len(rest) == 0 {
+			return nil, false
+		}
+		if len(rest) == 1 {
+			return rest[0], true
+		}
+		return rest, true
+	default:
+		return a, true
+	}
+}
+
+// NewAuthenticator builds the authenticator for cfg.Backends, a list of
+// "password" and "ldap" tried in order. The LDAP authenticator, if any, is
+// also returned so the caller can schedule its directory sync.
+func NewAuthenticator(cfg config.Auth) (Authenticator, *LDAPAuthenticator, error) {
+	var (
+		chain Chain
+		ldapA *LDAPAuthenticator
+	)
+	for _, name := range cfg.Backends {
+		switch strings.ToLower(name) {
+		case "password":
+			chain = append(chain, PasswordAuthenticator{})
+		case "ldap":
+			ldapA = NewLDAPAuthenticator(ldapConfig(cfg.LDAP))
+			chain = append(chain, ldapA)
+		default:
+			return nil, nil, errors.New("auth: unknown backend " + name)
+		}
+	}
+
+	if len(chain) == 1 {
+		return chain[0], ldapA, nil
+	}
+	return chain, ldapA, nil
+}
+
+// ProvisionUser creates an account for a user authenticated by an external
+// identity source. The account gets an unguessable random password, so it
+// can only be used via that source unless the user later sets one through a
+// password reset.
+func ProvisionUser(email, firstName, lastName string, isAdmin bool) (sdk.User, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return sdk.User{}, err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(b)), bcrypt.DefaultCost)
+	if err != nil {
+		return sdk.User{}, err
+	}
+
+	u, err := db.CreateUser(email, string(hashed), firstName, lastName, isAdmin)
+	if err != nil {
+		return sdk.User{}, err
+	}
+
+	logger.Infof("new user provisioned: id=%d email=%s", u.ID, u.Email)
+	return u, nil
+}
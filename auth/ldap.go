@@ -0,0 +1,453 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPProviderID is the provider name LDAP accounts are linked under in
+// user_identities.
+const LDAPProviderID = "ldap"
+
+// LDAPConfig describes how to find and authenticate users in an LDAP or
+// Active Directory server.
+type LDAPConfig struct {
+	// URL is an ldap:// or ldaps:// URL.
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+
+	// BindDN/BindPassword is the service account used to search for users.
+	// Leave both empty to search anonymously.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds a user by the login they typed; every "{login}" is
+	// replaced with the escaped login.
+	UserFilter string
+
+	// IDAttr is a stable, unique attribute (e.g. entryUUID or objectGUID)
+	// that identifies the user across renames. Empty means the entry's DN.
+	IDAttr        string
+	EmailAttr     string
+	FirstNameAttr string
+	LastNameAttr  string
+	GroupsAttr    string
+
+	// AdminGroup, when set, makes is_admin follow membership of this group.
+	AdminGroup string
+	// UserGroup, when set, makes can_login follow membership of this group.
+	UserGroup string
+	// LinkByEmail lets a directory user's first login take over an
+	// existing non-admin account with the same email. Off, such logins are
+	// refused instead, since whoever controls the directory entry's mail
+	// attribute would otherwise get that account.
+	LinkByEmail bool
+}
+
+// ldapConfig converts the LDAP settings from the config.
+func ldapConfig(cfg config.LDAP) LDAPConfig {
+	return LDAPConfig{
+		URL:                cfg.URL,
+		StartTLS:           cfg.StartTLS,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		BindDN:             cfg.BindDN,
+		BindPassword:       cfg.BindPassword,
+		BaseDN:             cfg.BaseDN,
+		UserFilter:         cfg.UserFilter,
+		IDAttr:             cfg.IDAttr,
+		EmailAttr:          cfg.EmailAttr,
+		FirstNameAttr:      cfg.FirstNameAttr,
+		LastNameAttr:       cfg.LastNameAttr,
+		GroupsAttr:         cfg.GroupsAttr,
+		AdminGroup:         cfg.AdminGroup,
+		UserGroup:          cfg.UserGroup,
+		LinkByEmail:        cfg.LinkByEmail,
+	}
+}
+
+// LDAPEntry is a directory user mapped onto Avenue's user fields.
+type LDAPEntry struct {
+	ID        string
+	DN        string
+	Email     string
+	FirstName string
+	LastName  string
+	// IsAdmin/CanLogin are only meaningful when AdminMapped/LoginMapped are
+	// true, i.e. the corresponding group is configured.
+	IsAdmin     bool
+	AdminMapped bool
+	CanLogin    bool
+	LoginMapped bool
+}
+
+// ldapConn is the subset of *ldap.Conn the authenticator uses, so tests can
+// substitute a fake directory.
+type ldapConn interface {
+	Bind(username, password string) error
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// LDAPAuthenticator authenticates users against an LDAP directory and keeps
+// the matching Avenue accounts in sync with it.
+type LDAPAuthenticator struct {
+	cfg  LDAPConfig
+	dial func() (ldapConn, error)
+}
+
+func NewLDAPAuthenticator(cfg LDAPConfig) *LDAPAuthenticator {
+	a := &LDAPAuthenticator{cfg: cfg}
+	a.dial = a.dialServer
+	return a
+}
+
+func (a *LDAPAuthenticator) dialServer() (ldapConn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.cfg.InsecureSkipVerify} //nolint:gosec // opt-in for self-signed directories
+
+	conn, err := ldap.DialURL(a.cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: dial: %w", err)
+	}
+	if a.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("ldap: starttls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// connect dials the directory and binds as the service account.
+func (a *LDAPAuthenticator) connect() (ldapConn, error) {
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	if a.cfg.BindDN != "" {
+		if err := conn.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("ldap: service bind: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (a *LDAPAuthenticator) attributes() []string {
+	attrs := []string{a.cfg.EmailAttr, a.cfg.FirstNameAttr, a.cfg.LastNameAttr, a.cfg.GroupsAttr}
+	if a.cfg.IDAttr != "" {
+		attrs = append(attrs, a.cfg.IDAttr)
+	}
+	return attrs
+}
+
+// userFilter substitutes the escaped login into the configured filter.
+func userFilter(filter, login string) string {
+	return strings.ReplaceAll(filter, "{login}", ldap.EscapeFilter(login))
+}
+
+// findUser searches for exactly one entry matching filter under base.
+func (a *LDAPAuthenticator) findUser(conn ldapConn, base string, scope int, filter string) (*ldap.Entry, error) {
+	res, err := conn.Search(ldap.NewSearchRequest(
+		base, scope, ldap.NeverDerefAliases, 2, 0, false,
+		filter, a.attributes(), nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("ldap: search: %w", err)
+	}
+	switch len(res.Entries) {
+	case 0:
+		return nil, sql.ErrNoRows
+	case 1:
+		return res.Entries[0], nil
+	default:
+		return nil, fmt.Errorf("ldap: filter %q matched more than one entry", filter)
+	}
+}
+
+// mapEntry applies the attribute and group mappings to a directory entry.
+func (a *LDAPAuthenticator) mapEntry(e *ldap.Entry) LDAPEntry {
+	m := LDAPEntry{
+		ID:        e.DN,
+		DN:        e.DN,
+		Email:     strings.ToLower(e.GetAttributeValue(a.cfg.EmailAttr)),
+		FirstName: e.GetAttributeValue(a.cfg.FirstNameAttr),
+		LastName:  e.GetAttributeValue(a.cfg.LastNameAttr),
+	}
+	if a.cfg.IDAttr != "" {
+		// Binary IDs such as AD's objectGUID aren't valid text, so store
+		// them hex encoded.
+		if raw := e.GetRawAttributeValue(a.cfg.IDAttr); utf8.Valid(raw) {
+			m.ID = string(raw)
+		} else {
+			m.ID = hex.EncodeToString(raw)
+		}
+	}
+
+	groups := e.GetAttributeValues(a.cfg.GroupsAttr)
+	if a.cfg.AdminGroup != "" {
+		m.AdminMapped = true
+		m.IsAdmin = hasGroup(groups, a.cfg.AdminGroup)
+	}
+	if a.cfg.UserGroup != "" {
+		m.LoginMapped = true
+		m.CanLogin = hasGroup(groups, a.cfg.UserGroup)
+	}
+	return m
+}
+
+// hasGroup reports whether groups contains group. DNs are compared case
+// insensitively, ignoring whitespace around RDN separators.
+func hasGroup(groups []string, group string) bool {
+	want := normalizeDN(group)
+	for _, g := range groups {
+		if normalizeDN(g) == want {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, p := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(p))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Authenticate finds the user in the directory, verifies the password by
+// binding as them, and returns the linked Avenue account, provisioning or
+// updating it from the directory as needed.
+func (a *LDAPAuthenticator) Authenticate(_ context.Context, login, password string) (sdk.User, error) {
+	entry, err := a.verify(login, password)
+	if err != nil {
+		return sdk.User{}, err
+	}
+
+	u, err := a.resolveUser(entry)
+	if err != nil {
+		return sdk.User{}, err
+	}
+	if !u.CanLogin {
+		return sdk.User{}, ErrLoginDisabled
+	}
+	return u, nil
+}
+
+// verify checks login/password against the directory and returns the
+// user's mapped entry.
+func (a *LDAPAuthenticator) verify(login, password string) (LDAPEntry, error) {
+	// An empty password would be an unauthenticated bind, which many
+	// servers report as a success.
+	if login == "" || password == "" {
+		return LDAPEntry{}, ErrInvalidCredentials
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		return LDAPEntry{}, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	entry, err := a.findUser(conn, a.cfg.BaseDN, ldap.ScopeWholeSubtree, userFilter(a.cfg.UserFilter, login))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return LDAPEntry{}, ErrInvalidCredentials
+		}
+		return LDAPEntry{}, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return LDAPEntry{}, ErrInvalidCredentials
+		}
+		return LDAPEntry{}, fmt.Errorf("ldap: user bind: %w", err)
+	}
+
+	mapped := a.mapEntry(entry)
+	if mapped.Email == "" {
+		return LDAPEntry{}, fmt.Errorf("ldap: %s has no %s attribute", entry.DN, a.cfg.EmailAttr)
+	}
+	return mapped, nil
+}
+
+// resolveUser finds the Avenue user linked to a directory entry, linking an
+// existing account by email (when LinkByEmail allows it) or provisioning a
+// new one the first time, then applies the directory's current attributes
+// to it.
+func (a *LDAPAuthenticator) resolveUser(e LDAPEntry) (sdk.User, error) {
+	u, err := db.GetUserByIdentity(LDAPProviderID, e.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		u, err = db.GetUserByEmail(e.Email)
+		switch {
+		case err == nil:
+			if err := a.checkEmailLink(u, e); err != nil {
+				return sdk.User{}, err
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			u, err = ProvisionUser(e.Email, e.FirstName, e.LastName, e.AdminMapped && e.IsAdmin)
+			if err != nil {
+				return sdk.User{}, fmt.Errorf("ldap: provision user: %w", err)
+			}
+		default:
+			return sdk.User{}, fmt.Errorf("ldap: get user by email: %w", err)
+		}
+		if _, err := db.LinkIdentity(u.ID, LDAPProviderID, e.ID, e.Email); err != nil {
+			return sdk.User{}, fmt.Errorf("ldap: link identity: %w", err)
+		}
+	} else if err != nil {
+		return sdk.User{}, fmt.Errorf("ldap: get user by identity: %w", err)
+	}
+
+	return applyEntry(u, e)
+}
+
+// checkEmailLink reports whether e, which isn't linked to any account yet,
+// may be linked to u, the existing account with the same email.
+func (a *LDAPAuthenticator) checkEmailLink(u sdk.User, e LDAPEntry) error {
+	if !a.cfg.LinkByEmail {
+		return fmt.Errorf("ldap: %s has the email of user %d, who isn't linked to it; set auth.ldap.link_by_email to link them", e.DN, u.ID)
+	}
+	if u.IsAdmin {
+		return fmt.Errorf("ldap: %s has the email of admin user %d; admins are never linked by email", e.DN, u.ID)
+	}
+	return nil
+}
+
+// applyEntry copies the directory's view of a user onto their Avenue
+// account, saving only if something changed.
+func applyEntry(u sdk.User, e LDAPEntry) (sdk.User, error) {
+	updated := u
+	updated.Email = e.Email
+	if e.FirstName != "" {
+		updated.FirstName = e.FirstName
+	}
+	if e.LastName != "" {
+		updated.LastName = e.LastName
+	}
+	if e.LoginMapped {
+		updated.CanLogin = e.CanLogin
+	}
+	if e.AdminMapped && e.IsAdmin != u.IsAdmin {
+		updated.IsAdmin = e.IsAdmin
+		if !e.IsAdmin {
+			// Same rule as UpdateProfile: never leave the app without an admin.
+			hasOthers, err := db.HasOtherAdmins(u)
+			if err != nil {
+				return sdk.User{}, fmt.Errorf("ldap: check for other admins: %w", err)
+			}
+			if !hasOthers {
+				logger.Warnf("ldap: not demoting user %d, they are the last admin", u.ID)
+				updated.IsAdmin = true
+			}
+		}
+	}
+
+	if updated == u {
+		return u, nil
+	}
+	if _, err := db.UpdateUser(updated); err != nil {
+		return sdk.User{}, fmt.Errorf("ldap: update user: %w", err)
+	}
+	return updated, nil
+}
+
+// Sync re-reads every LDAP-linked account from the directory and refreshes
+// its attributes. Accounts whose entry no longer exists, or that have left
+// UserGroup, are disabled and their sessions revoked.
+func (a *LDAPAuthenticator) Sync() error {
+	idents, err := db.ListIdentitiesByProvider(LDAPProviderID)
+	if err != nil {
+		return fmt.Errorf("ldap sync: list identities: %w", err)
+	}
+	if len(idents) == 0 {
+		return nil
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	for _, ident := range idents {
+		u, err := db.GetUserByID(ident.UserID)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				logger.Errorf("ldap sync: get user %d: %v", ident.UserID, err)
+			}
+			continue
+		}
+
+		entry, err := a.lookup(conn, ident.Subject)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			// Don't disable anyone because of a transient directory error.
+			return err
+		}
+
+		if entry == nil {
+			if u.CanLogin {
+				logger.Infof("ldap sync: disabling user %d, %s was removed from the directory", u.ID, ident.Subject)
+				u.CanLogin = false
+				if _, err := db.UpdateUser(u); err != nil {
+					logger.Errorf("ldap sync: disable user %d: %v", u.ID, err)
+					continue
+				}
+			}
+		} else if u, err = applyEntry(u, a.mapEntry(entry)); err != nil {
+			logger.Errorf("ldap sync: %v", err)
+			continue
+		}
+
+		if !u.CanLogin {
+			if err := db.DeleteSessionsForUser(u.ID); err != nil {
+				logger.Errorf("ldap sync: revoke sessions for user %d: %v", u.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// lookup finds a previously linked entry by its ID: a base-object search on
+// the DN, or a subtree search on IDAttr.
+func (a *LDAPAuthenticator) lookup(conn ldapConn, id string) (*ldap.Entry, error) {
+	if a.cfg.IDAttr == "" {
+		return a.findUser(conn, id, ldap.ScopeBaseObject, "(objectClass=*)")
+	}
+
+	return a.findUser(conn, a.cfg.BaseDN, ldap.ScopeWholeSubtree, idFilter(a.cfg.IDAttr, id))
+}
+
+// idFilter matches IDAttr against id. mapEntry hex encodes binary IDs, so an
+// id that is valid hex is also tried as the raw bytes it encodes.
+func idFilter(attr, id string) string {
+	attr = ldap.EscapeFilter(attr)
+	filter := fmt.Sprintf("(%s=%s)", attr, ldap.EscapeFilter(id))
+
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) == 0 {
+		return filter
+	}
+	var b strings.Builder
+	for _, c := range raw {
+		fmt.Fprintf(&b, `\%02x`, c)
+	}
+	return fmt.Sprintf("(|%s(%s=%s))", filter, attr, b.String())
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"avenue/backend/sdk"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeDirectory is an in-memory ldapConn: binds succeed when the password
+// matches passwords[dn], and searches return results[filter].
+type fakeDirectory struct {
+	passwords map[string]string
+	results   map[string][]*ldap.Entry
+	boundAs   []string
+}
+
+func (f *fakeDirectory) Bind(dn, password string) error {
+	if want, ok := f.passwords[dn]; !ok || want != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	f.boundAs = append(f.boundAs, dn)
+	return nil
+}
+
+func (f *fakeDirectory) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	return &ldap.SearchResult{Entries: f.results[req.Filter]}, nil
+}
+
+func (f *fakeDirectory) Close() error { return nil }
+
+func newTestLDAP(dir *fakeDirectory) *LDAPAuthenticator {
+	a := NewLDAPAuthenticator(LDAPConfig{
+		BindDN:        "cn=svc,dc=example,dc=com",
+		BindPassword:  "svc-secret",
+		BaseDN:        "dc=example,dc=com",
+		UserFilter:    "(uid={login})",
+		EmailAttr:     "mail",
+		FirstNameAttr: "givenName",
+		LastNameAttr:  "sn",
+		GroupsAttr:    "memberOf",
+		AdminGroup:    "cn=admins,ou=groups,dc=example,dc=com",
+		UserGroup:     "cn=avenue,ou=groups,dc=example,dc=com",
+	})
+	a.dial = func() (ldapConn, error) { return dir, nil }
+	return a
+}
+
+func TestLDAPVerify(t *testing.T) {
+	const adaDN = "uid=ada,ou=people,dc=example,dc=com"
+	dir := &fakeDirectory{
+		passwords: map[string]string{
+			"cn=svc,dc=example,dc=com": "svc-secret",
+			adaDN:                      "hunter2",
+		},
+		results: map[string][]*ldap.Entry{
+			"(uid=ada)": {ldap.NewEntry(adaDN, map[string][]string{
+				"mail":      {"Ada@Example.com"},
+				"givenName": {"Ada"},
+				"sn":        {"Lovelace"},
+				"memberOf":  {"CN=Admins, OU=Groups, DC=example, DC=com", "cn=avenue,ou=groups,dc=example,dc=com"},
+			})},
+		},
+	}
+	a := newTestLDAP(dir)
+
+	got, err := a.verify("ada", "hunter2")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	want := LDAPEntry{
+		ID:          adaDN,
+		DN:          adaDN,
+		Email:       "ada@example.com",
+		FirstName:   "Ada",
+		LastName:    "Lovelace",
+		IsAdmin:     true,
+		AdminMapped: true,
+		CanLogin:    true,
+		LoginMapped: true,
+	}
+	if got != want {
+		t.Errorf("entry = %+v, want %+v", got, want)
+	}
+	if len(dir.boundAs) != 2 || dir.boundAs[1] != adaDN {
+		t.Errorf("binds = %v, want service account then %s", dir.boundAs, adaDN)
+	}
+
+	for _, tc := range []struct{ name, login, password string }{
+		{"wrong password", "ada", "nope"},
+		{"empty password", "ada", ""},
+		{"unknown user", "grace", "hunter2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := a.verify(tc.login, tc.password); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("verify err = %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
+
+func TestUserFilterEscapesLogin(t *testing.T) {
+	got := userFilter("(|(uid={login})(mail={login}))", "a*)(uid=*")
+	want := `(|(uid=a\2a\29\28uid=\2a)(mail=a\2a\29\28uid=\2a))`
+	if got != want {
+		t.Errorf("userFilter = %q, want %q", got, want)
+	}
+}
+
+func TestIDFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{name: "text id", id: "ada-uuid", want: "(entryUUID=ada-uuid)"},
+		{name: "hex id also tried as bytes", id: "0aff", want: `(|(entryUUID=0aff)(entryUUID=\0a\ff))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idFilter("entryUUID", tt.id); got != tt.want {
+				t.Errorf("idFilter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckEmailLink(t *testing.T) {
+	entry := LDAPEntry{ID: "ada-uuid", DN: "uid=ada,ou=people,dc=example,dc=com", Email: "ada@example.com"}
+	user := sdk.User{ID: 7, Email: "ada@example.com"}
+	admin := sdk.User{ID: 1, Email: "ada@example.com", IsAdmin: true}
+
+	tests := []struct {
+		name        string
+		linkByEmail bool
+		user        sdk.User
+		wantErr     bool
+	}{
+		{name: "off by default", user: user, wantErr: true},
+		{name: "opted in", linkByEmail: true, user: user},
+		{name: "never an admin", linkByEmail: true, user: admin, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewLDAPAuthenticator(LDAPConfig{LinkByEmail: tt.linkByEmail})
+			if err := a.checkEmailLink(tt.user, entry); (err != nil) != tt.wantErr {
+				t.Errorf("checkEmailLink err = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Package auth holds Avenue's login backends: local bcrypt passwords, LDAP
+// directories, and OpenID Connect single sign-on providers.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"avenue/backend/config"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig describes a single OpenID Connect provider. Every field except
+// DisplayName, Scopes, and the admin mapping is required.
+type OIDCConfig struct {
+	// ID is the short, URL-safe name used in routes
+	// (/auth/oidc/:provider/...) and stored alongside linked identities.
+	ID          string
+	DisplayName string
+	IssuerURL   string
+	ClientID    string
+	// ClientSecret may be empty for public clients, which rely on PKCE alone.
+	ClientSecret string
+	Scopes       []string
+	// AdminClaim, when set, names an ID token claim (e.g. "groups") whose
+	// value decides is_admin on every login. A user is an admin if the claim
+	// is boolean true, or is a string/array containing any of AdminValues.
+	AdminClaim  string
+	AdminValues []string
+	// LinkByEmail lets a first login take over an existing non-admin
+	// account with the same verified email. Off, such logins are refused
+	// instead, since whoever controls that email at the provider would
+	// otherwise get the account; the user can link the identity from
+	// their profile.
+	LinkByEmail bool
+}
+
+// OIDCProvider is a discovered, ready-to-use OpenID Connect provider.
+type OIDCProvider struct {
+	Config   OIDCConfig
+	provider *gooidc.Provider
+	verifier *gooidc.IDTokenVerifier
+}
+
+// OIDCClaims is the subset of ID token claims Avenue uses to find, link,
+// and provision accounts.
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	// IsAdmin is only meaningful when AdminMapped is true, i.e. the provider
+	// has an AdminClaim configured.
+	IsAdmin     bool
+	AdminMapped bool
+}
+
+var (
+	ErrNonceMismatch = errors.New("oidc: id token nonce does not match login state")
+	ErrNoIDToken     = errors.New("oidc: token response did not include an id_token")
+)
+
+// NewOIDCProvider performs OIDC discovery against cfg.IssuerURL and returns
+// a provider ready to build authorization URLs and verify ID tokens.
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	if cfg.ID == "" || cfg.IssuerURL == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("oidc: provider %q: id, issuer and client id are required", cfg.ID)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{gooidc.ScopeOpenID, "email", "profile"}
+	}
+	if cfg.DisplayName == "" {
+		cfg.DisplayName = cfg.ID
+	}
+
+	p, err := gooidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: provider %q: discovery: %w", cfg.ID, err)
+	}
+
+	return &OIDCProvider{
+		Config:   cfg,
+		provider: p,
+		verifier: p.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+func (p *OIDCProvider) oauth2Config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.Config.ClientID,
+		ClientSecret: p.Config.ClientSecret,
+		Endpoint:     p.provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       p.Config.Scopes,
+	}
+}
+
+// AuthCodeURL builds the authorization-code + PKCE URL to send the browser
+// to. state and nonce are opaque random values the caller must persist
+// alongside codeVerifier until the callback comes back.
+func (p *OIDCProvider) AuthCodeURL(redirectURL, state, nonce, codeVerifier string) string {
+	return p.oauth2Config(redirectURL).AuthCodeURL(
+		state,
+		gooidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(codeVerifier),
+	)
+}
+
+// Exchange trades an authorization code for tokens, verifies the returned
+// ID token's signature, issuer, audience, expiry and nonce, and returns the
+// claims Avenue cares about. redirectURL must match the one passed to
+// AuthCodeURL.
+func (p *OIDCProvider) Exchange(ctx context.Context, redirectURL, code, nonce, codeVerifier string) (OIDCClaims, error) {
+	tok, err := p.oauth2Config(redirectURL).Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("oidc: exchange code: %w", err)
+	}
+
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return OIDCClaims{}, ErrNoIDToken
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("oidc: verify id token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return OIDCClaims{}, ErrNonceMismatch
+	}
+
+	var raw map[string]any
+	if err := idToken.Claims(&raw); err != nil {
+		return OIDCClaims{}, fmt.Errorf("oidc: decode claims: %w", err)
+	}
+
+	claims := OIDCClaims{
+		Subject:       idToken.Subject,
+		Email:         stringClaim(raw, "email"),
+		EmailVerified: boolClaim(raw, "email_verified"),
+		FirstName:     stringClaim(raw, "given_name"),
+		LastName:      stringClaim(raw, "family_name"),
+	}
+	if p.Config.AdminClaim != "" {
+		claims.AdminMapped = true
+		claims.IsAdmin = claimMatches(raw[p.Config.AdminClaim], p.Config.AdminValues)
+	}
+	return claims, nil
+}
+
+func stringClaim(raw map[string]any, key string) string {
+	s, _ := raw[key].(string)
+	return s
+}
+
+// boolClaim reads a boolean claim, tolerating providers that encode it as
+// the string "true".
+func boolClaim(raw map[string]any, key string) bool {
+	switch v := raw[key].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// claimMatches reports whether an admin-mapping claim grants admin: a bare
+// boolean true always does, while a string or array of strings must contain
+// one of values.
+func claimMatches(claim any, values []string) bool {
+	switch v := claim.(type) {
+	case bool:
+		return v
+	case string:
+		for _, want := range values {
+			if v == want {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && claimMatches(s, values) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OIDCConfigs returns the SSO providers configured in cfg, in the order
+// they're offered.
+func OIDCConfigs(cfg config.Auth) []OIDCConfig {
+	configs := make([]OIDCConfig, 0, len(cfg.OIDC))
+	for _, p := range cfg.OIDC {
+		configs = append(configs, OIDCConfig{
+			ID:           p.ID,
+			DisplayName:  p.DisplayName,
+			IssuerURL:    p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			Scopes:       p.Scopes,
+			AdminClaim:   p.AdminClaim,
+			AdminValues:  p.AdminValues,
+			LinkByEmail:  p.LinkByEmail,
+		})
+	}
+	return configs
+}
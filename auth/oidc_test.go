@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"avenue/backend/auth/authtest"
+
+	"golang.org/x/oauth2"
+)
+
+// authorize drives the mock IdP's authorize endpoint the way a browser
+// would and returns the code/state it redirects back with.
+func authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want 302", resp.StatusCode)
+	}
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func TestOIDCLoginFlowWithMockIdP(t *testing.T) {
+	idp := authtest.NewIdP("avenue")
+	defer idp.Close()
+
+	idp.SetClaims(map[string]any{
+		"sub":            "user-123",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"given_name":     "Ada",
+		"family_name":    "Lovelace",
+		"groups":         []string{"staff", "avenue-admins"},
+	})
+
+	ctx := context.Background()
+	p, err := NewOIDCProvider(ctx, OIDCConfig{
+		ID:          "mock",
+		IssuerURL:   idp.Issuer(),
+		ClientID:    "avenue",
+		AdminClaim:  "groups",
+		AdminValues: []string{"avenue-admins"},
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+
+	const redirectURL = "http://avenue.test/auth/oidc/mock/callback"
+	verifier := oauth2.GenerateVerifier()
+
+	code, state := authorize(t, p.AuthCodeURL(redirectURL, "state-1", "nonce-1", verifier))
+	if state != "state-1" {
+		t.Fatalf("state = %q, want %q", state, "state-1")
+	}
+
+	claims, err := p.Exchange(ctx, redirectURL, code, "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	want := OIDCClaims{
+		Subject:       "user-123",
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		FirstName:     "Ada",
+		LastName:      "Lovelace",
+		IsAdmin:       true,
+		AdminMapped:   true,
+	}
+	if claims != want {
+		t.Errorf("claims = %+v, want %+v", claims, want)
+	}
+}
+
+func TestOIDCExchangeRejectsNonceMismatch(t *testing.T) {
+	idp := authtest.NewIdP("avenue")
+	defer idp.Close()
+	idp.SetClaims(map[string]any{"sub": "user-123"})
+
+	ctx := context.Background()
+	p, err := NewOIDCProvider(ctx, OIDCConfig{ID: "mock", IssuerURL: idp.Issuer(), ClientID: "avenue"})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+
+	const redirectURL = "http://avenue.test/auth/oidc/mock/callback"
+	verifier := oauth2.GenerateVerifier()
+	code, _ := authorize(t, p.AuthCodeURL(redirectURL, "state-1", "nonce-1", verifier))
+
+	if _, err := p.Exchange(ctx, redirectURL, code, "some-other-nonce", verifier); !errors.Is(err, ErrNonceMismatch) {
+		t.Fatalf("Exchange err = %v, want ErrNonceMismatch", err)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	idp := authtest.NewIdP("avenue")
+	defer idp.Close()
+	idp.SetClaims(map[string]any{"sub": "user-123"})
+
+	ctx := context.Background()
+	p, err := NewOIDCProvider(ctx, OIDCConfig{ID: "mock", IssuerURL: idp.Issuer(), ClientID: "avenue"})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+
+	const redirectURL = "http://avenue.test/auth/oidc/mock/callback"
+	code, _ := authorize(t, p.AuthCodeURL(redirectURL, "state-1", "nonce-1", oauth2.GenerateVerifier()))
+
+	if _, err := p.Exchange(ctx, redirectURL, code, "nonce-1", oauth2.GenerateVerifier()); err == nil {
+		t.Fatal("expected Exchange with the wrong PKCE verifier to fail")
+	}
+}
+
+func TestClaimMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		claim  any
+		values []string
+		want   bool
+	}{
+		{name: "bool true", claim: true, want: true},
+		{name: "bool false", claim: false, want: false},
+		{name: "matching string", claim: "admin", values: []string{"admin"}, want: true},
+		{name: "non-matching string", claim: "staff", values: []string{"admin"}, want: false},
+		{name: "array containing a value", claim: []any{"staff", "admin"}, values: []string{"admin"}, want: true},
+		{name: "array without a value", claim: []any{"staff"}, values: []string{"admin"}, want: false},
+		{name: "missing claim", claim: nil, values: []string{"admin"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := claimMatches(tt.claim, tt.values); got != tt.want {
+				t.Errorf("claimMatches(%v, %v) = %v, want %v", tt.claim, tt.values, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"avenue/backend/shared"
+)
+
+// formatVersion is bumped whenever the archive layout changes.
+const formatVersion = 2
+
+// An archive is a tar file, gzipped if its name ends in .gz or .tgz,
+// holding in order: manifest.json, one db/<table>.jsonl per table with a
+// JSON object per row, then the blobs at blobs/<shard path>.
+const (
+	manifestName = "manifest.json"
+	dbDir        = "db/"
+	blobDir      = "blobs/"
+)
+
+// manifest describes an archive. It comes first, so a restore knows what
+// it's restoring before reading the rest.
+type manifest struct {
+	Format int `json:"format"`
+	// Schema is the newest migration applied when the backup was made.
+	Schema     string    `json:"schema"`
+	SnapshotAt time.Time `json:"snapshotAt"`
+	// Base is set on incremental backups to the snapshot time of the
+	// backup they build on. They only hold the blobs that one didn't.
+	Base      *time.Time  `json:"base,omitempty"`
+	Tables    []tableInfo `json:"tables"`
+	Blobs     int         `json:"blobs"`
+	BlobBytes int64       `json:"blobBytes"`
+	// Covered maps the UUID of every file whose blob is in this backup,
+	// or in the one it builds on, to the checksum it was backed up with.
+	// An incremental backup made on top of this one skips those blobs.
+	Covered map[string]string `json:"covered"`
+}
+
+type tableInfo struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+}
+
+func tableEntry(table string) string {
+	return dbDir + table + ".jsonl"
+}
+
+func blobEntry(uuid string) string {
+	return blobDir + strings.TrimPrefix(shared.BlobPath(uuid), "/")
+}
+
+// blobUUID returns the UUID of the blob stored at entry name. Only hex
+// digits and dashes are accepted, so an entry can't point outside the
+// upload directory.
+func blobUUID(name string) (string, bool) {
+	uuid := path.Base(name)
+	valid := len(uuid) >= 4 && !strings.ContainsFunc(uuid, func(r rune) bool {
+		return r != '-' && !('0' <= r && r <= '9') && !('a' <= r && r <= 'f') && !('A' <= r && r <= 'F')
+	})
+	if !valid || blobEntry(uuid) != name {
+		return "", false
+	}
+	return uuid, true
+}
+
+func compressed(name string) bool {
+	return strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// archiveWriter writes an archive to a temporary file that's renamed into
+// place by close, so an interrupted backup can't pass for a finished one.
+type archiveWriter struct {
+	*tar.Writer
+	f    *os.File
+	gz   *gzip.Writer
+	name string
+}
+
+func createArchive(name string) (*archiveWriter, error) {
+	f, err := os.OpenFile(name+".partial", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	w := &archiveWriter{f: f, name: name}
+	var out io.Writer = f
+	if compressed(name) {
+		w.gz = gzip.NewWriter(f)
+		out = w.gz
+	}
+	w.Writer = tar.NewWriter(out)
+	return w, nil
+}
+
+// writeEntry adds a file of the given size, copied from r.
+func (w *archiveWriter) writeEntry(name string, size int64, r io.Reader) error {
+	err := w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o600,
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, r, size)
+	return err
+}
+
+func (w *archiveWriter) writeJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return w.writeEntry(name, int64(len(data)), strings.NewReader(string(data)))
+}
+
+func (w *archiveWriter) close() error {
+	err := w.Writer.Close()
+	if w.gz != nil {
+		err = errors.Join(err, w.gz.Close())
+	}
+	err = errors.Join(err, w.f.Sync(), w.f.Close())
+	if err == nil {
+		err = os.Rename(w.f.Name(), w.name)
+	}
+	return err
+}
+
+// abort removes the unfinished archive.
+func (w *archiveWriter) abort() {
+	_ = w.f.Close()
+	_ = os.Remove(w.f.Name())
+}
+
+// archiveReader reads an archive written by archiveWriter.
+type archiveReader struct {
+	tr       *tar.Reader
+	f        *os.File
+	manifest manifest
+}
+
+// openArchive opens an archive and reads its manifest. Gzip is detected
+// from the content, so a renamed archive still opens.
+func openArchive(name string) (*archiveReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &archiveReader{f: f}
+	if err := r.init(); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return r, nil
+}
+
+func (r *archiveReader) init() error {
+	br := bufio.NewReader(r.f)
+	var in io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		in = gz
+	}
+	r.tr = tar.NewReader(in)
+
+	hdr, err := r.tr.Next()
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+	if hdr.Name != manifestName {
+		return fmt.Errorf("not an Avenue backup: starts with %q, not %s", hdr.Name, manifestName)
+	}
+	if err := json.NewDecoder(r.tr).Decode(&r.manifest); err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+	if r.manifest.Format != formatVersion {
+		return fmt.Errorf("archive format %d isn't supported (want %d)", r.manifest.Format, formatVersion)
+	}
+	return nil
+}
+
+func (r *archiveReader) close() error {
+	return r.f.Close()
+}
+
+// each calls onTable for every table and then onBlob for every blob, in
+// the order they're stored.
+func (r *archiveReader) each(onTable func(table string, body io.Reader) error, onBlob func(uuid string, body io.Reader, size int64) error) error {
+	blobsStarted := false
+	for {
+		hdr, err := r.tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if rest, ok := strings.CutPrefix(hdr.Name, dbDir); ok {
+			table, ok := strings.CutSuffix(rest, ".jsonl")
+			if !ok || blobsStarted {
+				return fmt.Errorf("unexpected entry %s", hdr.Name)
+			}
+			if err := onTable(table, r.tr); err != nil {
+				return fmt.Errorf("table %s: %w", table, err)
+			}
+			continue
+		}
+
+		uuid, ok := blobUUID(hdr.Name)
+		if !ok {
+			return fmt.Errorf("unexpected entry %s", hdr.Name)
+		}
+		blobsStarted = true
+		if err := onBlob(uuid, r.tr, hdr.Size); err != nil {
+			return fmt.Errorf("blob %s: %w", uuid, err)
+		}
+	}
+}
+
+// eachRow calls fn with each line of a table entry.
+func eachRow(body io.Reader, fn func(row []byte) error) error {
+	br := bufio.NewReaderSize(body, 64*1024)
+	for {
+		line, err := br.ReadBytes('\n')
+		if row := bytes.TrimSuffix(line, []byte("\n")); len(row) > 0 {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fileRow is the part of a files row that's needed to check its blob.
+type fileRow struct {
+	UUID     string  `json:"uuid"`
+	Checksum *string `json:"checksum"`
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, name := range []string{"backup.tar", "backup.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			uuid := "abcdef12-3456"
+			m := manifest{
+				Format:  formatVersion,
+				Schema:  "0025_create_notifications",
+				Base:    &base,
+				Tables:  []tableInfo{{Name: "users", Rows: 2}},
+				Blobs:   1,
+				Covered: map[string]string{uuid: "c1"},
+			}
+			rows := "{\"id\":1}\n{\"id\":2}\n"
+
+			w, err := createArchive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.writeJSON(manifestName, m); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.writeEntry(tableEntry("users"), int64(len(rows)), strings.NewReader(rows)); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.writeEntry(blobEntry(uuid), 5, strings.NewReader("hello")); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := openArchive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = r.close() }()
+			if r.manifest.Schema != m.Schema || !r.manifest.Base.Equal(base) || !maps.Equal(r.manifest.Covered, m.Covered) {
+				t.Errorf("manifest = %+v, want %+v", r.manifest, m)
+			}
+
+			var gotRows []string
+			var gotBlobs []string
+			err = r.each(func(table string, body io.Reader) error {
+				return eachRow(body, func(row []byte) error {
+					gotRows = append(gotRows, table+" "+string(row))
+					return nil
+				})
+			}, func(uuid string, body io.Reader, size int64) error {
+				data, err := io.ReadAll(body)
+				gotBlobs = append(gotBlobs, uuid+" "+string(data))
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{`users {"id":1}`, `users {"id":2}`}; !slices.Equal(gotRows, want) {
+				t.Errorf("rows = %q, want %q", gotRows, want)
+			}
+			if want := []string{uuid + " hello"}; !slices.Equal(gotBlobs, want) {
+				t.Errorf("blobs = %q, want %q", gotBlobs, want)
+			}
+		})
+	}
+}
+
+func TestBlobUUID(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "blobs/ab/cd/abcdef", want: "abcdef", wantOK: true},
+		{name: "blobs/ab/ce/abcdef"},
+		{name: "blobs/abcdef"},
+		{name: "blobs/ab/cd/../../abcdef"},
+		{name: "db/users.jsonl"},
+		{name: "blobs/../../...."},
+	}
+	for _, tt := range tests {
+		got, ok := blobUUID(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("blobUUID(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
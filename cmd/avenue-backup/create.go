@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/shared"
+
+	"github.com/spf13/afero"
+)
+
+func runCreate(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	out := fs.String("o", "", "where to write the archive, gzipped if it ends in .gz or .tgz (default avenue-backup-<time>.tar)")
+	sessions := fs.Bool("sessions", false, "include sessions, so users stay signed in after a restore")
+	base := fs.String("base", "", "only include blobs this earlier backup (or the ones it builds on) doesn't hold")
+	_ = fs.Parse(args)
+
+	var baseManifest *manifest
+	if *base != "" {
+		r, err := openArchive(*base)
+		if err != nil {
+			return err
+		}
+		baseManifest = &r.manifest
+		if err := r.close(); err != nil {
+			return err
+		}
+	}
+	if *out == "" {
+		*out = "avenue-backup-" + time.Now().UTC().Format("20060102T150405Z") + ".tar"
+	}
+
+	cfg := config.MustLoad()
+	if err := db.Connect(cfg.Database); err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	schema, err := db.AppliedMigration()
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	tables := db.BackupTables
+	if *sessions {
+		tables = append(tables[:len(tables):len(tables)], db.SessionsTable)
+	}
+
+	// Tables are dumped to temporary files first: tar needs each entry's
+	// size up front, and the manifest that lists them goes first.
+	tmp, err := os.MkdirTemp("", "avenue-backup-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	m, refs, err := dumpSnapshot(tables, tmp)
+	if err != nil {
+		return err
+	}
+	m.Schema = schema
+
+	var covered map[string]string
+	if baseManifest != nil {
+		m.Base = &baseManifest.SnapshotAt
+		covered = baseManifest.Covered
+	}
+	refs, m.Covered = blobsToBackUp(refs, covered)
+
+	fsys := afero.NewBasePathFs(afero.NewOsFs(), cfg.Storage.UploadDir)
+	var blobs []blobFile
+	failed := 0
+	for _, ref := range refs {
+		info, err := fsys.Stat(shared.BlobPath(ref.UUID))
+		if err != nil {
+			// Most likely purged from the trash since the snapshot.
+			log.Printf("file %s: %v, skipping", ref.UUID, err)
+			failed++
+			continue
+		}
+		blobs = append(blobs, blobFile{FileBlobRef: ref, size: info.Size()})
+		m.Covered[ref.UUID] = ref.Checksum
+		m.Blobs++
+		m.BlobBytes += info.Size()
+	}
+
+	w, err := createArchive(*out)
+	if err != nil {
+		return err
+	}
+	mismatched, err := writeArchive(w, m, tmp, blobs, fsys)
+	if err == nil {
+		err = w.close()
+	}
+	if err != nil {
+		w.abort()
+		return err
+	}
+	failed += mismatched
+
+	kind := "full"
+	if m.Base != nil {
+		kind = "incremental"
+	}
+	log.Printf("done: %s backup of %d tables and %d blobs (%s) written to %s, %d failed",
+		kind, len(m.Tables), m.Blobs, shared.FormatBytes(m.BlobBytes), *out, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d blobs couldn't be backed up cleanly; see above", failed)
+	}
+	return nil
+}
+
+// blobsToBackUp splits refs into the files whose blobs need backing up
+// and those a base backup already covers, given its Covered map (nil for a
+// full backup). A file is covered if the base holds its blob with the same
+// checksum, so it doesn't matter when the file was created or imported.
+// The covered files are returned in a new map that the caller adds the
+// backed up ones to.
+func blobsToBackUp(refs []db.FileBlobRef, base map[string]string) ([]db.FileBlobRef, map[string]string) {
+	var todo []db.FileBlobRef
+	covered := map[string]string{}
+	for _, ref := range refs {
+		if checksum, ok := base[ref.UUID]; ok && checksum == ref.Checksum {
+			covered[ref.UUID] = checksum
+			continue
+		}
+		todo = append(todo, ref)
+	}
+	return todo, covered
+}
+
+type blobFile struct {
+	db.FileBlobRef
+	size int64
+}
+
+// dumpSnapshot writes each table to dir as JSON lines, and lists every
+// file, all from one snapshot.
+func dumpSnapshot(tables []db.BackupTable, dir string) (manifest, []db.FileBlobRef, error) {
+	snap, err := db.BeginSnapshot(context.Background())
+	if err != nil {
+		return manifest{}, nil, fmt.Errorf("begin snapshot: %w", err)
+	}
+	defer func() { _ = snap.Close() }()
+
+	m := manifest{Format: formatVersion, SnapshotAt: snap.At}
+	for _, t := range tables {
+		n, err := dumpTable(snap, t.Name, filepath.Join(dir, t.Name+".jsonl"))
+		if err != nil {
+			return m, nil, fmt.Errorf("dump %s: %w", t.Name, err)
+		}
+		m.Tables = append(m.Tables, tableInfo{Name: t.Name, Rows: n})
+	}
+
+	refs, err := snap.FileBlobRefs()
+	if err != nil {
+		return m, nil, fmt.Errorf("list files: %w", err)
+	}
+	return m, refs, nil
+}
+
+func dumpTable(snap *db.Snapshot, table, path string) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+	n, err := snap.DumpTable(table, func(row []byte) error {
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+		return w.WriteByte('\n')
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	return n, errors.Join(err, f.Close())
+}
+
+// writeArchive writes everything, returning how many blobs didn't match
+// their stored checksum. Those are still written, since they're the only
+// copy there is, but restore will refuse them.
+func writeArchive(w *archiveWriter, m manifest, dir string, blobs []blobFile, fsys afero.Fs) (int, error) {
+	if err := w.writeJSON(manifestName, m); err != nil {
+		return 0, err
+	}
+	for _, t := range m.Tables {
+		if err := writeFileEntry(w, tableEntry(t.Name), filepath.Join(dir, t.Name+".jsonl")); err != nil {
+			return 0, fmt.Errorf("write %s: %w", t.Name, err)
+		}
+	}
+
+	mismatched := 0
+	for _, b := range blobs {
+		checksum, err := writeBlob(w, fsys, b)
+		if err != nil {
+			return mismatched, fmt.Errorf("file %s: %w", b.UUID, err)
+		}
+		if b.Checksum != "" && checksum != b.Checksum {
+			log.Printf("file %s: blob checksum %s doesn't match the stored %s", b.UUID, checksum, b.Checksum)
+			mismatched++
+		}
+	}
+	return mismatched, nil
+}
+
+func writeFileEntry(w *archiveWriter, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return w.writeEntry(name, info.Size(), f)
+}
+
+// writeBlob adds a file's blob and returns its SHA-256 checksum.
+func writeBlob(w *archiveWriter, fsys afero.Fs, b blobFile) (string, error) {
+	f, err := fsys.Open(shared.BlobPath(b.UUID))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	hasher := sha256.New()
+	if err := w.writeEntry(blobEntry(b.UUID), b.size, io.TeeReader(f, hasher)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
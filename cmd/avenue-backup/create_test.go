@@ -0,0 +1,38 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"avenue/backend/db"
+)
+
+func TestBlobsToBackUp(t *testing.T) {
+	refs := []db.FileBlobRef{
+		{UUID: "a", Checksum: "ca"},
+		{UUID: "b", Checksum: "cb2"},
+		{UUID: "c", Checksum: "cc"},
+		{UUID: "d"},
+	}
+
+	todo, covered := blobsToBackUp(refs, nil)
+	if len(todo) != len(refs) || len(covered) != 0 {
+		t.Errorf("full backup: todo %v, covered %v; want every file and none covered", todo, covered)
+	}
+
+	// b changed since the base, c was imported after it with an old
+	// creation time, and gone was deleted.
+	base := map[string]string{"a": "ca", "b": "cb1", "d": "", "gone": "cg"}
+	todo, covered = blobsToBackUp(refs, base)
+	var uuids []string
+	for _, ref := range todo {
+		uuids = append(uuids, ref.UUID)
+	}
+	if want := []string{"b", "c"}; !slices.Equal(uuids, want) {
+		t.Errorf("todo = %v, want %v", uuids, want)
+	}
+	if want := map[string]string{"a": "ca", "d": ""}; !maps.Equal(covered, want) {
+		t.Errorf("covered = %v, want %v", covered, want)
+	}
+}
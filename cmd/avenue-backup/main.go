@@ -0,0 +1,64 @@
+// Command avenue-backup backs up a whole Avenue instance to a single
+// archive and restores it, to recover from a disaster or move to a new
+// server.
+//
+// create takes a consistent snapshot of the database while the server
+// keeps running, then adds every file's blob, checked against the file's
+// stored checksum. Sessions are left out unless -sessions is given. With
+// -base, the backup is incremental: the database is still copied whole,
+// but only blobs the base backup doesn't hold with the same checksum are
+// included.
+//
+// restore rebuilds an instance from an archive. It runs the migrations and
+// needs an empty database, so run it before the server's first start (or
+// against a fresh database). With -incremental it instead replaces the
+// data of an instance restored from an earlier backup and adds the newer
+// blobs, skipping any already on disk with the right checksum. Each blob
+// is checked against its checksum as it's written.
+//
+// verify checks an archive's blobs against their checksums without
+// touching the database or disk.
+//
+// It reads the server's config (see package config) for the database and
+// upload directory.
+//
+// Usage:
+//
+//	go run ./cmd/avenue-backup create [-o file] [-sessions] [-base archive]
+//	go run ./cmd/avenue-backup restore [-incremental] archive
+//	go run ./cmd/avenue-backup verify archive
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = runCreate(os.Args[2:])
+	case "restore":
+		err = runRestore(os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: avenue-backup create [-o file] [-sessions] [-base archive]")
+	fmt.Fprintln(os.Stderr, "       avenue-backup restore [-incremental] archive")
+	fmt.Fprintln(os.Stderr, "       avenue-backup verify archive")
+	os.Exit(2)
+}
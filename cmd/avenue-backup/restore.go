@@ -0,0 +1,341 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/shared"
+
+	"github.com/spf13/afero"
+)
+
+// restoreBatch is how many rows go into each INSERT.
+const restoreBatch = 500
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	incremental := fs.Bool("incremental", false, "replace the data of an instance restored from an earlier backup instead of filling an empty one")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	r, err := openArchive(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.close() }()
+	m := r.manifest
+	if m.Base != nil && !*incremental {
+		return fmt.Errorf("%s is an incremental backup; restore the full backup it builds on, then this one with -incremental", fs.Arg(0))
+	}
+	tables, err := archiveTables(m)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.MustLoad()
+	if err := db.Connect(cfg.Database); err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	if err := db.RunMigrations(); err != nil {
+		return fmt.Errorf("db migrations: %w", err)
+	}
+	schema, err := db.AppliedMigration()
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	switch {
+	case m.Schema > schema:
+		return fmt.Errorf("the backup's schema (%s) is newer than this version's (%s); restore it with the version that made it", m.Schema, schema)
+	case m.Schema < schema:
+		return fmt.Errorf("the backup's schema (%s) is older than this version's (%s); restore it with the version that made it, then upgrade", m.Schema, schema)
+	}
+
+	restorer, err := db.BeginRestore(tables, *incremental)
+	if err != nil {
+		return fmt.Errorf("begin restore: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = restorer.Rollback()
+		}
+	}()
+	commit := func() error {
+		if committed {
+			return nil
+		}
+		committed = true
+		if err := restorer.Commit(); err != nil {
+			return fmt.Errorf("commit: %w", err)
+		}
+		log.Printf("restored %d tables", len(tables))
+		return nil
+	}
+
+	fsys := afero.NewBasePathFs(afero.NewOsFs(), cfg.Storage.UploadDir)
+	checksums := map[string]string{}
+	seen := map[string]bool{}
+	var written, unchanged, failed int
+
+	err = r.each(func(table string, body io.Reader) error {
+		return restoreTable(restorer, table, body, checksums)
+	}, func(uuid string, body io.Reader, size int64) error {
+		// The tables all come before the blobs.
+		if err := commit(); err != nil {
+			return err
+		}
+		want, known := checksums[uuid]
+		if !known {
+			log.Printf("blob %s belongs to no file, skipping", uuid)
+			return nil
+		}
+		seen[uuid] = true
+
+		if *incremental && want != "" {
+			if got, err := hashBlob(fsys, shared.BlobPath(uuid)); err == nil && got == want {
+				unchanged++
+				return nil
+			}
+		}
+		err := restoreBlob(fsys, uuid, body, want)
+		var mismatch checksumMismatch
+		if errors.As(err, &mismatch) {
+			log.Printf("file %s: %v, skipping", uuid, err)
+			failed++
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		written++
+		return nil
+	})
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Blobs not in the archive should already be on disk: from an earlier
+	// restore, for an incremental backup.
+	missing := 0
+	for uuid := range checksums {
+		if seen[uuid] {
+			continue
+		}
+		if ok, err := afero.Exists(fsys, shared.BlobPath(uuid)); err != nil || !ok {
+			log.Printf("file %s: blob missing from the archive and the upload directory", uuid)
+			missing++
+		}
+	}
+
+	log.Printf("done: %d blobs written, %d already up to date, %d failed, %d missing", written, unchanged, failed, missing)
+	if failed+missing > 0 {
+		return fmt.Errorf("%d files have no valid blob; see above", failed+missing)
+	}
+	return nil
+}
+
+// archiveTables maps the manifest's tables onto those the restore may
+// load, so an archive can't write anywhere else.
+func archiveTables(m manifest) ([]db.BackupTable, error) {
+	known := map[string]db.BackupTable{db.SessionsTable.Name: db.SessionsTable}
+	for _, t := range db.BackupTables {
+		known[t.Name] = t
+	}
+	tables := make([]db.BackupTable, 0, len(m.Tables))
+	for _, t := range m.Tables {
+		bt, ok := known[t.Name]
+		if !ok {
+			return nil, fmt.Errorf("the backup has an unknown table %q", t.Name)
+		}
+		tables = append(tables, bt)
+	}
+	return tables, nil
+}
+
+// restoreTable loads a table's rows in batches, noting each file's
+// checksum on the way.
+func restoreTable(restorer *db.Restorer, table string, body io.Reader, checksums map[string]string) error {
+	batch := make([]json.RawMessage, 0, restoreBatch)
+	n := 0
+	err := eachRow(body, func(row []byte) error {
+		if table == "files" {
+			var f fileRow
+			if err := json.Unmarshal(row, &f); err != nil {
+				return err
+			}
+			checksums[f.UUID] = ""
+			if f.Checksum != nil {
+				checksums[f.UUID] = *f.Checksum
+			}
+		}
+		batch = append(batch, row)
+		if len(batch) < restoreBatch {
+			return nil
+		}
+		n += len(batch)
+		err := restorer.Insert(table, batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	n += len(batch)
+	if err := restorer.Insert(table, batch); err != nil {
+		return err
+	}
+	log.Printf("table %s: %d rows", table, n)
+	return nil
+}
+
+type checksumMismatch struct {
+	got, want string
+}
+
+func (e checksumMismatch) Error() string {
+	return fmt.Sprintf("blob checksum %s doesn't match the stored %s", e.got, e.want)
+}
+
+// restoreBlob writes a blob to its place under the upload directory if it
+// matches want, via a temporary file so a bad or partial blob never
+// replaces a good one. A file with no stored checksum gets one.
+func restoreBlob(fsys afero.Fs, uuid string, body io.Reader, want string) error {
+	if err := shared.EnsureBlobDir(fsys, uuid); err != nil {
+		return err
+	}
+	final := shared.BlobPath(uuid)
+	tmp := final + ".restoring"
+	f, err := fsys.Create(tmp)
+	if err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hasher), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	got := hex.EncodeToString(hasher.Sum(nil))
+	if err == nil && want != "" && got != want {
+		err = checksumMismatch{got: got, want: want}
+	}
+	if err == nil {
+		err = fsys.Rename(tmp, final)
+	}
+	if err != nil {
+		_ = fsys.Remove(tmp)
+		return err
+	}
+
+	if want == "" {
+		return db.SetFileChecksum(uuid, got)
+	}
+	return nil
+}
+
+func hashBlob(fsys afero.Fs, path string) (string, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	r, err := openArchive(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.close() }()
+	m := r.manifest
+
+	checksums := map[string]string{}
+	seen := map[string]bool{}
+	var ok, unchecked, failed int
+	err = r.each(func(table string, body io.Reader) error {
+		if table != "files" {
+			return nil
+		}
+		return eachRow(body, func(row []byte) error {
+			var f fileRow
+			if err := json.Unmarshal(row, &f); err != nil {
+				return err
+			}
+			checksums[f.UUID] = ""
+			if f.Checksum != nil {
+				checksums[f.UUID] = *f.Checksum
+			}
+			return nil
+		})
+	}, func(uuid string, body io.Reader, size int64) error {
+		seen[uuid] = true
+		want, known := checksums[uuid]
+		if !known {
+			log.Printf("blob %s belongs to no file", uuid)
+			failed++
+			return nil
+		}
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, body); err != nil {
+			return err
+		}
+		got := hex.EncodeToString(hasher.Sum(nil))
+		switch {
+		case want == "":
+			unchecked++
+		case got != want:
+			log.Printf("file %s: %v", uuid, checksumMismatch{got: got, want: want})
+			failed++
+		default:
+			ok++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Every file's blob should be in the archive or, for an incremental
+	// backup, covered by the one it builds on.
+	for uuid, want := range checksums {
+		if seen[uuid] {
+			continue
+		}
+		if got, ok := m.Covered[uuid]; m.Base != nil && ok && got == want {
+			continue
+		}
+		log.Printf("file %s: blob missing", uuid)
+		failed++
+	}
+
+	fmt.Fprintf(os.Stdout, "%s: schema %s, snapshot %s, %d tables, %d blobs: %d ok, %d without a stored checksum, %d bad\n",
+		fs.Arg(0), m.Schema, m.SnapshotAt.Format("2006-01-02 15:04:05 MST"), len(m.Tables), len(seen), ok, unchecked, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d problems found", failed)
+	}
+	return nil
+}
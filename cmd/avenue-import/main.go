@@ -0,0 +1,103 @@
+// Command avenue-import imports a directory tree on the server's disk into
+// a user's drive, for migrating data too large to push through the upload
+// API. See package importer for what it creates.
+//
+// It's idempotent and safe to re-run: every folder and file gets a UUID
+// derived from its source path, so an interrupted import picks up where it
+// stopped and a finished one changes nothing. Unlike the admin import API
+// it can read from anywhere, and only the user's own quota is checked,
+// not folder or group quotas.
+//
+// It reads the server's config (see package config) for the database and
+// upload directory, and can run while the server does.
+//
+// Usage:
+//
+//	go run ./cmd/avenue-import -user <id or email> [-folder <uuid>] [-link] [-ignore-quota] [-dry-run] <dir>
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/importer"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/spf13/afero"
+)
+
+func main() {
+	user := flag.String("user", "", "id or email of the user to import for")
+	folder := flag.String("folder", "", "uuid of the user's folder to import into (default the root of their drive)")
+	link := flag.Bool("link", false, "hard-link blobs to the source files instead of copying them, where the filesystem allows")
+	ignoreQuota := flag.Bool("ignore-quota", false, "import even past the user's quota")
+	dryRun := flag.Bool("dry-run", false, "count what would be imported without touching disk or the database")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: avenue-import -user <id or email> [flags] <dir>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *user == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.MustLoad()
+	if err := db.Connect(cfg.Database); err != nil {
+		log.Fatalf("db connect: %v", err)
+	}
+	u, err := findUser(*user)
+	if err != nil {
+		log.Fatalf("find user %s: %v", *user, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fs := afero.NewBasePathFs(afero.NewOsFs(), cfg.Storage.UploadDir)
+	result, err := importer.Import(ctx, fs, cfg.Quotas, importer.Options{
+		Source:      flag.Arg(0),
+		UserID:      u.ID,
+		Folder:      *folder,
+		Link:        *link,
+		IgnoreQuota: *ignoreQuota,
+		DryRun:      *dryRun,
+	})
+
+	prefix, verb := "", "imported"
+	if *dryRun {
+		prefix, verb = "[dry run] ", "to import"
+	}
+	log.Printf("%sdone: %d folders and %d files (%s) %s for %s, %d already imported, %d failed",
+		prefix, result.Folders, result.Files, shared.FormatBytes(result.Bytes), verb, u.Email, result.Skipped, result.Failed)
+	if errors.Is(err, context.Canceled) {
+		log.Fatal("interrupted; run the same command again to resume")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if result.Failed > 0 {
+		log.Fatalf("%d files or folders failed; see above, then run the same command again to retry them", result.Failed)
+	}
+}
+
+func findUser(s string) (sdk.User, error) {
+	if strings.Contains(s, "@") {
+		return db.GetUserByEmail(s)
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return sdk.User{}, errors.New("not a user id or email")
+	}
+	return db.GetUserByID(id)
+}
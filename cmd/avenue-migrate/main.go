@@ -0,0 +1,160 @@
+// Command avenue-migrate shows and changes which database migrations are
+// applied. The server applies pending migrations itself when it starts;
+// this is for checking a database before an upgrade and for rolling back
+// after a bad one.
+//
+// status lists every migration with when it was applied, including any
+// applied by a newer version. up applies pending migrations, down rolls
+// back applied ones (the last one by default), and to applies or rolls
+// back until the given migration, by name or number, is the newest
+// applied; "to 0" rolls back everything. Each migration runs in a
+// transaction, so one that fails leaves the database as it was before it.
+//
+// Rolling back drops whatever the migrations created, data included, so
+// take a backup first (see cmd/avenue-backup) and stop the server, which
+// would otherwise migrate the database straight back up on restart.
+//
+// It reads the server's config (see package config) for the database.
+//
+// Usage:
+//
+//	go run ./cmd/avenue-migrate status
+//	go run ./cmd/avenue-migrate up [-n count]
+//	go run ./cmd/avenue-migrate down [-n count | -all]
+//	go run ./cmd/avenue-migrate to <name or number>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	var run func([]string) error
+	switch cmd {
+	case "status":
+		run = runStatus
+	case "up":
+		run = runUp
+	case "down":
+		run = runDown
+	case "to":
+		run = runTo
+	default:
+		usage()
+	}
+
+	cfg := config.MustLoad()
+	if err := db.Connect(cfg.Database); err != nil {
+		log.Fatalf("db connect: %v", err)
+	}
+	if err := run(args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: avenue-migrate status")
+	fmt.Fprintln(os.Stderr, "       avenue-migrate up [-n count]")
+	fmt.Fprintln(os.Stderr, "       avenue-migrate down [-n count | -all]")
+	fmt.Fprintln(os.Stderr, "       avenue-migrate to <name or number>")
+	os.Exit(2)
+}
+
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	_ = fs.Parse(args)
+
+	migrations, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED")
+	pending, unknown := 0, 0
+	for _, m := range migrations {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if m.AppliedAt == nil {
+			pending++
+		}
+		if m.Unknown {
+			applied += " (not in this build)"
+			unknown++
+		}
+		fmt.Fprintf(w, "%s\t%s\n", m.Name, applied)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case unknown > 0:
+		fmt.Printf("\nthe database was migrated by a newer version (%d migrations this build doesn't have); this build won't start against it\n", unknown)
+	case pending > 0:
+		fmt.Printf("\n%d pending; run up, or start the server, to apply them\n", pending)
+	default:
+		fmt.Println("\nup to date")
+	}
+	return nil
+}
+
+func runUp(args []string) error {
+	fs := flag.NewFlagSet("up", flag.ExitOnError)
+	n := fs.Int("n", 0, "apply only this many pending migrations (default all)")
+	_ = fs.Parse(args)
+	if *n < 0 || fs.NArg() != 0 {
+		usage()
+	}
+
+	ran, err := db.MigrateUp(*n)
+	return report("applied", ran, err)
+}
+
+func runDown(args []string) error {
+	fs := flag.NewFlagSet("down", flag.ExitOnError)
+	n := fs.Int("n", 1, "roll back this many migrations")
+	all := fs.Bool("all", false, "roll back every migration, dropping all of Avenue's tables")
+	_ = fs.Parse(args)
+	if *n < 1 || fs.NArg() != 0 {
+		usage()
+	}
+	if *all {
+		*n = 0
+	}
+
+	ran, err := db.MigrateDown(*n)
+	return report("rolled back", ran, err)
+}
+
+func runTo(args []string) error {
+	fs := flag.NewFlagSet("to", flag.ExitOnError)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	ran, err := db.MigrateTo(fs.Arg(0))
+	return report("ran", ran, err)
+}
+
+// report logs how many migrations ran; the db package logs each one.
+func report(verb string, names []string, err error) error {
+	if err == nil {
+		log.Printf("done: %d migrations %s", len(names), verb)
+	}
+	return err
+}
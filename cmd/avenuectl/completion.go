@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func runCompletion(a *app, args []string) error {
+	args, err := parseFlags(flags("completion"), args, 1)
+	if err != nil {
+		return err
+	}
+	top, subs := commandTree()
+	switch args[0] {
+	case "bash":
+		fmt.Fprint(a.out, bashCompletion(top, subs))
+	case "zsh":
+		// zsh runs bash completion scripts through bashcompinit.
+		fmt.Fprint(a.out, "autoload -U +X bashcompinit && bashcompinit\n"+bashCompletion(top, subs))
+	case "fish":
+		fmt.Fprint(a.out, fishCompletion(top, subs))
+	default:
+		return fmt.Errorf("completion: unsupported shell %q; use bash, zsh or fish", args[0])
+	}
+	return nil
+}
+
+// commandTree splits the command names into the top-level words and the
+// subcommands of each word that has them, in the order they're declared.
+func commandTree() (top []string, subs map[string][]string) {
+	subs = map[string][]string{}
+	for _, c := range commands {
+		word, sub, nested := strings.Cut(c.name, " ")
+		if _, seen := subs[word]; !seen {
+			top = append(top, word)
+			subs[word] = nil
+		}
+		if nested {
+			subs[word] = append(subs[word], sub)
+		}
+	}
+	return top, subs
+}
+
+func bashCompletion(top []string, subs map[string][]string) string {
+	var b strings.Builder
+	b.WriteString("_avenuectl() {\n")
+	b.WriteString("\tlocal cur=${COMP_WORDS[COMP_CWORD]}\n")
+	b.WriteString("\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(top, " "))
+	b.WriteString("\t\treturn\n\tfi\n")
+	b.WriteString("\tif [ \"$COMP_CWORD\" -eq 2 ]; then\n\t\tcase ${COMP_WORDS[1]} in\n")
+	for _, word := range top {
+		if len(subs[word]) > 0 {
+			fmt.Fprintf(&b, "\t\t%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", word, strings.Join(subs[word], " "))
+		}
+	}
+	b.WriteString("\t\tcompletion) COMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\")); return ;;\n")
+	b.WriteString("\t\tesac\n\tfi\n")
+	b.WriteString("\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -o filenames -F _avenuectl avenuectl\n")
+	return b.String()
+}
+
+func fishCompletion(top []string, subs map[string][]string) string {
+	summaries := map[string]string{}
+	for _, c := range commands {
+		summaries[c.name] = c.summary
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "complete -c avenuectl -n __fish_use_subcommand -f -a %q\n", strings.Join(top, " "))
+	for _, word := range top {
+		for _, sub := range subs[word] {
+			fmt.Fprintf(&b, "complete -c avenuectl -n '__fish_seen_subcommand_from %s' -f -a %s -d %q\n", word, sub, summaries[word+" "+sub])
+		}
+	}
+	b.WriteString("complete -c avenuectl -n '__fish_seen_subcommand_from completion' -f -a 'bash zsh fish'\n")
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+)
+
+// storedConfig is what avenuectl remembers between runs.
+type storedConfig struct {
+	Server string `json:"server"`
+	Email  string `json:"email,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+// defaultConfigPath is AVENUECTL_CONFIG, or avenuectl/config.json in the
+// user's config directory.
+func defaultConfigPath() string {
+	if path := os.Getenv("AVENUECTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "avenuectl.json"
+	}
+	return filepath.Join(dir, "avenuectl", "config.json")
+}
+
+// loadConfig reads the config at path. A missing file is an empty config.
+func loadConfig(path string) (storedConfig, error) {
+	var cfg storedConfig
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// saveConfig writes the config readable only by the user, since it holds
+// their session token.
+func (a *app) saveConfig() error {
+	data, err := json.MarshalIndent(a.cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.cfgPath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(a.cfgPath, append(data, '\n'), 0o600)
+}
+
+func runLogin(a *app, args []string) error {
+	fs := flags("login")
+	server := fs.String("server", a.cfg.Server, "the Avenue server's URL")
+	email := fs.String("email", a.cfg.Email, "your account's email")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin instead of prompting")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *server == "" {
+		return errors.New("login: no server; pass -server https://avenue.example.com")
+	}
+	stdin := bufio.NewReader(os.Stdin)
+	if *email == "" {
+		fmt.Fprint(os.Stderr, "Email: ")
+		line, err := stdin.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		*email = strings.TrimSpace(line)
+	}
+	password, err := readPassword(stdin, !*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	a.cfg.Server = strings.TrimSuffix(*server, "/")
+	a.cfg.Token = ""
+	a.connect()
+	resp, err := a.client.Login(a.header, sdk.LoginRequest{Email: *email, Password: password})
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	a.cfg.Email = *email
+	a.cfg.Token = resp.SessionID
+	if err := a.saveConfig(); err != nil {
+		return fmt.Errorf("login: save session: %w", err)
+	}
+	if a.json {
+		return a.printJSON(resp.UserData)
+	}
+	fmt.Fprintf(a.out, "Logged in to %s as %s\n", a.cfg.Server, resp.UserData.Email)
+	return nil
+}
+
+// readPassword reads a line from stdin, prompting with echo turned off if
+// prompt is set.
+func readPassword(stdin *bufio.Reader, prompt bool) (string, error) {
+	if prompt {
+		fmt.Fprint(os.Stderr, "Password: ")
+		if echoOff() {
+			defer func() {
+				echoOn()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// echoOff stops the terminal echoing what's typed, and reports whether it
+// could. It's a no-op when stdin isn't a terminal.
+func echoOff() bool {
+	cmd := exec.Command("stty", "-echo")
+	cmd.Stdin = os.Stdin
+	return cmd.Run() == nil
+}
+
+func echoOn() {
+	cmd := exec.Command("stty", "echo")
+	cmd.Stdin = os.Stdin
+	_ = cmd.Run()
+}
+
+func runLogout(a *app, args []string) error {
+	if _, err := parseFlags(flags("logout"), args, 0); err != nil {
+		return err
+	}
+	if a.cfg.Token != "" {
+		if _, err := a.client.Logout(a.header); err != nil {
+			fmt.Fprintf(os.Stderr, "avenuectl: logout: %v; forgetting the session anyway\n", err)
+		}
+	}
+	a.cfg.Token = ""
+	return a.saveConfig()
+}
+
+func runWhoami(a *app, args []string) error {
+	if _, err := parseFlags(flags("whoami"), args, 0); err != nil {
+		return err
+	}
+	user, err := a.client.GetProfile(a.header)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(user)
+	}
+	fmt.Fprintf(a.out, "%s (id %d) on %s\n", user.Email, user.ID, a.cfg.Server)
+	if user.Quota > 0 {
+		fmt.Fprintf(a.out, "using %s of %s\n", shared.FormatBytes(user.SpaceUsed), shared.FormatBytes(user.Quota))
+	}
+	return nil
+}
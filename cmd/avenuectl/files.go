@@ -0,0 +1,495 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+)
+
+const timeFormat = "2006-01-02 15:04"
+
+func (a *app) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+}
+
+// displayName is e's name with a trailing slash if it's a folder.
+func displayName(e entry) string {
+	if e.isFolder() {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+func runLs(a *app, args []string) error {
+	args, err := parseFlags(flags("ls"), args, 0)
+	if err != nil {
+		return err
+	}
+	target := "/"
+	if len(args) > 0 {
+		target = args[0]
+	}
+
+	e, err := a.resolve(target)
+	if err != nil {
+		return err
+	}
+	items := []entry{e}
+	if e.isFolder() {
+		if items, err = a.children(e); err != nil {
+			return err
+		}
+	}
+
+	if a.json {
+		return a.printJSON(items)
+	}
+	w := a.table()
+	for _, item := range items {
+		size := "-"
+		if !item.isFolder() {
+			size = shared.FormatBytes(item.FileSize)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", size, item.CreatedAt.Local().Format(timeFormat), displayName(item))
+	}
+	return w.Flush()
+}
+
+func runTree(a *app, args []string) error {
+	args, err := parseFlags(flags("tree"), args, 0)
+	if err != nil {
+		return err
+	}
+	target := "/"
+	if len(args) > 0 {
+		target = args[0]
+	}
+	root, err := a.resolve(target)
+	if err != nil {
+		return err
+	}
+
+	var all []entry
+	err = a.walk(root, func(e entry, depth int) error {
+		if a.json {
+			if depth > 0 {
+				all = append(all, e)
+			}
+			return nil
+		}
+		if depth == 0 {
+			fmt.Fprintln(a.out, e.Path)
+			return nil
+		}
+		fmt.Fprintf(a.out, "%s%s\n", strings.Repeat("  ", depth), displayName(e))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(all)
+	}
+	return nil
+}
+
+func runMkdir(a *app, args []string) error {
+	args, err := parseFlags(flags("mkdir"), args, 1)
+	if err != nil {
+		return err
+	}
+	var made []entry
+	for _, p := range args {
+		e, err := a.mkdirAll(p)
+		if err != nil {
+			return err
+		}
+		made = append(made, e)
+	}
+	if a.json {
+		return a.printJSON(made)
+	}
+	return nil
+}
+
+// transfer is one file uploaded or downloaded.
+type transfer struct {
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	Size   int64  `json:"size"`
+}
+
+func (a *app) report(t transfer, verb string) {
+	if !a.json {
+		fmt.Fprintf(a.out, "%s %s -> %s (%s)\n", verb, t.Local, t.Remote, shared.FormatBytes(t.Size))
+	}
+}
+
+func runUpload(a *app, args []string) error {
+	args, err := parseFlags(flags("upload"), args, 2)
+	if err != nil {
+		return err
+	}
+	sources, dest := args[:len(args)-1], args[len(args)-1]
+
+	destDir, err := a.mkdirAll(dest)
+	if err != nil {
+		return err
+	}
+
+	var done []transfer
+	for _, src := range sources {
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			t, err := a.uploadFile(src, destDir)
+			if err != nil {
+				return err
+			}
+			a.report(t, "uploaded")
+			done = append(done, t)
+			continue
+		}
+
+		// Mirror the directory under destDir, creating folders as they're
+		// reached; WalkDir visits a directory before what's in it.
+		folders := map[string]entry{}
+		root := filepath.Clean(src)
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			parent := destDir
+			if p != root {
+				parent = folders[filepath.Dir(p)]
+			}
+			if d.IsDir() {
+				f, err := a.mkdir(parent, d.Name())
+				if err != nil {
+					return err
+				}
+				folders[p] = f
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				fmt.Fprintf(os.Stderr, "avenuectl: skipping %s: not a regular file\n", p)
+				return nil
+			}
+			t, err := a.uploadFile(p, parent)
+			if err != nil {
+				return err
+			}
+			a.report(t, "uploaded")
+			done = append(done, t)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.json {
+		return a.printJSON(done)
+	}
+	return nil
+}
+
+// uploadFile uploads the local file at p into dir. The server renames it
+// with a " (N)" suffix if dir already has a file of the same name.
+func (a *app) uploadFile(p string, dir entry) (transfer, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return transfer{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return transfer{}, err
+	}
+
+	t := transfer{Local: p, Remote: path.Join(dir.Path, filepath.Base(p)), Size: info.Size()}
+	if _, err := a.client.UploadFile(a.header, filepath.Base(p), f, dir.UUID); err != nil {
+		return transfer{}, fmt.Errorf("upload %s: %w", p, err)
+	}
+	return t, nil
+}
+
+func runDownload(a *app, args []string) error {
+	args, err := parseFlags(flags("download"), args, 2)
+	if err != nil {
+		return err
+	}
+	sources, dest := args[:len(args)-1], args[len(args)-1]
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+
+	var done []transfer
+	for _, src := range sources {
+		root, err := a.resolve(src)
+		if err != nil {
+			return err
+		}
+		base := path.Dir(root.Path)
+		if root.UUID == "" {
+			base = "/"
+		}
+		err = a.walk(root, func(e entry, depth int) error {
+			rel := strings.TrimPrefix(strings.TrimPrefix(e.Path, base), "/")
+			local := filepath.Join(dest, filepath.FromSlash(rel))
+			if e.isFolder() {
+				return os.MkdirAll(local, 0o755)
+			}
+			t, err := a.downloadFile(e, local)
+			if err != nil {
+				return err
+			}
+			a.report(t, "downloaded")
+			done = append(done, t)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.json {
+		return a.printJSON(done)
+	}
+	return nil
+}
+
+// downloadFile writes the remote file e to local, via a temporary file so
+// an interrupted download doesn't leave a truncated file behind.
+func (a *app) downloadFile(e entry, local string) (transfer, error) {
+	resp, err := a.client.DownloadFile(a.header, e.UUID)
+	if err != nil {
+		return transfer{}, fmt.Errorf("download %s: %w", e.Path, err)
+	}
+	defer resp.Body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(local), ".avenuectl-*")
+	if err != nil {
+		return transfer{}, err
+	}
+	n, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), local)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return transfer{}, fmt.Errorf("download %s: %w", e.Path, err)
+	}
+
+	return transfer{Local: local, Remote: e.Path, Size: n}, nil
+}
+
+func runMv(a *app, args []string) error {
+	args, err := parseFlags(flags("mv"), args, 2)
+	if err != nil {
+		return err
+	}
+	sources, dest := args[:len(args)-1], args[len(args)-1]
+
+	items := make([]entry, len(sources))
+	for i, src := range sources {
+		if items[i], err = a.resolve(src); err != nil {
+			return err
+		}
+		if items[i].UUID == "" {
+			return errors.New("mv: can't move the root folder")
+		}
+	}
+
+	// Moving into an existing folder keeps the names; otherwise a single
+	// item is moved into dest's parent and renamed.
+	destDir, err := a.resolve(dest)
+	newName := ""
+	switch {
+	case err == nil && destDir.isFolder():
+	case err == nil || errors.Is(err, errNotFound):
+		if len(items) > 1 {
+			return fmt.Errorf("mv: %s: moving several items needs an existing folder", cleanRemote(dest))
+		}
+		if destDir, err = a.resolveFolder(path.Dir(cleanRemote(dest))); err != nil {
+			return err
+		}
+		newName = path.Base(cleanRemote(dest))
+	default:
+		return err
+	}
+
+	for _, item := range items {
+		if err := a.move(item, destDir, newName); err != nil {
+			return err
+		}
+		if !a.json {
+			fmt.Fprintf(a.out, "moved %s -> %s\n", item.Path, path.Join(destDir.Path, cmp.Or(newName, item.Name)))
+		}
+	}
+	return nil
+}
+
+func (a *app) move(item, dir entry, newName string) error {
+	if item.isFolder() {
+		if _, err := a.client.BulkMove(a.header, sdk.BulkMoveRequest{FolderIDs: []string{item.UUID}, Parent: dir.UUID}); err != nil {
+			return fmt.Errorf("mv %s: %w", item.Path, err)
+		}
+		if newName != "" && newName != item.Name {
+			return a.client.UpdateFolderName(a.header, item.UUID, newName)
+		}
+		return nil
+	}
+
+	if err := a.client.MoveFile(a.header, item.UUID, dir.UUID); err != nil {
+		return fmt.Errorf("mv %s: %w", item.Path, err)
+	}
+	if newName != "" && newName != item.Name {
+		return a.client.UpdateFileName(a.header, item.UUID, newName)
+	}
+	return nil
+}
+
+func runRm(a *app, args []string) error {
+	args, err := parseFlags(flags("rm"), args, 1)
+	if err != nil {
+		return err
+	}
+	for _, p := range args {
+		e, err := a.resolve(p)
+		if err != nil {
+			return err
+		}
+		switch {
+		case e.UUID == "":
+			return errors.New("rm: can't remove the root folder")
+		case e.isFolder():
+			_, err = a.client.DeleteFolder(a.header, e.UUID)
+		default:
+			err = a.client.DeleteFile(a.header, e.UUID)
+		}
+		if err != nil {
+			return fmt.Errorf("rm %s: %w", e.Path, err)
+		}
+		if !a.json {
+			fmt.Fprintf(a.out, "trashed %s\n", e.Path)
+		}
+	}
+	return nil
+}
+
+// trash lists every top-level item in the trash.
+func (a *app) trash() ([]sdk.FolderItem, error) {
+	var items []sdk.FolderItem
+	for page := 1; ; page++ {
+		resp, err := a.client.ListTrash(a.header, sdk.ListOptions{Page: page, Limit: pageLimit})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, resp.Items...)
+		if len(resp.Items) == 0 || len(items) >= resp.Total {
+			return items, nil
+		}
+	}
+}
+
+func runRestore(a *app, args []string) error {
+	args, err := parseFlags(flags("restore"), args, 1)
+	if err != nil {
+		return err
+	}
+	items, err := a.trash()
+	if err != nil {
+		return err
+	}
+
+	for _, want := range args {
+		var matches []sdk.FolderItem
+		for _, item := range items {
+			if item.UUID == want || item.Name == want {
+				matches = append(matches, item)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			return fmt.Errorf("restore: %q isn't in the trash", want)
+		case len(matches) > 1:
+			return fmt.Errorf("restore: %d items in the trash are called %q; use an id from \"trash list\"", len(matches), want)
+		}
+
+		item := matches[0]
+		if item.Type == sdk.FolderItemTypeFolder {
+			_, err = a.client.RestoreFolder(a.header, item.UUID)
+		} else {
+			_, err = a.client.RestoreFile(a.header, item.UUID)
+		}
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", item.Name, err)
+		}
+		if !a.json {
+			fmt.Fprintf(a.out, "restored %s\n", item.Name)
+		}
+	}
+	return nil
+}
+
+func runTrashList(a *app, args []string) error {
+	if _, err := parseFlags(flags("trash list"), args, 0); err != nil {
+		return err
+	}
+	items, err := a.trash()
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(items)
+	}
+	w := a.table()
+	for _, item := range items {
+		name := item.Name
+		if item.Type == sdk.FolderItemTypeFolder {
+			name += "/"
+		}
+		deleted := ""
+		if item.DeletedAt != nil {
+			deleted = item.DeletedAt.Local().Format(timeFormat)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", item.UUID, deleted, name)
+	}
+	return w.Flush()
+}
+
+func runTrashEmpty(a *app, args []string) error {
+	if _, err := parseFlags(flags("trash empty"), args, 0); err != nil {
+		return err
+	}
+	job, err := a.client.EmptyTrash(a.header)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(job)
+	}
+	if job.FinishedAt == nil {
+		fmt.Fprintf(a.out, "emptying the trash in the background (job %d)\n", job.ID)
+		return nil
+	}
+	fmt.Fprintf(a.out, "emptied the trash in %s\n", job.FinishedAt.Sub(job.CreatedAt).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,217 @@
+// Command avenuectl is a command-line client for an Avenue server, built on
+// package sdk. Log in once with "avenuectl login"; the session token is
+// stored in a config file and used by every other command.
+//
+// Remote paths are slash separated and start at the drive root, e.g.
+// "/Photos/2024". Every command can print JSON instead of tables with
+// -json, for scripting.
+//
+// Usage:
+//
+//	avenuectl [-json] [-config file] [-server url] <command> [args]
+//	avenuectl help
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"avenue/backend/sdk"
+)
+
+// command is one avenuectl subcommand. Names of nested commands, like
+// "share create", are space separated.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(a *app, args []string) error
+}
+
+// commands is set in init, since the help and completion commands refer
+// to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"login", "[-server url] [-email email] [-password-stdin]", "log in and store the session", runLogin},
+		{"logout", "", "end the session and forget it", runLogout},
+		{"whoami", "", "show the logged in user", runWhoami},
+		{"ls", "[path]", "list a folder", runLs},
+		{"tree", "[path]", "list a folder and everything under it", runTree},
+		{"mkdir", "path...", "create folders, and their parents as needed", runMkdir},
+		{"upload", "local... remote-folder", "upload files, and directories recursively", runUpload},
+		{"download", "remote... local-dir", "download files, and folders recursively", runDownload},
+		{"sync", "[-dry-run] [-watch] [-interval duration] local-dir remote-folder", "keep a local directory and a remote folder in sync", runSync},
+		{"mv", "path... destination", "move into a folder, or move and rename one item", runMv},
+		{"rm", "path...", "move files and folders to the trash", runRm},
+		{"restore", "name-or-id...", "restore items from the trash", runRestore},
+		{"trash list", "", "list the trash", runTrashList},
+		{"trash empty", "", "permanently delete everything in the trash", runTrashEmpty},
+		{"share create", "[-expires duration] [-require-login] [-allow-upload] [-max-file-size bytes] path", "create a share link for a file or folder", runShareCreate},
+		{"share list", "[-expired]", "list your share links", runShareList},
+		{"share revoke", "token...", "revoke share links", runShareRevoke},
+		{"sessions list", "", "list your active sessions", runSessionsList},
+		{"sessions revoke", "[-others] [id...]", "revoke sessions by id, or all but this one", runSessionsRevoke},
+		{"completion", "bash|zsh|fish", "print a shell completion script", runCompletion},
+		{"help", "", "show this help", runHelp},
+	}
+}
+
+// app is what every command runs with.
+type app struct {
+	client  *sdk.Client
+	header  http.Header
+	cfg     storedConfig
+	cfgPath string
+	json    bool
+	out     io.Writer
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "avenuectl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	global := flag.NewFlagSet("avenuectl", flag.ContinueOnError)
+	global.Usage = func() { printUsage(global.Output()) }
+	asJSON := global.Bool("json", false, "print JSON instead of tables")
+	cfgPath := global.String("config", defaultConfigPath(), "where the login is stored (env AVENUECTL_CONFIG)")
+	server := global.String("server", "", "the Avenue server's URL (env AVENUE_SERVER), overriding the stored one")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cmd, rest, err := findCommand(global.Args())
+	if err != nil {
+		printUsage(os.Stderr)
+		return err
+	}
+
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		return err
+	}
+	if *server != "" {
+		cfg.Server = *server
+	} else if env := os.Getenv("AVENUE_SERVER"); env != "" {
+		cfg.Server = env
+	}
+	if env := os.Getenv("AVENUE_TOKEN"); env != "" {
+		cfg.Token = env
+	}
+
+	a := &app{cfg: cfg, cfgPath: *cfgPath, json: *asJSON, out: out}
+	a.connect()
+	if err := cmd.run(a, rest); !errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return nil
+}
+
+// connect points the client at the configured server with the stored
+// session.
+func (a *app) connect() {
+	a.client = sdk.NewClient(strings.TrimSuffix(a.cfg.Server, "/"))
+	a.header = http.Header{}
+	if a.cfg.Token != "" {
+		a.header.Set("Authorization", "Token "+a.cfg.Token)
+	}
+}
+
+// findCommand picks the command named by the start of args, preferring the
+// longest name, and returns it with the remaining args.
+func findCommand(args []string) (command, []string, error) {
+	if len(args) == 0 {
+		return command{}, nil, errors.New("no command given")
+	}
+	if len(args) > 1 {
+		for _, c := range commands {
+			if c.name == args[0]+" "+args[1] {
+				return c, args[2:], nil
+			}
+		}
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c, args[1:], nil
+		}
+	}
+
+	var subs []string
+	for _, c := range commands {
+		if sub, ok := strings.CutPrefix(c.name, args[0]+" "); ok {
+			subs = append(subs, sub)
+		}
+	}
+	if len(subs) > 0 {
+		return command{}, nil, fmt.Errorf("%s needs a subcommand: %s", args[0], strings.Join(subs, ", "))
+	}
+	return command{}, nil, fmt.Errorf("unknown command %q", args[0])
+}
+
+// flags returns a flag set for the named command that prints its usage.
+func flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(fs.Output(), "usage: avenuectl %s %s\n\n%s\n", c.name, c.args, c.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs and checks there are at least min
+// positional args left.
+func parseFlags(fs *flag.FlagSet, args []string, min int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() < min {
+		fs.Usage()
+		return nil, fmt.Errorf("%s: expected at least %d argument(s)", fs.Name(), min)
+	}
+	return fs.Args(), nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: avenuectl [-json] [-config file] [-server url] <command> [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	width := 0
+	for _, c := range commands {
+		names = append(names, c.name)
+		width = max(width, len(c.name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(w, "  %-*s  %s\n", width, c.name, c.summary)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "avenuectl <command> -h" for a command's arguments.`)
+}
+
+func runHelp(a *app, args []string) error {
+	printUsage(a.out)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		wantRest []string
+		wantErr  bool
+	}{
+		{name: "single word", args: []string{"ls", "/Photos"}, want: "ls", wantRest: []string{"/Photos"}},
+		{name: "nested", args: []string{"share", "create", "-expires", "1h", "/a"}, want: "share create", wantRest: []string{"-expires", "1h", "/a"}},
+		{name: "missing subcommand", args: []string{"share"}, wantErr: true},
+		{name: "unknown", args: []string{"frobnicate"}, wantErr: true},
+		{name: "none", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, rest, err := findCommand(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("findCommand(%q) found %q, want an error", tt.args, cmd.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmd.name != tt.want || !slices.Equal(rest, tt.wantRest) {
+				t.Errorf("findCommand(%q) = %q, %q, want %q, %q", tt.args, cmd.name, rest, tt.want, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestSplitRemote(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "", want: nil},
+		{path: "/", want: nil},
+		{path: "Photos", want: []string{"Photos"}},
+		{path: "/Photos/2024/", want: []string{"Photos", "2024"}},
+		{path: "/Photos//./2024/../2025", want: []string{"Photos", "2025"}},
+		{path: "/../..", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitRemote(tt.path); !slices.Equal(got, tt.want) {
+			t.Errorf("splitRemote(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"avenue/backend/sdk"
+)
+
+// pageLimit is the most items the server returns per page.
+const pageLimit = 200
+
+// entry is a file or folder found at a remote path. The root folder has
+// an empty UUID.
+type entry struct {
+	sdk.FolderItem
+	Path string `json:"path"`
+}
+
+func (e entry) isFolder() bool {
+	return e.Type == sdk.FolderItemTypeFolder
+}
+
+var errNotFound = errors.New("no such file or folder")
+
+// cleanRemote turns a remote path into its clean, absolute form.
+func cleanRemote(p string) string {
+	return path.Clean("/" + p)
+}
+
+// splitRemote returns the names along a clean remote path, none for the
+// root.
+func splitRemote(p string) []string {
+	p = strings.Trim(cleanRemote(p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func rootEntry() entry {
+	return entry{FolderItem: sdk.FolderItem{Type: sdk.FolderItemTypeFolder, Name: "/"}, Path: "/"}
+}
+
+// list returns everything in folderID, "" being the root, across all
+// pages.
+func (a *app) list(folderID string) ([]sdk.FolderItem, error) {
+	var items []sdk.FolderItem
+	for page := 1; ; page++ {
+		resp, err := a.client.ListFolderContents(a.header, folderID, sdk.ListOptions{Page: page, Limit: pageLimit})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, resp.Items...)
+		if len(resp.Items) == 0 || len(items) >= resp.Total {
+			return items, nil
+		}
+	}
+}
+
+// children lists the folder at dir as entries.
+func (a *app) children(dir entry) ([]entry, error) {
+	items, err := a.list(dir.UUID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]entry, len(items))
+	for i, item := range items {
+		out[i] = entry{FolderItem: item, Path: path.Join(dir.Path, item.Name)}
+	}
+	return out, nil
+}
+
+// child returns the item called name in dir. A folder is preferred over a
+// file of the same name when wantFolder is set.
+func (a *app) child(dir entry, name string, wantFolder bool) (entry, error) {
+	items, err := a.children(dir)
+	if err != nil {
+		return entry{}, err
+	}
+	found := -1
+	for i, item := range items {
+		if item.Name != name {
+			continue
+		}
+		if found < 0 || (wantFolder && item.isFolder()) {
+			found = i
+		}
+	}
+	if found < 0 {
+		return entry{}, fmt.Errorf("%s: %w", path.Join(dir.Path, name), errNotFound)
+	}
+	return items[found], nil
+}
+
+// resolve finds the file or folder at remote path p.
+func (a *app) resolve(p string) (entry, error) {
+	cur := rootEntry()
+	names := splitRemote(p)
+	for i, name := range names {
+		if !cur.isFolder() {
+			return entry{}, fmt.Errorf("%s: not a folder", cur.Path)
+		}
+		next, err := a.child(cur, name, i < len(names)-1)
+		if err != nil {
+			return entry{}, err
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// resolveFolder is resolve for a path that must be a folder.
+func (a *app) resolveFolder(p string) (entry, error) {
+	e, err := a.resolve(p)
+	if err != nil {
+		return entry{}, err
+	}
+	if !e.isFolder() {
+		return entry{}, fmt.Errorf("%s: not a folder", e.Path)
+	}
+	return e, nil
+}
+
+// mkdir returns the folder called name in dir, creating it if there isn't
+// one.
+func (a *app) mkdir(dir entry, name string) (entry, error) {
+	existing, err := a.child(dir, name, true)
+	if err == nil && existing.isFolder() {
+		return existing, nil
+	}
+	if err != nil && !errors.Is(err, errNotFound) {
+		return entry{}, err
+	}
+
+	if err := a.client.CreateFolder(a.header, name, dir.UUID); err != nil {
+		return entry{}, fmt.Errorf("create %s: %w", path.Join(dir.Path, name), err)
+	}
+	// The API doesn't return the new folder, so look it up.
+	return a.child(dir, name, true)
+}
+
+// mkdirAll creates the folder at remote path p and any missing parents.
+func (a *app) mkdirAll(p string) (entry, error) {
+	cur := rootEntry()
+	for _, name := range splitRemote(p) {
+		next, err := a.mkdir(cur, name)
+		if err != nil {
+			return entry{}, err
+		}
+		if !next.isFolder() {
+			return entry{}, fmt.Errorf("%s: not a folder", next.Path)
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// walk calls fn for e and, if it's a folder, everything under it, parents
+// before their children.
+func (a *app) walk(e entry, fn func(e entry, depth int) error) error {
+	return a.walkDepth(e, 0, fn)
+}
+
+func (a *app) walkDepth(e entry, depth int, fn func(e entry, depth int) error) error {
+	if err := fn(e, depth); err != nil {
+		return err
+	}
+	if !e.isFolder() {
+		return nil
+	}
+	children, err := a.children(e)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		if err := a.walkDepth(c, depth+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *app) printJSON(v any) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
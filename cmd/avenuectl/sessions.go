@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+func runSessionsList(a *app, args []string) error {
+	if _, err := parseFlags(flags("sessions list"), args, 0); err != nil {
+		return err
+	}
+	sessions, err := a.client.ListSessions(a.header)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(sessions)
+	}
+	w := a.table()
+	for _, s := range sessions {
+		current := ""
+		if s.IsCurrent {
+			current = " (this one)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s%s\n", s.ID, s.CreatedAt.Local().Format(timeFormat), s.IPAddress, truncate(s.UserAgent, 50), current)
+	}
+	return w.Flush()
+}
+
+func runSessionsRevoke(a *app, args []string) error {
+	fs := flags("sessions revoke")
+	others := fs.Bool("others", false, "revoke every session but this one")
+	args, err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	if *others {
+		if len(args) > 0 {
+			return errors.New("sessions revoke: pass -others or session ids, not both")
+		}
+		if err := a.client.RevokeOtherSessions(a.header); err != nil {
+			return err
+		}
+		if !a.json {
+			fmt.Fprintln(a.out, "revoked every other session")
+		}
+		return nil
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("sessions revoke: expected session ids or -others")
+	}
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("sessions revoke: invalid session id %q", arg)
+		}
+		if err := a.client.RevokeSession(a.header, id); err != nil {
+			return fmt.Errorf("revoke session %d: %w", id, err)
+		}
+		if !a.json {
+			fmt.Fprintf(a.out, "revoked session %d\n", id)
+		}
+	}
+	return nil
+}
+
+// truncate shortens s to at most n runes, marking the cut with "...".
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
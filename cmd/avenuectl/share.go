@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+// share is a file or folder share link as avenuectl lists it.
+type share struct {
+	Token        string     `json:"token"`
+	Type         string     `json:"type"`
+	Name         string     `json:"name"`
+	URL          string     `json:"url"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RequireLogin bool       `json:"require_login"`
+	AllowUpload  bool       `json:"allow_upload"`
+}
+
+// shareURL is the frontend page for a share link.
+func (a *app) shareURL(token string, folder bool) string {
+	if folder {
+		return a.cfg.Server + "/share/folder/" + token
+	}
+	return a.cfg.Server + "/share/" + token
+}
+
+func runShareCreate(a *app, args []string) error {
+	fs := flags("share create")
+	expires := fs.Duration("expires", 0, "how long the link works for, e.g. 72h; forever if 0")
+	requireLogin := fs.Bool("require-login", false, "only logged in users can open the link")
+	allowUpload := fs.Bool("allow-upload", false, "let visitors upload into a shared folder")
+	maxFileSize := fs.Int64("max-file-size", 0, "the largest upload a folder link accepts, in bytes; the server's limit if 0")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	e, err := a.resolve(args[0])
+	if err != nil {
+		return err
+	}
+	if e.UUID == "" {
+		return errors.New("share create: can't share the root folder")
+	}
+	req := sdk.CreateShareLinkRequest{RequireLogin: *requireLogin}
+	if *expires > 0 {
+		at := time.Now().Add(*expires)
+		req.ExpiresAt = &at
+	}
+
+	var resp sdk.V1ShareLinkResponse
+	if e.isFolder() {
+		req.AllowUpload = *allowUpload
+		req.MaxFileSize = *maxFileSize
+		resp, err = a.client.CreateFolderShareLink(a.header, e.UUID, req)
+	} else {
+		if *allowUpload {
+			return errors.New("share create: -allow-upload only applies to folders")
+		}
+		resp, err = a.client.CreateShareLink(a.header, e.UUID, req)
+	}
+	if err != nil {
+		return fmt.Errorf("share %s: %w", e.Path, err)
+	}
+
+	s := share{
+		Token:        resp.Token,
+		Type:         e.Type,
+		Name:         e.Name,
+		URL:          a.shareURL(resp.Token, e.isFolder()),
+		ExpiresAt:    resp.ExpiresAt,
+		CreatedAt:    resp.CreatedAt,
+		RequireLogin: req.RequireLogin,
+		AllowUpload:  req.AllowUpload,
+	}
+	if a.json {
+		return a.printJSON(s)
+	}
+	fmt.Fprintln(a.out, s.URL)
+	return nil
+}
+
+func runShareList(a *app, args []string) error {
+	fs := flags("share list")
+	expired := fs.Bool("expired", false, "list links that have expired instead")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	listFiles, listFolders := a.client.ListUserShares, a.client.ListUserFolderShares
+	if *expired {
+		listFiles, listFolders = a.client.ListExpiredUserShares, a.client.ListExpiredUserFolderShares
+	}
+	files, err := listFiles(a.header)
+	if err != nil {
+		return err
+	}
+	folders, err := listFolders(a.header)
+	if err != nil {
+		return err
+	}
+
+	shares := make([]share, 0, len(files)+len(folders))
+	for _, l := range files {
+		shares = append(shares, share{
+			Token:        l.Token,
+			Type:         sdk.FolderItemTypeFile,
+			Name:         l.FileName,
+			URL:          a.shareURL(l.Token, false),
+			ExpiresAt:    l.ExpiresAt,
+			CreatedAt:    l.CreatedAt,
+			RequireLogin: l.RequireLogin,
+		})
+	}
+	for _, l := range folders {
+		shares = append(shares, share{
+			Token:        l.Token,
+			Type:         sdk.FolderItemTypeFolder,
+			Name:         l.FolderName,
+			URL:          a.shareURL(l.Token, true),
+			ExpiresAt:    l.ExpiresAt,
+			CreatedAt:    l.CreatedAt,
+			RequireLogin: l.RequireLogin,
+			AllowUpload:  l.AllowUpload,
+		})
+	}
+
+	if a.json {
+		return a.printJSON(shares)
+	}
+	w := a.table()
+	for _, s := range shares {
+		expiry := "never"
+		if s.ExpiresAt != nil {
+			expiry = s.ExpiresAt.Local().Format(timeFormat)
+		}
+		name := s.Name
+		if s.Type == sdk.FolderItemTypeFolder {
+			name += "/"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Token, expiry, name, shareOptions(s))
+	}
+	return w.Flush()
+}
+
+func shareOptions(s share) string {
+	switch {
+	case s.RequireLogin && s.AllowUpload:
+		return "login, upload"
+	case s.RequireLogin:
+		return "login"
+	case s.AllowUpload:
+		return "upload"
+	}
+	return ""
+}
+
+func runShareRevoke(a *app, args []string) error {
+	args, err := parseFlags(flags("share revoke"), args, 1)
+	if err != nil {
+		return err
+	}
+	for _, token := range args {
+		// Tokens don't say whether they're for a file or a folder, so try
+		// the file link first.
+		err := a.client.RevokeShareLink(a.header, token)
+		if isStatus(err, http.StatusNotFound) {
+			err = a.client.RevokeShareFolderLink(a.header, token)
+		}
+		if err != nil {
+			return fmt.Errorf("revoke %s: %w", token, err)
+		}
+		if !a.json {
+			fmt.Fprintf(a.out, "revoked %s\n", token)
+		}
+	}
+	return nil
+}
+
+// isStatus reports whether err is an API error with the given status.
+func isStatus(err error, status int) bool {
+	var apiErr *sdk.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
@@ -0,0 +1,714 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateFile is where sync keeps what it last saw, in the root of the
+// local directory. It's never synced itself.
+const stateFile = ".avenuesync.json"
+
+// syncState is the last state both sides agreed on. A change is anything
+// that differs from it, which is how sync tells a file deleted on one
+// side from a file created on the other.
+type syncState struct {
+	Server string                `json:"server"`
+	Folder string                `json:"folder"`
+	Files  map[string]syncedFile `json:"files"`
+	Dirs   []string              `json:"dirs"`
+}
+
+// syncedFile is a file present and identical on both sides. Size and
+// ModTime let an unchanged local file skip being hashed again.
+type syncedFile struct {
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Remote  string    `json:"remote"`
+	UUID    string    `json:"uuid"`
+}
+
+// snapshot is one side's files and folders, keyed by slash-separated path
+// relative to the synced folder. A file's version is its SHA-256 locally
+// and its stored checksum remotely, or its UUID prefixed with uuidVersion
+// if the server hasn't checksummed it.
+type snapshot struct {
+	files map[string]string
+	sizes map[string]int64
+	dirs  map[string]bool
+	// ids holds remote UUIDs, to follow files moved on the server.
+	ids map[string]string
+}
+
+const uuidVersion = "uuid:"
+
+func newSnapshot() snapshot {
+	return snapshot{files: map[string]string{}, sizes: map[string]int64{}, dirs: map[string]bool{}, ids: map[string]string{}}
+}
+
+// The things sync does, in the order it does them.
+const (
+	opMkdirLocal   = "mkdir-local"
+	opMkdirRemote  = "mkdir-remote"
+	opMoveLocal    = "move-local"
+	opMoveRemote   = "move-remote"
+	opUpload       = "upload"
+	opDownload     = "download"
+	opConflict     = "conflict"
+	opDeleteLocal  = "delete-local"
+	opDeleteRemote = "delete-remote"
+	opRmdirLocal   = "rmdir-local"
+	opRmdirRemote  = "rmdir-remote"
+)
+
+var opOrder = map[string]int{
+	opMkdirLocal: 0, opMkdirRemote: 0,
+	opMoveLocal: 1, opMoveRemote: 1,
+	opUpload: 2, opDownload: 2, opConflict: 2,
+	opDeleteLocal: 3, opDeleteRemote: 3,
+	opRmdirLocal: 4, opRmdirRemote: 4,
+}
+
+type action struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// From is the old path of a moved file.
+	From string `json:"from,omitempty"`
+}
+
+func (act action) String() string {
+	if act.From != "" {
+		return fmt.Sprintf("%-13s %s -> %s", act.Op, act.From, act.Path)
+	}
+	return fmt.Sprintf("%-13s %s", act.Op, act.Path)
+}
+
+// plan works out what makes local and remote the same again, given what
+// they were the last time they were (base). A file changed on one side is
+// copied to the other, deleting it included. A file changed on both sides
+// is a conflict, unless the change is the same; a deletion loses to an
+// edit. A file new on both sides whose remote copy has no checksum yet is
+// taken to be the same if the sizes match (see unchecksummedMatch). Moves
+// are spotted by content locally and by UUID remotely, so they don't cost
+// a transfer.
+func plan(local, remote snapshot, base syncState) []action {
+	var acts []action
+	done := map[string]bool{}
+	// kept is every file that exists somewhere once the plan has run.
+	kept := map[string]bool{}
+
+	paths := make([]string, 0, len(base.Files))
+	for p := range base.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	newLocal := unbased(local.files, remote.files, base.Files)
+	newRemote := unbased(remote.files, local.files, base.Files)
+
+	for _, from := range paths {
+		b := base.Files[from]
+		_, inLocal := local.files[from]
+		_, inRemote := remote.files[from]
+		switch {
+		case !inLocal && inRemote && remote.files[from] == b.Remote:
+			// Gone locally but unchanged remotely: moved if the same
+			// content turned up somewhere new.
+			for _, to := range newLocal {
+				if !done[to] && local.files[to] == b.Hash {
+					acts = append(acts, action{Op: opMoveRemote, Path: to, From: from})
+					done[from], done[to], kept[to] = true, true, true
+					break
+				}
+			}
+		case inLocal && !inRemote && local.files[from] == b.Hash && b.UUID != "":
+			for _, to := range newRemote {
+				if !done[to] && remote.ids[to] == b.UUID {
+					acts = append(acts, action{Op: opMoveLocal, Path: to, From: from})
+					done[from], done[to], kept[to] = true, true, true
+					break
+				}
+			}
+		}
+	}
+
+	for p := range local.files {
+		paths = append(paths, p)
+	}
+	for p := range remote.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	paths = slices.Compact(paths)
+
+	for _, p := range paths {
+		if done[p] {
+			continue
+		}
+		l, inLocal := local.files[p]
+		r, inRemote := remote.files[p]
+		b, inBase := base.Files[p]
+		localChanged := inLocal != inBase || (inBase && l != b.Hash)
+		remoteChanged := inRemote != inBase || (inBase && r != b.Remote)
+
+		switch {
+		case !localChanged && !remoteChanged:
+			if inLocal {
+				kept[p] = true
+			}
+		case localChanged && !remoteChanged:
+			if inLocal {
+				acts = append(acts, action{Op: opUpload, Path: p})
+				kept[p] = true
+			} else {
+				acts = append(acts, action{Op: opDeleteRemote, Path: p})
+			}
+		case remoteChanged && !localChanged:
+			if inRemote {
+				acts = append(acts, action{Op: opDownload, Path: p})
+				kept[p] = true
+			} else {
+				acts = append(acts, action{Op: opDeleteLocal, Path: p})
+			}
+		case inLocal && inRemote:
+			if l != r && (inBase || !unchecksummedMatch(local, remote, p)) {
+				acts = append(acts, action{Op: opConflict, Path: p})
+			}
+			kept[p] = true
+		case inLocal:
+			acts = append(acts, action{Op: opUpload, Path: p})
+			kept[p] = true
+		case inRemote:
+			acts = append(acts, action{Op: opDownload, Path: p})
+			kept[p] = true
+		}
+	}
+
+	acts = append(acts, planDirs(local.dirs, remote.dirs, base.Dirs, kept)...)
+	sort.SliceStable(acts, func(i, j int) bool {
+		oi, oj := opOrder[acts[i].Op], opOrder[acts[j].Op]
+		if oi != oj {
+			return oi < oj
+		}
+		// Parents are made before their children and removed after them.
+		if oi == opOrder[opRmdirLocal] {
+			return acts[i].Path > acts[j].Path
+		}
+		return acts[i].Path < acts[j].Path
+	})
+	return acts
+}
+
+// unchecksummedMatch reports whether the remote copy of p has no checksum
+// yet and is the size of the local one, which is as close as sync can get
+// to telling they're the same without downloading it.
+func unchecksummedMatch(local, remote snapshot, p string) bool {
+	return strings.HasPrefix(remote.files[p], uuidVersion) && local.sizes[p] == remote.sizes[p]
+}
+
+// unbased returns the sorted paths in files that are on neither the other
+// side nor in base.
+func unbased(files, other map[string]string, base map[string]syncedFile) []string {
+	var out []string
+	for p := range files {
+		_, inOther := other[p]
+		_, inBase := base[p]
+		if !inOther && !inBase {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// planDirs creates a folder missing on one side if it's new, or if it
+// still holds a file that's being kept. Otherwise it was deleted and goes
+// on the other side too.
+func planDirs(local, remote map[string]bool, base []string, kept map[string]bool) []action {
+	inBase := map[string]bool{}
+	for _, d := range base {
+		inBase[d] = true
+	}
+	holdsFile := func(dir string) bool {
+		for p := range kept {
+			if strings.HasPrefix(p, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	var acts []action
+	removed := map[string]bool{}
+	dirs := make([]string, 0, len(local)+len(remote))
+	for d := range local {
+		dirs = append(dirs, d)
+	}
+	for d := range remote {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	for _, d := range slices.Compact(dirs) {
+		switch {
+		case local[d] && remote[d]:
+		case local[d] && (!inBase[d] || holdsFile(d)):
+			acts = append(acts, action{Op: opMkdirRemote, Path: d})
+		case remote[d] && (!inBase[d] || holdsFile(d)):
+			acts = append(acts, action{Op: opMkdirLocal, Path: d})
+		case local[d]:
+			acts = append(acts, action{Op: opRmdirLocal, Path: d})
+		case !removed[path.Dir(d)]:
+			// Trashing a remote folder takes everything in it along.
+			acts = append(acts, action{Op: opRmdirRemote, Path: d})
+			removed[d] = true
+		default:
+			removed[d] = true
+		}
+	}
+	return acts
+}
+
+// conflictName is where the local copy of a conflicting file is kept,
+// e.g. "notes (conflict 2026-01-02 150405).txt".
+func conflictName(p string, now time.Time) string {
+	ext := path.Ext(p)
+	if ext == p || strings.HasSuffix(p, "/"+ext) {
+		ext = ""
+	}
+	return fmt.Sprintf("%s (conflict %s)%s", strings.TrimSuffix(p, ext), now.Format("2006-01-02 150405"), ext)
+}
+
+func runSync(a *app, args []string) error {
+	fs := flags("sync")
+	dryRun := fs.Bool("dry-run", false, "print what would change without changing anything")
+	watch := fs.Bool("watch", false, "keep syncing until interrupted")
+	interval := fs.Duration("interval", 30*time.Second, "how often -watch syncs")
+	args, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	if len(args) > 2 {
+		return errors.New("sync: expected a local directory and a remote folder")
+	}
+	if *interval <= 0 {
+		return errors.New("sync: -interval must be positive")
+	}
+
+	s := &syncer{app: a, localRoot: filepath.Clean(args[0]), remotePath: cleanRemote(args[1]), dryRun: *dryRun}
+	if info, err := os.Stat(s.localRoot); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("sync: %s is not a directory", s.localRoot)
+	}
+	if !*watch {
+		return s.sync()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for {
+		// A failed pass is retried next time round rather than ending the
+		// watch; the server may just be unreachable for a while.
+		if err := s.sync(); err != nil {
+			fmt.Fprintf(os.Stderr, "avenuectl: sync: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// syncer runs sync passes between one local directory and remote folder.
+type syncer struct {
+	*app
+	localRoot  string
+	remotePath string
+	dryRun     bool
+
+	// Filled in by each pass's scan.
+	base        syncState
+	localInfo   map[string]syncedFile
+	remoteFiles map[string]entry
+	folders     map[string]entry
+}
+
+func (s *syncer) sync() error {
+	base, err := s.loadState()
+	if err != nil {
+		return err
+	}
+	s.base = base
+	local, err := s.scanLocal()
+	if err != nil {
+		return err
+	}
+	remote, err := s.scanRemote()
+	if err != nil {
+		return err
+	}
+
+	acts := plan(local, remote, base)
+	if acts == nil {
+		acts = []action{}
+	}
+	if s.dryRun {
+		if s.json {
+			return s.printJSON(acts)
+		}
+		for _, act := range acts {
+			fmt.Fprintln(s.out, act)
+		}
+		return nil
+	}
+
+	applied := make([]action, 0, len(acts))
+	var applyErr error
+	for _, act := range acts {
+		if applyErr = s.apply(act); applyErr != nil {
+			applyErr = fmt.Errorf("%s %s: %w", act.Op, act.Path, applyErr)
+			break
+		}
+		applied = append(applied, act)
+		if !s.json {
+			fmt.Fprintln(s.out, act)
+		}
+	}
+
+	// Record what both sides hold now, even after a failure, so the next
+	// pass picks up where this one stopped.
+	if err := s.saveState(acts[len(applied):]); err != nil {
+		return errors.Join(applyErr, err)
+	}
+	if applyErr != nil {
+		return applyErr
+	}
+	if s.json {
+		return s.printJSON(applied)
+	}
+	return nil
+}
+
+func (s *syncer) statePath() string {
+	return filepath.Join(s.localRoot, stateFile)
+}
+
+// loadState returns the last synced state, or an empty one if the
+// directory was last synced with somewhere else.
+func (s *syncer) loadState() (syncState, error) {
+	fresh := syncState{Server: s.cfg.Server, Folder: s.remotePath, Files: map[string]syncedFile{}}
+	data, err := os.ReadFile(s.statePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return fresh, nil
+	}
+	if err != nil {
+		return fresh, err
+	}
+	var st syncState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fresh, fmt.Errorf("%s: %w", s.statePath(), err)
+	}
+	if st.Server != fresh.Server || st.Folder != fresh.Folder || st.Files == nil {
+		return fresh, nil
+	}
+	return st, nil
+}
+
+// saveState rescans both sides and records what they agree on, leaving
+// the paths of unapplied actions as they were in the base.
+func (s *syncer) saveState(unapplied []action) error {
+	local, err := s.scanLocal()
+	if err != nil {
+		return err
+	}
+	remote, err := s.scanRemote()
+	if err != nil {
+		return err
+	}
+
+	st := agreed(local, remote, s.localInfo, s.base, unapplied)
+	st.Server, st.Folder = s.cfg.Server, s.remotePath
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.statePath() + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.statePath())
+}
+
+// agreed returns the files and folders local and remote agree on. A file
+// agrees if both sides have the same version of it, or per
+// unchecksummedMatch if the server hasn't checksummed it yet. The paths of
+// unapplied actions keep their entries in base instead, so the next plan
+// sees the same change again rather than taking the two sides to match.
+func agreed(local, remote snapshot, localInfo map[string]syncedFile, base syncState, unapplied []action) syncState {
+	pending := map[string]bool{}
+	for _, act := range unapplied {
+		pending[act.Path] = true
+		if act.From != "" {
+			pending[act.From] = true
+		}
+	}
+
+	st := syncState{Files: map[string]syncedFile{}}
+	for p, hash := range local.files {
+		version, ok := remote.files[p]
+		if pending[p] || !ok || (version != hash && !unchecksummedMatch(local, remote, p)) {
+			continue
+		}
+		f := localInfo[p]
+		f.Remote, f.UUID = version, remote.ids[p]
+		st.Files[p] = f
+	}
+	for d := range local.dirs {
+		if remote.dirs[d] && !pending[d] {
+			st.Dirs = append(st.Dirs, d)
+		}
+	}
+	for p := range pending {
+		if f, ok := base.Files[p]; ok {
+			st.Files[p] = f
+		}
+		if slices.Contains(base.Dirs, p) {
+			st.Dirs = append(st.Dirs, p)
+		}
+	}
+	sort.Strings(st.Dirs)
+	return st
+}
+
+// ignored reports whether a local file is sync's own.
+func ignored(rel string) bool {
+	name := path.Base(rel)
+	return rel == stateFile || rel == stateFile+".tmp" || strings.HasPrefix(name, ".avenuectl-")
+}
+
+// scanLocal hashes every regular file under the local root, reusing the
+// hash in the state for files whose size and modification time haven't
+// changed.
+func (s *syncer) scanLocal() (snapshot, error) {
+	snap := newSnapshot()
+	s.localInfo = map[string]syncedFile{}
+	err := filepath.WalkDir(s.localRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == s.localRoot {
+			return nil
+		}
+		rel, err := filepath.Rel(s.localRoot, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			snap.dirs[rel] = true
+			return nil
+		}
+		if ignored(rel) || !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f := syncedFile{Size: info.Size(), ModTime: info.ModTime().UTC()}
+		if prev, ok := s.base.Files[rel]; ok && prev.Size == f.Size && prev.ModTime.Equal(f.ModTime) {
+			f.Hash = prev.Hash
+		} else if f.Hash, err = hashFile(p); err != nil {
+			return err
+		}
+		snap.files[rel] = f.Hash
+		snap.sizes[rel] = f.Size
+		s.localInfo[rel] = f
+		return nil
+	})
+	return snap, err
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scanRemote lists everything under the remote folder, creating it if it
+// doesn't exist yet (unless this is a dry run).
+func (s *syncer) scanRemote() (snapshot, error) {
+	snap := newSnapshot()
+	s.remoteFiles = map[string]entry{}
+	s.folders = map[string]entry{}
+
+	root, err := s.resolveFolder(s.remotePath)
+	if errors.Is(err, errNotFound) {
+		if s.dryRun {
+			s.folders["."] = entry{Path: s.remotePath}
+			return snap, nil
+		}
+		root, err = s.mkdirAll(s.remotePath)
+	}
+	if err != nil {
+		return snap, err
+	}
+
+	err = s.walk(root, func(e entry, depth int) error {
+		rel := strings.TrimPrefix(strings.TrimPrefix(e.Path, root.Path), "/")
+		if depth == 0 {
+			rel = "."
+		}
+		if e.isFolder() {
+			s.folders[rel] = e
+			if depth > 0 {
+				snap.dirs[rel] = true
+			}
+			return nil
+		}
+		if ignored(rel) {
+			return nil
+		}
+		// Files without a checksum predate checksums; their UUID stands
+		// in, since the server gives changed content a new file.
+		version := e.Checksum
+		if version == "" {
+			version = uuidVersion + e.UUID
+		}
+		snap.files[rel] = version
+		snap.sizes[rel] = e.FileSize
+		snap.ids[rel] = e.UUID
+		s.remoteFiles[rel] = e
+		return nil
+	})
+	return snap, err
+}
+
+func (s *syncer) localPath(rel string) string {
+	return filepath.Join(s.localRoot, filepath.FromSlash(rel))
+}
+
+// folder is the remote folder holding rel.
+func (s *syncer) folder(rel string) (entry, error) {
+	dir, ok := s.folders[path.Dir(rel)]
+	if !ok {
+		return entry{}, fmt.Errorf("%s: %w", path.Dir(rel), errNotFound)
+	}
+	return dir, nil
+}
+
+func (s *syncer) apply(act action) error {
+	switch act.Op {
+	case opMkdirLocal:
+		return os.MkdirAll(s.localPath(act.Path), 0o755)
+
+	case opMkdirRemote:
+		parent, err := s.folder(act.Path)
+		if err != nil {
+			return err
+		}
+		f, err := s.mkdir(parent, path.Base(act.Path))
+		if err != nil {
+			return err
+		}
+		s.folders[act.Path] = f
+		return nil
+
+	case opMoveLocal:
+		to := s.localPath(act.Path)
+		if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+			return err
+		}
+		return os.Rename(s.localPath(act.From), to)
+
+	case opMoveRemote:
+		dir, err := s.folder(act.Path)
+		if err != nil {
+			return err
+		}
+		return s.move(s.remoteFiles[act.From], dir, path.Base(act.Path))
+
+	case opUpload:
+		// Uploading beside an existing file would get a " (N)" suffix, so
+		// the old copy goes to the trash first.
+		if old, ok := s.remoteFiles[act.Path]; ok {
+			if err := s.client.DeleteFile(s.header, old.UUID); err != nil {
+				return err
+			}
+		}
+		dir, err := s.folder(act.Path)
+		if err != nil {
+			return err
+		}
+		_, err = s.uploadFile(s.localPath(act.Path), dir)
+		return err
+
+	case opDownload:
+		_, err := s.downloadFile(s.remoteFiles[act.Path], s.localPath(act.Path))
+		return err
+
+	case opConflict:
+		// Keep both: the local copy moves aside and is uploaded under its
+		// new name, and the remote copy takes the original name locally.
+		aside := conflictName(act.Path, time.Now())
+		if err := os.Rename(s.localPath(act.Path), s.localPath(aside)); err != nil {
+			return err
+		}
+		if _, err := s.downloadFile(s.remoteFiles[act.Path], s.localPath(act.Path)); err != nil {
+			return err
+		}
+		dir, err := s.folder(act.Path)
+		if err != nil {
+			return err
+		}
+		_, err = s.uploadFile(s.localPath(aside), dir)
+		return err
+
+	case opDeleteLocal:
+		if err := os.Remove(s.localPath(act.Path)); !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+
+	case opDeleteRemote:
+		return s.client.DeleteFile(s.header, s.remoteFiles[act.Path].UUID)
+
+	case opRmdirLocal:
+		// A directory still holding files sync doesn't track, like editor
+		// swap files, is left alone rather than losing them.
+		names, err := os.ReadDir(s.localPath(act.Path))
+		if err != nil || len(names) > 0 {
+			return err
+		}
+		return os.Remove(s.localPath(act.Path))
+
+	case opRmdirRemote:
+		_, err := s.client.DeleteFolder(s.header, s.folders[act.Path].UUID)
+		return err
+	}
+	return fmt.Errorf("unknown action %q", act.Op)
+}
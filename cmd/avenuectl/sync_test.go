@@ -0,0 +1,204 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	synced := func(hash, uuid string) syncedFile {
+		return syncedFile{Hash: hash, Remote: hash, UUID: uuid}
+	}
+	snap := func(files map[string]string, dirs ...string) snapshot {
+		s := newSnapshot()
+		for p, v := range files {
+			s.files[p] = v
+		}
+		for _, d := range dirs {
+			s.dirs[d] = true
+		}
+		return s
+	}
+
+	tests := []struct {
+		name          string
+		local, remote snapshot
+		remoteIDs     map[string]string
+		localSizes    map[string]int64
+		remoteSizes   map[string]int64
+		base          syncState
+		want          []action
+	}{
+		{
+			name:   "in sync",
+			local:  snap(map[string]string{"a.txt": "h1"}),
+			remote: snap(map[string]string{"a.txt": "h1"}),
+			base:   syncState{Files: map[string]syncedFile{"a.txt": synced("h1", "u1")}},
+		},
+		{
+			name:   "first sync copies both ways",
+			local:  snap(map[string]string{"docs/a.txt": "h1", "same.txt": "h3"}, "docs"),
+			remote: snap(map[string]string{"b.txt": "h2", "same.txt": "h3"}),
+			want: []action{
+				{Op: opMkdirRemote, Path: "docs"},
+				{Op: opDownload, Path: "b.txt"},
+				{Op: opUpload, Path: "docs/a.txt"},
+			},
+		},
+		{
+			name:        "first sync matches unchecksummed files by size",
+			local:       snap(map[string]string{"same.txt": "h1", "other.txt": "h2"}),
+			remote:      snap(map[string]string{"same.txt": uuidVersion + "u1", "other.txt": uuidVersion + "u2"}),
+			remoteSizes: map[string]int64{"same.txt": 5, "other.txt": 7},
+			localSizes:  map[string]int64{"same.txt": 5, "other.txt": 6},
+			want:        []action{{Op: opConflict, Path: "other.txt"}},
+		},
+		{
+			name:   "edited locally",
+			local:  snap(map[string]string{"a.txt": "h2"}),
+			remote: snap(map[string]string{"a.txt": "h1"}),
+			base:   syncState{Files: map[string]syncedFile{"a.txt": synced("h1", "u1")}},
+			want:   []action{{Op: opUpload, Path: "a.txt"}},
+		},
+		{
+			name:   "deleted remotely",
+			local:  snap(map[string]string{"a.txt": "h1"}),
+			remote: snap(nil),
+			base:   syncState{Files: map[string]syncedFile{"a.txt": synced("h1", "u1")}},
+			want:   []action{{Op: opDeleteLocal, Path: "a.txt"}},
+		},
+		{
+			name:   "edited on both sides",
+			local:  snap(map[string]string{"a.txt": "h2"}),
+			remote: snap(map[string]string{"a.txt": "h3"}),
+			base:   syncState{Files: map[string]syncedFile{"a.txt": synced("h1", "u1")}},
+			want:   []action{{Op: opConflict, Path: "a.txt"}},
+		},
+		{
+			name:   "same edit on both sides",
+			local:  snap(map[string]string{"a.txt": "h2"}),
+			remote: snap(map[string]string{"a.txt": "h2"}),
+			base:   syncState{Files: map[string]syncedFile{"a.txt": synced("h1", "u1")}},
+		},
+		{
+			name:   "an edit beats a deletion",
+			local:  snap(map[string]string{"a.txt": "h2"}),
+			remote: snap(nil),
+			base:   syncState{Files: map[string]syncedFile{"a.txt": synced("h1", "u1")}},
+			want:   []action{{Op: opUpload, Path: "a.txt"}},
+		},
+		{
+			name:   "moved locally",
+			local:  snap(map[string]string{"new/a.txt": "h1"}, "new"),
+			remote: snap(map[string]string{"a.txt": "h1"}),
+			base:   syncState{Files: map[string]syncedFile{"a.txt": synced("h1", "u1")}},
+			want: []action{
+				{Op: opMkdirRemote, Path: "new"},
+				{Op: opMoveRemote, Path: "new/a.txt", From: "a.txt"},
+			},
+		},
+		{
+			name:      "renamed remotely",
+			local:     snap(map[string]string{"a.txt": "h1"}),
+			remote:    snap(map[string]string{"b.txt": "h1"}),
+			remoteIDs: map[string]string{"b.txt": "u1"},
+			base:      syncState{Files: map[string]syncedFile{"a.txt": synced("h1", "u1")}},
+			want:      []action{{Op: opMoveLocal, Path: "b.txt", From: "a.txt"}},
+		},
+		{
+			name:   "folder deleted locally",
+			local:  snap(nil),
+			remote: snap(map[string]string{"d/e/a.txt": "h1"}, "d", "d/e"),
+			base: syncState{
+				Files: map[string]syncedFile{"d/e/a.txt": synced("h1", "u1")},
+				Dirs:  []string{"d", "d/e"},
+			},
+			want: []action{
+				{Op: opDeleteRemote, Path: "d/e/a.txt"},
+				{Op: opRmdirRemote, Path: "d"},
+			},
+		},
+		{
+			name:   "folder deleted remotely but edited locally",
+			local:  snap(map[string]string{"d/a.txt": "h2", "d/b.txt": "h3"}, "d"),
+			remote: snap(nil),
+			base: syncState{
+				Files: map[string]syncedFile{"d/a.txt": synced("h1", "u1"), "d/b.txt": synced("h3", "u3")},
+				Dirs:  []string{"d"},
+			},
+			want: []action{
+				{Op: opMkdirRemote, Path: "d"},
+				{Op: opUpload, Path: "d/a.txt"},
+				{Op: opDeleteLocal, Path: "d/b.txt"},
+			},
+		},
+		{
+			name:   "empty folders removed deepest first",
+			local:  snap(nil, "d", "d/e"),
+			remote: snap(nil),
+			base:   syncState{Dirs: []string{"d", "d/e"}},
+			want: []action{
+				{Op: opRmdirLocal, Path: "d/e"},
+				{Op: opRmdirLocal, Path: "d"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for p, id := range tt.remoteIDs {
+				tt.remote.ids[p] = id
+			}
+			for p, size := range tt.localSizes {
+				tt.local.sizes[p] = size
+			}
+			for p, size := range tt.remoteSizes {
+				tt.remote.sizes[p] = size
+			}
+			got := plan(tt.local, tt.remote, tt.base)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("plan =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConflictName(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := map[string]string{
+		"notes.txt":          "notes (conflict 2026-01-02 150405).txt",
+		"a/b/archive.tar.gz": "a/b/archive.tar (conflict 2026-01-02 150405).gz",
+		"Makefile":           "Makefile (conflict 2026-01-02 150405)",
+		"dir/.bashrc":        "dir/.bashrc (conflict 2026-01-02 150405)",
+	}
+	for p, want := range tests {
+		if got := conflictName(p, now); got != want {
+			t.Errorf("conflictName(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestAgreed(t *testing.T) {
+	local, remote := newSnapshot(), newSnapshot()
+	for p, v := range map[string]string{"same.txt": "h1", "fresh.txt": "h2", "resized.txt": "h3", "failed.txt": "h4", "edited.txt": "h5"} {
+		local.files[p], local.sizes[p] = v, 10
+	}
+	for p, v := range map[string]string{"same.txt": "h1", "fresh.txt": "uuid:u2", "resized.txt": "uuid:u3", "failed.txt": "uuid:u4", "edited.txt": "h0"} {
+		remote.files[p], remote.sizes[p] = v, 10
+	}
+	remote.sizes["resized.txt"] = 20
+	base := syncState{Files: map[string]syncedFile{"failed.txt": {Hash: "h0", Remote: "h0"}}}
+	unapplied := []action{{Op: opUpload, Path: "failed.txt"}}
+
+	got := agreed(local, remote, nil, base, unapplied)
+	want := map[string]string{"same.txt": "h1", "fresh.txt": "uuid:u2", "failed.txt": "h0"}
+	if len(got.Files) != len(want) {
+		t.Errorf("agreed on %v, want %v", got.Files, want)
+	}
+	for p, remote := range want {
+		if f, ok := got.Files[p]; !ok || f.Remote != remote {
+			t.Errorf("%s: recorded %+v (present %v), want remote version %s", p, f, ok, remote)
+		}
+	}
+}
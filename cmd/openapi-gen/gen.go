@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"avenue/backend/openapi"
+)
+
+const header = "Code generated by cmd/openapi-gen from openapi/openapi.yaml. DO NOT EDIT."
+
+// initialisms are the words Go spells in capitals.
+var initialisms = map[string]string{
+	"id":   "ID",
+	"ids":  "IDs",
+	"uuid": "UUID",
+	"url":  "URL",
+	"html": "HTML",
+	"ip":   "IP",
+}
+
+// goName turns a JSON property name into a Go field name: "file_size",
+// "fileSize" and "File-Size" all become FileSize.
+func goName(json string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range json {
+		switch {
+		case r == '_' || r == '-':
+			flush()
+		case unicode.IsUpper(r):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		if in, ok := initialisms[strings.ToLower(w)]; ok {
+			b.WriteString(in)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+// goType returns the Go type for s.
+func goType(s *openapi.Schema) (string, error) {
+	if s.GoType != "" {
+		return s.GoType, nil
+	}
+	if s.Ref != "" {
+		return pointer(s, openapi.RefName(s)), nil
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			return pointer(s, "time.Time"), nil
+		}
+		return pointer(s, "string"), nil
+	case "integer":
+		if s.Format == "int64" {
+			return pointer(s, "int64"), nil
+		}
+		return pointer(s, "int"), nil
+	case "number":
+		return pointer(s, "float64"), nil
+	case "boolean":
+		return pointer(s, "bool"), nil
+	case "array":
+		elem, err := goType(s.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case "object":
+		if len(s.Properties) == 0 && s.AllowsAdditional() {
+			return "map[string]any", nil
+		}
+		return "", fmt.Errorf("nested object schemas aren't supported; give it a name under components")
+	case "":
+		return "any", nil
+	}
+	return "", fmt.Errorf("unsupported type %q", s.Type)
+}
+
+// pointer makes a nullable scalar a pointer.
+func pointer(s *openapi.Schema, t string) string {
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+// comment writes text as // comment lines indented by indent.
+func comment(b *bytes.Buffer, indent, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		b.WriteString(strings.TrimRight(indent+"// "+line, " ") + "\n")
+	}
+}
+
+// goTypes generates a Go struct for every schema in the document.
+func goTypes(doc *openapi.Document, pkg string) ([]byte, error) {
+	var b bytes.Buffer
+	for _, named := range doc.Components.Schemas {
+		s := named.Value
+		if s.Type != "object" {
+			return nil, fmt.Errorf("schema %s: only object schemas are supported", named.Name)
+		}
+		b.WriteString("\n")
+		comment(&b, "", s.Description)
+		fmt.Fprintf(&b, "type %s struct {\n", named.Name)
+		for _, p := range s.Properties {
+			name := p.Value.GoName
+			if name == "" {
+				name = goName(p.Name)
+			}
+			t, err := goType(p.Value)
+			if err != nil {
+				return nil, fmt.Errorf("schema %s, property %s: %w", named.Name, p.Name, err)
+			}
+			tag := p.Name
+			if !s.IsRequired(p.Name) {
+				tag += ",omitempty"
+			}
+			tags := []string{"json:" + strconv.Quote(tag)}
+			if p.Value.GoBinding != "" {
+				tags = append(tags, "binding:"+strconv.Quote(p.Value.GoBinding))
+			}
+			if p.Value.GoForm != "" {
+				tags = append(tags, "form:"+strconv.Quote(p.Value.GoForm))
+			}
+			comment(&b, "\t", p.Value.Description)
+			fmt.Fprintf(&b, "\t%s %s `%s`\n", name, t, strings.Join(tags, " "))
+		}
+		for _, f := range s.GoServerFields {
+			fmt.Fprintf(&b, "\t%s %s `json:\"-\"`", f.Name, f.Type)
+			if f.Description != "" {
+				fmt.Fprintf(&b, " // %s", strings.TrimSpace(f.Description))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n")
+	}
+
+	var imports []string
+	if bytes.Contains(b.Bytes(), []byte("json.RawMessage")) {
+		imports = append(imports, `"encoding/json"`)
+	}
+	if bytes.Contains(b.Bytes(), []byte("time.Time")) {
+		imports = append(imports, `"time"`)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// %s\n\npackage %s\n", header, pkg)
+	if len(imports) > 0 {
+		fmt.Fprintf(&out, "\nimport (\n\t%s\n)\n", strings.Join(imports, "\n\t"))
+	}
+	out.Write(b.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// tsIdent matches property names that needn't be quoted in TypeScript.
+var tsIdent = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsType returns the TypeScript type for s.
+func tsType(s *openapi.Schema) (string, error) {
+	var t string
+	switch {
+	case s.Ref != "":
+		t = openapi.RefName(s)
+	case len(s.Enum) > 0:
+		quoted := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			quoted[i] = "'" + e + "'"
+		}
+		t = strings.Join(quoted, " | ")
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer" || s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	case s.Type == "array":
+		elem, err := tsType(s.Items)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		t = elem + "[]"
+	case s.Type == "object" && len(s.Properties) == 0:
+		t = "Record<string, unknown>"
+	case s.Type == "":
+		t = "unknown"
+	default:
+		return "", fmt.Errorf("unsupported type %q", s.Type)
+	}
+	if s.Nullable {
+		t += " | null"
+	}
+	return t, nil
+}
+
+// tsTypes generates a TypeScript interface for every schema in the
+// document.
+func tsTypes(doc *openapi.Document) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// %s\n", header)
+	for _, named := range doc.Components.Schemas {
+		s := named.Value
+		b.WriteString("\n")
+		comment(&b, "", s.Description)
+		fmt.Fprintf(&b, "export interface %s {\n", named.Name)
+		for _, p := range s.Properties {
+			t, err := tsType(p.Value)
+			if err != nil {
+				return nil, fmt.Errorf("schema %s, property %s: %w", named.Name, p.Name, err)
+			}
+			name := p.Name
+			if !tsIdent.MatchString(name) {
+				name = "'" + name + "'"
+			}
+			if !s.IsRequired(p.Name) {
+				name += "?"
+			}
+			comment(&b, "  ", p.Value.Description)
+			fmt.Fprintf(&b, "  %s: %s;\n", name, t)
+		}
+		b.WriteString("}\n")
+	}
+	return b.Bytes(), nil
+}
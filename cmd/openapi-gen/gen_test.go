@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"avenue/backend/openapi"
+)
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{"file_size", "FileSize"},
+		{"fileSize", "FileSize"},
+		{"File-Size", "FileSize"},
+		{"uuid", "UUID"},
+		{"folderId", "FolderID"},
+		{"file_ids", "FileIDs"},
+		{"download_url", "DownloadURL"},
+		{"User-Id", "UserID"},
+	}
+	for _, tt := range tests {
+		if got := goName(tt.json); got != tt.want {
+			t.Errorf("goName(%q) = %q, want %q", tt.json, got, tt.want)
+		}
+	}
+}
+
+// TestGeneratedUpToDate fails when openapi.yaml has changed without
+// go generate ./sdk being rerun.
+func TestGeneratedUpToDate(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		gen  func() ([]byte, error)
+	}{
+		{"../../sdk/types_gen.go", func() ([]byte, error) { return goTypes(doc, "sdk") }},
+		{"../../src/types/api.ts", func() ([]byte, error) { return tsTypes(doc) }},
+	}
+	for _, tt := range tests {
+		want, err := tt.gen()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		got, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date; run go generate ./sdk", tt.path)
+		}
+	}
+}
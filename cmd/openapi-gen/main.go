@@ -0,0 +1,65 @@
+// Command openapi-gen generates the SDK's Go types and the frontend's
+// TypeScript types from the schemas in openapi/openapi.yaml, so neither
+// has to be kept in step with the server by hand. It's run by go generate
+// in package sdk:
+//
+//	go generate ./sdk
+//
+// With -check it writes nothing and fails if either file is out of date.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"avenue/backend/openapi"
+)
+
+func main() {
+	goOut := flag.String("go", "", "write the Go types to this file")
+	goPkg := flag.String("package", "sdk", "package name for the Go types")
+	tsOut := flag.String("ts", "", "write the TypeScript types to this file")
+	check := flag.Bool("check", false, "fail if the files aren't up to date instead of writing them")
+	flag.Parse()
+
+	doc, err := openapi.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	outputs := map[string]func() ([]byte, error){}
+	if *goOut != "" {
+		outputs[*goOut] = func() ([]byte, error) { return goTypes(doc, *goPkg) }
+	}
+	if *tsOut != "" {
+		outputs[*tsOut] = func() ([]byte, error) { return tsTypes(doc) }
+	}
+	if len(outputs) == 0 {
+		log.Fatal("nothing to do: pass -go and/or -ts")
+	}
+
+	stale := false
+	for path, gen := range outputs {
+		b, err := gen()
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		if *check {
+			old, err := os.ReadFile(path)
+			if err != nil || !bytes.Equal(old, b) {
+				fmt.Fprintf(os.Stderr, "%s is out of date; run go generate ./sdk\n", path)
+				stale = true
+			}
+			continue
+		}
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if stale {
+		os.Exit(1)
+	}
+}
@@ -7,8 +7,10 @@
 // new sharded path is left alone (aside from backfilling its checksum if
 // missing), and a file with a checksum already set is skipped entirely.
 //
-// Stop the server before running this against a live UPLOAD_DIR — it moves
-// blobs on disk that the running app assumes are still at their old path.
+// It reads the server's config (see package config) for the database and
+// upload directory. Stop the server before running this against a live
+// upload directory — it moves blobs on disk that the running app assumes
+// are still at their old path.
 //
 // Usage:
 //
@@ -25,6 +27,7 @@ import (
 	"log"
 	"path"
 
+	"avenue/backend/config"
 	"avenue/backend/db"
 	"avenue/backend/shared"
 
@@ -35,11 +38,12 @@ func main() {
 	dryRun := flag.Bool("dry-run", false, "log what would change without touching disk or the database")
 	flag.Parse()
 
-	if err := db.Connect(); err != nil {
+	cfg := config.MustLoad()
+	if err := db.Connect(cfg.Database); err != nil {
 		log.Fatalf("db connect: %v", err)
 	}
 
-	fs := afero.NewBasePathFs(afero.NewOsFs(), shared.GetEnv("UPLOAD_DIR", "./avenuectl/temp/"))
+	fs := afero.NewBasePathFs(afero.NewOsFs(), cfg.Storage.UploadDir)
 
 	refs, err := db.ListAllFileBlobRefs()
 	if err != nil {
@@ -0,0 +1,390 @@
+// Package config loads Avenue's settings once at startup. Each setting
+// starts at its built-in default, is overridden by the YAML or TOML file
+// named by AVENUE_CONFIG if there is one, and then by its env var, so a
+// deployment configured purely through the environment keeps working.
+// Load validates the result and reports every problem it finds at once.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnvKey names the env var holding the path of the config file. The
+// format follows its extension: .yaml, .yml or .toml.
+const FileEnvKey = "AVENUE_CONFIG"
+
+// Config is every setting the server reads. Fields are tagged with their
+// key in the config file (yaml), their env var (env), their default
+// (default) and, for credentials, secret so they're redacted when shown.
+type Config struct {
+	LogLevel   string     `yaml:"log_level" env:"LOG_LEVEL" default:"debug"`
+	Server     Server     `yaml:"server"`
+	Database   Database   `yaml:"database"`
+	RootUser   RootUser   `yaml:"root_user"`
+	Storage    Storage    `yaml:"storage"`
+	Quotas     Quotas     `yaml:"quotas"`
+	Auth       Auth       `yaml:"auth"`
+	Email      Email      `yaml:"email"`
+	Jobs       Jobs       `yaml:"jobs"`
+	Sweepers   Sweepers   `yaml:"sweepers"`
+	RateLimits RateLimits `yaml:"rate_limits"`
+	Bandwidth  Bandwidth  `yaml:"bandwidth"`
+
+	// File is the config file that was loaded, if any.
+	File string `yaml:"-"`
+}
+
+// Server holds the HTTP server's settings.
+type Server struct {
+	AppEnv               string   `yaml:"app_env" env:"APP_ENV" default:"production"`
+	AllowedOrigins       []string `yaml:"allowed_origins" env:"ALLOWED_ORIGINS"`
+	CookieDomain         string   `yaml:"cookie_domain" env:"COOKIE_DOMAIN"`
+	RegistrationEnabled  bool     `yaml:"registration_enabled" env:"REGISTRATION_ENABLED" default:"false"`
+	PasswordLoginEnabled bool     `yaml:"password_login_enabled" env:"PASSWORD_LOGIN_ENABLED" default:"true"`
+	FileSharing          bool     `yaml:"file_sharing" env:"ENABLE_FILE_SHARING" default:"false"`
+	FolderSharing        bool     `yaml:"folder_sharing" env:"ENABLE_FOLDER_SHARING" default:"false"`
+
+	// Listen is a TCP address like ":8080", or "unix:" followed by the
+	// path of a Unix socket.
+	Listen string `yaml:"listen" env:"LISTEN_ADDR" default:":8080"`
+	// TLSCert and TLSKey, when set, serve HTTPS directly. The files are
+	// checked for changes every TLSReloadInterval, so renewed certificates
+	// are picked up without a restart.
+	TLSCert           string        `yaml:"tls_cert" env:"TLS_CERT_FILE"`
+	TLSKey            string        `yaml:"tls_key" env:"TLS_KEY_FILE"`
+	TLSReloadInterval time.Duration `yaml:"tls_reload_interval" env:"TLS_RELOAD_INTERVAL" default:"1m"`
+	// HTTPRedirectListen, when set, is a TCP address that redirects plain
+	// HTTP requests to HTTPS.
+	HTTPRedirectListen string `yaml:"http_redirect_listen" env:"HTTP_REDIRECT_ADDR"`
+	// TrustedProxies lists the IPs and CIDRs whose ClientIPHeaders are
+	// believed. Requests from anywhere else are attributed to their
+	// connection's address. When Listen is a Unix socket it defaults to
+	// UnixSocketClientIP; see load.
+	TrustedProxies  []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	ClientIPHeaders []string `yaml:"client_ip_headers" env:"CLIENT_IP_HEADERS" default:"X-Forwarded-For,X-Real-IP"`
+}
+
+// UnixSocketPrefix marks a Listen address as a Unix socket path.
+const UnixSocketPrefix = "unix:"
+
+// UnixSocketClientIP is the address requests over a Unix socket count as
+// coming from.
+const UnixSocketClientIP = "127.0.0.1"
+
+// Production reports whether the server runs in production mode, which
+// turns off request logging and marks cookies Secure.
+func (s Server) Production() bool {
+	return s.AppEnv == "production"
+}
+
+// TLS reports whether the server serves HTTPS itself.
+func (s Server) TLS() bool {
+	return s.TLSCert != ""
+}
+
+// Database holds the Postgres connection settings.
+type Database struct {
+	Host     string `yaml:"host" env:"DB_HOST" default:"localhost"`
+	Port     string `yaml:"port" env:"DB_PORT" default:"5432"`
+	User     string `yaml:"user" env:"DB_USER" default:"user"`
+	Password string `yaml:"password" env:"DB_PASSWORD" default:"secret" secret:"true"`
+	Name     string `yaml:"name" env:"DB_DATABASE" default:"avenue"`
+}
+
+// DSN returns the lib/pq connection string for d.
+func (d Database) DSN() string {
+	return fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		d.Host, d.Port, d.User, d.Password, d.Name,
+	)
+}
+
+// RootUser is the admin account created on startup.
+type RootUser struct {
+	Email    string `yaml:"email" env:"ROOT_USER_EMAIL" default:"root@gmail.com"`
+	Password string `yaml:"password" env:"ROOT_USER_PASSWORD" default:"password" secret:"true"`
+	// Reset updates the root user's password on every startup.
+	Reset bool `yaml:"reset" env:"ROOT_USER_RESET" default:"false"`
+}
+
+// Storage holds where blobs live and how big uploads and extractions may
+// get.
+type Storage struct {
+	UploadDir         string `yaml:"upload_dir" env:"UPLOAD_DIR" default:"./avenuectl/temp/"`
+	MaxFileSize       int64  `yaml:"max_file_size" env:"MAX_FILE_BYTE_SIZE" default:"209715200"`
+	ExtractMaxEntries int    `yaml:"extract_max_entries" env:"EXTRACT_MAX_ENTRIES" default:"10000"`
+	ExtractMaxBytes   int64  `yaml:"extract_max_bytes" env:"EXTRACT_MAX_BYTES" default:"10737418240"`
+	// ImportDir is the directory admins may import trees from with
+	// POST /v1/admin/import. Empty turns the endpoint off; cmd/avenue-import
+	// can import from anywhere regardless.
+	ImportDir string `yaml:"import_dir" env:"IMPORT_DIR" default:""`
+}
+
+// Quotas holds when users are warned about their quota and whether they
+// may go over it for a while.
+type Quotas struct {
+	// WarnAt lists the percentages of their quota at which users are
+	// emailed and notified, once each until their usage drops back under.
+	WarnAt Percentages `yaml:"warn_at" env:"QUOTA_WARN_AT" default:"80,95"`
+	// GracePeriod, when set, lets users keep uploading past their quota,
+	// up to GraceOverage percent over it, for this long after they first
+	// go over. After that uploads are refused until they're back under.
+	GracePeriod  time.Duration `yaml:"grace_period" env:"QUOTA_GRACE_PERIOD" default:"0s"`
+	GraceOverage int           `yaml:"grace_overage" env:"QUOTA_GRACE_OVERAGE" default:"10"`
+}
+
+// Percentages is a list of whole percentages, written as e.g. "80,95".
+type Percentages []int
+
+// UnmarshalText implements encoding.TextUnmarshaler. The percentages are
+// sorted and each must be between 1 and 100.
+func (p *Percentages) UnmarshalText(text []byte) error {
+	out := Percentages{}
+	for _, part := range splitList(string(text)) {
+		n, err := strconv.Atoi(strings.TrimSuffix(part, "%"))
+		if err != nil || n < 1 || n > 100 {
+			return fmt.Errorf("invalid percentage %q, expected a whole number from 1 to 100", part)
+		}
+		out = append(out, n)
+	}
+	slices.Sort(out)
+	*p = slices.Compact(out)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p Percentages) MarshalText() ([]byte, error) {
+	parts := make([]string, len(p))
+	for i, n := range p {
+		parts[i] = strconv.Itoa(n)
+	}
+	return []byte(strings.Join(parts, ",")), nil
+}
+
+// Auth holds the login backends and SSO providers.
+type Auth struct {
+	Backends []string `yaml:"backends" env:"AUTH_BACKENDS" default:"password"`
+	LDAP     LDAP     `yaml:"ldap"`
+	// OIDC lists the SSO providers in the order they're offered. In the
+	// environment it's set with OIDC_PROVIDERS and OIDC_<ID>_* instead;
+	// see loadOIDC.
+	OIDC []OIDCProvider `yaml:"oidc"`
+}
+
+// LDAP holds the LDAP login backend's settings.
+type LDAP struct {
+	URL                string        `yaml:"url" env:"LDAP_URL"`
+	StartTLS           bool          `yaml:"start_tls" env:"LDAP_START_TLS" default:"false"`
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify" env:"LDAP_INSECURE_SKIP_VERIFY" default:"false"`
+	BindDN             string        `yaml:"bind_dn" env:"LDAP_BIND_DN"`
+	BindPassword       string        `yaml:"bind_password" env:"LDAP_BIND_PASSWORD" secret:"true"`
+	BaseDN             string        `yaml:"base_dn" env:"LDAP_BASE_DN"`
+	UserFilter         string        `yaml:"user_filter" env:"LDAP_USER_FILTER" default:"(&(objectClass=person)(|(uid={login})(mail={login})))"`
+	IDAttr             string        `yaml:"attr_id" env:"LDAP_ATTR_ID"`
+	EmailAttr          string        `yaml:"attr_email" env:"LDAP_ATTR_EMAIL" default:"mail"`
+	FirstNameAttr      string        `yaml:"attr_first_name" env:"LDAP_ATTR_FIRST_NAME" default:"givenName"`
+	LastNameAttr       string        `yaml:"attr_last_name" env:"LDAP_ATTR_LAST_NAME" default:"sn"`
+	GroupsAttr         string        `yaml:"attr_groups" env:"LDAP_ATTR_GROUPS" default:"memberOf"`
+	AdminGroup         string        `yaml:"admin_group" env:"LDAP_ADMIN_GROUP"`
+	UserGroup          string        `yaml:"user_group" env:"LDAP_USER_GROUP"`
+	LinkByEmail        bool          `yaml:"link_by_email" env:"LDAP_LINK_BY_EMAIL" default:"false"`
+	SyncInterval       time.Duration `yaml:"sync_interval" env:"LDAP_SYNC_INTERVAL" default:"1h"`
+}
+
+// OIDCProvider is one SSO provider. Its env vars are prefixed with
+// OIDC_<ID>_; see OIDCEnvPrefix.
+type OIDCProvider struct {
+	ID           string   `yaml:"id"`
+	DisplayName  string   `yaml:"display_name" env:"DISPLAY_NAME"`
+	Issuer       string   `yaml:"issuer" env:"ISSUER"`
+	ClientID     string   `yaml:"client_id" env:"CLIENT_ID"`
+	ClientSecret string   `yaml:"client_secret" env:"CLIENT_SECRET" secret:"true"`
+	Scopes       []string `yaml:"scopes" env:"SCOPES"`
+	AdminClaim   string   `yaml:"admin_claim" env:"ADMIN_CLAIM"`
+	AdminValues  []string `yaml:"admin_values" env:"ADMIN_VALUES"`
+	LinkByEmail  bool     `yaml:"link_by_email" env:"LINK_BY_EMAIL" default:"false"`
+}
+
+// Email holds how outbound mail is built and sent.
+type Email struct {
+	// Sender is ses, smtp, file, stdout or none. Empty picks ses if
+	// SES.From is set and none otherwise.
+	Sender             string        `yaml:"sender" env:"EMAIL_SENDER"`
+	FileDir            string        `yaml:"file_dir" env:"EMAIL_FILE_DIR" default:"./emails"`
+	TemplateDir        string        `yaml:"template_dir" env:"EMAIL_TEMPLATE_DIR"`
+	GlobalTo           string        `yaml:"global_to" env:"GLOBAL_EMAIL_TO"`
+	OutboxPollInterval time.Duration `yaml:"outbox_poll_interval" env:"EMAIL_OUTBOX_POLL_INTERVAL" default:"5s"`
+	RetryBackoff       time.Duration `yaml:"retry_backoff" env:"EMAIL_RETRY_BACKOFF" default:"30s"`
+	MaxAttempts        int           `yaml:"max_attempts" env:"EMAIL_MAX_ATTEMPTS" default:"8"`
+	OutboxRetention    time.Duration `yaml:"outbox_retention" env:"EMAIL_OUTBOX_RETENTION" default:"168h"`
+	SES                SES           `yaml:"ses"`
+	SMTP               SMTP          `yaml:"smtp"`
+}
+
+// SES holds the AWS SES sender's settings. Credentials and the region come
+// from the standard AWS env vars.
+type SES struct {
+	From string `yaml:"from" env:"SES_FROM"`
+}
+
+// SMTP holds the SMTP sender's settings.
+type SMTP struct {
+	Host string `yaml:"host" env:"SMTP_HOST"`
+	// Port defaults to 587, or 465 with implicit TLS.
+	Port     string `yaml:"port" env:"SMTP_PORT"`
+	Security string `yaml:"security" env:"SMTP_SECURITY" default:"starttls"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string `yaml:"from" env:"SMTP_FROM"`
+}
+
+// Jobs holds the background job queue's settings.
+type Jobs struct {
+	Workers         int           `yaml:"workers" env:"JOBS_WORKERS" default:"4"`
+	PollInterval    time.Duration `yaml:"poll_interval" env:"JOBS_POLL_INTERVAL" default:"2s"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff" env:"JOBS_RETRY_BACKOFF" default:"30s"`
+	Retention       time.Duration `yaml:"retention" env:"JOBS_RETENTION" default:"72h"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"JOBS_CLEANUP_INTERVAL" default:"1h"`
+	SyncWait        time.Duration `yaml:"sync_wait" env:"JOBS_SYNC_WAIT" default:"5s"`
+}
+
+// Sweepers holds the periodic maintenance jobs' settings.
+type Sweepers struct {
+	TrashRetention       time.Duration `yaml:"trash_retention" env:"TRASH_RETENTION" default:"720h"`
+	TrashSweepInterval   time.Duration `yaml:"trash_sweep_interval" env:"TRASH_SWEEP_INTERVAL" default:"5m"`
+	SessionSweepInterval time.Duration `yaml:"session_sweep_interval" env:"SESSION_SWEEP_INTERVAL" default:"1h"`
+}
+
+// Rate limit stores selectable with rate_limits.store.
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+)
+
+// RateLimits holds where request counts are kept and each limit.
+type RateLimits struct {
+	Store               string    `yaml:"store" env:"RATE_LIMIT_STORE" default:"memory"`
+	LoginIP             RateLimit `yaml:"login_ip" env:"RATE_LIMIT_LOGIN_IP" default:"20/1m"`
+	LoginEmail          RateLimit `yaml:"login_email" env:"RATE_LIMIT_LOGIN_EMAIL" default:"5/15m"`
+	ForgotPasswordIP    RateLimit `yaml:"forgot_password_ip" env:"RATE_LIMIT_FORGOT_PASSWORD_IP" default:"5/15m"`
+	ForgotPasswordEmail RateLimit `yaml:"forgot_password_email" env:"RATE_LIMIT_FORGOT_PASSWORD_EMAIL" default:"3/1h"`
+	ShareIP             RateLimit `yaml:"share_ip" env:"RATE_LIMIT_SHARE_IP" default:"120/1m"`
+	ShareToken          RateLimit `yaml:"share_token" env:"RATE_LIMIT_SHARE_TOKEN" default:"600/1m"`
+	APIUser             RateLimit `yaml:"api_user" env:"RATE_LIMIT_API_USER" default:"off"`
+}
+
+// Bandwidth holds the transfer caps, in bytes per second; 0 is unlimited.
+type Bandwidth struct {
+	UserDownload  int64 `yaml:"user_download" env:"BANDWIDTH_USER_DOWNLOAD" default:"0"`
+	UserUpload    int64 `yaml:"user_upload" env:"BANDWIDTH_USER_UPLOAD" default:"0"`
+	ShareDownload int64 `yaml:"share_download" env:"BANDWIDTH_SHARE_DOWNLOAD" default:"0"`
+	ShareUpload   int64 `yaml:"share_upload" env:"BANDWIDTH_SHARE_UPLOAD" default:"0"`
+}
+
+// RateLimit allows Max requests per sliding Window. A Max of 0 is off.
+type RateLimit struct {
+	Max    int
+	Window time.Duration
+}
+
+// ParseRateLimit parses a limit written as "<max>/<window>", e.g. "20/1m"
+// for 20 requests a minute. "off" or a max of 0 disables the limit.
+func ParseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "off") {
+		return RateLimit{}, nil
+	}
+
+	maxStr, windowStr, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf(`invalid rate limit %q, expected "<max>/<window>" or "off"`, value)
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(maxStr))
+	if err != nil || max < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit max %q", maxStr)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+	if err != nil || window <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit window %q", windowStr)
+	}
+	return RateLimit{Max: max, Window: window}, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseRateLimit.
+func (r *RateLimit) UnmarshalText(text []byte) error {
+	parsed, err := ParseRateLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r RateLimit) MarshalText() ([]byte, error) {
+	if r.Max == 0 {
+		return []byte("off"), nil
+	}
+	return []byte(fmt.Sprintf("%d/%s", r.Max, r.Window)), nil
+}
+
+// Load reads the config from the defaults, the file named by AVENUE_CONFIG
+// and the environment, and validates it.
+func Load() (*Config, error) {
+	return load(os.Getenv(FileEnvKey), os.LookupEnv)
+}
+
+// MustLoad is Load for commands, which can't run without their config.
+func MustLoad() *Config {
+	cfg, err := Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// Defaults returns the config with only its defaults applied, ignoring
+// AVENUE_CONFIG and the environment, e.g. for a server under test. It
+// isn't validated: settings without a default, such as
+// Server.AllowedOrigins, are left for the caller to fill in.
+func Defaults() *Config {
+	cfg := &Config{}
+	l := loader{lookupEnv: func(string) (string, bool) { return "", false }}
+	l.apply(cfg)
+	return cfg
+}
+
+func load(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := &Config{File: path}
+	l := loader{lookupEnv: lookupEnv, file: path}
+
+	if path != "" {
+		doc, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		l.doc = doc
+	}
+
+	l.apply(cfg)
+	if len(l.errs) > 0 {
+		return nil, errors.Join(l.errs...)
+	}
+	// Every request over a Unix socket comes from UnixSocketClientIP, so
+	// unless the proxy in front is trusted to say who the client is, they
+	// would all share one IP for rate limits and the sessions list.
+	if strings.HasPrefix(cfg.Server.Listen, UnixSocketPrefix) && len(cfg.Server.TrustedProxies) == 0 {
+		cfg.Server.TrustedProxies = []string{UnixSocketClientIP}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
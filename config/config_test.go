@@ -0,0 +1,390 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a lookup over vars, with the one setting that has no usable
+// default already filled in.
+func env(vars map[string]string) func(string) (string, bool) {
+	all := map[string]string{"ALLOWED_ORIGINS": "http://localhost:5173"}
+	for k, v := range vars {
+		all[k] = v
+	}
+	return func(key string) (string, bool) {
+		v, ok := all[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaultsAndEnv(t *testing.T) {
+	cfg, err := load("", env(map[string]string{
+		"ALLOWED_ORIGINS":     "http://a.example, https://b.example",
+		"JOBS_WORKERS":        "8",
+		"TRASH_RETENTION":     "48h",
+		"RATE_LIMIT_LOGIN_IP": "off",
+		"DB_HOST":             "", // empty is unset
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.Server.AllowedOrigins; len(got) != 2 || got[1] != "https://b.example" {
+		t.Errorf("allowed origins = %q", got)
+	}
+	if cfg.Jobs.Workers != 8 {
+		t.Errorf("jobs.workers = %d, want 8", cfg.Jobs.Workers)
+	}
+	if cfg.Sweepers.TrashRetention != 48*time.Hour {
+		t.Errorf("sweepers.trash_retention = %s, want 48h", cfg.Sweepers.TrashRetention)
+	}
+	if cfg.RateLimits.LoginIP.Max != 0 {
+		t.Errorf("rate_limits.login_ip = %+v, want off", cfg.RateLimits.LoginIP)
+	}
+	if cfg.RateLimits.LoginEmail != (RateLimit{Max: 5, Window: 15 * time.Minute}) {
+		t.Errorf("rate_limits.login_email = %+v, want the default 5/15m", cfg.RateLimits.LoginEmail)
+	}
+	if cfg.Database.Host != "localhost" {
+		t.Errorf("database.host = %q, want the default", cfg.Database.Host)
+	}
+	if !cfg.Server.PasswordLoginEnabled || !cfg.Server.Production() {
+		t.Errorf("server defaults = %+v", cfg.Server)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "avenue.yaml",
+			content: `
+server:
+  allowed_origins: [https://drive.example.com]
+  registration_enabled: true
+jobs:
+  workers: 2
+  sync_wait: 1s
+rate_limits:
+  share_ip: 10/1m
+quotas:
+  warn_at: [95, 80]
+auth:
+  oidc:
+    - id: google
+      issuer: https://accounts.google.com
+      client_id: abc
+`,
+		},
+		{
+			name: "avenue.toml",
+			content: `
+[server]
+allowed_origins = ["https://drive.example.com"]
+registration_enabled = true
+
+[jobs]
+workers = 2
+sync_wait = "1s"
+
+[rate_limits]
+share_ip = "10/1m"
+
+[quotas]
+warn_at = [95, 80]
+
+[[auth.oidc]]
+id = "google"
+issuer = "https://accounts.google.com"
+client_id = "abc"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, tt.name, tt.content)
+			// The env still wins over the file.
+			cfg, err := load(path, func(key string) (string, bool) {
+				if key == "JOBS_WORKERS" {
+					return "6", true
+				}
+				return "", false
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := cfg.Server.AllowedOrigins; len(got) != 1 || got[0] != "https://drive.example.com" {
+				t.Errorf("allowed origins = %q", got)
+			}
+			if !cfg.Server.RegistrationEnabled {
+				t.Error("registration_enabled not read from the file")
+			}
+			if cfg.Jobs.Workers != 6 {
+				t.Errorf("jobs.workers = %d, want the env's 6", cfg.Jobs.Workers)
+			}
+			if cfg.Jobs.SyncWait != time.Second {
+				t.Errorf("jobs.sync_wait = %s, want 1s", cfg.Jobs.SyncWait)
+			}
+			if cfg.RateLimits.ShareIP != (RateLimit{Max: 10, Window: time.Minute}) {
+				t.Errorf("rate_limits.share_ip = %+v", cfg.RateLimits.ShareIP)
+			}
+			if got := cfg.Quotas.WarnAt; len(got) != 2 || got[0] != 80 || got[1] != 95 {
+				t.Errorf("quotas.warn_at = %v, want [80 95]", got)
+			}
+			if len(cfg.Auth.OIDC) != 1 || cfg.Auth.OIDC[0].ClientID != "abc" || cfg.Auth.OIDC[0].DisplayName != "google" {
+				t.Errorf("auth.oidc = %+v", cfg.Auth.OIDC)
+			}
+			if cfg.File != path {
+				t.Errorf("file = %q, want %q", cfg.File, path)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string // YAML, if any
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "unknown key",
+			file: "jobs:\n  worker: 2\n",
+			want: []string{"unknown setting jobs.worker"},
+		},
+		{
+			name: "bad duration in env",
+			env:  map[string]string{"JOBS_POLL_INTERVAL": "soon"},
+			want: []string{`JOBS_POLL_INTERVAL: invalid duration "soon"`},
+		},
+		{
+			name: "bad value in file",
+			file: "storage:\n  max_file_size: big\n",
+			want: []string{"storage.max_file_size", `invalid integer "big"`},
+		},
+		{
+			name: "no origins",
+			env:  map[string]string{"ALLOWED_ORIGINS": ","},
+			want: []string{"server.allowed_origins (ALLOWED_ORIGINS): must list at least one origin"},
+		},
+		{
+			name: "wildcard origin",
+			env:  map[string]string{"ALLOWED_ORIGINS": "*"},
+			want: []string{`can't be "*"`},
+		},
+		{
+			name: "several problems at once",
+			env: map[string]string{
+				"AUTH_BACKENDS": "ldap",
+				"EMAIL_SENDER":  "smtp",
+				"JOBS_WORKERS":  "0",
+			},
+			want: []string{"auth.ldap.url", "email.smtp.host", "jobs.workers (JOBS_WORKERS): must be positive"},
+		},
+		{
+			name: "tls key without a cert",
+			env:  map[string]string{"TLS_KEY_FILE": "/etc/avenue/key.pem"},
+			want: []string{"server.tls_cert (TLS_CERT_FILE): and server.tls_key (TLS_KEY_FILE) must be set together"},
+		},
+		{
+			name: "redirect without tls",
+			env:  map[string]string{"HTTP_REDIRECT_ADDR": ":80"},
+			want: []string{"server.http_redirect_listen (HTTP_REDIRECT_ADDR): redirects to HTTPS"},
+		},
+		{
+			name: "bad listeners",
+			env:  map[string]string{"LISTEN_ADDR": "8080", "TRUSTED_PROXIES": "10.0.0.0/8, proxy.local"},
+			want: []string{`server.listen (LISTEN_ADDR): "8080" is not an address`, `"proxy.local" is not an IP or CIDR`},
+		},
+		{
+			name: "bad quota warning",
+			env:  map[string]string{"QUOTA_WARN_AT": "80,150"},
+			want: []string{`QUOTA_WARN_AT: invalid percentage "150"`},
+		},
+		{
+			name: "oidc provider missing issuer",
+			env:  map[string]string{"OIDC_PROVIDERS": "corp", "OIDC_CORP_CLIENT_ID": "abc"},
+			want: []string{"auth.oidc.corp: issuer (OIDC_CORP_ISSUER)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, "avenue.yaml", tt.file)
+			}
+			_, err := load(path, env(tt.env))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadOIDCFromEnv(t *testing.T) {
+	cfg, err := load("", env(map[string]string{
+		"OIDC_PROVIDERS":           "google, my-idp",
+		"OIDC_GOOGLE_ISSUER":       "https://accounts.google.com",
+		"OIDC_GOOGLE_CLIENT_ID":    "g",
+		"OIDC_MY_IDP_ISSUER":       "https://idp.example.com",
+		"OIDC_MY_IDP_CLIENT_ID":    "m",
+		"OIDC_MY_IDP_DISPLAY_NAME": "Corporate SSO",
+		"OIDC_MY_IDP_ADMIN_VALUES": "admins, ops",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.Auth.OIDC) != 2 {
+		t.Fatalf("got %d providers, want 2", len(cfg.Auth.OIDC))
+	}
+	p := cfg.Auth.OIDC[1]
+	if p.ID != "my-idp" || p.DisplayName != "Corporate SSO" || p.ClientID != "m" {
+		t.Errorf("provider = %+v", p)
+	}
+	if len(p.AdminValues) != 2 || p.AdminValues[1] != "ops" {
+		t.Errorf("admin values = %q", p.AdminValues)
+	}
+}
+
+func TestUnixSocketTrustsItsProxy(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{name: "tcp", env: map[string]string{}, want: nil},
+		{name: "unix socket", env: map[string]string{"LISTEN_ADDR": "unix:/run/avenue.sock"}, want: []string{"127.0.0.1"}},
+		{
+			name: "unix socket with proxies",
+			env:  map[string]string{"LISTEN_ADDR": "unix:/run/avenue.sock", "TRUSTED_PROXIES": "10.0.0.0/8"},
+			want: []string{"10.0.0.0/8"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := load("", env(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Server.TrustedProxies; strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("trusted proxies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg, err := load("", env(map[string]string{
+		"DB_PASSWORD":               "hunter2",
+		"SMTP_PASSWORD":             "",
+		"OIDC_PROVIDERS":            "google",
+		"OIDC_GOOGLE_ISSUER":        "https://accounts.google.com",
+		"OIDC_GOOGLE_CLIENT_ID":     "g",
+		"OIDC_GOOGLE_CLIENT_SECRET": "s3cret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := cfg.Redacted()
+	if got := out["database"].(map[string]any)["password"]; got != redacted {
+		t.Errorf("database.password = %v, want %s", got, redacted)
+	}
+	if got := out["email"].(map[string]any)["smtp"].(map[string]any)["password"]; got != "" {
+		t.Errorf("unset email.smtp.password = %v, want empty", got)
+	}
+	if got := out["jobs"].(map[string]any)["poll_interval"]; got != "2s" {
+		t.Errorf("jobs.poll_interval = %v, want 2s", got)
+	}
+	if got := out["quotas"].(map[string]any)["warn_at"]; got != "80,95" {
+		t.Errorf("quotas.warn_at = %v, want 80,95", got)
+	}
+	if got := out["rate_limits"].(map[string]any)["api_user"]; got != "off" {
+		t.Errorf("rate_limits.api_user = %v, want off", got)
+	}
+	providers := out["auth"].(map[string]any)["oidc"].([]map[string]any)
+	if len(providers) != 1 || providers[0]["client_id"] != "g" || providers[0]["client_secret"] != redacted {
+		t.Errorf("auth.oidc = %v", providers)
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "s3cret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("redacted config contains %q", secret)
+		}
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RateLimit
+		wantErr bool
+	}{
+		{value: "20/1m", want: RateLimit{Max: 20, Window: time.Minute}},
+		{value: " 5 / 15m ", want: RateLimit{Max: 5, Window: 15 * time.Minute}},
+		{value: "0/1s", want: RateLimit{Max: 0, Window: time.Second}},
+		{value: "off"},
+		{value: "OFF"},
+		{value: "20", wantErr: true},
+		{value: "x/1m", wantErr: true},
+		{value: "-1/1m", wantErr: true},
+		{value: "20/soon", wantErr: true},
+		{value: "20/0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRateLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCEnvPrefix(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"google", "OIDC_GOOGLE_"},
+		{"my-idp", "OIDC_MY_IDP_"},
+		{"Corp.2", "OIDC_CORP_2_"},
+	}
+	for _, tt := range tests {
+		if got := OIDCEnvPrefix(tt.id); got != tt.want {
+			t.Errorf("OIDCEnvPrefix(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
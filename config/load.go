@@ -0,0 +1,330 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// readFile parses the config file at path into a generic document.
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	doc := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("config: %s: unsupported extension %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// field is one setting: a leaf of Config reached through nested structs.
+type field struct {
+	path   string // key in the config file, e.g. "jobs.poll_interval"
+	env    string
+	def    string
+	hasDef bool
+	secret bool
+	value  reflect.Value
+}
+
+// fields lists the settings in v, a struct, prefixing their file keys with
+// path and their env vars with envPrefix. Slices of structs, like
+// Auth.OIDC, aren't settings themselves and are skipped.
+func fields(v reflect.Value, path, envPrefix string) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("yaml")
+		if key == "" || key == "-" {
+			continue
+		}
+		if path != "" {
+			key = path + "." + key
+		}
+
+		fv := v.Field(i)
+		if isLeaf(sf.Type) {
+			def, hasDef := sf.Tag.Lookup("default")
+			env := sf.Tag.Get("env")
+			if env != "" {
+				env = envPrefix + env
+			}
+			out = append(out, field{
+				path:   key,
+				env:    env,
+				def:    def,
+				hasDef: hasDef,
+				secret: sf.Tag.Get("secret") == "true",
+				value:  fv,
+			})
+			continue
+		}
+		if sf.Type.Kind() == reflect.Struct {
+			out = append(out, fields(fv, key, envPrefix)...)
+		}
+	}
+	return out
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func isLeaf(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) || t == reflect.TypeFor[time.Duration]() {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// set parses raw into v.
+func set(v reflect.Value, raw string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, expected e.g. \"30s\" or \"1h\"", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, expected true or false", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(splitList(raw)))
+	}
+	return nil
+}
+
+// setFromFile sets v from a value decoded from the config file. Lists may
+// be written either as lists or as comma separated strings.
+func setFromFile(v reflect.Value, raw any) error {
+	if items, ok := raw.([]any); ok {
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		list := make([]string, 0, len(items))
+		for _, item := range items {
+			list = append(list, fmt.Sprint(item))
+		}
+		if v.Type().Elem().Kind() != reflect.String {
+			return set(v, strings.Join(list, ","))
+		}
+		v.Set(reflect.ValueOf(list))
+		return nil
+	}
+	if _, ok := raw.(map[string]any); ok {
+		return fmt.Errorf("expected a value, got a table")
+	}
+	return set(v, fmt.Sprint(raw))
+}
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// lookup finds the value at a dotted path in doc.
+func lookup(doc map[string]any, path string) (any, bool) {
+	var cur any = doc
+	for _, key := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// loader applies defaults, the file and the environment to a Config,
+// collecting every error rather than stopping at the first.
+type loader struct {
+	file      string
+	doc       map[string]any
+	lookupEnv func(string) (string, bool)
+	errs      []error
+}
+
+func (l *loader) errorf(format string, args ...any) {
+	l.errs = append(l.errs, fmt.Errorf(format, args...))
+}
+
+func (l *loader) env(key string) (string, bool) {
+	v, ok := l.lookupEnv(key)
+	return v, ok && v != ""
+}
+
+func (l *loader) apply(cfg *Config) {
+	all := fields(reflect.ValueOf(cfg).Elem(), "", "")
+	known := map[string]bool{"auth.oidc": true}
+	for _, f := range all {
+		known[f.path] = true
+		l.applyField(f, l.doc)
+	}
+	l.checkUnknown(l.doc, "", known)
+	l.loadOIDC(cfg)
+}
+
+// applyField sets f from its default, then doc, then its env var.
+func (l *loader) applyField(f field, doc map[string]any) {
+	if f.hasDef {
+		if err := set(f.value, f.def); err != nil {
+			panic(fmt.Sprintf("config: bad default for %s: %v", f.path, err))
+		}
+	}
+	if raw, ok := lookup(doc, f.path); ok {
+		if err := setFromFile(f.value, raw); err != nil {
+			l.errorf("%s: %s: %w", l.file, f.path, err)
+		}
+	}
+	if f.env == "" {
+		return
+	}
+	if raw, ok := l.env(f.env); ok {
+		if err := set(f.value, raw); err != nil {
+			l.errorf("%s: %w", f.env, err)
+		}
+	}
+}
+
+// checkUnknown reports keys in the file that don't match a setting, which
+// are most likely typos.
+func (l *loader) checkUnknown(doc map[string]any, path string, known map[string]bool) {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		full := key
+		if path != "" {
+			full = path + "." + key
+		}
+		if known[full] {
+			continue
+		}
+		if sub, ok := doc[key].(map[string]any); ok && hasPrefix(known, full+".") {
+			l.checkUnknown(sub, full, known)
+			continue
+		}
+		l.errorf("%s: unknown setting %s", l.file, full)
+	}
+}
+
+func hasPrefix(known map[string]bool, prefix string) bool {
+	for k := range known {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadOIDC builds the SSO providers. The file lists them under auth.oidc;
+// OIDC_PROVIDERS, if set, replaces that list with the given IDs, each
+// configured from the file entry with the same ID and OIDC_<ID>_* on top.
+func (l *loader) loadOIDC(cfg *Config) {
+	entries := map[string]map[string]any{}
+	var ids []string
+	if raw, ok := lookup(l.doc, "auth.oidc"); ok {
+		list, ok := raw.([]any)
+		if !ok {
+			l.errorf("%s: auth.oidc: expected a list of providers", l.file)
+		}
+		for i, item := range list {
+			entry, ok := item.(map[string]any)
+			id, _ := entry["id"].(string)
+			if !ok || id == "" {
+				l.errorf("%s: auth.oidc[%d]: expected a provider with an id", l.file, i)
+				continue
+			}
+			entries[id] = entry
+			ids = append(ids, id)
+		}
+	}
+	if raw, ok := l.env("OIDC_PROVIDERS"); ok {
+		ids = splitList(raw)
+	}
+
+	cfg.Auth.OIDC = nil
+	for _, id := range ids {
+		p := OIDCProvider{ID: id}
+		path := "auth.oidc." + id
+		known := map[string]bool{}
+		for _, f := range fields(reflect.ValueOf(&p).Elem(), "", OIDCEnvPrefix(id)) {
+			known[path+"."+f.path] = true
+			l.applyField(f, entries[id])
+		}
+		if entry, ok := entries[id]; ok {
+			l.checkUnknown(entry, path, known)
+		}
+		if p.DisplayName == "" {
+			p.DisplayName = id
+		}
+		cfg.Auth.OIDC = append(cfg.Auth.OIDC, p)
+	}
+}
+
+// OIDCEnvPrefix returns the prefix of provider id's env vars, e.g.
+// "my-idp" -> "OIDC_MY_IDP_" for OIDC_MY_IDP_ISSUER.
+func OIDCEnvPrefix(id string) string {
+	return "OIDC_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, id) + "_"
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// redacted replaces the value of a secret that is set.
+const redacted = "REDACTED"
+
+// Validate checks for settings that parse but can't work, on their own or
+// together, and reports all of them at once.
+func (c *Config) Validate() error {
+	v := validator{envs: map[string]string{}}
+	for _, f := range fields(reflect.ValueOf(c).Elem(), "", "") {
+		v.envs[f.path] = f.env
+	}
+
+	v.check(slices.Contains([]string{"debug", "info", "warn", "error"}, c.LogLevel),
+		"log_level", "must be debug, info, warn or error, got %q", c.LogLevel)
+
+	v.check(len(c.Server.AllowedOrigins) > 0, "server.allowed_origins",
+		"must list at least one origin: the API allows credentialed cross-origin requests, which need each origin named")
+	for _, origin := range c.Server.AllowedOrigins {
+		u, err := url.Parse(origin)
+		switch {
+		case origin == "*":
+			v.fail("server.allowed_origins", `can't be "*" because cookies are sent cross-origin; list each origin`)
+		case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "":
+			v.fail("server.allowed_origins", "%q is not an origin like https://avenue.example.com", origin)
+		}
+	}
+
+	v.validateListeners(c.Server)
+
+	v.check(c.Storage.UploadDir != "", "storage.upload_dir", "must be set")
+	v.check(c.Storage.MaxFileSize > 0, "storage.max_file_size", "must be positive")
+	v.check(c.Storage.ExtractMaxEntries > 0, "storage.extract_max_entries", "must be positive")
+	v.check(c.Storage.ExtractMaxBytes > 0, "storage.extract_max_bytes", "must be positive")
+
+	v.check(c.Quotas.GracePeriod >= 0, "quotas.grace_period", "can't be negative")
+	v.check(c.Quotas.GraceOverage >= 0, "quotas.grace_overage", "can't be negative")
+
+	v.check(len(c.Auth.Backends) > 0, "auth.backends", "must list at least one of password and ldap")
+	for _, backend := range c.Auth.Backends {
+		switch strings.ToLower(backend) {
+		case "password":
+		case "ldap":
+			v.check(c.Auth.LDAP.URL != "", "auth.ldap.url", "is required by the ldap backend")
+			v.check(c.Auth.LDAP.BaseDN != "", "auth.ldap.base_dn", "is required by the ldap backend")
+			v.check(strings.Contains(c.Auth.LDAP.UserFilter, "{login}"), "auth.ldap.user_filter", "must contain {login}")
+			v.positive(c.Auth.LDAP.SyncInterval, "auth.ldap.sync_interval")
+		default:
+			v.fail("auth.backends", "unknown backend %q, expected password or ldap", backend)
+		}
+	}
+	seen := map[string]bool{}
+	for _, p := range c.Auth.OIDC {
+		prefix := OIDCEnvPrefix(p.ID)
+		if seen[prefix] {
+			v.errs = append(v.errs, fmt.Errorf("auth.oidc: provider %q is listed twice", p.ID))
+		}
+		seen[prefix] = true
+		if p.Issuer == "" || p.ClientID == "" {
+			v.errs = append(v.errs, fmt.Errorf("auth.oidc.%s: issuer (%sISSUER) and client_id (%sCLIENT_ID) are required", p.ID, prefix, prefix))
+		}
+	}
+
+	switch c.Email.Sender {
+	case "":
+	case "ses":
+		v.check(c.Email.SES.From != "", "email.ses.from", "is required by the ses sender")
+	case "smtp":
+		v.check(c.Email.SMTP.Host != "", "email.smtp.host", "is required by the smtp sender")
+		_, err := mail.ParseAddress(c.Email.SMTP.From)
+		v.check(err == nil, "email.smtp.from", "must be an address like Avenue <avenue@example.com>")
+		v.check(slices.Contains([]string{"starttls", "tls", "none"}, c.Email.SMTP.Security),
+			"email.smtp.security", "must be starttls, tls or none, got %q", c.Email.SMTP.Security)
+	case "file", "stdout", "none":
+	default:
+		v.fail("email.sender", "must be ses, smtp, file, stdout or none, got %q", c.Email.Sender)
+	}
+	v.positive(c.Email.OutboxPollInterval, "email.outbox_poll_interval")
+	v.positive(c.Email.RetryBackoff, "email.retry_backoff")
+	v.positive(c.Email.OutboxRetention, "email.outbox_retention")
+	v.check(c.Email.MaxAttempts > 0, "email.max_attempts", "must be positive")
+
+	v.check(c.Jobs.Workers > 0, "jobs.workers", "must be positive")
+	v.positive(c.Jobs.PollInterval, "jobs.poll_interval")
+	v.positive(c.Jobs.RetryBackoff, "jobs.retry_backoff")
+	v.positive(c.Jobs.Retention, "jobs.retention")
+	v.positive(c.Jobs.CleanupInterval, "jobs.cleanup_interval")
+	v.check(c.Jobs.SyncWait >= 0, "jobs.sync_wait", "can't be negative")
+
+	v.positive(c.Sweepers.TrashRetention, "sweepers.trash_retention")
+	v.positive(c.Sweepers.TrashSweepInterval, "sweepers.trash_sweep_interval")
+	v.positive(c.Sweepers.SessionSweepInterval, "sweepers.session_sweep_interval")
+
+	v.check(c.RateLimits.Store == RateLimitStoreMemory || c.RateLimits.Store == RateLimitStorePostgres,
+		"rate_limits.store", "must be memory or postgres, got %q", c.RateLimits.Store)
+
+	v.check(c.Bandwidth.UserDownload >= 0, "bandwidth.user_download", "can't be negative")
+	v.check(c.Bandwidth.UserUpload >= 0, "bandwidth.user_upload", "can't be negative")
+	v.check(c.Bandwidth.ShareDownload >= 0, "bandwidth.share_download", "can't be negative")
+	v.check(c.Bandwidth.ShareUpload >= 0, "bandwidth.share_upload", "can't be negative")
+
+	return errors.Join(v.errs...)
+}
+
+func (v *validator) validateListeners(s Server) {
+	socket, isSocket := strings.CutPrefix(s.Listen, UnixSocketPrefix)
+	switch {
+	case isSocket:
+		v.check(socket != "", "server.listen", "needs a socket path after %q", UnixSocketPrefix)
+	default:
+		_, _, err := net.SplitHostPort(s.Listen)
+		v.check(err == nil, "server.listen", `%q is not an address like ":8080" or "unix:/run/avenue.sock"`, s.Listen)
+	}
+
+	v.check((s.TLSCert == "") == (s.TLSKey == ""), "server.tls_cert", "and server.tls_key (TLS_KEY_FILE) must be set together")
+	if s.TLS() {
+		v.positive(s.TLSReloadInterval, "server.tls_reload_interval")
+	}
+	if s.HTTPRedirectListen != "" {
+		_, _, err := net.SplitHostPort(s.HTTPRedirectListen)
+		v.check(err == nil, "server.http_redirect_listen", `%q is not an address like ":80"`, s.HTTPRedirectListen)
+		v.check(s.TLS(), "server.http_redirect_listen", "redirects to HTTPS, so server.tls_cert and server.tls_key must be set")
+		v.check(!isSocket, "server.http_redirect_listen", "can't redirect to a Unix socket; server.listen must be a TCP address")
+	}
+
+	for _, proxy := range s.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		v.check(err == nil || net.ParseIP(proxy) != nil, "server.trusted_proxies", "%q is not an IP or CIDR", proxy)
+	}
+}
+
+type validator struct {
+	envs map[string]string
+	errs []error
+}
+
+// fail records a problem with the setting at path, naming its env var too
+// so it can be found however it was set.
+func (v *validator) fail(path, format string, args ...any) {
+	name := path
+	if env := v.envs[path]; env != "" {
+		name = fmt.Sprintf("%s (%s)", path, env)
+	}
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) check(ok bool, path, format string, args ...any) {
+	if !ok {
+		v.fail(path, format, args...)
+	}
+}
+
+func (v *validator) positive(d time.Duration, path string) {
+	v.check(d > 0, path, "must be a positive duration, got %s", d)
+}
+
+// Redacted returns the effective settings shaped like the config file,
+// with durations and rate limits written the way they're configured and
+// every secret that is set replaced by "REDACTED".
+func (c *Config) Redacted() map[string]any {
+	out := map[string]any{}
+	for _, f := range fields(reflect.ValueOf(c).Elem(), "", "") {
+		put(out, f.path, display(f))
+	}
+
+	providers := make([]map[string]any, 0, len(c.Auth.OIDC))
+	for i := range c.Auth.OIDC {
+		p := map[string]any{}
+		for _, f := range fields(reflect.ValueOf(&c.Auth.OIDC[i]).Elem(), "", "") {
+			put(p, f.path, display(f))
+		}
+		providers = append(providers, p)
+	}
+	put(out, "auth.oidc", providers)
+	return out
+}
+
+func display(f field) any {
+	if f.secret {
+		if f.value.String() == "" {
+			return ""
+		}
+		return redacted
+	}
+	switch v := f.value.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case encoding.TextMarshaler:
+		text, _ := v.MarshalText()
+		return string(text)
+	case []string:
+		if v == nil {
+			return []string{}
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// put sets the value at a dotted path in m, creating tables on the way.
+func put(m map[string]any, path string, value any) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		sub, ok := m[key].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			m[key] = sub
+		}
+		m = sub
+	}
+	m[keys[len(keys)-1]] = value
+}
@@ -0,0 +1,220 @@
+package db
+
+import (
+	"database/sql"
+
+	"avenue/backend/sdk"
+)
+
+// accountFilesFilter matches the files that make up a user's drive: loose
+// files they created at the root, plus everything inside folders they own
+// (including files other users uploaded through a shared folder). Files the
+// user uploaded into somebody else's shared folder belong to that drive and
+// are left alone. $1 is the user ID.
+const accountFilesFilter = `
+	((parent_id IS NULL AND created_by = $1)
+	 OR parent_id IN (SELECT id FROM folders WHERE owner_id = $1))
+`
+
+// DeleteAccount soft-deletes a user and everything tied to it in a single
+// transaction: login is disabled, every share link they created (or that
+// points into their drive) is revoked, their files and folders are moved to
+// the trash, linked SSO identities and pending reset tokens are removed, and
+// an audit entry is recorded. Sessions are not touched here; callers revoke
+// them with DeleteSessionsForUser once this succeeds. Returns sql.ErrNoRows
+// if the user doesn't exist or is already deleted.
+func DeleteAccount(userID, actorID int64, purge bool) (sdk.AccountDeletionSummary, error) {
+	summary := sdk.AccountDeletionSummary{UserID: userID, Mode: sdk.AccountDeletionTrash}
+	if purge {
+		summary.Mode = sdk.AccountDeletionPurge
+	}
+
+	tx, err := DB.Beginx()
+	if err != nil {
+		return summary, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var email string
+	err = tx.QueryRow(`
+		UPDATE users SET deleted_at = now(), can_login = false, updated_at = now()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING email
+	`, userID).Scan(&email)
+	if err != nil {
+		return summary, err
+	}
+
+	res, err := tx.Exec(`
+		DELETE FROM share_links
+		WHERE created_by = $1
+		   OR file_id IN (SELECT id FROM files WHERE `+accountFilesFilter+`)
+	`, userID)
+	if err != nil {
+		return summary, err
+	}
+	if summary.SharesRevoked, err = res.RowsAffected(); err != nil {
+		return summary, err
+	}
+
+	res, err = tx.Exec(`
+		DELETE FROM share_folder_links
+		WHERE created_by = $1
+		   OR folder_id IN (SELECT id FROM folders WHERE owner_id = $1)
+	`, userID)
+	if err != nil {
+		return summary, err
+	}
+	folderShares, err := res.RowsAffected()
+	if err != nil {
+		return summary, err
+	}
+	summary.SharesRevoked += folderShares
+
+	res, err = tx.Exec(`UPDATE files SET deleted_at = now() WHERE deleted_at IS NULL AND `+accountFilesFilter, userID)
+	if err != nil {
+		return summary, err
+	}
+	if summary.Files, err = res.RowsAffected(); err != nil {
+		return summary, err
+	}
+
+	res, err = tx.Exec(`UPDATE folders SET deleted_at = now() WHERE owner_id = $1 AND deleted_at IS NULL`, userID)
+	if err != nil {
+		return summary, err
+	}
+	if summary.Folders, err = res.RowsAffected(); err != nil {
+		return summary, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_identities WHERE user_id = $1`, userID); err != nil {
+		return summary, err
+	}
+	if _, err := tx.Exec(`DELETE FROM password_reset_tokens WHERE user_id = $1`, userID); err != nil {
+		return summary, err
+	}
+
+	err = recordAuditEvent(tx, actorID, AuditAccountDeleted, userID, map[string]any{
+		"email":         email,
+		"mode":          summary.Mode,
+		"files":         summary.Files,
+		"folders":       summary.Folders,
+		"sharesRevoked": summary.SharesRevoked,
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	return summary, tx.Commit()
+}
+
+// PurgeAccountFiles permanently deletes every file and folder in a user's
+// drive, trashed or not, and returns the deleted files so the caller can
+// remove their blobs and reconcile quota usage for whoever uploaded them.
+func PurgeAccountFiles(userID int64) ([]sdk.File, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Query(`DELETE FROM files WHERE `+accountFilesFilter+` RETURNING uuid, created_by, file_size`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var files []sdk.File
+	for rows.Next() {
+		var f sdk.File
+		if err := rows.Scan(&f.UUID, &f.CreatedBy, &f.FileSize); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	if _, err := tx.Exec(`DELETE FROM folders WHERE owner_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// ListAccountFolders returns every live folder in a user's drive with its
+// slash-separated path from the root, parents before children.
+func ListAccountFolders(userID int64) ([]sdk.ExportFolder, error) {
+	rows, err := DB.Query(`
+		WITH RECURSIVE tree AS (
+			SELECT id, uuid, name, name::text AS path, created_at
+			FROM folders
+			WHERE owner_id = $1 AND parent_id IS NULL AND deleted_at IS NULL
+			UNION ALL
+			SELECT f.id, f.uuid, f.name, t.path || '/' || f.name, f.created_at
+			FROM folders f
+			INNER JOIN tree t ON f.parent_id = t.id
+			WHERE f.deleted_at IS NULL
+		)
+		SELECT uuid, name, path, created_at FROM tree ORDER BY path
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []sdk.ExportFolder
+	for rows.Next() {
+		var f sdk.ExportFolder
+		if err := rows.Scan(&f.UUID, &f.Name, &f.Path, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+// ListAccountFiles returns every live file in a user's drive along with the
+// path of its containing folder ("" for the root).
+func ListAccountFiles(userID int64) ([]sdk.ExportFile, error) {
+	rows, err := DB.Query(`
+		WITH RECURSIVE tree AS (
+			SELECT id, name::text AS path
+			FROM folders
+			WHERE owner_id = $1 AND parent_id IS NULL AND deleted_at IS NULL
+			UNION ALL
+			SELECT f.id, t.path || '/' || f.name
+			FROM folders f
+			INNER JOIN tree t ON f.parent_id = t.id
+			WHERE f.deleted_at IS NULL
+		)
+		SELECT files.uuid, files.name, files.mime_type, files.file_size, files.checksum,
+		       files.created_by, files.created_at, COALESCE(tree.path, '')
+		FROM files
+		LEFT JOIN tree ON tree.id = files.parent_id
+		WHERE files.deleted_at IS NULL
+		  AND ((files.parent_id IS NULL AND files.created_by = $1) OR tree.id IS NOT NULL)
+		ORDER BY tree.path NULLS FIRST, files.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []sdk.ExportFile
+	for rows.Next() {
+		var f sdk.ExportFile
+		var checksum sql.NullString
+		if err := rows.Scan(&f.UUID, &f.Name, &f.MimeType, &f.FileSize, &checksum, &f.CreatedBy, &f.CreatedAt, &f.Folder); err != nil {
+			return nil, err
+		}
+		f.Checksum = checksum.String
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
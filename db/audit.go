@@ -0,0 +1,47 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Audit log actions.
+const (
+	AuditAccountDeleted = "account.deleted"
+	AuditAccountExport  = "account.exported"
+)
+
+// execer is satisfied by both *sqlx.DB and *sqlx.Tx, so audit entries can be
+// written inside the transaction they describe.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+var (
+	_ execer = (*sqlx.DB)(nil)
+	_ execer = (*sqlx.Tx)(nil)
+)
+
+// RecordAuditEvent appends an entry to the audit log. actorID is the user
+// who performed the action and targetUserID the account it affected; either
+// may be 0 when not applicable.
+func RecordAuditEvent(actorID int64, action string, targetUserID int64, details map[string]any) error {
+	return recordAuditEvent(DB, actorID, action, targetUserID, details)
+}
+
+func recordAuditEvent(ex execer, actorID int64, action string, targetUserID int64, details map[string]any) error {
+	if details == nil {
+		details = map[string]any{}
+	}
+	payload, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = ex.Exec(`
+		INSERT INTO audit_log (actor_user_id, action, target_user_id, details)
+		VALUES (NULLIF($1, 0), $2, NULLIF($3, 0), $4)
+	`, actorID, action, targetUserID, payload)
+	return err
+}
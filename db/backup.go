@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// BackupTable is a table copied by cmd/avenue-backup. Serial tables have a
+// BIGSERIAL id whose sequence is moved past the restored rows.
+type BackupTable struct {
+	Name   string
+	Serial bool
+}
+
+// BackupTables are the tables holding an instance's data, parents before
+// the tables that reference them. Queues, jobs, rate limits and short-lived
+// tokens aren't included; a restored instance starts those afresh.
+var BackupTables = []BackupTable{
+	{Name: "users", Serial: true},
+	{Name: "groups", Serial: true},
+	{Name: "group_members"},
+	{Name: "user_identities", Serial: true},
+	{Name: "folders", Serial: true},
+	{Name: "files", Serial: true},
+	{Name: "share_links", Serial: true},
+	{Name: "share_folder_links", Serial: true},
+	{Name: "notifications", Serial: true},
+	{Name: "audit_log", Serial: true},
+}
+
+// SessionsTable is backed up only on request, since restoring it keeps
+// everyone signed in.
+var SessionsTable = BackupTable{Name: "sessions", Serial: true}
+
+// Snapshot is a read-only view of the database as it was at one moment,
+// so a backup taken while the server runs is consistent.
+type Snapshot struct {
+	tx *sqlx.Tx
+	// At is when the snapshot was taken, by the database's clock.
+	At time.Time
+}
+
+// BeginSnapshot starts a repeatable read transaction. Close it when done.
+func BeginSnapshot(ctx context.Context) (*Snapshot, error) {
+	tx, err := DB.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{tx: tx}
+	if err := tx.QueryRow(`SELECT now()`).Scan(&s.At); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close ends the snapshot.
+func (s *Snapshot) Close() error {
+	return s.tx.Rollback()
+}
+
+// DumpTable calls fn with every row of table as a JSON object and returns
+// how many there were.
+func (s *Snapshot) DumpTable(table string, fn func(row []byte) error) (int, error) {
+	rows, err := s.tx.Query(fmt.Sprintf(`SELECT row_to_json(t)::text FROM %s t`, pq.QuoteIdentifier(table)))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return n, err
+		}
+		if err := fn(row); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+// FileBlobRefs returns every file, trashed ones included.
+func (s *Snapshot) FileBlobRefs() ([]FileBlobRef, error) {
+	rows, err := s.tx.Query(`SELECT uuid, created_by, file_size, checksum FROM files ORDER BY uuid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []FileBlobRef
+	for rows.Next() {
+		var ref FileBlobRef
+		var checksum sql.NullString
+		if err := rows.Scan(&ref.UUID, &ref.CreatedBy, &ref.FileSize, &checksum); err != nil {
+			return nil, err
+		}
+		ref.Checksum = checksum.String
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// Restorer loads backed up rows in a single transaction.
+type Restorer struct {
+	tx     *sqlx.Tx
+	tables []BackupTable
+}
+
+// BeginRestore starts loading tables. With replace, everything in them is
+// deleted first, along with rows in other tables that reference them;
+// otherwise they must be empty.
+func BeginRestore(tables []BackupTable, replace bool) (*Restorer, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	r := &Restorer{tx: tx, tables: tables}
+
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = pq.QuoteIdentifier(t.Name)
+	}
+	if replace {
+		_, err = tx.Exec(`TRUNCATE ` + strings.Join(names, ", ") + ` RESTART IDENTITY CASCADE`)
+	} else {
+		for i, t := range tables {
+			var used bool
+			if err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM ` + names[i] + `)`).Scan(&used); err == nil && used {
+				err = fmt.Errorf("table %s isn't empty", t.Name)
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Insert adds rows, each a JSON object as written by DumpTable, to table.
+// Keys missing from a row take NULL rather than the column's default.
+func (r *Restorer) Insert(table string, rows []json.RawMessage) error {
+	if !r.has(table) {
+		return fmt.Errorf("table %s isn't being restored", table)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	batch, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	name := pq.QuoteIdentifier(table)
+	_, err = r.tx.Exec(`INSERT INTO `+name+` SELECT * FROM json_populate_recordset(NULL::`+name+`, $1)`, string(batch))
+	return err
+}
+
+func (r *Restorer) has(table string) bool {
+	for _, t := range r.tables {
+		if t.Name == table {
+			return true
+		}
+	}
+	return false
+}
+
+// Commit moves each serial table's sequence past its restored ids, so new
+// rows don't collide with them, and commits.
+func (r *Restorer) Commit() error {
+	for _, t := range r.tables {
+		if !t.Serial {
+			continue
+		}
+		name := pq.QuoteIdentifier(t.Name)
+		_, err := r.tx.Exec(`SELECT setval(pg_get_serial_sequence($1, 'id'), COALESCE((SELECT MAX(id) FROM `+name+`), 0) + 1, false)`, t.Name)
+		if err != nil {
+			_ = r.tx.Rollback()
+			return fmt.Errorf("reset %s id sequence: %w", t.Name, err)
+		}
+	}
+	return r.tx.Commit()
+}
+
+// Rollback abandons the restore.
+func (r *Restorer) Rollback() error {
+	return r.tx.Rollback()
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// CopySourceFolder is a folder inside a subtree being copied.
+type CopySourceFolder struct {
+	ID       int64
+	Name     string
+	ParentID int64
+}
+
+// CopySourceFile is a file inside a subtree being copied. FolderID is the
+// source folder it lives in.
+type CopySourceFile struct {
+	UUID      string
+	Name      string
+	Extension string
+	MimeType  string
+	FileSize  int64
+	Checksum  string
+	FolderID  int64
+}
+
+// ListFolderSubtreeForCopy returns every live folder nested under rootID
+// (excluding rootID itself), parents before children, and every live file
+// in rootID or any of those folders.
+func ListFolderSubtreeForCopy(rootID int64) ([]CopySourceFolder, []CopySourceFile, error) {
+	rows, err := DB.Query(`
+		WITH RECURSIVE subtree AS (
+			SELECT id, name, COALESCE(parent_id, 0) AS parent_id, 0 AS depth
+			FROM folders WHERE id = $1
+			UNION ALL
+			SELECT f.id, f.name, f.parent_id, s.depth + 1
+			FROM folders f
+			INNER JOIN subtree s ON f.parent_id = s.id
+			WHERE f.deleted_at IS NULL
+		)
+		SELECT id, name, parent_id FROM subtree WHERE depth > 0 ORDER BY depth, id
+	`, rootID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	folderIDs := []int64{rootID}
+	var folders []CopySourceFolder
+	for rows.Next() {
+		var f CopySourceFolder
+		if err := rows.Scan(&f.ID, &f.Name, &f.ParentID); err != nil {
+			return nil, nil, err
+		}
+		folders = append(folders, f)
+		folderIDs = append(folderIDs, f.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	files, err := listFilesInFolders(folderIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return folders, files, nil
+}
+
+func listFilesInFolders(folderIDs []int64) ([]CopySourceFile, error) {
+	rows, err := DB.Query(`
+		SELECT uuid, name, extension, mime_type, file_size, checksum, parent_id
+		FROM files
+		WHERE parent_id = ANY($1) AND deleted_at IS NULL
+		ORDER BY id
+	`, pq.Array(folderIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []CopySourceFile
+	for rows.Next() {
+		var f CopySourceFile
+		var checksum sql.NullString
+		if err := rows.Scan(&f.UUID, &f.Name, &f.Extension, &f.MimeType, &f.FileSize, &checksum, &f.FolderID); err != nil {
+			return nil, err
+		}
+		f.Checksum = checksum.String
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// NextAvailableFolderName returns name, or name with a " (N)" suffix if one
+// of ownerID's live folders in parentID (0 for the root) already uses it —
+// the same scheme uploads use for file names.
+func NextAvailableFolderName(parentID, ownerID int64, name string) (string, error) {
+	rows, err := DB.Query(`
+		SELECT name FROM folders
+		WHERE owner_id = $1 AND COALESCE(parent_id, 0) = $2 AND deleted_at IS NULL
+	`, ownerID, parentID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]struct{})
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return "", err
+		}
+		existing[n] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return NextAvailableName(existing, name, ""), nil
+}
@@ -2,8 +2,8 @@ package db
 
 import (
 	"fmt"
-	"os"
 
+	"avenue/backend/config"
 	"avenue/backend/logger"
 
 	"github.com/jmoiron/sqlx"
@@ -12,17 +12,12 @@ import (
 
 var DB *sqlx.DB
 
-func Connect() error {
-	host := getenv("DB_HOST", "localhost")
-	port := getenv("DB_PORT", "5432")
-	user := getenv("DB_USER", "user")
-	password := getenv("DB_PASSWORD", "secret")
-	dbname := getenv("DB_DATABASE", "avenue")
+// dsn is the connection string Connect used, kept for connections that
+// can't come from the pool, such as Listen's.
+var dsn string
 
-	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname,
-	)
+func Connect(cfg config.Database) error {
+	dsn = cfg.DSN()
 
 	var err error
 	DB, err = sqlx.Open("postgres", dsn)
@@ -34,13 +29,6 @@ func Connect() error {
 		return fmt.Errorf("db: ping: %w", err)
 	}
 
-	logger.Infof("connected to postgres at %s:%s/%s", host, port, dbname)
+	logger.Infof("connected to postgres at %s:%s/%s", cfg.Host, cfg.Port, cfg.Name)
 	return nil
 }
-
-func getenv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return fallback
-}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+const outboxColumns = `id, COALESCE(dedup_key, ''), to_address, subject, status, attempts,
+	COALESCE(last_error, ''), next_attempt_at, created_at, sent_at`
+
+func scanOutboxEmail(row interface{ Scan(...any) error }, extra ...any) (sdk.OutboxEmail, error) {
+	var (
+		e      sdk.OutboxEmail
+		sentAt sql.NullTime
+	)
+	dest := append([]any{&e.ID, &e.DedupKey, &e.To, &e.Subject, &e.Status, &e.Attempts,
+		&e.LastError, &e.NextAttemptAt, &e.CreatedAt, &sentAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return e, err
+	}
+	if sentAt.Valid {
+		e.SentAt = &sentAt.Time
+	}
+	return e, nil
+}
+
+// ClaimedEmail is an outbox message claimed for delivery, along with the
+// bodies the admin endpoints never show.
+type ClaimedEmail struct {
+	sdk.OutboxEmail
+	HTML string
+	Text string
+}
+
+// EnqueueEmail adds a message to the outbox. If dedupKey is non-empty and a
+// message with the same key was already enqueued, nothing is inserted and
+// enqueued is false.
+func EnqueueEmail(dedupKey, to, subject, html, text string) (id int64, enqueued bool, err error) {
+	err = DB.QueryRow(`
+		INSERT INTO email_outbox (dedup_key, to_address, subject, html, text)
+		VALUES (NULLIF($1, ''), $2, $3, $4, $5)
+		ON CONFLICT (dedup_key) WHERE dedup_key IS NOT NULL DO NOTHING
+		RETURNING id
+	`, dedupKey, to, subject, html, text).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+// ClaimDueEmails marks up to limit due messages as 'sending' for lease and
+// returns them with their bodies, bumping their attempt count. Messages left
+// in 'sending' by a worker that died are reclaimed once their lease expires.
+// SKIP LOCKED lets several workers (or instances) drain the queue
+// concurrently without claiming the same rows.
+func ClaimDueEmails(limit int, lease time.Duration) ([]ClaimedEmail, error) {
+	rows, err := DB.Query(`
+		UPDATE email_outbox SET
+			status = 'sending',
+			attempts = attempts + 1,
+			locked_until = now() + $2 * INTERVAL '1 second',
+			updated_at = now()
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE (status = 'pending' AND next_attempt_at <= now())
+			   OR (status = 'sending' AND locked_until < now())
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+outboxColumns+`, html, text
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []ClaimedEmail
+	for rows.Next() {
+		var e ClaimedEmail
+		e.OutboxEmail, err = scanOutboxEmail(rows, &e.HTML, &e.Text)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+// MarkEmailSent records a delivered message and drops its bodies, which
+// can hold password reset links.
+func MarkEmailSent(id int64) error {
+	_, err := DB.Exec(`
+		UPDATE email_outbox
+		SET status='sent', html='', text='', sent_at=now(), locked_until=NULL, last_error=NULL, updated_at=now()
+		WHERE id=$1
+	`, id)
+	return err
+}
+
+// MarkEmailFailed records a failed delivery attempt. The message is retried
+// at nextAttempt, or moved to 'dead' if dead is true, which drops its
+// bodies as MarkEmailSent does.
+func MarkEmailFailed(id int64, lastError string, nextAttempt time.Time, dead bool) error {
+	status := sdk.EmailStatusPending
+	if dead {
+		status = sdk.EmailStatusDead
+	}
+	_, err := DB.Exec(`
+		UPDATE email_outbox
+		SET status=$2, last_error=$3, next_attempt_at=$4, locked_until=NULL, updated_at=now(),
+			html = CASE WHEN $5 THEN '' ELSE html END,
+			text = CASE WHEN $5 THEN '' ELSE text END
+		WHERE id=$1
+	`, id, status, lastError, nextAttempt, dead)
+	return err
+}
+
+// ListOutboxEmails returns a page of the outbox, newest first, optionally
+// filtered by status.
+func ListOutboxEmails(status string, limit, offset int) ([]sdk.OutboxEmail, error) {
+	rows, err := DB.Query(`
+		SELECT `+outboxColumns+`
+		FROM email_outbox
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []sdk.OutboxEmail
+	for rows.Next() {
+		e, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+func CountOutboxEmails(status string) (int, error) {
+	var n int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM email_outbox WHERE $1 = '' OR status = $1`, status).Scan(&n)
+	return n, err
+}
+
+// GetOutboxEmail returns a single message.
+func GetOutboxEmail(id int64) (sdk.OutboxEmail, error) {
+	return scanOutboxEmail(DB.QueryRow(`SELECT `+outboxColumns+` FROM email_outbox WHERE id = $1`, id))
+}
+
+// RetryOutboxEmail puts a message waiting for its next attempt back on the
+// queue for immediate delivery with a fresh attempt budget. Returns
+// sql.ErrNoRows if the message doesn't exist or isn't pending; dead
+// messages have had their bodies dropped and can't be sent again.
+func RetryOutboxEmail(id int64) error {
+	res, err := DB.Exec(`
+		UPDATE email_outbox
+		SET status='pending', attempts=0, next_attempt_at=now(), updated_at=now()
+		WHERE id=$1 AND status = 'pending'
+	`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteSentEmailsBefore removes delivered messages older than cutoff and
+// returns how many were removed. Dead messages are kept for inspection.
+func DeleteSentEmailsBefore(cutoff time.Time) (int64, error) {
+	res, err := DB.Exec(`DELETE FROM email_outbox WHERE status='sent' AND sent_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -45,8 +45,15 @@ func nextAvailableFileName(parentID, name, extension string) (string, error) {
 		return "", err
 	}
 
+	return NextAvailableName(existing, name, extension), nil
+}
+
+// NextAvailableName returns name, or name with the lowest free " (N)"
+// suffix if it's already in existing. The suffix goes before ".extension"
+// when name ends with one.
+func NextAvailableName(existing map[string]struct{}, name, extension string) string {
 	if _, ok := existing[name]; !ok {
-		return name, nil
+		return name
 	}
 
 	// name already includes ".extension" (e.g. "file.pdf"), so the " (N)"
@@ -61,7 +68,7 @@ func nextAvailableFileName(parentID, name, extension string) (string, error) {
 	for n := 1; ; n++ {
 		candidate := fmt.Sprintf("%s (%d)%s", base, n, suffix)
 		if _, ok := existing[candidate]; !ok {
-			return candidate, nil
+			return candidate
 		}
 	}
 }
@@ -78,14 +85,14 @@ func CreateFile(file *sdk.File) (string, error) {
 	file.Name = name
 
 	err = DB.QueryRow(`
-		INSERT INTO files (uuid, name, extension, mime_type, file_size, parent_id, created_by, created_at)
+		INSERT INTO files (uuid, name, extension, mime_type, file_size, parent_id, created_by, checksum, created_at)
 		VALUES ($1, $2, $3, $4, $5,
 			CASE WHEN $6 = '' THEN NULL
 			     ELSE (SELECT id FROM folders WHERE uuid = $6)
 			END,
-			$7, now())
+			$7, NULLIF($8, ''), now())
 		RETURNING id
-	`, file.UUID, file.Name, file.Extension, file.MimeType, file.FileSize, file.Parent, file.CreatedBy).Scan(&file.ID)
+	`, file.UUID, file.Name, file.Extension, file.MimeType, file.FileSize, file.Parent, file.CreatedBy, file.Checksum).Scan(&file.ID)
 	if err != nil {
 		return "", err
 	}
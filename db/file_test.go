@@ -0,0 +1,30 @@
+package db
+
+import "testing"
+
+func TestNextAvailableName(t *testing.T) {
+	existing := map[string]struct{}{
+		"report.pdf":     {},
+		"report (1).pdf": {},
+		"notes":          {},
+		"Photos":         {},
+		"Photos (2)":     {},
+	}
+
+	tests := []struct {
+		name      string
+		extension string
+		want      string
+	}{
+		{name: "fresh.pdf", extension: "pdf", want: "fresh.pdf"},
+		{name: "report.pdf", extension: "pdf", want: "report (2).pdf"},
+		{name: "notes", extension: "", want: "notes (1)"},
+		{name: "Photos", extension: "", want: "Photos (1)"},
+	}
+
+	for _, tt := range tests {
+		if got := NextAvailableName(existing, tt.name, tt.extension); got != tt.want {
+			t.Errorf("NextAvailableName(%q, %q) = %q, want %q", tt.name, tt.extension, got, tt.want)
+		}
+	}
+}
@@ -37,6 +37,19 @@ func GetFolder(folderID, userID string) (*sdk.Folder, error) {
 	return &f, nil
 }
 
+// GetTrashedFolder is GetFolder for a folder that's in the trash.
+func GetTrashedFolder(folderID, userID string) (*sdk.Folder, error) {
+	var f sdk.Folder
+	err := DB.QueryRow(
+		`SELECT id, uuid, name, COALESCE(parent_id, 0), owner_id FROM folders WHERE uuid=$1 AND owner_id=$2::BIGINT AND deleted_at IS NOT NULL`,
+		folderID, userID,
+	).Scan(&f.ID, &f.UUID, &f.Name, &f.ParentID, &f.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
 func UpdateFolder(f sdk.Folder) error {
 	_, err := DB.Exec(
 		`UPDATE folders SET name=$2 WHERE uuid=$1 AND owner_id=$3`,
@@ -618,3 +631,11 @@ func ListFolderParents(folderID, ownerID string) ([]sdk.Folder, error) {
 	}
 	return folders, rows.Err()
 }
+
+// DeleteFoldersByID permanently deletes ownerID's folders with the given
+// IDs. Used to roll back folders created by a failed extraction or copy;
+// callers remove the files inside them first.
+func DeleteFoldersByID(ids []int64, ownerID int64) error {
+	_, err := DB.Exec(`DELETE FROM folders WHERE id = ANY($1) AND owner_id = $2`, pq.Array(ids), ownerID)
+	return err
+}
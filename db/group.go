@@ -0,0 +1,168 @@
+package db
+
+import (
+	"database/sql"
+
+	"avenue/backend/sdk"
+)
+
+// groupColumns selects a group along with its live member count and their
+// combined usage. Deleted accounts don't count towards either.
+const groupColumns = `g.id, g.name, g.quota, g.soft_quota, g.created_at,
+	(SELECT COUNT(*) FROM group_members m JOIN users u ON u.id = m.user_id
+	 WHERE m.group_id = g.id AND u.deleted_at IS NULL),
+	(SELECT COALESCE(SUM(u.space_used), 0) FROM group_members m JOIN users u ON u.id = m.user_id
+	 WHERE m.group_id = g.id AND u.deleted_at IS NULL)`
+
+func scanGroup(row interface{ Scan(...any) error }) (sdk.Group, error) {
+	var g sdk.Group
+	err := row.Scan(&g.ID, &g.Name, &g.Quota, &g.SoftQuota, &g.CreatedAt, &g.Members, &g.Used)
+	return g, err
+}
+
+func scanGroups(rows *sql.Rows) ([]sdk.Group, error) {
+	defer rows.Close()
+
+	groups := []sdk.Group{}
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func ListGroups() ([]sdk.Group, error) {
+	rows, err := DB.Query(`SELECT ` + groupColumns + ` FROM groups g ORDER BY g.name`)
+	if err != nil {
+		return nil, err
+	}
+	return scanGroups(rows)
+}
+
+func GetGroup(id int64) (sdk.Group, error) {
+	return scanGroup(DB.QueryRow(`SELECT `+groupColumns+` FROM groups g WHERE g.id = $1`, id))
+}
+
+// ListUserGroups returns the groups userID belongs to.
+func ListUserGroups(userID int64) ([]sdk.Group, error) {
+	rows, err := DB.Query(`
+		SELECT `+groupColumns+` FROM groups g
+		WHERE g.id IN (SELECT group_id FROM group_members WHERE user_id = $1)
+		ORDER BY g.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanGroups(rows)
+}
+
+func CreateGroup(req sdk.GroupRequest) (sdk.Group, error) {
+	var id int64
+	err := DB.QueryRow(
+		`INSERT INTO groups (name, quota, soft_quota) VALUES ($1, $2, $3) RETURNING id`,
+		req.Name, req.Quota, req.SoftQuota,
+	).Scan(&id)
+	if err != nil {
+		return sdk.Group{}, err
+	}
+	return GetGroup(id)
+}
+
+// UpdateGroup replaces group id's settings. Its soft quota warning is
+// reset, so members are warned again if they're still past the new soft
+// quota. Returns sql.ErrNoRows if there's no such group.
+func UpdateGroup(id int64, req sdk.GroupRequest) (sdk.Group, error) {
+	res, err := DB.Exec(
+		`UPDATE groups SET name=$2, quota=$3, soft_quota=$4, soft_warned_at=NULL WHERE id=$1`,
+		id, req.Name, req.Quota, req.SoftQuota,
+	)
+	if err != nil {
+		return sdk.Group{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sdk.Group{}, sql.ErrNoRows
+	}
+	return GetGroup(id)
+}
+
+// DeleteGroup deletes group id and its memberships. Returns sql.ErrNoRows
+// if there's no such group.
+func DeleteGroup(id int64) error {
+	res, err := DB.Exec(`DELETE FROM groups WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListGroupMembers returns the live accounts in group id.
+func ListGroupMembers(id int64) ([]sdk.User, error) {
+	rows, err := DB.Query(`
+		SELECT u.id, u.email, COALESCE(u.first_name,''), COALESCE(u.last_name,''), u.can_login, u.is_admin, u.quota, u.space_used, u.created_at
+		FROM users u
+		INNER JOIN group_members m ON m.user_id = u.id
+		WHERE m.group_id = $1 AND u.deleted_at IS NULL
+		ORDER BY u.id ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []sdk.User{}
+	for rows.Next() {
+		var u sdk.User
+		if err := rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.CanLogin, &u.IsAdmin, &u.Quota, &u.SpaceUsed, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// AddGroupMember adds userID to group id. Adding an existing member does
+// nothing.
+func AddGroupMember(id, userID int64) error {
+	_, err := DB.Exec(
+		`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		id, userID,
+	)
+	return err
+}
+
+// RemoveGroupMember removes userID from group id. Returns sql.ErrNoRows if
+// they weren't a member.
+func RemoveGroupMember(id, userID int64) error {
+	res, err := DB.Exec(`DELETE FROM group_members WHERE group_id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkGroupSoftWarned records that group id's members have been warned
+// about its soft quota, and reports whether they hadn't been already.
+func MarkGroupSoftWarned(id int64) (bool, error) {
+	res, err := DB.Exec(`UPDATE groups SET soft_warned_at=now() WHERE id=$1 AND soft_warned_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ClearGroupSoftWarning forgets that group id's members were warned, once
+// they're back under its soft quota.
+func ClearGroupSoftWarning(id int64) error {
+	_, err := DB.Exec(`UPDATE groups SET soft_warned_at=NULL WHERE id=$1 AND soft_warned_at IS NOT NULL`, id)
+	return err
+}
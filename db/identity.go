@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"avenue/backend/sdk"
+)
+
+// ErrIdentityLinkedElsewhere is returned by LinkIdentity when the external
+// identity is already linked to a different Avenue account.
+var ErrIdentityLinkedElsewhere = errors.New("identity is already linked to another account")
+
+// OIDCLoginState is the server-side half of an in-flight OIDC login,
+// created when the browser is redirected to the IdP and consumed when it
+// returns to the callback. LinkUserID is set when an already logged-in user
+// is linking a new identity rather than logging in.
+type OIDCLoginState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	RedirectURL  string
+	ReturnTo     string
+	LinkUserID   sql.NullInt64
+}
+
+func CreateOIDCLoginState(s OIDCLoginState) error {
+	_, err := DB.Exec(`
+		INSERT INTO oidc_login_states (state, provider, nonce, code_verifier, redirect_url, return_to, link_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, s.State, s.Provider, s.Nonce, s.CodeVerifier, s.RedirectURL, s.ReturnTo, s.LinkUserID)
+	return err
+}
+
+// ConsumeOIDCLoginState deletes and returns the login state for state, so a
+// callback can't be replayed. Returns sql.ErrNoRows if the state is unknown,
+// expired, or belongs to a different provider.
+func ConsumeOIDCLoginState(state, provider string) (OIDCLoginState, error) {
+	var s OIDCLoginState
+	err := DB.QueryRow(`
+		DELETE FROM oidc_login_states
+		WHERE state = $1 AND provider = $2 AND expires_at > now()
+		RETURNING state, provider, nonce, code_verifier, redirect_url, return_to, link_user_id
+	`, state, provider).Scan(&s.State, &s.Provider, &s.Nonce, &s.CodeVerifier, &s.RedirectURL, &s.ReturnTo, &s.LinkUserID)
+	return s, err
+}
+
+// DeleteExpiredOIDCLoginStates removes abandoned login attempts and returns
+// how many rows were removed.
+func DeleteExpiredOIDCLoginStates() (int64, error) {
+	res, err := DB.Exec(`DELETE FROM oidc_login_states WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetUserByIdentity returns the (non-deleted) user linked to the given
+// provider subject.
+func GetUserByIdentity(provider, subject string) (sdk.User, error) {
+	var u sdk.User
+	err := DB.QueryRow(`
+		SELECT u.id, u.email, COALESCE(u.first_name,''), COALESCE(u.last_name,''), u.password, u.can_login, u.is_admin, u.quota, u.space_used, u.created_at
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2 AND u.deleted_at IS NULL
+	`, provider, subject).Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.Password, &u.CanLogin, &u.IsAdmin, &u.Quota, &u.SpaceUsed, &u.CreatedAt)
+	return u, err
+}
+
+// LinkIdentity links an external identity to userID. Linking an identity
+// that's already linked to userID is a no-op; linking one that belongs to
+// someone else returns ErrIdentityLinkedElsewhere.
+func LinkIdentity(userID int64, provider, subject, email string) (sdk.UserIdentity, error) {
+	var ident sdk.UserIdentity
+	err := DB.QueryRow(`
+		WITH ins AS (
+			INSERT INTO user_identities (user_id, provider, subject, email)
+			VALUES ($1, $2, $3, NULLIF($4, ''))
+			ON CONFLICT (provider, subject) DO NOTHING
+			RETURNING id, user_id, provider, subject, COALESCE(email, '') AS email, created_at
+		)
+		SELECT id, user_id, provider, subject, email, created_at FROM ins
+		UNION ALL
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM user_identities WHERE provider = $2 AND subject = $3
+		LIMIT 1
+	`, userID, provider, subject, email).Scan(&ident.ID, &ident.UserID, &ident.Provider, &ident.Subject, &ident.Email, &ident.CreatedAt)
+	if err != nil {
+		return ident, err
+	}
+	if ident.UserID != userID {
+		return sdk.UserIdentity{}, ErrIdentityLinkedElsewhere
+	}
+	return ident, nil
+}
+
+func ListIdentitiesForUser(userID int64) ([]sdk.UserIdentity, error) {
+	rows, err := DB.Query(`
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM user_identities WHERE user_id = $1 ORDER BY created_at ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var idents []sdk.UserIdentity
+	for rows.Next() {
+		var i sdk.UserIdentity
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		idents = append(idents, i)
+	}
+	return idents, rows.Err()
+}
+
+// ListIdentitiesByProvider returns every identity linked from provider, for
+// syncing accounts against an external directory.
+func ListIdentitiesByProvider(provider string) ([]sdk.UserIdentity, error) {
+	rows, err := DB.Query(`
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM user_identities WHERE provider = $1 ORDER BY id ASC
+	`, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var idents []sdk.UserIdentity
+	for rows.Next() {
+		var i sdk.UserIdentity
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		idents = append(idents, i)
+	}
+	return idents, rows.Err()
+}
+
+// DeleteIdentityForUser unlinks a single identity, scoped to userID so a
+// caller can only unlink their own.
+func DeleteIdentityForUser(id, userID int64) error {
+	res, err := DB.Exec(`DELETE FROM user_identities WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetUserAdmin updates just the is_admin flag, e.g. when it's driven by an
+// identity provider claim rather than an admin editing the user.
+func SetUserAdmin(userID int64, isAdmin bool) error {
+	_, err := DB.Exec(`UPDATE users SET is_admin=$2, updated_at=now() WHERE id=$1 AND deleted_at IS NULL`, userID, isAdmin)
+	return err
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+// ImportFolder creates folder f.UUID under f.ParentID (0 for the root),
+// named f.Name or, if that's taken, with a " (N)" suffix, unless a folder
+// with that UUID already exists. Either way f is filled in from the stored
+// row, and created reports whether it's new. Used by package importer,
+// whose UUIDs are derived from the source path so a re-run finds the
+// folders it made before.
+func ImportFolder(f *sdk.Folder, createdAt time.Time) (created bool, err error) {
+	err = DB.QueryRow(
+		`SELECT id, name, COALESCE(parent_id, 0), owner_id FROM folders WHERE uuid = $1`, f.UUID,
+	).Scan(&f.ID, &f.Name, &f.ParentID, &f.OwnerID)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	name, err := NextAvailableFolderName(f.ParentID, f.OwnerID, f.Name)
+	if err != nil {
+		return false, err
+	}
+	f.Name = name
+	err = DB.QueryRow(`
+		INSERT INTO folders (uuid, name, parent_id, owner_id, created_at)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5)
+		RETURNING id
+	`, f.UUID, f.Name, f.ParentID, f.OwnerID, createdAt).Scan(&f.ID)
+	return err == nil, err
+}
+
+// FileExists reports whether a file row with uuid exists, trashed or not.
+func FileExists(uuid string) (bool, error) {
+	var exists bool
+	err := DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM files WHERE uuid = $1)`, uuid).Scan(&exists)
+	return exists, err
+}
+
+// ImportFile is CreateFile for a file whose blob is already in place: the
+// UUID, size, checksum and created_at are stored as given. The name gets a
+// " (N)" suffix if a sibling already uses it, and the size is charged to
+// the creator's usage in the same transaction, so a file FileExists finds
+// has always been charged for.
+func ImportFile(f *sdk.File) error {
+	name, err := nextAvailableFileName(f.Parent, f.Name, f.Extension)
+	if err != nil {
+		return err
+	}
+	f.Name = name
+
+	tx, err := DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRow(`
+		INSERT INTO files (uuid, name, extension, mime_type, file_size, parent_id, created_by, checksum, created_at)
+		VALUES ($1, $2, $3, $4, $5,
+			CASE WHEN $6 = '' THEN NULL
+			     ELSE (SELECT id FROM folders WHERE uuid = $6)
+			END,
+			$7, NULLIF($8, ''), $9)
+		RETURNING id
+	`, f.UUID, f.Name, f.Extension, f.MimeType, f.FileSize, f.Parent, f.CreatedBy, f.Checksum, f.CreatedAt).Scan(&f.ID)
+	if err != nil {
+		return err
+	}
+	change, charged, err := updateUsage(tx, f.CreatedBy, f.FileSize)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if charged {
+		runUsageHooks(change)
+	}
+	return nil
+}
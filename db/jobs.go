@@ -0,0 +1,300 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+const jobColumns = `id, COALESCE(user_id, 0), kind, status, progress_done, progress_total, attempts,
+	max_attempts, cancel_requested, error, payload, result, run_at, created_at, started_at, finished_at`
+
+func scanJob(row interface{ Scan(...any) error }) (sdk.Job, error) {
+	var (
+		j          sdk.Job
+		payload    []byte
+		result     []byte
+		startedAt  sql.NullTime
+		finishedAt sql.NullTime
+	)
+	err := row.Scan(&j.ID, &j.UserID, &j.Kind, &j.Status, &j.ProgressDone, &j.ProgressTotal, &j.Attempts,
+		&j.MaxAttempts, &j.CancelRequested, &j.Error, &payload, &result, &j.RunAt, &j.CreatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return j, err
+	}
+	j.Payload = payload
+	if len(result) > 0 {
+		j.Result = result
+	}
+	if startedAt.Valid {
+		j.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return j, nil
+}
+
+func scanJobs(rows *sql.Rows) ([]sdk.Job, error) {
+	defer rows.Close()
+
+	var jobs []sdk.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// EnqueueJob adds a pending job of kind to the queue. userID is 0 for
+// system jobs. payload is the kind's JSON-encoded payload.
+func EnqueueJob(userID int64, kind string, payload []byte, maxAttempts int) (sdk.Job, error) {
+	return scanJob(DB.QueryRow(`
+		INSERT INTO jobs (user_id, kind, payload, max_attempts)
+		VALUES (NULLIF($1, 0), $2, $3, $4)
+		RETURNING `+jobColumns,
+		userID, kind, payload, maxAttempts,
+	))
+}
+
+// EnqueueScheduledJob enqueues a system job of kind if its schedule is due,
+// and pushes the schedule interval into the future. Only one caller wins
+// each interval, so running several instances doesn't run it several
+// times. Nothing is enqueued while a previous run is still pending or
+// running.
+func EnqueueScheduledJob(kind string, interval time.Duration, maxAttempts int) (id int64, enqueued bool, err error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`INSERT INTO job_schedules (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, kind); err != nil {
+		return 0, false, err
+	}
+
+	var lastJobID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT last_job_id FROM job_schedules
+		WHERE name = $1 AND next_run_at <= now()
+		FOR UPDATE SKIP LOCKED
+	`, kind).Scan(&lastJobID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if lastJobID.Valid {
+		var active bool
+		err := tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM jobs WHERE id = $1 AND status IN ('pending', 'running'))
+		`, lastJobID.Int64).Scan(&active)
+		if err != nil {
+			return 0, false, err
+		}
+		if active {
+			return 0, false, nil
+		}
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO jobs (kind, max_attempts) VALUES ($1, $2) RETURNING id
+	`, kind, maxAttempts).Scan(&id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE job_schedules SET next_run_at = now() + $2 * INTERVAL '1 second', last_job_id = $3
+		WHERE name = $1
+	`, kind, interval.Seconds(), id); err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// ClaimDueJobs marks up to limit due jobs as 'running' for lease and
+// returns them, bumping their attempt count. Jobs left running by a worker
+// that died are reclaimed once their lease expires, or failed (canceled,
+// if that was asked for) when they're out of attempts. SKIP LOCKED lets
+// several workers and instances share the queue without running a job
+// twice.
+func ClaimDueJobs(limit int, lease time.Duration) ([]sdk.Job, error) {
+	if _, err := DB.Exec(`
+		UPDATE jobs SET
+			status = CASE WHEN cancel_requested THEN 'canceled' ELSE 'failed' END,
+			error = CASE WHEN cancel_requested THEN '' ELSE 'worker stopped before the job finished' END,
+			locked_until = NULL, updated_at = now(), finished_at = now()
+		WHERE status = 'running' AND locked_until < now()
+		  AND (attempts >= max_attempts OR cancel_requested)
+	`); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		UPDATE jobs SET
+			status = 'running',
+			attempts = attempts + 1,
+			locked_until = now() + $2 * INTERVAL '1 second',
+			started_at = COALESCE(started_at, now()),
+			updated_at = now()
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= now())
+			   OR (status = 'running' AND locked_until < now())
+			ORDER BY run_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// HeartbeatJob extends a running job's lease and records its progress. It
+// reports whether the job's owner has asked for it to be canceled.
+func HeartbeatJob(id, progressDone, progressTotal int64, lease time.Duration) (cancelRequested bool, err error) {
+	err = DB.QueryRow(`
+		UPDATE jobs SET
+			progress_done = $2, progress_total = $3,
+			locked_until = now() + $4 * INTERVAL '1 second', updated_at = now()
+		WHERE id = $1 AND status = 'running'
+		RETURNING cancel_requested
+	`, id, progressDone, progressTotal, lease.Seconds()).Scan(&cancelRequested)
+	return cancelRequested, err
+}
+
+// CompleteJob marks a job done with its final progress and result (nil for
+// none).
+func CompleteJob(id, progressDone, progressTotal int64, result []byte) error {
+	var resultArg any
+	if len(result) > 0 {
+		resultArg = result
+	}
+	_, err := DB.Exec(`
+		UPDATE jobs SET
+			status = 'done', progress_done = $2, progress_total = $3, result = $4, error = '',
+			locked_until = NULL, updated_at = now(), finished_at = now()
+		WHERE id = $1
+	`, id, progressDone, progressTotal, resultArg)
+	return err
+}
+
+// FailJob records a failed attempt. If retryAt is non-nil the job goes back
+// to 'pending' until then; otherwise it is failed for good.
+func FailJob(id, progressDone, progressTotal int64, jobErr string, retryAt *time.Time) error {
+	if retryAt != nil {
+		_, err := DB.Exec(`
+			UPDATE jobs SET
+				status = 'pending', progress_done = $2, progress_total = $3, error = $4, run_at = $5,
+				locked_until = NULL, updated_at = now()
+			WHERE id = $1
+		`, id, progressDone, progressTotal, jobErr, *retryAt)
+		return err
+	}
+
+	_, err := DB.Exec(`
+		UPDATE jobs SET
+			status = 'failed', progress_done = $2, progress_total = $3, error = $4,
+			locked_until = NULL, updated_at = now(), finished_at = now()
+		WHERE id = $1
+	`, id, progressDone, progressTotal, jobErr)
+	return err
+}
+
+// MarkJobCanceled records that a running job stopped because it was
+// canceled.
+func MarkJobCanceled(id, progressDone, progressTotal int64) error {
+	_, err := DB.Exec(`
+		UPDATE jobs SET
+			status = 'canceled', progress_done = $2, progress_total = $3,
+			locked_until = NULL, updated_at = now(), finished_at = now()
+		WHERE id = $1
+	`, id, progressDone, progressTotal)
+	return err
+}
+
+// RequestJobCancel cancels one of userID's jobs. A pending job is canceled
+// straight away; a running one is flagged, and its worker stops it at the
+// next heartbeat. Returns sql.ErrNoRows if the job doesn't exist, isn't
+// userID's, or has already finished.
+func RequestJobCancel(id, userID int64) (sdk.Job, error) {
+	return scanJob(DB.QueryRow(`
+		UPDATE jobs SET
+			cancel_requested = true,
+			status = CASE WHEN status = 'pending' THEN 'canceled' ELSE status END,
+			finished_at = CASE WHEN status = 'pending' THEN now() ELSE finished_at END,
+			updated_at = now()
+		WHERE id = $1 AND user_id = $2 AND status IN ('pending', 'running')
+		RETURNING `+jobColumns,
+		id, userID,
+	))
+}
+
+// GetJob returns one of userID's jobs.
+func GetJob(id, userID int64) (sdk.Job, error) {
+	return scanJob(DB.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1 AND user_id = $2`, id, userID))
+}
+
+// GetJobByID returns any job, including system jobs. Callers are
+// responsible for checking the caller may see it.
+func GetJobByID(id int64) (sdk.Job, error) {
+	return scanJob(DB.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+}
+
+// ListJobs returns userID's most recent jobs, newest first, optionally
+// filtered by status. A userID of 0 lists every job, system jobs included.
+func ListJobs(userID int64, status string, limit, offset int) ([]sdk.Job, error) {
+	rows, err := DB.Query(`
+		SELECT `+jobColumns+` FROM jobs
+		WHERE ($1::BIGINT = 0 OR user_id = $1) AND ($2::TEXT = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, userID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// CountJobs returns the number of jobs ListJobs would page through.
+func CountJobs(userID int64, status string) (int, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM jobs WHERE ($1::BIGINT = 0 OR user_id = $1) AND ($2::TEXT = '' OR status = $2)
+	`, userID, status).Scan(&count)
+	return count, err
+}
+
+// DeleteFinishedJobsBefore removes jobs that finished before cutoff and
+// returns their IDs, so any files they left behind can be cleaned up too.
+func DeleteFinishedJobsBefore(cutoff time.Time) ([]int64, error) {
+	rows, err := DB.Query(`DELETE FROM jobs WHERE finished_at < $1 RETURNING id`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
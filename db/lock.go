@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+)
+
+// LockKey derives a Postgres advisory lock key from a name, so callers
+// don't have to coordinate numeric keys.
+func LockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("avenue:" + name))
+	return int64(h.Sum64())
+}
+
+// AdvisoryLock is a session-level Postgres advisory lock. It's held on a
+// connection of its own and released if that connection drops, so an
+// instance that dies or loses the database gives it up automatically.
+type AdvisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryAdvisoryLock takes the advisory lock for key without waiting. ok is
+// false if another session holds it.
+func TryAdvisoryLock(ctx context.Context, key int64) (lock *AdvisoryLock, ok bool, err error) {
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil || !ok {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	return &AdvisoryLock{conn: conn, key: key}, true, nil
+}
+
+// AdvisoryLockWait takes the advisory lock for key, waiting for whoever
+// holds it to let go.
+func AdvisoryLockWait(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// Held reports whether the lock's connection is still alive, and with it
+// the lock.
+func (l *AdvisoryLock) Held(ctx context.Context) bool {
+	_, err := l.conn.ExecContext(ctx, `SELECT 1`)
+	return err == nil
+}
+
+// Release unlocks and returns the connection to the pool. If the
+// connection is already broken the lock went with it.
+func (l *AdvisoryLock) Release() error {
+	_, err := l.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, l.key)
+	if closeErr := l.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
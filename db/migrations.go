@@ -1,11 +1,16 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
 	"path/filepath"
-	"sort"
+	"slices"
 	"strings"
+	"time"
 
 	"avenue/backend/logger"
 )
@@ -13,50 +18,287 @@ import (
 //go:embed migrations/*.sql
 var migrationFS embed.FS
 
+// Each migration is a file migrations/<name>.sql, applied in name order,
+// with a migrations/<name>.down.sql that undoes it. Both run in a
+// transaction along with the row recording them in the migrations table,
+// unless the file has a line reading noTransaction, for statements such
+// as CREATE INDEX CONCURRENTLY that can't run in one.
+const (
+	downSuffix    = ".down.sql"
+	noTransaction = "-- avenue:no-transaction"
+)
+
+// migrationLockName names the advisory lock held while migrating, so
+// instances starting together don't apply the same migration twice.
+const migrationLockName = "migrations"
+
+// ErrSchemaTooNew is returned when the database has migrations applied
+// that this build doesn't know, i.e. it was migrated by a newer version.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// Migration is a migration's state, as listed by MigrationStatus.
+type Migration struct {
+	Name string
+	// AppliedAt is nil for a pending migration.
+	AppliedAt *time.Time
+	// Unknown is set on a migration applied to the database that isn't
+	// in this build.
+	Unknown bool
+}
+
+// RunMigrations applies every pending migration. It fails with
+// ErrSchemaTooNew, without changing anything, if the database was
+// migrated by a newer version.
 func RunMigrations() error {
+	_, err := MigrateUp(0)
+	return err
+}
+
+// MigrateUp applies the first n pending migrations, or all of them if n
+// is 0, and returns their names.
+func MigrateUp(n int) ([]string, error) {
+	return migrate(func(s migrationState) (up, down []string, err error) {
+		return s.pending(n), nil, nil
+	})
+}
+
+// MigrateDown rolls back the last n applied migrations, or all of them if
+// n is 0, and returns their names, newest first.
+func MigrateDown(n int) ([]string, error) {
+	return migrate(func(s migrationState) (up, down []string, err error) {
+		return nil, s.latest(n), nil
+	})
+}
+
+// MigrateTo applies or rolls back migrations until target, a migration's
+// name or number, is the newest applied. Target "0" rolls back all of
+// them. It returns the names of the migrations it ran.
+func MigrateTo(target string) ([]string, error) {
+	return migrate(func(s migrationState) (up, down []string, err error) {
+		i, err := s.target(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		up, down = s.steps(i)
+		return up, down, nil
+	})
+}
+
+// MigrationStatus lists this build's migrations in order, then any the
+// database has that this build doesn't.
+func MigrationStatus() ([]Migration, error) {
+	var out []Migration
+	err := withMigrationLock(func() error {
+		s, err := loadMigrationState()
+		if err != nil {
+			return err
+		}
+		for _, name := range s.known {
+			m := Migration{Name: name}
+			if at, ok := s.applied[name]; ok {
+				m.AppliedAt = &at
+			}
+			out = append(out, m)
+		}
+		for _, name := range s.unknown() {
+			at := s.applied[name]
+			out = append(out, Migration{Name: name, AppliedAt: &at, Unknown: true})
+		}
+		return nil
+	})
+	return out, err
+}
+
+// migrate applies the migrations pick returns as up, in order, then rolls
+// back those it returns as down, in order.
+func migrate(pick func(migrationState) (up, down []string, err error)) ([]string, error) {
+	var ran []string
+	err := withMigrationLock(func() error {
+		s, err := loadMigrationState()
+		if err != nil {
+			return err
+		}
+		if unknown := s.unknown(); len(unknown) > 0 {
+			latest := "none"
+			if len(s.known) > 0 {
+				latest = s.known[len(s.known)-1]
+			}
+			return fmt.Errorf("migrations: %w: %s applied, but this build only goes up to %s; run the newer version or roll them back with it",
+				ErrSchemaTooNew, strings.Join(unknown, ", "), latest)
+		}
+		up, down, err := pick(s)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range up {
+			if err := runMigration(name, name+".sql", true); err != nil {
+				return fmt.Errorf("migrations: apply %s: %w", name, err)
+			}
+			logger.Infof("migration applied: %s", name)
+			ran = append(ran, name)
+		}
+		for _, name := range down {
+			if err := runMigration(name, name+downSuffix, false); err != nil {
+				return fmt.Errorf("migrations: roll back %s: %w", name, err)
+			}
+			logger.Infof("migration rolled back: %s", name)
+			ran = append(ran, name)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+func withMigrationLock(fn func() error) error {
+	lock, err := AdvisoryLockWait(context.Background(), LockKey(migrationLockName))
+	if err != nil {
+		return fmt.Errorf("migrations: lock: %w", err)
+	}
+	defer func() { _ = lock.Release() }()
+
 	if err := ensureMigrationsTable(); err != nil {
 		return fmt.Errorf("migrations: ensure table: %w", err)
 	}
+	return fn()
+}
 
-	entries, err := migrationFS.ReadDir("migrations")
+// migrationState is which migrations this build has and which the
+// database has applied.
+type migrationState struct {
+	known   []string
+	applied map[string]time.Time
+}
+
+func loadMigrationState() (migrationState, error) {
+	known, err := migrationNames()
 	if err != nil {
-		return fmt.Errorf("migrations: read dir: %w", err)
+		return migrationState{}, err
 	}
+	rows, err := DB.Query(`SELECT name, applied_at FROM migrations`)
+	if err != nil {
+		return migrationState{}, fmt.Errorf("migrations: list applied: %w", err)
+	}
+	defer rows.Close()
+	applied := map[string]time.Time{}
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return migrationState{}, err
+		}
+		applied[name] = at
+	}
+	return migrationState{known: known, applied: applied}, rows.Err()
+}
 
-	var names []string
-	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
-			continue
+// unknown returns the applied migrations this build doesn't have, in
+// order.
+func (s migrationState) unknown() []string {
+	var out []string
+	for name := range s.applied {
+		if !slices.Contains(s.known, name) {
+			out = append(out, name)
 		}
-		names = append(names, entry.Name())
 	}
-	sort.Strings(names)
+	slices.Sort(out)
+	return out
+}
 
-	for _, filename := range names {
-		name := strings.TrimSuffix(filename, ".sql")
+// steps returns the migrations to apply, in order, and those to roll
+// back, newest first, to have exactly the first target known migrations
+// applied.
+func (s migrationState) steps(target int) (up, down []string) {
+	for i, name := range s.known {
+		_, applied := s.applied[name]
+		switch {
+		case i < target && !applied:
+			up = append(up, name)
+		case i >= target && applied:
+			down = append(down, name)
+		}
+	}
+	slices.Reverse(down)
+	return up, down
+}
 
-		applied, err := isMigrationApplied(name)
-		if err != nil {
-			return fmt.Errorf("migrations: check %s: %w", name, err)
+// pending returns the first n migrations not applied yet, or all of them
+// if n is 0, in order.
+func (s migrationState) pending(n int) []string {
+	var out []string
+	for _, name := range s.known {
+		if _, ok := s.applied[name]; !ok && (n == 0 || len(out) < n) {
+			out = append(out, name)
 		}
-		if applied {
-			continue
+	}
+	return out
+}
+
+// latest returns the last n applied migrations, or all of them if n is 0,
+// newest first.
+func (s migrationState) latest(n int) []string {
+	var out []string
+	for _, name := range slices.Backward(s.known) {
+		if _, ok := s.applied[name]; ok && (n == 0 || len(out) < n) {
+			out = append(out, name)
 		}
+	}
+	return out
+}
 
-		path := "migrations/" + filename
-		sql, err := migrationFS.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("migrations: read %s: %w", path, err)
+// target is the target that leaves name, a migration's full name or its
+// number, as the newest applied.
+func (s migrationState) target(name string) (int, error) {
+	if strings.Trim(name, "0") == "" && name != "" {
+		return 0, nil
+	}
+	for i, known := range s.known {
+		number, _, _ := strings.Cut(known, "_")
+		if known == name || number == name {
+			return i + 1, nil
 		}
+	}
+	return 0, fmt.Errorf("migrations: no migration %q", name)
+}
+
+// migrationNames lists the embedded migrations in the order they apply.
+func migrationNames() ([]string, error) {
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("migrations: read dir: %w", err)
+	}
 
-		if err := applyMigration(name, string(sql)); err != nil {
-			return fmt.Errorf("migrations: apply %s: %w", name, err)
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".sql" || strings.HasSuffix(name, downSuffix) {
+			continue
 		}
+		names = append(names, strings.TrimSuffix(name, ".sql"))
+	}
+	slices.Sort(names)
+	return names, nil
+}
 
-		logger.Infof("migration applied: %s", name)
+// LatestMigration is the name of the newest migration this build knows,
+// e.g. "0025_create_notifications".
+func LatestMigration() (string, error) {
+	names, err := migrationNames()
+	if err != nil || len(names) == 0 {
+		return "", err
 	}
+	return names[len(names)-1], nil
+}
 
-	return nil
+// AppliedMigration is the name of the newest migration applied to the
+// database, or "" if none are.
+func AppliedMigration() (string, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return "", err
+	}
+	var name sql.NullString
+	err := DB.QueryRow(`SELECT MAX(name) FROM migrations`).Scan(&name)
+	return name.String, err
 }
 
 func ensureMigrationsTable() error {
@@ -67,23 +309,53 @@ func ensureMigrationsTable() error {
 	return err
 }
 
-func isMigrationApplied(name string) (bool, error) {
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM migrations WHERE name = $1", name).Scan(&count)
+// runMigration runs the migration file and records that name is applied,
+// or with up false, that it no longer is.
+func runMigration(name, file string, up bool) error {
+	body, err := migrationFS.ReadFile("migrations/" + file)
+	if errors.Is(err, fs.ErrNotExist) && !up {
+		return errors.New("it has no down migration")
+	}
 	if err != nil {
-		return false, err
+		return fmt.Errorf("read %s: %w", file, err)
 	}
-	return count > 0, nil
-}
 
-func applyMigration(name, body string) error {
-	if _, err := DB.Exec(body); err != nil {
-		return fmt.Errorf("exec: %w", err)
+	record := `INSERT INTO migrations (name) VALUES ($1)`
+	if !up {
+		record = `DELETE FROM migrations WHERE name = $1`
 	}
 
-	if _, err := DB.Exec("INSERT INTO migrations (name) VALUES ($1)", name); err != nil {
+	if !transactional(string(body)) {
+		if _, err := DB.Exec(string(body)); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+		if _, err := DB.Exec(record, name); err != nil {
+			return fmt.Errorf("record: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(string(body)); err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	if _, err := tx.Exec(record, name); err != nil {
 		return fmt.Errorf("record: %w", err)
 	}
+	return tx.Commit()
+}
 
-	return nil
+// transactional reports whether a migration runs in a transaction, i.e.
+// doesn't opt out with a noTransaction line.
+func transactional(body string) bool {
+	for line := range strings.Lines(body) {
+		if strings.TrimSpace(line) == noTransaction {
+			return false
+		}
+	}
+	return true
 }
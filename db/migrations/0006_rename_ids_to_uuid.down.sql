@@ -0,0 +1,66 @@
+-- Undoes 0006 in reverse order: share_links first, since it still points
+-- at files.id, then files, which points at folders.id, then folders.
+
+-- ============================================================
+-- 4. share_links
+--    file_id BIGINT → TEXT holding the file's uuid
+--    created_by BIGINT → TEXT
+-- ============================================================
+
+ALTER TABLE share_links ALTER COLUMN created_by TYPE TEXT USING created_by::TEXT;
+
+ALTER TABLE share_links ADD COLUMN file_id_old TEXT;
+UPDATE share_links sl
+    SET file_id_old = f.uuid
+    FROM files f
+    WHERE f.id = sl.file_id;
+DELETE FROM share_links WHERE file_id_old IS NULL;
+ALTER TABLE share_links DROP COLUMN file_id;
+ALTER TABLE share_links RENAME COLUMN file_id_old TO file_id;
+ALTER TABLE share_links ALTER COLUMN file_id SET NOT NULL;
+
+-- ============================================================
+-- 3. sessions
+-- ============================================================
+
+ALTER TABLE sessions RENAME COLUMN uuid TO session_id;
+
+-- ============================================================
+-- 2. files
+--    uuid → id TEXT PRIMARY KEY, parent_id → parent TEXT
+-- ============================================================
+
+ALTER TABLE files ALTER COLUMN created_by TYPE TEXT USING created_by::TEXT;
+
+ALTER TABLE files ADD COLUMN parent TEXT NOT NULL DEFAULT '';
+UPDATE files fi
+    SET parent = fo.uuid
+    FROM folders fo
+    WHERE fo.id = fi.parent_id;
+ALTER TABLE files DROP COLUMN parent_id;
+
+ALTER TABLE files DROP CONSTRAINT files_uuid_unique;
+ALTER TABLE files DROP CONSTRAINT files_pkey;
+ALTER TABLE files DROP COLUMN id;
+ALTER TABLE files RENAME COLUMN uuid TO id;
+ALTER TABLE files ADD PRIMARY KEY (id);
+
+-- ============================================================
+-- 1. folders
+--    uuid → folder_id TEXT PRIMARY KEY, parent_id → parent TEXT
+-- ============================================================
+
+ALTER TABLE folders ALTER COLUMN owner_id TYPE TEXT USING owner_id::TEXT;
+
+ALTER TABLE folders ADD COLUMN parent TEXT NOT NULL DEFAULT '';
+UPDATE folders child
+    SET parent = parent_row.uuid
+    FROM folders parent_row
+    WHERE parent_row.id = child.parent_id;
+ALTER TABLE folders DROP COLUMN parent_id;
+
+ALTER TABLE folders DROP CONSTRAINT folders_uuid_unique;
+ALTER TABLE folders DROP CONSTRAINT folders_pkey;
+ALTER TABLE folders DROP COLUMN id;
+ALTER TABLE folders RENAME COLUMN uuid TO folder_id;
+ALTER TABLE folders ADD PRIMARY KEY (folder_id);
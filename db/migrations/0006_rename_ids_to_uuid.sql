@@ -1,5 +1,3 @@
-BEGIN;
-
 -- ============================================================
 -- 1. folders
 --    folder_id TEXT PRIMARY KEY → uuid TEXT UNIQUE
@@ -72,5 +70,3 @@ ALTER TABLE share_links RENAME COLUMN file_id_new TO file_id;
 ALTER TABLE share_links ALTER COLUMN file_id SET NOT NULL;
 
 ALTER TABLE share_links ALTER COLUMN created_by TYPE BIGINT USING created_by::BIGINT;
-
-COMMIT;
@@ -0,0 +1,2 @@
+ALTER TABLE share_links DROP COLUMN last_accessed;
+ALTER TABLE share_folder_links DROP COLUMN last_accessed;
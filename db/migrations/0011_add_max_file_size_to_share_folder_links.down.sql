@@ -0,0 +1 @@
+ALTER TABLE share_folder_links DROP COLUMN max_file_size;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_files_deleted_at;
+DROP INDEX IF EXISTS idx_folders_deleted_at;
+
+ALTER TABLE files DROP COLUMN deleted_at;
+ALTER TABLE folders DROP COLUMN deleted_at;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_files_checksum;
+ALTER TABLE files DROP COLUMN checksum;
@@ -0,0 +1 @@
+ALTER TABLE folders DROP COLUMN created_at;
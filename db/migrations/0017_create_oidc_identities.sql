@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider   TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    email      TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+-- Short-lived state for in-flight OIDC logins: the random state/nonce and
+-- PKCE verifier generated when the browser is sent to the IdP, consumed
+-- exactly once when it comes back to the callback.
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state         TEXT PRIMARY KEY,
+    provider      TEXT NOT NULL,
+    nonce         TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    redirect_url  TEXT NOT NULL,
+    return_to     TEXT NOT NULL DEFAULT '/',
+    link_user_id  BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    expires_at    TIMESTAMP NOT NULL DEFAULT (now() + INTERVAL '10 minutes')
+);
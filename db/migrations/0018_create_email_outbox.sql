@@ -0,0 +1,24 @@
+-- Durable queue of outbound email. Handlers enqueue rows here and a worker
+-- delivers them, retrying with backoff until they're sent or give up
+-- ('dead'). A row stuck in 'sending' past locked_until was claimed by a
+-- worker that died and is picked up again.
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    dedup_key       TEXT,
+    to_address      TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    html            TEXT NOT NULL DEFAULT '',
+    text            TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'sent', 'dead')),
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+    locked_until    TIMESTAMP,
+    created_at      TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMP NOT NULL DEFAULT now(),
+    sent_at         TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_email_outbox_dedup_key ON email_outbox (dedup_key) WHERE dedup_key IS NOT NULL;
+CREATE INDEX idx_email_outbox_due ON email_outbox (next_attempt_at) WHERE status IN ('pending', 'sending');
+CREATE INDEX idx_email_outbox_status_created ON email_outbox (status, created_at DESC);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS audit_log;
+
+-- Fails if a deleted account and a live one share an email address; those
+-- have to be resolved by hand before this can be rolled back.
+DROP INDEX IF EXISTS idx_users_email_active;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
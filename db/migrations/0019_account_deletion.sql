@@ -0,0 +1,17 @@
+-- Deleted accounts keep their row (deleted_at is set), so email uniqueness
+-- only applies to live accounts, letting the address be registered again.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (email) WHERE deleted_at IS NULL;
+
+-- Append-only record of sensitive actions. User ids are deliberately not
+-- foreign keys so entries outlive the accounts they refer to.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id             BIGSERIAL PRIMARY KEY,
+    actor_user_id  BIGINT,
+    action         TEXT NOT NULL,
+    target_user_id BIGINT,
+    details        JSONB NOT NULL DEFAULT '{}',
+    created_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_log_target_user ON audit_log (target_user_id, created_at DESC);
@@ -0,0 +1,20 @@
+-- Background jobs that unpack a stored zip/tar archive into a folder. The
+-- counters are updated as the job runs so clients can show progress.
+CREATE TABLE IF NOT EXISTS extraction_jobs (
+    id                BIGSERIAL PRIMARY KEY,
+    user_id           BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_uuid         TEXT NOT NULL,
+    folder_uuid       TEXT NOT NULL DEFAULT '',
+    status            TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    total_entries     INT NOT NULL DEFAULT 0,
+    processed_entries INT NOT NULL DEFAULT 0,
+    skipped_entries   INT NOT NULL DEFAULT 0,
+    total_bytes       BIGINT NOT NULL DEFAULT 0,
+    written_bytes     BIGINT NOT NULL DEFAULT 0,
+    error             TEXT NOT NULL DEFAULT '',
+    created_at        TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at        TIMESTAMP NOT NULL DEFAULT now(),
+    finished_at       TIMESTAMP
+);
+
+CREATE INDEX idx_extraction_jobs_user_created ON extraction_jobs (user_id, created_at DESC);
@@ -0,0 +1,38 @@
+-- Durable background job queue. Handlers and the scheduler enqueue rows
+-- here and workers claim them with SKIP LOCKED, so each job runs on exactly
+-- one instance. A 'running' row past locked_until was claimed by a worker
+-- that died and is picked up again (or failed, once out of attempts).
+-- user_id is NULL for system jobs such as the trash sweep.
+CREATE TABLE IF NOT EXISTS jobs (
+    id               BIGSERIAL PRIMARY KEY,
+    user_id          BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    kind             TEXT NOT NULL,
+    payload          JSONB NOT NULL DEFAULT '{}',
+    result           JSONB,
+    status           TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed', 'canceled')),
+    progress_done    BIGINT NOT NULL DEFAULT 0,
+    progress_total   BIGINT NOT NULL DEFAULT 0,
+    attempts         INT NOT NULL DEFAULT 0,
+    max_attempts     INT NOT NULL DEFAULT 1,
+    cancel_requested BOOLEAN NOT NULL DEFAULT false,
+    error            TEXT NOT NULL DEFAULT '',
+    run_at           TIMESTAMP NOT NULL DEFAULT now(),
+    locked_until     TIMESTAMP,
+    created_at       TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMP NOT NULL DEFAULT now(),
+    started_at       TIMESTAMP,
+    finished_at      TIMESTAMP
+);
+
+CREATE INDEX idx_jobs_due ON jobs (run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX idx_jobs_user_created ON jobs (user_id, created_at DESC);
+CREATE INDEX idx_jobs_finished ON jobs (finished_at) WHERE finished_at IS NOT NULL;
+
+-- When each periodic job is next due. Instances race to advance next_run_at
+-- and only the winner enqueues, so a schedule fires once per interval no
+-- matter how many instances are running.
+CREATE TABLE IF NOT EXISTS job_schedules (
+    name        TEXT PRIMARY KEY,
+    next_run_at TIMESTAMP NOT NULL DEFAULT now(),
+    last_job_id BIGINT
+);
@@ -0,0 +1,12 @@
+-- Recent attempts counted by the rate limiters when RATE_LIMIT_STORE is
+-- 'postgres', so every instance sees the same counts. Rows are pruned per
+-- key on access and by the session sweeper once expired.
+CREATE TABLE IF NOT EXISTS rate_limit_hits (
+    id         BIGSERIAL PRIMARY KEY,
+    key        TEXT NOT NULL,
+    hit_at     TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_hits_key ON rate_limit_hits (key, hit_at);
+CREATE INDEX idx_rate_limit_hits_expires ON rate_limit_hits (expires_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+
+ALTER TABLE folders DROP COLUMN quota;
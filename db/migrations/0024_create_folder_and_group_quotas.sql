@@ -0,0 +1,22 @@
+-- Optional quotas on a folder's subtree and on groups of users, pooled
+-- across their members. A quota of 0 means unlimited. soft_warned_at
+-- records that a group's members were warned it passed its soft quota, so
+-- they're warned once per crossing.
+ALTER TABLE folders ADD COLUMN quota BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS groups (
+    id             BIGSERIAL PRIMARY KEY,
+    name           TEXT NOT NULL UNIQUE,
+    quota          BIGINT NOT NULL DEFAULT 0,
+    soft_quota     BIGINT NOT NULL DEFAULT 0,
+    soft_warned_at TIMESTAMP,
+    created_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id  BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_group_members_user ON group_members (user_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notifications;
+
+ALTER TABLE users DROP COLUMN over_quota_since;
+ALTER TABLE users DROP COLUMN quota_warned_percent;
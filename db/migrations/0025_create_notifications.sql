@@ -0,0 +1,18 @@
+-- In-app notifications, and the quota state that drives the quota ones.
+-- quota_warned_percent is the highest warning threshold a user has been
+-- told about, so each is sent once until their usage drops back under it.
+-- over_quota_since is when their usage last went over their quota, which
+-- starts the grace period if one is configured.
+ALTER TABLE users ADD COLUMN quota_warned_percent INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN over_quota_since TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind       TEXT NOT NULL,
+    message    TEXT NOT NULL,
+    read_at    TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_notifications_user ON notifications (user_id, created_at DESC);
@@ -0,0 +1,8 @@
+-- Extractions and bulk copies now run on the jobs queue (files.extract and
+-- files.copy), so their own tables, and the scheduled job that failed the
+-- ones left behind by a stopped instance, are no longer used.
+DROP TABLE IF EXISTS extraction_jobs;
+DROP TABLE IF EXISTS copy_jobs;
+
+DELETE FROM job_schedules WHERE name = 'jobs.reap_interrupted';
+DELETE FROM jobs WHERE kind = 'jobs.reap_interrupted';
@@ -0,0 +1,2 @@
+-- The bodies and tokens scrubbed by the up migration are gone for good;
+-- rolling it back leaves the outbox as it is.
@@ -0,0 +1,8 @@
+-- Delivered and dead mail no longer keeps its bodies, which can hold
+-- password reset links, and dedup keys hash the token they're built from
+-- instead of holding it. Scrub what was queued before that.
+UPDATE email_outbox SET html = '', text = '' WHERE status IN ('sent', 'dead');
+
+UPDATE email_outbox
+SET dedup_key = split_part(dedup_key, ':', 1) || ':' || encode(sha256(convert_to(substr(dedup_key, strpos(dedup_key, ':') + 1), 'UTF8')), 'hex')
+WHERE split_part(dedup_key, ':', 1) IN ('forgot_password', 'password_changed', 'admin_password_reset', 'user_created');
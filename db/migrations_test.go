@@ -0,0 +1,91 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMigrationsHaveDowns(t *testing.T) {
+	names, err := migrationNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no migrations found")
+	}
+	for _, name := range names {
+		if _, err := migrationFS.ReadFile("migrations/" + name + downSuffix); err != nil {
+			t.Errorf("%s has no down migration: %v", name, err)
+		}
+	}
+}
+
+func TestMigrationSteps(t *testing.T) {
+	known := []string{"0001_a", "0002_b", "0003_c", "0004_d"}
+	applied := func(names ...string) migrationState {
+		s := migrationState{known: known, applied: map[string]time.Time{}}
+		for _, n := range names {
+			s.applied[n] = time.Time{}
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		state    migrationState
+		target   string
+		wantUp   []string
+		wantDown []string
+	}{
+		{name: "forward", state: applied("0001_a"), target: "0003", wantUp: []string{"0002_b", "0003_c"}},
+		{name: "back", state: applied("0001_a", "0002_b", "0003_c"), target: "0001_a", wantDown: []string{"0003_c", "0002_b"}},
+		{name: "everything", state: applied("0001_a", "0002_b"), target: "0", wantDown: []string{"0002_b", "0001_a"}},
+		{name: "fills a gap", state: applied("0001_a", "0003_c"), target: "0003", wantUp: []string{"0002_b"}},
+		{name: "already there", state: applied("0001_a", "0002_b"), target: "0002"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := tt.state.target(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			up, down := tt.state.steps(target)
+			if !slices.Equal(up, tt.wantUp) || !slices.Equal(down, tt.wantDown) {
+				t.Errorf("got up %v, down %v, want up %v, down %v", up, down, tt.wantUp, tt.wantDown)
+			}
+		})
+	}
+
+	if _, err := applied().target("0009"); err == nil {
+		t.Error("target of an unknown migration succeeded")
+	}
+
+	s := applied("0001_a", "0003_c")
+	if got, want := s.pending(1), []string{"0002_b"}; !slices.Equal(got, want) {
+		t.Errorf("pending(1) = %v, want %v", got, want)
+	}
+	if got, want := s.pending(0), []string{"0002_b", "0004_d"}; !slices.Equal(got, want) {
+		t.Errorf("pending(0) = %v, want %v", got, want)
+	}
+	if got, want := s.latest(1), []string{"0003_c"}; !slices.Equal(got, want) {
+		t.Errorf("latest(1) = %v, want %v", got, want)
+	}
+	if got, want := s.latest(0), []string{"0003_c", "0001_a"}; !slices.Equal(got, want) {
+		t.Errorf("latest(0) = %v, want %v", got, want)
+	}
+
+	s.applied["0005_newer"] = time.Time{}
+	if got, want := s.unknown(), []string{"0005_newer"}; !slices.Equal(got, want) {
+		t.Errorf("unknown() = %v, want %v", got, want)
+	}
+}
+
+func TestTransactional(t *testing.T) {
+	if !transactional("CREATE TABLE t (id INT);\n") {
+		t.Error("plain migration isn't transactional")
+	}
+	if transactional("-- avenue:no-transaction\nCREATE INDEX CONCURRENTLY i ON t (id);\n") {
+		t.Error("opted-out migration is transactional")
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+
+	"avenue/backend/sdk"
+)
+
+// CreateNotification adds an unread notification for userID.
+func CreateNotification(userID int64, kind, message string) error {
+	_, err := DB.Exec(
+		`INSERT INTO notifications (user_id, kind, message) VALUES ($1, $2, $3)`,
+		userID, kind, message,
+	)
+	return err
+}
+
+// ListNotifications returns userID's latest notifications, newest first,
+// and how many of all of theirs are unread.
+func ListNotifications(userID int64, limit int) ([]sdk.Notification, int, error) {
+	rows, err := DB.Query(`
+		SELECT id, kind, message, read_at, created_at FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []sdk.Notification{}
+	for rows.Next() {
+		var n sdk.Notification
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Message, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var unread int
+	err = DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`, userID).Scan(&unread)
+	return notifications, unread, err
+}
+
+// MarkNotificationRead marks one of userID's notifications read. Returns
+// sql.ErrNoRows if they have no such notification.
+func MarkNotificationRead(id, userID int64) error {
+	res, err := DB.Exec(
+		`UPDATE notifications SET read_at = COALESCE(read_at, now()) WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks all of userID's notifications read.
+func MarkAllNotificationsRead(userID int64) error {
+	_, err := DB.Exec(`UPDATE notifications SET read_at = now() WHERE user_id = $1 AND read_at IS NULL`, userID)
+	return err
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"time"
+
+	"avenue/backend/logger"
+
+	"github.com/lib/pq"
+)
+
+const (
+	listenerMinReconnect = 10 * time.Second
+	listenerMaxReconnect = time.Minute
+)
+
+// Notify sends a Postgres NOTIFY on channel, waking every instance that
+// Listens on it.
+func Notify(channel string) error {
+	_, err := DB.Exec(`SELECT pg_notify($1, '')`, channel)
+	return err
+}
+
+// Listen calls fn whenever a NOTIFY arrives on channel, from this or any
+// other instance. It holds its own connection, reconnecting as needed, and
+// also calls fn after reconnecting since notifications sent while it was
+// disconnected are lost.
+func Listen(channel string, fn func()) error {
+	l := pq.NewListener(dsn, listenerMinReconnect, listenerMaxReconnect, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warnf("db listen %s: %v", channel, err)
+		}
+	})
+	if err := l.Listen(channel); err != nil {
+		_ = l.Close()
+		return err
+	}
+
+	go func() {
+		for range l.Notify {
+			// A nil notification means the connection was re-established.
+			fn()
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql"
+
+	"avenue/backend/sdk"
+
+	"github.com/lib/pq"
+)
+
+// SetFolderQuota sets the quota on ownerID's folderID. Returns
+// sql.ErrNoRows if there's no such folder.
+func SetFolderQuota(folderID, ownerID string, quota int64) error {
+	res, err := DB.Exec(
+		`UPDATE folders SET quota=$3 WHERE uuid=$1 AND owner_id=$2::BIGINT AND deleted_at IS NULL`,
+		folderID, ownerID, quota,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListFolderQuotas returns the quotas on folderID and its ancestors,
+// nearest first, each with how much its subtree holds.
+func ListFolderQuotas(folderID string) ([]sdk.FolderQuota, error) {
+	rows, err := DB.Query(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, uuid, name, parent_id, quota, 0 AS depth FROM folders WHERE uuid = $1
+			UNION ALL
+			SELECT p.id, p.uuid, p.name, p.parent_id, p.quota, a.depth + 1
+			FROM folders p
+			INNER JOIN ancestors a ON p.id = a.parent_id
+		)
+		SELECT uuid, name, quota FROM ancestors WHERE quota > 0 ORDER BY depth
+	`, folderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotas := []sdk.FolderQuota{}
+	for rows.Next() {
+		var q sdk.FolderQuota
+		if err := rows.Scan(&q.FolderID, &q.Name, &q.Quota); err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range quotas {
+		if quotas[i].Used, err = FolderUsage(quotas[i].FolderID); err != nil {
+			return nil, err
+		}
+	}
+	return quotas, nil
+}
+
+// FolderUsage returns the total size of the files in folderID's subtree,
+// not counting trashed ones.
+func FolderUsage(folderID string) (int64, error) {
+	var used int64
+	err := DB.QueryRow(`
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM folders WHERE uuid = $1
+			UNION ALL
+			SELECT f.id FROM folders f
+			INNER JOIN subtree s ON f.parent_id = s.id
+			WHERE f.deleted_at IS NULL
+		)
+		SELECT COALESCE(SUM(file_size), 0) FROM files
+		WHERE parent_id IN (SELECT id FROM subtree) AND deleted_at IS NULL
+	`, folderID).Scan(&used)
+	return used, err
+}
+
+// BytesEnteringFolder returns how much moving the given files and folders
+// of userID's would add to folderID's subtree. Items already inside it
+// don't count, since moving them around within it changes nothing.
+func BytesEnteringFolder(folderID string, fileIDs, folderIDs []string, userID string) (int64, error) {
+	var size int64
+	err := DB.QueryRow(`
+		WITH RECURSIVE target AS (
+			SELECT id FROM folders WHERE uuid = $1
+			UNION ALL
+			SELECT f.id FROM folders f
+			INNER JOIN target t ON f.parent_id = t.id
+		),
+		moved AS (
+			SELECT id FROM folders
+			WHERE uuid = ANY($3::text[]) AND owner_id = $4::BIGINT AND deleted_at IS NULL
+			  AND id NOT IN (SELECT id FROM target)
+			UNION ALL
+			SELECT f.id FROM folders f
+			INNER JOIN moved m ON f.parent_id = m.id
+			WHERE f.deleted_at IS NULL
+		)
+		SELECT COALESCE(SUM(file_size), 0) FROM files
+		WHERE deleted_at IS NULL AND (
+			parent_id IN (SELECT id FROM moved)
+			OR (uuid = ANY($2::text[])
+			    AND (created_by = $4::BIGINT OR parent_id IN (SELECT id FROM folders WHERE owner_id = $4::BIGINT))
+			    AND (parent_id IS NULL OR parent_id NOT IN (SELECT id FROM target)))
+		)
+	`, folderID, pq.Array(fileIDs), pq.Array(folderIDs), userID).Scan(&size)
+	return size, err
+}
@@ -0,0 +1,60 @@
+package db
+
+import "time"
+
+// RateLimitHit records an attempt for key and reports whether it's still
+// within max attempts per window, counting attempts from every instance.
+// A rejected attempt isn't recorded; instead RateLimitHit returns how long
+// until the oldest recorded one ages out. Attempts for the same key are
+// serialized with a transaction-level advisory lock so concurrent requests
+// can't both slip in under the limit.
+func RateLimitHit(key string, max int, window time.Duration) (bool, time.Duration, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return false, 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, key); err != nil {
+		return false, 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM rate_limit_hits WHERE key = $1 AND expires_at <= now()`, key); err != nil {
+		return false, 0, err
+	}
+
+	var count int
+	var retryAfter float64
+	if err := tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM MIN(expires_at) - now()), 0)
+		FROM rate_limit_hits
+		WHERE key = $1
+	`, key).Scan(&count, &retryAfter); err != nil {
+		return false, 0, err
+	}
+	if count >= max {
+		return false, time.Duration(retryAfter * float64(time.Second)), tx.Commit()
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO rate_limit_hits (key, expires_at) VALUES ($1, now() + $2 * INTERVAL '1 second')
+	`, key, window.Seconds()); err != nil {
+		return false, 0, err
+	}
+	return true, 0, tx.Commit()
+}
+
+// ResetRateLimit forgets every recorded attempt for key.
+func ResetRateLimit(key string) error {
+	_, err := DB.Exec(`DELETE FROM rate_limit_hits WHERE key = $1`, key)
+	return err
+}
+
+// DeleteExpiredRateLimitHits removes attempts that have aged out of their
+// window, for keys that haven't been seen since.
+func DeleteExpiredRateLimitHits() (int64, error) {
+	res, err := DB.Exec(`DELETE FROM rate_limit_hits WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -1,8 +1,12 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"strconv"
+	"time"
 
+	"avenue/backend/config"
 	"avenue/backend/sdk"
 
 	"golang.org/x/crypto/bcrypt"
@@ -131,18 +135,98 @@ func GetUserUsage(id int64) (int64, error) {
 	return spaceUsed, err
 }
 
+// UsageChange is a user's quota state right after UpdateUsage changed it.
+type UsageChange struct {
+	UserID         int64
+	Used           int64
+	Quota          int64
+	WarnedPercent  int
+	OverQuotaSince *time.Time
+}
+
+var usageHooks []func(UsageChange)
+
+// OnUsageChange registers fn to be called after every UpdateUsage, e.g. to
+// warn users nearing their quota. Hooks are registered at startup.
+func OnUsageChange(fn func(UsageChange)) {
+	usageHooks = append(usageHooks, fn)
+}
+
+// UpdateUsage adds delta to userID's usage and notes when they went over
+// their quota, then runs the OnUsageChange hooks. A delta of 0 just runs
+// them, e.g. after the quota itself changed.
 func UpdateUsage(userID int64, delta int64) error {
-	_, err := DB.Exec(`
-		UPDATE users SET space_used = GREATEST(0, space_used + $2), updated_at=now() WHERE id=$1
-	`, userID, delta)
-	return err
+	change, ok, err := updateUsage(DB, userID, delta)
+	if err != nil || !ok {
+		return err
+	}
+	runUsageHooks(change)
+	return nil
+}
+
+// rowQueryer is a *sqlx.DB or a *sqlx.Tx.
+type rowQueryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// updateUsage is UpdateUsage through q, without running the hooks, so it
+// can be part of a transaction; the caller runs them once it commits. ok
+// is false if there's no such user.
+func updateUsage(q rowQueryer, userID int64, delta int64) (change UsageChange, ok bool, err error) {
+	change.UserID = userID
+	err = q.QueryRow(`
+		UPDATE users SET
+			space_used = GREATEST(0, space_used + $2),
+			over_quota_since = CASE
+				WHEN quota > 0 AND GREATEST(0, space_used + $2) > quota THEN COALESCE(over_quota_since, now())
+			END,
+			updated_at=now()
+		WHERE id=$1
+		RETURNING space_used, quota, quota_warned_percent, over_quota_since
+	`, userID, delta).Scan(&change.Used, &change.Quota, &change.WarnedPercent, &change.OverQuotaSince)
+	if errors.Is(err, sql.ErrNoRows) {
+		return change, false, nil
+	}
+	return change, err == nil, err
+}
+
+func runUsageHooks(change UsageChange) {
+	for _, fn := range usageHooks {
+		fn(change)
+	}
+}
+
+// GetQuotaStatus returns userID's quota, usage and quota warning state.
+// Limit and GraceEndsAt depend on the config and are left for the caller.
+func GetQuotaStatus(userID int64) (sdk.QuotaStatus, error) {
+	var q sdk.QuotaStatus
+	err := DB.QueryRow(`
+		SELECT quota, space_used, quota_warned_percent, over_quota_since
+		FROM users WHERE id=$1 AND deleted_at IS NULL
+	`, userID).Scan(&q.Quota, &q.Used, &q.WarnedPercent, &q.OverQuotaSince)
+	return q, err
+}
+
+// SetQuotaWarnedPercent records the highest quota warning userID has been
+// sent, and reports whether it changed. Concurrent callers moving it to
+// the same value see true only once, so each warning goes out once.
+func SetQuotaWarnedPercent(userID int64, percent int) (bool, error) {
+	res, err := DB.Exec(
+		`UPDATE users SET quota_warned_percent=$2 WHERE id=$1 AND quota_warned_percent <> $2`,
+		userID, percent,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
-// UpsertRootUser creates the admin account from env vars if it doesn't already exist.
-// If ROOT_USER_RESET=true is set, the root user's password is also updated on startup.
-func UpsertRootUser() error {
-	email := getenv("ROOT_USER_EMAIL", "root@gmail.com")
-	password := getenv("ROOT_USER_PASSWORD", "password")
+// UpsertRootUser creates the admin account described by cfg if it doesn't already exist.
+// If cfg.Reset is set, the root user's password is also updated on startup.
+func UpsertRootUser(cfg config.RootUser) error {
+	email := cfg.Email
+	password := cfg.Password
 
 	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -150,7 +234,7 @@ func UpsertRootUser() error {
 	}
 
 	var count int
-	if err := DB.QueryRow(`SELECT COUNT(*) FROM users WHERE email=$1`, email).Scan(&count); err != nil {
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM users WHERE email=$1 AND deleted_at IS NULL`, email).Scan(&count); err != nil {
 		return err
 	}
 
@@ -162,9 +246,9 @@ func UpsertRootUser() error {
 		return err
 	}
 
-	if getenv("ROOT_USER_RESET", "") == "true" {
+	if cfg.Reset {
 		_, err = DB.Exec(`
-			UPDATE users SET password=$2, updated_at=now() WHERE email=$1
+			UPDATE users SET password=$2, updated_at=now() WHERE email=$1 AND deleted_at IS NULL
 		`, email, string(hashed))
 		return err
 	}
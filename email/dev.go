@@ -0,0 +1,66 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// devFrom is the From address used by the development senders.
+const devFrom = "Avenue <avenue@localhost>"
+
+// WriterSender writes every message, MIME encoded, to an io.Writer. With
+// os.Stdout it's handy for local development; nothing is delivered.
+type WriterSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSender(w io.Writer) *WriterSender {
+	return &WriterSender{w: w}
+}
+
+// Send writes the message followed by a separator line.
+func (s *WriterSender) Send(msg Message) error {
+	body, err := buildMIME(devFrom, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(body); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "\r\n%s\r\n", "----- end of message -----")
+	return err
+}
+
+// FileSender writes each message to its own .eml file in a directory, which
+// most mail clients can open directly. Nothing is delivered.
+type FileSender struct {
+	dir string
+}
+
+// NewFileSender creates dir if needed and returns a sender writing into it.
+func NewFileSender(dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("email: create %s: %w", dir, err)
+	}
+	return &FileSender{dir: dir}, nil
+}
+
+// Send writes the message to <dir>/<timestamp>-<random>.eml.
+func (s *FileSender) Send(msg Message) error {
+	now := time.Now()
+	body, err := buildMIME(devFrom, msg, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000Z"), randomToken()[:8])
+	return os.WriteFile(filepath.Join(s.dir, name), body, 0o640)
+}
@@ -2,8 +2,12 @@ package email
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 
-	"avenue/backend/shared"
+	"avenue/backend/config"
+	"avenue/backend/db"
 )
 
 // Message represents an outbound email.
@@ -12,6 +16,10 @@ type Message struct {
 	Subject string
 	HTML    string
 	Text    string
+	// DedupKey, when set, makes Send a no-op if a message with the same key
+	// was already enqueued, e.g. "welcome:<user id>". Keys are shown to
+	// admins, so they mustn't hold secrets such as reset tokens.
+	DedupKey string
 }
 
 // Sender is the interface for sending emails. Implementations can be swapped
@@ -23,21 +31,104 @@ type Sender interface {
 // Default is the package-level sender, set during application initialization.
 var Default Sender
 
-// globalEmailTo overrides the To address on all outbound messages when set.
-var globalEmailTo = shared.GetEnv("GLOBAL_EMAIL_TO", "")
+// globalEmailTo overrides the To address on all outbound messages when set,
+// and templateDir holds deployment overrides of the built-in templates. Both
+// are set by Configure.
+var globalEmailTo, templateDir string
 
-var ErrNotConfigured = errors.New("sender is not configured")
+// Configure applies the settings cfg holds for building and addressing
+// messages. Call it at startup, before anything is sent.
+func Configure(cfg config.Email) {
+	globalEmailTo = cfg.GlobalTo
+	templateDir = cfg.TemplateDir
+}
+
+var (
+	ErrNotConfigured = errors.New("sender is not configured")
+	// ErrInvalidAddress is returned for malformed addresses. Retrying won't
+	// help, so the outbox gives up on such messages straight away.
+	ErrInvalidAddress = errors.New("email: invalid address")
+)
 
-// Send delivers msg via Default, overriding the To address with GLOBAL_EMAIL_TO
-// if that variable is set.
+// Send enqueues msg in the outbox for the worker to deliver (see
+// StartOutboxWorker), so a provider outage delays mail instead of losing it.
+// Returns ErrNotConfigured without enqueuing if no sender is configured.
 func Send(msg Message) error {
 	if Default == nil {
 		return ErrNotConfigured
 	}
 
+	_, enqueued, err := db.EnqueueEmail(msg.DedupKey, msg.To, msg.Subject, msg.HTML, msg.Text)
+	if err != nil {
+		return fmt.Errorf("email: enqueue: %w", err)
+	}
+	if enqueued {
+		wakeOutbox()
+	}
+	return nil
+}
+
+// Deliver sends msg immediately via Default, overriding the To address with
+// the configured global address if there is one. Callers should normally use Send.
+func Deliver(msg Message) error {
+	if Default == nil {
+		return ErrNotConfigured
+	}
+
 	if globalEmailTo != "" {
 		msg.To = globalEmailTo
 	}
 
 	return Default.Send(msg)
 }
+
+// NewSender builds the sender selected by cfg.Sender:
+//
+//	ses    - AWS SES (see NewSESSender)
+//	smtp   - an SMTP relay (see NewSMTPSender)
+//	file   - write .eml files to cfg.FileDir, for development
+//	stdout - print messages to stdout, for development
+//	none   - don't send email
+//
+// When cfg.Sender is empty, SES is used if cfg.SES.From is set, otherwise no
+// sender is configured. A nil Sender with a nil error means email is
+// intentionally disabled.
+func NewSender(cfg config.Email) (Sender, error) {
+	kind := strings.ToLower(cfg.Sender)
+	if kind == "" {
+		if cfg.SES.From == "" {
+			return nil, ErrNotConfigured
+		}
+		kind = "ses"
+	}
+
+	// Each constructor returns a concrete pointer type; check the error
+	// before converting so a failed constructor never yields a non-nil
+	// Sender wrapping a nil pointer.
+	switch kind {
+	case "ses":
+		s, err := NewSESSender(cfg.SES)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "smtp":
+		s, err := NewSMTPSender(cfg.SMTP)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "file":
+		s, err := NewFileSender(cfg.FileDir)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "stdout":
+		return NewWriterSender(os.Stdout), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("email: unknown sender %q", kind)
+	}
+}
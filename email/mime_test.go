@@ -0,0 +1,69 @@
+package email
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildMIMEMultipart(t *testing.T) {
+	raw, err := buildMIME("Avenue <avenue@example.com>", Message{
+		To:      "ada@example.com",
+		Subject: "Réinitialiser",
+		HTML:    "<p>Hello</p>",
+		Text:    "Hello\nthere",
+	}, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("buildMIME: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Réinitialiser" {
+		t.Errorf("subject = %q (%v)", subject, err)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("Message-ID = %q", msg.Header.Get("Message-ID"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q (%v)", mediaType, err)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var got []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		body, _ := io.ReadAll(p) // multipart decodes quoted-printable
+		got = append(got, p.Header.Get("Content-Type")+"|"+string(body))
+	}
+
+	want := []string{
+		"text/plain; charset=utf-8|Hello\r\nthere",
+		"text/html; charset=utf-8|<p>Hello</p>",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("parts = %q, want %q", got, want)
+	}
+}
+
+func TestBuildMIMERejectsBadAddress(t *testing.T) {
+	if _, err := buildMIME("avenue@example.com", Message{To: "not an address", Text: "x"}, time.Now()); err == nil {
+		t.Fatal("expected an error for an invalid To address")
+	}
+}
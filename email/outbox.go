@@ -0,0 +1,119 @@
+package email
+
+import (
+	"errors"
+	"time"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/shared"
+)
+
+const (
+	outboxBatchSize = 20
+	// outboxLease is how long a claimed message is reserved for the worker
+	// that claimed it before another worker may reclaim it.
+	outboxLease = 2 * time.Minute
+	// maxRetryBackoff caps the exponential backoff between attempts.
+	maxRetryBackoff = 6 * time.Hour
+)
+
+// wake lets Send nudge the worker so fresh mail (e.g. a password reset)
+// goes out immediately rather than on the next poll.
+var wake = make(chan struct{}, 1)
+
+func wakeOutbox() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// StartOutboxWorker launches a background goroutine that delivers queued
+// email. It polls every cfg.OutboxPollInterval and whenever Send enqueues
+// something. Failed deliveries are retried with exponential backoff
+// starting at cfg.RetryBackoff (capped at 6h) and marked dead after
+// cfg.MaxAttempts attempts. Delivered messages are deleted after
+// cfg.OutboxRetention.
+func StartOutboxWorker(cfg config.Email) {
+	interval := cfg.OutboxPollInterval
+	retention := cfg.OutboxRetention
+	base := cfg.RetryBackoff
+	maxAttempts := cfg.MaxAttempts
+
+	go func() {
+		poll := time.NewTicker(interval)
+		defer poll.Stop()
+		cleanup := time.NewTicker(time.Hour)
+		defer cleanup.Stop()
+
+		drainOutbox(base, maxAttempts)
+		for {
+			select {
+			case <-poll.C:
+			case <-wake:
+			case <-cleanup.C:
+				purgeSentEmails(retention)
+				continue
+			}
+			drainOutbox(base, maxAttempts)
+		}
+	}()
+}
+
+// drainOutbox delivers due messages in batches until none are left.
+func drainOutbox(base time.Duration, maxAttempts int) {
+	if Default == nil {
+		return
+	}
+
+	for {
+		emails, err := db.ClaimDueEmails(outboxBatchSize, outboxLease)
+		if err != nil {
+			logger.Errorf("email outbox: claim: %v", err)
+			return
+		}
+
+		for _, e := range emails {
+			deliverOutboxEmail(e, base, maxAttempts)
+		}
+
+		if len(emails) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func deliverOutboxEmail(e db.ClaimedEmail, base time.Duration, maxAttempts int) {
+	err := Deliver(Message{To: e.To, Subject: e.Subject, HTML: e.HTML, Text: e.Text})
+	if err == nil {
+		if err := db.MarkEmailSent(e.ID); err != nil {
+			logger.Errorf("email outbox: mark %d sent: %v", e.ID, err)
+		}
+		return
+	}
+
+	dead := e.Attempts >= maxAttempts || errors.Is(err, ErrInvalidAddress)
+	next := time.Now().Add(shared.RetryBackoff(e.Attempts, base, maxRetryBackoff))
+	if dead {
+		logger.Errorf("email outbox: giving up on %d to %s after %d attempt(s): %v", e.ID, e.To, e.Attempts, err)
+	} else {
+		logger.Warnf("email outbox: attempt %d for %d failed, retrying at %s: %v", e.Attempts, e.ID, next.Format(time.RFC3339), err)
+	}
+
+	if err := db.MarkEmailFailed(e.ID, err.Error(), next, dead); err != nil {
+		logger.Errorf("email outbox: mark %d failed: %v", e.ID, err)
+	}
+}
+
+func purgeSentEmails(retention time.Duration) {
+	removed, err := db.DeleteSentEmailsBefore(time.Now().Add(-retention))
+	if err != nil {
+		logger.Errorf("email outbox: purge sent: %v", err)
+		return
+	}
+	if removed > 0 {
+		logger.Infof("email outbox: removed %d delivered message(s)", removed)
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 
-	"avenue/backend/shared"
+	"avenue/backend/config"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sesv2"
 	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
 )
@@ -17,25 +17,24 @@ type SESSender struct {
 	from   string
 }
 
-// NewSESSender creates a SESSender from environment variables:
+// NewSESSender creates a SESSender sending from cfg.From. The region and
+// credentials come from the standard AWS environment:
 //
-//	SES_FROM       - the From address (required)
 //	AWS_REGION     - AWS region (required, or set via standard AWS env/config)
 //	AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY - credentials (or use IAM role)
-func NewSESSender() (*SESSender, error) {
-	from := shared.GetEnv("SES_FROM", "")
-	if from == "" {
-		return nil, fmt.Errorf("email: SES_FROM is not set")
+func NewSESSender(cfg config.SES) (*SESSender, error) {
+	if cfg.From == "" {
+		return nil, fmt.Errorf("email: no SES from address is set")
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background())
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("email: failed to load AWS config: %w", err)
 	}
 
 	return &SESSender{
-		client: sesv2.NewFromConfig(cfg),
-		from:   from,
+		client: sesv2.NewFromConfig(awsCfg),
+		from:   cfg.From,
 	}, nil
 }
 
@@ -0,0 +1,155 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"avenue/backend/config"
+)
+
+// SMTP connection security modes.
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+// SMTPSender sends emails through an SMTP relay.
+type SMTPSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+	security string
+	timeout  time.Duration
+}
+
+// NewSMTPSender creates an SMTPSender relaying through cfg.Host. The port
+// defaults to 587, or 465 with implicit TLS (SMTPSecurityTLS); the username
+// and password, if set, are sent with PLAIN auth.
+func NewSMTPSender(cfg config.SMTP) (*SMTPSender, error) {
+	s := &SMTPSender{
+		host:     cfg.Host,
+		port:     cfg.Port,
+		username: cfg.Username,
+		password: cfg.Password,
+		from:     cfg.From,
+		security: strings.ToLower(cfg.Security),
+		timeout:  30 * time.Second,
+	}
+
+	if s.host == "" {
+		return nil, fmt.Errorf("email: no SMTP host is set")
+	}
+	if _, err := mail.ParseAddress(s.from); err != nil {
+		return nil, fmt.Errorf("email: SMTP from is not a valid address: %w", err)
+	}
+
+	defaultPort := "587"
+	switch s.security {
+	case SMTPSecurityTLS:
+		defaultPort = "465"
+	case SMTPSecurityStartTLS, SMTPSecurityNone:
+	default:
+		return nil, fmt.Errorf("email: unknown SMTP security %q", s.security)
+	}
+	if s.port == "" {
+		s.port = defaultPort
+	}
+
+	return s, nil
+}
+
+// Send delivers the message via the configured SMTP relay.
+func (s *SMTPSender) Send(msg Message) error {
+	body, err := buildMIME(s.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	c, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if s.username != "" {
+		// net/smtp refuses PLAIN auth over an unencrypted connection to
+		// anything but localhost, so credentials never leak in the clear.
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("email: smtp auth: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(s.from)
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("%w: to: %v", ErrInvalidAddress, err)
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("email: smtp MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("email: smtp RCPT TO: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("email: smtp DATA: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("email: smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: smtp DATA: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// dial connects to the relay and negotiates TLS according to s.security.
+func (s *SMTPSender) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.host, s.port)
+	tlsConfig := &tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: s.timeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.security == SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("email: smtp dial: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(s.timeout))
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("email: smtp handshake: %w", err)
+	}
+
+	if s.security == SMTPSecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			_ = c.Close()
+			return nil, fmt.Errorf("email: smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("email: smtp starttls: %w", err)
+		}
+	}
+
+	return c, nil
+}
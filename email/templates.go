@@ -0,0 +1,188 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"avenue/backend/logger"
+)
+
+// Each template is up to three files in templates/ (or EMAIL_TEMPLATE_DIR):
+//
+//	<name>.subject.txt - the subject line (text/template, required)
+//	<name>.txt         - the plain text body (text/template, required)
+//	<name>.html        - the HTML body (html/template, optional)
+//
+// A file in EMAIL_TEMPLATE_DIR replaces the built-in file of the same name,
+// so deployments can rebrand just the parts they care about.
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+// Template names.
+const (
+	TemplateForgotPassword     = "forgot_password"
+	TemplateAdminPasswordReset = "admin_password_reset"
+	TemplateUserCreated        = "user_created"
+	TemplateWelcome            = "welcome"
+	TemplateGroupQuotaWarning  = "group_quota_warning"
+	TemplateQuotaWarning       = "quota_warning"
+)
+
+// TemplateData is passed to every template. Fields a template doesn't use
+// are left empty.
+type TemplateData struct {
+	// URL is the message's call to action, e.g. a password reset link.
+	URL string
+	// Name is what the message is about, e.g. a group's name.
+	Name string
+	// Used and Limit are human readable sizes for quota warnings, and
+	// Percent how much of the limit is used.
+	Used    string
+	Limit   string
+	Percent int
+	// Deadline is when a grace period ends, for people.
+	Deadline string
+}
+
+// sampleData is what the admin preview renders each template with.
+var sampleData = map[string]TemplateData{
+	TemplateForgotPassword:     {URL: "https://avenue.example.com/reset-password?token=preview"},
+	TemplateAdminPasswordReset: {URL: "https://avenue.example.com/reset-password?token=preview"},
+	TemplateUserCreated:        {URL: "https://avenue.example.com/reset-password?token=preview"},
+	TemplateWelcome:            {},
+	TemplateGroupQuotaWarning:  {Name: "Design team", Used: "81.0 GiB", Limit: "80.0 GiB"},
+	TemplateQuotaWarning:       {Used: "8.6 GiB", Limit: "10.0 GiB", Percent: 80},
+}
+
+var ErrUnknownTemplate = errors.New("email: unknown template")
+
+// Rendered is a template rendered for a particular TemplateData.
+type Rendered struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// TemplateNames lists the available templates, sorted.
+func TemplateNames() []string {
+	names := make([]string, 0, len(sampleData))
+	for name := range sampleData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SampleData returns the example data a template is previewed with.
+func SampleData(name string) (TemplateData, bool) {
+	data, ok := sampleData[name]
+	return data, ok
+}
+
+// templateFS layers the template directory (if set) over the built-in
+// templates.
+func templateFS() fs.FS {
+	builtin, _ := fs.Sub(builtinTemplates, "templates")
+	if templateDir == "" {
+		return builtin
+	}
+	return overlayFS{top: os.DirFS(templateDir), bottom: builtin}
+}
+
+// overlayFS serves files from top, falling back to bottom.
+type overlayFS struct {
+	top, bottom fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	return o.bottom.Open(name)
+}
+
+// Render renders the named template from the active template set (built-in
+// files overlaid with EMAIL_TEMPLATE_DIR). Reads happen on every call, so
+// edits to override files take effect without a restart.
+func Render(name string, data TemplateData) (Rendered, error) {
+	return render(templateFS(), name, data)
+}
+
+func render(fsys fs.FS, name string, data TemplateData) (Rendered, error) {
+	if _, ok := sampleData[name]; !ok {
+		return Rendered{}, fmt.Errorf("%w %q", ErrUnknownTemplate, name)
+	}
+
+	var (
+		r   Rendered
+		err error
+	)
+	if r.Subject, err = renderText(fsys, name+".subject.txt", data); err != nil {
+		return Rendered{}, err
+	}
+	r.Subject = strings.TrimSpace(r.Subject)
+	if r.Text, err = renderText(fsys, name+".txt", data); err != nil {
+		return Rendered{}, err
+	}
+
+	src, err := fs.ReadFile(fsys, name+".html")
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	} else if err != nil {
+		return Rendered{}, fmt.Errorf("email: read %s.html: %w", name, err)
+	}
+	tmpl, err := htmltemplate.New(name + ".html").Parse(string(src))
+	if err != nil {
+		return Rendered{}, fmt.Errorf("email: parse %s.html: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return Rendered{}, fmt.Errorf("email: render %s.html: %w", name, err)
+	}
+	r.HTML = buf.String()
+
+	return r, nil
+}
+
+func renderText(fsys fs.FS, file string, data TemplateData) (string, error) {
+	src, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return "", fmt.Errorf("email: read %s: %w", file, err)
+	}
+	tmpl, err := texttemplate.New(file).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return "", fmt.Errorf("email: parse %s: %w", file, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("email: render %s: %w", file, err)
+	}
+	return buf.String(), nil
+}
+
+// NewMessage renders the named template into a message for to. If a
+// deployment's override is broken, the built-in template is used instead so
+// mail keeps flowing; the error is logged.
+func NewMessage(to, name string, data TemplateData) (Message, error) {
+	r, err := Render(name, data)
+	if err != nil && !errors.Is(err, ErrUnknownTemplate) && templateDir != "" {
+		logger.Errorf("email: template override %s: %v; using built-in template", name, err)
+		builtin, _ := fs.Sub(builtinTemplates, "templates")
+		r, err = render(builtin, name, data)
+	}
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{To: to, Subject: r.Subject, HTML: r.HTML, Text: r.Text}, nil
+}
@@ -0,0 +1,73 @@
+package email
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuiltinTemplatesRender(t *testing.T) {
+	builtin, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range TemplateNames() {
+		t.Run(name, func(t *testing.T) {
+			data, _ := SampleData(name)
+			r, err := render(builtin, name, data)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if r.Subject == "" || strings.Contains(r.Subject, "\n") {
+				t.Errorf("subject = %q, want a single non-empty line", r.Subject)
+			}
+			if r.Text == "" {
+				t.Error("text body is empty")
+			}
+			if data.URL != "" && !strings.Contains(r.Text, data.URL) {
+				t.Errorf("text body doesn't contain the link %q", data.URL)
+			}
+		})
+	}
+}
+
+func TestOverlayOverridesIndividualFiles(t *testing.T) {
+	builtin, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	override := fstest.MapFS{
+		"forgot_password.subject.txt": {Data: []byte("Acme Drive password reset\n")},
+		"forgot_password.html":        {Data: []byte(`<a href="{{.URL}}">Reset</a>`)},
+	}
+
+	r, err := render(overlayFS{top: override, bottom: builtin}, TemplateForgotPassword, TemplateData{URL: "https://x.test/r?a=1&b=2"})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	if r.Subject != "Acme Drive password reset" {
+		t.Errorf("subject = %q", r.Subject)
+	}
+	if r.HTML != `<a href="https://x.test/r?a=1&amp;b=2">Reset</a>` {
+		t.Errorf("html = %q", r.HTML)
+	}
+	// The text body wasn't overridden, so it still comes from the built-in.
+	if !strings.Contains(r.Text, "https://x.test/r?a=1&b=2") {
+		t.Errorf("text = %q", r.Text)
+	}
+}
+
+func TestRenderReportsBrokenOverride(t *testing.T) {
+	builtin, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	override := fstest.MapFS{"welcome.txt": {Data: []byte("Hi {{.Nope}}")}}
+
+	if _, err := render(overlayFS{top: override, bottom: builtin}, TemplateWelcome, TemplateData{}); err == nil {
+		t.Fatal("expected an error for a template referencing an unknown field")
+	}
+}
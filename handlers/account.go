@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"avenue/backend/auth"
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/afero"
+)
+
+// exportManifestVersion is bumped whenever the layout of an account export
+// archive or its manifest.json changes incompatibly.
+const exportManifestVersion = 1
+
+// validateAccountDeletion checks that the confirmation email matches the
+// account being deleted and that deleting it wouldn't leave the app without
+// an admin.
+func validateAccountDeletion(target sdk.User, req sdk.DeleteAccountRequest, hasOtherAdmins bool) error {
+	if !strings.EqualFold(strings.TrimSpace(req.ConfirmEmail), target.Email) {
+		return errors.New("confirmation email does not match the account")
+	}
+
+	if target.IsAdmin && !hasOtherAdmins {
+		return errors.New("application requires at least one admin user")
+	}
+
+	return nil
+}
+
+// exportArchivePath returns the zip entry name for a file in an account
+// export: files/<folder path>/<name>, or files/<name> at the root.
+func exportArchivePath(folder, name string) string {
+	return path.Join("files", folder, name)
+}
+
+// DeleteOwnAccount deletes the authenticated user's account. The request
+// must repeat the account's email and, while password login is enabled,
+// its password.
+func (s *Server) DeleteOwnAccount(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", fmt.Errorf("user id not found: %w", err))
+		return
+	}
+
+	var req sdk.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	u, err := s.users.GetUserByIDStr(userID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	// SSO-only deployments have no password to confirm with; the live
+	// session plus the typed-out email has to do.
+	if authenticator, ok := s.loginAuthenticator(); ok {
+		if req.Password == "" {
+			respond(c, http.StatusBadRequest, "", errors.New("password is required"))
+			return
+		}
+		verified, err := authenticator.Authenticate(c.Request.Context(), u.Email, req.Password)
+		if err != nil || verified.ID != u.ID {
+			if err != nil && !errors.Is(err, auth.ErrInvalidCredentials) && !errors.Is(err, auth.ErrLoginDisabled) {
+				logger.Errorf("delete account: verify password: %v", err)
+			}
+			respond(c, http.StatusUnauthorized, "", errors.New("password is incorrect"))
+			return
+		}
+	}
+
+	summary, ok := s.deleteAccount(c, u, u.ID, req)
+	if !ok {
+		return
+	}
+
+	c.SetCookie(string(shared.USERCOOKIENAME), "", -1, "/", s.cfg.Server.CookieDomain, s.cfg.Server.Production(), true)
+	c.SetCookie(string(shared.SESSIONCOOKIENAME), "", -1, "/", s.cfg.Server.CookieDomain, s.cfg.Server.Production(), true)
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// AdminDeleteAccount deletes another user's account. Requires an admin
+// caller; admins delete their own account through DeleteOwnAccount.
+func (s *Server) AdminDeleteAccount(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req sdk.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	target, err := s.users.GetUserByIDStr(c.Param("userID"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "user not found", err)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	if target.ID == admin.ID {
+		respond(c, http.StatusBadRequest, "", errors.New("use /v1/user/profile to delete your own account"))
+		return
+	}
+
+	summary, ok := s.deleteAccount(c, target, admin.ID, req)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// deleteAccount runs the shared part of self and admin deletion: validation,
+// the database changes, session revocation and, in purge mode, removing the
+// user's blobs. On failure it writes the response and returns ok=false.
+func (s *Server) deleteAccount(c *gin.Context, target sdk.User, actorID int64, req sdk.DeleteAccountRequest) (sdk.AccountDeletionSummary, bool) {
+	var hasOtherAdmins bool
+	if target.IsAdmin {
+		var err error
+		if hasOtherAdmins, err = s.users.HasOtherAdmins(target); err != nil {
+			respond(c, http.StatusInternalServerError, "", fmt.Errorf("check admins: %w", err))
+			return sdk.AccountDeletionSummary{}, false
+		}
+	}
+
+	if err := validateAccountDeletion(target, req, hasOtherAdmins); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return sdk.AccountDeletionSummary{}, false
+	}
+
+	summary, err := db.DeleteAccount(target.ID, actorID, req.Purge)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "user not found", err)
+			return summary, false
+		}
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("delete account: %w", err))
+		return summary, false
+	}
+
+	logger.Infof("account deleted: id=%d email=%s by=%d mode=%s", target.ID, target.Email, actorID, summary.Mode)
+
+	// The account is already gone from the app's point of view, so the
+	// remaining cleanup is best effort.
+	if err := s.sessions.DeleteSessionsForUser(target.ID); err != nil {
+		logger.Errorf("delete account %d: revoke sessions: %v", target.ID, err)
+	}
+
+	if req.Purge {
+		files, err := db.PurgeAccountFiles(target.ID)
+		if err != nil {
+			logger.Errorf("delete account %d: purge files: %v", target.ID, err)
+			return summary, true
+		}
+		for _, f := range files {
+			if err := s.fs.Remove(shared.BlobPath(f.UUID)); err != nil && !errors.Is(err, afero.ErrFileNotFound) {
+				logger.Errorf("delete account %d: remove blob %s: %v", target.ID, f.UUID, err)
+			}
+			if err := s.users.UpdateUsage(f.CreatedBy, -f.FileSize); err != nil {
+				logger.Errorf("delete account %d: update usage for user %d: %v", target.ID, f.CreatedBy, err)
+			}
+		}
+	}
+
+	return summary, true
+}
+
+// ExportOwnAccount streams a zip of the authenticated user's whole drive
+// plus a manifest.json of their profile, file metadata and shares.
+func (s *Server) ExportOwnAccount(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", fmt.Errorf("user id not found: %w", err))
+		return
+	}
+
+	u, err := s.users.GetUserByIDStr(userID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	s.exportAccount(c, u, u.ID)
+}
+
+// AdminExportAccount is ExportOwnAccount for another user. Requires an
+// admin caller.
+func (s *Server) AdminExportAccount(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	target, err := s.users.GetUserByIDStr(c.Param("userID"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "user not found", err)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	s.exportAccount(c, target, admin.ID)
+}
+
+// exportAccount streams u's export archive. Files go under files/ with the
+// drive's folder structure (empty folders included as directory entries),
+// and manifest.json is written last so it can list any files that failed
+// to copy. Like the other zip downloads nothing is buffered, so once the
+// headers are sent errors can only be recorded in the manifest.
+func (s *Server) exportAccount(c *gin.Context, u sdk.User, actorID int64) {
+	userID := fmt.Sprintf("%d", u.ID)
+
+	folders, err := db.ListAccountFolders(u.ID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not list folders", err)
+		return
+	}
+	files, err := db.ListAccountFiles(u.ID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not list files", err)
+		return
+	}
+
+	fileShares, err := s.shares.ListSharesByUser(userID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not list shares", err)
+		return
+	}
+	expiredFileShares, err := s.shares.ListExpiredSharesByUser(userID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not list expired shares", err)
+		return
+	}
+	folderShares, err := s.shares.ListShareFoldersByUser(userID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not list folder shares", err)
+		return
+	}
+	expiredFolderShares, err := s.shares.ListExpiredShareFoldersByUser(userID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not list expired folder shares", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	manifest := sdk.ExportManifest{
+		Version:      exportManifestVersion,
+		ExportedAt:   now,
+		User:         u,
+		Folders:      folders,
+		Files:        make([]sdk.ExportFile, 0, len(files)),
+		FileShares:   append(fileShares, expiredFileShares...),
+		FolderShares: append(folderShares, expiredFolderShares...),
+		Errors:       []sdk.ExportError{},
+	}
+	if manifest.Folders == nil {
+		manifest.Folders = []sdk.ExportFolder{}
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", contentDispositionAttachment(fmt.Sprintf("avenue-export-%d-%s.zip", u.ID, now.Format("20060102"))))
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Access-Control-Expose-Headers", "Content-Disposition")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	zw := zip.NewWriter(c.Writer)
+	defer func() {
+		_ = zw.Close()
+	}()
+
+	for _, folder := range folders {
+		if _, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     exportArchivePath(folder.Path, "") + "/",
+			Modified: folder.CreatedAt,
+		}); err != nil {
+			logger.Errorf("export account %d: create folder entry %s: %v", u.ID, folder.UUID, err)
+		}
+	}
+
+	names := make(map[string]int)
+	for _, f := range files {
+		f.ArchivePath = uniqueZipEntryName(names, exportArchivePath(f.Folder, f.Name))
+		if err := s.writeExportFile(zw, f); err != nil {
+			manifest.Errors = append(manifest.Errors, sdk.ExportError{UUID: f.UUID, Path: f.ArchivePath, Error: err.Error()})
+			f.ArchivePath = ""
+		}
+		manifest.Files = append(manifest.Files, f)
+	}
+
+	manifestWriter, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "manifest.json",
+		Method:   zip.Deflate,
+		Modified: now,
+	})
+	if err != nil {
+		logger.Errorf("export account %d: create manifest entry: %v", u.ID, err)
+		return
+	}
+	enc := json.NewEncoder(manifestWriter)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		logger.Errorf("export account %d: write manifest: %v", u.ID, err)
+		return
+	}
+
+	err = db.RecordAuditEvent(actorID, db.AuditAccountExport, u.ID, map[string]any{
+		"files":   len(manifest.Files),
+		"folders": len(manifest.Folders),
+		"errors":  len(manifest.Errors),
+	})
+	if err != nil {
+		logger.Errorf("export account %d: record audit event: %v", u.ID, err)
+	}
+}
+
+// writeExportFile copies f's blob into the archive under f.ArchivePath.
+func (s *Server) writeExportFile(zw *zip.Writer, f sdk.ExportFile) error {
+	fileData, err := s.fs.Open(shared.BlobPath(f.UUID))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fileData.Close()
+	}()
+
+	entryWriter, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     f.ArchivePath,
+		Method:   zip.Deflate,
+		Modified: f.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entryWriter, fileData)
+	return err
+}
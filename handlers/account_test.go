@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"testing"
+
+	"avenue/backend/sdk"
+)
+
+func TestValidateAccountDeletion(t *testing.T) {
+	user := sdk.User{ID: 2, Email: "ada@example.com"}
+	admin := sdk.User{ID: 1, Email: "root@example.com", IsAdmin: true}
+
+	tests := []struct {
+		name           string
+		target         sdk.User
+		req            sdk.DeleteAccountRequest
+		hasOtherAdmins bool
+		wantErr        string
+	}{
+		{
+			name:   "matching email is allowed",
+			target: user,
+			req:    sdk.DeleteAccountRequest{ConfirmEmail: "ada@example.com"},
+		},
+		{
+			name:   "email match ignores case and surrounding space",
+			target: user,
+			req:    sdk.DeleteAccountRequest{ConfirmEmail: " Ada@Example.com "},
+		},
+		{
+			name:    "mismatched email is rejected",
+			target:  user,
+			req:     sdk.DeleteAccountRequest{ConfirmEmail: "someone@example.com"},
+			wantErr: "confirmation email does not match the account",
+		},
+		{
+			name:    "last admin is rejected",
+			target:  admin,
+			req:     sdk.DeleteAccountRequest{ConfirmEmail: "root@example.com"},
+			wantErr: "application requires at least one admin user",
+		},
+		{
+			name:           "admin with another admin is allowed",
+			target:         admin,
+			req:            sdk.DeleteAccountRequest{ConfirmEmail: "root@example.com"},
+			hasOtherAdmins: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAccountDeletion(tt.target, tt.req, tt.hasOtherAdmins)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExportArchivePath(t *testing.T) {
+	tests := []struct {
+		folder string
+		name   string
+		want   string
+	}{
+		{folder: "", name: "notes.txt", want: "files/notes.txt"},
+		{folder: "Photos", name: "cat.jpg", want: "files/Photos/cat.jpg"},
+		{folder: "Photos/2024", name: "dog.jpg", want: "files/Photos/2024/dog.jpg"},
+		{folder: "Photos", name: "", want: "files/Photos"},
+	}
+
+	for _, tt := range tests {
+		if got := exportArchivePath(tt.folder, tt.name); got != tt.want {
+			t.Errorf("exportArchivePath(%q, %q) = %q, want %q", tt.folder, tt.name, got, tt.want)
+		}
+	}
+}
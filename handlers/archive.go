@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+// Names of the bookkeeping entries written at the root of every download
+// archive. They're reserved up front so a user file with the same name gets
+// disambiguated instead of colliding.
+const (
+	archiveManifestName = "manifest.json"
+	archiveErrorsName   = "errors.txt"
+)
+
+// archiveWriter is the common surface of the zip and tar writers used to
+// stream download archives. Entries are written one at a time; tar needs
+// each entry's size before its contents, so Create takes it up front.
+type archiveWriter interface {
+	Create(name string, size int64, modified time.Time) (io.Writer, error)
+	Close() error
+}
+
+// archiveFormatInfo describes how an archive format is served.
+type archiveFormatInfo struct {
+	Extension   string
+	ContentType string
+}
+
+var archiveFormats = map[string]archiveFormatInfo{
+	sdk.ArchiveFormatZip:      {Extension: ".zip", ContentType: "application/zip"},
+	sdk.ArchiveFormatZipStore: {Extension: ".zip", ContentType: "application/zip"},
+	sdk.ArchiveFormatTar:      {Extension: ".tar", ContentType: "application/x-tar"},
+	sdk.ArchiveFormatTarGz:    {Extension: ".tar.gz", ContentType: "application/gzip"},
+}
+
+// parseArchiveFormat validates a requested archive format, defaulting to
+// a deflated zip when none is given.
+func parseArchiveFormat(format string) (string, archiveFormatInfo, error) {
+	if format == "" {
+		format = sdk.ArchiveFormatZip
+	}
+	info, ok := archiveFormats[format]
+	if !ok {
+		return "", archiveFormatInfo{}, fmt.Errorf("unsupported archive format %q", format)
+	}
+	return format, info, nil
+}
+
+// newArchiveWriter returns a writer producing format into w. format must
+// have been validated with parseArchiveFormat.
+func newArchiveWriter(format string, w io.Writer) archiveWriter {
+	switch format {
+	case sdk.ArchiveFormatZipStore:
+		return &zipArchive{zw: zip.NewWriter(w), method: zip.Store}
+	case sdk.ArchiveFormatTar:
+		return &tarArchive{tw: tar.NewWriter(w)}
+	case sdk.ArchiveFormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarArchive{tw: tar.NewWriter(gz), gz: gz}
+	default:
+		return &zipArchive{zw: zip.NewWriter(w), method: zip.Deflate}
+	}
+}
+
+type zipArchive struct {
+	zw     *zip.Writer
+	method uint16
+}
+
+func (a *zipArchive) Create(name string, _ int64, modified time.Time) (io.Writer, error) {
+	return a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   a.method,
+		Modified: modified,
+	})
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+type tarArchive struct {
+	tw *tar.Writer
+	gz *gzip.Writer // nil for an uncompressed tar
+
+	// remaining is how much of the current entry's declared size hasn't
+	// been written yet. A blob that fails mid-copy is padded out with zeros
+	// so the entries after it (and the manifest) still land intact.
+	remaining int64
+}
+
+func (a *tarArchive) Create(name string, size int64, modified time.Time) (io.Writer, error) {
+	if err := a.pad(); err != nil {
+		return nil, err
+	}
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  modified,
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.remaining = size
+	return a, nil
+}
+
+func (a *tarArchive) Write(p []byte) (int, error) {
+	n, err := a.tw.Write(p)
+	a.remaining -= int64(n)
+	return n, err
+}
+
+func (a *tarArchive) pad() error {
+	if a.remaining <= 0 {
+		return nil
+	}
+	_, err := io.CopyN(a, zeroReader{}, a.remaining)
+	return err
+}
+
+func (a *tarArchive) Close() error {
+	err := a.pad()
+	if closeErr := a.tw.Close(); err == nil {
+		err = closeErr
+	}
+	if a.gz != nil {
+		if gzErr := a.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// writeArchiveBytes adds an in-memory entry such as the manifest.
+func writeArchiveBytes(aw archiveWriter, name string, data []byte, modified time.Time) error {
+	w, err := aw.Create(name, int64(len(data)), modified)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/spf13/afero"
+)
+
+func TestParseArchiveFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantExt string
+		wantErr bool
+	}{
+		{in: "", want: sdk.ArchiveFormatZip, wantExt: ".zip"},
+		{in: "zip", want: sdk.ArchiveFormatZip, wantExt: ".zip"},
+		{in: "zip-store", want: sdk.ArchiveFormatZipStore, wantExt: ".zip"},
+		{in: "tar", want: sdk.ArchiveFormatTar, wantExt: ".tar"},
+		{in: "tar.gz", want: sdk.ArchiveFormatTarGz, wantExt: ".tar.gz"},
+		{in: "rar", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, info, err := parseArchiveFormat(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseArchiveFormat(%q): expected error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseArchiveFormat(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want || info.Extension != tt.wantExt {
+			t.Errorf("parseArchiveFormat(%q) = %q, %q; want %q, %q", tt.in, got, info.Extension, tt.want, tt.wantExt)
+		}
+	}
+}
+
+// readArchive returns the name → contents of every entry in an archive
+// produced by newArchiveWriter.
+func readArchive(t *testing.T, format string, data []byte) map[string]string {
+	t.Helper()
+	out := make(map[string]string)
+
+	switch format {
+	case sdk.ArchiveFormatZip, sdk.ArchiveFormatZipStore:
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("open zip: %v", err)
+		}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("open %s: %v", f.Name, err)
+			}
+			b, _ := io.ReadAll(rc)
+			_ = rc.Close()
+			out[f.Name] = string(b)
+		}
+	default:
+		var r io.Reader = bytes.NewReader(data)
+		if format == sdk.ArchiveFormatTarGz {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				t.Fatalf("open gzip: %v", err)
+			}
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("read tar: %v", err)
+			}
+			b, _ := io.ReadAll(tr)
+			out[hdr.Name] = string(b)
+		}
+	}
+	return out
+}
+
+func TestArchiveWriterRoundTrip(t *testing.T) {
+	entries := map[string]string{
+		"notes.txt":           "hello",
+		"Photos/2024/a.jpg":   "not really a jpeg",
+		archiveManifestName:   `{"entries":[]}`,
+		"Photos/empty.bin":    "",
+		"Dokumente/Übersicht": "unicode names need PAX headers in tar",
+	}
+
+	for _, format := range []string{sdk.ArchiveFormatZip, sdk.ArchiveFormatZipStore, sdk.ArchiveFormatTar, sdk.ArchiveFormatTarGz} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			aw := newArchiveWriter(format, &buf)
+			for name, content := range entries {
+				if err := writeArchiveBytes(aw, name, []byte(content), time.Now()); err != nil {
+					t.Fatalf("write %s: %v", name, err)
+				}
+			}
+			if err := aw.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+
+			got := readArchive(t, format, buf.Bytes())
+			if len(got) != len(entries) {
+				t.Fatalf("got %d entries, want %d", len(got), len(entries))
+			}
+			for name, content := range entries {
+				if got[name] != content {
+					t.Errorf("entry %q = %q, want %q", name, got[name], content)
+				}
+			}
+		})
+	}
+}
+
+func TestTarArchivePadsShortEntry(t *testing.T) {
+	var buf bytes.Buffer
+	aw := newArchiveWriter(sdk.ArchiveFormatTar, &buf)
+
+	w, err := aw.Create("short.txt", 5, time.Now())
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := w.Write([]byte("abc")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := writeArchiveBytes(aw, "next.txt", []byte("ok"), time.Now()); err != nil {
+		t.Fatalf("write after short entry: %v", err)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	got := readArchive(t, sdk.ArchiveFormatTar, buf.Bytes())
+	if got["short.txt"] != "abc\x00\x00" {
+		t.Errorf("short.txt = %q, want zero padding", got["short.txt"])
+	}
+	if got["next.txt"] != "ok" {
+		t.Errorf("next.txt = %q, want %q", got["next.txt"], "ok")
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := &Server{fs: fs}
+
+	present := "aaaa1111-0000-0000-0000-000000000000"
+	missing := "bbbb2222-0000-0000-0000-000000000000"
+	if err := shared.EnsureBlobDir(fs, present); err != nil {
+		t.Fatal(err)
+	}
+	if err := afero.WriteFile(fs, shared.BlobPath(present), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := []archiveSource{
+		{UUID: present, Path: "notes.txt"},
+		{UUID: missing, Path: "gone.txt"},
+		{UUID: present, Path: "notes.txt"},
+	}
+
+	var buf bytes.Buffer
+	aw := newArchiveWriter(sdk.ArchiveFormatZip, &buf)
+	added := 0
+	manifest, err := s.writeArchive(context.Background(), aw, sdk.ArchiveFormatZip, sources, func() { added++ })
+	if err != nil {
+		t.Fatalf("writeArchive: %v", err)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if added != len(sources) {
+		t.Errorf("added called %d times, want %d", added, len(sources))
+	}
+	if len(manifest.Entries) != 2 || len(manifest.Errors) != 1 {
+		t.Fatalf("manifest has %d entries and %d errors, want 2 and 1", len(manifest.Entries), len(manifest.Errors))
+	}
+
+	got := readArchive(t, sdk.ArchiveFormatZip, buf.Bytes())
+	for _, name := range []string{"notes.txt", "notes (1).txt", archiveErrorsName, archiveManifestName} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("archive is missing %q", name)
+		}
+	}
+	var written sdk.ArchiveManifest
+	if err := json.Unmarshal([]byte(got[archiveManifestName]), &written); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if len(written.Entries) != 2 {
+		t.Errorf("written manifest has %d entries, want 2", len(written.Entries))
+	}
+}
+
+func TestWriteArchiveStopsWhenCanceled(t *testing.T) {
+	s := &Server{fs: afero.NewMemMapFs()}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	aw := newArchiveWriter(sdk.ArchiveFormatTar, io.Discard)
+	_, err := s.writeArchive(ctx, aw, sdk.ArchiveFormatTar, []archiveSource{{UUID: "cccc3333", Path: "a.txt"}}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"avenue/backend/sdk"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminGetConfig shows the effective configuration the server started
+// with, so an admin can check what a deployment's file and env vars added
+// up to. Secrets are redacted. Requires an admin caller.
+func (s *Server) AdminGetConfig(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, sdk.V1AdminConfigResponse{
+		File:   s.cfg.File,
+		Config: s.cfg.Redacted(),
+	})
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"avenue/backend/db"
+	"avenue/backend/jobs"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// copyFolderTree is a folder subtree queued for copying.
+type copyFolderTree struct {
+	Root    sdk.Folder
+	Folders []db.CopySourceFolder
+	Files   []db.CopySourceFile
+}
+
+// copyPlan is everything a bulk copy will create, gathered up front so the
+// quota can be checked before anything is written.
+type copyPlan struct {
+	Dest       sdk.Folder
+	Files      []*sdk.File
+	Trees      []copyFolderTree
+	TotalFiles int
+	TotalBytes int64
+}
+
+// copyRequestError is a problem with the copy request itself, such as an
+// ID that doesn't exist, rather than with carrying it out.
+type copyRequestError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (e *copyRequestError) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+	return e.message + ": " + e.err.Error()
+}
+
+func (e *copyRequestError) Unwrap() error { return e.err }
+
+// copier carries out a copyPlan. Blobs are cloned rather than shared, since
+// purging a file removes its blob. Progress counts files copied.
+type copier struct {
+	s        *Server
+	ctx      context.Context
+	run      *jobs.Run
+	userID   int64
+	dest     sdk.Folder
+	rollback driveRollback
+
+	copied int
+	bytes  int64
+}
+
+// copyAll copies everything in plan. On failure, cancellation included,
+// everything created so far is removed again.
+func (cp *copier) copyAll(plan copyPlan) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("copy panicked: %v", r)
+		}
+		if err != nil {
+			cp.rollback.undo(cp.s, fmt.Sprintf("copy job %d", cp.run.Job().ID))
+			cp.copied, cp.bytes = 0, 0
+		}
+	}()
+
+	for _, f := range plan.Files {
+		err := cp.copyFile(db.CopySourceFile{
+			UUID:      f.UUID,
+			Name:      f.Name,
+			Extension: f.Extension,
+			MimeType:  f.MimeType,
+			FileSize:  f.FileSize,
+			Checksum:  f.Checksum,
+		}, cp.dest.UUID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, tree := range plan.Trees {
+		if err := cp.copyTree(tree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTree recreates tree under dest. The copied root is renamed with the
+// " (N)" scheme if dest already has a folder of that name; everything
+// below it lands in fresh folders and can't clash.
+func (cp *copier) copyTree(tree copyFolderTree) error {
+	name, err := db.NextAvailableFolderName(cp.dest.ID, cp.userID, tree.Root.Name)
+	if err != nil {
+		return err
+	}
+
+	root := sdk.Folder{Name: name, OwnerID: cp.userID, ParentID: cp.dest.ID}
+	if _, err := cp.s.folders.CreateFolder(&root); err != nil {
+		return fmt.Errorf("create folder %s: %w", name, err)
+	}
+	cp.rollback.addFolder(root.ID)
+
+	copies := map[int64]sdk.Folder{tree.Root.ID: root}
+	for _, src := range tree.Folders {
+		if err := cp.ctx.Err(); err != nil {
+			return err
+		}
+		parent, ok := copies[src.ParentID]
+		if !ok {
+			return fmt.Errorf("folder %d copied before its parent", src.ID)
+		}
+		f := sdk.Folder{Name: src.Name, OwnerID: cp.userID, ParentID: parent.ID}
+		if _, err := cp.s.folders.CreateFolder(&f); err != nil {
+			return fmt.Errorf("create folder %s: %w", src.Name, err)
+		}
+		cp.rollback.addFolder(f.ID)
+		copies[src.ID] = f
+	}
+
+	for _, src := range tree.Files {
+		parent, ok := copies[src.FolderID]
+		if !ok {
+			return fmt.Errorf("file %s is outside the copied folder", src.UUID)
+		}
+		if err := cp.copyFile(src, parent.UUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile clones src's blob and creates a file row for it in parent,
+// charged to the copying user. CreateFile applies the usual " (N)" suffix
+// when parent already has a file of the same name.
+func (cp *copier) copyFile(src db.CopySourceFile, parent string) error {
+	if err := cp.ctx.Err(); err != nil {
+		return err
+	}
+
+	fileID := uuid.NewString()
+	size, err := cp.s.cloneBlob(src.UUID, fileID)
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", src.Name, err)
+	}
+
+	file := sdk.File{
+		UUID:      fileID,
+		Name:      src.Name,
+		Extension: src.Extension,
+		MimeType:  src.MimeType,
+		FileSize:  size,
+		Checksum:  src.Checksum,
+		Parent:    parent,
+		CreatedBy: cp.userID,
+	}
+	if _, err := cp.s.files.CreateFile(&file); err != nil {
+		_ = cp.s.fs.Remove(shared.BlobPath(fileID))
+		return fmt.Errorf("create file %s: %w", src.Name, err)
+	}
+	cp.rollback.addFile(file)
+
+	cp.copied++
+	cp.bytes += size
+	cp.run.Add(1)
+	return nil
+}
+
+// cloneBlob copies the blob of srcUUID to a new blob for dstUUID and
+// returns its size.
+func (s *Server) cloneBlob(srcUUID, dstUUID string) (int64, error) {
+	src, err := s.fs.Open(shared.BlobPath(srcUUID))
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if err := shared.EnsureBlobDir(s.fs, dstUUID); err != nil {
+		return 0, err
+	}
+	dstPath := shared.BlobPath(dstUUID)
+	dst, err := s.fs.Create(dstPath)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = s.fs.Remove(dstPath)
+		return 0, err
+	}
+	return n, nil
+}
+
+// planCopy resolves req's files and folders for userID into a copyPlan.
+// Problems with the request itself come back as a *copyRequestError.
+func (s *Server) planCopy(req sdk.BulkCopyRequest, userID string) (copyPlan, error) {
+	var plan copyPlan
+	if req.Parent != "" {
+		folder, err := s.folders.GetFolder(req.Parent, userID)
+		if err != nil {
+			return plan, &copyRequestError{status: http.StatusBadRequest, message: "destination folder must exist", err: err}
+		}
+		plan.Dest = *folder
+	}
+
+	for _, id := range req.FileIDs {
+		file, err := s.files.GetFileByIDForUser(id, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return plan, &copyRequestError{status: http.StatusNotFound, message: fmt.Sprintf("file not found in db: %s", id), err: err}
+			}
+			return plan, fmt.Errorf("get file: %w", err)
+		}
+		plan.Files = append(plan.Files, file)
+		plan.TotalFiles++
+		plan.TotalBytes += file.FileSize
+	}
+
+	for _, id := range req.FolderIDs {
+		folder, err := s.folders.GetFolder(id, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return plan, &copyRequestError{status: http.StatusNotFound, message: fmt.Sprintf("folder not found in db: %s", id), err: err}
+			}
+			return plan, fmt.Errorf("get folder: %w", err)
+		}
+
+		if req.Parent != "" {
+			inSubtree, err := s.shares.IsFolderInSubtree(folder.ID, req.Parent)
+			if err != nil {
+				return plan, fmt.Errorf("check destination folder: %w", err)
+			}
+			if inSubtree {
+				return plan, &copyRequestError{status: http.StatusBadRequest, message: "cannot copy a folder into itself or one of its own subfolders"}
+			}
+		}
+
+		folders, files, err := db.ListFolderSubtreeForCopy(folder.ID)
+		if err != nil {
+			return plan, fmt.Errorf("list folder contents: %w", err)
+		}
+		plan.Trees = append(plan.Trees, copyFolderTree{Root: *folder, Folders: folders, Files: files})
+		plan.TotalFiles += len(files)
+		for _, f := range files {
+			plan.TotalBytes += f.FileSize
+		}
+	}
+	return plan, nil
+}
+
+// checkCopyQuota fails with errQuotaExceeded if plan won't fit in userID's
+// quota, their groups' quotas or those of the destination folder.
+func (s *Server) checkCopyQuota(userID int64, plan copyPlan) error {
+	status, err := s.quotaStatus(userID)
+	if err != nil {
+		return fmt.Errorf("get usage: %w", err)
+	}
+	if status.Limit != 0 && status.Used+plan.TotalBytes > status.Limit {
+		return fmt.Errorf("%w: not enough room left to copy these items", errQuotaExceeded)
+	}
+	if err := s.checkGroupQuota(userID, plan.TotalBytes); err != nil {
+		return err
+	}
+	return s.checkFolderQuota(plan.Dest.UUID, plan.TotalBytes)
+}
+
+// copyJob carries out a bulk copy. The request is resolved and the quota
+// checked again, since either may have changed since it was enqueued. A
+// failed attempt removes what it copied, so it's safe to retry.
+func (s *Server) copyJob(ctx context.Context, run *jobs.Run, req sdk.BulkCopyRequest) (any, error) {
+	userID := run.Job().UserID
+
+	plan, err := s.planCopy(req, strconv.FormatInt(userID, 10))
+	if err != nil {
+		var reqErr *copyRequestError
+		if errors.As(err, &reqErr) {
+			return nil, jobs.Permanent(err)
+		}
+		return nil, err
+	}
+	if err := s.checkCopyQuota(userID, plan); err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			return nil, jobs.Permanent(err)
+		}
+		return nil, err
+	}
+	run.SetTotal(int64(plan.TotalFiles))
+
+	cp := &copier{s: s, ctx: ctx, run: run, userID: userID, dest: plan.Dest, rollback: driveRollback{userID: userID}}
+	if err := cp.copyAll(plan); err != nil {
+		return nil, err
+	}
+	if cp.bytes > 0 {
+		s.checkGroupSoftQuotas(userID)
+	}
+	return sdk.CopyResult{Files: cp.copied, Bytes: cp.bytes}, nil
+}
+
+// BulkCopy recursively copies files and folders into a destination folder
+// as a files.copy job. Copies belong to, and count against the quota of,
+// the calling user. The request is checked, quota included, before the job
+// is enqueued, so a bad ID or a copy that won't fit fails straight away.
+func (s *Server) BulkCopy(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not get user id", err)
+		return
+	}
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	var req sdk.BulkCopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "could not marshal all data to json", err)
+		return
+	}
+
+	if len(req.FileIDs) == 0 && len(req.FolderIDs) == 0 {
+		respond(c, http.StatusBadRequest, "no file or folder ids provided", nil)
+		return
+	}
+
+	plan, err := s.planCopy(req, userID)
+	if err != nil {
+		var reqErr *copyRequestError
+		if errors.As(err, &reqErr) {
+			respond(c, reqErr.status, reqErr.message, reqErr.err)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "could not plan copy", err)
+		return
+	}
+	if err := s.checkCopyQuota(userIDInt, plan); err != nil {
+		respondQuotaErr(c, err)
+		return
+	}
+
+	s.startJob(c, userIDInt, sdk.JobKindCopy, req, "could not copy items")
+}
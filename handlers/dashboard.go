@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"avenue/backend/db"
 	"avenue/backend/sdk"
 	"avenue/backend/shared"
 	"net/http"
@@ -10,23 +9,25 @@ import (
 )
 
 func (s *Server) DashboardInfo(c *gin.Context) {
-	serverMax := shared.GetEnvInt64("MAX_FILE_BYTE_SIZE", shared.DEFAULTMAXFILESIZE)
+	serverMax := s.cfg.Storage.MaxFileSize
 	maxFileSize := serverMax
 
 	userID, err := shared.GetUserIDFromContext(c.Request.Context())
 	if err == nil {
-		user, err := db.GetUserByIDStr(userID)
-		if err == nil && user.Quota > 0 {
-			remaining := user.Quota - user.SpaceUsed
-			if remaining < maxFileSize {
-				maxFileSize = remaining
+		user, err := s.users.GetUserByIDStr(userID)
+		if err == nil {
+			if status, err := s.quotaStatus(user.ID); err == nil && status.Limit > 0 {
+				remaining := status.Limit - status.Used
+				if remaining < maxFileSize {
+					maxFileSize = remaining
+				}
 			}
 		}
 	}
 
 	c.JSON(http.StatusOK, sdk.V1DashboardResponse{
 		MaxFileSize:          maxFileSize,
-		FileSharingEnabled:   shared.GetEnvBool("ENABLE_FILE_SHARING", false),
-		FolderSharingEnabled: shared.GetEnvBool("ENABLE_FOLDER_SHARING", false),
+		FileSharingEnabled:   s.cfg.Server.FileSharing,
+		FolderSharingEnabled: s.cfg.Server.FolderSharing,
 	})
 }
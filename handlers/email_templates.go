@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"avenue/backend/email"
+	"avenue/backend/sdk"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListEmailTemplates — GET /v1/admin/email-templates
+func (s *Server) ListEmailTemplates(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, sdk.V1EmailTemplatesResponse{Templates: email.TemplateNames()})
+}
+
+// PreviewEmailTemplate — GET /v1/admin/email-templates/:name/preview
+//
+// Renders a template with sample data using the active template set, so
+// admins can check their overrides. ?format=html or ?format=text returns
+// just that body (viewable directly in a browser); the default is JSON with
+// the subject and both bodies.
+func (s *Server) PreviewEmailTemplate(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	name := c.Param("name")
+	data, ok := email.SampleData(name)
+	if !ok {
+		respond(c, http.StatusNotFound, "template not found", nil)
+		return
+	}
+
+	r, err := email.Render(name, data)
+	if err != nil {
+		// Almost always a syntax error in an override, which is exactly
+		// what the admin is here to find out about.
+		respond(c, http.StatusUnprocessableEntity, "", fmt.Errorf("render template: %w", err))
+		return
+	}
+
+	switch c.Query("format") {
+	case sdk.EmailFormatHTML:
+		if r.HTML == "" {
+			respond(c, http.StatusNotFound, "", errors.New("template has no html body"))
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(r.HTML))
+	case sdk.EmailFormatText:
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(r.Text))
+	default:
+		c.JSON(http.StatusOK, sdk.EmailTemplatePreview{
+			Name:    name,
+			Subject: r.Subject,
+			HTML:    r.HTML,
+			Text:    r.Text,
+		})
+	}
+}
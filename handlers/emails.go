@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"avenue/backend/db"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListOutboxEmails — GET /v1/admin/emails?status=dead&page=1&limit=50
+func (s *Server) ListOutboxEmails(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", sdk.EmailStatusPending, sdk.EmailStatusSending, sdk.EmailStatusSent, sdk.EmailStatusDead:
+	default:
+		respond(c, http.StatusBadRequest, "", fmt.Errorf("invalid status %q", status))
+		return
+	}
+
+	page, limit, offset := shared.ParsePagination(c.Query("page"), c.Query("limit"))
+
+	emails, err := db.ListOutboxEmails(status, limit, offset)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("list emails: %w", err))
+		return
+	}
+	total, err := db.CountOutboxEmails(status)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("count emails: %w", err))
+		return
+	}
+	if emails == nil {
+		emails = []sdk.OutboxEmail{}
+	}
+
+	c.JSON(http.StatusOK, sdk.V1OutboxEmailsResponse{
+		Emails: emails,
+		Page:   page,
+		Limit:  limit,
+		Total:  total,
+	})
+}
+
+// GetOutboxEmail — GET /v1/admin/emails/:emailID
+func (s *Server) GetOutboxEmail(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("emailID"), 10, 64)
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", errors.New("invalid email id"))
+		return
+	}
+
+	e, err := db.GetOutboxEmail(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "email not found", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get email: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, e)
+}
+
+// RetryOutboxEmail — POST /v1/admin/emails/:emailID/retry
+//
+// Requeues an email waiting to be retried for immediate delivery with a
+// fresh attempt budget. Dead emails have had their bodies dropped, so they
+// can't be.
+func (s *Server) RetryOutboxEmail(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("emailID"), 10, 64)
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", errors.New("invalid email id"))
+		return
+	}
+
+	if err := db.RetryOutboxEmail(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusConflict, "", errors.New("email not found, or not waiting to be retried"))
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("retry email: %w", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,520 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"avenue/backend/jobs"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/spf13/afero"
+)
+
+// extractLimits bounds what a single archive may expand to, guarding
+// against zip bombs. Declared sizes are checked before anything is written
+// and actual sizes are enforced while copying, since headers can lie.
+type extractLimits struct {
+	MaxEntries   int
+	MaxBytes     int64
+	MaxFileBytes int64
+}
+
+func (s *Server) extractLimits() extractLimits {
+	return extractLimits{
+		MaxEntries:   s.cfg.Storage.ExtractMaxEntries,
+		MaxBytes:     s.cfg.Storage.ExtractMaxBytes,
+		MaxFileBytes: s.cfg.Storage.MaxFileSize,
+	}
+}
+
+// detectArchiveFormat picks the archive format from a stored file's name.
+func detectArchiveFormat(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return sdk.ArchiveFormatZip, true
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return sdk.ArchiveFormatTarGz, true
+	case strings.HasSuffix(lower, ".tar"):
+		return sdk.ArchiveFormatTar, true
+	}
+	return "", false
+}
+
+// cleanArchivePath normalises an entry name from an archive into a
+// slash-separated path relative to the extraction folder. Names that are
+// absolute or climb out with ".." are rejected outright (zip-slip) rather
+// than clamped, since they only show up in hostile or broken archives. An
+// empty result means the entry is the archive root itself.
+func cleanArchivePath(name string) (string, error) {
+	if strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("invalid entry name %q", name)
+	}
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", fmt.Errorf("entry %q has an absolute path", name)
+	}
+
+	parts := make([]string, 0, strings.Count(name, "/")+1)
+	for _, part := range strings.Split(name, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", fmt.Errorf("entry %q points outside the archive", name)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+type archiveEntryKind int
+
+const (
+	archiveEntryFile archiveEntryKind = iota
+	archiveEntryDir
+	// archiveEntryOther covers symlinks, hard links, devices and the like,
+	// which are never extracted.
+	archiveEntryOther
+)
+
+type archiveEntryHeader struct {
+	Name string
+	Kind archiveEntryKind
+	Size int64
+}
+
+// walkArchive calls fn for every entry of the archive stored at blobPath.
+// r is only set for regular files and is valid until fn returns.
+func walkArchive(fsys afero.Fs, blobPath, format string, fn func(h archiveEntryHeader, r io.Reader) error) error {
+	f, err := fsys.Open(blobPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if format == sdk.ArchiveFormatZip {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return fmt.Errorf("read zip: %w", err)
+		}
+		for _, zf := range zr.File {
+			h := archiveEntryHeader{Name: zf.Name, Size: int64(zf.UncompressedSize64)}
+			switch mode := zf.Mode(); {
+			case mode.IsDir():
+				h.Kind = archiveEntryDir
+			case mode.IsRegular():
+				h.Kind = archiveEntryFile
+			default:
+				h.Kind = archiveEntryOther
+			}
+			if h.Kind != archiveEntryFile {
+				if err := fn(h, nil); err != nil {
+					return err
+				}
+				continue
+			}
+
+			rc, err := zf.Open()
+			if err != nil {
+				return fmt.Errorf("open %s: %w", zf.Name, err)
+			}
+			err = fn(h, rc)
+			_ = rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = f
+	if format == sdk.ArchiveFormatTarGz {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("read gzip: %w", err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		h := archiveEntryHeader{Name: hdr.Name, Size: hdr.Size}
+		var body io.Reader
+		switch {
+		case hdr.Typeflag == tar.TypeDir:
+			h.Kind = archiveEntryDir
+		case hdr.FileInfo().Mode().IsRegular():
+			h.Kind = archiveEntryFile
+			body = tr
+		default:
+			h.Kind = archiveEntryOther
+		}
+		if err := fn(h, body); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveScan is what a first pass over an archive found.
+type archiveScan struct {
+	Entries int
+	Skipped int
+	Bytes   int64
+}
+
+// scanArchive validates every entry name and totals the archive's declared
+// size without extracting anything, failing as soon as a limit is crossed.
+func scanArchive(fsys afero.Fs, blobPath, format string, limits extractLimits) (archiveScan, error) {
+	var scan archiveScan
+	err := walkArchive(fsys, blobPath, format, func(h archiveEntryHeader, _ io.Reader) error {
+		if h.Kind == archiveEntryOther {
+			scan.Skipped++
+			return nil
+		}
+		if _, err := cleanArchivePath(h.Name); err != nil {
+			return err
+		}
+
+		scan.Entries++
+		if scan.Entries > limits.MaxEntries {
+			return fmt.Errorf("archive has more than %d entries", limits.MaxEntries)
+		}
+		if h.Kind != archiveEntryFile {
+			return nil
+		}
+
+		if h.Size < 0 || h.Size > limits.MaxFileBytes {
+			return fmt.Errorf("entry %q is larger than the %d byte file limit", h.Name, limits.MaxFileBytes)
+		}
+		scan.Bytes += h.Size
+		if scan.Bytes > limits.MaxBytes {
+			return fmt.Errorf("archive expands to more than %d bytes", limits.MaxBytes)
+		}
+		return nil
+	})
+	return scan, err
+}
+
+// errInvalidArchive wraps the reasons an archive can't be extracted at all:
+// a hostile or broken entry, or one past the limits. The extraction job
+// fails for good on it rather than being retried.
+var errInvalidArchive = errors.New("invalid archive")
+
+// extractPayload is the payload of a files.extract job.
+type extractPayload struct {
+	FileID string `json:"fileId"`
+	Folder string `json:"folder"`
+}
+
+// checkExtraction scans archive and checks that what it expands to fits in
+// userID's quota, their groups' quotas and those of target. Archives that
+// can't be extracted fail with errInvalidArchive, and ones that won't fit
+// with errQuotaExceeded.
+func (s *Server) checkExtraction(userID int64, archive *sdk.File, format string, target sdk.Folder) (archiveScan, error) {
+	scan, err := scanArchive(s.fs, shared.BlobPath(archive.UUID), format, s.extractLimits())
+	if err != nil {
+		return scan, fmt.Errorf("%w: %w", errInvalidArchive, err)
+	}
+
+	status, err := s.quotaStatus(userID)
+	if err != nil {
+		return scan, fmt.Errorf("get usage: %w", err)
+	}
+	if status.Limit != 0 && status.Used+scan.Bytes > status.Limit {
+		return scan, fmt.Errorf("%w: archive expands to %d bytes, more than the %d bytes left in your quota", errQuotaExceeded, scan.Bytes, max(status.Limit-status.Used, 0))
+	}
+	if err := s.checkGroupQuota(userID, scan.Bytes); err != nil {
+		return scan, err
+	}
+	if err := s.checkFolderQuota(target.UUID, scan.Bytes); err != nil {
+		return scan, err
+	}
+	return scan, nil
+}
+
+// extraction is the state of one running extraction job. Progress counts
+// entries processed.
+type extraction struct {
+	s      *Server
+	ctx    context.Context
+	run    *jobs.Run
+	userID int64
+
+	// folders maps a cleaned directory path to the folder created for it;
+	// "" is the target folder (zero for the drive root).
+	folders  map[string]sdk.Folder
+	rollback driveRollback
+
+	processed int
+	written   int64
+}
+
+// extractJob unpacks an archive into its target folder. It scans the whole
+// archive and checks the user's, group and folder quotas before writing
+// anything; if extraction then fails part way, or is canceled, everything
+// it created is removed again so the drive is left as it was and the job
+// can be retried.
+func (s *Server) extractJob(ctx context.Context, run *jobs.Run, p extractPayload) (any, error) {
+	userID := run.Job().UserID
+	userIDStr := strconv.FormatInt(userID, 10)
+
+	archive, err := s.files.GetFileByIDForUser(p.FileID, userIDStr)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, jobs.Permanent(fmt.Errorf("archive %s not found", p.FileID))
+		}
+		return nil, err
+	}
+	format, ok := detectArchiveFormat(archive.Name)
+	if !ok {
+		return nil, jobs.Permanent(fmt.Errorf("%s is not a zip, tar or tar.gz archive", archive.Name))
+	}
+	var target sdk.Folder
+	if p.Folder != "" {
+		folder, err := s.folders.GetFolder(p.Folder, userIDStr)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, jobs.Permanent(fmt.Errorf("folder %s not found", p.Folder))
+			}
+			return nil, err
+		}
+		target = *folder
+	}
+
+	scan, err := s.checkExtraction(userID, archive, format, target)
+	if err != nil {
+		if errors.Is(err, errInvalidArchive) || errors.Is(err, errQuotaExceeded) {
+			return nil, jobs.Permanent(err)
+		}
+		return nil, err
+	}
+	run.SetTotal(int64(scan.Entries))
+
+	x := &extraction{
+		s:        s,
+		ctx:      ctx,
+		run:      run,
+		userID:   userID,
+		folders:  map[string]sdk.Folder{"": target},
+		rollback: driveRollback{userID: userID},
+	}
+	if err := x.extract(archive, format); err != nil {
+		x.rollback.undo(s, fmt.Sprintf("extraction job %d", run.Job().ID))
+		if errors.Is(err, errInvalidArchive) {
+			return nil, jobs.Permanent(err)
+		}
+		return nil, err
+	}
+	if x.written > 0 {
+		s.checkGroupSoftQuotas(userID)
+	}
+	return sdk.ExtractResult{Entries: x.processed, Skipped: scan.Skipped, Bytes: x.written}, nil
+}
+
+func (x *extraction) extract(archive *sdk.File, format string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("extraction panicked: %v", r)
+		}
+	}()
+
+	return walkArchive(x.s.fs, shared.BlobPath(archive.UUID), format, func(h archiveEntryHeader, r io.Reader) error {
+		if err := x.ctx.Err(); err != nil {
+			return err
+		}
+		if h.Kind == archiveEntryOther {
+			return nil
+		}
+		name, err := cleanArchivePath(h.Name)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidArchive, err)
+		}
+
+		if h.Kind == archiveEntryDir {
+			if name != "" {
+				if _, err := x.ensureFolder(name); err != nil {
+					return err
+				}
+			}
+		} else if err := x.extractFile(name, h.Size, r); err != nil {
+			return err
+		}
+
+		x.processed++
+		x.run.Add(1)
+		return nil
+	})
+}
+
+// ensureFolder returns the folder for dir, creating it (and any missing
+// parents) under the target folder on first use.
+func (x *extraction) ensureFolder(dir string) (sdk.Folder, error) {
+	if f, ok := x.folders[dir]; ok {
+		return f, nil
+	}
+
+	parentDir := path.Dir(dir)
+	if parentDir == "." {
+		parentDir = ""
+	}
+	parent, err := x.ensureFolder(parentDir)
+	if err != nil {
+		return sdk.Folder{}, err
+	}
+
+	f := sdk.Folder{Name: path.Base(dir), OwnerID: x.userID, ParentID: parent.ID}
+	if _, err := x.s.folders.CreateFolder(&f); err != nil {
+		return sdk.Folder{}, fmt.Errorf("create folder %s: %w", dir, err)
+	}
+	x.rollback.addFolder(f.ID)
+	x.folders[dir] = f
+	return f, nil
+}
+
+// extractFile writes one archive entry to a new blob and file row. At most
+// size bytes are accepted, so an entry that expands beyond its declared
+// size fails the job instead of filling the disk.
+func (x *extraction) extractFile(name string, size int64, r io.Reader) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		dir = ""
+	}
+	folder, err := x.ensureFolder(dir)
+	if err != nil {
+		return err
+	}
+
+	fileID := uuid.NewString()
+	if err := shared.EnsureBlobDir(x.s.fs, fileID); err != nil {
+		return err
+	}
+	dstPath := shared.BlobPath(fileID)
+	dst, err := x.s.fs.Create(dstPath)
+	if err != nil {
+		return err
+	}
+
+	hasher := sha256.New()
+	sniff := &shared.SniffWriter{}
+	n, err := io.Copy(io.MultiWriter(dst, hasher, sniff), io.LimitReader(r, size+1))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > size {
+		err = fmt.Errorf("%w: entry %q is larger than its header claims", errInvalidArchive, name)
+	}
+	if err != nil {
+		_ = x.s.fs.Remove(dstPath)
+		return fmt.Errorf("extract %s: %w", name, err)
+	}
+
+	base := path.Base(name)
+	file := sdk.File{
+		UUID:      fileID,
+		Name:      base,
+		Extension: strings.ToLower(strings.TrimPrefix(path.Ext(base), ".")),
+		MimeType:  http.DetectContentType(sniff.Bytes()),
+		FileSize:  n,
+		Checksum:  hex.EncodeToString(hasher.Sum(nil)),
+		Parent:    folder.UUID,
+		CreatedBy: x.userID,
+	}
+	if _, err := x.s.files.CreateFile(&file); err != nil {
+		_ = x.s.fs.Remove(dstPath)
+		return fmt.Errorf("create file %s: %w", name, err)
+	}
+	x.rollback.addFile(file)
+	x.written += n
+	return nil
+}
+
+// ExtractArchive unpacks a stored zip, tar or tar.gz file into a folder as
+// a files.extract job. Only the file's name, the archive and the target
+// folder are checked here; the job scans the archive and checks the quotas
+// before writing anything, so one that can't be extracted or won't fit
+// fails the job.
+func (s *Server) ExtractArchive(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not get user id", err)
+		return
+	}
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	var req sdk.ExtractArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	archive, err := s.files.GetFileByIDForUser(c.Param("fileID"), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "file not found in db", err)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "could not get file", err)
+		return
+	}
+
+	if _, ok := detectArchiveFormat(archive.Name); !ok {
+		respond(c, http.StatusBadRequest, "file is not a zip, tar or tar.gz archive", nil)
+		return
+	}
+
+	var target sdk.Folder
+	if req.Folder != "" {
+		folder, err := s.folders.GetFolder(req.Folder, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respond(c, http.StatusNotFound, "folder not found in db", err)
+				return
+			}
+			respond(c, http.StatusInternalServerError, "could not get folder", err)
+			return
+		}
+		target = *folder
+	}
+
+	s.startJob(c, userIDInt, sdk.JobKindExtract, extractPayload{FileID: archive.UUID, Folder: target.UUID}, "could not extract archive")
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"avenue/backend/sdk"
+
+	"github.com/spf13/afero"
+)
+
+func TestCleanArchivePath(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "notes.txt", want: "notes.txt"},
+		{in: "project/src/main.go", want: "project/src/main.go"},
+		{in: "project/", want: "project"},
+		{in: "./project//docs/./a.md", want: "project/docs/a.md"},
+		{in: `windows\style\path.txt`, want: "windows/style/path.txt"},
+		{in: "./", want: ""},
+		{in: "../evil.sh", wantErr: true},
+		{in: "project/../../evil.sh", wantErr: true},
+		{in: `..\evil.sh`, wantErr: true},
+		{in: "/etc/passwd", wantErr: true},
+		{in: `C:\Windows\evil.dll`, wantErr: true},
+		{in: "nul\x00byte", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := cleanArchivePath(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("cleanArchivePath(%q) = %q, expected error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("cleanArchivePath(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("cleanArchivePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "project.zip", want: sdk.ArchiveFormatZip, wantOK: true},
+		{name: "Project.ZIP", want: sdk.ArchiveFormatZip, wantOK: true},
+		{name: "backup.tar", want: sdk.ArchiveFormatTar, wantOK: true},
+		{name: "backup.tar.gz", want: sdk.ArchiveFormatTarGz, wantOK: true},
+		{name: "backup.tgz", want: sdk.ArchiveFormatTarGz, wantOK: true},
+		{name: "photo.jpg"},
+		{name: "archive.rar"},
+	}
+
+	for _, tt := range tests {
+		got, ok := detectArchiveFormat(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("detectArchiveFormat(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+type testArchiveEntry struct {
+	name    string
+	content string
+	symlink bool
+}
+
+func writeTestZip(t *testing.T, fsys afero.Fs, name string, entries []testArchiveEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.symlink {
+			hdr.SetMode(fs.ModeSymlink | 0o777)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatalf("create %s: %v", e.name, err)
+		}
+		_, _ = w.Write([]byte(e.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	if err := afero.WriteFile(fsys, name, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+}
+
+func writeTestTar(t *testing.T, fsys afero.Fs, name string, entries []testArchiveEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		switch {
+		case e.symlink:
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.content, 0
+		case strings.HasSuffix(e.name, "/"):
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("header %s: %v", e.name, err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			_, _ = tw.Write([]byte(e.content))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	if err := afero.WriteFile(fsys, name, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write tar: %v", err)
+	}
+}
+
+func TestScanArchive(t *testing.T) {
+	limits := extractLimits{MaxEntries: 10, MaxBytes: 100, MaxFileBytes: 50}
+
+	tests := []struct {
+		name    string
+		entries []testArchiveEntry
+		limits  extractLimits
+		want    archiveScan
+		wantErr string
+	}{
+		{
+			name: "nested project",
+			entries: []testArchiveEntry{
+				{name: "project/"},
+				{name: "project/README.md", content: "hello"},
+				{name: "project/src/main.go", content: "package main"},
+			},
+			want: archiveScan{Entries: 3, Bytes: 17},
+		},
+		{
+			name: "symlinks are skipped",
+			entries: []testArchiveEntry{
+				{name: "a.txt", content: "a"},
+				{name: "link", content: "/etc/passwd", symlink: true},
+			},
+			want: archiveScan{Entries: 1, Skipped: 1, Bytes: 1},
+		},
+		{
+			name:    "zip slip is rejected",
+			entries: []testArchiveEntry{{name: "../../evil.sh", content: "x"}},
+			wantErr: "points outside the archive",
+		},
+		{
+			name: "too many entries",
+			entries: []testArchiveEntry{
+				{name: "a"}, {name: "b"}, {name: "c"},
+			},
+			limits:  extractLimits{MaxEntries: 2, MaxBytes: 100, MaxFileBytes: 50},
+			wantErr: "more than 2 entries",
+		},
+		{
+			name:    "file over the per-file limit",
+			entries: []testArchiveEntry{{name: "big.bin", content: strings.Repeat("x", 51)}},
+			wantErr: "byte file limit",
+		},
+		{
+			name: "total over the expanded size limit",
+			entries: []testArchiveEntry{
+				{name: "a.bin", content: strings.Repeat("x", 50)},
+				{name: "b.bin", content: strings.Repeat("x", 50)},
+				{name: "c.bin", content: "x"},
+			},
+			wantErr: "expands to more than 100 bytes",
+		},
+	}
+
+	for _, format := range []string{sdk.ArchiveFormatZip, sdk.ArchiveFormatTar} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				fsys := afero.NewMemMapFs()
+				if format == sdk.ArchiveFormatZip {
+					writeTestZip(t, fsys, "/archive", tt.entries)
+				} else {
+					writeTestTar(t, fsys, "/archive", tt.entries)
+				}
+
+				l := tt.limits
+				if l == (extractLimits{}) {
+					l = limits
+				}
+				got, err := scanArchive(fsys, "/archive", format, l)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package handlers
 
 import (
-	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -15,11 +16,9 @@ import (
 	"strings"
 	"time"
 
-	"avenue/backend/db"
 	"avenue/backend/logger"
 	"avenue/backend/sdk"
 	"avenue/backend/shared"
-	"avenue/backend/sweeper"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/afero"
@@ -52,35 +51,34 @@ func (s *Server) Upload(c *gin.Context) {
 		return
 	}
 
-	user, err := db.GetUserByIDStr(userID)
-	if err != nil {
-		logger.Errorf("error getting user: %s", err.Error())
-		respond(c, http.StatusInternalServerError, "", err)
-		return
-	}
-
 	userIDInt, err := strconv.ParseInt(userID, 10, 64)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
 	}
 
-	envMaxFileSize := shared.GetEnvInt64("MAX_FILE_BYTE_SIZE", shared.DEFAULTMAXFILESIZE)
+	envMaxFileSize := s.cfg.Storage.MaxFileSize
 	var total int64
 
-	var totalUsed int64
-	if user.Quota != 0 {
-		totalUsed, err = db.GetUserUsage(userIDInt)
-		if err != nil {
-			logger.Errorf("error getting user quota: %s", err.Error())
-			respond(c, http.StatusInternalServerError, "", err)
-			return
-		}
+	status, err := s.quotaStatus(userIDInt)
+	if err != nil {
+		logger.Errorf("error getting user quota: %s", err.Error())
+		respond(c, http.StatusInternalServerError, "", err)
+		return
 	}
 
-	maxFileSize, overQuota := computeUploadLimit(user.Quota, totalUsed, envMaxFileSize)
+	maxFileSize, overQuota := computeUploadLimit(status.Limit, status.Used, envMaxFileSize)
 	if overQuota {
-		respond(c, http.StatusUnprocessableEntity, "", errors.New("max quota reached. Please delete files to be able to upload files"))
+		respond(c, http.StatusUnprocessableEntity, "", fmt.Errorf("%w: please delete files to be able to upload files", errQuotaExceeded))
+		return
+	}
+	groupLeft, group, err := s.groupRoom(userIDInt)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	if groupLeft == 0 {
+		respond(c, http.StatusUnprocessableEntity, "", fmt.Errorf("%w: group %q is full", errQuotaExceeded, group.Name))
 		return
 	}
 
@@ -89,6 +87,7 @@ func (s *Server) Upload(c *gin.Context) {
 		c.Request.Body,
 		maxFileSize,
 	)
+	s.throttleUpload(c, userID, "")
 
 	mr, err := c.Request.MultipartReader()
 	if err != nil {
@@ -143,6 +142,16 @@ func (s *Server) Upload(c *gin.Context) {
 			}
 			contentType = http.DetectContentType(buf[:n])
 
+			room, folderQuota, err := s.folderRoom(parent)
+			if err != nil {
+				respond(c, http.StatusInternalServerError, "", err)
+				return
+			}
+			if room == 0 {
+				respond(c, http.StatusUnprocessableEntity, "", fmt.Errorf("%w: folder %q is full", errQuotaExceeded, folderQuota.Name))
+				return
+			}
+
 			// Create file record in database
 			createdAt = time.Now().UTC()
 			fileRecord := &sdk.File{
@@ -152,7 +161,7 @@ func (s *Server) Upload(c *gin.Context) {
 				Parent:    parent,
 				CreatedBy: userIDInt,
 			}
-			fileID, err = db.CreateFile(fileRecord)
+			fileID, err = s.files.CreateFile(fileRecord)
 			if err == nil {
 				// CreateFile may have deduplicated the name against existing
 				// siblings; use the name it actually stored from here on.
@@ -172,7 +181,7 @@ func (s *Server) Upload(c *gin.Context) {
 			dstPath := shared.BlobPath(fileID)
 			dst, err := s.fs.Create(dstPath)
 			if err != nil {
-				deleteErr := db.DeleteFile(fileID, userID)
+				deleteErr := s.files.DeleteFile(fileID, userID)
 				if deleteErr != nil {
 					logger.Errorf("delete file: %v", deleteErr)
 					respond(c, http.StatusInternalServerError, "could not delete file in db", deleteErr)
@@ -186,12 +195,12 @@ func (s *Server) Upload(c *gin.Context) {
 			hasher := sha256.New()
 			mw := io.MultiWriter(dst, hasher)
 
-			r := bytes.NewReader(buf)
+			r := bytes.NewReader(buf[:n])
 			written, err := io.Copy(mw, r)
 			if err != nil {
 				dst.Close()
 				_ = s.fs.Remove(dstPath)
-				deleteErr := db.DeleteFile(fileID, userID)
+				deleteErr := s.files.DeleteFile(fileID, userID)
 				if deleteErr != nil {
 					logger.Errorf("delete file: %v", deleteErr)
 					respond(c, http.StatusInternalServerError, "could not delete file in db", deleteErr)
@@ -203,17 +212,22 @@ func (s *Server) Upload(c *gin.Context) {
 			}
 			total += written
 
-			written, err = io.Copy(mw, part)
+			written, err = io.Copy(mw, limitToQuotas(part, total, room, folderQuota, groupLeft, group))
 			if err != nil {
 				dst.Close()
 				_ = s.fs.Remove(dstPath)
-				deleteErr := db.DeleteFile(fileID, userID)
+				deleteErr := s.files.DeleteFile(fileID, userID)
 				if deleteErr != nil {
 					logger.Errorf("delete file: %v", deleteErr)
 					respond(c, http.StatusInternalServerError, "could not delete file in db", deleteErr)
 					return
 				}
 
+				if errors.Is(err, errQuotaExceeded) {
+					respond(c, http.StatusUnprocessableEntity, "", err)
+					return
+				}
+
 				var maxErr *http.MaxBytesError
 
 				if errors.As(err, &maxErr) {
@@ -250,7 +264,7 @@ func (s *Server) Upload(c *gin.Context) {
 	}
 
 	// Update file size in database
-	err = db.UpdateFile(sdk.File{
+	err = s.files.UpdateFile(sdk.File{
 		UUID:      fileID,
 		FileSize:  total,
 		Checksum:  checksum,
@@ -265,12 +279,13 @@ func (s *Server) Upload(c *gin.Context) {
 		return
 	}
 
-	err = db.UpdateUsage(userIDInt, total)
+	err = s.users.UpdateUsage(userIDInt, total)
 	if err != nil {
 		// todo should we rollback? Or just have a cron that'll reconcile?
 		respond(c, http.StatusInternalServerError, "could not update user quota usage", err)
 		return
 	}
+	s.checkGroupSoftQuotas(userIDInt)
 
 	c.JSON(http.StatusCreated, sdk.File{
 		UUID:      fileID,
@@ -292,7 +307,7 @@ func (s *Server) ListFiles(c *gin.Context) {
 		return
 	}
 
-	files, err := db.ListFiles(userID)
+	files, err := s.files.ListFiles(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not list files", err)
 		return
@@ -313,7 +328,7 @@ func (s *Server) SearchFiles(c *gin.Context) {
 		return
 	}
 
-	files, err := db.SearchChildFiles(c.Param("folderID"), userID, fileName)
+	files, err := s.files.SearchChildFiles(c.Param("folderID"), userID, fileName)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not search files", err)
 		return
@@ -334,7 +349,7 @@ func (s *Server) MoveFile(c *gin.Context) {
 		return
 	}
 
-	file, err := db.GetFileByIDForUser(c.Param("fileID"), userID)
+	file, err := s.files.GetFileByIDForUser(c.Param("fileID"), userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "file not found in db", err)
@@ -346,15 +361,19 @@ func (s *Server) MoveFile(c *gin.Context) {
 	}
 
 	if req.Parent != "" {
-		if _, err := db.GetFolder(req.Parent, userID); err != nil {
+		if _, err := s.folders.GetFolder(req.Parent, userID); err != nil {
 			respond(c, http.StatusBadRequest, "destination folder must exist", err)
 			return
 		}
+		if err := s.checkMoveQuota(req.Parent, []string{file.UUID}, nil, userID); err != nil {
+			respondQuotaErr(c, err)
+			return
+		}
 	}
 
 	file.Parent = req.Parent
 
-	if err := db.UpdateFile(*file, userID); err != nil {
+	if err := s.files.UpdateFile(*file, userID); err != nil {
 		respond(c, http.StatusInternalServerError, "could not move file", err)
 		return
 	}
@@ -375,7 +394,7 @@ func (s *Server) UpdateFileName(c *gin.Context) {
 		return
 	}
 
-	file, err := db.GetFileByIDForUser(c.Param("fileID"), userID)
+	file, err := s.files.GetFileByIDForUser(c.Param("fileID"), userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "file not found in db", err)
@@ -388,7 +407,7 @@ func (s *Server) UpdateFileName(c *gin.Context) {
 
 	file.Name = newName
 
-	err = db.UpdateFile(*file, userID)
+	err = s.files.UpdateFile(*file, userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not update file", err)
 		return
@@ -404,7 +423,7 @@ func (s *Server) GetFile(c *gin.Context) {
 		return
 	}
 
-	file, err := db.GetFileByIDForUser(c.Param("fileID"), userID)
+	file, err := s.files.GetFileByIDForUser(c.Param("fileID"), userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "file not found in db", err)
@@ -441,15 +460,92 @@ func (s *Server) GetFile(c *gin.Context) {
 	c.Writer.Flush()
 
 	// ----- Stream file to client -----
-	if _, err := io.Copy(c.Writer, fileData); err != nil {
+	if _, err := io.Copy(s.throttleDownload(c, c.Writer, userID, ""), fileData); err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
 }
 
-// DownloadFilesZip streams a zip archive of the requested files to the
-// client. Files are read from disk and written into the zip one at a time
-// so the archive is never buffered in memory or on disk before sending.
+// archiveSource is a file queued for a download archive.
+type archiveSource struct {
+	UUID      string
+	Path      string
+	CreatedAt time.Time
+}
+
+// archiveLookupError reports a requested file or folder that couldn't be
+// looked up while collecting archive sources.
+type archiveLookupError struct {
+	message string
+	err     error
+}
+
+func (e *archiveLookupError) Error() string { return e.message + ": " + e.err.Error() }
+func (e *archiveLookupError) Unwrap() error { return e.err }
+
+// collectArchiveSources resolves the files and folders in req to the files
+// an archive of them holds, and picks the archive's name: the folder's
+// name when exactly one folder is requested, "download" otherwise.
+func (s *Server) collectArchiveSources(req sdk.DownloadFilesZipRequest, userID string) ([]archiveSource, string, error) {
+	sources := make([]archiveSource, 0, len(req.FileIDs))
+	for _, id := range req.FileIDs {
+		file, err := s.files.GetFileByIDForUser(id, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, "", &archiveLookupError{message: fmt.Sprintf("file not found in db: %s", id), err: err}
+			}
+			return nil, "", &archiveLookupError{message: "could not get file", err: err}
+		}
+		sources = append(sources, archiveSource{UUID: file.UUID, Path: file.Name, CreatedAt: file.CreatedAt})
+	}
+
+	archiveName := "download"
+	for _, id := range req.FolderIDs {
+		folder, err := s.folders.GetFolder(id, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, "", &archiveLookupError{message: fmt.Sprintf("folder not found in db: %s", id), err: err}
+			}
+			return nil, "", &archiveLookupError{message: "could not get folder", err: err}
+		}
+		if len(req.FolderIDs) == 1 && len(req.FileIDs) == 0 {
+			archiveName = folder.Name
+		}
+
+		entries, err := s.folders.ListFolderFilesForZip(id, userID)
+		if err != nil {
+			return nil, "", &archiveLookupError{message: "could not list folder contents", err: err}
+		}
+		for _, entry := range entries {
+			sources = append(sources, archiveSource{UUID: entry.UUID, Path: entry.DirPath + "/" + entry.Name, CreatedAt: entry.CreatedAt})
+		}
+	}
+	return sources, archiveName, nil
+}
+
+// respondArchiveLookupError writes the response for an error from
+// collectArchiveSources.
+func respondArchiveLookupError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, sql.ErrNoRows) {
+		status = http.StatusNotFound
+	}
+	var lookupErr *archiveLookupError
+	if errors.As(err, &lookupErr) {
+		respond(c, status, lookupErr.message, lookupErr.err)
+		return
+	}
+	respond(c, status, "", err)
+}
+
+// DownloadFilesZip streams an archive of the requested files and folders to
+// the client. Requested files sit at the archive root and each folder keeps
+// its directory structure under its own name. Files are read from disk and
+// written into the archive one at a time so it is never buffered in memory
+// or on disk before sending. A manifest.json listing every entry closes the
+// archive, along with an errors.txt naming any file that couldn't be added.
+// Very large downloads can instead be built in the background with
+// CreateArchiveJob.
 func (s *Server) DownloadFilesZip(c *gin.Context) {
 	userID, err := shared.GetUserIDFromContext(c.Request.Context())
 	if err != nil {
@@ -468,135 +564,110 @@ func (s *Server) DownloadFilesZip(c *gin.Context) {
 		return
 	}
 
-	// Folder downloads walk an entire subtree, so they can't be combined
-	// with other files/folders in the same request — only one folder,
-	// downloaded on its own, is allowed.
-	if len(req.FolderIDs) > 0 {
-		if len(req.FolderIDs) > 1 || len(req.FileIDs) > 0 {
-			respond(c, http.StatusBadRequest, "a folder can't be downloaded alongside other files or folders", nil)
-			return
-		}
-
-		s.downloadFolderZip(c, userID, req.FolderIDs[0])
+	format, formatInfo, err := parseArchiveFormat(req.Format)
+	if err != nil {
+		respond(c, http.StatusBadRequest, "invalid format", err)
 		return
 	}
 
-	files := make([]*sdk.File, 0, len(req.FileIDs))
-	for _, id := range req.FileIDs {
-		file, err := db.GetFileByIDForUser(id, userID)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				respond(c, http.StatusNotFound, fmt.Sprintf("file not found in db: %s", id), err)
-				return
-			}
-
-			respond(c, http.StatusInternalServerError, "could not get file", err)
-			return
-		}
-		files = append(files, file)
+	sources, archiveName, err := s.collectArchiveSources(req, userID)
+	if err != nil {
+		respondArchiveLookupError(c, err)
+		return
 	}
 
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Disposition", `attachment; filename="download.zip"`)
+	c.Header("Content-Type", formatInfo.ContentType)
+	c.Header("Content-Disposition", contentDispositionAttachment(archiveName+formatInfo.Extension))
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Access-Control-Expose-Headers", "Content-Disposition")
 	c.Status(http.StatusOK)
 	c.Writer.Flush()
 
-	zw := zip.NewWriter(c.Writer)
-	defer func() {
-		_ = zw.Close()
-	}()
+	aw := newArchiveWriter(format, s.throttleDownload(c, c.Writer, userID, ""))
+	if _, err := s.writeArchive(c.Request.Context(), aw, format, sources, nil); err != nil {
+		logger.Errorf("error writing %s download: %s", format, err.Error())
+	}
+	if err := aw.Close(); err != nil {
+		logger.Errorf("error finishing %s download: %s", format, err.Error())
+	}
+}
 
-	names := make(map[string]int)
-	for _, file := range files {
-		path := shared.BlobPath(file.UUID)
-		fileData, err := s.fs.Open(path)
-		if err != nil {
-			logger.Errorf("error opening file %s for zip download: %s", file.UUID, err.Error())
-			continue
+// writeArchive adds every source to aw, then errors.txt if any couldn't be
+// added and finally manifest.json, and returns the manifest. added, if
+// non-nil, is called after each source. It stops early once ctx is done.
+// The caller closes aw.
+func (s *Server) writeArchive(ctx context.Context, aw archiveWriter, format string, sources []archiveSource, added func()) (sdk.ArchiveManifest, error) {
+	manifest := sdk.ArchiveManifest{
+		CreatedAt: time.Now().UTC(),
+		Entries:   make([]sdk.ArchiveEntry, 0, len(sources)),
+		Errors:    []sdk.ExportError{},
+	}
+	names := map[string]int{archiveManifestName: 1, archiveErrorsName: 1}
+	for _, src := range sources {
+		if err := ctx.Err(); err != nil {
+			return manifest, err
 		}
 
-		entryWriter, err := zw.CreateHeader(&zip.FileHeader{
-			Name:     uniqueZipEntryName(names, file.Name),
-			Method:   zip.Deflate,
-			Modified: file.CreatedAt,
-		})
+		name := uniqueZipEntryName(names, src.Path)
+		size, err := s.writeArchiveFile(aw, name, src)
+		if added != nil {
+			added()
+		}
 		if err != nil {
-			_ = fileData.Close()
-			logger.Errorf("error creating zip entry for %s: %s", file.UUID, err.Error())
+			logger.Errorf("error adding file %s to %s download: %s", src.UUID, format, err.Error())
+			manifest.Errors = append(manifest.Errors, sdk.ExportError{UUID: src.UUID, Path: name, Error: err.Error()})
 			continue
 		}
+		manifest.Entries = append(manifest.Entries, sdk.ArchiveEntry{Path: name, UUID: src.UUID, Size: size, Modified: src.CreatedAt})
+	}
 
-		if _, err := io.Copy(entryWriter, fileData); err != nil {
-			logger.Errorf("error writing file %s to zip: %s", file.UUID, err.Error())
+	if len(manifest.Errors) > 0 {
+		var buf bytes.Buffer
+		for _, e := range manifest.Errors {
+			fmt.Fprintf(&buf, "%s: %s\n", e.Path, e.Error)
+		}
+		if err := writeArchiveBytes(aw, archiveErrorsName, buf.Bytes(), manifest.CreatedAt); err != nil {
+			return manifest, fmt.Errorf("write %s: %w", archiveErrorsName, err)
 		}
-		_ = fileData.Close()
 	}
-}
 
-// downloadFolderZip streams a zip archive of every file nested under
-// folderID, preserving its directory structure. A single request may only
-// target one folder (enforced by the caller), but multiple folder downloads
-// can run concurrently across the server.
-func (s *Server) downloadFolderZip(c *gin.Context, userID, folderID string) {
-	folder, err := db.GetFolder(folderID, userID)
+	data, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respond(c, http.StatusNotFound, "folder not found in db", err)
-			return
-		}
-
-		respond(c, http.StatusInternalServerError, "could not get folder", err)
-		return
+		return manifest, fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := writeArchiveBytes(aw, archiveManifestName, data, manifest.CreatedAt); err != nil {
+		return manifest, fmt.Errorf("write %s: %w", archiveManifestName, err)
 	}
+	return manifest, nil
+}
 
-	entries, err := db.ListFolderFilesForZip(folderID, userID)
+// writeArchiveFile copies src's blob into the archive under name and
+// returns the number of bytes written. A blob that fails to open is
+// reported before anything is added; a failure mid-copy leaves a truncated
+// entry behind, which the caller records in the manifest.
+func (s *Server) writeArchiveFile(aw archiveWriter, name string, src archiveSource) (int64, error) {
+	fileData, err := s.fs.Open(shared.BlobPath(src.UUID))
 	if err != nil {
-		respond(c, http.StatusInternalServerError, "could not list folder contents", err)
-		return
+		return 0, err
 	}
-
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Disposition", contentDispositionAttachment(folder.Name+".zip"))
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Access-Control-Expose-Headers", "Content-Disposition")
-	c.Status(http.StatusOK)
-	c.Writer.Flush()
-
-	zw := zip.NewWriter(c.Writer)
 	defer func() {
-		_ = zw.Close()
+		_ = fileData.Close()
 	}()
 
-	names := make(map[string]int)
-	for _, entry := range entries {
-		path := shared.BlobPath(entry.UUID)
-		fileData, err := s.fs.Open(path)
-		if err != nil {
-			logger.Errorf("error opening file %s for folder zip download: %s", entry.UUID, err.Error())
-			continue
-		}
-
-		entryWriter, err := zw.CreateHeader(&zip.FileHeader{
-			Name:     uniqueZipEntryName(names, entry.DirPath+"/"+entry.Name),
-			Method:   zip.Deflate,
-			Modified: entry.CreatedAt,
-		})
-		if err != nil {
-			_ = fileData.Close()
-			logger.Errorf("error creating zip entry for %s: %s", entry.UUID, err.Error())
-			continue
-		}
+	info, err := fileData.Stat()
+	if err != nil {
+		return 0, err
+	}
 
-		if _, err := io.Copy(entryWriter, fileData); err != nil {
-			logger.Errorf("error writing file %s to zip: %s", entry.UUID, err.Error())
-		}
-		_ = fileData.Close()
+	entryWriter, err := aw.Create(name, info.Size(), src.CreatedAt)
+	if err != nil {
+		return 0, err
 	}
+
+	return io.Copy(entryWriter, fileData)
 }
 
-// uniqueZipEntryName disambiguates duplicate file names within a single zip
+// uniqueZipEntryName disambiguates duplicate file names within a single
 // archive by appending " (n)" before the extension, mirroring how OS file
 // managers handle name collisions.
 func uniqueZipEntryName(seen map[string]int, name string) string {
@@ -621,7 +692,7 @@ func (s *Server) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	if err = db.TrashFileForUser(c.Param("fileID"), userID); err != nil {
+	if err = s.files.TrashFileForUser(c.Param("fileID"), userID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "file not found in db", err)
 			return
@@ -637,12 +708,12 @@ func (s *Server) DeleteFile(c *gin.Context) {
 // trashRestoreResponse builds the post-restore pagination totals shared by
 // RestoreFile, RestoreFolder, and BulkRestore, so the UI can refresh its
 // trash counts without re-fetching the whole list.
-func trashRestoreResponse(userID, message string) (sdk.V1RestoreResponse, error) {
-	totalFiles, err := db.CountTrashedFiles(userID)
+func (s *Server) trashRestoreResponse(userID, message string) (sdk.V1RestoreResponse, error) {
+	totalFiles, err := s.files.CountTrashedFiles(userID)
 	if err != nil {
 		return sdk.V1RestoreResponse{}, err
 	}
-	totalFolders, err := db.CountTrashedFolders(userID)
+	totalFolders, err := s.folders.CountTrashedFolders(userID)
 	if err != nil {
 		return sdk.V1RestoreResponse{}, err
 	}
@@ -660,7 +731,7 @@ func (s *Server) RestoreFile(c *gin.Context) {
 		return
 	}
 
-	if err = db.RestoreFileForUser(c.Param("fileID"), userID); err != nil {
+	if err = s.files.RestoreFileForUser(c.Param("fileID"), userID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "file not found in trash", err)
 			return
@@ -670,7 +741,7 @@ func (s *Server) RestoreFile(c *gin.Context) {
 		return
 	}
 
-	resp, err := trashRestoreResponse(userID, "File restored")
+	resp, err := s.trashRestoreResponse(userID, "File restored")
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not count trashed items", err)
 		return
@@ -688,7 +759,7 @@ func (s *Server) PurgeFile(c *gin.Context) {
 		return
 	}
 
-	f, err := db.PurgeFileForUser(c.Param("fileID"), userID)
+	f, err := s.files.PurgeFileForUser(c.Param("fileID"), userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "file not found in trash", err)
@@ -703,7 +774,7 @@ func (s *Server) PurgeFile(c *gin.Context) {
 		logger.Errorf("purge file: remove blob %s: %v", f.UUID, err)
 	}
 
-	if err = db.UpdateUsage(f.CreatedBy, -f.FileSize); err != nil {
+	if err = s.users.UpdateUsage(f.CreatedBy, -f.FileSize); err != nil {
 		respond(c, http.StatusInternalServerError, "could not update user quota usage", err)
 		return
 	}
@@ -726,25 +797,25 @@ func (s *Server) ListTrash(c *gin.Context) {
 
 	sortColumn := "deleted_at"
 	switch c.Query("sortBy") {
-	case "name":
+	case sdk.TrashSortByName:
 		sortColumn = "name"
-	case "size":
+	case sdk.TrashSortBySize:
 		sortColumn = "file_size"
 	}
 
 	page, limit, offset := shared.ParsePagination(c.Query("page"), c.Query("limit"))
 
-	items, err := db.ListTrashedItems(userID, sortColumn, sortDir, limit, offset)
+	items, err := s.folders.ListTrashedItems(userID, sortColumn, sortDir, limit, offset)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not list trashed items", err)
 		return
 	}
-	totalFiles, err := db.CountTrashedFiles(userID)
+	totalFiles, err := s.files.CountTrashedFiles(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not count trashed files", err)
 		return
 	}
-	totalFolders, err := db.CountTrashedFolders(userID)
+	totalFolders, err := s.folders.CountTrashedFolders(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not count trashed folders", err)
 		return
@@ -752,60 +823,26 @@ func (s *Server) ListTrash(c *gin.Context) {
 
 	c.JSON(http.StatusOK, sdk.V1TrashResponse{
 		Items:         items,
-		RetentionDays: int(sweeper.Retention().Hours() / 24),
+		RetentionDays: int(s.cfg.Sweepers.TrashRetention.Hours() / 24),
 		Page:          page,
 		Limit:         limit,
 		Total:         totalFiles + totalFolders,
 	})
 }
 
-// EmptyTrash permanently deletes everything the user has trashed.
+// EmptyTrash permanently deletes everything the user has trashed. It runs
+// as a trash.empty job, answered as described on startJob.
 func (s *Server) EmptyTrash(c *gin.Context) {
 	userID, err := shared.GetUserIDFromContext(c.Request.Context())
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not get user id", err)
 		return
 	}
-
-	files, err := db.ListTrashedFiles(userID)
-	if err != nil {
-		respond(c, http.StatusInternalServerError, "could not list trashed files", err)
-		return
-	}
-	for _, f := range files {
-		purged, err := db.PurgeFileForUser(f.UUID, userID)
-		if err != nil {
-			logger.Errorf("empty trash: purge file %s: %v", f.UUID, err)
-			continue
-		}
-		if err := s.fs.Remove(shared.BlobPath(purged.UUID)); err != nil && !errors.Is(err, afero.ErrFileNotFound) {
-			logger.Errorf("empty trash: remove blob %s: %v", purged.UUID, err)
-		}
-		if err := db.UpdateUsage(purged.CreatedBy, -purged.FileSize); err != nil {
-			logger.Errorf("empty trash: update usage for user %d: %v", purged.CreatedBy, err)
-		}
-	}
-
-	folders, err := db.ListTrashedFolders(userID)
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
 	if err != nil {
-		respond(c, http.StatusInternalServerError, "could not list trashed folders", err)
+		respond(c, http.StatusInternalServerError, "", err)
 		return
 	}
-	for _, folder := range folders {
-		purgedFiles, err := db.PurgeFolder(folder.UUID, userID)
-		if err != nil {
-			logger.Errorf("empty trash: purge folder %s: %v", folder.UUID, err)
-			continue
-		}
-		for _, f := range purgedFiles {
-			if err := s.fs.Remove(shared.BlobPath(f.UUID)); err != nil && !errors.Is(err, afero.ErrFileNotFound) {
-				logger.Errorf("empty trash: remove blob %s: %v", f.UUID, err)
-			}
-			if err := db.UpdateUsage(f.CreatedBy, -f.FileSize); err != nil {
-				logger.Errorf("empty trash: update usage for user %d: %v", f.CreatedBy, err)
-			}
-		}
-	}
 
-	c.Status(http.StatusOK)
+	s.startJob(c, userIDInt, sdk.JobKindEmptyTrash, struct{}{}, "could not empty trash")
 }
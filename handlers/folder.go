@@ -6,13 +6,10 @@ import (
 	"net/http"
 	"strconv"
 
-	"avenue/backend/db"
-	"avenue/backend/logger"
 	"avenue/backend/sdk"
 	"avenue/backend/shared"
 
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/afero"
 )
 
 func (s *Server) CreateFolder(c *gin.Context) {
@@ -29,7 +26,7 @@ func (s *Server) CreateFolder(c *gin.Context) {
 
 	var parentID int64
 	if req.Parent != "" {
-		parentFolder, err := db.GetFolder(req.Parent, userID)
+		parentFolder, err := s.folders.GetFolder(req.Parent, userID)
 		if err != nil {
 			respond(c, http.StatusBadRequest, "parent folder must exist", err)
 			return
@@ -43,7 +40,7 @@ func (s *Server) CreateFolder(c *gin.Context) {
 		return
 	}
 
-	_, err = db.CreateFolder(&sdk.Folder{
+	_, err = s.folders.CreateFolder(&sdk.Folder{
 		Name:     req.Name,
 		OwnerID:  ownerIDInt,
 		ParentID: parentID,
@@ -66,7 +63,7 @@ func (s *Server) DeleteFolder(c *gin.Context) {
 
 	folderID := c.Param("folderID")
 
-	err = db.TrashFolder(folderID, userID)
+	err = s.folders.TrashFolder(folderID, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "folder not found in db", err)
@@ -81,7 +78,8 @@ func (s *Server) DeleteFolder(c *gin.Context) {
 }
 
 // BulkDelete moves a batch of files and folders to the trash in a single
-// request, instead of requiring one DELETE call per item.
+// request, instead of requiring one DELETE call per item. It runs as a
+// files.bulk_trash job, answered as described on startJob.
 func (s *Server) BulkDelete(c *gin.Context) {
 	userID, err := shared.GetUserIDFromContext(c.Request.Context())
 	if err != nil {
@@ -89,6 +87,12 @@ func (s *Server) BulkDelete(c *gin.Context) {
 		return
 	}
 
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+
 	var req sdk.BulkDeleteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		respond(c, http.StatusBadRequest, "could not marshal all data to json", err)
@@ -100,12 +104,7 @@ func (s *Server) BulkDelete(c *gin.Context) {
 		return
 	}
 
-	if err := db.BulkTrash(req.FileIDs, req.FolderIDs, userID); err != nil {
-		respond(c, http.StatusInternalServerError, "could not delete items", err)
-		return
-	}
-
-	respond(c, http.StatusOK, "Items moved to trash", nil)
+	s.startJob(c, userIDInt, sdk.JobKindBulkTrash, req, "could not delete items")
 }
 
 // BulkRestore restores a batch of trashed files and folders in a single
@@ -128,12 +127,12 @@ func (s *Server) BulkRestore(c *gin.Context) {
 		return
 	}
 
-	if err := db.BulkRestore(req.FileIDs, req.FolderIDs, userID); err != nil {
+	if err := s.folders.BulkRestore(req.FileIDs, req.FolderIDs, userID); err != nil {
 		respond(c, http.StatusInternalServerError, "could not restore items", err)
 		return
 	}
 
-	resp, err := trashRestoreResponse(userID, "Items restored")
+	resp, err := s.trashRestoreResponse(userID, "Items restored")
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not count trashed items", err)
 		return
@@ -163,13 +162,17 @@ func (s *Server) BulkMove(c *gin.Context) {
 	}
 
 	if req.Parent != "" {
-		if _, err := db.GetFolder(req.Parent, userID); err != nil {
+		if _, err := s.folders.GetFolder(req.Parent, userID); err != nil {
 			respond(c, http.StatusBadRequest, "destination folder must exist", err)
 			return
 		}
+		if err := s.checkMoveQuota(req.Parent, req.FileIDs, req.FolderIDs, userID); err != nil {
+			respondQuotaErr(c, err)
+			return
+		}
 	}
 
-	if err := db.BulkMove(req.FileIDs, req.FolderIDs, req.Parent, userID); err != nil {
+	if err := s.folders.BulkMove(req.FileIDs, req.FolderIDs, req.Parent, userID); err != nil {
 		respond(c, http.StatusInternalServerError, "could not move items", err)
 		return
 	}
@@ -185,7 +188,7 @@ func (s *Server) RestoreFolder(c *gin.Context) {
 		return
 	}
 
-	err = db.RestoreFolder(c.Param("folderID"), userID)
+	err = s.folders.RestoreFolder(c.Param("folderID"), userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "folder not found in trash", err)
@@ -196,7 +199,7 @@ func (s *Server) RestoreFolder(c *gin.Context) {
 		return
 	}
 
-	resp, err := trashRestoreResponse(userID, "Folder restored")
+	resp, err := s.trashRestoreResponse(userID, "Folder restored")
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not count trashed items", err)
 		return
@@ -206,16 +209,22 @@ func (s *Server) RestoreFolder(c *gin.Context) {
 }
 
 // PurgeFolder permanently deletes a trashed folder and everything nested
-// inside it, removing file blobs from disk and reconciling quota usage.
+// inside it, removing file blobs from disk and reconciling quota usage. It
+// runs as a folder.purge job, answered as described on startJob.
 func (s *Server) PurgeFolder(c *gin.Context) {
 	userID, err := shared.GetUserIDFromContext(c.Request.Context())
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not get user id", err)
 		return
 	}
-
-	purgedFiles, err := db.PurgeFolder(c.Param("folderID"), userID)
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
 	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	folderID := c.Param("folderID")
+	if _, err := s.folders.GetTrashedFolder(folderID, userID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "folder not found in trash", err)
 			return
@@ -225,16 +234,7 @@ func (s *Server) PurgeFolder(c *gin.Context) {
 		return
 	}
 
-	for _, f := range purgedFiles {
-		if err := s.fs.Remove(shared.BlobPath(f.UUID)); err != nil && !errors.Is(err, afero.ErrFileNotFound) {
-			logger.Errorf("purge folder: remove file blob %s: %v", f.UUID, err)
-		}
-		if err := db.UpdateUsage(f.CreatedBy, -f.FileSize); err != nil {
-			logger.Errorf("purge folder: update usage for user %d: %v", f.CreatedBy, err)
-		}
-	}
-
-	c.Status(http.StatusOK)
+	s.startJob(c, userIDInt, sdk.JobKindPurgeFolder, purgeFolderPayload{FolderID: folderID}, "could not purge folder")
 }
 
 func (s *Server) UpdateFolderName(c *gin.Context) {
@@ -250,7 +250,7 @@ func (s *Server) UpdateFolderName(c *gin.Context) {
 		return
 	}
 
-	folder, err := db.GetFolder(c.Param("folderID"), userID)
+	folder, err := s.folders.GetFolder(c.Param("folderID"), userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "folder not found in db", err)
@@ -263,7 +263,7 @@ func (s *Server) UpdateFolderName(c *gin.Context) {
 
 	folder.Name = newName
 
-	err = db.UpdateFolder(*folder)
+	err = s.folders.UpdateFolder(*folder)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "could not update folder", err)
 		return
@@ -309,9 +309,9 @@ func (s *Server) ListFolderContents(c *gin.Context) {
 
 	sortColumn := "name"
 	switch c.Query("sortBy") {
-	case "size":
+	case sdk.FolderSortBySize:
 		sortColumn = "file_size"
-	case "date":
+	case sdk.FolderSortByDate:
 		sortColumn = "created_at"
 	}
 
@@ -324,7 +324,7 @@ func (s *Server) ListFolderContents(c *gin.Context) {
 	}
 
 	folderID := c.Param("folderID")
-	items, err := db.ListFolderItems(folderID, userID, sortColumn, sortDir, limit, offset, itemType)
+	items, err := s.folders.ListFolderItems(folderID, userID, sortColumn, sortDir, limit, offset, itemType)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "Internal server error", err)
 		return
@@ -332,14 +332,14 @@ func (s *Server) ListFolderContents(c *gin.Context) {
 
 	var totalFolders, totalFiles int
 	if itemType != sdk.FolderItemTypeFile {
-		totalFolders, err = db.CountChildFolders(folderID, userID)
+		totalFolders, err = s.folders.CountChildFolders(folderID, userID)
 		if err != nil {
 			respond(c, http.StatusInternalServerError, "Internal server error", err)
 			return
 		}
 	}
 	if itemType != sdk.FolderItemTypeFolder {
-		totalFiles, err = db.CountChildFiles(folderID, userID)
+		totalFiles, err = s.files.CountChildFiles(folderID, userID)
 		if err != nil {
 			respond(c, http.StatusInternalServerError, "Internal server error", err)
 			return
@@ -348,7 +348,7 @@ func (s *Server) ListFolderContents(c *gin.Context) {
 
 	var x sdk.V1FolderContentsResponse
 
-	folderParents, err := db.ListFolderParents(folderID, userID)
+	folderParents, err := s.folders.ListFolderParents(folderID, userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "Internal server error", err)
 		return
@@ -361,5 +361,14 @@ func (s *Server) ListFolderContents(c *gin.Context) {
 
 	x.BreadCrumbs = buildBreadcrumbs(folderID, folderParents)
 
+	x.Quotas = []sdk.FolderQuota{}
+	if folderID != "" {
+		x.Quotas, err = s.folders.ListFolderQuotas(folderID)
+		if err != nil {
+			respond(c, http.StatusInternalServerError, "Internal server error", err)
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, x)
 }
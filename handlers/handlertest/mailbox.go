@@ -0,0 +1,58 @@
+package handlertest
+
+import (
+	"strings"
+	"sync"
+
+	"avenue/backend/email"
+)
+
+// Mailbox is an email.Sender that keeps what it's sent instead of
+// delivering it, for tests that follow mail through the outbox.
+type Mailbox struct {
+	mu       sync.Mutex
+	messages []email.Message
+	failures map[string]error
+}
+
+// NewMailbox returns an empty Mailbox.
+func NewMailbox() *Mailbox {
+	return &Mailbox{failures: map[string]error{}}
+}
+
+// Send keeps msg, or returns the error set with Fail for its address.
+func (m *Mailbox) Send(msg email.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failures[strings.ToLower(msg.To)]; err != nil {
+		return err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Fail makes every delivery to the address to fail with err from now on,
+// or succeed again if err is nil.
+func (m *Mailbox) Fail(to string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		delete(m.failures, strings.ToLower(to))
+		return
+	}
+	m.failures[strings.ToLower(to)] = err
+}
+
+// Messages returns the messages delivered to the address to, oldest
+// first.
+func (m *Mailbox) Messages(to string) []email.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []email.Message
+	for _, msg := range m.messages {
+		if strings.EqualFold(msg.To, to) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,303 @@
+// Package handlertest runs the Avenue API in-process for tests: the real
+// handlers.Server behind an httptest.Server, with blobs kept in an
+// afero.NewMemMapFs and everything else either in the in-memory store or
+// in a throwaway Postgres database created for the run. Start seeds the
+// root user and hands back an sdk.Client pointed at the server along with
+// the root user's session.
+//
+// The in-memory store only covers users, sessions, files, folders, shares
+// and jobs (see package store), so routes built on the rest of the data —
+// groups, identities, notifications, the email outbox, folder copies and
+// account deletion/export — need Postgres.
+package handlertest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"avenue/backend/auth"
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/email"
+	"avenue/backend/handlers"
+	"avenue/backend/jobs"
+	"avenue/backend/quota"
+	"avenue/backend/sdk"
+	"avenue/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Options configures Start.
+type Options struct {
+	// Config is the server's config; nil uses Config().
+	Config *config.Config
+	// Postgres, if set, is the server to create a throwaway database on.
+	// Otherwise data is kept in memory.
+	Postgres *config.Database
+	// OIDC lists the SSO providers to offer, e.g. ones backed by an
+	// authtest.IdP. Logging in with them needs Postgres.
+	OIDC []*auth.OIDCProvider
+	// Email, if set, delivers the mail the email outbox sends, e.g. a
+	// Mailbox. The outbox needs Postgres; without it no mail is sent.
+	Email email.Sender
+}
+
+// Config returns the config Start uses by default: config.Defaults with
+// self registration and file and folder sharing turned on, the login and
+// forgot-password rate limits off so tests can log in as often as they
+// like, the email outbox checked for mail every 100ms, and
+// admin@example.com / password as the root user.
+func Config() *config.Config {
+	cfg := config.Defaults()
+	cfg.Server.AllowedOrigins = []string{"http://localhost"}
+	cfg.Server.RegistrationEnabled = true
+	cfg.Server.FileSharing = true
+	cfg.Server.FolderSharing = true
+	cfg.RateLimits.LoginIP = config.RateLimit{}
+	cfg.RateLimits.LoginEmail = config.RateLimit{}
+	cfg.RateLimits.ForgotPasswordIP = config.RateLimit{}
+	cfg.RateLimits.ForgotPasswordEmail = config.RateLimit{}
+	cfg.Email.OutboxPollInterval = 100 * time.Millisecond
+	cfg.RootUser = config.RootUser{Email: "admin@example.com", Password: "password"}
+	return cfg
+}
+
+// PostgresFromEnv returns the Postgres server named by AVENUE_TEST_DB_HOST,
+// or nil if it's unset. AVENUE_TEST_DB_PORT, AVENUE_TEST_DB_USER and
+// AVENUE_TEST_DB_PASSWORD default to the config's defaults, and
+// AVENUE_TEST_DB_NAME names the existing database to connect to while the
+// throwaway one is created and dropped.
+func PostgresFromEnv() *config.Database {
+	host := os.Getenv("AVENUE_TEST_DB_HOST")
+	if host == "" {
+		return nil
+	}
+	d := config.Defaults().Database
+	d.Host = host
+	for env, field := range map[string]*string{
+		"AVENUE_TEST_DB_PORT":     &d.Port,
+		"AVENUE_TEST_DB_USER":     &d.User,
+		"AVENUE_TEST_DB_PASSWORD": &d.Password,
+		"AVENUE_TEST_DB_NAME":     &d.Name,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	return &d
+}
+
+// User is an account on a test server, logged in.
+type User struct {
+	sdk.User
+	Password string
+	// Header authenticates as the user.
+	Header http.Header
+}
+
+// Server is a running test server. Call Close when done.
+type Server struct {
+	// URL is the server's base URL, e.g. for sdk.NewClient.
+	URL    string
+	Client *sdk.Client
+	// Admin is the root user.
+	Admin User
+	// Postgres reports whether data is kept in Postgres rather than in
+	// memory.
+	Postgres bool
+
+	http  *httptest.Server
+	users store.Users
+	drop  func() error
+}
+
+var (
+	// postgresInUse guards package db's single connection: only one
+	// Postgres-backed server can run per process.
+	postgresInUse atomic.Bool
+	// jobsInUse guards package jobs' handlers and queue, which are
+	// process-wide too: only one server can run at a time.
+	jobsInUse atomic.Bool
+)
+
+// Start starts a server per opts and logs in as its root user.
+func Start(opts Options) (*Server, error) {
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = Config()
+	}
+	gin.SetMode(gin.TestMode)
+
+	if !jobsInUse.CompareAndSwap(false, true) {
+		return nil, errors.New("handlertest: a server is already running")
+	}
+	s := &Server{Postgres: opts.Postgres != nil}
+	st := store.NewMemory()
+	if s.Postgres {
+		if !postgresInUse.CompareAndSwap(false, true) {
+			jobsInUse.Store(false)
+			return nil, errors.New("handlertest: a Postgres-backed server is already running")
+		}
+		drop, err := createDatabase(*opts.Postgres, &cfg.Database)
+		if err != nil {
+			postgresInUse.Store(false)
+			jobsInUse.Store(false)
+			return nil, err
+		}
+		s.drop = drop
+		st = store.Postgres()
+	}
+
+	if err := s.seedRoot(st.Users, cfg.RootUser); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	srv := handlers.SetupServer(cfg)
+	srv.SetStore(st)
+	srv.SetFS(afero.NewMemMapFs())
+	srv.SetOIDCProviders(opts.OIDC)
+	if s.Postgres {
+		email.Configure(cfg.Email)
+		if opts.Email != nil {
+			email.Default = opts.Email
+			email.StartOutboxWorker(cfg.Email)
+		}
+		quota.Watch(cfg.Quotas)
+	}
+	srv.RegisterJobs()
+	if s.Postgres {
+		jobs.Start(srv.FS(), cfg.Jobs)
+	} else {
+		jobs.StartInProcess(srv.FS(), cfg.Jobs, st.Jobs)
+	}
+	srv.SetupRoutes()
+
+	s.http = httptest.NewServer(srv.Handler())
+	s.URL = s.http.URL
+	s.Client = sdk.NewClient(s.URL)
+	s.users = st.Users
+
+	h, err := s.Login(cfg.RootUser.Email, cfg.RootUser.Password)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	root, err := st.Users.GetUserByEmail(cfg.RootUser.Email)
+	if err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("handlertest: get root user: %w", err)
+	}
+	s.Admin = User{User: root, Password: cfg.RootUser.Password, Header: h}
+	return s, nil
+}
+
+// createDatabase creates a fresh database on the server pg, connects
+// package db to it and migrates it, pointing d at it. The returned drop
+// disconnects and deletes it.
+func createDatabase(pg config.Database, d *config.Database) (func() error, error) {
+	admin, err := sqlx.Open("postgres", pg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("handlertest: open postgres: %w", err)
+	}
+	name := fmt.Sprintf("avenue_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE DATABASE ` + name); err != nil {
+		_ = admin.Close()
+		return nil, fmt.Errorf("handlertest: create database: %w", err)
+	}
+	drop := func() error {
+		if db.DB != nil {
+			_ = db.DB.Close()
+		}
+		_, err := admin.Exec(`DROP DATABASE IF EXISTS ` + name + ` WITH (FORCE)`)
+		_ = admin.Close()
+		postgresInUse.Store(false)
+		return err
+	}
+
+	*d = pg
+	d.Name = name
+	if err := db.Connect(*d); err != nil {
+		return nil, errors.Join(err, drop())
+	}
+	if err := db.RunMigrations(); err != nil {
+		return nil, errors.Join(err, drop())
+	}
+	return drop, nil
+}
+
+// seedRoot creates the root user the way the server does on startup:
+// with db.UpsertRootUser on Postgres, and as an admin in users otherwise.
+func (s *Server) seedRoot(users store.Users, root config.RootUser) error {
+	if s.Postgres {
+		if err := db.UpsertRootUser(root); err != nil {
+			return fmt.Errorf("handlertest: upsert root user: %w", err)
+		}
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(root.Password), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+	if _, err := users.CreateUser(root.Email, string(hash), "", "", true); err != nil {
+		return fmt.Errorf("handlertest: create root user: %w", err)
+	}
+	return nil
+}
+
+// CreateUser adds an account straight to the store, bypassing the API,
+// and logs in as it.
+func (s *Server) CreateUser(email, password string, isAdmin bool) (User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return User{}, err
+	}
+	u, err := s.users.CreateUser(email, string(hash), "Test", "User", isAdmin)
+	if err != nil {
+		return User{}, fmt.Errorf("handlertest: create user: %w", err)
+	}
+	h, err := s.Login(email, password)
+	if err != nil {
+		return User{}, err
+	}
+	return User{User: u, Password: password, Header: h}, nil
+}
+
+// Login logs in through the API and returns the headers that
+// authenticate as the new session.
+func (s *Server) Login(email, password string) (http.Header, error) {
+	login, err := s.Client.Login(nil, sdk.LoginRequest{Email: email, Password: password})
+	if err != nil {
+		return nil, fmt.Errorf("handlertest: log in as %s: %w", email, err)
+	}
+	h := http.Header{}
+	h.Set(handlers.AUTHHEADER, "Token "+login.SessionID)
+	return h, nil
+}
+
+// Close stops the server and drops its database, if it has one. The job
+// worker can't be stopped; the next server started hands it its own
+// queue.
+func (s *Server) Close() error {
+	if s.http != nil {
+		s.http.Close()
+	}
+	jobsInUse.Store(false)
+	if s.Postgres {
+		// Idles the outbox worker, which can't be stopped.
+		email.Default = nil
+	}
+	if s.drop != nil {
+		return s.drop()
+	}
+	return nil
+}
@@ -10,9 +10,12 @@ import (
 	"strings"
 	"time"
 
-	"avenue/backend/db"
+	"avenue/backend/auth"
+	"avenue/backend/config"
 	"avenue/backend/logger"
+	"avenue/backend/openapi"
 	"avenue/backend/shared"
+	"avenue/backend/store"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -21,16 +24,22 @@ import (
 
 // Server holds dependencies for the HTTP server.
 type Server struct {
+	cfg    *config.Config
 	router *gin.Engine
 	fs     afero.Fs
+
+	users    store.Users
+	sessions store.Sessions
+	files    store.Files
+	folders  store.Folders
+	shares   store.Shares
+	jobs     store.Jobs
+
+	authenticator auth.Authenticator
+	oidcProviders []*auth.OIDCProvider
 }
 
-var (
-	AUTHHEADER   = "Authorization"
-	APPENV       = shared.GetEnv("APP_ENV", "production")
-	COOKIEDOMAIN = shared.GetEnv("COOKIE_DOMAIN", "")
-	COOKIESECURE = APPENV == "production"
-)
+var AUTHHEADER = "Authorization"
 
 // SessionRollingWindow is how long a session (and its cookies) stays valid
 // after the most recent authenticated request.
@@ -42,24 +51,63 @@ func (s *Server) FS() afero.Fs {
 	return s.fs
 }
 
-func SetupServer() Server {
-	prod := APPENV == "production"
+// SetupServer builds the server from cfg, which must have been validated.
+func SetupServer(cfg *config.Config) Server {
+	prod := cfg.Server.Production()
 	if prod {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	r := gin.New()
+	// Only believe client IP headers set by a configured proxy; the rate
+	// limits and session list key on c.ClientIP().
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		panic(err)
+	}
+	r.RemoteIPHeaders = cfg.Server.ClientIPHeaders
 	r.Use(gin.Recovery())
 	if !prod {
 		r.Use(gin.Logger())
 	}
 
+	configureRateLimits(cfg.RateLimits)
+
 	fs := afero.NewOsFs()
-	jailedFs := afero.NewBasePathFs(fs, shared.GetEnv("UPLOAD_DIR", "./avenuectl/temp/"))
-	return Server{
+	jailedFs := afero.NewBasePathFs(fs, cfg.Storage.UploadDir)
+	srv := Server{
+		cfg:    cfg,
 		fs:     jailedFs,
 		router: r,
 	}
+	srv.SetStore(store.Postgres())
+	return srv
+}
+
+// SetStore replaces the store the handlers read and write users,
+// sessions, files, folders, shares and jobs through (Postgres by default), and
+// points the password authenticator at its users. Call SetAuthenticator
+// afterwards to use a different authenticator.
+func (s *Server) SetStore(st store.Store) {
+	s.users, s.sessions, s.files, s.folders, s.shares = st.Users, st.Sessions, st.Files, st.Folders, st.Shares
+	s.jobs = st.Jobs
+	s.authenticator = auth.PasswordAuthenticator{Users: st.Users}
+}
+
+// SetFS replaces the filesystem blobs are kept in, e.g. with
+// afero.NewMemMapFs in tests.
+func (s *Server) SetFS(fs afero.Fs) {
+	s.fs = fs
+}
+
+// Handler returns the server's routes as an http.Handler, for serving
+// them some other way than Run, e.g. with httptest.
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// SetAuthenticator replaces the backend used to check login/password pairs.
+func (s *Server) SetAuthenticator(a auth.Authenticator) {
+	s.authenticator = a
 }
 
 func (s *Server) ServeUI(uiFS embed.FS) {
@@ -143,7 +191,22 @@ func (s *Server) getAuthenticatedUserID(c *gin.Context) (int64, bool) {
 	if !ok {
 		return 0, false
 	}
-	session, valid := db.IsValidSession(token)
+	session, valid := s.sessions.IsValidSession(token)
+	if !valid {
+		return 0, false
+	}
+	return session.UserId, true
+}
+
+// sessionUserID is getAuthenticatedUserID for browser navigations: only the
+// Authorization header and the session cookie count, never a "token" query
+// param that whoever built the URL could have chosen.
+func (s *Server) sessionUserID(c *gin.Context) (int64, bool) {
+	token, ok := requestSessionToken(c, false)
+	if !ok {
+		return 0, false
+	}
+	session, valid := s.sessions.IsValidSession(token)
 	if !valid {
 		return 0, false
 	}
@@ -155,12 +218,12 @@ func (s *Server) isAuthenticated(c *gin.Context) bool {
 	if !ok {
 		return false
 	}
-	_, valid := db.IsValidSession(token)
+	_, valid := s.sessions.IsValidSession(token)
 	return valid
 }
 
 func (s *Server) userIDExists(userID string) bool {
-	_, err := db.GetUserByIDStr(userID)
+	_, err := s.users.GetUserByIDStr(userID)
 	return err == nil
 }
 
@@ -189,7 +252,7 @@ func (s *Server) sessionCheckImpl(c *gin.Context, allowQueryToken bool) {
 	}
 
 	// see if the session is valid
-	session, valid := db.IsValidSession(token)
+	session, valid := s.sessions.IsValidSession(token)
 	if !valid {
 		c.AbortWithStatus(http.StatusUnauthorized)
 		return
@@ -203,7 +266,7 @@ func (s *Server) sessionCheckImpl(c *gin.Context, allowQueryToken bool) {
 	session.ExpiresAt = time.Now().Add(SessionRollingWindow).Unix()
 
 	// update the session to be a rolling timeout
-	_, err := db.UpdateSession(session)
+	_, err := s.sessions.UpdateSession(session)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", errors.New("could not update session"))
 		return
@@ -212,8 +275,8 @@ func (s *Server) sessionCheckImpl(c *gin.Context, allowQueryToken bool) {
 	// keep the cookies' Max-Age in sync with the rolling session window,
 	// otherwise the browser drops them well before the session expires
 	maxAge := int(SessionRollingWindow.Seconds())
-	c.SetCookie(string(shared.USERCOOKIENAME), fmt.Sprint(session.UserId), maxAge, "/", COOKIEDOMAIN, COOKIESECURE, true)
-	c.SetCookie(string(shared.SESSIONCOOKIENAME), token, maxAge, "/", COOKIEDOMAIN, COOKIESECURE, true)
+	c.SetCookie(string(shared.USERCOOKIENAME), fmt.Sprint(session.UserId), maxAge, "/", s.cfg.Server.CookieDomain, s.cfg.Server.Production(), true)
+	c.SetCookie(string(shared.SESSIONCOOKIENAME), token, maxAge, "/", s.cfg.Server.CookieDomain, s.cfg.Server.Production(), true)
 
 	rc := c.Request.Context()
 
@@ -229,7 +292,7 @@ func (s *Server) sessionCheckImpl(c *gin.Context, allowQueryToken bool) {
 }
 
 func (s *Server) fileSharingRequired(c *gin.Context) {
-	if !shared.GetEnvBool("ENABLE_FILE_SHARING", false) {
+	if !s.cfg.Server.FileSharing {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
@@ -237,7 +300,7 @@ func (s *Server) fileSharingRequired(c *gin.Context) {
 }
 
 func (s *Server) folderSharingRequired(c *gin.Context) {
-	if !shared.GetEnvBool("ENABLE_FOLDER_SHARING", false) {
+	if !s.cfg.Server.FolderSharing {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
@@ -245,26 +308,15 @@ func (s *Server) folderSharingRequired(c *gin.Context) {
 }
 
 func (s *Server) SetupRoutes() {
-	allowedOrigins := shared.GetEnv("ALLOWED_ORIGINS", "")
-
-	logger.Errorf("%+v", allowedOrigins)
-
-	originsList := strings.Split(allowedOrigins, ",")
-	for i, o := range originsList {
-		originsList[i] = strings.TrimSpace(o)
-	}
-
-	logger.Errorf("%+v", originsList)
-
 	c := cors.Config{
-		AllowOrigins: originsList,
+		AllowOrigins: s.cfg.Server.AllowedOrigins,
 		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders: []string{"Origin", "Content-Type", "content-type", "Accept", "Authorization", "authorization"},
 		// The SPA now authenticates via its HttpOnly session cookie rather
 		// than a JS-attached Authorization header, so cross-origin requests
 		// (e.g. the Vite dev server on a different port) need the browser to
 		// send that cookie, which requires AllowCredentials plus an explicit
-		// origin allowlist (never "*") in ALLOWED_ORIGINS.
+		// origin allowlist (never "*") in server.allowed_origins.
 		AllowCredentials: true,
 		ExposeHeaders:    []string{"Content-Length"},
 		MaxAge:           12 * time.Hour,
@@ -275,24 +327,27 @@ func (s *Server) SetupRoutes() {
 	unsecuredRouter := s.router.Group("")
 
 	unsecuredRouter.GET("/ping", s.pingHandler)
+	unsecuredRouter.GET("/openapi.json", s.OpenAPISpec)
 	unsecuredRouter.POST("/login", s.Login)
 	unsecuredRouter.GET("/loginMeta", s.LoginMeta)
 	unsecuredRouter.POST("/register", s.Register)
 	unsecuredRouter.POST("/forgot-password", s.ForgotPassword)
 	unsecuredRouter.POST("/reset-password", s.ResetPassword)
+	unsecuredRouter.GET("/auth/oidc/:provider/login", s.OIDCLogin)
+	unsecuredRouter.GET("/auth/oidc/:provider/callback", s.OIDCCallback)
 	publicFileShare := unsecuredRouter.Group("/api/share")
-	publicFileShare.Use(s.fileSharingRequired)
+	publicFileShare.Use(s.fileSharingRequired, rateLimit(shareIPLimiter, clientIPKey), rateLimit(shareTokenLimiter, shareTokenKey))
 	publicFileShare.GET("/:token", s.GetShareLinkMeta)
 	publicFileShare.GET("/:token/download", s.DownloadSharedFile)
 	publicFolderShare := unsecuredRouter.Group("/api/share/folder")
-	publicFolderShare.Use(s.folderSharingRequired)
+	publicFolderShare.Use(s.folderSharingRequired, rateLimit(shareIPLimiter, clientIPKey), rateLimit(shareTokenLimiter, shareTokenKey))
 	publicFolderShare.GET("/:token", s.GetSharedFolderContents)
 	publicFolderShare.GET("/:token/browse/:subFolderUUID", s.BrowseSharedSubFolder)
 	publicFolderShare.GET("/:token/file/:fileUUID", s.DownloadSharedFolderFile)
 	publicFolderShare.POST("/:token/upload", s.UploadToSharedFolder)
 
 	securedRouterV1 := s.router.Group("/v1")
-	securedRouterV1.Use(s.sessionCheck)
+	securedRouterV1.Use(s.sessionCheck, rateLimit(apiUserLimiter, userKey))
 
 	securedRouterV1.GET("/ping", s.pingHandler)
 
@@ -321,13 +376,16 @@ func (s *Server) SetupRoutes() {
 	securedRouterV1.GET("/folder/files/:fileName", s.SearchFiles) // search root folder
 	securedRouterV1.GET("/folder/:folderID/files/:fileName", s.SearchFiles)
 
-	// these two are reached via direct browser navigation (img src / <a> download
+	// these are reached via direct browser navigation (img src / <a> download
 	// links) rather than fetch(), so they can't send an Authorization header and
 	// need to accept the session token as a query param instead.
 	downloadRoutesV1 := s.router.Group("/v1")
-	downloadRoutesV1.Use(s.sessionCheckAllowQueryToken)
+	downloadRoutesV1.Use(s.sessionCheckAllowQueryToken, rateLimit(apiUserLimiter, userKey))
 	downloadRoutesV1.GET("/file/:fileID", s.GetFile)
 	downloadRoutesV1.GET("/files/zip", s.DownloadFilesZip)
+	downloadRoutesV1.GET("/user/export", s.ExportOwnAccount)
+	downloadRoutesV1.GET("/user/:userID/export", s.AdminExportAccount)
+	downloadRoutesV1.GET("/jobs/:jobID/download", s.DownloadJobArchive)
 
 	securedRouterV1.PATCH("/file/:fileID/move", s.MoveFile)
 	securedRouterV1.PATCH("/file/:fileID/:fileName", s.UpdateFileName)
@@ -337,6 +395,9 @@ func (s *Server) SetupRoutes() {
 	securedRouterV1.DELETE("/files/bulk-delete", s.BulkDelete)
 	securedRouterV1.PATCH("/files/bulk-restore", s.BulkRestore)
 	securedRouterV1.PATCH("/files/bulk-move", s.BulkMove)
+	securedRouterV1.POST("/files/bulk-copy", s.BulkCopy)
+	securedRouterV1.POST("/file/:fileID/extract", s.ExtractArchive)
+	securedRouterV1.POST("/files/archive", s.CreateArchiveJob)
 
 	// -- folder routes -- //
 	securedRouterV1.POST("/folder", s.CreateFolder)
@@ -346,28 +407,57 @@ func (s *Server) SetupRoutes() {
 	securedRouterV1.GET("/folder/list/:folderID", s.ListFolderContents)
 	securedRouterV1.PATCH("/folder/:folderID/restore", s.RestoreFolder)
 	securedRouterV1.DELETE("/folder/:folderID/purge", s.PurgeFolder)
+	securedRouterV1.PUT("/folder/:folderID/quota", s.SetFolderQuota)
 
 	// -- trash routes -- //
 	securedRouterV1.GET("/trash", s.ListTrash)
 	securedRouterV1.POST("/trash/empty", s.EmptyTrash)
 
+	// -- job routes -- //
+	securedRouterV1.GET("/jobs", s.ListJobs)
+	securedRouterV1.GET("/jobs/:jobID", s.GetJob)
+	securedRouterV1.POST("/jobs/:jobID/cancel", s.CancelJob)
+
 	// --- users routes --- //
 	securedRouterV1.POST("/logout", s.Logout)
 	securedRouterV1.GET("/user/profile", s.GetProfile)
 	securedRouterV1.GET("/users", s.GetUsers)
 	securedRouterV1.POST("/user", s.CreateUser) // todo might be able to remove this route and have the ui do some work
 	securedRouterV1.PUT("/user/profile", s.UpdateProfile)
+	securedRouterV1.DELETE("/user/profile", s.DeleteOwnAccount)
 	securedRouterV1.PATCH("/user/:userID", s.UpdateProfile)
+	securedRouterV1.DELETE("/user/:userID", s.AdminDeleteAccount)
 	securedRouterV1.PATCH("/user/password", s.UpdatePassword)
 	securedRouterV1.POST("/user/:userID/send-reset-email", s.AdminSendPasswordReset)
 	securedRouterV1.GET("/user/sessions", s.ListSessions)
 	securedRouterV1.DELETE("/user/sessions/:sessionID", s.RevokeSession)
 	securedRouterV1.DELETE("/user/sessions", s.RevokeOtherSessions)
-
-}
-
-func (s *Server) Run(address string) error {
-	return s.router.Run(address)
+	securedRouterV1.GET("/user/identities", s.ListIdentities)
+	securedRouterV1.POST("/user/identities/:provider/link", s.StartIdentityLink)
+	securedRouterV1.DELETE("/user/identities/:identityID", s.UnlinkIdentity)
+	securedRouterV1.GET("/user/groups", s.ListMyGroups)
+	securedRouterV1.GET("/user/quota", s.GetQuotaStatus)
+	securedRouterV1.GET("/user/notifications", s.ListNotifications)
+	securedRouterV1.POST("/user/notifications/read", s.MarkAllNotificationsRead)
+	securedRouterV1.POST("/user/notifications/:notificationID/read", s.MarkNotificationRead)
+
+	// -- admin routes -- //
+	securedRouterV1.GET("/admin/email-templates", s.ListEmailTemplates)
+	securedRouterV1.GET("/admin/email-templates/:name/preview", s.PreviewEmailTemplate)
+	securedRouterV1.GET("/admin/emails", s.ListOutboxEmails)
+	securedRouterV1.GET("/admin/emails/:emailID", s.GetOutboxEmail)
+	securedRouterV1.POST("/admin/emails/:emailID/retry", s.RetryOutboxEmail)
+	securedRouterV1.GET("/admin/jobs", s.AdminListJobs)
+	securedRouterV1.GET("/admin/config", s.AdminGetConfig)
+	securedRouterV1.POST("/admin/import", s.AdminImport)
+	securedRouterV1.GET("/admin/groups", s.AdminListGroups)
+	securedRouterV1.POST("/admin/groups", s.AdminCreateGroup)
+	securedRouterV1.GET("/admin/groups/:groupID", s.AdminGetGroup)
+	securedRouterV1.PUT("/admin/groups/:groupID", s.AdminUpdateGroup)
+	securedRouterV1.DELETE("/admin/groups/:groupID", s.AdminDeleteGroup)
+	securedRouterV1.GET("/admin/groups/:groupID/members", s.AdminListGroupMembers)
+	securedRouterV1.PUT("/admin/groups/:groupID/members/:userID", s.AdminAddGroupMember)
+	securedRouterV1.DELETE("/admin/groups/:groupID/members/:userID", s.AdminRemoveGroupMember)
 }
 
 // pingHandler is a simple handler to check if the server is running.
@@ -376,3 +466,13 @@ func (s *Server) pingHandler(c *gin.Context) {
 	logger.Debugf("ctx val: %s", ctx.Value(shared.USERCOOKIENAME))
 	respond(c, 200, "pong", nil)
 }
+
+// OpenAPISpec serves the OpenAPI document describing this API.
+func (s *Server) OpenAPISpec(c *gin.Context) {
+	b, err := openapi.JSON()
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", b)
+}
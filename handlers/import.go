@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"avenue/backend/importer"
+	"avenue/backend/jobs"
+	"avenue/backend/sdk"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminImport imports a directory under the import directory into a user's
+// drive as a files.import job. Requires an admin caller.
+func (s *Server) AdminImport(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+	if s.cfg.Storage.ImportDir == "" {
+		respond(c, http.StatusForbidden, "", errors.New("imports are disabled; set storage.import_dir to allow them"))
+		return
+	}
+
+	var req sdk.ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+	source, err := s.importSource(req.Path)
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+	if info, err := os.Stat(source); err != nil || !info.IsDir() {
+		respond(c, http.StatusBadRequest, "", fmt.Errorf("%q is not a directory in the import directory", req.Path))
+		return
+	}
+
+	if _, err := s.users.GetUserByID(req.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "user not found", err)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
+		return
+	}
+	if req.Folder != "" {
+		if _, err := s.folders.GetFolder(req.Folder, strconv.FormatInt(req.UserID, 10)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respond(c, http.StatusNotFound, "folder not found", err)
+				return
+			}
+			respond(c, http.StatusInternalServerError, "could not get folder", err)
+			return
+		}
+	}
+
+	s.startJob(c, admin.ID, sdk.JobKindImport, req, "could not import")
+}
+
+// importSource resolves path, which must stay inside the import directory
+// once symlinks are followed, so a link under the import directory can't
+// pull in files from elsewhere on the server.
+func (s *Server) importSource(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("path %q must be relative to the import directory and stay inside it", path)
+	}
+	root, err := filepath.EvalSymlinks(s.cfg.Storage.ImportDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve import directory: %w", err)
+	}
+	source, err := filepath.EvalSymlinks(filepath.Join(root, path))
+	if err != nil {
+		return "", fmt.Errorf("%q is not a directory in the import directory", path)
+	}
+	if rel, err := filepath.Rel(root, source); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path %q must be relative to the import directory and stay inside it", path)
+	}
+	return source, nil
+}
+
+// importJob runs an import. It's idempotent, so a retried attempt skips
+// what an earlier one already imported. Progress counts files imported.
+func (s *Server) importJob(ctx context.Context, run *jobs.Run, req sdk.ImportRequest) (any, error) {
+	if s.cfg.Storage.ImportDir == "" {
+		return nil, jobs.Permanent(errors.New("imports are disabled"))
+	}
+	source, err := s.importSource(req.Path)
+	if err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	result, err := importer.Import(ctx, s.fs, s.cfg.Quotas, importer.Options{
+		Source:      source,
+		UserID:      req.UserID,
+		Folder:      req.Folder,
+		Link:        req.Link,
+		IgnoreQuota: req.IgnoreQuota,
+		CheckQuota: func(size int64) error {
+			if err := s.checkGroupQuota(req.UserID, size); err != nil {
+				return err
+			}
+			return s.checkFolderQuota(req.Folder, size)
+		},
+		Progress: run,
+	})
+	if errors.Is(err, errQuotaExceeded) || errors.Is(err, importer.ErrQuotaExceeded) || errors.Is(err, sql.ErrNoRows) {
+		return nil, jobs.Permanent(err)
+	}
+	return result, err
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"avenue/backend/config"
+)
+
+func TestImportSource(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "import")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "team", "share"), filepath.Join(root, "share"), outside} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "team"), filepath.Join(root, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	// Resolve the temp dir itself, which may sit behind a symlink.
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{cfg: &config.Config{Storage: config.Storage{ImportDir: root}}}
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "", want: root},
+		{path: ".", want: root},
+		{path: "team/share", want: filepath.Join(root, "team", "share")},
+		{path: "team/../share", want: filepath.Join(root, "share")},
+		{path: "alias/share", want: filepath.Join(root, "team", "share")},
+		{path: "escape", wantErr: true},
+		{path: "missing", wantErr: true},
+		{path: "..", wantErr: true},
+		{path: "team/../../etc", wantErr: true},
+		{path: "/etc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := s.importSource(tt.path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("importSource(%q) = %q, want an error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("importSource(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
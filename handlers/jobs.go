@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"avenue/backend/jobs"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/afero"
+)
+
+// purgeFolderPayload is the payload of a folder.purge job.
+type purgeFolderPayload struct {
+	FolderID string `json:"folderId"`
+}
+
+// RegisterJobs installs the handlers for the jobs the server enqueues. Call
+// it before jobs.Start. Purging a folder deletes its rows before its blobs,
+// so a second attempt would find nothing left to do and is never made.
+// Copies and extractions remove what they created when an attempt fails.
+func (s *Server) RegisterJobs() {
+	jobs.Register(sdk.JobKindEmptyTrash, 3, s.emptyTrashJob)
+	jobs.Register(sdk.JobKindPurgeFolder, 1, s.purgeFolderJob)
+	jobs.Register(sdk.JobKindBulkTrash, 3, s.bulkTrashJob)
+	jobs.Register(sdk.JobKindArchive, 3, s.archiveJob)
+	jobs.Register(sdk.JobKindImport, 3, s.importJob)
+	jobs.Register(sdk.JobKindCopy, 3, s.copyJob)
+	jobs.Register(sdk.JobKindExtract, 3, s.extractJob)
+}
+
+// startJob enqueues a job of kind for userID and waits up to
+// jobs.sync_wait for it. A job that finishes in time is
+// answered with 200 and the finished job (or failMsg and its error), so
+// quick operations behave much as they did when they ran inside the
+// request. Anything slower is answered with 202 and the pending job, to be
+// polled with GetJob.
+func (s *Server) startJob(c *gin.Context, userID int64, kind string, payload any, failMsg string) {
+	job, err := jobs.Enqueue(userID, kind, payload)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not start job", err)
+		return
+	}
+
+	job, err = jobs.Wait(c.Request.Context(), job.ID, s.cfg.Jobs.SyncWait)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not get job", err)
+		return
+	}
+
+	switch job.Status {
+	case sdk.JobStatusDone:
+		c.JSON(http.StatusOK, job)
+	case sdk.JobStatusFailed:
+		respond(c, http.StatusInternalServerError, failMsg, errors.New(job.Error))
+	default:
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// emptyTrashJob permanently deletes everything the job's user has trashed.
+// Items that fail are logged and skipped so one bad blob can't wedge the
+// rest; running it again picks up whatever is left.
+func (s *Server) emptyTrashJob(ctx context.Context, run *jobs.Run, _ struct{}) (any, error) {
+	userID := strconv.FormatInt(run.Job().UserID, 10)
+	var result sdk.PurgeResult
+
+	files, err := s.files.ListTrashedFiles(userID)
+	if err != nil {
+		return nil, fmt.Errorf("list trashed files: %w", err)
+	}
+	run.SetTotal(int64(len(files)))
+
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		run.Add(1)
+
+		purged, err := s.files.PurgeFileForUser(f.UUID, userID)
+		if err != nil {
+			logger.Errorf("empty trash: purge file %s: %v", f.UUID, err)
+			continue
+		}
+		s.removePurgedBlob(*purged, "empty trash")
+		result.Files++
+		result.Bytes += purged.FileSize
+	}
+
+	// Listed only now, so the total covers the folders once the files
+	// are done.
+	folders, err := s.folders.ListTrashedFolders(userID)
+	if err != nil {
+		return result, fmt.Errorf("list trashed folders: %w", err)
+	}
+	run.SetTotal(int64(len(files) + len(folders)))
+
+	for _, folder := range folders {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		run.Add(1)
+
+		purgedFiles, err := s.folders.PurgeFolder(folder.UUID, userID)
+		if err != nil {
+			logger.Errorf("empty trash: purge folder %s: %v", folder.UUID, err)
+			continue
+		}
+		result.Folders++
+		for _, f := range purgedFiles {
+			s.removePurgedBlob(f, "empty trash")
+			result.Files++
+			result.Bytes += f.FileSize
+		}
+	}
+
+	return result, nil
+}
+
+// purgeFolderJob permanently deletes a trashed folder and everything nested
+// inside it. Progress counts blobs removed from disk.
+func (s *Server) purgeFolderJob(ctx context.Context, run *jobs.Run, payload purgeFolderPayload) (any, error) {
+	userID := strconv.FormatInt(run.Job().UserID, 10)
+
+	purgedFiles, err := s.folders.PurgeFolder(payload.FolderID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, jobs.Permanent(errors.New("folder not found in trash"))
+		}
+		return nil, err
+	}
+
+	// The rows are gone, so finish removing their blobs even if the job is
+	// canceled meanwhile; otherwise they'd be orphaned on disk.
+	result := sdk.PurgeResult{Folders: 1}
+	run.SetTotal(int64(len(purgedFiles)))
+	for _, f := range purgedFiles {
+		s.removePurgedBlob(f, "purge folder")
+		run.Add(1)
+		result.Files++
+		result.Bytes += f.FileSize
+	}
+	return result, nil
+}
+
+// bulkTrashJob moves a batch of files and folders to the trash in one
+// transaction.
+func (s *Server) bulkTrashJob(_ context.Context, run *jobs.Run, req sdk.BulkDeleteRequest) (any, error) {
+	userID := strconv.FormatInt(run.Job().UserID, 10)
+
+	run.SetTotal(1)
+	if err := s.folders.BulkTrash(req.FileIDs, req.FolderIDs, userID); err != nil {
+		return nil, err
+	}
+	run.Add(1)
+	return nil, nil
+}
+
+// archiveJob builds an archive of the requested files and folders and keeps
+// it at shared.JobArtifactPath until the job is cleaned up. Progress counts
+// files added.
+func (s *Server) archiveJob(ctx context.Context, run *jobs.Run, req sdk.DownloadFilesZipRequest) (any, error) {
+	job := run.Job()
+	userID := strconv.FormatInt(job.UserID, 10)
+
+	format, formatInfo, err := parseArchiveFormat(req.Format)
+	if err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	sources, archiveName, err := s.collectArchiveSources(req, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, jobs.Permanent(err)
+		}
+		return nil, err
+	}
+	run.SetTotal(int64(len(sources)))
+
+	if err := shared.EnsureJobArtifactDir(s.fs); err != nil {
+		return nil, err
+	}
+	artifactPath := shared.JobArtifactPath(job.ID)
+	out, err := s.fs.Create(artifactPath)
+	if err != nil {
+		return nil, err
+	}
+
+	aw := newArchiveWriter(format, out)
+	manifest, err := s.writeArchive(ctx, aw, format, sources, func() { run.Add(1) })
+	if closeErr := aw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = s.fs.Remove(artifactPath)
+		return nil, err
+	}
+
+	info, err := s.fs.Stat(artifactPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdk.ArchiveResult{
+		Name:        archiveName + formatInfo.Extension,
+		ContentType: formatInfo.ContentType,
+		Size:        info.Size(),
+		Entries:     len(manifest.Entries),
+		Errors:      len(manifest.Errors),
+	}, nil
+}
+
+// removePurgedBlob removes a purged file's blob and gives its size back to
+// its owner's quota. Failures are logged; the row is already gone.
+func (s *Server) removePurgedBlob(f sdk.File, logPrefix string) {
+	if err := s.fs.Remove(shared.BlobPath(f.UUID)); err != nil && !errors.Is(err, afero.ErrFileNotFound) {
+		logger.Errorf("%s: remove blob %s: %v", logPrefix, f.UUID, err)
+	}
+	if err := s.users.UpdateUsage(f.CreatedBy, -f.FileSize); err != nil {
+		logger.Errorf("%s: update usage for user %d: %v", logPrefix, f.CreatedBy, err)
+	}
+}
+
+// validJobStatus reports whether status is usable as a job list filter.
+func validJobStatus(status string) bool {
+	switch status {
+	case "", sdk.JobStatusPending, sdk.JobStatusRunning, sdk.JobStatusDone, sdk.JobStatusFailed, sdk.JobStatusCanceled:
+		return true
+	}
+	return false
+}
+
+// CreateArchiveJob builds an archive of the requested files and folders in
+// the background, for downloads too big to stream reliably. Fetch it with
+// DownloadJobArchive once the job is done.
+func (s *Server) CreateArchiveJob(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not get user id", err)
+		return
+	}
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	var req sdk.DownloadFilesZipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "could not marshal all data to json", err)
+		return
+	}
+
+	if len(req.FileIDs) == 0 && len(req.FolderIDs) == 0 {
+		respond(c, http.StatusBadRequest, "no file or folder ids provided", nil)
+		return
+	}
+
+	if _, _, err := parseArchiveFormat(req.Format); err != nil {
+		respond(c, http.StatusBadRequest, "invalid format", err)
+		return
+	}
+
+	// Resolved here too so a bad ID is a 404 rather than a failed job.
+	if _, _, err := s.collectArchiveSources(req, userID); err != nil {
+		respondArchiveLookupError(c, err)
+		return
+	}
+
+	s.startJob(c, userIDInt, sdk.JobKindArchive, req, "could not build archive")
+}
+
+// ListJobs returns a page of the user's background jobs, newest first,
+// optionally filtered by ?status=.
+func (s *Server) ListJobs(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not get user id", err)
+		return
+	}
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	s.listJobs(c, userIDInt)
+}
+
+// AdminListJobs returns a page of every user's jobs along with system jobs
+// such as the trash sweep, newest first, optionally filtered by ?status=.
+func (s *Server) AdminListJobs(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	s.listJobs(c, 0)
+}
+
+func (s *Server) listJobs(c *gin.Context, userID int64) {
+	status := c.Query("status")
+	if !validJobStatus(status) {
+		respond(c, http.StatusBadRequest, "", fmt.Errorf("invalid status %q", status))
+		return
+	}
+
+	page, limit, offset := shared.ParsePagination(c.Query("page"), c.Query("limit"))
+
+	list, err := s.jobs.ListJobs(userID, status, limit, offset)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not list jobs", err)
+		return
+	}
+	total, err := s.jobs.CountJobs(userID, status)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not count jobs", err)
+		return
+	}
+	if list == nil {
+		list = []sdk.Job{}
+	}
+
+	c.JSON(http.StatusOK, sdk.V1JobsResponse{
+		Jobs:  list,
+		Page:  page,
+		Limit: limit,
+		Total: total,
+	})
+}
+
+// fetchOwnJob fetches the job named by the :jobID param, requiring that it
+// belong to the calling user. On failure it writes the response and returns
+// ok=false.
+func (s *Server) fetchOwnJob(c *gin.Context) (sdk.Job, bool) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not get user id", err)
+		return sdk.Job{}, false
+	}
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return sdk.Job{}, false
+	}
+
+	jobID, err := strconv.ParseInt(c.Param("jobID"), 10, 64)
+	if err != nil {
+		respond(c, http.StatusBadRequest, "invalid job id", err)
+		return sdk.Job{}, false
+	}
+
+	job, err := s.jobs.GetJob(jobID, userIDInt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "job not found", err)
+			return sdk.Job{}, false
+		}
+		respond(c, http.StatusInternalServerError, "could not get job", err)
+		return sdk.Job{}, false
+	}
+	return job, true
+}
+
+// GetJob returns one of the user's jobs with its current status and
+// progress.
+func (s *Server) GetJob(c *gin.Context) {
+	job, ok := s.fetchOwnJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob cancels one of the user's jobs. A pending job is canceled at
+// once; a running one stops within a few seconds, and the returned job has
+// CancelRequested set until it does. Jobs that have already finished can't
+// be canceled.
+func (s *Server) CancelJob(c *gin.Context) {
+	job, ok := s.fetchOwnJob(c)
+	if !ok {
+		return
+	}
+
+	canceled, err := s.jobs.RequestJobCancel(job.ID, job.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusConflict, "", errors.New("job has already finished"))
+			return
+		}
+		respond(c, http.StatusInternalServerError, "could not cancel job", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, canceled)
+}
+
+// DownloadJobArchive streams the archive built by one of the user's
+// files.archive jobs.
+func (s *Server) DownloadJobArchive(c *gin.Context) {
+	job, ok := s.fetchOwnJob(c)
+	if !ok {
+		return
+	}
+
+	if job.Kind != sdk.JobKindArchive {
+		respond(c, http.StatusBadRequest, "", errors.New("job does not build an archive"))
+		return
+	}
+	if job.Status != sdk.JobStatusDone {
+		respond(c, http.StatusConflict, "", fmt.Errorf("archive job is %s", job.Status))
+		return
+	}
+
+	var result sdk.ArchiveResult
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		respond(c, http.StatusInternalServerError, "could not read job result", err)
+		return
+	}
+
+	archive, err := s.fs.Open(shared.JobArtifactPath(job.ID))
+	if err != nil {
+		if errors.Is(err, afero.ErrFileNotFound) {
+			respond(c, http.StatusGone, "", errors.New("archive is no longer available"))
+			return
+		}
+		respond(c, http.StatusInternalServerError, "could not open archive", err)
+		return
+	}
+	defer func() {
+		_ = archive.Close()
+	}()
+
+	c.Header("Content-Type", result.ContentType)
+	c.Header("Content-Disposition", contentDispositionAttachment(result.Name))
+	c.Header("Content-Length", strconv.FormatInt(result.Size, 10))
+	c.Header("Access-Control-Expose-Headers", "Content-Disposition")
+	c.Status(http.StatusOK)
+
+	if _, err := io.Copy(s.throttleDownload(c, c.Writer, strconv.FormatInt(job.UserID, 10), ""), archive); err != nil {
+		logger.Errorf("error streaming archive of job %d: %s", job.ID, err.Error())
+	}
+}
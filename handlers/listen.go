@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"avenue/backend/config"
+	"avenue/backend/logger"
+)
+
+const (
+	// readHeaderTimeout bounds how long a client may take to send its
+	// request headers, so idle connections can't pile up.
+	readHeaderTimeout = 30 * time.Second
+	// socketMode lets a reverse proxy in the server's group connect to a
+	// Unix socket listener.
+	socketMode = 0o660
+)
+
+// Run serves the API on the configured listener, over HTTPS if a
+// certificate is configured, and the HTTP to HTTPS redirect if one is. It
+// returns when either stops.
+func (s *Server) Run() error {
+	cfg := s.cfg.Server
+
+	ln, err := listen(cfg.Listen)
+	if err != nil {
+		return err
+	}
+
+	var handler http.Handler = s.router
+	if strings.HasPrefix(cfg.Listen, config.UnixSocketPrefix) {
+		handler = unixSocketRemoteAddr(handler)
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: readHeaderTimeout}
+
+	if !cfg.TLS() {
+		logger.Infof("listening on %s", cfg.Listen)
+		return srv.Serve(ln)
+	}
+
+	certs, err := newCertReloader(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	go certs.watch(context.Background(), cfg.TLSReloadInterval)
+	srv.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.getCertificate,
+	}
+
+	errs := make(chan error, 2)
+	if cfg.HTTPRedirectListen != "" {
+		_, httpsPort, _ := net.SplitHostPort(cfg.Listen)
+		redirect := &http.Server{
+			Addr:              cfg.HTTPRedirectListen,
+			Handler:           redirectToHTTPS(httpsPort),
+			ReadHeaderTimeout: readHeaderTimeout,
+		}
+		go func() {
+			logger.Infof("redirecting HTTP on %s to HTTPS", cfg.HTTPRedirectListen)
+			errs <- fmt.Errorf("http redirect: %w", redirect.ListenAndServe())
+		}()
+	}
+	go func() {
+		logger.Infof("listening with TLS on %s", cfg.Listen)
+		errs <- srv.ServeTLS(ln, "", "")
+	}()
+	return <-errs
+}
+
+// listen opens addr, a TCP address or "unix:" and a socket path. A socket
+// left behind by a previous run is removed first.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, config.UnixSocketPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+	return ln, nil
+}
+
+// unixSocketRemoteAddr attributes requests over a Unix socket, which have
+// no remote address, to config.UnixSocketClientIP, so they pass as coming
+// from a local proxy and the client IP is taken from its headers if it's
+// trusted.
+func unixSocketRemoteAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := net.SplitHostPort(r.RemoteAddr); err != nil {
+			r.RemoteAddr = net.JoinHostPort(config.UnixSocketClientIP, "0")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// redirectToHTTPS answers every request with a permanent redirect to the
+// same URL over HTTPS on httpsPort.
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// certReloader serves a certificate loaded from files on disk and loads it
+// again when they change, so a renewed certificate is used without a
+// restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reload loads the certificate again if either file has changed since it
+// was last loaded, and reports whether it did.
+func (r *certReloader) reload() (bool, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil && bytes.Equal(r.cert.Certificate[0], cert.Certificate[0]) {
+		r.modTime = modTime
+		return false, nil
+	}
+	r.cert, r.modTime = &cert, modTime
+	return true, nil
+}
+
+// watch reloads the certificate every interval until ctx is done. A
+// certificate that fails to load, e.g. because only one of the files has
+// been replaced so far, is logged and the previous one kept.
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.reload()
+			if err != nil {
+				logger.Errorf("tls: keeping the current certificate: %v", err)
+			} else if reloaded {
+				logger.Infof("tls: loaded new certificate from %s", r.certFile)
+			}
+		}
+	}
+}
+
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("load certificate: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
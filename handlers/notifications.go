@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"avenue/backend/db"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notificationsLimit caps how many notifications ListNotifications returns.
+const notificationsLimit = 50
+
+// callerID returns the authenticated caller's id, answering 500 if there
+// isn't one.
+func callerID(c *gin.Context) (int64, bool) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not get user id", err)
+		return 0, false
+	}
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return 0, false
+	}
+	return id, true
+}
+
+// ListNotifications returns the caller's latest notifications, newest
+// first, and how many are unread.
+func (s *Server) ListNotifications(c *gin.Context) {
+	userID, ok := callerID(c)
+	if !ok {
+		return
+	}
+	notifications, unread, err := db.ListNotifications(userID, notificationsLimit)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, sdk.V1NotificationsResponse{Notifications: notifications, Unread: unread})
+}
+
+// MarkNotificationRead marks one of the caller's notifications read.
+func (s *Server) MarkNotificationRead(c *gin.Context) {
+	userID, ok := callerID(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("notificationID"), 10, 64)
+	if err != nil {
+		respond(c, http.StatusBadRequest, "invalid notification id", err)
+		return
+	}
+	if err := db.MarkNotificationRead(id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "notification not found", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// MarkAllNotificationsRead marks all of the caller's notifications read.
+func (s *Server) MarkAllNotificationsRead(c *gin.Context) {
+	userID, ok := callerID(c)
+	if !ok {
+		return
+	}
+	if err := db.MarkAllNotificationsRead(userID); err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetQuotaStatus returns how much of their quota the caller has used, the
+// limit uploads are held to, and when their grace period ends if they're
+// in one.
+func (s *Server) GetQuotaStatus(c *gin.Context) {
+	userID, ok := callerID(c)
+	if !ok {
+		return
+	}
+	status, err := s.quotaStatus(userID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"avenue/backend/auth"
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcStateCookie ties an in-flight login to the browser that started
+	// it. It holds a hash of the state, which the callback must match, so
+	// a callback URL can't be finished in anyone else's browser.
+	oidcStateCookie = "oidc_state"
+	// oidcStateMaxAge matches how long oidc_login_states rows last.
+	oidcStateMaxAge = 10 * time.Minute
+)
+
+// SetOIDCProviders registers the SSO providers users can log in with, in
+// the order they should be offered on the login page.
+func (s *Server) SetOIDCProviders(providers []*auth.OIDCProvider) {
+	s.oidcProviders = providers
+}
+
+func (s *Server) oidcProvider(id string) (*auth.OIDCProvider, bool) {
+	for _, p := range s.oidcProviders {
+		if p.Config.ID == id {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// passwordLoginEnabled reports whether local email/password login is
+// allowed. Deployments that authenticate exclusively through SSO can turn
+// it off with server.password_login_enabled.
+func (s *Server) passwordLoginEnabled() bool {
+	return s.cfg.Server.PasswordLoginEnabled
+}
+
+// loginProviders lists the configured SSO providers for LoginMeta.
+func (s *Server) loginProviders() []sdk.LoginProvider {
+	providers := make([]sdk.LoginProvider, 0, len(s.oidcProviders))
+	for _, p := range s.oidcProviders {
+		providers = append(providers, sdk.LoginProvider{
+			ID:       p.Config.ID,
+			Name:     p.Config.DisplayName,
+			LoginURL: "/auth/oidc/" + p.Config.ID + "/login",
+		})
+	}
+	return providers
+}
+
+// oidcRedirectURL is the callback URL registered with the IdP for
+// provider, derived from the incoming request the same way password reset
+// links are.
+func oidcRedirectURL(c *gin.Context, provider string) string {
+	scheme := "https"
+	if c.Request.TLS == nil {
+		scheme = "http"
+	}
+	return scheme + "://" + c.Request.Host + "/auth/oidc/" + provider + "/callback"
+}
+
+// safeReturnTo only allows same-origin, absolute-path redirects after login
+// so the callback can't be turned into an open redirect.
+func safeReturnTo(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		return "/"
+	}
+	return returnTo
+}
+
+// oidcStateHash is what the state cookie holds for state.
+func oidcStateHash(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// setOIDCStateCookie sets the state cookie to value, or clears it when
+// maxAge is negative. It's only sent to the OIDC routes, and SameSite=Lax
+// still lets the IdP's top-level redirect back to the callback carry it.
+func (s *Server) setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/auth/oidc/",
+		Domain:   s.cfg.Server.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   s.cfg.Server.Production(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// beginOIDCLogin persists a fresh state/nonce/PKCE verifier, binds the
+// state to the browser with the state cookie and returns the IdP
+// authorization URL to send the browser to. linkUserID is non-zero when
+// an authenticated user is linking a new identity.
+func (s *Server) beginOIDCLogin(c *gin.Context, p *auth.OIDCProvider, returnTo string, linkUserID int64) (string, error) {
+	state, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+	redirectURL := oidcRedirectURL(c, p.Config.ID)
+
+	if err := db.CreateOIDCLoginState(db.OIDCLoginState{
+		State:        state,
+		Provider:     p.Config.ID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectURL:  redirectURL,
+		ReturnTo:     safeReturnTo(returnTo),
+		LinkUserID:   sql.NullInt64{Int64: linkUserID, Valid: linkUserID != 0},
+	}); err != nil {
+		return "", err
+	}
+	s.setOIDCStateCookie(c, oidcStateHash(state), int(oidcStateMaxAge.Seconds()))
+
+	return p.AuthCodeURL(redirectURL, state, nonce, verifier), nil
+}
+
+// OIDCLogin — GET /auth/oidc/:provider/login
+func (s *Server) OIDCLogin(c *gin.Context) {
+	p, ok := s.oidcProvider(c.Param("provider"))
+	if !ok {
+		respond(c, http.StatusNotFound, "unknown login provider", nil)
+		return
+	}
+
+	authURL, err := s.beginOIDCLogin(c, p, c.Query("redirect"), 0)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("begin oidc login: %w", err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback — GET /auth/oidc/:provider/callback
+//
+// Validates the returned state against the database and the state cookie,
+// exchanges the code, verifies the ID token, then either links the
+// identity to the user who started a link flow (who must still be the one
+// logged in), or logs in (provisioning a new account just in time if
+// needed).
+func (s *Server) OIDCCallback(c *gin.Context) {
+	providerID := c.Param("provider")
+	p, ok := s.oidcProvider(providerID)
+	if !ok {
+		respond(c, http.StatusNotFound, "unknown login provider", nil)
+		return
+	}
+
+	if idpErr := c.Query("error"); idpErr != "" {
+		respond(c, http.StatusUnauthorized, "login was not completed", errors.New(idpErr))
+		return
+	}
+
+	cookie, err := c.Cookie(oidcStateCookie)
+	s.setOIDCStateCookie(c, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(oidcStateHash(c.Query("state")))) != 1 {
+		respond(c, http.StatusBadRequest, "", errors.New("login was not started from this browser"))
+		return
+	}
+
+	state, err := db.ConsumeOIDCLoginState(c.Query("state"), providerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusBadRequest, "", errors.New("invalid or expired login state"))
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("consume oidc state: %w", err))
+		return
+	}
+
+	claims, err := p.Exchange(c.Request.Context(), state.RedirectURL, c.Query("code"), state.Nonce, state.CodeVerifier)
+	if err != nil {
+		logger.Warnf("oidc(%s): %v", providerID, err)
+		respond(c, http.StatusUnauthorized, "", errors.New("could not verify identity provider response"))
+		return
+	}
+
+	if state.LinkUserID.Valid {
+		if userID, ok := s.sessionUserID(c); !ok || userID != state.LinkUserID.Int64 {
+			respond(c, http.StatusForbidden, "", errors.New("log in as the account the identity is being linked to"))
+			return
+		}
+		if _, err := db.LinkIdentity(state.LinkUserID.Int64, providerID, claims.Subject, claims.Email); err != nil {
+			if errors.Is(err, db.ErrIdentityLinkedElsewhere) {
+				respond(c, http.StatusConflict, "", err)
+				return
+			}
+			respond(c, http.StatusInternalServerError, "", fmt.Errorf("link identity: %w", err))
+			return
+		}
+		c.Redirect(http.StatusFound, state.ReturnTo)
+		return
+	}
+
+	u, err := s.resolveOIDCUser(p.Config, claims)
+	if err != nil {
+		var statusErr *oidcUserError
+		if errors.As(err, &statusErr) {
+			respond(c, statusErr.status, "", statusErr)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	if _, err := s.startSession(c, u); err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("create session: %w", err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, state.ReturnTo)
+}
+
+// oidcUserError is a resolveOIDCUser failure that should be reported to the
+// client with a specific status rather than as a 500.
+type oidcUserError struct {
+	status int
+	msg    string
+}
+
+func (e *oidcUserError) Error() string { return e.msg }
+
+// resolveOIDCUser finds the Avenue user for a verified identity: first by
+// an existing link, then by verified email if the provider allows linking
+// that way (see checkOIDCEmailLink), and finally by provisioning a brand
+// new account. Admin status is synced from the provider's admin claim when
+// one is configured.
+func (s *Server) resolveOIDCUser(p auth.OIDCConfig, claims auth.OIDCClaims) (sdk.User, error) {
+	providerID := p.ID
+	u, err := db.GetUserByIdentity(providerID, claims.Subject)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return sdk.User{}, fmt.Errorf("get user by identity: %w", err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		// Only trust the email for matching/provisioning once the IdP has
+		// verified it, otherwise anyone able to set an arbitrary email on
+		// their IdP profile could take over the matching Avenue account.
+		if claims.Email == "" || !claims.EmailVerified {
+			return sdk.User{}, &oidcUserError{http.StatusForbidden, "identity provider did not supply a verified email"}
+		}
+
+		u, err = s.users.GetUserByEmail(claims.Email)
+		switch {
+		case err == nil:
+			if err := checkOIDCEmailLink(p, u); err != nil {
+				return sdk.User{}, err
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			u, err = auth.ProvisionUser(claims.Email, claims.FirstName, claims.LastName, claims.AdminMapped && claims.IsAdmin)
+		}
+		if err != nil {
+			return sdk.User{}, fmt.Errorf("provision user: %w", err)
+		}
+
+		if _, err := db.LinkIdentity(u.ID, providerID, claims.Subject, claims.Email); err != nil {
+			return sdk.User{}, fmt.Errorf("link identity: %w", err)
+		}
+	}
+
+	if !u.CanLogin {
+		return sdk.User{}, &oidcUserError{http.StatusForbidden, "this account is not allowed to log in"}
+	}
+
+	if claims.AdminMapped && claims.IsAdmin != u.IsAdmin {
+		if !claims.IsAdmin {
+			// Same rule as UpdateProfile: never leave the app without an admin.
+			hasOthers, err := s.users.HasOtherAdmins(u)
+			if err != nil {
+				return sdk.User{}, fmt.Errorf("check for other admins: %w", err)
+			}
+			if !hasOthers {
+				logger.Warnf("oidc(%s): not demoting user %d, they are the last admin", providerID, u.ID)
+				return u, nil
+			}
+		}
+		if err := db.SetUserAdmin(u.ID, claims.IsAdmin); err != nil {
+			return sdk.User{}, fmt.Errorf("sync admin: %w", err)
+		}
+		u.IsAdmin = claims.IsAdmin
+	}
+
+	return u, nil
+}
+
+// checkOIDCEmailLink reports whether a first login through p, which isn't
+// linked to any account yet, may be linked to u, the existing account with
+// the same verified email.
+func checkOIDCEmailLink(p auth.OIDCConfig, u sdk.User) error {
+	if !p.LinkByEmail {
+		return &oidcUserError{http.StatusConflict, "an account with this email already exists; log in to it and link " + p.DisplayName + " from your profile"}
+	}
+	if u.IsAdmin {
+		return &oidcUserError{http.StatusConflict, "admin accounts are never linked by email; log in and link " + p.DisplayName + " from your profile"}
+	}
+	return nil
+}
+
+// StartIdentityLink — POST /v1/user/identities/:provider/link
+//
+// Returns the IdP URL the browser should visit to link an additional
+// identity to the caller's account.
+func (s *Server) StartIdentityLink(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", errors.New("user id not found"))
+		return
+	}
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", errors.New("invalid user id"))
+		return
+	}
+
+	p, ok := s.oidcProvider(c.Param("provider"))
+	if !ok {
+		respond(c, http.StatusNotFound, "unknown login provider", nil)
+		return
+	}
+
+	returnTo := c.Query("redirect")
+	if returnTo == "" {
+		returnTo = "/profile"
+	}
+
+	authURL, err := s.beginOIDCLogin(c, p, returnTo, userIDInt)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("begin oidc link: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, sdk.V1IdentityLinkResponse{URL: authURL})
+}
+
+// ListIdentities — GET /v1/user/identities
+func (s *Server) ListIdentities(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", errors.New("user id not found"))
+		return
+	}
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", errors.New("invalid user id"))
+		return
+	}
+
+	idents, err := db.ListIdentitiesForUser(userIDInt)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("list identities: %w", err))
+		return
+	}
+	if idents == nil {
+		idents = []sdk.UserIdentity{}
+	}
+	c.JSON(http.StatusOK, idents)
+}
+
+// UnlinkIdentity — DELETE /v1/user/identities/:identityID
+//
+// Unlinking the last identity is refused when password login is disabled,
+// since the user would have no way left to sign in.
+func (s *Server) UnlinkIdentity(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", errors.New("user id not found"))
+		return
+	}
+	userIDInt, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", errors.New("invalid user id"))
+		return
+	}
+
+	identityID, err := strconv.ParseInt(c.Param("identityID"), 10, 64)
+	if err != nil {
+		respond(c, http.StatusBadRequest, "", errors.New("invalid identity id"))
+		return
+	}
+
+	if !s.passwordLoginEnabled() {
+		idents, err := db.ListIdentitiesForUser(userIDInt)
+		if err != nil {
+			respond(c, http.StatusInternalServerError, "", fmt.Errorf("list identities: %w", err))
+			return
+		}
+		if len(idents) <= 1 {
+			respond(c, http.StatusBadRequest, "", errors.New("cannot unlink your only way to log in"))
+			return
+		}
+	}
+
+	if err := db.DeleteIdentityForUser(identityID, userIDInt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "identity not found", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("unlink identity: %w", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"testing"
+
+	"avenue/backend/auth"
+	"avenue/backend/sdk"
+)
+
+func TestSafeReturnTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		returnTo string
+		want     string
+	}{
+		{name: "empty", returnTo: "", want: "/"},
+		{name: "root", returnTo: "/", want: "/"},
+		{name: "app path with query", returnTo: "/folder/abc?view=grid", want: "/folder/abc?view=grid"},
+		{name: "absolute url", returnTo: "https://evil.example/", want: "/"},
+		{name: "protocol relative url", returnTo: "//evil.example/", want: "/"},
+		{name: "backslash trick", returnTo: "/\\evil.example/", want: "/"},
+		{name: "relative path", returnTo: "profile", want: "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := safeReturnTo(tt.returnTo); got != tt.want {
+				t.Errorf("safeReturnTo(%q) = %q, want %q", tt.returnTo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOIDCEmailLink(t *testing.T) {
+	user := sdk.User{ID: 7, Email: "ada@example.com"}
+	admin := sdk.User{ID: 1, Email: "ada@example.com", IsAdmin: true}
+
+	tests := []struct {
+		name        string
+		linkByEmail bool
+		user        sdk.User
+		wantErr     bool
+	}{
+		{name: "off by default", user: user, wantErr: true},
+		{name: "opted in", linkByEmail: true, user: user},
+		{name: "never an admin", linkByEmail: true, user: admin, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOIDCEmailLink(auth.OIDCConfig{ID: "mock", LinkByEmail: tt.linkByEmail}, tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkOIDCEmailLink err = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
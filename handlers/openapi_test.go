@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"avenue/backend/openapi"
+	"avenue/backend/sdk"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginParam matches a gin path parameter, ":fileID" or "*path".
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z]+)`)
+
+// openapiPath writes a gin route path the OpenAPI way.
+func openapiPath(path string) string {
+	return ginParam.ReplaceAllString(path, "{$1}")
+}
+
+func loadSpec(t *testing.T) *openapi.Document {
+	t.Helper()
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	doc := loadSpec(t)
+
+	var srv *Server
+	newMemoryServer(t, func(s *Server) { srv = s })
+
+	registered := map[string]bool{}
+	for _, r := range srv.router.Routes() {
+		key := r.Method + " " + openapiPath(r.Path)
+		registered[key] = true
+		if doc.Operation(r.Method, openapiPath(r.Path)) == nil {
+			t.Errorf("%s (%s) isn't in openapi.yaml", key, r.Handler)
+		}
+	}
+	for _, p := range doc.Paths {
+		for method := range p.Value {
+			key := strings.ToUpper(method) + " " + p.Name
+			if !registered[key] {
+				t.Errorf("openapi.yaml documents %s, which isn't a route", key)
+			}
+		}
+	}
+}
+
+// bodyRecorder keeps a copy of what a handler writes.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// validateResponses fails t for every response that doesn't match doc.
+func validateResponses(t *testing.T, doc *openapi.Document, checked *sync.Map) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if c.FullPath() == "" {
+			return
+		}
+		path := openapiPath(c.FullPath())
+		checked.Store(c.Request.Method+" "+path, true)
+		err := doc.ValidateResponse(c.Request.Method, path, w.Status(), w.Header().Get("Content-Type"), w.body.Bytes())
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	doc := loadSpec(t)
+	var checked sync.Map
+	client, h := newMemoryServer(t, func(s *Server) {
+		s.router.Use(validateResponses(t, doc, &checked))
+	})
+
+	must := func(what string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+	}
+	drain := func(what string, resp *http.Response, err error) {
+		t.Helper()
+		must(what, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	_, err := client.OpenAPISpec(nil)
+	must("openapi.json", err)
+	_, err = client.Ping(nil)
+	must("ping", err)
+	_, err = client.PingSession(h)
+	must("ping session", err)
+	_, err = client.LoginMeta(nil)
+	must("login meta", err)
+
+	must("create folder", client.CreateFolder(h, "docs", ""))
+	root, err := client.ListRootFolderContents(h, sdk.ListOptions{Type: sdk.FolderItemTypeFolder})
+	must("list root", err)
+	folder := findItem(t, root.Items, "docs")
+	must("rename folder", client.UpdateFolderName(h, folder.UUID, "papers"))
+
+	file, err := client.UploadFile(h, "notes.txt", strings.NewReader("hello"), "")
+	must("upload", err)
+	_, err = client.ListFiles(h)
+	must("list files", err)
+	_, err = client.SearchFiles(h, "", "notes")
+	must("search", err)
+	resp, err := client.DownloadFile(h, file.UUID)
+	drain("download", resp, err)
+	must("rename file", client.UpdateFileName(h, file.UUID, "renamed.txt"))
+	must("move file", client.MoveFile(h, file.UUID, folder.UUID))
+	_, err = client.ListFolderContents(h, folder.UUID, sdk.ListOptions{Sort: sdk.SortDesc, SortBy: sdk.FolderSortBySize})
+	must("list folder", err)
+
+	link, err := client.CreateShareLink(h, file.UUID, sdk.CreateShareLinkRequest{})
+	must("share", err)
+	_, err = client.GetShareLinkMeta(nil, link.Token)
+	must("share meta", err)
+	resp, err = client.DownloadSharedFile(nil, link.Token)
+	drain("shared download", resp, err)
+	_, err = client.ListFileShares(h, file.UUID)
+	must("list file shares", err)
+	_, err = client.ListUserShares(h)
+	must("list shares", err)
+	must("revoke share", client.RevokeShareLink(h, link.Token))
+	if _, err := client.GetShareLinkMeta(nil, link.Token); err == nil {
+		t.Error("revoked share link still works")
+	}
+
+	must("trash file", client.DeleteFile(h, file.UUID))
+	_, err = client.ListTrash(h, sdk.ListOptions{SortBy: sdk.TrashSortByName})
+	must("list trash", err)
+	_, err = client.RestoreFile(h, file.UUID)
+	must("restore file", err)
+	_, err = client.DeleteFolder(h, folder.UUID)
+	must("trash folder", err)
+	_, err = client.RestoreFolder(h, folder.UUID)
+	must("restore folder", err)
+
+	me, err := client.GetProfile(h)
+	must("profile", err)
+	first, last := "Renamed", "User"
+	_, err = client.UpdateProfile(h, sdk.UpdateProfileRequest{ID: me.ID, FirstName: &first, LastName: &last})
+	must("update profile", err)
+	_, err = client.ListSessions(h)
+	must("list sessions", err)
+	if _, err := client.ListSessions(nil); err == nil {
+		t.Error("listed sessions without a session")
+	}
+	_, err = client.Logout(h)
+	must("logout", err)
+
+	n := 0
+	checked.Range(func(any, any) bool { n++; return true })
+	if n == 0 {
+		t.Fatal("no responses were checked against the spec")
+	}
+}
@@ -1,15 +1,13 @@
 package handlers
 
 import (
-	"bytes"
-	"embed"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"html/template"
 	"net/http"
 	"strconv"
 
-	"avenue/backend/db"
 	"avenue/backend/email"
 	"avenue/backend/logger"
 	"avenue/backend/sdk"
@@ -18,21 +16,6 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-//go:embed templates/forgot_password.html
-var forgotPasswordTmplFS embed.FS
-
-var forgotPasswordTmpl = template.Must(
-	template.New("forgot_password.html").ParseFS(forgotPasswordTmplFS, "templates/forgot_password.html"),
-)
-
-func forgotPasswordHTML(resetURL string) string {
-	var buf bytes.Buffer
-	if err := forgotPasswordTmpl.Execute(&buf, resetURL); err != nil {
-		return resetURL
-	}
-	return buf.String()
-}
-
 func (s *Server) ForgotPassword(c *gin.Context) {
 	var req sdk.ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -43,12 +26,16 @@ func (s *Server) ForgotPassword(c *gin.Context) {
 	// Rate limit before touching the DB so the check can't be used to
 	// distinguish registered from unregistered emails by timing/behavior.
 	// Still returns 204 on limit so the response looks identical either way.
-	if !forgotPasswordIPLimiter.allow(c.ClientIP()) || !forgotPasswordEmailLimiter.allow(normalizeEmailKey(req.Email)) {
+	if ok, _ := forgotPasswordIPLimiter.allow(c.ClientIP()); !ok {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if ok, _ := forgotPasswordEmailLimiter.allow(normalizeEmailKey(req.Email)); !ok {
 		c.Status(http.StatusNoContent)
 		return
 	}
 
-	user, err := db.GetUserByEmail(req.Email)
+	user, err := s.users.GetUserByEmail(req.Email)
 	if err != nil {
 		// Return success regardless so we don't leak which emails are registered.
 		c.Status(http.StatusNoContent)
@@ -60,7 +47,7 @@ func (s *Server) ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	token, err := db.CreatePasswordResetToken(user.ID)
+	token, err := s.users.CreatePasswordResetToken(user.ID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", errors.New("could not create reset token"))
 		return
@@ -72,13 +59,12 @@ func (s *Server) ForgotPassword(c *gin.Context) {
 	}
 	resetURL := scheme + "://" + c.Request.Host + "/reset-password?token=" + token
 
-	if err := email.Send(email.Message{
-		To:      user.Email,
-		Subject: "Reset your Avenue password",
-		HTML:    forgotPasswordHTML(resetURL),
-		Text:    "You requested a password reset for your Avenue account.\n\nClick the link below to set a new password:\n\n" + resetURL + "\n\nThis link expires in 1 hour. If you did not request this, you can safely ignore this email.",
-	}); err != nil {
-		if !errors.Is(err, email.ErrNotConfigured) {
+	msg, err := email.NewMessage(user.Email, email.TemplateForgotPassword, email.TemplateData{URL: resetURL})
+	if err != nil {
+		logger.Errorf("email(forgot password): %v", err)
+	} else {
+		msg.DedupKey = tokenDedupKey("forgot_password", token)
+		if err := email.Send(msg); err != nil && !errors.Is(err, email.ErrNotConfigured) {
 			logger.Errorf("email(forgot password): %v", err)
 		}
 	}
@@ -93,13 +79,13 @@ func (s *Server) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	userID, err := db.ConsumePasswordResetToken(req.Token)
+	userID, err := s.users.ConsumePasswordResetToken(req.Token)
 	if err != nil {
 		respond(c, http.StatusBadRequest, "", errors.New("invalid or expired reset token"))
 		return
 	}
 
-	user, err := db.GetUserByID(userID)
+	user, err := s.users.GetUserByID(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", errors.New("user not found"))
 		return
@@ -111,20 +97,21 @@ func (s *Server) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := db.UpdatePassword(userID, string(hashed)); err != nil {
+	if err := s.users.UpdatePassword(userID, string(hashed)); err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("update password: %w", err))
 		return
 	}
 
-	if err := db.DeleteSessionsForUser(userID); err != nil {
+	if err := s.sessions.DeleteSessionsForUser(userID); err != nil {
 		logger.Errorf("revoke sessions after password reset: %v", err)
 	}
 
 	if err := email.Send(email.Message{
-		To:      user.Email,
-		Subject: "Your password was changed",
-		Text:    "Your Avenue account password was just changed via a password reset. If you did not make this change, please contact your administrator immediately.",
-	}); err != nil {
+		To:       user.Email,
+		DedupKey: tokenDedupKey("password_changed", req.Token),
+		Subject:  "Your password was changed",
+		Text:     "Your Avenue account password was just changed via a password reset. If you did not make this change, please contact your administrator immediately.",
+	}); err != nil && !errors.Is(err, email.ErrNotConfigured) {
 		logger.Errorf("email(reset password confirmation): %v", err)
 	}
 
@@ -132,7 +119,7 @@ func (s *Server) ResetPassword(c *gin.Context) {
 }
 
 func (s *Server) AdminSendPasswordReset(c *gin.Context) {
-	caller, ok := requireAdmin(c)
+	caller, ok := s.requireAdmin(c)
 	if !ok {
 		return
 	}
@@ -143,13 +130,13 @@ func (s *Server) AdminSendPasswordReset(c *gin.Context) {
 		return
 	}
 
-	target, err := db.GetUserByID(targetID)
+	target, err := s.users.GetUserByID(targetID)
 	if err != nil {
 		respond(c, http.StatusNotFound, "", errors.New("user not found"))
 		return
 	}
 
-	token, err := db.CreatePasswordResetToken(target.ID)
+	token, err := s.users.CreatePasswordResetToken(target.ID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("create reset token: %w", err))
 		return
@@ -161,20 +148,30 @@ func (s *Server) AdminSendPasswordReset(c *gin.Context) {
 	}
 	resetURL := scheme + "://" + c.Request.Host + "/reset-password?token=" + token
 
-	if err := email.Send(email.Message{
-		To:      target.Email,
-		Subject: "Reset your Avenue password",
-		HTML:    forgotPasswordHTML(resetURL),
-		Text:    "An administrator has sent you a password reset for your Avenue account.\n\nClick the link below to set a new password:\n\n" + resetURL + "\n\nThis link expires in 1 hour.",
-	}); err != nil {
+	msg, err := email.NewMessage(target.Email, email.TemplateAdminPasswordReset, email.TemplateData{URL: resetURL})
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("render email: %w", err))
+		return
+	}
+	msg.DedupKey = tokenDedupKey("admin_password_reset", token)
+
+	if err := email.Send(msg); err != nil {
 		if errors.Is(err, email.ErrNotConfigured) {
 			respond(c, http.StatusServiceUnavailable, "", errors.New("email is not configured"))
 			return
 		}
-		respond(c, http.StatusInternalServerError, "", fmt.Errorf("send email: %w", err))
+		respond(c, http.StatusInternalServerError, "", fmt.Errorf("enqueue email: %w", err))
 		return
 	}
 
-	logger.Infof("admin password reset email sent: target=%d by=%d", target.ID, caller.ID)
+	logger.Infof("admin password reset email queued: target=%d by=%d", target.ID, caller.ID)
 	c.Status(http.StatusNoContent)
 }
+
+// tokenDedupKey is the outbox dedup key for kind of mail about token, a
+// password reset token. It holds a hash of the token rather than the token
+// itself, which would be enough to reset the password.
+func tokenDedupKey(kind, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return kind + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"avenue/backend/db"
+	"avenue/backend/email"
+	"avenue/backend/logger"
+	"avenue/backend/quota"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errQuotaExceeded is returned when a write would take a folder or group
+// past its quota. Handlers answer it with 422.
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// unlimited is the room left where no quota applies.
+const unlimited int64 = -1
+
+// quotaRoom returns how many more bytes fit under the tightest of quotas, or
+// unlimited if there are none. Quotas already exceeded leave no room.
+func quotaRoom(quotas, used []int64) (room int64, tightest int) {
+	room, tightest = unlimited, -1
+	for i, quota := range quotas {
+		left := max(quota-used[i], 0)
+		if room == unlimited || left < room {
+			room, tightest = left, i
+		}
+	}
+	return room, tightest
+}
+
+// quotaStatus is quota.Status for userID, read through s.users.
+func (s *Server) quotaStatus(userID int64) (sdk.QuotaStatus, error) {
+	status, err := s.users.GetQuotaStatus(userID)
+	if err != nil {
+		return sdk.QuotaStatus{}, err
+	}
+	return quota.WithLimit(s.cfg.Quotas, status), nil
+}
+
+// folderRoom returns how many more bytes fit in folderID given its own
+// quota and those of its ancestors, and the quota that binds.
+func (s *Server) folderRoom(folderID string) (int64, sdk.FolderQuota, error) {
+	if folderID == "" {
+		return unlimited, sdk.FolderQuota{}, nil
+	}
+	quotas, err := s.folders.ListFolderQuotas(folderID)
+	if err != nil {
+		return 0, sdk.FolderQuota{}, err
+	}
+	limits, used := make([]int64, len(quotas)), make([]int64, len(quotas))
+	for i, q := range quotas {
+		limits[i], used[i] = q.Quota, q.Used
+	}
+	room, i := quotaRoom(limits, used)
+	if i < 0 {
+		return room, sdk.FolderQuota{}, nil
+	}
+	return room, quotas[i], nil
+}
+
+// groupRoom returns how many more bytes userID may store given the pooled
+// quotas of the groups they're in, and the group that binds.
+func (s *Server) groupRoom(userID int64) (int64, sdk.Group, error) {
+	groups, err := s.users.ListUserGroups(userID)
+	if err != nil {
+		return 0, sdk.Group{}, err
+	}
+	var limits, used []int64
+	var limited []sdk.Group
+	for _, g := range groups {
+		if g.Quota > 0 {
+			limits, used, limited = append(limits, g.Quota), append(used, g.Used), append(limited, g)
+		}
+	}
+	room, i := quotaRoom(limits, used)
+	if i < 0 {
+		return room, sdk.Group{}, nil
+	}
+	return room, limited[i], nil
+}
+
+// checkFolderQuota fails with errQuotaExceeded if adding size bytes to
+// folderID would take it or an ancestor past its quota.
+func (s *Server) checkFolderQuota(folderID string, size int64) error {
+	room, q, err := s.folderRoom(folderID)
+	if err != nil {
+		return err
+	}
+	if room != unlimited && size > room {
+		return fmt.Errorf("%w: folder %q has %d of %d bytes free", errQuotaExceeded, q.Name, room, q.Quota)
+	}
+	return nil
+}
+
+// checkMoveQuota is checkFolderQuota for moving files and folders into
+// folderID; only what isn't already inside the quota's folder counts.
+func (s *Server) checkMoveQuota(folderID string, fileIDs, folderIDs []string, userID string) error {
+	quotas, err := s.folders.ListFolderQuotas(folderID)
+	if err != nil {
+		return err
+	}
+	for _, q := range quotas {
+		entering, err := s.folders.BytesEnteringFolder(q.FolderID, fileIDs, folderIDs, userID)
+		if err != nil {
+			return err
+		}
+		if entering > 0 && q.Used+entering > q.Quota {
+			return fmt.Errorf("%w: folder %q has %d of %d bytes free", errQuotaExceeded, q.Name, max(q.Quota-q.Used, 0), q.Quota)
+		}
+	}
+	return nil
+}
+
+// checkGroupQuota fails with errQuotaExceeded if userID storing size more
+// bytes would take one of their groups past its quota.
+func (s *Server) checkGroupQuota(userID, size int64) error {
+	room, g, err := s.groupRoom(userID)
+	if err != nil {
+		return err
+	}
+	if room != unlimited && size > room {
+		return fmt.Errorf("%w: group %q has %d of %d bytes free", errQuotaExceeded, g.Name, room, g.Quota)
+	}
+	return nil
+}
+
+// respondQuotaErr answers a failed quota check: 422 if over quota, 500 if
+// the check itself failed.
+func respondQuotaErr(c *gin.Context, err error) {
+	if errors.Is(err, errQuotaExceeded) {
+		respond(c, http.StatusUnprocessableEntity, "", err)
+		return
+	}
+	respond(c, http.StatusInternalServerError, "", err)
+}
+
+// quotaReader reads from r until more than left bytes have gone by, then
+// fails with err, or errQuotaExceeded if that's nil, so an upload stops as
+// soon as it's too big. err should wrap errQuotaExceeded and say which
+// quota was hit.
+type quotaReader struct {
+	r    io.Reader
+	left int64
+	err  error
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	q.left -= int64(n)
+	if q.left < 0 {
+		if q.err != nil {
+			return n, q.err
+		}
+		return n, errQuotaExceeded
+	}
+	return n, err
+}
+
+// limitToQuotas wraps r, an upload that has already had written bytes read
+// from it, so it fails with errQuotaExceeded once it no longer fits in
+// folderRoom or groupRoom, whichever runs out first. Either may be
+// unlimited.
+func limitToQuotas(r io.Reader, written, folderRoom int64, folder sdk.FolderQuota, groupRoom int64, group sdk.Group) io.Reader {
+	if folderRoom != unlimited {
+		r = &quotaReader{r: r, left: folderRoom - written, err: fmt.Errorf("%w: folder %q has %d of %d bytes free", errQuotaExceeded, folder.Name, folderRoom, folder.Quota)}
+	}
+	if groupRoom != unlimited {
+		r = &quotaReader{r: r, left: groupRoom - written, err: fmt.Errorf("%w: group %q has %d of %d bytes free", errQuotaExceeded, group.Name, groupRoom, group.Quota)}
+	}
+	return r
+}
+
+// checkGroupSoftQuotas emails the members of each of userID's groups that
+// has just passed its soft quota, once until it drops back under.
+func (s *Server) checkGroupSoftQuotas(userID int64) {
+	groups, err := s.users.ListUserGroups(userID)
+	if err != nil {
+		logger.Errorf("group soft quota: %v", err)
+		return
+	}
+	for _, g := range groups {
+		if g.SoftQuota <= 0 {
+			continue
+		}
+		if g.Used < g.SoftQuota {
+			if err := db.ClearGroupSoftWarning(g.ID); err != nil {
+				logger.Errorf("group soft quota: %v", err)
+			}
+			continue
+		}
+
+		first, err := db.MarkGroupSoftWarned(g.ID)
+		if err != nil {
+			logger.Errorf("group soft quota: %v", err)
+			continue
+		}
+		if first {
+			warnGroupMembers(g)
+		}
+	}
+}
+
+func warnGroupMembers(g sdk.Group) {
+	members, err := db.ListGroupMembers(g.ID)
+	if err != nil {
+		logger.Errorf("group soft quota: %v", err)
+		return
+	}
+	data := email.TemplateData{
+		Name:  g.Name,
+		Used:  shared.FormatBytes(g.Used),
+		Limit: shared.FormatBytes(g.SoftQuota),
+	}
+	for _, m := range members {
+		msg, err := email.NewMessage(m.Email, email.TemplateGroupQuotaWarning, data)
+		if err != nil {
+			logger.Errorf("email(group quota): %v", err)
+			return
+		}
+		if err := email.Send(msg); err != nil && !errors.Is(err, email.ErrNotConfigured) {
+			logger.Errorf("email(group quota): %v", err)
+		}
+	}
+}
+
+// SetFolderQuota caps how much the caller's folder and everything under it
+// may hold. A quota of 0 removes the cap.
+func (s *Server) SetFolderQuota(c *gin.Context) {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "could not get user id", err)
+		return
+	}
+
+	var req sdk.SetFolderQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+	if req.Quota < 0 {
+		respond(c, http.StatusBadRequest, "quota can't be negative", nil)
+		return
+	}
+
+	folderID := c.Param("folderID")
+	if err := s.folders.SetFolderQuota(folderID, userID, req.Quota); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "folder not found", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	quotas, err := s.folders.ListFolderQuotas(folderID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, quotas)
+}
+
+// int64Param parses the named path parameter, answering 400 if it's
+// not a number.
+func int64Param(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		respond(c, http.StatusBadRequest, "invalid "+name, err)
+		return 0, false
+	}
+	return id, true
+}
+
+func validateGroupRequest(req sdk.GroupRequest) error {
+	switch {
+	case req.Name == "":
+		return errors.New("name is required")
+	case req.Quota < 0 || req.SoftQuota < 0:
+		return errors.New("quotas can't be negative")
+	case req.Quota > 0 && req.SoftQuota > req.Quota:
+		return errors.New("soft quota can't be above the quota")
+	}
+	return nil
+}
+
+func (s *Server) AdminListGroups(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	groups, err := db.ListGroups()
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+func (s *Server) AdminGetGroup(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	id, ok := int64Param(c, "groupID")
+	if !ok {
+		return
+	}
+	g, err := db.GetGroup(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "group not found", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, g)
+}
+
+func (s *Server) AdminCreateGroup(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	var req sdk.GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+	if err := validateGroupRequest(req); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+	g, err := db.CreateGroup(req)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusCreated, g)
+}
+
+func (s *Server) AdminUpdateGroup(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	id, ok := int64Param(c, "groupID")
+	if !ok {
+		return
+	}
+	var req sdk.GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+	if err := validateGroupRequest(req); err != nil {
+		respond(c, http.StatusBadRequest, "", err)
+		return
+	}
+	g, err := db.UpdateGroup(id, req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "group not found", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, g)
+}
+
+func (s *Server) AdminDeleteGroup(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	id, ok := int64Param(c, "groupID")
+	if !ok {
+		return
+	}
+	if err := db.DeleteGroup(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "group not found", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) AdminListGroupMembers(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	id, ok := int64Param(c, "groupID")
+	if !ok {
+		return
+	}
+	members, err := db.ListGroupMembers(id)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+func (s *Server) AdminAddGroupMember(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	id, ok := int64Param(c, "groupID")
+	if !ok {
+		return
+	}
+	userID, ok := int64Param(c, "userID")
+	if !ok {
+		return
+	}
+	if _, err := db.GetGroup(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "group not found", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	if _, err := s.users.GetUserByIDStr(strconv.FormatInt(userID, 10)); err != nil {
+		respond(c, http.StatusNotFound, "user not found", nil)
+		return
+	}
+	if err := db.AddGroupMember(id, userID); err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	s.checkGroupSoftQuotas(userID)
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) AdminRemoveGroupMember(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	id, ok := int64Param(c, "groupID")
+	if !ok {
+		return
+	}
+	userID, ok := int64Param(c, "userID")
+	if !ok {
+		return
+	}
+	if err := db.RemoveGroupMember(id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respond(c, http.StatusNotFound, "user is not in the group", nil)
+			return
+		}
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListMyGroups returns the groups the caller belongs to, with how much of
+// each one's pooled quota is used.
+func (s *Server) ListMyGroups(c *gin.Context) {
+	userID, ok := callerID(c)
+	if !ok {
+		return
+	}
+	groups, err := s.users.ListUserGroups(userID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
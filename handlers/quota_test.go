@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"avenue/backend/sdk"
+	"avenue/backend/store"
+
+	"github.com/spf13/afero"
+)
+
+func TestQuotaRoom(t *testing.T) {
+	tests := []struct {
+		name         string
+		quotas, used []int64
+		wantRoom     int64
+		wantTightest int
+	}{
+		{name: "no quotas", wantRoom: unlimited, wantTightest: -1},
+		{name: "one quota", quotas: []int64{100}, used: []int64{40}, wantRoom: 60, wantTightest: 0},
+		{name: "tightest wins", quotas: []int64{100, 1000}, used: []int64{10, 950}, wantRoom: 50, wantTightest: 1},
+		{name: "full", quotas: []int64{100}, used: []int64{100}, wantRoom: 0, wantTightest: 0},
+		{name: "over quota leaves no room", quotas: []int64{1000, 100}, used: []int64{0, 250}, wantRoom: 0, wantTightest: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room, tightest := quotaRoom(tt.quotas, tt.used)
+			if room != tt.wantRoom || tightest != tt.wantTightest {
+				t.Errorf("got (%d, %d), want (%d, %d)", room, tightest, tt.wantRoom, tt.wantTightest)
+			}
+		})
+	}
+}
+
+func TestQuotaReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		left    int64
+		wantErr bool
+	}{
+		{name: "under", input: "hello", left: 10},
+		{name: "exactly", input: "hello", left: 5},
+		{name: "over", input: "hello world", left: 5, wantErr: true},
+		{name: "none left", input: "", left: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			_, err := io.Copy(&out, &quotaReader{r: strings.NewReader(tt.input), left: tt.left})
+			if got := errors.Is(err, errQuotaExceeded); got != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out.String() != tt.input {
+				t.Errorf("read %q, want %q", out.String(), tt.input)
+			}
+		})
+	}
+}
+
+// groupedUsers puts every user in groups, which the memory store has none
+// of. groups may be changed while the server is running.
+type groupedUsers struct {
+	store.Users
+
+	mu     sync.Mutex
+	groups []sdk.Group
+}
+
+func (u *groupedUsers) ListUserGroups(int64) ([]sdk.Group, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.groups, nil
+}
+
+func (u *groupedUsers) setGroups(groups ...sdk.Group) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.groups = groups
+}
+
+func wantQuotaExceeded(t *testing.T, what string, err error) {
+	t.Helper()
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("%s: err = %v, want a 422", what, err)
+	}
+	if apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Code != sdk.ErrorCodeQuotaExceeded {
+		t.Fatalf("%s: got %d %q, want 422 %q", what, apiErr.StatusCode, apiErr.Code, sdk.ErrorCodeQuotaExceeded)
+	}
+}
+
+// TestQuotasEnforced leaves 4 bytes of room in a folder, either through
+// its own quota or through the user's group's, and checks that everything
+// that writes more than that into it is refused.
+func TestQuotasEnforced(t *testing.T) {
+	for _, kind := range []string{"folder", "group"} {
+		t.Run(kind, func(t *testing.T) {
+			users := &groupedUsers{}
+			var srv *Server
+			client, h := newMemoryServer(t, func(s *Server) {
+				s.cfg.Server.FolderSharing = true
+				users.Users = s.users
+				s.users = users
+				srv = s
+			})
+
+			// Everything to be copied, moved or extracted is uploaded to the
+			// root before any quota is set.
+			if err := client.CreateFolder(h, "small", ""); err != nil {
+				t.Fatalf("create folder: %v", err)
+			}
+			if err := client.CreateFolder(h, "outside", ""); err != nil {
+				t.Fatalf("create folder: %v", err)
+			}
+			root, err := client.ListFolderContents(h, "", sdk.ListOptions{Limit: 50})
+			if err != nil {
+				t.Fatalf("list root: %v", err)
+			}
+			small, outside := findItem(t, root.Items, "small"), findItem(t, root.Items, "outside")
+			big, err := client.UploadFile(h, "big.txt", strings.NewReader("too big"), "")
+			if err != nil {
+				t.Fatalf("upload: %v", err)
+			}
+			if _, err := client.UploadFile(h, "nested.txt", strings.NewReader("too big"), outside.UUID); err != nil {
+				t.Fatalf("upload: %v", err)
+			}
+			archiveFS := afero.NewMemMapFs()
+			writeTestZip(t, archiveFS, "a.zip", []testArchiveEntry{{name: "a.txt", content: "too big"}})
+			zipData, err := afero.ReadFile(archiveFS, "a.zip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive, err := client.UploadFile(h, "a.zip", bytes.NewReader(zipData), "")
+			if err != nil {
+				t.Fatalf("upload archive: %v", err)
+			}
+			link, err := client.CreateFolderShareLink(h, small.UUID, sdk.CreateShareLinkRequest{AllowUpload: true})
+			if err != nil {
+				t.Fatalf("share folder: %v", err)
+			}
+
+			if kind == "folder" {
+				if _, err := client.SetFolderQuota(h, small.UUID, 4); err != nil {
+					t.Fatalf("set quota: %v", err)
+				}
+			} else {
+				users.setGroups(sdk.Group{ID: 1, Name: "team", Quota: 100, Used: 96})
+			}
+
+			_, err = client.UploadFile(h, "new.txt", strings.NewReader("too big"), small.UUID)
+			wantQuotaExceeded(t, "upload", err)
+			err = client.UploadToSharedFolder(nil, link.Token, "new.txt", strings.NewReader("too big"), "")
+			wantQuotaExceeded(t, "share upload", err)
+			_, err = client.BulkCopy(h, sdk.BulkCopyRequest{FileIDs: []string{big.UUID}, Parent: small.UUID})
+			wantQuotaExceeded(t, "copy", err)
+			// Extraction checks the quotas in its job, which this server
+			// doesn't run.
+			if _, err := srv.checkExtraction(archive.CreatedBy, &archive, "zip", sdk.Folder{UUID: small.UUID}); !errors.Is(err, errQuotaExceeded) {
+				t.Fatalf("extract: err = %v, want errQuotaExceeded", err)
+			}
+
+			// Moving doesn't add to what the user stores, so only a folder
+			// quota can refuse it.
+			if kind == "folder" {
+				err = client.MoveFile(h, big.UUID, small.UUID)
+				wantQuotaExceeded(t, "move", err)
+				_, err = client.BulkMove(h, sdk.BulkMoveRequest{FolderIDs: []string{outside.UUID}, Parent: small.UUID})
+				wantQuotaExceeded(t, "bulk move", err)
+			}
+
+			if _, err := client.UploadFile(h, "ok.txt", strings.NewReader("fits"), small.UUID); err != nil {
+				t.Fatalf("upload that fits: %v", err)
+			}
+		})
+	}
+}
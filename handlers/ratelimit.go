@@ -1,73 +1,214 @@
 package handlers
 
 import (
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/shared"
+
+	"github.com/gin-gonic/gin"
 )
 
-// slidingWindowLimiter counts recent events per key and rejects once a key
-// exceeds max events within window. It's process-local (no shared store),
-// which is sufficient for Avenue's single-instance deployment model and
-// avoids adding an infra dependency just to slow down login/reset abuse.
-type slidingWindowLimiter struct {
+// rateLimitStore counts attempts per key. allow records an attempt and
+// reports whether key is still within max attempts per window, and if not,
+// how long until it will be; reset forgets key's attempts.
+type rateLimitStore interface {
+	allow(key string, max int, window time.Duration) (bool, time.Duration, error)
+	reset(key string) error
+}
+
+// rateLimits is the store every limiter counts in. It's process-local
+// unless the config selects a shared one; see configureRateLimits.
+var rateLimits rateLimitStore = newMemoryRateLimitStore()
+
+// configureRateLimits selects the store cfg names: "memory" keeps counts
+// in this process, which is fine for a single instance; "postgres" shares
+// them between every instance behind a load balancer. It then sets each
+// limiter to its configured limit.
+func configureRateLimits(cfg config.RateLimits) {
+	if cfg.Store == config.RateLimitStorePostgres {
+		rateLimits = postgresRateLimitStore{}
+	} else {
+		rateLimits = newMemoryRateLimitStore()
+	}
+
+	for l, limit := range map[*slidingWindowLimiter]config.RateLimit{
+		loginIPLimiter:             cfg.LoginIP,
+		loginEmailLimiter:          cfg.LoginEmail,
+		forgotPasswordIPLimiter:    cfg.ForgotPasswordIP,
+		forgotPasswordEmailLimiter: cfg.ForgotPasswordEmail,
+		shareIPLimiter:             cfg.ShareIP,
+		shareTokenLimiter:          cfg.ShareToken,
+		apiUserLimiter:             cfg.APIUser,
+	} {
+		l.max, l.window = limit.Max, limit.Window
+	}
+}
+
+// memoryRateLimitStore is a sliding window counter kept in this process.
+type memoryRateLimitStore struct {
 	mu     sync.Mutex
-	max    int
-	window time.Duration
 	events map[string][]time.Time
 }
 
-func newSlidingWindowLimiter(max int, window time.Duration) *slidingWindowLimiter {
-	return &slidingWindowLimiter{
-		max:    max,
-		window: window,
-		events: make(map[string][]time.Time),
-	}
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{events: make(map[string][]time.Time)}
 }
 
-// allow records an attempt for key and reports whether it's still within
-// the limit. Expired timestamps are pruned on access so the map can't grow
-// without bound for a key that stops being used.
-func (l *slidingWindowLimiter) allow(key string) bool {
+// allow prunes expired timestamps on access so the map can't grow without
+// bound for a key that stops being used.
+func (s *memoryRateLimitStore) allow(key string, max int, window time.Duration) (bool, time.Duration, error) {
 	now := time.Now()
-	cutoff := now.Add(-l.window)
+	cutoff := now.Add(-window)
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	kept := l.events[key][:0]
-	for _, t := range l.events[key] {
+	kept := s.events[key][:0]
+	for _, t := range s.events[key] {
 		if t.After(cutoff) {
 			kept = append(kept, t)
 		}
 	}
 
-	if len(kept) >= l.max {
-		l.events[key] = kept
-		return false
+	if len(kept) >= max {
+		s.events[key] = kept
+		return false, kept[0].Add(window).Sub(now), nil
 	}
 
-	l.events[key] = append(kept, now)
-	return true
+	s.events[key] = append(kept, now)
+	return true, 0, nil
+}
+
+func (s *memoryRateLimitStore) reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.events, key)
+	return nil
+}
+
+// postgresRateLimitStore is a sliding window counter in the
+// rate_limit_hits table, shared by every instance.
+type postgresRateLimitStore struct{}
+
+func (postgresRateLimitStore) allow(key string, max int, window time.Duration) (bool, time.Duration, error) {
+	return db.RateLimitHit(key, max, window)
+}
+
+func (postgresRateLimitStore) reset(key string) error {
+	return db.ResetRateLimit(key)
+}
+
+// slidingWindowLimiter rejects a key once it exceeds max events within
+// window; a max of 0 turns it off. Counts live in rateLimits under the
+// limiter's name, so limiters sharing a store don't see each other's keys.
+type slidingWindowLimiter struct {
+	name   string
+	max    int
+	window time.Duration
+}
+
+func newSlidingWindowLimiter(name string, max int, window time.Duration) *slidingWindowLimiter {
+	return &slidingWindowLimiter{name: name, max: max, window: window}
+}
+
+// allow records an attempt for key and reports whether it's still within
+// the limit, and if not, how long the caller should wait. If the store
+// can't be reached the attempt is let through and logged, so a database
+// hiccup doesn't lock everybody out.
+func (l *slidingWindowLimiter) allow(key string) (bool, time.Duration) {
+	if l.max <= 0 {
+		return true, 0
+	}
+	ok, retryAfter, err := rateLimits.allow(l.name+":"+key, l.max, l.window)
+	if err != nil {
+		logger.Errorf("rate limit %s: %v", l.name, err)
+		return true, 0
+	}
+	return ok, retryAfter
 }
 
 // reset clears recorded attempts for key, e.g. after a successful login so a
 // legitimate user isn't penalized by earlier failed attempts.
 func (l *slidingWindowLimiter) reset(key string) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	delete(l.events, key)
+	if err := rateLimits.reset(l.name + ":" + key); err != nil {
+		logger.Errorf("rate limit %s: reset: %v", l.name, err)
+	}
+}
+
+// rateLimitKey picks what a request is counted against. An empty key lets
+// the request through uncounted.
+type rateLimitKey func(c *gin.Context) string
+
+func clientIPKey(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// userKey counts against the signed-in user, so it only works behind
+// sessionCheck.
+func userKey(c *gin.Context) string {
+	userID, err := shared.GetUserIDFromContext(c.Request.Context())
+	if err != nil {
+		return ""
+	}
+	return userID
+}
+
+func shareTokenKey(c *gin.Context) string {
+	return c.Param("token")
+}
+
+// rateLimit is middleware that answers 429 with a Retry-After header once
+// the request's key exceeds l.
+func rateLimit(l *slidingWindowLimiter, key rateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			return
+		}
+		if ok, retryAfter := l.allow(k); !ok {
+			tooManyRequests(c, retryAfter)
+		}
+	}
+}
+
+// tooManyRequests answers 429, telling the client to retry after
+// retryAfter, rounded up to whole seconds.
+func tooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	respond(c, http.StatusTooManyRequests, "too many requests", nil)
 }
 
 // Separate IP and per-account limiters: the IP limiter slows down a single
 // attacker, while the per-account limiter stops credential-stuffing spread
 // across many source IPs from ever locking onto one account.
 var (
-	loginIPLimiter    = newSlidingWindowLimiter(20, time.Minute)
-	loginEmailLimiter = newSlidingWindowLimiter(5, 15*time.Minute)
+	loginIPLimiter    = newSlidingWindowLimiter("login_ip", 20, time.Minute)
+	loginEmailLimiter = newSlidingWindowLimiter("login_email", 5, 15*time.Minute)
+
+	forgotPasswordIPLimiter    = newSlidingWindowLimiter("forgot_password_ip", 5, 15*time.Minute)
+	forgotPasswordEmailLimiter = newSlidingWindowLimiter("forgot_password_email", 3, time.Hour)
+)
+
+// The public share routes are limited per IP, which is what stops share
+// tokens being guessed, and per token, so a leaked link can't be hammered
+// from many addresses. The per-user limit on the signed-in API is off
+// unless configured.
+var (
+	shareIPLimiter    = newSlidingWindowLimiter("share_ip", 120, time.Minute)
+	shareTokenLimiter = newSlidingWindowLimiter("share_token", 600, time.Minute)
 
-	forgotPasswordIPLimiter    = newSlidingWindowLimiter(5, 15*time.Minute)
-	forgotPasswordEmailLimiter = newSlidingWindowLimiter(3, time.Hour)
+	apiUserLimiter = newSlidingWindowLimiter("api_user", 0, time.Minute)
 )
 
 func normalizeEmailKey(email string) string {
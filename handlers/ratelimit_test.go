@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	defer func(store rateLimitStore) { rateLimits = store }(rateLimits)
+	rateLimits = newMemoryRateLimitStore()
+
+	login := newSlidingWindowLimiter("login", 2, time.Minute)
+	forgot := newSlidingWindowLimiter("forgot", 2, time.Minute)
+
+	steps := []struct {
+		name    string
+		limiter *slidingWindowLimiter
+		key     string
+		reset   bool
+		want    bool
+	}{
+		{name: "first attempt", limiter: login, key: "a", want: true},
+		{name: "second attempt", limiter: login, key: "a", want: true},
+		{name: "over the limit", limiter: login, key: "a", want: false},
+		{name: "other key", limiter: login, key: "b", want: true},
+		{name: "other limiter, same key", limiter: forgot, key: "a", want: true},
+		{name: "after reset", limiter: login, key: "a", reset: true, want: true},
+	}
+
+	for _, step := range steps {
+		if step.reset {
+			step.limiter.reset(step.key)
+		}
+		if got, _ := step.limiter.allow(step.key); got != step.want {
+			t.Errorf("%s: allow(%q) = %v, want %v", step.name, step.key, got, step.want)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreWindowExpires(t *testing.T) {
+	store := newMemoryRateLimitStore()
+
+	if ok, _, _ := store.allow("k", 1, 10*time.Millisecond); !ok {
+		t.Fatal("first attempt rejected")
+	}
+	ok, retryAfter, _ := store.allow("k", 1, 10*time.Millisecond)
+	if ok {
+		t.Fatal("second attempt within the window allowed")
+	}
+	if retryAfter <= 0 || retryAfter > 10*time.Millisecond {
+		t.Errorf("retryAfter = %v, want within the 10ms window", retryAfter)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _, _ := store.allow("k", 1, 10*time.Millisecond); !ok {
+		t.Fatal("attempt after the window rejected")
+	}
+}
+
+func TestSlidingWindowLimiterDisabled(t *testing.T) {
+	l := &slidingWindowLimiter{name: "off", max: 0, window: time.Minute}
+	for i := 0; i < 5; i++ {
+		if ok, _ := l.allow("k"); !ok {
+			t.Fatalf("attempt %d rejected by a disabled limiter", i+1)
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	defer func(store rateLimitStore) { rateLimits = store }(rateLimits)
+	rateLimits = newMemoryRateLimitStore()
+	gin.SetMode(gin.TestMode)
+
+	l := &slidingWindowLimiter{name: "test_token", max: 1, window: time.Minute}
+	r := gin.New()
+	r.GET("/share/:token", rateLimit(l, shareTokenKey), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	get := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/share/"+token, nil))
+		return w
+	}
+
+	if w := get("a"); w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	w := get("a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	if w := get("b"); w.Code != http.StatusOK {
+		t.Errorf("other token: status %d", w.Code)
+	}
+}
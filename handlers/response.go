@@ -6,7 +6,6 @@ import (
 	"mime"
 	"net/http"
 
-	"avenue/backend/db"
 	"avenue/backend/logger"
 	"avenue/backend/sdk"
 
@@ -28,6 +27,9 @@ func respond(c *gin.Context, status int, message string, err error) {
 	if err != nil {
 		r.Error = err.Error()
 	}
+	if errors.Is(err, errQuotaExceeded) {
+		r.Code = sdk.ErrorCodeQuotaExceeded
+	}
 
 	if status >= 500 {
 		logger.Errorf("HTTP %d: %s: %s", status, r.Message, r.Error)
@@ -39,8 +41,8 @@ func respond(c *gin.Context, status int, message string, err error) {
 // fetchOwnedFile fetches the file identified by fileID, requiring that
 // userID own it. On failure it writes the 404/500 response and returns
 // ok=false.
-func fetchOwnedFile(c *gin.Context, fileID, userID string) (*sdk.File, bool) {
-	file, err := db.GetFileByID(fileID, userID)
+func (s *Server) fetchOwnedFile(c *gin.Context, fileID, userID string) (*sdk.File, bool) {
+	file, err := s.files.GetFileByID(fileID, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "file not found", nil)
@@ -55,8 +57,8 @@ func fetchOwnedFile(c *gin.Context, fileID, userID string) (*sdk.File, bool) {
 // fetchOwnedFolder fetches the folder identified by folderID, requiring that
 // userID own it. On failure it writes the 404/500 response and returns
 // ok=false.
-func fetchOwnedFolder(c *gin.Context, folderID, userID string) (*sdk.Folder, bool) {
-	folder, err := db.GetFolder(folderID, userID)
+func (s *Server) fetchOwnedFolder(c *gin.Context, folderID, userID string) (*sdk.Folder, bool) {
+	folder, err := s.folders.GetFolder(folderID, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respond(c, http.StatusNotFound, "folder not found", nil)
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/spf13/afero"
+)
+
+// driveRollback remembers the files and folders a multi-step operation
+// (extraction, copy) has created so they can be removed again if it fails
+// part way.
+type driveRollback struct {
+	userID  int64
+	files   []sdk.File
+	folders []int64
+}
+
+func (rb *driveRollback) addFile(f sdk.File) {
+	rb.files = append(rb.files, f)
+}
+
+func (rb *driveRollback) addFolder(id int64) {
+	rb.folders = append(rb.folders, id)
+}
+
+// undo deletes everything recorded from s, along with the blobs and quota
+// usage of the files. Errors are logged under logPrefix and don't stop the
+// rest of the cleanup.
+func (rb *driveRollback) undo(s *Server, logPrefix string) {
+	userID := strconv.FormatInt(rb.userID, 10)
+	for _, f := range rb.files {
+		if err := s.files.DeleteFile(f.UUID, userID); err != nil {
+			logger.Errorf("%s: roll back file %s: %v", logPrefix, f.UUID, err)
+			continue
+		}
+		if err := s.fs.Remove(shared.BlobPath(f.UUID)); err != nil && !errors.Is(err, afero.ErrFileNotFound) {
+			logger.Errorf("%s: remove blob %s: %v", logPrefix, f.UUID, err)
+		}
+		if err := s.users.UpdateUsage(rb.userID, -f.FileSize); err != nil {
+			logger.Errorf("%s: update usage: %v", logPrefix, err)
+		}
+	}
+	if len(rb.folders) > 0 {
+		if err := db.DeleteFoldersByID(rb.folders, rb.userID); err != nil {
+			logger.Errorf("%s: roll back folders: %v", logPrefix, err)
+		}
+	}
+	rb.files, rb.folders = nil, nil
+}
@@ -7,7 +7,6 @@ import (
 	"strconv"
 	"time"
 
-	"avenue/backend/db"
 	"avenue/backend/sdk"
 	"avenue/backend/shared"
 
@@ -33,7 +32,7 @@ func (s *Server) ListSessions(c *gin.Context) {
 
 	currentSessionID, _ := shared.GetSessionIDFromContext(ctx)
 
-	sessions, err := db.ListSessionsForUser(userID)
+	sessions, err := s.sessions.ListSessionsForUser(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("list sessions: %w", err))
 		return
@@ -78,7 +77,7 @@ func (s *Server) RevokeSession(c *gin.Context) {
 	}
 
 	currentSessionID, _ := shared.GetSessionIDFromContext(ctx)
-	sessions, err := db.ListSessionsForUser(userID)
+	sessions, err := s.sessions.ListSessionsForUser(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("list sessions: %w", err))
 		return
@@ -90,7 +89,7 @@ func (s *Server) RevokeSession(c *gin.Context) {
 		}
 	}
 
-	if err := db.DeleteSessionByIDForUser(sessionID, userID); err != nil {
+	if err := s.sessions.DeleteSessionByIDForUser(sessionID, userID); err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("revoke session: %w", err))
 		return
 	}
@@ -120,7 +119,7 @@ func (s *Server) RevokeOtherSessions(c *gin.Context) {
 		return
 	}
 
-	if err := db.DeleteOtherSessions(userID, currentSessionID); err != nil {
+	if err := s.sessions.DeleteOtherSessions(userID, currentSessionID); err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("revoke other sessions: %w", err))
 		return
 	}
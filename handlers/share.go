@@ -6,7 +6,6 @@ import (
 	"io"
 	"net/http"
 
-	"avenue/backend/db"
 	"avenue/backend/sdk"
 	"avenue/backend/shared"
 
@@ -23,7 +22,7 @@ func (s *Server) CreateShareLink(c *gin.Context) {
 
 	fileID := c.Param("fileID")
 
-	if _, ok := fetchOwnedFile(c, fileID, userID); !ok {
+	if _, ok := s.fetchOwnedFile(c, fileID, userID); !ok {
 		return
 	}
 
@@ -33,7 +32,7 @@ func (s *Server) CreateShareLink(c *gin.Context) {
 		return
 	}
 
-	link, err := db.CreateShareLink(fileID, userID, req.ExpiresAt, req.RequireLogin)
+	link, err := s.shares.CreateShareLink(fileID, userID, req.ExpiresAt, req.RequireLogin)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -49,7 +48,7 @@ func (s *Server) CreateShareLink(c *gin.Context) {
 func (s *Server) GetShareLinkMeta(c *gin.Context) {
 	token := c.Param("token")
 
-	link, err := db.GetShareLink(token)
+	link, err := s.shares.GetShareLink(token)
 	if err != nil {
 		respond(c, http.StatusNotFound, "share link not found or expired", nil)
 		return
@@ -60,7 +59,7 @@ func (s *Server) GetShareLinkMeta(c *gin.Context) {
 		return
 	}
 
-	file, err := db.GetFileByIDPublic(link.FileID)
+	file, err := s.files.GetFileByIDPublic(link.FileID)
 	if err != nil {
 		respond(c, http.StatusNotFound, "file not found", nil)
 		return
@@ -83,11 +82,11 @@ func (s *Server) ListFileShares(c *gin.Context) {
 	}
 
 	fileID := c.Param("fileID")
-	if _, ok := fetchOwnedFile(c, fileID, userID); !ok {
+	if _, ok := s.fetchOwnedFile(c, fileID, userID); !ok {
 		return
 	}
 
-	links, err := db.ListSharesByFile(fileID, userID)
+	links, err := s.shares.ListSharesByFile(fileID, userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -105,7 +104,7 @@ func (s *Server) ListUserShares(c *gin.Context) {
 		return
 	}
 
-	links, err := db.ListSharesByUser(userID)
+	links, err := s.shares.ListSharesByUser(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -123,7 +122,7 @@ func (s *Server) ListExpiredUserShares(c *gin.Context) {
 		return
 	}
 
-	links, err := db.ListExpiredSharesByUser(userID)
+	links, err := s.shares.ListExpiredSharesByUser(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -142,7 +141,7 @@ func (s *Server) RevokeShareLink(c *gin.Context) {
 	}
 
 	token := c.Param("token")
-	if err := db.DeleteShareLink(token, userID); err != nil {
+	if err := s.shares.DeleteShareLink(token, userID); err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
 	}
@@ -152,7 +151,7 @@ func (s *Server) RevokeShareLink(c *gin.Context) {
 func (s *Server) DownloadSharedFile(c *gin.Context) {
 	token := c.Param("token")
 
-	link, err := db.GetShareLink(token)
+	link, err := s.shares.GetShareLink(token)
 	if err != nil {
 		respond(c, http.StatusNotFound, "share link not found or expired", nil)
 		return
@@ -163,7 +162,7 @@ func (s *Server) DownloadSharedFile(c *gin.Context) {
 		return
 	}
 
-	file, err := db.GetFileByIDPublic(link.FileID)
+	file, err := s.files.GetFileByIDPublic(link.FileID)
 	if err != nil {
 		respond(c, http.StatusNotFound, "file not found", nil)
 		return
@@ -190,7 +189,7 @@ func (s *Server) DownloadSharedFile(c *gin.Context) {
 
 	c.Writer.Flush()
 
-	if _, err := io.Copy(c.Writer, fileData); err != nil {
+	if _, err := io.Copy(s.throttleDownload(c, c.Writer, "", token), fileData); err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
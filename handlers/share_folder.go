@@ -11,7 +11,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"avenue/backend/db"
 	"avenue/backend/sdk"
 	"avenue/backend/shared"
 
@@ -28,7 +27,7 @@ func (s *Server) CreateFolderShareLink(c *gin.Context) {
 	}
 
 	folderID := c.Param("folderID")
-	if _, ok := fetchOwnedFolder(c, folderID, userID); !ok {
+	if _, ok := s.fetchOwnedFolder(c, folderID, userID); !ok {
 		return
 	}
 
@@ -38,7 +37,7 @@ func (s *Server) CreateFolderShareLink(c *gin.Context) {
 		return
 	}
 
-	link, err := db.CreateShareFolderLink(folderID, userID, req.ExpiresAt, req.RequireLogin, req.AllowUpload, req.MaxFileSize)
+	link, err := s.shares.CreateShareFolderLink(folderID, userID, req.ExpiresAt, req.RequireLogin, req.AllowUpload, req.MaxFileSize)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -60,11 +59,11 @@ func (s *Server) ListFolderShares(c *gin.Context) {
 	}
 
 	folderID := c.Param("folderID")
-	if _, ok := fetchOwnedFolder(c, folderID, userID); !ok {
+	if _, ok := s.fetchOwnedFolder(c, folderID, userID); !ok {
 		return
 	}
 
-	links, err := db.ListShareFoldersByFolder(folderID, userID)
+	links, err := s.shares.ListShareFoldersByFolder(folderID, userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -83,7 +82,7 @@ func (s *Server) ListUserFolderShares(c *gin.Context) {
 		return
 	}
 
-	links, err := db.ListShareFoldersByUser(userID)
+	links, err := s.shares.ListShareFoldersByUser(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -102,7 +101,7 @@ func (s *Server) ListExpiredUserFolderShares(c *gin.Context) {
 		return
 	}
 
-	links, err := db.ListExpiredShareFoldersByUser(userID)
+	links, err := s.shares.ListExpiredShareFoldersByUser(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -122,7 +121,7 @@ func (s *Server) RevokeShareFolderLink(c *gin.Context) {
 	}
 
 	token := c.Param("token")
-	if err := db.DeleteShareFolderLink(token, userID); err != nil {
+	if err := s.shares.DeleteShareFolderLink(token, userID); err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
 	}
@@ -136,13 +135,13 @@ func (s *Server) GetSharedFolderContents(c *gin.Context) {
 		return
 	}
 
-	files, err := db.ListChildFilePublic(link.FolderUUID)
+	files, err := s.files.ListChildFilePublic(link.FolderUUID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
 	}
 	ownerID := fmt.Sprint(link.CreatedBy)
-	folders, err := db.ListChildFolder(link.FolderUUID, ownerID, sdk.SortAsc, shared.MAXPAGELIMIT, 0)
+	folders, err := s.folders.ListChildFolder(link.FolderUUID, ownerID, sdk.SortAsc, shared.MAXPAGELIMIT, 0)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -161,7 +160,7 @@ func (s *Server) GetSharedFolderContents(c *gin.Context) {
 		Files:       files,
 		Folders:     folders,
 		AllowUpload: link.AllowUpload,
-		MaxFileSize: uploadLimitForLink(link),
+		MaxFileSize: s.uploadLimitForLink(link),
 	})
 }
 
@@ -173,25 +172,25 @@ func (s *Server) BrowseSharedSubFolder(c *gin.Context) {
 	}
 
 	subFolderUUID := c.Param("subFolderUUID")
-	inTree, err := db.IsFolderInSubtree(link.FolderIntID, subFolderUUID)
+	inTree, err := s.shares.IsFolderInSubtree(link.FolderIntID, subFolderUUID)
 	if err != nil || !inTree {
 		respond(c, http.StatusNotFound, "folder not found in shared tree", nil)
 		return
 	}
 
 	ownerID := fmt.Sprint(link.CreatedBy)
-	subFolder, err := db.GetFolder(subFolderUUID, ownerID)
+	subFolder, err := s.folders.GetFolder(subFolderUUID, ownerID)
 	if err != nil {
 		respond(c, http.StatusNotFound, "folder not found", nil)
 		return
 	}
 
-	files, err := db.ListChildFilePublic(subFolderUUID)
+	files, err := s.files.ListChildFilePublic(subFolderUUID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
 	}
-	folders, err := db.ListChildFolder(subFolderUUID, ownerID, sdk.SortAsc, shared.MAXPAGELIMIT, 0)
+	folders, err := s.folders.ListChildFolder(subFolderUUID, ownerID, sdk.SortAsc, shared.MAXPAGELIMIT, 0)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
@@ -210,7 +209,7 @@ func (s *Server) BrowseSharedSubFolder(c *gin.Context) {
 		Files:       files,
 		Folders:     folders,
 		AllowUpload: link.AllowUpload,
-		MaxFileSize: uploadLimitForLink(link),
+		MaxFileSize: s.uploadLimitForLink(link),
 	})
 }
 
@@ -233,28 +232,16 @@ func (s *Server) UploadToSharedFolder(c *gin.Context) {
 	}
 	creatorIDStr := fmt.Sprint(creatorID)
 
-	creator, err := db.GetUserByIDStr(creatorIDStr)
+	status, err := s.quotaStatus(creatorID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
 	}
 
-	maxFileSize := effectiveMaxFileSize(link.MaxFileSize)
-
-	if creator.Quota != 0 {
-		totalUsed, err := db.GetUserUsage(creatorID)
-		if err != nil {
-			respond(c, http.StatusInternalServerError, "", err)
-			return
-		}
-		if totalUsed >= creator.Quota {
-			respond(c, http.StatusUnprocessableEntity, "", errors.New("creator quota reached"))
-			return
-		}
-		remaining := creator.Quota - totalUsed
-		if remaining < maxFileSize {
-			maxFileSize = remaining
-		}
+	maxFileSize, overQuota := computeUploadLimit(status.Limit, status.Used, s.effectiveMaxFileSize(link.MaxFileSize))
+	if overQuota {
+		respond(c, http.StatusUnprocessableEntity, "", fmt.Errorf("%w: the folder owner is out of space", errQuotaExceeded))
+		return
 	}
 
 	// Determine target folder (query param ?folder=<uuid>, must be in shared subtree)
@@ -262,14 +249,34 @@ func (s *Server) UploadToSharedFolder(c *gin.Context) {
 	if targetFolderUUID == "" {
 		targetFolderUUID = link.FolderUUID
 	} else {
-		inTree, err := db.IsFolderInSubtree(link.FolderIntID, targetFolderUUID)
+		inTree, err := s.shares.IsFolderInSubtree(link.FolderIntID, targetFolderUUID)
 		if err != nil || !inTree {
 			respond(c, http.StatusNotFound, "target folder not found in shared tree", nil)
 			return
 		}
 	}
 
+	folderLeft, folderQuota, err := s.folderRoom(targetFolderUUID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	if folderLeft == 0 {
+		respond(c, http.StatusUnprocessableEntity, "", fmt.Errorf("%w: folder %q is full", errQuotaExceeded, folderQuota.Name))
+		return
+	}
+	groupLeft, group, err := s.groupRoom(creatorID)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, "", err)
+		return
+	}
+	if groupLeft == 0 {
+		respond(c, http.StatusUnprocessableEntity, "", fmt.Errorf("%w: group %q is full", errQuotaExceeded, group.Name))
+		return
+	}
+
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize)
+	s.throttleUpload(c, "", link.Token)
 
 	mr, err := c.Request.MultipartReader()
 	if err != nil {
@@ -305,7 +312,7 @@ func (s *Server) UploadToSharedFolder(c *gin.Context) {
 			}
 			contentType = http.DetectContentType(buf[:n])
 
-			fileID, err = db.CreateFile(&sdk.File{
+			fileID, err = s.files.CreateFile(&sdk.File{
 				Name:      filename,
 				Extension: extension,
 				MimeType:  contentType,
@@ -318,14 +325,14 @@ func (s *Server) UploadToSharedFolder(c *gin.Context) {
 			}
 
 			if err := shared.EnsureBlobDir(s.fs, fileID); err != nil {
-				_ = db.DeleteFile(fileID, creatorIDStr)
+				_ = s.files.DeleteFile(fileID, creatorIDStr)
 				respond(c, http.StatusInternalServerError, "", err)
 				return
 			}
 
 			dst, err := s.fs.Create(shared.BlobPath(fileID))
 			if err != nil {
-				_ = db.DeleteFile(fileID, creatorIDStr)
+				_ = s.files.DeleteFile(fileID, creatorIDStr)
 				respond(c, http.StatusInternalServerError, "", err)
 				return
 			}
@@ -335,16 +342,20 @@ func (s *Server) UploadToSharedFolder(c *gin.Context) {
 
 			written, err := io.Copy(mw, bytes.NewReader(buf[:n]))
 			if err != nil {
-				_ = db.DeleteFile(fileID, creatorIDStr)
+				_ = s.files.DeleteFile(fileID, creatorIDStr)
 				respond(c, http.StatusInternalServerError, "", err)
 				return
 			}
 			total += written
 
-			written, err = io.Copy(mw, part)
+			written, err = io.Copy(mw, limitToQuotas(part, total, folderLeft, folderQuota, groupLeft, group))
 			_ = dst.Close()
 			if err != nil {
-				_ = db.DeleteFile(fileID, creatorIDStr)
+				_ = s.files.DeleteFile(fileID, creatorIDStr)
+				if errors.Is(err, errQuotaExceeded) {
+					respond(c, http.StatusUnprocessableEntity, "", err)
+					return
+				}
 				var maxErr *http.MaxBytesError
 				if errors.As(err, &maxErr) || errors.Is(err, http.ErrBodyReadAfterClose) {
 					respond(c, http.StatusRequestEntityTooLarge, "", errors.New("file too large"))
@@ -365,7 +376,7 @@ func (s *Server) UploadToSharedFolder(c *gin.Context) {
 		return
 	}
 
-	if err := db.UpdateFile(sdk.File{
+	if err := s.files.UpdateFile(sdk.File{
 		UUID:      fileID,
 		FileSize:  total,
 		Checksum:  checksum,
@@ -378,10 +389,11 @@ func (s *Server) UploadToSharedFolder(c *gin.Context) {
 		return
 	}
 
-	if err := db.UpdateUsage(creatorID, total); err != nil {
+	if err := s.users.UpdateUsage(creatorID, total); err != nil {
 		respond(c, http.StatusInternalServerError, "", err)
 		return
 	}
+	s.checkGroupSoftQuotas(creatorID)
 
 	c.Status(http.StatusCreated)
 }
@@ -394,13 +406,13 @@ func (s *Server) DownloadSharedFolderFile(c *gin.Context) {
 	}
 
 	fileUUID := c.Param("fileUUID")
-	inTree, err := db.IsFileInSubtree(link.FolderIntID, fileUUID)
+	inTree, err := s.shares.IsFileInSubtree(link.FolderIntID, fileUUID)
 	if err != nil || !inTree {
 		respond(c, http.StatusNotFound, "file not found in shared folder", nil)
 		return
 	}
 
-	file, err := db.GetFileByIDPublic(fileUUID)
+	file, err := s.files.GetFileByIDPublic(fileUUID)
 	if err != nil {
 		respond(c, http.StatusNotFound, "file not found", nil)
 		return
@@ -424,42 +436,31 @@ func (s *Server) DownloadSharedFolderFile(c *gin.Context) {
 	c.Header("Content-Length", fmt.Sprintf("%d", file.FileSize))
 	c.Writer.Flush()
 
-	if _, err := io.Copy(c.Writer, fileData); err != nil {
+	if _, err := io.Copy(s.throttleDownload(c, c.Writer, "", link.Token), fileData); err != nil {
 		c.Status(http.StatusInternalServerError)
 	}
 }
 
 // effectiveMaxFileSize returns linkMax if it is non-zero, otherwise the server default.
-func effectiveMaxFileSize(linkMax int64) int64 {
+func (s *Server) effectiveMaxFileSize(linkMax int64) int64 {
 	if linkMax > 0 {
 		return linkMax
 	}
-	return shared.GetEnvInt64("MAX_FILE_BYTE_SIZE", shared.DEFAULTMAXFILESIZE)
+	return s.cfg.Storage.MaxFileSize
 }
 
 // uploadLimitForLink returns the smallest of: the link's configured max (or server
 // default) and the folder owner's remaining quota. Falls back to effectiveMaxFileSize
 // if quota information cannot be retrieved.
-func uploadLimitForLink(link sdk.ShareFolderLink) int64 {
-	limit := effectiveMaxFileSize(link.MaxFileSize)
+func (s *Server) uploadLimitForLink(link sdk.ShareFolderLink) int64 {
+	limit := s.effectiveMaxFileSize(link.MaxFileSize)
 
-	owner, err := db.GetUserByIDStr(fmt.Sprint(link.CreatedBy))
-	if err != nil || owner.Quota == 0 {
-		return limit
-	}
-
-	used, err := db.GetUserUsage(link.CreatedBy)
+	status, err := s.quotaStatus(link.CreatedBy)
 	if err != nil {
 		return limit
 	}
 
-	remaining := owner.Quota - used
-	if remaining <= 0 {
-		return 0
-	}
-	if remaining < limit {
-		return remaining
-	}
+	limit, _ = computeUploadLimit(status.Limit, status.Used, limit)
 	return limit
 }
 
@@ -467,7 +468,7 @@ func uploadLimitForLink(link sdk.ShareFolderLink) int64 {
 // not-found and auth checks, and returns false (having written the response) on failure.
 func (s *Server) resolveShareFolderLink(c *gin.Context) (sdk.ShareFolderLink, bool) {
 	token := c.Param("token")
-	link, err := db.GetShareFolderLink(token)
+	link, err := s.shares.GetShareFolderLink(token)
 	if err != nil {
 		respond(c, http.StatusNotFound, "share link not found or expired", nil)
 		return sdk.ShareFolderLink{}, false
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"avenue/backend/config"
+	"avenue/backend/sdk"
+	"avenue/backend/store"
+
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newMemoryServer starts the API over the in-memory store and filesystem,
+// with one user who's logged in. It returns a client and the headers that
+// authenticate as that user. setup, if given, runs before the routes are
+// registered, e.g. to add middleware.
+func newMemoryServer(t *testing.T, setup ...func(*Server)) (*sdk.Client, http.Header) {
+	t.Helper()
+
+	cfg := config.Defaults()
+	cfg.Server.AllowedOrigins = []string{"http://localhost"}
+	cfg.Server.FileSharing = true
+
+	st := store.NewMemory()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Users.CreateUser("user@example.com", string(hash), "Test", "User", false); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := SetupServer(cfg)
+	srv.SetStore(st)
+	srv.SetFS(afero.NewMemMapFs())
+	for _, f := range setup {
+		f(&srv)
+	}
+	srv.SetupRoutes()
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	client := sdk.NewClient(ts.URL)
+	login, err := client.Login(nil, sdk.LoginRequest{Email: "user@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	h := http.Header{}
+	h.Set(AUTHHEADER, "Token "+login.SessionID)
+	return client, h
+}
+
+// findItem returns the item named name in items, failing if there isn't one.
+func findItem(t *testing.T, items []sdk.FolderItem, name string) sdk.FolderItem {
+	t.Helper()
+	for _, it := range items {
+		if it.Name == name {
+			return it
+		}
+	}
+	t.Fatalf("no item %q in %+v", name, items)
+	return sdk.FolderItem{}
+}
+
+func TestMemoryStoreFileLifecycle(t *testing.T) {
+	client, h := newMemoryServer(t)
+
+	if err := client.CreateFolder(h, "docs", ""); err != nil {
+		t.Fatalf("create folder: %v", err)
+	}
+	root, err := client.ListFolderContents(h, "", sdk.ListOptions{Limit: 50})
+	if err != nil {
+		t.Fatalf("list root: %v", err)
+	}
+	folder := findItem(t, root.Items, "docs")
+
+	if _, err := client.UploadFile(h, "notes.txt", strings.NewReader("hello"), folder.UUID); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	contents, err := client.ListFolderContents(h, folder.UUID, sdk.ListOptions{Limit: 50})
+	if err != nil {
+		t.Fatalf("list folder: %v", err)
+	}
+	if contents.Total != 1 {
+		t.Fatalf("folder has %d items, want 1", contents.Total)
+	}
+	file := findItem(t, contents.Items, "notes.txt")
+
+	resp, err := client.DownloadFile(h, file.UUID)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("downloaded %q, want %q", body, "hello")
+	}
+
+	if err := client.UpdateFileName(h, file.UUID, "renamed.txt"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if _, err := client.DeleteFolder(h, folder.UUID); err != nil {
+		t.Fatalf("trash folder: %v", err)
+	}
+	trash, err := client.ListTrash(h, sdk.ListOptions{Limit: 50})
+	if err != nil {
+		t.Fatalf("list trash: %v", err)
+	}
+	if len(trash.Items) != 1 || trash.Items[0].UUID != folder.UUID {
+		t.Fatalf("trash = %+v, want only the folder", trash.Items)
+	}
+
+	if _, err := client.RestoreFolder(h, folder.UUID); err != nil {
+		t.Fatalf("restore folder: %v", err)
+	}
+	contents, err = client.ListFolderContents(h, folder.UUID, sdk.ListOptions{Limit: 50})
+	if err != nil {
+		t.Fatalf("list restored folder: %v", err)
+	}
+	findItem(t, contents.Items, "renamed.txt")
+}
+
+func TestMemoryStoreShareLink(t *testing.T) {
+	client, h := newMemoryServer(t)
+
+	if _, err := client.UploadFile(h, "photo.png", strings.NewReader("png"), ""); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	root, err := client.ListFolderContents(h, "", sdk.ListOptions{Limit: 50})
+	if err != nil {
+		t.Fatalf("list root: %v", err)
+	}
+	file := findItem(t, root.Items, "photo.png")
+
+	link, err := client.CreateShareLink(h, file.UUID, sdk.CreateShareLinkRequest{})
+	if err != nil {
+		t.Fatalf("share: %v", err)
+	}
+	meta, err := client.GetShareLinkMeta(nil, link.Token)
+	if err != nil {
+		t.Fatalf("share meta: %v", err)
+	}
+	if meta.FileSize != 3 {
+		t.Errorf("shared file size = %d, want 3", meta.FileSize)
+	}
+
+	if err := client.DeleteFile(h, file.UUID); err != nil {
+		t.Fatalf("trash file: %v", err)
+	}
+	if _, err := client.GetShareLinkMeta(nil, link.Token); err == nil {
+		t.Error("share link still works after its file was trashed")
+	}
+}
+
+func TestMemoryStoreSessions(t *testing.T) {
+	client, h := newMemoryServer(t)
+
+	other, err := client.Login(nil, sdk.LoginRequest{Email: "user@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	sessions, err := client.ListSessions(h)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+
+	if err := client.RevokeOtherSessions(h); err != nil {
+		t.Fatalf("revoke others: %v", err)
+	}
+	otherH := http.Header{}
+	otherH.Set(AUTHHEADER, "Token "+other.SessionID)
+	if _, err := client.ListSessions(otherH); err == nil {
+		t.Error("revoked session still works")
+	}
+
+	if _, err := client.Logout(h); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if _, err := client.ListSessions(h); err == nil {
+		t.Error("session still works after logout")
+	}
+}
+
+func TestMemoryStoreQuotaExceeded(t *testing.T) {
+	client, h := newMemoryServer(t)
+
+	if err := client.CreateFolder(h, "small", ""); err != nil {
+		t.Fatalf("create folder: %v", err)
+	}
+	root, err := client.ListFolderContents(h, "", sdk.ListOptions{Limit: 50})
+	if err != nil {
+		t.Fatalf("list root: %v", err)
+	}
+	folder := findItem(t, root.Items, "small")
+	if _, err := client.SetFolderQuota(h, folder.UUID, 4); err != nil {
+		t.Fatalf("set quota: %v", err)
+	}
+
+	_, err = client.UploadFile(h, "big.txt", strings.NewReader("too big"), folder.UUID)
+	if !errors.Is(err, sdk.ErrQuotaExceeded) {
+		t.Fatalf("upload over quota: err = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := client.UploadFile(h, "ok.txt", strings.NewReader("fits"), folder.UUID); err != nil {
+		t.Fatalf("upload under quota: %v", err)
+	}
+}
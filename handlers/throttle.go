@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bandwidthCap is the per-user and per-share-link cap for one direction,
+// in bytes per second. 0 means unlimited.
+type bandwidthCap struct {
+	direction string
+	user      int64
+	share     int64
+}
+
+const (
+	// throttleChunk caps how much a throttled stream moves per wait, so a
+	// low cap sends steadily rather than in long bursts and pauses.
+	throttleChunk = 32 * 1024
+	// bucketIdleTimeout is how long a bucket goes unused before it may be
+	// dropped; by then it has refilled, so dropping it changes nothing.
+	bucketIdleTimeout = time.Minute
+	// bucketPruneThreshold is how many buckets there must be before idle
+	// ones are looked for.
+	bucketPruneThreshold = 1024
+)
+
+// tokenBucket meters bytes at rate per second, allowing a burst of one
+// second's worth.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: float64(rate), tokens: float64(rate), last: now}
+}
+
+// take spends n tokens at now and returns how long the caller must wait
+// before sending them. The bucket goes into debt rather than refusing, so
+// concurrent streams queue up behind each other in order.
+func (b *tokenBucket) take(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+		b.last = now
+	}
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until n bytes may pass or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	d := b.take(n, time.Now())
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (b *tokenBucket) idleSince(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.last)
+}
+
+// bandwidthBuckets holds a bucket per user or share link and direction.
+// Every transfer with the same key shares its bucket, so parallel
+// downloads can't get around a cap. Buckets are per instance.
+type bandwidthBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+var bandwidth = &bandwidthBuckets{buckets: make(map[string]*tokenBucket)}
+
+func (bb *bandwidthBuckets) bucket(key string, rate int64) *tokenBucket {
+	now := time.Now()
+
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+
+	if b, ok := bb.buckets[key]; ok && b.rate == float64(rate) {
+		return b
+	}
+
+	if len(bb.buckets) >= bucketPruneThreshold {
+		for k, b := range bb.buckets {
+			if b.idleSince(now) > bucketIdleTimeout {
+				delete(bb.buckets, k)
+			}
+		}
+	}
+
+	b := newTokenBucket(rate, now)
+	bb.buckets[key] = b
+	return b
+}
+
+// buckets returns the buckets a transfer in cap's direction is metered
+// against: userID's and shareToken's, each if set and capped.
+func (bc bandwidthCap) buckets(userID, shareToken string) []*tokenBucket {
+	var buckets []*tokenBucket
+	if userID != "" && bc.user > 0 {
+		buckets = append(buckets, bandwidth.bucket(bc.direction+":user:"+userID, bc.user))
+	}
+	if shareToken != "" && bc.share > 0 {
+		buckets = append(buckets, bandwidth.bucket(bc.direction+":share:"+shareToken, bc.share))
+	}
+	return buckets
+}
+
+func waitAll(ctx context.Context, buckets []*tokenBucket, n int) error {
+	for _, b := range buckets {
+		if err := b.wait(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type throttledWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	buckets []*tokenBucket
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), throttleChunk)]
+		if err := waitAll(t.ctx, t.buckets, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+type throttledBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	buckets []*tokenBucket
+}
+
+func (t *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := waitAll(t.ctx, t.buckets, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// throttleDownload wraps w, a response body carrying blob data, in the
+// download caps of userID and shareToken. Share links are metered only
+// against their own cap, so pass "" for whichever doesn't apply.
+func (s *Server) throttleDownload(c *gin.Context, w io.Writer, userID, shareToken string) io.Writer {
+	bc := bandwidthCap{direction: "download", user: s.cfg.Bandwidth.UserDownload, share: s.cfg.Bandwidth.ShareDownload}
+	buckets := bc.buckets(userID, shareToken)
+	if len(buckets) == 0 {
+		return w
+	}
+	return &throttledWriter{ctx: c.Request.Context(), w: w, buckets: buckets}
+}
+
+// throttleUpload meters the request body against the upload caps of userID
+// and shareToken.
+func (s *Server) throttleUpload(c *gin.Context, userID, shareToken string) {
+	bc := bandwidthCap{direction: "upload", user: s.cfg.Bandwidth.UserUpload, share: s.cfg.Bandwidth.ShareUpload}
+	buckets := bc.buckets(userID, shareToken)
+	if len(buckets) == 0 {
+		return
+	}
+	c.Request.Body = &throttledBody{ReadCloser: c.Request.Body, ctx: c.Request.Context(), buckets: buckets}
+}
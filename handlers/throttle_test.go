@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newTokenBucket(1000, start)
+
+	steps := []struct {
+		name  string
+		after time.Duration
+		n     int
+		want  time.Duration
+	}{
+		{name: "burst", n: 1000, want: 0},
+		{name: "into debt", n: 500, want: 500 * time.Millisecond},
+		{name: "debt repaid", after: 500 * time.Millisecond, n: 0, want: 0},
+		{name: "refilled", after: 250 * time.Millisecond, n: 250, want: 0},
+		{name: "refill capped at a second", after: time.Hour, n: 2000, want: time.Second},
+	}
+
+	now := start
+	for _, step := range steps {
+		now = now.Add(step.after)
+		if got := b.take(step.n, now); got != step.want {
+			t.Errorf("%s: take(%d) = %v, want %v", step.name, step.n, got, step.want)
+		}
+	}
+}
+
+func TestThrottledWriterStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	w := &throttledWriter{ctx: ctx, w: &buf, buckets: []*tokenBucket{newTokenBucket(1, time.Now())}}
+
+	n, err := w.Write(make([]byte, 10))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if n != 0 || buf.Len() != 0 {
+		t.Errorf("wrote %d bytes past the cap", n)
+	}
+}
@@ -1,16 +1,14 @@
 package handlers
 
 import (
-	"bytes"
 	"crypto/rand"
-	"embed"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"html/template"
 	"net/http"
-	"strings"
+	"strconv"
 
+	"avenue/backend/auth"
 	"avenue/backend/db"
 	"avenue/backend/email"
 	"avenue/backend/logger"
@@ -21,27 +19,21 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-//go:embed templates/user_created.html
-var userCreatedTmplFS embed.FS
-
-var userCreatedTmpl = template.Must(
-	template.New("user_created.html").ParseFS(userCreatedTmplFS, "templates/user_created.html"),
-)
-
-func userCreatedHTML(setPasswordURL string) string {
-	var buf bytes.Buffer
-	if err := userCreatedTmpl.Execute(&buf, setPasswordURL); err != nil {
-		return setPasswordURL
-	}
-	return buf.String()
-}
-
 func (s *Server) LoginMeta(c *gin.Context) {
-	enabled := shared.GetEnv("REGISTRATION_ENABLED", "false")
-	c.JSON(http.StatusOK, sdk.V1LoginMetaResponse{RegistrationEnabled: enabled})
+	c.JSON(http.StatusOK, sdk.V1LoginMetaResponse{
+		RegistrationEnabled:  strconv.FormatBool(s.cfg.Server.RegistrationEnabled),
+		PasswordLoginEnabled: s.loginFormEnabled(),
+		Providers:            s.loginProviders(),
+	})
 }
 
 func (s *Server) Login(c *gin.Context) {
+	authenticator, ok := s.loginAuthenticator()
+	if !ok {
+		respond(c, http.StatusForbidden, "", errors.New("password login is disabled"))
+		return
+	}
+
 	var req sdk.LoginRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -50,29 +42,36 @@ func (s *Server) Login(c *gin.Context) {
 	}
 
 	emailKey := normalizeEmailKey(req.Email)
-	if !loginIPLimiter.allow(c.ClientIP()) || !loginEmailLimiter.allow(emailKey) {
-		c.Status(http.StatusTooManyRequests)
+	if ok, retryAfter := loginIPLimiter.allow(c.ClientIP()); !ok {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+	if ok, retryAfter := loginEmailLimiter.allow(emailKey); !ok {
+		tooManyRequests(c, retryAfter)
 		return
 	}
 
-	u, err := s.authorize(req.Email, req.Password)
+	u, err := authenticator.Authenticate(c.Request.Context(), req.Email, req.Password)
 	if err != nil {
+		if errors.Is(err, auth.ErrLoginDisabled) {
+			respond(c, http.StatusForbidden, "", err)
+			return
+		}
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			logger.Errorf("login: %v", err)
+		}
 		c.Status(http.StatusUnauthorized)
 		return
 	}
 
 	loginEmailLimiter.reset(emailKey)
 
-	session, err := db.CreateSession(u.ID, c.Request.UserAgent(), c.ClientIP())
+	session, err := s.startSession(c, u)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("create session: %w", err))
 		return
 	}
 
-	maxAge := int(SessionRollingWindow.Seconds())
-	c.SetCookie(string(shared.USERCOOKIENAME), fmt.Sprintf("%d", u.ID), maxAge, "/", COOKIEDOMAIN, COOKIESECURE, true)
-	c.SetCookie(string(shared.SESSIONCOOKIENAME), session.SessionID, maxAge, "/", COOKIEDOMAIN, COOKIESECURE, true)
-
 	c.JSON(http.StatusOK, sdk.V1LoginResponse{
 		Message:   "OK",
 		UserID:    u.ID,
@@ -81,25 +80,51 @@ func (s *Server) Login(c *gin.Context) {
 	})
 }
 
-func (s *Server) authorize(email, password string) (sdk.User, error) {
-	user, err := db.GetUserByEmail(email)
+// startSession creates a session for u and sets the session cookies on the
+// response. Shared by password and SSO login.
+func (s *Server) startSession(c *gin.Context, u sdk.User) (db.Session, error) {
+	session, err := s.sessions.CreateSession(u.ID, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
-		return user, err
+		return session, err
 	}
 
-	logger.Debugf("user password hash: %s", user.Password)
+	maxAge := int(SessionRollingWindow.Seconds())
+	c.SetCookie(string(shared.USERCOOKIENAME), fmt.Sprintf("%d", u.ID), maxAge, "/", s.cfg.Server.CookieDomain, s.cfg.Server.Production(), true)
+	c.SetCookie(string(shared.SESSIONCOOKIENAME), session.SessionID, maxAge, "/", s.cfg.Server.CookieDomain, s.cfg.Server.Production(), true)
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
-		return sdk.User{}, err
+	return session, nil
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// loginAuthenticator returns the login backend (local passwords by
+// default, see SetAuthenticator) that checks login/password pairs. With
+// password login turned off, local passwords stop counting but backends
+// such as LDAP still work; ok is false if that leaves none.
+func (s *Server) loginAuthenticator() (auth.Authenticator, bool) {
+	if s.passwordLoginEnabled() {
+		return s.authenticator, s.authenticator != nil
 	}
+	return auth.WithoutPasswords(s.authenticator)
+}
 
-	return user, nil
+// loginFormEnabled reports whether logging in with a login and password
+// works at all, which LoginMeta tells the login page.
+func (s *Server) loginFormEnabled() bool {
+	_, ok := s.loginAuthenticator()
+	return ok
 }
 
 func (s *Server) Logout(c *gin.Context) {
-	c.SetCookie(string(shared.USERCOOKIENAME), "", -1, "/", COOKIEDOMAIN, COOKIESECURE, true)
-	c.SetCookie(string(shared.SESSIONCOOKIENAME), "", -1, "/", COOKIEDOMAIN, COOKIESECURE, true)
+	c.SetCookie(string(shared.USERCOOKIENAME), "", -1, "/", s.cfg.Server.CookieDomain, s.cfg.Server.Production(), true)
+	c.SetCookie(string(shared.SESSIONCOOKIENAME), "", -1, "/", s.cfg.Server.CookieDomain, s.cfg.Server.Production(), true)
 
 	// sessionCheck (required by the secured route this handler is mounted on)
 	// authenticates via the Authorization header, not the ambient cookie, so
@@ -110,7 +135,7 @@ func (s *Server) Logout(c *gin.Context) {
 		return
 	}
 
-	if err = db.DeleteSession(sessID); err != nil {
+	if err = s.sessions.DeleteSession(sessID); err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("delete session: %w", err))
 		return
 	}
@@ -119,9 +144,7 @@ func (s *Server) Logout(c *gin.Context) {
 }
 
 func (s *Server) Register(c *gin.Context) {
-	enabled := strings.ToLower(shared.GetEnv("REGISTRATION_ENABLED", "false"))
-
-	if enabled == "false" {
+	if !s.cfg.Server.RegistrationEnabled || !s.passwordLoginEnabled() {
 		respond(c, http.StatusBadRequest, "", errors.New("registration is not enabled"))
 		return
 	}
@@ -138,7 +161,7 @@ func (s *Server) Register(c *gin.Context) {
 		return
 	}
 
-	if !db.IsUniqueEmail(req.Email) {
+	if !s.users.IsUniqueEmail(req.Email) {
 		respond(c, http.StatusConflict, "", errors.New("email already exists"))
 		return
 	}
@@ -150,35 +173,34 @@ func (s *Server) Register(c *gin.Context) {
 	}
 
 	isAdmin := false
-	u, err := db.CreateUser(req.Email, string(hashedPass), req.FirstName, req.LastName, isAdmin)
+	u, err := s.users.CreateUser(req.Email, string(hashedPass), req.FirstName, req.LastName, isAdmin)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("create user: %w", err))
 		return
 	}
 
-	go func(userEmail string) {
-		if err := email.Send(email.Message{
-			To:      userEmail,
-			Subject: "Welcome to Avenue",
-			Text:    "Your Avenue account has been created. You can now log in at any time.",
-		}); err != nil && !errors.Is(err, email.ErrNotConfigured) {
+	if msg, err := email.NewMessage(u.Email, email.TemplateWelcome, email.TemplateData{}); err != nil {
+		logger.Errorf("email(register): %v", err)
+	} else {
+		msg.DedupKey = fmt.Sprintf("welcome:%d", u.ID)
+		if err := email.Send(msg); err != nil && !errors.Is(err, email.ErrNotConfigured) {
 			logger.Errorf("email(register): %v", err)
 		}
-	}(u.Email)
+	}
 
 	c.JSON(http.StatusCreated, u)
 }
 
 // requireAdmin fetches the authenticated caller and confirms they're an
 // admin. On failure it writes the appropriate response and returns ok=false.
-func requireAdmin(c *gin.Context) (sdk.User, bool) {
+func (s *Server) requireAdmin(c *gin.Context) (sdk.User, bool) {
 	userID, err := shared.GetUserIDFromContext(c.Request.Context())
 	if err != nil {
 		respond(c, http.StatusForbidden, "", fmt.Errorf("user id not found: %w", err))
 		return sdk.User{}, false
 	}
 
-	u, err := db.GetUserByIDStr(userID)
+	u, err := s.users.GetUserByIDStr(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
 		return sdk.User{}, false
@@ -193,7 +215,7 @@ func requireAdmin(c *gin.Context) (sdk.User, bool) {
 }
 
 func (s *Server) CreateUser(c *gin.Context) {
-	_, ok := requireAdmin(c)
+	_, ok := s.requireAdmin(c)
 	if !ok {
 		return
 	}
@@ -210,7 +232,7 @@ func (s *Server) CreateUser(c *gin.Context) {
 		return
 	}
 
-	if !db.IsUniqueEmail(req.Email) {
+	if !s.users.IsUniqueEmail(req.Email) {
 		respond(c, http.StatusConflict, "", errors.New("email already exists"))
 		return
 	}
@@ -221,12 +243,11 @@ func (s *Server) CreateUser(c *gin.Context) {
 	if req.Password != nil {
 		password = *req.Password
 	} else {
-		b := make([]byte, 32)
-		if _, err := rand.Read(b); err != nil {
+		var err error
+		if password, err = randomHex(32); err != nil {
 			respond(c, http.StatusInternalServerError, "", fmt.Errorf("generate password: %w", err))
 			return
 		}
-		password = hex.EncodeToString(b)
 	}
 
 	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -235,7 +256,7 @@ func (s *Server) CreateUser(c *gin.Context) {
 		return
 	}
 
-	nu, err := db.CreateUser(req.Email, string(hashed), req.FirstName, req.LastName, req.IsAdmin)
+	nu, err := s.users.CreateUser(req.Email, string(hashed), req.FirstName, req.LastName, req.IsAdmin)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("create user: %w", err))
 		return
@@ -244,48 +265,48 @@ func (s *Server) CreateUser(c *gin.Context) {
 	logger.Infof("new user created: id=%d email=%s", nu.ID, nu.Email)
 
 	if req.SendEmail {
-		// Capture request fields before entering the goroutine — gin may recycle the context.
-		scheme := "https"
-		if c.Request.TLS == nil {
-			scheme = "http"
-		}
-		host := c.Request.Host
-		go func(userID int64, userEmail, scheme, host string) {
-			token, err := db.CreatePasswordResetToken(userID)
-			if err != nil {
-				logger.Errorf("email(user created): create reset token: %v", err)
-				return
-			}
-			setPasswordURL := scheme + "://" + host + "/reset-password?token=" + token
-			if err := email.Send(email.Message{
-				To:      userEmail,
-				Subject: "Your Avenue account has been created",
-				HTML:    userCreatedHTML(setPasswordURL),
-				Text:    "An administrator has created an Avenue account for you.\n\nClick the link below to set your password:\n\n" + setPasswordURL + "\n\nThis link expires in 1 hour.",
-			}); err != nil && !errors.Is(err, email.ErrNotConfigured) {
-				logger.Errorf("email(user created): %v", err)
-			}
-		}(nu.ID, nu.Email, scheme, host)
+		s.sendUserCreatedEmail(c, nu)
 	}
 
-	// todo allow pagination
-	us, err := db.GetUsers()
+	c.JSON(http.StatusCreated, nu)
+}
+
+// sendUserCreatedEmail queues the invite with a set-password link for a user
+// an admin just created. Failures are logged; the user already exists and
+// the admin can send a reset email later.
+func (s *Server) sendUserCreatedEmail(c *gin.Context, u sdk.User) {
+	token, err := s.users.CreatePasswordResetToken(u.ID)
 	if err != nil {
-		respond(c, http.StatusInternalServerError, "", fmt.Errorf("list users: %w", err))
+		logger.Errorf("email(user created): create reset token: %v", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, us)
+	scheme := "https"
+	if c.Request.TLS == nil {
+		scheme = "http"
+	}
+	setPasswordURL := scheme + "://" + c.Request.Host + "/reset-password?token=" + token
+
+	msg, err := email.NewMessage(u.Email, email.TemplateUserCreated, email.TemplateData{URL: setPasswordURL})
+	if err != nil {
+		logger.Errorf("email(user created): %v", err)
+		return
+	}
+	msg.DedupKey = tokenDedupKey("user_created", token)
+
+	if err := email.Send(msg); err != nil && !errors.Is(err, email.ErrNotConfigured) {
+		logger.Errorf("email(user created): %v", err)
+	}
 }
 
 func (s *Server) GetUsers(c *gin.Context) {
-	_, ok := requireAdmin(c)
+	_, ok := s.requireAdmin(c)
 	if !ok {
 		return
 	}
 
 	// todo allow pagination
-	us, err := db.GetUsers()
+	us, err := s.users.GetUsers()
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("list users: %w", err))
 		return
@@ -302,7 +323,7 @@ func (s *Server) GetProfile(c *gin.Context) {
 		return
 	}
 
-	u, err := db.GetUserByIDStr(userID)
+	u, err := s.users.GetUserByIDStr(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
 		return
@@ -356,24 +377,28 @@ func (s *Server) UpdateProfile(c *gin.Context) {
 		respond(c, http.StatusBadRequest, "", err)
 		return
 	}
+	if id := c.Param("userID"); id != "" && id != strconv.FormatInt(req.ID, 10) {
+		respond(c, http.StatusBadRequest, "", errors.New("user id in the path doesn't match the request body"))
+		return
+	}
 
-	u, err := db.GetUserByIDStr(userID)
+	u, err := s.users.GetUserByIDStr(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
 		return
 	}
 
-	updatingUser, err := db.GetUserByID(req.ID)
+	updatingUser, err := s.users.GetUserByID(req.ID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get target user: %w", err))
 		return
 	}
 
-	emailConflict := req.Email != nil && *req.Email != updatingUser.Email && !db.IsUniqueEmail(*req.Email)
+	emailConflict := req.Email != nil && *req.Email != updatingUser.Email && !s.users.IsUniqueEmail(*req.Email)
 
 	var hasOtherAdmins bool
 	if req.IsAdmin != nil && u.IsAdmin {
-		hasOtherAdmins, _ = db.HasOtherAdmins(updatingUser)
+		hasOtherAdmins, _ = s.users.HasOtherAdmins(updatingUser)
 	}
 
 	if err := validateProfileUpdate(userID, u.IsAdmin, req, emailConflict, hasOtherAdmins); err != nil {
@@ -423,12 +448,19 @@ func (s *Server) UpdateProfile(c *gin.Context) {
 		updatingUser.Quota = *req.Quota
 	}
 
-	updatingUser, err = db.UpdateUser(updatingUser)
+	updatingUser, err = s.users.UpdateUser(updatingUser)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("update user: %w", err))
 		return
 	}
 
+	if req.Quota != nil && u.IsAdmin {
+		// Re-evaluate quota warnings and the grace period against the new quota.
+		if err := s.users.UpdateUsage(updatingUser.ID, 0); err != nil {
+			logger.Errorf("update user %d usage: %v", updatingUser.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, updatingUser)
 }
 
@@ -447,7 +479,7 @@ func (s *Server) UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	u, err := db.GetUserByIDStr(userID)
+	u, err := s.users.GetUserByIDStr(userID)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("get user: %w", err))
 		return
@@ -465,14 +497,14 @@ func (s *Server) UpdatePassword(c *gin.Context) {
 	}
 	u.Password = string(hashed)
 
-	u, err = db.UpdateUser(u)
+	u, err = s.users.UpdateUser(u)
 	if err != nil {
 		respond(c, http.StatusInternalServerError, "", fmt.Errorf("update password: %w", err))
 		return
 	}
 
 	if currentSessionID, err := shared.GetSessionIDFromContext(ctx); err == nil {
-		if err := db.DeleteOtherSessions(u.ID, currentSessionID); err != nil {
+		if err := s.sessions.DeleteOtherSessions(u.ID, currentSessionID); err != nil {
 			logger.Errorf("revoke other sessions after password change: %v", err)
 		}
 	}
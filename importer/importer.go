@@ -0,0 +1,384 @@
+// Package importer copies a directory tree on the server's own disk into a
+// user's drive, for moving a team onto Avenue without pushing everything
+// through the upload API. Directories become folders and regular files
+// become files whose blobs are copied, or hard-linked where the upload
+// directory is on the same filesystem, into their shard (see
+// shared.BlobPath). Each file keeps its modification time as its
+// created_at and is charged to the user's usage.
+//
+// An import is idempotent and can be resumed: the UUID of every folder and
+// file is derived from the user, the target folder and its source path, so
+// running the same import again skips whatever it already created and only
+// picks up the rest. It's used by the admin import API and by
+// cmd/avenue-import.
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/quota"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/google/uuid"
+	"github.com/spf13/afero"
+)
+
+// namespace seeds the UUIDs of imported folders and files.
+var namespace = uuid.MustParse("5b0c1a8e-3f7d-4c2e-9a61-0e4d8f2b7c15")
+
+// ErrQuotaExceeded is returned when an import would take the user past
+// their quota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Progress is told how many files an import will create and then about
+// each one as it goes. *jobs.Run satisfies it.
+type Progress interface {
+	SetTotal(total int64)
+	Add(n int64)
+}
+
+// Options describes an import.
+type Options struct {
+	// Source is the directory whose contents are imported.
+	Source string
+	// UserID owns everything imported.
+	UserID int64
+	// Folder is the UUID of the folder to import into, or "" for the root
+	// of the user's drive.
+	Folder string
+	// Link hard-links blobs to the source files instead of copying them,
+	// falling back to a copy where that fails. The source files must then
+	// not be changed in place, since the blobs would change with them.
+	Link bool
+	// IgnoreQuota imports even if it takes the user past their quota.
+	IgnoreQuota bool
+	// CheckQuota, if set and IgnoreQuota isn't, is called with the bytes
+	// about to be imported after the user's own quota has been checked,
+	// for any other quotas that apply.
+	CheckQuota func(size int64) error
+	// DryRun counts what would be imported without writing anything.
+	DryRun bool
+	// Progress, if set, is kept up to date as files are imported.
+	Progress Progress
+}
+
+// Import imports opts.Source into the user's drive, writing blobs to fsys.
+// Files that can't be read or stored are logged, counted as failed and
+// skipped, so one bad file doesn't stop the rest; symlinks and other
+// special files are skipped too. The import stops early, with what it did
+// so far, if ctx is cancelled.
+func Import(ctx context.Context, fsys afero.Fs, quotas config.Quotas, opts Options) (sdk.ImportResult, error) {
+	var result sdk.ImportResult
+	source, err := filepath.Abs(opts.Source)
+	if err == nil {
+		source, err = filepath.EvalSymlinks(source)
+	}
+	if err != nil {
+		return result, err
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return result, err
+	}
+	if !info.IsDir() {
+		return result, fmt.Errorf("%s is not a directory", source)
+	}
+
+	root := sdk.Folder{OwnerID: opts.UserID}
+	if opts.Folder != "" {
+		f, err := db.GetFolder(opts.Folder, strconv.FormatInt(opts.UserID, 10))
+		if err != nil {
+			return result, fmt.Errorf("get folder %s: %w", opts.Folder, err)
+		}
+		root = *f
+	}
+	imp := &importer{fsys: fsys, opts: opts, source: source, root: root}
+
+	pending, err := imp.scan(ctx)
+	if err != nil {
+		return result, err
+	}
+	if opts.Progress != nil {
+		opts.Progress.SetTotal(int64(pending.Files))
+	}
+	if !opts.IgnoreQuota && pending.Bytes > 0 {
+		if err := checkQuota(quotas, opts, pending.Bytes); err != nil {
+			return result, err
+		}
+	}
+	if opts.DryRun {
+		return pending, nil
+	}
+	return imp.run(ctx)
+}
+
+func checkQuota(quotas config.Quotas, opts Options, size int64) error {
+	status, err := quota.Status(quotas, opts.UserID)
+	if err != nil {
+		return fmt.Errorf("get usage: %w", err)
+	}
+	if status.Limit != 0 && status.Used+size > status.Limit {
+		return fmt.Errorf("%w: import needs %d bytes, more than the %d bytes left in the user's quota", ErrQuotaExceeded, size, max(status.Limit-status.Used, 0))
+	}
+	if opts.CheckQuota != nil {
+		return opts.CheckQuota(size)
+	}
+	return nil
+}
+
+type importer struct {
+	fsys   afero.Fs
+	opts   Options
+	source string
+	root   sdk.Folder
+	// folders maps a slash-separated path relative to source to the folder
+	// made for it; "." is root.
+	folders map[string]sdk.Folder
+}
+
+// ID returns the UUID of whatever is imported from rel, a slash-separated
+// path relative to the source directory.
+func ID(userID int64, folder, source, rel string) string {
+	name := strings.Join([]string{strconv.FormatInt(userID, 10), folder, source, rel}, "\x00")
+	return uuid.NewSHA1(namespace, []byte(name)).String()
+}
+
+func (imp *importer) id(rel string) string {
+	return ID(imp.opts.UserID, imp.opts.Folder, imp.source, rel)
+}
+
+// walk calls fn for every directory and regular file under the source,
+// parents first, with its slash-separated path relative to the source.
+// Entries that can't be read are logged, counted in failed and skipped.
+func (imp *importer) walk(ctx context.Context, failed *int, fn func(rel string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(imp.source, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			logger.Errorf("import %s: %v, skipping", p, err)
+			*failed++
+			if d != nil && d.IsDir() && p != imp.source {
+				return filepath.SkipDir
+			}
+			if p == imp.source {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			logger.Warnf("import %s: not a regular file, skipping", p)
+			return nil
+		}
+		rel, err := filepath.Rel(imp.source, p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), d)
+	})
+}
+
+// scan counts the files and bytes not imported yet.
+func (imp *importer) scan(ctx context.Context) (sdk.ImportResult, error) {
+	var pending sdk.ImportResult
+	err := imp.walk(ctx, &pending.Failed, func(rel string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if rel != "." {
+				pending.Folders++
+			}
+			return nil
+		}
+		exists, err := db.FileExists(imp.id(rel))
+		if err != nil {
+			return fmt.Errorf("check %s: %w", rel, err)
+		}
+		if exists {
+			pending.Skipped++
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			logger.Errorf("import %s: %v, skipping", rel, err)
+			pending.Failed++
+			return nil
+		}
+		pending.Files++
+		pending.Bytes += info.Size()
+		return nil
+	})
+	return pending, err
+}
+
+func (imp *importer) run(ctx context.Context) (sdk.ImportResult, error) {
+	var result sdk.ImportResult
+	imp.folders = map[string]sdk.Folder{".": imp.root}
+	err := imp.walk(ctx, &result.Failed, func(rel string, d fs.DirEntry) error {
+		if rel == "." {
+			return nil
+		}
+		parent := imp.folders[path.Dir(rel)]
+		info, err := d.Info()
+		if err != nil {
+			logger.Errorf("import %s: %v, skipping", rel, err)
+			result.Failed++
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			f := sdk.Folder{UUID: imp.id(rel), Name: d.Name(), ParentID: parent.ID, OwnerID: imp.opts.UserID}
+			created, err := db.ImportFolder(&f, info.ModTime())
+			if err != nil {
+				logger.Errorf("import %s: create folder: %v, skipping", rel, err)
+				result.Failed++
+				return filepath.SkipDir
+			}
+			imp.folders[rel] = f
+			if created {
+				result.Folders++
+			}
+			return nil
+		}
+
+		fileID := imp.id(rel)
+		exists, err := db.FileExists(fileID)
+		if err != nil {
+			return fmt.Errorf("check %s: %w", rel, err)
+		}
+		if exists {
+			result.Skipped++
+			return nil
+		}
+		if err := imp.importFile(rel, fileID, parent, info); err != nil {
+			logger.Errorf("import %s: %v, skipping", rel, err)
+			result.Failed++
+			return nil
+		}
+		result.Files++
+		result.Bytes += info.Size()
+		if imp.opts.Progress != nil {
+			imp.opts.Progress.Add(1)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// importFile stores the blob of the file at rel and then its row, so a
+// file is only ever listed once its blob is in place. A blob left behind
+// by an import that died in between is overwritten on the next run.
+func (imp *importer) importFile(rel, fileID string, parent sdk.Folder, info fs.FileInfo) error {
+	src := filepath.Join(imp.source, filepath.FromSlash(rel))
+	if err := shared.EnsureBlobDir(imp.fsys, fileID); err != nil {
+		return err
+	}
+	blob := shared.BlobPath(fileID)
+	checksum, sniff, err := imp.storeBlob(src, blob)
+	if err != nil {
+		return err
+	}
+
+	file := sdk.File{
+		UUID:      fileID,
+		Name:      info.Name(),
+		Extension: Extension(info.Name()),
+		MimeType:  http.DetectContentType(sniff),
+		FileSize:  info.Size(),
+		Checksum:  checksum,
+		Parent:    parent.UUID,
+		CreatedBy: imp.opts.UserID,
+		CreatedAt: info.ModTime().UTC(),
+	}
+	if err := db.ImportFile(&file); err != nil {
+		_ = imp.fsys.Remove(blob)
+		return fmt.Errorf("create file: %w", err)
+	}
+	return nil
+}
+
+// Extension returns a file name's extension the way uploads record it:
+// lowercased, without the dot.
+func Extension(name string) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+}
+
+// storeBlob puts src's content at blob, via a temporary file so a partial
+// copy never takes a blob's place, and returns its SHA-256 checksum and
+// first 512 bytes.
+func (imp *importer) storeBlob(src, blob string) (checksum string, sniff []byte, err error) {
+	tmp := blob + ".importing"
+	_ = imp.fsys.Remove(tmp)
+	if imp.opts.Link && imp.link(src, tmp) {
+		checksum, sniff, err = copyHashed(src, io.Discard)
+	} else {
+		checksum, sniff, err = imp.copy(src, tmp)
+	}
+	if err == nil {
+		err = imp.fsys.Rename(tmp, blob)
+	}
+	if err != nil {
+		_ = imp.fsys.Remove(tmp)
+		return "", nil, err
+	}
+	return checksum, sniff, nil
+}
+
+// link hard-links src to name in fsys, reporting whether it worked. It
+// can't when fsys isn't backed by the OS filesystem or src is on another
+// device.
+func (imp *importer) link(src, name string) bool {
+	real, ok := imp.fsys.(interface {
+		RealPath(name string) (string, error)
+	})
+	if !ok {
+		return false
+	}
+	dst, err := real.RealPath(name)
+	if err != nil {
+		return false
+	}
+	return os.Link(src, dst) == nil
+}
+
+func (imp *importer) copy(src, dst string) (checksum string, sniff []byte, err error) {
+	out, err := imp.fsys.Create(dst)
+	if err != nil {
+		return "", nil, err
+	}
+	checksum, sniff, err = copyHashed(src, out)
+	return checksum, sniff, errors.Join(err, out.Close())
+}
+
+// copyHashed copies the file at p to w and returns its SHA-256 checksum
+// and first 512 bytes.
+func copyHashed(p string, w io.Writer) (checksum string, sniff []byte, err error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() { _ = f.Close() }()
+	hasher := sha256.New()
+	sw := &shared.SniffWriter{}
+	if _, err := io.Copy(io.MultiWriter(w, hasher, sw), f); err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), sw.Bytes(), nil
+}
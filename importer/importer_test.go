@@ -0,0 +1,64 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"avenue/backend/shared"
+
+	"github.com/spf13/afero"
+)
+
+func TestID(t *testing.T) {
+	id := ID(1, "", "/srv/share", "docs/a.txt")
+	if again := ID(1, "", "/srv/share", "docs/a.txt"); again != id {
+		t.Errorf("ID isn't stable: %s, then %s", id, again)
+	}
+	for _, other := range []string{
+		ID(2, "", "/srv/share", "docs/a.txt"),
+		ID(1, "f", "/srv/share", "docs/a.txt"),
+		ID(1, "", "/srv/other", "docs/a.txt"),
+		ID(1, "", "/srv/share", "docs/b.txt"),
+	} {
+		if other == id {
+			t.Errorf("different imports share ID %s", id)
+		}
+	}
+}
+
+func TestStoreBlob(t *testing.T) {
+	content := []byte("hello, avenue")
+	sum := sha256.Sum256(content)
+	want := hex.EncodeToString(sum[:])
+
+	for _, link := range []bool{false, true} {
+		src := filepath.Join(t.TempDir(), "a.txt")
+		if err := os.WriteFile(src, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		fsys := afero.NewBasePathFs(afero.NewOsFs(), t.TempDir())
+		imp := &importer{fsys: fsys, opts: Options{Link: link}}
+
+		const id = "abcdef12-3456-7890"
+		if err := shared.EnsureBlobDir(fsys, id); err != nil {
+			t.Fatal(err)
+		}
+		checksum, sniff, err := imp.storeBlob(src, shared.BlobPath(id))
+		if err != nil {
+			t.Fatalf("link=%v: %v", link, err)
+		}
+		if checksum != want || string(sniff) != string(content) {
+			t.Errorf("link=%v: got checksum %s and sniff %q, want %s and %q", link, checksum, sniff, want, content)
+		}
+		got, err := afero.ReadFile(fsys, shared.BlobPath(id))
+		if err != nil || string(got) != string(content) {
+			t.Errorf("link=%v: blob = %q, %v, want %q", link, got, err, content)
+		}
+		if ok, _ := afero.Exists(fsys, shared.BlobPath(id)+".importing"); ok {
+			t.Errorf("link=%v: temporary file left behind", link)
+		}
+	}
+}
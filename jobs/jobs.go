@@ -0,0 +1,169 @@
+// Package jobs runs long operations and periodic maintenance off a
+// persistent queue in Postgres. Each kind of job has a typed handler
+// registered with Register; Enqueue adds work for a user and Schedule runs
+// a system job on a fixed interval. Workers on every instance share the
+// queue, so a job runs exactly once however many instances are up, and a
+// single elected instance enqueues the scheduled jobs. StartInProcess runs
+// the same worker off a queue kept in memory, for tests.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+	"avenue/backend/store"
+)
+
+var (
+	// queue is where jobs are kept: Postgres, unless StartInProcess says
+	// otherwise.
+	queue store.Jobs = store.Postgres().Jobs
+	// inProcess is set by StartInProcess, when this is the only instance
+	// and there are no others to notify or to elect a scheduler among.
+	inProcess bool
+)
+
+// Run is handed to a job handler while it runs. Progress reported through it
+// is saved with every heartbeat, so clients polling the job see it within a
+// few seconds.
+type Run struct {
+	job   sdk.Job
+	done  atomic.Int64
+	total atomic.Int64
+}
+
+// Job returns the job being run.
+func (r *Run) Job() sdk.Job {
+	return r.job
+}
+
+// SetTotal sets how much work the job has to do, in the kind's own unit.
+func (r *Run) SetTotal(n int64) {
+	r.total.Store(n)
+}
+
+// Add records n more units of work as done.
+func (r *Run) Add(n int64) {
+	r.done.Add(n)
+}
+
+func (r *Run) progress() (done, total int64) {
+	return r.done.Load(), r.total.Load()
+}
+
+type handlerFunc func(ctx context.Context, run *Run, payload json.RawMessage) (any, error)
+
+type handler struct {
+	run         handlerFunc
+	maxAttempts int
+}
+
+var handlers = map[string]handler{}
+
+// Register installs the handler for jobs of kind. Payloads are decoded into
+// P before fn is called. fn should return promptly with ctx.Err() once ctx
+// is done, which happens when the job is canceled; its result is stored as
+// JSON on the job. A job that fails is retried with backoff until it has
+// been attempted maxAttempts times, unless the error is wrapped with
+// Permanent. Handlers that can't safely run twice should use 1.
+//
+// Register must be called before Start; registering a kind again replaces
+// its handler.
+func Register[P any](kind string, maxAttempts int, fn func(ctx context.Context, run *Run, payload P) (any, error)) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	handlers[kind] = handler{
+		maxAttempts: maxAttempts,
+		run: func(ctx context.Context, run *Run, raw json.RawMessage) (any, error) {
+			var payload P
+			if len(raw) > 0 {
+				if err := json.Unmarshal(raw, &payload); err != nil {
+					return nil, Permanent(fmt.Errorf("decode %s payload: %w", kind, err))
+				}
+			}
+			return fn(ctx, run, payload)
+		},
+	}
+}
+
+// Enqueue adds a job of kind for userID (0 for a system job) and nudges
+// the workers on every instance so it starts straight away wherever
+// there's a free slot.
+func Enqueue(userID int64, kind string, payload any) (sdk.Job, error) {
+	h, ok := handlers[kind]
+	if !ok {
+		return sdk.Job{}, fmt.Errorf("no handler registered for job kind %q", kind)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return sdk.Job{}, err
+	}
+
+	job, err := queue.EnqueueJob(userID, kind, data, h.maxAttempts)
+	if err != nil {
+		return sdk.Job{}, err
+	}
+	wakeWorker()
+	if inProcess {
+		return job, nil
+	}
+	if err := db.Notify(notifyChannel); err != nil {
+		logger.Warnf("jobs: notify other instances of job %d: %v", job.ID, err)
+	}
+	return job, nil
+}
+
+// waitPollInterval is how often Wait checks on a job.
+const waitPollInterval = 100 * time.Millisecond
+
+// Wait polls job id until it finishes, timeout passes or ctx is done, and
+// returns its latest state. Handlers use it to answer quick jobs within the
+// request and hand back a pending job for slow ones.
+func Wait(ctx context.Context, id int64, timeout time.Duration) (sdk.Job, error) {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := queue.GetJobByID(id)
+		if err != nil || job.Finished() || time.Now().After(deadline) {
+			return job, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. because what the job was
+// meant to act on no longer exists.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
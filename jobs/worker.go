@@ -0,0 +1,356 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+	"avenue/backend/store"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	cleanupJobMaxAttempts   = 1
+	scheduledJobMaxAttempts = 1
+
+	// jobLease is how long a claimed job is reserved for its worker. The
+	// worker renews it with every heartbeat, so it only runs out if the
+	// worker dies.
+	jobLease = time.Minute
+	// heartbeatInterval is how often a running job's progress is saved and
+	// its cancel flag checked.
+	heartbeatInterval = 2 * time.Second
+	// maxRetryBackoff caps the exponential backoff between attempts.
+	maxRetryBackoff = time.Hour
+
+	// notifyChannel is the Postgres NOTIFY channel Enqueue uses to wake
+	// the workers on every instance.
+	notifyChannel = "avenue_jobs"
+	// schedulerLockName names the advisory lock held by the instance that
+	// enqueues scheduled jobs.
+	schedulerLockName = "jobs.scheduler"
+)
+
+// schedule is a system job enqueued on a fixed interval.
+type schedule struct {
+	kind     string
+	interval time.Duration
+}
+
+var schedules []schedule
+
+// Schedule runs the system job kind every interval, starting as soon as
+// the worker starts. The schedule is shared through the database, so each
+// interval enqueues one job across all instances, and a run is skipped
+// while the previous one is still going. Call it before Start.
+func Schedule(kind string, interval time.Duration) {
+	schedules = append(schedules, schedule{kind: kind, interval: interval})
+}
+
+// wake lets Enqueue, and NOTIFYs from other instances, nudge the worker so
+// fresh jobs start immediately rather than on the next poll.
+var wake = make(chan struct{}, 1)
+
+func wakeWorker() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the background worker. It runs up to cfg.Workers jobs at
+// once, and polls for due jobs every cfg.PollInterval and whenever one is
+// enqueued on any instance. Whichever instance holds the scheduler lock
+// enqueues scheduled jobs as they fall due. Failed attempts are retried
+// with exponential backoff starting at cfg.RetryBackoff (capped at 1h).
+// Finished jobs, and any archive they built, are deleted after
+// cfg.Retention, checked every cfg.CleanupInterval.
+func Start(fs afero.Fs, cfg config.Jobs) {
+	queue, inProcess = store.Postgres().Jobs, false
+	if err := db.Listen(notifyChannel, wakeWorker); err != nil {
+		logger.Warnf("jobs: listen for new jobs, falling back to polling: %v", err)
+	}
+	start(fs, cfg)
+}
+
+// StartInProcess is Start for a single instance keeping its jobs in q
+// rather than Postgres, e.g. store.NewMemory's in tests. It schedules jobs
+// itself rather than electing an instance to.
+func StartInProcess(fs afero.Fs, cfg config.Jobs, q store.Jobs) {
+	queue, inProcess = q, true
+	start(fs, cfg)
+}
+
+func start(fs afero.Fs, cfg config.Jobs) {
+	workers := max(cfg.Workers, 1)
+	interval := cfg.PollInterval
+	base := cfg.RetryBackoff
+	retention := cfg.Retention
+
+	Register(sdk.JobKindJobCleanup, cleanupJobMaxAttempts, func(ctx context.Context, run *Run, _ struct{}) (any, error) {
+		return cleanupJobs(fs, retention)
+	})
+	Schedule(sdk.JobKindJobCleanup, cfg.CleanupInterval)
+
+	slots := make(chan struct{}, workers)
+	go func() {
+		poll := time.NewTicker(interval)
+		defer poll.Stop()
+
+		var sched scheduler
+		for {
+			if sched.lead() {
+				enqueueScheduled()
+			}
+			dispatch(slots, base)
+
+			select {
+			case <-poll.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// scheduler tracks whether this instance is the one enqueueing scheduled
+// jobs. Leadership is a Postgres advisory lock, so it passes to another
+// instance as soon as the leader exits or loses its database connection.
+// The schedules themselves live in the database, so a new leader carries
+// on where the old one left off.
+type scheduler struct {
+	lock *db.AdvisoryLock
+}
+
+// lead reports whether this instance leads, trying to take over if nobody
+// does.
+func (s *scheduler) lead() bool {
+	if inProcess {
+		return true
+	}
+	ctx := context.Background()
+	if s.lock != nil {
+		if s.lock.Held(ctx) {
+			return true
+		}
+		logger.Warnf("jobs: lost the scheduler lock")
+		_ = s.lock.Release()
+		s.lock = nil
+	}
+
+	lock, ok, err := db.TryAdvisoryLock(ctx, db.LockKey(schedulerLockName))
+	if err != nil {
+		logger.Errorf("jobs: take scheduler lock: %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	logger.Infof("jobs: this instance now runs the scheduler")
+	s.lock = lock
+	return true
+}
+
+// enqueueScheduled enqueues every schedule that has fallen due.
+func enqueueScheduled() {
+	for _, sch := range schedules {
+		if _, ok := handlers[sch.kind]; !ok {
+			continue
+		}
+		id, enqueued, err := queue.EnqueueScheduledJob(sch.kind, sch.interval, scheduledJobMaxAttempts)
+		if err != nil {
+			logger.Errorf("jobs: schedule %s: %v", sch.kind, err)
+			continue
+		}
+		if enqueued {
+			logger.Debugf("jobs: enqueued scheduled %s job %d", sch.kind, id)
+		}
+	}
+}
+
+// dispatch claims as many due jobs as there are free slots and starts each
+// in its own goroutine.
+func dispatch(slots chan struct{}, base time.Duration) {
+	for {
+		free := cap(slots) - len(slots)
+		if free == 0 {
+			return
+		}
+
+		claimed, err := queue.ClaimDueJobs(free, jobLease)
+		if err != nil {
+			logger.Errorf("jobs: claim: %v", err)
+			return
+		}
+
+		for _, job := range claimed {
+			slots <- struct{}{}
+			go func(job sdk.Job) {
+				defer func() {
+					<-slots
+					wakeWorker()
+				}()
+				execute(job, base)
+			}(job)
+		}
+
+		if len(claimed) < free {
+			return
+		}
+	}
+}
+
+// execute runs one claimed job and records how it ended.
+func execute(job sdk.Job, base time.Duration) {
+	run := &Run{job: job}
+
+	h, ok := handlers[job.Kind]
+	if !ok {
+		if err := queue.FailJob(job.ID, 0, 0, fmt.Sprintf("no handler registered for job kind %q", job.Kind), nil); err != nil {
+			logger.Errorf("jobs: mark %d failed: %v", job.ID, err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	canceled := make(chan struct{})
+	stop := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		heartbeat(run, cancel, canceled, stop)
+	}()
+
+	result, err := callHandler(ctx, h, run, job.Payload)
+	close(stop)
+	<-heartbeatDone
+
+	wasCanceled := false
+	select {
+	case <-canceled:
+		wasCanceled = true
+	default:
+	}
+
+	finish(run, result, err, wasCanceled, base)
+}
+
+// callHandler runs h, turning a panic into an error so one bad job can't
+// take the worker down.
+func callHandler(ctx context.Context, h handler, run *Run, payload json.RawMessage) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h.run(ctx, run, payload)
+}
+
+// heartbeat renews run's lease and saves its progress every
+// heartbeatInterval until stop is closed. If the job's owner asks for it to
+// be canceled, it closes canceled and cancels the handler's context.
+func heartbeat(run *Run, cancel context.CancelFunc, canceled, stop chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		done, total := run.progress()
+		cancelRequested, err := queue.HeartbeatJob(run.job.ID, done, total, jobLease)
+		if err != nil {
+			logger.Errorf("jobs: heartbeat %d: %v", run.job.ID, err)
+			continue
+		}
+		if cancelRequested {
+			close(canceled)
+			cancel()
+			return
+		}
+	}
+}
+
+// finish records the outcome of one attempt at run's job.
+func finish(run *Run, result any, err error, canceled bool, base time.Duration) {
+	job := run.job
+	done, total := run.progress()
+
+	state, retryAt := outcome(job, err, canceled, time.Now(), base)
+	switch state {
+	case sdk.JobStatusDone:
+		var data []byte
+		if result != nil {
+			var marshalErr error
+			if data, marshalErr = json.Marshal(result); marshalErr != nil {
+				logger.Errorf("jobs: encode result of %s job %d: %v", job.Kind, job.ID, marshalErr)
+				data = nil
+			}
+		}
+		if err := queue.CompleteJob(job.ID, done, total, data); err != nil {
+			logger.Errorf("jobs: mark %d done: %v", job.ID, err)
+		}
+	case sdk.JobStatusCanceled:
+		logger.Infof("jobs: %s job %d canceled", job.Kind, job.ID)
+		if err := queue.MarkJobCanceled(job.ID, done, total); err != nil {
+			logger.Errorf("jobs: mark %d canceled: %v", job.ID, err)
+		}
+	case sdk.JobStatusPending:
+		logger.Warnf("jobs: attempt %d of %s job %d failed, retrying at %s: %v",
+			job.Attempts, job.Kind, job.ID, retryAt.Format(time.RFC3339), err)
+		if err := queue.FailJob(job.ID, done, total, err.Error(), retryAt); err != nil {
+			logger.Errorf("jobs: mark %d for retry: %v", job.ID, err)
+		}
+	default:
+		logger.Errorf("jobs: %s job %d failed after %d attempt(s): %v", job.Kind, job.ID, job.Attempts, err)
+		if err := queue.FailJob(job.ID, done, total, err.Error(), nil); err != nil {
+			logger.Errorf("jobs: mark %d failed: %v", job.ID, err)
+		}
+	}
+}
+
+// outcome decides what an attempt at job that returned err leaves it as:
+// done, canceled, pending again (with the time to retry at) or failed.
+// Cancellation only counts if the handler didn't finish anyway.
+func outcome(job sdk.Job, err error, canceled bool, now time.Time, base time.Duration) (string, *time.Time) {
+	switch {
+	case err == nil:
+		return sdk.JobStatusDone, nil
+	case canceled || errors.Is(err, context.Canceled):
+		return sdk.JobStatusCanceled, nil
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		return sdk.JobStatusFailed, nil
+	}
+	retryAt := now.Add(shared.RetryBackoff(job.Attempts, base, maxRetryBackoff))
+	return sdk.JobStatusPending, &retryAt
+}
+
+// cleanupJobs deletes jobs that finished more than retention ago, along
+// with any file they left behind.
+func cleanupJobs(fs afero.Fs, retention time.Duration) (any, error) {
+	ids, err := queue.DeleteFinishedJobsBefore(time.Now().Add(-retention))
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := fs.Remove(shared.JobArtifactPath(id)); err != nil && !errors.Is(err, afero.ErrFileNotFound) {
+			logger.Errorf("jobs: remove artifact of job %d: %v", id, err)
+		}
+	}
+	if len(ids) > 0 {
+		logger.Infof("jobs: removed %d finished job(s)", len(ids))
+	}
+	return map[string]int{"removed": len(ids)}, nil
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+func TestOutcome(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	base := 30 * time.Second
+	failure := errors.New("disk on fire")
+
+	tests := []struct {
+		name      string
+		job       sdk.Job
+		err       error
+		canceled  bool
+		wantState string
+		wantRetry time.Duration // 0 for no retry
+	}{
+		{name: "success", job: sdk.Job{Attempts: 1, MaxAttempts: 3}, wantState: sdk.JobStatusDone},
+		{name: "success despite cancel", job: sdk.Job{Attempts: 1, MaxAttempts: 3}, canceled: true, wantState: sdk.JobStatusDone},
+		{name: "canceled", job: sdk.Job{Attempts: 1, MaxAttempts: 3}, err: context.Canceled, canceled: true, wantState: sdk.JobStatusCanceled},
+		{name: "canceled with wrapped error", job: sdk.Job{Attempts: 1, MaxAttempts: 3}, err: fmt.Errorf("copy: %w", context.Canceled), wantState: sdk.JobStatusCanceled},
+		{name: "first failure retries", job: sdk.Job{Attempts: 1, MaxAttempts: 3}, err: failure, wantState: sdk.JobStatusPending, wantRetry: 30 * time.Second},
+		{name: "second failure backs off", job: sdk.Job{Attempts: 2, MaxAttempts: 3}, err: failure, wantState: sdk.JobStatusPending, wantRetry: time.Minute},
+		{name: "out of attempts", job: sdk.Job{Attempts: 3, MaxAttempts: 3}, err: failure, wantState: sdk.JobStatusFailed},
+		{name: "single attempt", job: sdk.Job{Attempts: 1, MaxAttempts: 1}, err: failure, wantState: sdk.JobStatusFailed},
+		{name: "permanent", job: sdk.Job{Attempts: 1, MaxAttempts: 3}, err: Permanent(failure), wantState: sdk.JobStatusFailed},
+		{name: "wrapped permanent", job: sdk.Job{Attempts: 1, MaxAttempts: 3}, err: fmt.Errorf("purge: %w", Permanent(failure)), wantState: sdk.JobStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, retryAt := outcome(tt.job, tt.err, tt.canceled, now, base)
+			if state != tt.wantState {
+				t.Errorf("state = %q, want %q", state, tt.wantState)
+			}
+			switch {
+			case tt.wantRetry == 0 && retryAt != nil:
+				t.Errorf("retryAt = %v, want none", *retryAt)
+			case tt.wantRetry != 0 && retryAt == nil:
+				t.Errorf("retryAt = nil, want %v", now.Add(tt.wantRetry))
+			case tt.wantRetry != 0 && !retryAt.Equal(now.Add(tt.wantRetry)):
+				t.Errorf("retryAt = %v, want %v", *retryAt, now.Add(tt.wantRetry))
+			}
+		})
+	}
+}
+
+func TestRegisterDecodesPayload(t *testing.T) {
+	type payload struct {
+		FolderID string `json:"folderId"`
+	}
+
+	const kind = "test.decode"
+	defer delete(handlers, kind)
+
+	var got payload
+	Register(kind, 0, func(_ context.Context, run *Run, p payload) (any, error) {
+		got = p
+		run.SetTotal(2)
+		run.Add(2)
+		return "ok", nil
+	})
+
+	h := handlers[kind]
+	if h.maxAttempts != 1 {
+		t.Errorf("maxAttempts = %d, want 1", h.maxAttempts)
+	}
+
+	run := &Run{job: sdk.Job{Kind: kind}}
+	result, err := h.run(context.Background(), run, json.RawMessage(`{"folderId":"abc"}`))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got.FolderID != "abc" || result != "ok" {
+		t.Errorf("got payload %+v and result %v", got, result)
+	}
+	if done, total := run.progress(); done != 2 || total != 2 {
+		t.Errorf("progress = %d/%d, want 2/2", done, total)
+	}
+
+	_, err = h.run(context.Background(), run, json.RawMessage(`{"folderId":`))
+	if err == nil || !isPermanent(err) {
+		t.Errorf("bad payload: err = %v, want a permanent error", err)
+	}
+}
+
+func TestCallHandlerRecoversPanic(t *testing.T) {
+	h := handler{run: func(context.Context, *Run, json.RawMessage) (any, error) {
+		panic("boom")
+	}}
+
+	_, err := callHandler(context.Background(), h, &Run{}, nil)
+	if err == nil {
+		t.Fatal("expected an error from a panicking handler")
+	}
+}
@@ -8,22 +8,33 @@ import (
 
 var log *slog.Logger
 
+// level is read on every log call, so SetLevel takes effect immediately.
+var level = new(slog.LevelVar)
+
 func init() {
-	level := slog.LevelDebug
-	switch os.Getenv("LOG_LEVEL") {
-	case "info":
-		level = slog.LevelInfo
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	}
+	SetLevel(os.Getenv("LOG_LEVEL"))
 
 	log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: level,
 	}))
 }
 
+// SetLevel sets the minimum level logged: "info", "warn", "error", or
+// anything else for debug. It starts out as LOG_LEVEL until the config is
+// loaded.
+func SetLevel(name string) {
+	switch name {
+	case "info":
+		level.Set(slog.LevelInfo)
+	case "warn":
+		level.Set(slog.LevelWarn)
+	case "error":
+		level.Set(slog.LevelError)
+	default:
+		level.Set(slog.LevelDebug)
+	}
+}
+
 func Debugf(msg string, args ...any) {
 	log.Debug(fmt.Sprintf(msg, args...))
 }
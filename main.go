@@ -1,20 +1,31 @@
 package main
 
 import (
+	"context"
 	"embed"
 
+	"avenue/backend/auth"
+	"avenue/backend/config"
 	"avenue/backend/db"
 	"avenue/backend/email"
 	"avenue/backend/handlers"
+	"avenue/backend/jobs"
 	"avenue/backend/logger"
-	"avenue/backend/shared"
+	"avenue/backend/quota"
 	"avenue/backend/sweeper"
 )
 
 var frontendFS embed.FS
 
 func main() {
-	if err := db.Connect(); err != nil {
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Errorf("config: %v", err)
+		return
+	}
+	logger.SetLevel(cfg.LogLevel)
+
+	if err := db.Connect(cfg.Database); err != nil {
 		logger.Errorf("db connect: %v", err)
 		return
 	}
@@ -24,28 +35,55 @@ func main() {
 		return
 	}
 
-	if err := db.UpsertRootUser(); err != nil {
+	if err := db.UpsertRootUser(cfg.RootUser); err != nil {
 		logger.Warnf("upsert root user: %v", err)
 	}
 
-	sender, err := email.NewSESSender()
+	email.Configure(cfg.Email)
+	quota.Watch(cfg.Quotas)
+	sender, err := email.NewSender(cfg.Email)
 	if err != nil {
 		logger.Warnf("email sender not configured: %v", err)
 	} else {
 		email.Default = sender
 	}
 
-	server := handlers.SetupServer()
+	server := handlers.SetupServer(cfg)
+
+	var providers []*auth.OIDCProvider
+	for _, oidcCfg := range auth.OIDCConfigs(cfg.Auth) {
+		p, err := auth.NewOIDCProvider(context.Background(), oidcCfg)
+		if err != nil {
+			logger.Warnf("sso provider disabled: %v", err)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	server.SetOIDCProviders(providers)
+
+	authenticator, ldapAuth, err := auth.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		logger.Errorf("auth: %v", err)
+		return
+	}
+	server.SetAuthenticator(authenticator)
 
-	sweeper.Start(server.FS())
-	sweeper.StartSessionSweeper()
+	server.RegisterJobs()
+	sweeper.RegisterTrashSweep(server.FS(), cfg.Sweepers)
+	sweeper.RegisterSessionSweep(cfg.Sweepers)
+	if ldapAuth != nil {
+		sweeper.RegisterLDAPSync(ldapAuth, cfg.Auth.LDAP.SyncInterval)
+	}
+	jobs.Start(server.FS(), cfg.Jobs)
+	email.StartOutboxWorker(cfg.Email)
 
 	server.SetupRoutes()
 
-	if shared.GetEnv("APP_ENV", "production") == "production" {
+	if cfg.Server.Production() {
 		server.ServeUI(frontendFS)
 	}
 
-	// Start the server
-	_ = server.Run(":8080")
+	if err := server.Run(); err != nil {
+		logger.Errorf("server: %v", err)
+	}
 }
@@ -0,0 +1,270 @@
+// Package openapi holds the OpenAPI document describing Avenue's REST API,
+// openapi.yaml, and reads it for the server, which serves it at
+// /openapi.json, for the contract test that checks the handlers'
+// responses against it, and for cmd/openapi-gen, which generates the SDK's
+// types from it.
+//
+// Only the parts of OpenAPI 3.0 the document uses are modelled.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+var specJSON = sync.OnceValues(func() ([]byte, error) {
+	b, err := yaml.YAMLToJSON(specYAML)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: convert to json: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return nil, fmt.Errorf("openapi: convert to json: %w", err)
+	}
+	return buf.Bytes(), nil
+})
+
+// JSON returns the document as JSON.
+func JSON() ([]byte, error) {
+	return specJSON()
+}
+
+// Load parses the document.
+func Load() (*Document, error) {
+	b, err := JSON()
+	if err != nil {
+		return nil, err
+	}
+	var d Document
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("openapi: parse: %w", err)
+	}
+	return &d, nil
+}
+
+// Document is an OpenAPI document. Paths and schemas keep the order
+// they're written in, so what's generated from them does too.
+type Document struct {
+	OpenAPI    string             `json:"openapi"`
+	Info       Info               `json:"info"`
+	Paths      Ordered[PathItem]  `json:"paths"`
+	Components Components         `json:"components"`
+	Security   []map[string][]any `json:"security"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps lowercase HTTP methods to the operation for each.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description"`
+	Tags        []string             `json:"tags"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas    Ordered[*Schema]      `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+	Responses  map[string]*Response  `json:"responses"`
+}
+
+// Schema is a JSON schema, plus the x-go-* extensions that tell the
+// generator what it can't work out for itself.
+type Schema struct {
+	Ref                  string           `json:"$ref"`
+	Type                 string           `json:"type"`
+	Format               string           `json:"format"`
+	Description          string           `json:"description"`
+	Nullable             bool             `json:"nullable"`
+	Enum                 []string         `json:"enum"`
+	Items                *Schema          `json:"items"`
+	Required             []string         `json:"required"`
+	Properties           Ordered[*Schema] `json:"properties"`
+	AdditionalProperties json.RawMessage  `json:"additionalProperties"`
+
+	GoName         string    `json:"x-go-name"`
+	GoType         string    `json:"x-go-type"`
+	GoBinding      string    `json:"x-go-binding"`
+	GoForm         string    `json:"x-go-form"`
+	GoServerFields []GoField `json:"x-go-server-fields"`
+}
+
+// GoField is a field of a generated Go type that isn't part of the wire
+// format.
+type GoField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// IsRequired reports whether the object schema s always has the property
+// name.
+func (s *Schema) IsRequired(name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAdditional reports whether an object matching s may have
+// properties it doesn't list.
+func (s *Schema) AllowsAdditional() bool {
+	return len(s.AdditionalProperties) > 0 && string(s.AdditionalProperties) != "false"
+}
+
+// Named is a key and its value in an Ordered map.
+type Named[T any] struct {
+	Name  string
+	Value T
+}
+
+// Ordered is a JSON object decoded with its keys kept in order.
+type Ordered[T any] []Named[T]
+
+func (o *Ordered[T]) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("expected an object, got %v", tok)
+	}
+	*o = nil
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		*o = append(*o, Named[T]{Name: tok.(string), Value: v})
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// Get returns the value for name, if there is one.
+func (o Ordered[T]) Get(name string) (T, bool) {
+	for _, n := range o {
+		if n.Name == name {
+			return n.Value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// Resolve follows s's $ref, if it has one.
+func (d *Document) Resolve(s *Schema) (*Schema, error) {
+	if s == nil || s.Ref == "" {
+		return s, nil
+	}
+	name, ok := strings.CutPrefix(s.Ref, schemaRefPrefix)
+	if !ok {
+		return nil, fmt.Errorf("openapi: unsupported $ref %q", s.Ref)
+	}
+	target, ok := d.Components.Schemas.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("openapi: no schema %q", name)
+	}
+	return target, nil
+}
+
+// RefName returns the name of the schema s refers to, or "".
+func RefName(s *Schema) string {
+	return strings.TrimPrefix(s.Ref, schemaRefPrefix)
+}
+
+// Operation returns the operation for method on path, which is written
+// the OpenAPI way ("/v1/file/{fileID}"), or nil if there isn't one.
+func (d *Document) Operation(method, path string) *Operation {
+	item, ok := d.Paths.Get(path)
+	if !ok {
+		return nil
+	}
+	return item[strings.ToLower(method)]
+}
+
+// Parameter resolves p's $ref, if it has one.
+func (d *Document) Parameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+	if !ok || d.Components.Parameters[name] == nil {
+		return nil, fmt.Errorf("openapi: no parameter %q", p.Ref)
+	}
+	return d.Components.Parameters[name], nil
+}
+
+// Response returns op's response for status: the one documented for that
+// code, or else its default response, which explicit is false for. It's
+// nil if there's neither.
+func (d *Document) Response(op *Operation, status int) (resp *Response, explicit bool, err error) {
+	resp, explicit = op.Responses[strconv.Itoa(status)], true
+	if resp == nil {
+		resp, explicit = op.Responses["default"], false
+	}
+	if resp == nil || resp.Ref == "" {
+		return resp, explicit, nil
+	}
+	name, ok := strings.CutPrefix(resp.Ref, "#/components/responses/")
+	if !ok || d.Components.Responses[name] == nil {
+		return nil, false, fmt.Errorf("openapi: no response %q", resp.Ref)
+	}
+	return d.Components.Responses[name], explicit, nil
+}
+
+// StatusText names status for error messages.
+func statusText(status int) string {
+	return fmt.Sprintf("%d %s", status, http.StatusText(status))
+}
@@ -0,0 +1,130 @@
+package openapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]string{}
+	for _, p := range doc.Paths {
+		for method, op := range p.Value {
+			where := strings.ToUpper(method) + " " + p.Name
+			if op.OperationID == "" {
+				t.Errorf("%s has no operationId", where)
+			} else if other, ok := ids[op.OperationID]; ok {
+				t.Errorf("%s and %s are both %s", other, where, op.OperationID)
+			}
+			ids[op.OperationID] = where
+
+			for _, param := range op.Parameters {
+				if _, err := doc.Parameter(param); err != nil {
+					t.Errorf("%s: %v", where, err)
+				}
+			}
+			if op.RequestBody != nil {
+				for _, m := range op.RequestBody.Content {
+					checkRefs(t, doc, where, m.Schema)
+				}
+			}
+			for status, resp := range op.Responses {
+				if resp.Ref != "" {
+					resp = doc.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+				}
+				if resp == nil {
+					t.Errorf("%s: response %s doesn't resolve", where, status)
+					continue
+				}
+				for _, m := range resp.Content {
+					checkRefs(t, doc, where, m.Schema)
+				}
+			}
+		}
+	}
+	for _, s := range doc.Components.Schemas {
+		checkRefs(t, doc, s.Name, s.Value)
+	}
+}
+
+// checkRefs fails t for every $ref under s that doesn't resolve.
+func checkRefs(t *testing.T, doc *Document, where string, s *Schema) {
+	t.Helper()
+	if s == nil {
+		return
+	}
+	if _, err := doc.Resolve(s); err != nil {
+		t.Errorf("%s: %v", where, err)
+	}
+	checkRefs(t, doc, where, s.Items)
+	for _, p := range s.Properties {
+		checkRefs(t, doc, where+"."+p.Name, p.Value)
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, _ := doc.Components.Schemas.Get("FolderItem")
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			body: `{"type":"file","id":1,"uuid":"u","name":"a.txt","file_size":3,"created_at":"2026-01-02T03:04:05Z"}`,
+		},
+		{
+			name:    "missing required",
+			body:    `{"type":"file","id":1,"uuid":"u","name":"a.txt","created_at":"2026-01-02T03:04:05Z"}`,
+			wantErr: `missing "file_size"`,
+		},
+		{
+			name:    "unexpected property",
+			body:    `{"type":"file","id":1,"uuid":"u","name":"a.txt","file_size":3,"created_at":"2026-01-02T03:04:05Z","size":3}`,
+			wantErr: `unexpected property "size"`,
+		},
+		{
+			name:    "not in enum",
+			body:    `{"type":"dir","id":1,"uuid":"u","name":"a","file_size":0,"created_at":"2026-01-02T03:04:05Z"}`,
+			wantErr: `"dir" isn't one of folder, file`,
+		},
+		{
+			name:    "not an integer",
+			body:    `{"type":"file","id":1.5,"uuid":"u","name":"a.txt","file_size":3,"created_at":"2026-01-02T03:04:05Z"}`,
+			wantErr: "isn't an integer",
+		},
+		{
+			name:    "bad date-time",
+			body:    `{"type":"file","id":1,"uuid":"u","name":"a.txt","file_size":3,"created_at":"yesterday"}`,
+			wantErr: "isn't a date-time",
+		},
+		{
+			name:    "null",
+			body:    `null`,
+			wantErr: "is null",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := doc.ValidateJSON(item, []byte(tt.body))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
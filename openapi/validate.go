@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ValidateResponse checks a response to method on path (written the
+// OpenAPI way) against the document: its status must be documented, it
+// must have a body exactly when its documented response has content, and
+// a JSON body must match the schema. Error responses that fall through to
+// an operation's default response may have no body.
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	op := d.Operation(method, path)
+	if op == nil {
+		return fmt.Errorf("%s %s isn't documented", method, path)
+	}
+	resp, explicit, err := d.Response(op, status)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("%s %s: %s isn't documented", method, path, statusText(status))
+	}
+
+	if len(body) == 0 {
+		if len(resp.Content) > 0 && explicit {
+			return fmt.Errorf("%s %s: %s has no body, want one", method, path, statusText(status))
+		}
+		return nil
+	}
+	if len(resp.Content) == 0 {
+		return fmt.Errorf("%s %s: %s has a body, want none: %s", method, path, statusText(status), body)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		if _, binary := resp.Content["application/octet-stream"]; binary {
+			// Downloads are sent with the file's own content type.
+			return nil
+		}
+		return fmt.Errorf("%s %s: %s is %q, which isn't documented", method, path, statusText(status), mediaType)
+	}
+	if mediaType != "application/json" || media.Schema == nil {
+		return nil
+	}
+	if err := d.ValidateJSON(media.Schema, body); err != nil {
+		return fmt.Errorf("%s %s: %s: %w", method, path, statusText(status), err)
+	}
+	return nil
+}
+
+// ValidateJSON checks that the JSON document b matches s.
+func (d *Document) ValidateJSON(s *Schema, b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+	return d.Validate(s, v)
+}
+
+// Validate checks that v, decoded from JSON with UseNumber, matches s.
+// Objects may only have the properties their schema lists unless it
+// allows additional ones, so a misspelt or undocumented field is caught.
+func (d *Document) Validate(s *Schema, v any) error {
+	return d.validate(s, v, "$")
+}
+
+func (d *Document) validate(s *Schema, v any, at string) error {
+	s, err := d.Resolve(s)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.Properties) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: is null", at)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch(at, "an object", v)
+		}
+		return d.validateObject(s, obj, at)
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return mismatch(at, "an array", v)
+		}
+		var errs []error
+		for i, item := range arr {
+			errs = append(errs, d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)))
+		}
+		return errors.Join(errs...)
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch(at, "a string", v)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: %q isn't a date-time", at, str)
+			}
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q isn't one of %s", at, str, strings.Join(s.Enum, ", "))
+		}
+		return nil
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return mismatch(at, "an integer", v)
+		}
+		if _, err := n.Int64(); err != nil {
+			return fmt.Errorf("%s: %s isn't an integer", at, n)
+		}
+		return nil
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return mismatch(at, "a number", v)
+		}
+		return nil
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch(at, "a boolean", v)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", at, s.Type)
+	}
+}
+
+func (d *Document) validateObject(s *Schema, obj map[string]any, at string) error {
+	var errs []error
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			errs = append(errs, fmt.Errorf("%s: missing %q", at, name))
+		}
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		prop, ok := s.Properties.Get(k)
+		if !ok {
+			if !s.AllowsAdditional() {
+				errs = append(errs, fmt.Errorf("%s: unexpected property %q", at, k))
+			}
+			continue
+		}
+		errs = append(errs, d.validate(prop, obj[k], at+"."+k))
+	}
+	return errors.Join(errs...)
+}
+
+func mismatch(at, want string, got any) error {
+	return fmt.Errorf("%s: is %T, want %s", at, got, want)
+}
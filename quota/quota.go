@@ -0,0 +1,137 @@
+// Package quota decides how much users may store and warns them as they
+// near their quota. Warnings go out by email and as in-app notifications
+// whenever db.UpdateUsage moves a user past one of the configured
+// thresholds, once per threshold until their usage drops back under it.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"avenue/backend/config"
+	"avenue/backend/db"
+	"avenue/backend/email"
+	"avenue/backend/logger"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+)
+
+// full is the warning level for a user at or past their quota. It's always
+// warned about, whatever thresholds are configured.
+const full = 100
+
+// Watch warns users as db.UpdateUsage moves them past cfg's thresholds.
+func Watch(cfg config.Quotas) {
+	db.OnUsageChange(func(c db.UsageChange) {
+		if err := check(cfg, c); err != nil {
+			logger.Errorf("quota warning for user %d: %v", c.UserID, err)
+		}
+	})
+}
+
+// Limit returns how much a user may store right now: their quota, or
+// while a grace period runs, their quota plus the allowed overage. 0 means
+// unlimited.
+func Limit(cfg config.Quotas, quota int64, overQuotaSince *time.Time, now time.Time) int64 {
+	if quota == 0 || cfg.GracePeriod == 0 {
+		return quota
+	}
+	if overQuotaSince != nil && !now.Before(overQuotaSince.Add(cfg.GracePeriod)) {
+		return quota
+	}
+	return quota + quota*int64(cfg.GraceOverage)/100
+}
+
+// GraceEndsAt returns when a user who went over their quota at
+// overQuotaSince is held to it again, or nil if no grace period applies.
+func GraceEndsAt(cfg config.Quotas, overQuotaSince *time.Time) *time.Time {
+	if overQuotaSince == nil || cfg.GracePeriod == 0 {
+		return nil
+	}
+	end := overQuotaSince.Add(cfg.GracePeriod)
+	return &end
+}
+
+// Status returns userID's quota state with its Limit and GraceEndsAt
+// filled in.
+func Status(cfg config.Quotas, userID int64) (sdk.QuotaStatus, error) {
+	status, err := db.GetQuotaStatus(userID)
+	if err != nil {
+		return sdk.QuotaStatus{}, err
+	}
+	return WithLimit(cfg, status), nil
+}
+
+// WithLimit fills in status's Limit and GraceEndsAt, for a status read
+// some other way than Status.
+func WithLimit(cfg config.Quotas, status sdk.QuotaStatus) sdk.QuotaStatus {
+	status.Limit = Limit(cfg, status.Quota, status.OverQuotaSince, time.Now())
+	status.GraceEndsAt = GraceEndsAt(cfg, status.OverQuotaSince)
+	return status
+}
+
+// level returns the highest of thresholds, or full, that used has reached
+// as a percentage of quota, or 0 if none.
+func level(thresholds []int, used, quota int64) int {
+	if quota <= 0 {
+		return 0
+	}
+	if used >= quota {
+		return full
+	}
+	reached := 0
+	for _, t := range thresholds {
+		if used*100 >= int64(t)*quota {
+			reached = max(reached, t)
+		}
+	}
+	return reached
+}
+
+// check records the level c's user is at and, if it went up, warns them.
+func check(cfg config.Quotas, c db.UsageChange) error {
+	lvl := level(cfg.WarnAt, c.Used, c.Quota)
+	if lvl == c.WarnedPercent {
+		return nil
+	}
+	changed, err := db.SetQuotaWarnedPercent(c.UserID, lvl)
+	if err != nil || !changed || lvl < c.WarnedPercent {
+		return err
+	}
+
+	data := email.TemplateData{
+		Used:    shared.FormatBytes(c.Used),
+		Limit:   shared.FormatBytes(c.Quota),
+		Percent: lvl,
+	}
+	message := fmt.Sprintf("You've used %d%% of your storage (%s of %s).", lvl, data.Used, data.Limit)
+	if lvl == full {
+		message = fmt.Sprintf("You've used all of your storage (%s of %s).", data.Used, data.Limit)
+		if end := GraceEndsAt(cfg, c.OverQuotaSince); end != nil {
+			data.Deadline = end.UTC().Format("2 Jan 2006 15:04 MST")
+			message += " You can keep uploading until " + data.Deadline + ", then uploads stop until you free up space."
+		} else if cfg.GracePeriod > 0 {
+			message += " You can go over it for a while, then uploads stop until you free up space."
+		} else {
+			message += " Delete files to be able to upload again."
+		}
+	}
+
+	if err := db.CreateNotification(c.UserID, sdk.NotificationQuotaWarning, message); err != nil {
+		return err
+	}
+
+	user, err := db.GetUserByID(c.UserID)
+	if err != nil {
+		return err
+	}
+	msg, err := email.NewMessage(user.Email, email.TemplateQuotaWarning, data)
+	if err != nil {
+		return err
+	}
+	if err := email.Send(msg); err != nil && !errors.Is(err, email.ErrNotConfigured) {
+		return err
+	}
+	return nil
+}
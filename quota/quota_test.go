@@ -0,0 +1,65 @@
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"avenue/backend/config"
+)
+
+func TestLevel(t *testing.T) {
+	thresholds := []int{80, 95}
+	tests := []struct {
+		name        string
+		used, quota int64
+		want        int
+	}{
+		{name: "unlimited", used: 1 << 40, quota: 0, want: 0},
+		{name: "under every threshold", used: 79, quota: 100, want: 0},
+		{name: "at a threshold", used: 80, quota: 100, want: 80},
+		{name: "between thresholds", used: 94, quota: 100, want: 80},
+		{name: "past the last threshold", used: 99, quota: 100, want: 95},
+		{name: "full", used: 100, quota: 100, want: full},
+		{name: "over", used: 150, quota: 100, want: full},
+		{name: "large sizes don't overflow", used: 81 << 40, quota: 100 << 40, want: 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := level(thresholds, tt.used, tt.quota); got != tt.want {
+				t.Errorf("level(%d, %d) = %d, want %d", tt.used, tt.quota, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	grace := config.Quotas{GracePeriod: 72 * time.Hour, GraceOverage: 10}
+
+	tests := []struct {
+		name      string
+		cfg       config.Quotas
+		quota     int64
+		overSince *time.Time
+		want      int64
+	}{
+		{name: "no grace period", cfg: config.Quotas{GraceOverage: 10}, quota: 1000, want: 1000},
+		{name: "unlimited", cfg: grace, quota: 0, want: 0},
+		{name: "under quota may use the overage", cfg: grace, quota: 1000, want: 1100},
+		{name: "in the grace period", cfg: grace, quota: 1000, overSince: at(-71 * time.Hour), want: 1100},
+		{name: "grace period over", cfg: grace, quota: 1000, overSince: at(-72 * time.Hour), want: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Limit(tt.cfg, tt.quota, tt.overSince, now); got != tt.want {
+				t.Errorf("Limit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,52 +1,134 @@
 package sdk
 
-import "net/http"
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
 
 // Ping checks that the server is reachable.
 func (c *Client) Ping(h http.Header) (MessageResponse, error) {
+	return c.PingContext(withHeader(context.Background(), h))
+}
+
+// PingContext is Ping with a context.
+func (c *Client) PingContext(ctx context.Context) (MessageResponse, error) {
 	var out MessageResponse
-	err := c.request(h, http.MethodGet, "/ping", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/ping", nil, &out)
 	return out, err
 }
 
-// LoginMeta reports whether self-registration is enabled.
+// PingSession checks that the server is reachable and that the caller's
+// session is still valid.
+func (c *Client) PingSession(h http.Header) (MessageResponse, error) {
+	return c.PingSessionContext(withHeader(context.Background(), h))
+}
+
+// PingSessionContext is PingSession with a context.
+func (c *Client) PingSessionContext(ctx context.Context) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.request(ctx, http.MethodGet, "/v1/ping", nil, &out)
+	return out, err
+}
+
+// OpenAPISpec fetches the OpenAPI document describing the server's API, as
+// JSON.
+func (c *Client) OpenAPISpec(h http.Header) ([]byte, error) {
+	return c.OpenAPISpecContext(withHeader(context.Background(), h))
+}
+
+// OpenAPISpecContext is OpenAPISpec with a context.
+func (c *Client) OpenAPISpecContext(ctx context.Context) ([]byte, error) {
+	resp, err := c.rawRequest(ctx, http.MethodGet, "/openapi.json", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return io.ReadAll(resp.Body)
+}
+
+// OIDCLoginURL returns the URL that starts logging in with the given SSO
+// provider. It has to be opened in a browser: the server sends it on to the
+// provider, whose callback logs the user in and lands them on redirect, a
+// path on the server (the app's home page when empty).
+func (c *Client) OIDCLoginURL(provider, redirect string) string {
+	u := c.BaseURL + "/auth/oidc/" + url.PathEscape(provider) + "/login"
+	if redirect != "" {
+		u += "?redirect=" + url.QueryEscape(redirect)
+	}
+	return u
+}
+
+// LoginMeta reports whether self-registration and password login are
+// enabled, and which SSO providers can be used to log in.
 func (c *Client) LoginMeta(h http.Header) (V1LoginMetaResponse, error) {
+	return c.LoginMetaContext(withHeader(context.Background(), h))
+}
+
+// LoginMetaContext is LoginMeta with a context.
+func (c *Client) LoginMetaContext(ctx context.Context) (V1LoginMetaResponse, error) {
 	var out V1LoginMetaResponse
-	err := c.request(h, http.MethodGet, "/loginMeta", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/loginMeta", nil, &out)
 	return out, err
 }
 
 // Login authenticates with an email/password and returns the new session.
 func (c *Client) Login(h http.Header, req LoginRequest) (V1LoginResponse, error) {
+	return c.LoginContext(withHeader(context.Background(), h), req)
+}
+
+// LoginContext is Login with a context.
+func (c *Client) LoginContext(ctx context.Context, req LoginRequest) (V1LoginResponse, error) {
 	var out V1LoginResponse
-	err := c.request(h, http.MethodPost, "/login", req, &out)
+	err := c.request(ctx, http.MethodPost, "/login", req, &out)
 	return out, err
 }
 
 // Logout ends the caller's current session.
 func (c *Client) Logout(h http.Header) (MessageResponse, error) {
+	return c.LogoutContext(withHeader(context.Background(), h))
+}
+
+// LogoutContext is Logout with a context.
+func (c *Client) LogoutContext(ctx context.Context) (MessageResponse, error) {
 	var out MessageResponse
-	err := c.request(h, http.MethodPost, "/v1/logout", nil, &out)
+	err := c.request(ctx, http.MethodPost, "/v1/logout", nil, &out)
 	return out, err
 }
 
 // Register self-registers a new user account (only when registration is
 // enabled on the server).
 func (c *Client) Register(h http.Header, req RegisterRequest) (User, error) {
+	return c.RegisterContext(withHeader(context.Background(), h), req)
+}
+
+// RegisterContext is Register with a context.
+func (c *Client) RegisterContext(ctx context.Context, req RegisterRequest) (User, error) {
 	var out User
-	err := c.request(h, http.MethodPost, "/register", req, &out)
+	err := c.request(ctx, http.MethodPost, "/register", req, &out)
 	return out, err
 }
 
 // ForgotPassword emails a password-reset link, if the email matches an
 // account. Always succeeds on the wire to avoid leaking account existence.
 func (c *Client) ForgotPassword(h http.Header, email string) error {
+	return c.ForgotPasswordContext(withHeader(context.Background(), h), email)
+}
+
+// ForgotPasswordContext is ForgotPassword with a context.
+func (c *Client) ForgotPasswordContext(ctx context.Context, email string) error {
 	req := ForgotPasswordRequest{Email: email}
-	return c.request(h, http.MethodPost, "/forgot-password", req, nil)
+	return c.request(ctx, http.MethodPost, "/forgot-password", req, nil)
 }
 
 // ResetPassword consumes a password-reset token and sets a new password.
 func (c *Client) ResetPassword(h http.Header, token, newPassword string) error {
+	return c.ResetPasswordContext(withHeader(context.Background(), h), token, newPassword)
+}
+
+// ResetPasswordContext is ResetPassword with a context.
+func (c *Client) ResetPasswordContext(ctx context.Context, token, newPassword string) error {
 	req := ResetPasswordRequest{Token: token, NewPassword: newPassword}
-	return c.request(h, http.MethodPost, "/reset-password", req, nil)
+	return c.request(ctx, http.MethodPost, "/reset-password", req, nil)
 }
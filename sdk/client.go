@@ -1,16 +1,27 @@
-// Package sdk is a lightweight Go client for the Avenue HTTP API. Every call
-// takes the caller-supplied headers (for authentication) and returns the
-// decoded response type alongside an error, mirroring net/http idioms.
+// Package sdk is a lightweight Go client for the Avenue HTTP API. Every
+// call comes in two forms: Foo takes caller-supplied headers (for
+// authentication), and FooContext takes a context.Context and relies on
+// the Client's Auth instead. Both return the decoded response type
+// alongside an error, mirroring net/http idioms.
+//
+// Auth is usually a TokenAuth or a SessionAuth. Idempotent calls are
+// retried under the Client's Retry policy, uploads and downloads report
+// their progress to a function set with WithProgress, and paginated
+// listings can be walked with iterators such as FolderItems.
 package sdk
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 // Client is an Avenue API client. The zero value is not usable; construct
@@ -22,11 +33,18 @@ type Client struct {
 	// HTTPClient is used to perform requests. Defaults to http.DefaultClient
 	// when nil.
 	HTTPClient *http.Client
+	// Auth authenticates every request. Headers passed to the methods that
+	// take them are set after it, so they win. Nil sends no credentials of
+	// its own.
+	Auth AuthProvider
+	// Retry says how idempotent requests are retried. NewClient sets it to
+	// DefaultRetry; the zero value never retries.
+	Retry RetryPolicy
 }
 
 // NewClient returns a Client targeting baseURL.
 func NewClient(baseURL string) *Client {
-	return &Client{BaseURL: baseURL}
+	return &Client{BaseURL: baseURL, Retry: DefaultRetry}
 }
 
 // paginationQuery builds a "page=&limit=" query string, omitting either
@@ -49,15 +67,47 @@ func (c *Client) httpClient() *http.Client {
 	return http.DefaultClient
 }
 
+type headerKey struct{}
+
+// withHeader returns ctx carrying h, the headers a method without a
+// context was called with, for rawRequest to set on the request.
+func withHeader(ctx context.Context, h http.Header) context.Context {
+	if h == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, headerKey{}, h)
+}
+
+type noAuthKey struct{}
+
+// withoutAuth returns ctx marked so that requests made with it skip the
+// Client's Auth, for the login an AuthProvider itself makes.
+func withoutAuth(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noAuthKey{}, true)
+}
+
 // APIError is returned when the server responds with a non-2xx status. It
 // carries the decoded error body (see handlers.Response) plus the status
-// code.
+// code. errors.Is matches it against ErrNotFound, ErrQuotaExceeded and
+// ErrRateLimited.
 type APIError struct {
 	StatusCode int    `json:"-"`
 	Message    string `json:"message"`
 	Error_     string `json:"error"`
+	// Code is the server's MessageResponse.Code, if it sent one.
+	Code string `json:"code"`
+	// RetryAfter is how long the server asked the client to wait before
+	// trying again, from a Retry-After header.
+	RetryAfter time.Duration `json:"-"`
 }
 
+// Errors an *APIError matches with errors.Is.
+var (
+	ErrNotFound      = errors.New("avenue: not found")
+	ErrQuotaExceeded = errors.New("avenue: quota exceeded")
+	ErrRateLimited   = errors.New("avenue: rate limited")
+)
+
 func (e *APIError) Error() string {
 	if e.Error_ != "" {
 		return fmt.Sprintf("avenue: %d: %s", e.StatusCode, e.Error_)
@@ -68,42 +118,189 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("avenue: %d", e.StatusCode)
 }
 
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrQuotaExceeded:
+		return e.Code == ErrorCodeQuotaExceeded
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// responseError reads a non-2xx response into an *APIError and closes its
+// body.
+func responseError(resp *http.Response) *APIError {
+	defer func() { _ = resp.Body.Close() }()
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	_ = json.NewDecoder(resp.Body).Decode(apiErr)
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return apiErr
+}
+
+// RetryPolicy says how a Client retries idempotent requests (GET, HEAD,
+// PUT, DELETE and OPTIONS) that failed to reach the server or were
+// answered with 429, 502, 503 or 504. Others are never retried, and
+// neither are requests whose body can't be replayed, such as uploads.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is tried in all; 0 or 1
+	// disables retries.
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry. It doubles for each
+	// retry after that, up to MaxBackoff, with some jitter.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetry is the RetryPolicy NewClient uses.
+var DefaultRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: 250 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// backoff returns how long to wait before retrying after the given attempt
+// (1 for the first) failed with err, and false if it shouldn't be retried.
+// A Retry-After longer than MaxBackoff isn't waited out.
+func (p RetryPolicy) backoff(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	var retryAfter time.Duration
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return 0, false
+		}
+		retryAfter = apiErr.RetryAfter
+	}
+
+	d := p.MinBackoff << (attempt - 1)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d > 0 {
+		d = d/2 + rand.N(d/2+1)
+	}
+	if retryAfter > p.MaxBackoff {
+		return 0, false
+	}
+	return max(d, retryAfter), true
+}
+
+// idempotent reports whether repeating a request with method has the same
+// effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// rawRequest performs an HTTP request and returns the raw *http.Response,
+// for endpoints that don't return JSON (file downloads) and for request.
+// The caller is responsible for closing the response body. Non-2xx
+// responses are translated into an *APIError and the body is closed for
+// you. It's authenticated with c.Auth, then the headers in ctx.
+// If body can be replayed (it's a *bytes.Reader, *bytes.Buffer or
+// *strings.Reader, as for JSON), a request rejected with 401 is retried
+// once after refreshing a Refresher, and idempotent ones are retried under
+// c.Retry.
+func (c *Client) rawRequest(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	replayable := req.Body == nil || req.GetBody != nil
+	auth := c.Auth
+	if ctx.Value(noAuthKey{}) != nil {
+		auth = nil
+	}
+	h, _ := ctx.Value(headerKey{}).(http.Header)
+
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		r := req.Clone(ctx)
+		if attempt > 1 && req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if auth != nil {
+			if err := auth.Authenticate(ctx, r); err != nil {
+				return nil, err
+			}
+		}
+		for k, v := range h {
+			r.Header[k] = v
+		}
+
+		resp, err := c.httpClient().Do(r)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if err == nil {
+			err = responseError(resp)
+		} else if ctx.Err() != nil {
+			return nil, err
+		}
+		if !replayable {
+			return nil, err
+		}
+
+		var apiErr *APIError
+		if refresher, ok := auth.(Refresher); ok && !refreshed && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			if rerr := refresher.Refresh(ctx); rerr != nil {
+				return nil, errors.Join(err, rerr)
+			}
+			refreshed = true
+			continue
+		}
+
+		if !idempotent(method) {
+			return nil, err
+		}
+		wait, ok := c.Retry.backoff(attempt, err)
+		if !ok {
+			return nil, err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
 // request performs an HTTP request against the Avenue API. body is JSON
 // marshaled when non-nil; out is JSON unmarshaled from the response body
 // when non-nil. Callers get back an *APIError for non-2xx responses.
-func (c *Client) request(h http.Header, method, path string, body any, out any) error {
+func (c *Client) request(ctx context.Context, method, path string, body any, out any) error {
 	var reqBody io.Reader
+	contentType := ""
 	if body != nil {
 		b, err := json.Marshal(body)
 		if err != nil {
 			return err
 		}
 		reqBody = bytes.NewReader(b)
+		contentType = "application/json"
 	}
 
-	req, err := http.NewRequest(method, c.BaseURL+path, reqBody)
-	if err != nil {
-		return err
-	}
-	for k, v := range h {
-		req.Header[k] = v
-	}
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
-
-	resp, err := c.httpClient().Do(req)
+	resp, err := c.rawRequest(ctx, method, path, reqBody, contentType)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode >= 300 {
-		apiErr := &APIError{StatusCode: resp.StatusCode}
-		_ = json.NewDecoder(resp.Body).Decode(apiErr)
-		return apiErr
-	}
-
 	if out == nil {
 		return nil
 	}
@@ -112,34 +309,3 @@ func (c *Client) request(h http.Header, method, path string, body any, out any)
 	}
 	return json.NewDecoder(resp.Body).Decode(out)
 }
-
-// rawRequest performs an HTTP request and returns the raw *http.Response for
-// endpoints that don't return JSON (file downloads). The caller is
-// responsible for closing the response body. Non-2xx responses are
-// translated into an *APIError and the body is closed for you.
-func (c *Client) rawRequest(h http.Header, method, path string, body io.Reader, contentType string) (*http.Response, error) {
-	req, err := http.NewRequest(method, c.BaseURL+path, body)
-	if err != nil {
-		return nil, err
-	}
-	for k, v := range h {
-		req.Header[k] = v
-	}
-	if contentType != "" {
-		req.Header.Set("Content-Type", contentType)
-	}
-
-	resp, err := c.httpClient().Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode >= 300 {
-		defer func() { _ = resp.Body.Close() }()
-		apiErr := &APIError{StatusCode: resp.StatusCode}
-		_ = json.NewDecoder(resp.Body).Decode(apiErr)
-		return nil, apiErr
-	}
-
-	return resp, nil
-}
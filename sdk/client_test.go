@@ -0,0 +1,312 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a Client for a server running handler, retrying
+// without waiting long.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	c := NewClient(ts.URL)
+	c.Retry = RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		failures  int
+		status    int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "get recovers", method: http.MethodGet, failures: 2, status: http.StatusServiceUnavailable, wantCalls: 3},
+		{name: "get gives up", method: http.MethodGet, failures: 5, status: http.StatusBadGateway, wantCalls: 3, wantErr: true},
+		{name: "delete recovers", method: http.MethodDelete, failures: 1, status: http.StatusGatewayTimeout, wantCalls: 2},
+		{name: "post isn't retried", method: http.MethodPost, failures: 1, status: http.StatusServiceUnavailable, wantCalls: 1, wantErr: true},
+		{name: "patch isn't retried", method: http.MethodPatch, failures: 1, status: http.StatusServiceUnavailable, wantCalls: 1, wantErr: true},
+		{name: "client errors aren't retried", method: http.MethodGet, failures: 1, status: http.StatusBadRequest, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if body, _ := io.ReadAll(r.Body); r.Method != http.MethodGet && string(body) != `{"parent":"p"}` {
+					t.Errorf("attempt %d sent body %q", calls.Load()+1, body)
+				}
+				if int(calls.Add(1)) <= tt.failures {
+					writeJSON(w, tt.status, MessageResponse{Error: "try again"})
+					return
+				}
+				writeJSON(w, http.StatusOK, MessageResponse{Message: "ok"})
+			})
+
+			var body any
+			if tt.method != http.MethodGet {
+				body = MoveFileRequest{Parent: "p"}
+			}
+			err := c.request(context.Background(), tt.method, "/x", body, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("server saw %d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "60")
+		writeJSON(w, http.StatusTooManyRequests, MessageResponse{Message: "too many requests"})
+	})
+
+	_, err := c.ListFilesContext(context.Background())
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Minute {
+		t.Errorf("err = %#v, want RetryAfter of a minute", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("server saw %d calls, want 1", calls.Load())
+	}
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		err    *APIError
+		target error
+		want   bool
+	}{
+		{&APIError{StatusCode: http.StatusNotFound}, ErrNotFound, true},
+		{&APIError{StatusCode: http.StatusNotFound}, ErrRateLimited, false},
+		{&APIError{StatusCode: http.StatusUnprocessableEntity, Code: ErrorCodeQuotaExceeded}, ErrQuotaExceeded, true},
+		{&APIError{StatusCode: http.StatusUnprocessableEntity}, ErrQuotaExceeded, false},
+		{&APIError{StatusCode: http.StatusTooManyRequests}, ErrRateLimited, true},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("wrapped: %w", tt.err)
+		if got := errors.Is(err, tt.target); got != tt.want {
+			t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestSessionAuth(t *testing.T) {
+	var logins atomic.Int32
+	var valid atomic.Value
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			if got := r.Header.Get("Authorization"); got != "" {
+				t.Errorf("login sent Authorization %q", got)
+			}
+			session := "s" + strconv.Itoa(int(logins.Add(1)))
+			valid.Store(session)
+			writeJSON(w, http.StatusOK, V1LoginResponse{SessionID: session})
+		default:
+			if r.Header.Get("Authorization") != "Token "+valid.Load().(string) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			writeJSON(w, http.StatusOK, []File{})
+		}
+	})
+	auth := NewSessionAuth(c, "user@example.com", "password123")
+	c.Auth = auth
+	ctx := context.Background()
+
+	if _, err := c.ListFilesContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListFilesContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if logins.Load() != 1 {
+		t.Fatalf("logged in %d times, want once", logins.Load())
+	}
+
+	valid.Store("forgotten") // as if s1 had expired
+	if _, err := c.ListFilesContext(ctx); err != nil {
+		t.Fatalf("after the session expired: %v", err)
+	}
+	if auth.SessionID() != "s2" {
+		t.Errorf("session = %q, want s2", auth.SessionID())
+	}
+}
+
+func TestHeadersOverrideAuth(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, MessageResponse{Message: r.Header.Get("Authorization")})
+	})
+	c.Auth = TokenAuth("from-auth")
+
+	got, err := c.PingContext(context.Background())
+	if err != nil || got.Message != "Token from-auth" {
+		t.Errorf("PingContext sent %q (%v), want the token from Auth", got.Message, err)
+	}
+	h := http.Header{}
+	h.Set("Authorization", "Token from-header")
+	got, err = c.Ping(h)
+	if err != nil || got.Message != "Token from-header" {
+		t.Errorf("Ping sent %q (%v), want the token from its headers", got.Message, err)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	const content = "hello, world"
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			f, _, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("upload: %v", err)
+			} else if b, _ := io.ReadAll(f); string(b) != content {
+				t.Errorf("uploaded %q, want %q", b, content)
+			}
+			writeJSON(w, http.StatusOK, MessageResponse{})
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		_, _ = io.WriteString(w, content)
+	})
+
+	var done, total int64
+	ctx := WithProgress(context.Background(), func(d, t int64) { done, total = d, t })
+
+	if _, err := c.UploadFileContext(ctx, "a.txt", strings.NewReader(content), ""); err != nil {
+		t.Fatal(err)
+	}
+	if done != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("upload progress = %d of %d, want %d of %d", done, total, len(content), len(content))
+	}
+
+	done, total = 0, 0
+	resp, err := c.DownloadFileContext(ctx, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if done != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("download progress = %d of %d, want %d of %d", done, total, len(content), len(content))
+	}
+}
+
+func TestFolderItems(t *testing.T) {
+	const total = 5
+	var requests atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var items []FolderItem
+		for i := (page - 1) * limit; i < min(page*limit, total); i++ {
+			items = append(items, FolderItem{Name: strconv.Itoa(i)})
+		}
+		writeJSON(w, http.StatusOK, V1FolderContentsResponse{Items: items, Page: page, Limit: limit, Total: total})
+	})
+
+	var names []string
+	for item, err := range c.FolderItems(context.Background(), "", ListOptions{Limit: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, item.Name)
+	}
+	if strings.Join(names, ",") != "0,1,2,3,4" {
+		t.Errorf("items = %v, want 0 to 4", names)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("fetched %d pages, want 3", requests.Load())
+	}
+
+	requests.Store(0)
+	for range c.FolderItems(context.Background(), "", ListOptions{Limit: 2}) {
+		break
+	}
+	if requests.Load() != 1 {
+		t.Errorf("fetched %d pages after stopping at the first item, want 1", requests.Load())
+	}
+}
+
+func TestListOptionsQuery(t *testing.T) {
+	tests := []struct {
+		opts ListOptions
+		want string
+	}{
+		{ListOptions{}, ""},
+		{ListOptions{Page: 2, Limit: 10}, "limit=10&page=2"},
+		{ListOptions{Sort: SortDesc, SortBy: FolderSortBySize, Type: FolderItemTypeFile}, "sort=desc&sortBy=size&type=file"},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.query(); got != tt.want {
+			t.Errorf("%+v.query() = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestFilterUserShares(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		name := "active"
+		if r.URL.Path == "/v1/shares/expired" {
+			name = "expired"
+		}
+		writeJSON(w, http.StatusOK, []ShareLinkWithFileName{
+			{Token: "b", FileName: "Beta " + name, CreatedAt: day},
+			{Token: "a", FileName: "alpha " + name, CreatedAt: day.Add(time.Hour)},
+			{Token: "c", FileName: "gamma", CreatedAt: day.Add(-time.Hour)},
+		})
+	})
+
+	tests := []struct {
+		filter ShareFilter
+		want   string
+	}{
+		{ShareFilter{}, "c,b,a"},
+		{ShareFilter{Sort: SortDesc}, "a,b,c"},
+		{ShareFilter{SortBy: ShareSortByName}, "a,b,c"},
+		{ShareFilter{Name: "ACTIVE", SortBy: ShareSortByName, Sort: SortDesc}, "b,a"},
+		{ShareFilter{Expired: true, Name: "expired"}, "b,a"},
+		{ShareFilter{Name: "active", Expired: true}, ""},
+	}
+	for _, tt := range tests {
+		links, err := c.FilterUserShares(nil, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tokens []string
+		for _, l := range links {
+			tokens = append(tokens, l.Token)
+		}
+		if got := strings.Join(tokens, ","); got != tt.want {
+			t.Errorf("FilterUserShares(%+v) = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+)
+
+// AdminGetConfig returns the server's effective configuration, after its
+// config file and env overrides, with secrets redacted. Requires an admin
+// caller.
+func (c *Client) AdminGetConfig(h http.Header) (V1AdminConfigResponse, error) {
+	return c.AdminGetConfigContext(withHeader(context.Background(), h))
+}
+
+// AdminGetConfigContext is AdminGetConfig with a context.
+func (c *Client) AdminGetConfigContext(ctx context.Context) (V1AdminConfigResponse, error) {
+	var out V1AdminConfigResponse
+	err := c.request(ctx, http.MethodGet, "/v1/admin/config", nil, &out)
+	return out, err
+}
@@ -0,0 +1,91 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// An AuthProvider adds credentials to the requests a Client sends.
+type AuthProvider interface {
+	Authenticate(ctx context.Context, req *http.Request) error
+}
+
+// A Refresher is an AuthProvider whose credentials can be renewed. When a
+// request is rejected with 401, the Client calls Refresh once and sends it
+// again.
+type Refresher interface {
+	AuthProvider
+	Refresh(ctx context.Context) error
+}
+
+// setToken sets req's Authorization header to send token.
+func setToken(req *http.Request, token string) {
+	req.Header.Set("Authorization", "Token "+token)
+}
+
+// TokenAuth authenticates with a token the caller already holds, such as
+// a long-lived session token saved from an earlier login (as avenuectl
+// keeps in its config) or handed out for a script to use. It's never
+// renewed: once the server forgets it, calls fail with a 401.
+type TokenAuth string
+
+func (t TokenAuth) Authenticate(_ context.Context, req *http.Request) error {
+	setToken(req, string(t))
+	return nil
+}
+
+// SessionAuth logs in with an email and password the first time a request
+// needs it, authenticates with the session it gets back, and logs in again
+// when the server rejects the session, e.g. once it's expired. Construct
+// one with NewSessionAuth.
+type SessionAuth struct {
+	client *Client
+	login  LoginRequest
+
+	mu      sync.Mutex
+	session string
+}
+
+// NewSessionAuth returns a SessionAuth that logs in through c. It's
+// usually set as c.Auth too.
+func NewSessionAuth(c *Client, email, password string) *SessionAuth {
+	return &SessionAuth{client: c, login: LoginRequest{Email: email, Password: password}}
+}
+
+func (a *SessionAuth) Authenticate(ctx context.Context, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.session == "" {
+		if err := a.logIn(ctx); err != nil {
+			return err
+		}
+	}
+	setToken(req, a.session)
+	return nil
+}
+
+// Refresh logs in again.
+func (a *SessionAuth) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.session = ""
+	return a.logIn(ctx)
+}
+
+// SessionID returns the current session's ID, or "" before the first
+// login.
+func (a *SessionAuth) SessionID() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.session
+}
+
+func (a *SessionAuth) logIn(ctx context.Context) error {
+	resp, err := a.client.LoginContext(withoutAuth(ctx), a.login)
+	if err != nil {
+		return err
+	}
+	a.session = resp.SessionID
+	return nil
+}
@@ -1,11 +1,19 @@
 package sdk
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // DashboardInfo returns server-wide/caller-specific dashboard metadata
 // (upload limits, which sharing features are enabled).
 func (c *Client) DashboardInfo(h http.Header) (V1DashboardResponse, error) {
+	return c.DashboardInfoContext(withHeader(context.Background(), h))
+}
+
+// DashboardInfoContext is DashboardInfo with a context.
+func (c *Client) DashboardInfoContext(ctx context.Context) (V1DashboardResponse, error) {
 	var out V1DashboardResponse
-	err := c.request(h, http.MethodGet, "/v1/dashboard", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/dashboard", nil, &out)
 	return out, err
 }
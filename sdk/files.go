@@ -1,142 +1,248 @@
 package sdk
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
-// UploadFile uploads a file's contents. parent is the destination folder's
-// UUID, or "" for the root folder.
-func (c *Client) UploadFile(h http.Header, filename string, data io.Reader, parent string) error {
-	var buf bytes.Buffer
-	mw := multipart.NewWriter(&buf)
+// UploadFile uploads a file's contents and returns the stored file. parent
+// is the destination folder's UUID, or "" for the root folder.
+func (c *Client) UploadFile(h http.Header, filename string, data io.Reader, parent string) (File, error) {
+	return c.UploadFileContext(withHeader(context.Background(), h), filename, data, parent)
+}
 
+// UploadFileContext is UploadFile with a context.
+func (c *Client) UploadFileContext(ctx context.Context, filename string, data io.Reader, parent string) (File, error) {
+	fields := url.Values{}
 	if parent != "" {
-		if err := mw.WriteField("parent", parent); err != nil {
-			return err
-		}
-	}
-
-	fw, err := mw.CreateFormFile("file", filename)
-	if err != nil {
-		return err
+		fields.Set("parent", parent)
 	}
-	if _, err := io.Copy(fw, data); err != nil {
-		return err
-	}
-	if err := mw.Close(); err != nil {
-		return err
-	}
-
-	resp, err := c.rawRequest(h, http.MethodPost, "/v1/file", &buf, mw.FormDataContentType())
-	if err != nil {
-		return err
-	}
-	defer func() { _ = resp.Body.Close() }()
-	return nil
+	var out File
+	err := c.upload(ctx, "/v1/file", fields, filename, data, &out)
+	return out, err
 }
 
 // ListFiles lists every file owned by the authenticated user.
 func (c *Client) ListFiles(h http.Header) ([]File, error) {
+	return c.ListFilesContext(withHeader(context.Background(), h))
+}
+
+// ListFilesContext is ListFiles with a context.
+func (c *Client) ListFilesContext(ctx context.Context) ([]File, error) {
 	var out []File
-	err := c.request(h, http.MethodGet, "/v1/file/list", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/file/list", nil, &out)
 	return out, err
 }
 
 // SearchFiles searches for files by name within a folder. Pass "" for
 // folderID to search the root folder.
 func (c *Client) SearchFiles(h http.Header, folderID, fileName string) ([]File, error) {
+	return c.SearchFilesContext(withHeader(context.Background(), h), folderID, fileName)
+}
+
+// SearchFilesContext is SearchFiles with a context.
+func (c *Client) SearchFilesContext(ctx context.Context, folderID, fileName string) ([]File, error) {
 	var out []File
-	path := fmt.Sprintf("/v1/folder/files/%s", fileName)
+	path := "/v1/folder/files/" + url.PathEscape(fileName)
 	if folderID != "" {
-		path = fmt.Sprintf("/v1/folder/%s/files/%s", folderID, fileName)
+		path = fmt.Sprintf("/v1/folder/%s/files/%s", folderID, url.PathEscape(fileName))
 	}
-	err := c.request(h, http.MethodGet, path, nil, &out)
+	err := c.request(ctx, http.MethodGet, path, nil, &out)
 	return out, err
 }
 
+// SearchFolder finds what's directly inside a folder by name, as the drive's
+// search box does: the subfolders whose names contain query, ignoring case,
+// followed by the files whose names start with it, as SearchFiles finds
+// them. Pass "" for folderID to search the root folder.
+func (c *Client) SearchFolder(h http.Header, folderID, query string) ([]FolderItem, error) {
+	return c.SearchFolderContext(withHeader(context.Background(), h), folderID, query)
+}
+
+// SearchFolderContext is SearchFolder with a context.
+func (c *Client) SearchFolderContext(ctx context.Context, folderID, query string) ([]FolderItem, error) {
+	// The search endpoint only covers files, so folders are matched here.
+	var out []FolderItem
+	q := strings.ToLower(query)
+	for item, err := range c.FolderItems(ctx, folderID, ListOptions{Type: FolderItemTypeFolder}) {
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(strings.ToLower(item.Name), q) {
+			out = append(out, item)
+		}
+	}
+
+	files, err := c.SearchFilesContext(ctx, folderID, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		out = append(out, FolderItem{
+			Type:      FolderItemTypeFile,
+			ID:        f.ID,
+			UUID:      f.UUID,
+			Name:      f.Name,
+			Extension: f.Extension,
+			MimeType:  f.MimeType,
+			FileSize:  f.FileSize,
+			Checksum:  f.Checksum,
+			CreatedBy: f.CreatedBy,
+			CreatedAt: f.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
 // DownloadFile streams a file's contents. The caller must close the
 // returned response body. The file name is available on
 // resp.Header.Get("Content-Disposition").
 func (c *Client) DownloadFile(h http.Header, fileID string) (*http.Response, error) {
-	return c.rawRequest(h, http.MethodGet, fmt.Sprintf("/v1/file/%s", fileID), nil, "")
+	return c.DownloadFileContext(withHeader(context.Background(), h), fileID)
+}
+
+// DownloadFileContext is DownloadFile with a context.
+func (c *Client) DownloadFileContext(ctx context.Context, fileID string) (*http.Response, error) {
+	return c.download(ctx, fmt.Sprintf("/v1/file/%s", fileID))
 }
 
 // MoveFile moves a file to a new parent folder. parent is the destination
 // folder's UUID, or "" to move the file to the root.
 func (c *Client) MoveFile(h http.Header, fileID, parent string) error {
+	return c.MoveFileContext(withHeader(context.Background(), h), fileID, parent)
+}
+
+// MoveFileContext is MoveFile with a context.
+func (c *Client) MoveFileContext(ctx context.Context, fileID, parent string) error {
 	req := MoveFileRequest{Parent: parent}
-	return c.request(h, http.MethodPatch, fmt.Sprintf("/v1/file/%s/move", fileID), req, nil)
+	return c.request(ctx, http.MethodPatch, fmt.Sprintf("/v1/file/%s/move", fileID), req, nil)
 }
 
 // UpdateFileName renames a file.
 func (c *Client) UpdateFileName(h http.Header, fileID, newName string) error {
-	return c.request(h, http.MethodPatch, fmt.Sprintf("/v1/file/%s/%s", fileID, newName), nil, nil)
+	return c.UpdateFileNameContext(withHeader(context.Background(), h), fileID, newName)
+}
+
+// UpdateFileNameContext is UpdateFileName with a context.
+func (c *Client) UpdateFileNameContext(ctx context.Context, fileID, newName string) error {
+	return c.request(ctx, http.MethodPatch, fmt.Sprintf("/v1/file/%s/%s", fileID, newName), nil, nil)
 }
 
 // DeleteFile moves a file to the trash.
 func (c *Client) DeleteFile(h http.Header, fileID string) error {
-	return c.request(h, http.MethodDelete, fmt.Sprintf("/v1/file/%s", fileID), nil, nil)
+	return c.DeleteFileContext(withHeader(context.Background(), h), fileID)
+}
+
+// DeleteFileContext is DeleteFile with a context.
+func (c *Client) DeleteFileContext(ctx context.Context, fileID string) error {
+	return c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/file/%s", fileID), nil, nil)
+}
+
+// RestoreFile restores a trashed file. The response carries how many items
+// are left in the trash.
+func (c *Client) RestoreFile(h http.Header, fileID string) (V1RestoreResponse, error) {
+	return c.RestoreFileContext(withHeader(context.Background(), h), fileID)
 }
 
-// RestoreFile restores a trashed file.
-func (c *Client) RestoreFile(h http.Header, fileID string) error {
-	return c.request(h, http.MethodPatch, fmt.Sprintf("/v1/file/%s/restore", fileID), nil, nil)
+// RestoreFileContext is RestoreFile with a context.
+func (c *Client) RestoreFileContext(ctx context.Context, fileID string) (V1RestoreResponse, error) {
+	var out V1RestoreResponse
+	err := c.request(ctx, http.MethodPatch, fmt.Sprintf("/v1/file/%s/restore", fileID), nil, &out)
+	return out, err
 }
 
 // PurgeFile permanently deletes a trashed file.
 func (c *Client) PurgeFile(h http.Header, fileID string) error {
-	return c.request(h, http.MethodDelete, fmt.Sprintf("/v1/file/%s/purge", fileID), nil, nil)
+	return c.PurgeFileContext(withHeader(context.Background(), h), fileID)
+}
+
+// PurgeFileContext is PurgeFile with a context.
+func (c *Client) PurgeFileContext(ctx context.Context, fileID string) error {
+	return c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/file/%s/purge", fileID), nil, nil)
+}
+
+// ListTrash lists the files and folders the user has trashed. opts selects
+// the page and its order; opts.Type is ignored.
+func (c *Client) ListTrash(h http.Header, opts ListOptions) (V1TrashResponse, error) {
+	return c.ListTrashContext(withHeader(context.Background(), h), opts)
 }
 
-// ListTrash lists the files and folders the user has trashed. page and
-// limit control pagination of both lists; pass 0 for either to use the
-// server-side defaults.
-func (c *Client) ListTrash(h http.Header, page, limit int) (V1TrashResponse, error) {
+// ListTrashContext is ListTrash with a context.
+func (c *Client) ListTrashContext(ctx context.Context, opts ListOptions) (V1TrashResponse, error) {
 	var out V1TrashResponse
-	path := "/v1/trash?" + paginationQuery(page, limit)
-	err := c.request(h, http.MethodGet, path, nil, &out)
+	opts.Type = ""
+	path := "/v1/trash?" + opts.query()
+	err := c.request(ctx, http.MethodGet, path, nil, &out)
 	return out, err
 }
 
 // BulkDelete moves a batch of files and folders to the trash in a single
-// request.
-func (c *Client) BulkDelete(h http.Header, req BulkDeleteRequest) (MessageResponse, error) {
-	var out MessageResponse
-	err := c.request(h, http.MethodDelete, "/v1/files/bulk-delete", req, &out)
+// request. Like EmptyTrash, the returned job may still be running.
+func (c *Client) BulkDelete(h http.Header, req BulkDeleteRequest) (Job, error) {
+	return c.BulkDeleteContext(withHeader(context.Background(), h), req)
+}
+
+// BulkDeleteContext is BulkDelete with a context.
+func (c *Client) BulkDeleteContext(ctx context.Context, req BulkDeleteRequest) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodDelete, "/v1/files/bulk-delete", req, &out)
 	return out, err
 }
 
 // BulkRestore restores a batch of trashed files and folders in a single
 // request.
 func (c *Client) BulkRestore(h http.Header, req BulkRestoreRequest) (V1RestoreResponse, error) {
+	return c.BulkRestoreContext(withHeader(context.Background(), h), req)
+}
+
+// BulkRestoreContext is BulkRestore with a context.
+func (c *Client) BulkRestoreContext(ctx context.Context, req BulkRestoreRequest) (V1RestoreResponse, error) {
 	var out V1RestoreResponse
-	err := c.request(h, http.MethodPatch, "/v1/files/bulk-restore", req, &out)
+	err := c.request(ctx, http.MethodPatch, "/v1/files/bulk-restore", req, &out)
 	return out, err
 }
 
 // BulkMove moves a batch of files and folders to a new parent folder in a
 // single request.
 func (c *Client) BulkMove(h http.Header, req BulkMoveRequest) (MessageResponse, error) {
+	return c.BulkMoveContext(withHeader(context.Background(), h), req)
+}
+
+// BulkMoveContext is BulkMove with a context.
+func (c *Client) BulkMoveContext(ctx context.Context, req BulkMoveRequest) (MessageResponse, error) {
 	var out MessageResponse
-	err := c.request(h, http.MethodPatch, "/v1/files/bulk-move", req, &out)
+	err := c.request(ctx, http.MethodPatch, "/v1/files/bulk-move", req, &out)
 	return out, err
 }
 
-// EmptyTrash permanently deletes everything the user has trashed.
-func (c *Client) EmptyTrash(h http.Header) error {
-	return c.request(h, http.MethodPost, "/v1/trash/empty", nil, nil)
+// EmptyTrash permanently deletes everything the user has trashed. The
+// returned job is done unless emptying took more than a few seconds, in
+// which case it carries on in the background; poll GetJob for progress.
+func (c *Client) EmptyTrash(h http.Header) (Job, error) {
+	return c.EmptyTrashContext(withHeader(context.Background(), h))
 }
 
-// DownloadFilesZip streams a zip archive of the requested files, or of a
-// single folder's subtree, as a raw response. The caller must close the
-// returned response body. The archive name is available on
+// EmptyTrashContext is EmptyTrash with a context.
+func (c *Client) EmptyTrashContext(ctx context.Context) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodPost, "/v1/trash/empty", nil, &out)
+	return out, err
+}
+
+// DownloadFilesZip streams an archive of the requested files and folders as
+// a raw response, in req.Format (a deflated zip by default). The caller must
+// close the returned response body. The archive name is available on
 // resp.Header.Get("Content-Disposition").
 func (c *Client) DownloadFilesZip(h http.Header, req DownloadFilesZipRequest) (*http.Response, error) {
+	return c.DownloadFilesZipContext(withHeader(context.Background(), h), req)
+}
+
+// DownloadFilesZipContext is DownloadFilesZip with a context.
+func (c *Client) DownloadFilesZipContext(ctx context.Context, req DownloadFilesZipRequest) (*http.Response, error) {
 	q := url.Values{}
 	for _, id := range req.FileIDs {
 		q.Add("ids", id)
@@ -144,5 +250,38 @@ func (c *Client) DownloadFilesZip(h http.Header, req DownloadFilesZipRequest) (*
 	for _, id := range req.FolderIDs {
 		q.Add("folderIds", id)
 	}
-	return c.rawRequest(h, http.MethodGet, "/v1/files/zip?"+q.Encode(), nil, "")
+	if req.Format != "" {
+		q.Set("format", req.Format)
+	}
+	return c.download(ctx, "/v1/files/zip?"+q.Encode())
+}
+
+// ExtractArchive unpacks a stored zip, tar or tar.gz file into req.Folder
+// (the drive root when empty). The returned job is done unless extraction
+// took more than a few seconds, in which case it carries on in the
+// background; poll GetJob for progress. Its result is an ExtractResult.
+func (c *Client) ExtractArchive(h http.Header, fileID string, req ExtractArchiveRequest) (Job, error) {
+	return c.ExtractArchiveContext(withHeader(context.Background(), h), fileID, req)
+}
+
+// ExtractArchiveContext is ExtractArchive with a context.
+func (c *Client) ExtractArchiveContext(ctx context.Context, fileID string, req ExtractArchiveRequest) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodPost, "/v1/file/"+url.PathEscape(fileID)+"/extract", req, &out)
+	return out, err
+}
+
+// BulkCopy copies files and folder subtrees into req.Parent. The returned
+// job is done unless copying took more than a few seconds, in which case it
+// carries on in the background; poll GetJob for progress. Its result is a
+// CopyResult.
+func (c *Client) BulkCopy(h http.Header, req BulkCopyRequest) (Job, error) {
+	return c.BulkCopyContext(withHeader(context.Background(), h), req)
+}
+
+// BulkCopyContext is BulkCopy with a context.
+func (c *Client) BulkCopyContext(ctx context.Context, req BulkCopyRequest) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodPost, "/v1/files/bulk-copy", req, &out)
+	return out, err
 }
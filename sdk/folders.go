@@ -1,6 +1,7 @@
 package sdk
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
@@ -8,40 +9,101 @@ import (
 // CreateFolder creates a new folder. parent is the destination folder's
 // UUID, or "" for the root folder.
 func (c *Client) CreateFolder(h http.Header, name, parent string) error {
+	return c.CreateFolderContext(withHeader(context.Background(), h), name, parent)
+}
+
+// CreateFolderContext is CreateFolder with a context.
+func (c *Client) CreateFolderContext(ctx context.Context, name, parent string) error {
 	req := CreateFolderRequest{Name: name, Parent: parent}
-	return c.request(h, http.MethodPost, "/v1/folder", req, nil)
+	return c.request(ctx, http.MethodPost, "/v1/folder", req, nil)
 }
 
 // DeleteFolder moves a folder, and everything nested inside it, to the trash.
 func (c *Client) DeleteFolder(h http.Header, folderID string) (MessageResponse, error) {
+	return c.DeleteFolderContext(withHeader(context.Background(), h), folderID)
+}
+
+// DeleteFolderContext is DeleteFolder with a context.
+func (c *Client) DeleteFolderContext(ctx context.Context, folderID string) (MessageResponse, error) {
 	var out MessageResponse
-	err := c.request(h, http.MethodDelete, fmt.Sprintf("/v1/folder/%s", folderID), nil, &out)
+	err := c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/folder/%s", folderID), nil, &out)
 	return out, err
 }
 
 // RestoreFolder restores a trashed folder and everything nested inside it.
-func (c *Client) RestoreFolder(h http.Header, folderID string) error {
-	return c.request(h, http.MethodPatch, fmt.Sprintf("/v1/folder/%s/restore", folderID), nil, nil)
+// The response carries how many items are left in the trash.
+func (c *Client) RestoreFolder(h http.Header, folderID string) (V1RestoreResponse, error) {
+	return c.RestoreFolderContext(withHeader(context.Background(), h), folderID)
+}
+
+// RestoreFolderContext is RestoreFolder with a context.
+func (c *Client) RestoreFolderContext(ctx context.Context, folderID string) (V1RestoreResponse, error) {
+	var out V1RestoreResponse
+	err := c.request(ctx, http.MethodPatch, fmt.Sprintf("/v1/folder/%s/restore", folderID), nil, &out)
+	return out, err
 }
 
 // PurgeFolder permanently deletes a trashed folder and everything nested
-// inside it.
-func (c *Client) PurgeFolder(h http.Header, folderID string) error {
-	return c.request(h, http.MethodDelete, fmt.Sprintf("/v1/folder/%s/purge", folderID), nil, nil)
+// inside it. Like EmptyTrash, the returned job may still be running.
+func (c *Client) PurgeFolder(h http.Header, folderID string) (Job, error) {
+	return c.PurgeFolderContext(withHeader(context.Background(), h), folderID)
+}
+
+// PurgeFolderContext is PurgeFolder with a context.
+func (c *Client) PurgeFolderContext(ctx context.Context, folderID string) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/folder/%s/purge", folderID), nil, &out)
+	return out, err
 }
 
 // UpdateFolderName renames a folder.
 func (c *Client) UpdateFolderName(h http.Header, folderID, newName string) error {
-	return c.request(h, http.MethodPatch, fmt.Sprintf("/v1/folder/%s/%s", folderID, newName), nil, nil)
+	return c.UpdateFolderNameContext(withHeader(context.Background(), h), folderID, newName)
+}
+
+// UpdateFolderNameContext is UpdateFolderName with a context.
+func (c *Client) UpdateFolderNameContext(ctx context.Context, folderID, newName string) error {
+	return c.request(ctx, http.MethodPatch, fmt.Sprintf("/v1/folder/%s/%s", folderID, newName), nil, nil)
 }
 
 // ListFolderContents lists the files and subfolders of a folder, along with
-// its breadcrumb trail. Pass "" for folderID to list the root folder. page
-// and limit control pagination of both lists; pass 0 for either to use the
-// server-side defaults.
-func (c *Client) ListFolderContents(h http.Header, folderID string, page, limit int) (V1FolderContentsResponse, error) {
+// its breadcrumb trail. Pass "" for folderID to list the root folder. opts
+// selects the page, its order and, with opts.Type, whether only folders or
+// only files are listed.
+func (c *Client) ListFolderContents(h http.Header, folderID string, opts ListOptions) (V1FolderContentsResponse, error) {
+	return c.ListFolderContentsContext(withHeader(context.Background(), h), folderID, opts)
+}
+
+// ListFolderContentsContext is ListFolderContents with a context.
+func (c *Client) ListFolderContentsContext(ctx context.Context, folderID string, opts ListOptions) (V1FolderContentsResponse, error) {
 	var out V1FolderContentsResponse
-	path := fmt.Sprintf("/v1/folder/list/%s?%s", folderID, paginationQuery(page, limit))
-	err := c.request(h, http.MethodGet, path, nil, &out)
+	path := fmt.Sprintf("/v1/folder/list/%s?%s", folderID, opts.query())
+	err := c.request(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+// ListRootFolderContents lists the root folder, as ListFolderContents does
+// for "".
+func (c *Client) ListRootFolderContents(h http.Header, opts ListOptions) (V1FolderContentsResponse, error) {
+	return c.ListRootFolderContentsContext(withHeader(context.Background(), h), opts)
+}
+
+// ListRootFolderContentsContext is ListRootFolderContents with a context.
+func (c *Client) ListRootFolderContentsContext(ctx context.Context, opts ListOptions) (V1FolderContentsResponse, error) {
+	return c.ListFolderContentsContext(ctx, "", opts)
+}
+
+// SetFolderQuota caps how much a folder and everything under it may hold.
+// A quota of 0 removes the cap. Returns the quotas now applying to the
+// folder, nearest first.
+func (c *Client) SetFolderQuota(h http.Header, folderID string, quota int64) ([]FolderQuota, error) {
+	return c.SetFolderQuotaContext(withHeader(context.Background(), h), folderID, quota)
+}
+
+// SetFolderQuotaContext is SetFolderQuota with a context.
+func (c *Client) SetFolderQuotaContext(ctx context.Context, folderID string, quota int64) ([]FolderQuota, error) {
+	var out []FolderQuota
+	req := SetFolderQuotaRequest{Quota: quota}
+	err := c.request(ctx, http.MethodPut, fmt.Sprintf("/v1/folder/%s/quota", folderID), req, &out)
 	return out, err
 }
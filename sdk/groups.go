@@ -0,0 +1,114 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListMyGroups returns the groups the caller belongs to, with how much of
+// each one's pooled quota is used.
+func (c *Client) ListMyGroups(h http.Header) ([]Group, error) {
+	return c.ListMyGroupsContext(withHeader(context.Background(), h))
+}
+
+// ListMyGroupsContext is ListMyGroups with a context.
+func (c *Client) ListMyGroupsContext(ctx context.Context) ([]Group, error) {
+	var out []Group
+	err := c.request(ctx, http.MethodGet, "/v1/user/groups", nil, &out)
+	return out, err
+}
+
+// AdminListGroups returns every group. Requires an admin caller.
+func (c *Client) AdminListGroups(h http.Header) ([]Group, error) {
+	return c.AdminListGroupsContext(withHeader(context.Background(), h))
+}
+
+// AdminListGroupsContext is AdminListGroups with a context.
+func (c *Client) AdminListGroupsContext(ctx context.Context) ([]Group, error) {
+	var out []Group
+	err := c.request(ctx, http.MethodGet, "/v1/admin/groups", nil, &out)
+	return out, err
+}
+
+// AdminGetGroup returns a group. Requires an admin caller.
+func (c *Client) AdminGetGroup(h http.Header, groupID int64) (Group, error) {
+	return c.AdminGetGroupContext(withHeader(context.Background(), h), groupID)
+}
+
+// AdminGetGroupContext is AdminGetGroup with a context.
+func (c *Client) AdminGetGroupContext(ctx context.Context, groupID int64) (Group, error) {
+	var out Group
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/admin/groups/%d", groupID), nil, &out)
+	return out, err
+}
+
+// AdminCreateGroup creates a group. Requires an admin caller.
+func (c *Client) AdminCreateGroup(h http.Header, req GroupRequest) (Group, error) {
+	return c.AdminCreateGroupContext(withHeader(context.Background(), h), req)
+}
+
+// AdminCreateGroupContext is AdminCreateGroup with a context.
+func (c *Client) AdminCreateGroupContext(ctx context.Context, req GroupRequest) (Group, error) {
+	var out Group
+	err := c.request(ctx, http.MethodPost, "/v1/admin/groups", req, &out)
+	return out, err
+}
+
+// AdminUpdateGroup renames a group and sets its quotas. Requires an admin
+// caller.
+func (c *Client) AdminUpdateGroup(h http.Header, groupID int64, req GroupRequest) (Group, error) {
+	return c.AdminUpdateGroupContext(withHeader(context.Background(), h), groupID, req)
+}
+
+// AdminUpdateGroupContext is AdminUpdateGroup with a context.
+func (c *Client) AdminUpdateGroupContext(ctx context.Context, groupID int64, req GroupRequest) (Group, error) {
+	var out Group
+	err := c.request(ctx, http.MethodPut, fmt.Sprintf("/v1/admin/groups/%d", groupID), req, &out)
+	return out, err
+}
+
+// AdminDeleteGroup deletes a group. Its members keep their files. Requires
+// an admin caller.
+func (c *Client) AdminDeleteGroup(h http.Header, groupID int64) error {
+	return c.AdminDeleteGroupContext(withHeader(context.Background(), h), groupID)
+}
+
+// AdminDeleteGroupContext is AdminDeleteGroup with a context.
+func (c *Client) AdminDeleteGroupContext(ctx context.Context, groupID int64) error {
+	return c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/admin/groups/%d", groupID), nil, nil)
+}
+
+// AdminListGroupMembers returns a group's members. Requires an admin
+// caller.
+func (c *Client) AdminListGroupMembers(h http.Header, groupID int64) ([]User, error) {
+	return c.AdminListGroupMembersContext(withHeader(context.Background(), h), groupID)
+}
+
+// AdminListGroupMembersContext is AdminListGroupMembers with a context.
+func (c *Client) AdminListGroupMembersContext(ctx context.Context, groupID int64) ([]User, error) {
+	var out []User
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/admin/groups/%d/members", groupID), nil, &out)
+	return out, err
+}
+
+// AdminAddGroupMember adds a user to a group. Requires an admin caller.
+func (c *Client) AdminAddGroupMember(h http.Header, groupID, userID int64) error {
+	return c.AdminAddGroupMemberContext(withHeader(context.Background(), h), groupID, userID)
+}
+
+// AdminAddGroupMemberContext is AdminAddGroupMember with a context.
+func (c *Client) AdminAddGroupMemberContext(ctx context.Context, groupID, userID int64) error {
+	return c.request(ctx, http.MethodPut, fmt.Sprintf("/v1/admin/groups/%d/members/%d", groupID, userID), nil, nil)
+}
+
+// AdminRemoveGroupMember removes a user from a group. Requires an admin
+// caller.
+func (c *Client) AdminRemoveGroupMember(h http.Header, groupID, userID int64) error {
+	return c.AdminRemoveGroupMemberContext(withHeader(context.Background(), h), groupID, userID)
+}
+
+// AdminRemoveGroupMemberContext is AdminRemoveGroupMember with a context.
+func (c *Client) AdminRemoveGroupMemberContext(ctx context.Context, groupID, userID int64) error {
+	return c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/admin/groups/%d/members/%d", groupID, userID), nil, nil)
+}
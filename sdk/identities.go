@@ -0,0 +1,49 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ListIdentities lists the SSO identities linked to the authenticated user.
+func (c *Client) ListIdentities(h http.Header) ([]UserIdentity, error) {
+	return c.ListIdentitiesContext(withHeader(context.Background(), h))
+}
+
+// ListIdentitiesContext is ListIdentities with a context.
+func (c *Client) ListIdentitiesContext(ctx context.Context) ([]UserIdentity, error) {
+	var out []UserIdentity
+	err := c.request(ctx, http.MethodGet, "/v1/user/identities", nil, &out)
+	return out, err
+}
+
+// LinkIdentity starts linking an identity from the given SSO provider to the
+// authenticated user. The returned URL must be opened in a browser to
+// complete the link, after which the browser is sent to redirect, a path on
+// the server ("/profile" when empty).
+func (c *Client) LinkIdentity(h http.Header, provider, redirect string) (V1IdentityLinkResponse, error) {
+	return c.LinkIdentityContext(withHeader(context.Background(), h), provider, redirect)
+}
+
+// LinkIdentityContext is LinkIdentity with a context.
+func (c *Client) LinkIdentityContext(ctx context.Context, provider, redirect string) (V1IdentityLinkResponse, error) {
+	var out V1IdentityLinkResponse
+	path := "/v1/user/identities/" + url.PathEscape(provider) + "/link"
+	if redirect != "" {
+		path += "?redirect=" + url.QueryEscape(redirect)
+	}
+	err := c.request(ctx, http.MethodPost, path, nil, &out)
+	return out, err
+}
+
+// UnlinkIdentity removes a linked SSO identity by ID.
+func (c *Client) UnlinkIdentity(h http.Header, identityID int64) error {
+	return c.UnlinkIdentityContext(withHeader(context.Background(), h), identityID)
+}
+
+// UnlinkIdentityContext is UnlinkIdentity with a context.
+func (c *Client) UnlinkIdentityContext(ctx context.Context, identityID int64) error {
+	return c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/user/identities/%d", identityID), nil, nil)
+}
@@ -0,0 +1,105 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ListJobs lists the authenticated user's background jobs, newest first.
+// status may be empty for all statuses.
+func (c *Client) ListJobs(h http.Header, status string, page, limit int) (V1JobsResponse, error) {
+	return c.ListJobsContext(withHeader(context.Background(), h), status, page, limit)
+}
+
+// ListJobsContext is ListJobs with a context.
+func (c *Client) ListJobsContext(ctx context.Context, status string, page, limit int) (V1JobsResponse, error) {
+	return c.listJobs(ctx, "/v1/jobs?", status, page, limit)
+}
+
+// AdminListJobs lists every user's background jobs along with system jobs
+// such as the trash sweep, newest first. status may be empty for all
+// statuses. Requires an admin caller.
+func (c *Client) AdminListJobs(h http.Header, status string, page, limit int) (V1JobsResponse, error) {
+	return c.AdminListJobsContext(withHeader(context.Background(), h), status, page, limit)
+}
+
+// AdminListJobsContext is AdminListJobs with a context.
+func (c *Client) AdminListJobsContext(ctx context.Context, status string, page, limit int) (V1JobsResponse, error) {
+	return c.listJobs(ctx, "/v1/admin/jobs?", status, page, limit)
+}
+
+func (c *Client) listJobs(ctx context.Context, path, status string, page, limit int) (V1JobsResponse, error) {
+	path += paginationQuery(page, limit)
+	if status != "" {
+		path += "&status=" + url.QueryEscape(status)
+	}
+
+	var out V1JobsResponse
+	err := c.request(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+// GetJob returns a background job's current status and progress.
+func (c *Client) GetJob(h http.Header, jobID int64) (Job, error) {
+	return c.GetJobContext(withHeader(context.Background(), h), jobID)
+}
+
+// GetJobContext is GetJob with a context.
+func (c *Client) GetJobContext(ctx context.Context, jobID int64) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/jobs/%d", jobID), nil, &out)
+	return out, err
+}
+
+// CancelJob cancels a pending or running job. A running job stops within a
+// few seconds; poll GetJob to see it finish.
+func (c *Client) CancelJob(h http.Header, jobID int64) (Job, error) {
+	return c.CancelJobContext(withHeader(context.Background(), h), jobID)
+}
+
+// CancelJobContext is CancelJob with a context.
+func (c *Client) CancelJobContext(ctx context.Context, jobID int64) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodPost, fmt.Sprintf("/v1/jobs/%d/cancel", jobID), nil, &out)
+	return out, err
+}
+
+// CreateArchiveJob builds an archive of the requested files and folders in
+// the background. Once the job is done, fetch it with DownloadJobArchive.
+func (c *Client) CreateArchiveJob(h http.Header, req DownloadFilesZipRequest) (Job, error) {
+	return c.CreateArchiveJobContext(withHeader(context.Background(), h), req)
+}
+
+// CreateArchiveJobContext is CreateArchiveJob with a context.
+func (c *Client) CreateArchiveJobContext(ctx context.Context, req DownloadFilesZipRequest) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodPost, "/v1/files/archive", req, &out)
+	return out, err
+}
+
+// DownloadJobArchive streams the archive built by a files.archive job as a
+// raw response. The caller must close the returned response body.
+func (c *Client) DownloadJobArchive(h http.Header, jobID int64) (*http.Response, error) {
+	return c.DownloadJobArchiveContext(withHeader(context.Background(), h), jobID)
+}
+
+// DownloadJobArchiveContext is DownloadJobArchive with a context.
+func (c *Client) DownloadJobArchiveContext(ctx context.Context, jobID int64) (*http.Response, error) {
+	return c.download(ctx, fmt.Sprintf("/v1/jobs/%d/download", jobID))
+}
+
+// AdminImport imports a directory from the server's import_dir into a
+// user's drive in the background. Running the same import again picks up
+// whatever an earlier run didn't finish. Requires an admin caller.
+func (c *Client) AdminImport(h http.Header, req ImportRequest) (Job, error) {
+	return c.AdminImportContext(withHeader(context.Background(), h), req)
+}
+
+// AdminImportContext is AdminImport with a context.
+func (c *Client) AdminImportContext(ctx context.Context, req ImportRequest) (Job, error) {
+	var out Job
+	err := c.request(ctx, http.MethodPost, "/v1/admin/import", req, &out)
+	return out, err
+}
@@ -0,0 +1,67 @@
+package sdk
+
+import (
+	"context"
+	"iter"
+)
+
+// pages yields every item of a paginated listing, fetching a page at a
+// time with list until it has had total items or an empty page. It stops
+// at the first error, yielding it with a zero item.
+func pages[T any](list func(page int) (items []T, total int, err error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		seen := 0
+		for page := 1; ; page++ {
+			items, total, err := list(page)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			seen += len(items)
+			if len(items) == 0 || seen >= total {
+				return
+			}
+		}
+	}
+}
+
+// FolderItems iterates over everything in a folder, as
+// ListFolderContents lists it with opts, fetching opts.Limit items at a time
+// (the server's default when 0). opts.Page is ignored. Pass "" for folderID
+// for the root folder.
+func (c *Client) FolderItems(ctx context.Context, folderID string, opts ListOptions) iter.Seq2[FolderItem, error] {
+	return pages(func(page int) ([]FolderItem, int, error) {
+		opts := opts
+		opts.Page = page
+		out, err := c.ListFolderContentsContext(ctx, folderID, opts)
+		return out.Items, out.Total, err
+	})
+}
+
+// TrashItems iterates over the user's trash, as ListTrash lists it with
+// opts, fetching opts.Limit items at a time (the server's default when 0).
+// opts.Page is ignored.
+func (c *Client) TrashItems(ctx context.Context, opts ListOptions) iter.Seq2[FolderItem, error] {
+	return pages(func(page int) ([]FolderItem, int, error) {
+		opts := opts
+		opts.Page = page
+		out, err := c.ListTrashContext(ctx, opts)
+		return out.Items, out.Total, err
+	})
+}
+
+// Jobs iterates over the user's background jobs, newest first, as ListJobs
+// lists them, fetching limit jobs at a time (the server's default when 0).
+// status may be empty for all statuses.
+func (c *Client) Jobs(ctx context.Context, status string, limit int) iter.Seq2[Job, error] {
+	return pages(func(page int) ([]Job, int, error) {
+		out, err := c.ListJobsContext(ctx, status, page, limit)
+		return out.Jobs, out.Total, err
+	})
+}
@@ -1,6 +1,7 @@
 package sdk
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
@@ -8,19 +9,34 @@ import (
 // ListSessions lists the authenticated user's active sessions, with the
 // session used to make the request marked via IsCurrent.
 func (c *Client) ListSessions(h http.Header) ([]SessionInfo, error) {
+	return c.ListSessionsContext(withHeader(context.Background(), h))
+}
+
+// ListSessionsContext is ListSessions with a context.
+func (c *Client) ListSessionsContext(ctx context.Context) ([]SessionInfo, error) {
 	var out []SessionInfo
-	err := c.request(h, http.MethodGet, "/v1/user/sessions", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/user/sessions", nil, &out)
 	return out, err
 }
 
 // RevokeSession revokes a single session by ID. The caller's current session
 // cannot be revoked this way; use Logout instead.
 func (c *Client) RevokeSession(h http.Header, sessionID int64) error {
-	return c.request(h, http.MethodDelete, fmt.Sprintf("/v1/user/sessions/%d", sessionID), nil, nil)
+	return c.RevokeSessionContext(withHeader(context.Background(), h), sessionID)
+}
+
+// RevokeSessionContext is RevokeSession with a context.
+func (c *Client) RevokeSessionContext(ctx context.Context, sessionID int64) error {
+	return c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/user/sessions/%d", sessionID), nil, nil)
 }
 
 // RevokeOtherSessions revokes every session for the authenticated user except
 // the one making the request.
 func (c *Client) RevokeOtherSessions(h http.Header) error {
-	return c.request(h, http.MethodDelete, "/v1/user/sessions", nil, nil)
+	return c.RevokeOtherSessionsContext(withHeader(context.Background(), h))
+}
+
+// RevokeOtherSessionsContext is RevokeOtherSessions with a context.
+func (c *Client) RevokeOtherSessionsContext(ctx context.Context) error {
+	return c.request(ctx, http.MethodDelete, "/v1/user/sessions", nil, nil)
 }
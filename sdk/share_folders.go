@@ -1,70 +1,110 @@
 package sdk
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // CreateFolderShareLink creates a public share link for a folder. Requires
 // folder sharing to be enabled server-side.
 func (c *Client) CreateFolderShareLink(h http.Header, folderID string, req CreateShareLinkRequest) (V1ShareLinkResponse, error) {
+	return c.CreateFolderShareLinkContext(withHeader(context.Background(), h), folderID, req)
+}
+
+// CreateFolderShareLinkContext is CreateFolderShareLink with a context.
+func (c *Client) CreateFolderShareLinkContext(ctx context.Context, folderID string, req CreateShareLinkRequest) (V1ShareLinkResponse, error) {
 	var out V1ShareLinkResponse
-	err := c.request(h, http.MethodPost, fmt.Sprintf("/v1/folder/%s/share", folderID), req, &out)
+	err := c.request(ctx, http.MethodPost, fmt.Sprintf("/v1/folder/%s/share", folderID), req, &out)
 	return out, err
 }
 
 // ListFolderShares lists active share links for a single folder.
 func (c *Client) ListFolderShares(h http.Header, folderID string) ([]ShareFolderLink, error) {
+	return c.ListFolderSharesContext(withHeader(context.Background(), h), folderID)
+}
+
+// ListFolderSharesContext is ListFolderShares with a context.
+func (c *Client) ListFolderSharesContext(ctx context.Context, folderID string) ([]ShareFolderLink, error) {
 	var out []ShareFolderLink
-	err := c.request(h, http.MethodGet, fmt.Sprintf("/v1/folder/%s/shares", folderID), nil, &out)
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/folder/%s/shares", folderID), nil, &out)
 	return out, err
 }
 
 // ListUserFolderShares lists all active folder share links created by the
 // authenticated user.
 func (c *Client) ListUserFolderShares(h http.Header) ([]ShareFolderLink, error) {
+	return c.ListUserFolderSharesContext(withHeader(context.Background(), h))
+}
+
+// ListUserFolderSharesContext is ListUserFolderShares with a context.
+func (c *Client) ListUserFolderSharesContext(ctx context.Context) ([]ShareFolderLink, error) {
 	var out []ShareFolderLink
-	err := c.request(h, http.MethodGet, "/v1/folder-shares", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/folder-shares", nil, &out)
 	return out, err
 }
 
 // ListExpiredUserFolderShares lists expired folder share links created by
 // the authenticated user.
 func (c *Client) ListExpiredUserFolderShares(h http.Header) ([]ShareFolderLink, error) {
+	return c.ListExpiredUserFolderSharesContext(withHeader(context.Background(), h))
+}
+
+// ListExpiredUserFolderSharesContext is ListExpiredUserFolderShares with a context.
+func (c *Client) ListExpiredUserFolderSharesContext(ctx context.Context) ([]ShareFolderLink, error) {
 	var out []ShareFolderLink
-	err := c.request(h, http.MethodGet, "/v1/folder-shares/expired", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/folder-shares/expired", nil, &out)
 	return out, err
 }
 
 // RevokeShareFolderLink revokes a folder share link.
 func (c *Client) RevokeShareFolderLink(h http.Header, token string) error {
-	return c.request(h, http.MethodDelete, fmt.Sprintf("/v1/share/folder/%s", token), nil, nil)
+	return c.RevokeShareFolderLinkContext(withHeader(context.Background(), h), token)
+}
+
+// RevokeShareFolderLinkContext is RevokeShareFolderLink with a context.
+func (c *Client) RevokeShareFolderLinkContext(ctx context.Context, token string) error {
+	return c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/share/folder/%s", token), nil, nil)
 }
 
 // GetSharedFolderContents lists the files and subfolders at the root of a
 // shared folder via its public share token.
 func (c *Client) GetSharedFolderContents(h http.Header, token string) (V1SharedFolderContentsResponse, error) {
+	return c.GetSharedFolderContentsContext(withHeader(context.Background(), h), token)
+}
+
+// GetSharedFolderContentsContext is GetSharedFolderContents with a context.
+func (c *Client) GetSharedFolderContentsContext(ctx context.Context, token string) (V1SharedFolderContentsResponse, error) {
 	var out V1SharedFolderContentsResponse
-	err := c.request(h, http.MethodGet, fmt.Sprintf("/api/share/folder/%s", token), nil, &out)
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/api/share/folder/%s", token), nil, &out)
 	return out, err
 }
 
 // BrowseSharedSubFolder lists the files and subfolders of a subfolder
 // within a shared folder tree.
 func (c *Client) BrowseSharedSubFolder(h http.Header, token, subFolderUUID string) (V1SharedFolderContentsResponse, error) {
+	return c.BrowseSharedSubFolderContext(withHeader(context.Background(), h), token, subFolderUUID)
+}
+
+// BrowseSharedSubFolderContext is BrowseSharedSubFolder with a context.
+func (c *Client) BrowseSharedSubFolderContext(ctx context.Context, token, subFolderUUID string) (V1SharedFolderContentsResponse, error) {
 	var out V1SharedFolderContentsResponse
-	err := c.request(h, http.MethodGet, fmt.Sprintf("/api/share/folder/%s/browse/%s", token, subFolderUUID), nil, &out)
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/api/share/folder/%s/browse/%s", token, subFolderUUID), nil, &out)
 	return out, err
 }
 
 // DownloadSharedFolderFile streams a file within a shared folder tree. The
 // caller must close the returned response body.
 func (c *Client) DownloadSharedFolderFile(h http.Header, token, fileUUID string) (*http.Response, error) {
-	return c.rawRequest(h, http.MethodGet, fmt.Sprintf("/api/share/folder/%s/file/%s", token, fileUUID), nil, "")
+	return c.DownloadSharedFolderFileContext(withHeader(context.Background(), h), token, fileUUID)
+}
+
+// DownloadSharedFolderFileContext is DownloadSharedFolderFile with a context.
+func (c *Client) DownloadSharedFolderFileContext(ctx context.Context, token, fileUUID string) (*http.Response, error) {
+	return c.download(ctx, fmt.Sprintf("/api/share/folder/%s/file/%s", token, fileUUID))
 }
 
 // UploadToSharedFolder uploads a file into a shared folder (or one of its
@@ -72,29 +112,36 @@ func (c *Client) DownloadSharedFolderFile(h http.Header, token, fileUUID string)
 // the share link to have uploads enabled. Pass "" for targetFolderUUID to
 // upload into the shared folder's root.
 func (c *Client) UploadToSharedFolder(h http.Header, token, filename string, data io.Reader, targetFolderUUID string) error {
-	var buf bytes.Buffer
-	mw := multipart.NewWriter(&buf)
-
-	fw, err := mw.CreateFormFile("file", filename)
-	if err != nil {
-		return err
-	}
-	if _, err := io.Copy(fw, data); err != nil {
-		return err
-	}
-	if err := mw.Close(); err != nil {
-		return err
-	}
+	return c.UploadToSharedFolderContext(withHeader(context.Background(), h), token, filename, data, targetFolderUUID)
+}
 
+// UploadToSharedFolderContext is UploadToSharedFolder with a context.
+func (c *Client) UploadToSharedFolderContext(ctx context.Context, token, filename string, data io.Reader, targetFolderUUID string) error {
 	path := fmt.Sprintf("/api/share/folder/%s/upload", token)
 	if targetFolderUUID != "" {
 		path += "?folder=" + url.QueryEscape(targetFolderUUID)
 	}
+	return c.upload(ctx, path, nil, filename, data, nil)
+}
 
-	resp, err := c.rawRequest(h, http.MethodPost, path, &buf, mw.FormDataContentType())
+// FilterUserFolderShares lists the authenticated user's folder share links,
+// as ListUserFolderShares or ListExpiredUserFolderShares do, filtered and
+// sorted per f.
+func (c *Client) FilterUserFolderShares(h http.Header, f ShareFilter) ([]ShareFolderLink, error) {
+	return c.FilterUserFolderSharesContext(withHeader(context.Background(), h), f)
+}
+
+// FilterUserFolderSharesContext is FilterUserFolderShares with a context.
+func (c *Client) FilterUserFolderSharesContext(ctx context.Context, f ShareFilter) ([]ShareFolderLink, error) {
+	list := c.ListUserFolderSharesContext
+	if f.Expired {
+		list = c.ListExpiredUserFolderSharesContext
+	}
+	links, err := list(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() { _ = resp.Body.Close() }()
-	return nil
+	return filterShares(links, f,
+		func(l ShareFolderLink) string { return l.FolderName },
+		func(l ShareFolderLink) time.Time { return l.CreatedAt }), nil
 }
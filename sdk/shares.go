@@ -1,56 +1,156 @@
 package sdk
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 )
 
 // CreateShareLink creates a public share link for a file. Requires file
 // sharing to be enabled server-side.
 func (c *Client) CreateShareLink(h http.Header, fileID string, req CreateShareLinkRequest) (V1ShareLinkResponse, error) {
+	return c.CreateShareLinkContext(withHeader(context.Background(), h), fileID, req)
+}
+
+// CreateShareLinkContext is CreateShareLink with a context.
+func (c *Client) CreateShareLinkContext(ctx context.Context, fileID string, req CreateShareLinkRequest) (V1ShareLinkResponse, error) {
 	var out V1ShareLinkResponse
-	err := c.request(h, http.MethodPost, fmt.Sprintf("/v1/file/%s/share", fileID), req, &out)
+	err := c.request(ctx, http.MethodPost, fmt.Sprintf("/v1/file/%s/share", fileID), req, &out)
 	return out, err
 }
 
 // GetShareLinkMeta fetches metadata about a shared file (name, size, mime
 // type) without downloading it. Public endpoint.
 func (c *Client) GetShareLinkMeta(h http.Header, token string) (V1ShareLinkMetaResponse, error) {
+	return c.GetShareLinkMetaContext(withHeader(context.Background(), h), token)
+}
+
+// GetShareLinkMetaContext is GetShareLinkMeta with a context.
+func (c *Client) GetShareLinkMetaContext(ctx context.Context, token string) (V1ShareLinkMetaResponse, error) {
 	var out V1ShareLinkMetaResponse
-	err := c.request(h, http.MethodGet, fmt.Sprintf("/api/share/%s", token), nil, &out)
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/api/share/%s", token), nil, &out)
 	return out, err
 }
 
 // ListFileShares lists active share links for a single file.
 func (c *Client) ListFileShares(h http.Header, fileID string) ([]ShareLink, error) {
+	return c.ListFileSharesContext(withHeader(context.Background(), h), fileID)
+}
+
+// ListFileSharesContext is ListFileShares with a context.
+func (c *Client) ListFileSharesContext(ctx context.Context, fileID string) ([]ShareLink, error) {
 	var out []ShareLink
-	err := c.request(h, http.MethodGet, fmt.Sprintf("/v1/file/%s/shares", fileID), nil, &out)
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/file/%s/shares", fileID), nil, &out)
 	return out, err
 }
 
 // ListUserShares lists all active file share links created by the
 // authenticated user.
 func (c *Client) ListUserShares(h http.Header) ([]ShareLinkWithFileName, error) {
+	return c.ListUserSharesContext(withHeader(context.Background(), h))
+}
+
+// ListUserSharesContext is ListUserShares with a context.
+func (c *Client) ListUserSharesContext(ctx context.Context) ([]ShareLinkWithFileName, error) {
 	var out []ShareLinkWithFileName
-	err := c.request(h, http.MethodGet, "/v1/shares", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/shares", nil, &out)
 	return out, err
 }
 
 // ListExpiredUserShares lists expired file share links created by the
 // authenticated user.
 func (c *Client) ListExpiredUserShares(h http.Header) ([]ShareLinkWithFileName, error) {
+	return c.ListExpiredUserSharesContext(withHeader(context.Background(), h))
+}
+
+// ListExpiredUserSharesContext is ListExpiredUserShares with a context.
+func (c *Client) ListExpiredUserSharesContext(ctx context.Context) ([]ShareLinkWithFileName, error) {
 	var out []ShareLinkWithFileName
-	err := c.request(h, http.MethodGet, "/v1/shares/expired", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/shares/expired", nil, &out)
 	return out, err
 }
 
 // RevokeShareLink revokes a file share link.
 func (c *Client) RevokeShareLink(h http.Header, token string) error {
-	return c.request(h, http.MethodDelete, fmt.Sprintf("/v1/share/%s", token), nil, nil)
+	return c.RevokeShareLinkContext(withHeader(context.Background(), h), token)
+}
+
+// RevokeShareLinkContext is RevokeShareLink with a context.
+func (c *Client) RevokeShareLinkContext(ctx context.Context, token string) error {
+	return c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/share/%s", token), nil, nil)
 }
 
 // DownloadSharedFile streams a shared file's contents via its public share
 // token. The caller must close the returned response body.
 func (c *Client) DownloadSharedFile(h http.Header, token string) (*http.Response, error) {
-	return c.rawRequest(h, http.MethodGet, fmt.Sprintf("/api/share/%s/download", token), nil, "")
+	return c.DownloadSharedFileContext(withHeader(context.Background(), h), token)
+}
+
+// DownloadSharedFileContext is DownloadSharedFile with a context.
+func (c *Client) DownloadSharedFileContext(ctx context.Context, token string) (*http.Response, error) {
+	return c.download(ctx, fmt.Sprintf("/api/share/%s/download", token))
+}
+
+// ShareFilter narrows and orders the share links FilterUserShares and
+// FilterUserFolderShares return, as the Shares page does. The server has no
+// such options; the links are filtered and sorted after they're fetched.
+type ShareFilter struct {
+	// Expired lists expired links instead of active ones.
+	Expired bool
+	// Name keeps only the links to files or folders whose names contain
+	// it, ignoring case.
+	Name string
+	// SortBy is ShareSortByName or ShareSortByDate.
+	SortBy string
+	// Sort is the direction; SortAsc when empty.
+	Sort SortDirection
+}
+
+// filterShares applies f to links, telling their names and creation times
+// apart with name and created.
+func filterShares[T any](links []T, f ShareFilter, name func(T) string, created func(T) time.Time) []T {
+	q := strings.ToLower(f.Name)
+	out := make([]T, 0, len(links))
+	for _, l := range links {
+		if strings.Contains(strings.ToLower(name(l)), q) {
+			out = append(out, l)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b T) int {
+		var cmp int
+		if f.SortBy == ShareSortByName {
+			cmp = strings.Compare(strings.ToLower(name(a)), strings.ToLower(name(b)))
+		} else {
+			cmp = created(a).Compare(created(b))
+		}
+		if f.Sort == SortDesc {
+			return -cmp
+		}
+		return cmp
+	})
+	return out
+}
+
+// FilterUserShares lists the authenticated user's file share links, as
+// ListUserShares or ListExpiredUserShares do, filtered and sorted per f.
+func (c *Client) FilterUserShares(h http.Header, f ShareFilter) ([]ShareLinkWithFileName, error) {
+	return c.FilterUserSharesContext(withHeader(context.Background(), h), f)
+}
+
+// FilterUserSharesContext is FilterUserShares with a context.
+func (c *Client) FilterUserSharesContext(ctx context.Context, f ShareFilter) ([]ShareLinkWithFileName, error) {
+	list := c.ListUserSharesContext
+	if f.Expired {
+		list = c.ListExpiredUserSharesContext
+	}
+	links, err := list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterShares(links, f,
+		func(l ShareLinkWithFileName) string { return l.FileName },
+		func(l ShareLinkWithFileName) time.Time { return l.CreatedAt }), nil
 }
@@ -1,5 +1,10 @@
 package sdk
 
+import (
+	"net/url"
+	"strconv"
+)
+
 // SortDirection controls list ordering for endpoints that accept a `sort`
 // query parameter (e.g. GET /v1/folder/list). Values are lowercase and are
 // valid directly as SQL ORDER BY direction keywords (case-insensitive).
@@ -9,3 +14,61 @@ const (
 	SortAsc  SortDirection = "asc"
 	SortDesc SortDirection = "desc"
 )
+
+// Values for ListOptions.SortBy when listing a folder. Folders are sorted by
+// name when it's empty.
+const (
+	FolderSortByName = "name"
+	FolderSortBySize = "size"
+	FolderSortByDate = "date"
+)
+
+// Values for ListOptions.SortBy when listing the trash. The trash is sorted
+// by when things were trashed when it's empty.
+const (
+	TrashSortByName = "name"
+	TrashSortBySize = "size"
+)
+
+// Values for ShareFilter.SortBy. Share links are sorted by when they were
+// created when it's empty.
+const (
+	ShareSortByName = "name"
+	ShareSortByDate = "date"
+)
+
+// ListOptions selects a page of a folder or trash listing and how it's
+// ordered. The zero value asks for the server's defaults: the first page,
+// in ascending order.
+type ListOptions struct {
+	// Page and Limit control pagination; 0 uses the server's default.
+	Page  int
+	Limit int
+	Sort  SortDirection
+	// SortBy is one of the FolderSortBy or TrashSortBy values.
+	SortBy string
+	// Type, if set, lists only FolderItemTypeFolder or FolderItemTypeFile
+	// items. Only ListFolderContents takes it.
+	Type string
+}
+
+// query encodes o as a query string, leaving out what's unset.
+func (o ListOptions) query() string {
+	q := url.Values{}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Sort != "" {
+		q.Set("sort", string(o.Sort))
+	}
+	if o.SortBy != "" {
+		q.Set("sortBy", o.SortBy)
+	}
+	if o.Type != "" {
+		q.Set("type", o.Type)
+	}
+	return q.Encode()
+}
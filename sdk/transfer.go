@@ -0,0 +1,128 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// ProgressFunc is told how many bytes of a transfer have gone by so far,
+// and how many there are in all, or -1 if that isn't known.
+type ProgressFunc func(done, total int64)
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes the uploads and downloads
+// started with it report their progress to fn. An upload counts the bytes
+// of the file read from the caller; a download counts the bytes of the
+// response body as the caller reads them.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFrom(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressReader reports the bytes read through it.
+type progressReader struct {
+	r     io.Reader
+	done  int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.done += int64(n)
+		p.fn(p.done, p.total)
+	}
+	return n, err
+}
+
+// progressBody is a response body that reports the bytes read from it.
+type progressBody struct {
+	progressReader
+	io.Closer
+}
+
+// size returns how many bytes r holds, if it can tell: for a
+// *bytes.Reader, *strings.Reader or *bytes.Buffer, or an *os.File.
+// Otherwise it's -1.
+func size(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Stat() (fs.FileInfo, error) }:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	}
+	return -1
+}
+
+// download GETs path, returning the raw response, whose body reports
+// progress if ctx asks for it.
+func (c *Client) download(ctx context.Context, path string) (*http.Response, error) {
+	resp, err := c.rawRequest(ctx, http.MethodGet, path, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if fn := progressFrom(ctx); fn != nil {
+		resp.Body = &progressBody{progressReader{r: resp.Body, total: resp.ContentLength, fn: fn}, resp.Body}
+	}
+	return resp, nil
+}
+
+// upload POSTs a multipart form to path with fields and then data as the
+// file part named filename, decoding the JSON response into out when it's
+// non-nil. The form is streamed rather than built in memory first, so
+// uploads aren't retried.
+func (c *Client) upload(ctx context.Context, path string, fields url.Values, filename string, data io.Reader, out any) error {
+	if fn := progressFrom(ctx); fn != nil {
+		data = &progressReader{r: data, total: size(data), fn: fn}
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		_ = pw.CloseWithError(writeForm(mw, fields, filename, data))
+	}()
+
+	resp, err := c.rawRequest(ctx, http.MethodPost, path, pr, mw.FormDataContentType())
+	// The server may answer before reading the whole form, e.g. when the
+	// file is over quota; stop writing it.
+	_ = pr.Close()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func writeForm(mw *multipart.Writer, fields url.Values, filename string, data io.Reader) error {
+	for k, vs := range fields {
+		for _, v := range vs {
+			if err := mw.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, data); err != nil {
+		return err
+	}
+	return mw.Close()
+}
@@ -1,57 +1,282 @@
 package sdk
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 // GetProfile returns the authenticated user's own profile.
 func (c *Client) GetProfile(h http.Header) (User, error) {
+	return c.GetProfileContext(withHeader(context.Background(), h))
+}
+
+// GetProfileContext is GetProfile with a context.
+func (c *Client) GetProfileContext(ctx context.Context) (User, error) {
 	var out User
-	err := c.request(h, http.MethodGet, "/v1/user/profile", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/user/profile", nil, &out)
 	return out, err
 }
 
 // GetUsers lists all users. Requires an admin caller.
 func (c *Client) GetUsers(h http.Header) ([]User, error) {
+	return c.GetUsersContext(withHeader(context.Background(), h))
+}
+
+// GetUsersContext is GetUsers with a context.
+func (c *Client) GetUsersContext(ctx context.Context) ([]User, error) {
 	var out []User
-	err := c.request(h, http.MethodGet, "/v1/users", nil, &out)
+	err := c.request(ctx, http.MethodGet, "/v1/users", nil, &out)
 	return out, err
 }
 
-// CreateUser creates a new user account. Requires an admin caller. Returns
-// the full, refreshed user list.
-func (c *Client) CreateUser(h http.Header, req CreateUserRequest) ([]User, error) {
-	var out []User
-	err := c.request(h, http.MethodPost, "/v1/user", req, &out)
+// CreateUser creates a new user account and returns it. Requires an admin
+// caller.
+func (c *Client) CreateUser(h http.Header, req CreateUserRequest) (User, error) {
+	return c.CreateUserContext(withHeader(context.Background(), h), req)
+}
+
+// CreateUserContext is CreateUser with a context.
+func (c *Client) CreateUserContext(ctx context.Context, req CreateUserRequest) (User, error) {
+	var out User
+	err := c.request(ctx, http.MethodPost, "/v1/user", req, &out)
 	return out, err
 }
 
 // UpdateProfile updates a user's profile. req.ID selects the target user;
 // non-admin callers may only update themselves.
 func (c *Client) UpdateProfile(h http.Header, req UpdateProfileRequest) (User, error) {
+	return c.UpdateProfileContext(withHeader(context.Background(), h), req)
+}
+
+// UpdateProfileContext is UpdateProfile with a context.
+func (c *Client) UpdateProfileContext(ctx context.Context, req UpdateProfileRequest) (User, error) {
 	var out User
-	err := c.request(h, http.MethodPut, "/v1/user/profile", req, &out)
+	err := c.request(ctx, http.MethodPut, "/v1/user/profile", req, &out)
 	return out, err
 }
 
 // UpdateProfileByID is the PATCH /v1/user/:userID variant of UpdateProfile.
+// req.ID defaults to userID when it's unset.
 func (c *Client) UpdateProfileByID(h http.Header, userID string, req UpdateProfileRequest) (User, error) {
+	return c.UpdateProfileByIDContext(withHeader(context.Background(), h), userID, req)
+}
+
+// UpdateProfileByIDContext is UpdateProfileByID with a context.
+func (c *Client) UpdateProfileByIDContext(ctx context.Context, userID string, req UpdateProfileRequest) (User, error) {
+	if req.ID == 0 {
+		req.ID, _ = strconv.ParseInt(userID, 10, 64)
+	}
 	var out User
-	err := c.request(h, http.MethodPatch, fmt.Sprintf("/v1/user/%s", userID), req, &out)
+	err := c.request(ctx, http.MethodPatch, fmt.Sprintf("/v1/user/%s", userID), req, &out)
 	return out, err
 }
 
-// UpdatePassword changes the authenticated user's own password.
-func (c *Client) UpdatePassword(h http.Header, newPassword string) (User, error) {
-	req := UpdatePasswordRequest{Password: newPassword}
+// UpdatePassword changes the authenticated user's own password, given
+// their current one. Every other session of theirs is revoked.
+func (c *Client) UpdatePassword(h http.Header, currentPassword, newPassword string) (User, error) {
+	return c.UpdatePasswordContext(withHeader(context.Background(), h), currentPassword, newPassword)
+}
+
+// UpdatePasswordContext is UpdatePassword with a context.
+func (c *Client) UpdatePasswordContext(ctx context.Context, currentPassword, newPassword string) (User, error) {
+	req := UpdatePasswordRequest{Password: newPassword, CurrentPassword: currentPassword}
 	var out User
-	err := c.request(h, http.MethodPatch, "/v1/user/password", req, &out)
+	err := c.request(ctx, http.MethodPatch, "/v1/user/password", req, &out)
 	return out, err
 }
 
 // AdminSendPasswordReset emails a password-reset link to the target user.
 // Requires an admin caller.
 func (c *Client) AdminSendPasswordReset(h http.Header, userID string) error {
-	return c.request(h, http.MethodPost, fmt.Sprintf("/v1/user/%s/send-reset-email", userID), nil, nil)
+	return c.AdminSendPasswordResetContext(withHeader(context.Background(), h), userID)
+}
+
+// AdminSendPasswordResetContext is AdminSendPasswordReset with a context.
+func (c *Client) AdminSendPasswordResetContext(ctx context.Context, userID string) error {
+	return c.request(ctx, http.MethodPost, fmt.Sprintf("/v1/user/%s/send-reset-email", userID), nil, nil)
+}
+
+// ListEmailTemplates lists the email templates that can be previewed.
+// Requires an admin caller.
+func (c *Client) ListEmailTemplates(h http.Header) (V1EmailTemplatesResponse, error) {
+	return c.ListEmailTemplatesContext(withHeader(context.Background(), h))
+}
+
+// ListEmailTemplatesContext is ListEmailTemplates with a context.
+func (c *Client) ListEmailTemplatesContext(ctx context.Context) (V1EmailTemplatesResponse, error) {
+	var out V1EmailTemplatesResponse
+	err := c.request(ctx, http.MethodGet, "/v1/admin/email-templates", nil, &out)
+	return out, err
+}
+
+// PreviewEmailTemplate renders an email template with sample data, using the
+// server's active (possibly overridden) templates. Requires an admin caller.
+func (c *Client) PreviewEmailTemplate(h http.Header, name string) (EmailTemplatePreview, error) {
+	return c.PreviewEmailTemplateContext(withHeader(context.Background(), h), name)
+}
+
+// PreviewEmailTemplateContext is PreviewEmailTemplate with a context.
+func (c *Client) PreviewEmailTemplateContext(ctx context.Context, name string) (EmailTemplatePreview, error) {
+	var out EmailTemplatePreview
+	err := c.request(ctx, http.MethodGet, "/v1/admin/email-templates/"+url.PathEscape(name)+"/preview", nil, &out)
+	return out, err
+}
+
+// RenderEmailTemplate renders an email template with sample data, as
+// PreviewEmailTemplate does, and returns just its html or text body, per
+// format. Requires an admin caller.
+func (c *Client) RenderEmailTemplate(h http.Header, name, format string) (string, error) {
+	return c.RenderEmailTemplateContext(withHeader(context.Background(), h), name, format)
+}
+
+// RenderEmailTemplateContext is RenderEmailTemplate with a context.
+func (c *Client) RenderEmailTemplateContext(ctx context.Context, name, format string) (string, error) {
+	path := "/v1/admin/email-templates/" + url.PathEscape(name) + "/preview?format=" + url.QueryEscape(format)
+	resp, err := c.rawRequest(ctx, http.MethodGet, path, nil, "")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, err := io.ReadAll(resp.Body)
+	return string(b), err
+}
+
+// ListOutboxEmails lists queued, sent and dead outbound emails, newest
+// first. status may be empty for all statuses. Requires an admin caller.
+func (c *Client) ListOutboxEmails(h http.Header, status string, page, limit int) (V1OutboxEmailsResponse, error) {
+	return c.ListOutboxEmailsContext(withHeader(context.Background(), h), status, page, limit)
+}
+
+// ListOutboxEmailsContext is ListOutboxEmails with a context.
+func (c *Client) ListOutboxEmailsContext(ctx context.Context, status string, page, limit int) (V1OutboxEmailsResponse, error) {
+	path := "/v1/admin/emails?" + paginationQuery(page, limit)
+	if status != "" {
+		path += "&status=" + url.QueryEscape(status)
+	}
+
+	var out V1OutboxEmailsResponse
+	err := c.request(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+// GetOutboxEmail returns a single outbound email. Requires an admin caller.
+func (c *Client) GetOutboxEmail(h http.Header, emailID int64) (OutboxEmail, error) {
+	return c.GetOutboxEmailContext(withHeader(context.Background(), h), emailID)
+}
+
+// GetOutboxEmailContext is GetOutboxEmail with a context.
+func (c *Client) GetOutboxEmailContext(ctx context.Context, emailID int64) (OutboxEmail, error) {
+	var out OutboxEmail
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/admin/emails/%d", emailID), nil, &out)
+	return out, err
+}
+
+// RetryOutboxEmail sends an email waiting for its next attempt straight
+// away. Dead emails can't be retried. Requires an admin caller.
+func (c *Client) RetryOutboxEmail(h http.Header, emailID int64) error {
+	return c.RetryOutboxEmailContext(withHeader(context.Background(), h), emailID)
+}
+
+// RetryOutboxEmailContext is RetryOutboxEmail with a context.
+func (c *Client) RetryOutboxEmailContext(ctx context.Context, emailID int64) error {
+	return c.request(ctx, http.MethodPost, fmt.Sprintf("/v1/admin/emails/%d/retry", emailID), nil, nil)
+}
+
+// DeleteAccount deletes the authenticated user's own account. The session
+// used for the call is revoked along with all others.
+func (c *Client) DeleteAccount(h http.Header, req DeleteAccountRequest) (AccountDeletionSummary, error) {
+	return c.DeleteAccountContext(withHeader(context.Background(), h), req)
+}
+
+// DeleteAccountContext is DeleteAccount with a context.
+func (c *Client) DeleteAccountContext(ctx context.Context, req DeleteAccountRequest) (AccountDeletionSummary, error) {
+	var out AccountDeletionSummary
+	err := c.request(ctx, http.MethodDelete, "/v1/user/profile", req, &out)
+	return out, err
+}
+
+// AdminDeleteAccount deletes another user's account. Requires an admin
+// caller.
+func (c *Client) AdminDeleteAccount(h http.Header, userID string, req DeleteAccountRequest) (AccountDeletionSummary, error) {
+	return c.AdminDeleteAccountContext(withHeader(context.Background(), h), userID, req)
+}
+
+// AdminDeleteAccountContext is AdminDeleteAccount with a context.
+func (c *Client) AdminDeleteAccountContext(ctx context.Context, userID string, req DeleteAccountRequest) (AccountDeletionSummary, error) {
+	var out AccountDeletionSummary
+	err := c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/user/%s", userID), req, &out)
+	return out, err
+}
+
+// ExportAccount streams a zip of the authenticated user's drive and a
+// manifest.json of their metadata and shares. The caller must close the
+// returned response body.
+func (c *Client) ExportAccount(h http.Header) (*http.Response, error) {
+	return c.ExportAccountContext(withHeader(context.Background(), h))
+}
+
+// ExportAccountContext is ExportAccount with a context.
+func (c *Client) ExportAccountContext(ctx context.Context) (*http.Response, error) {
+	return c.download(ctx, "/v1/user/export")
+}
+
+// AdminExportAccount is ExportAccount for another user. Requires an admin
+// caller.
+func (c *Client) AdminExportAccount(h http.Header, userID string) (*http.Response, error) {
+	return c.AdminExportAccountContext(withHeader(context.Background(), h), userID)
+}
+
+// AdminExportAccountContext is AdminExportAccount with a context.
+func (c *Client) AdminExportAccountContext(ctx context.Context, userID string) (*http.Response, error) {
+	return c.download(ctx, fmt.Sprintf("/v1/user/%s/export", userID))
+}
+
+// GetQuotaStatus returns how much of their quota the caller has used and
+// the limit their uploads are held to.
+func (c *Client) GetQuotaStatus(h http.Header) (QuotaStatus, error) {
+	return c.GetQuotaStatusContext(withHeader(context.Background(), h))
+}
+
+// GetQuotaStatusContext is GetQuotaStatus with a context.
+func (c *Client) GetQuotaStatusContext(ctx context.Context) (QuotaStatus, error) {
+	var out QuotaStatus
+	err := c.request(ctx, http.MethodGet, "/v1/user/quota", nil, &out)
+	return out, err
+}
+
+// ListNotifications returns the caller's latest notifications, newest
+// first, and how many are unread.
+func (c *Client) ListNotifications(h http.Header) (V1NotificationsResponse, error) {
+	return c.ListNotificationsContext(withHeader(context.Background(), h))
+}
+
+// ListNotificationsContext is ListNotifications with a context.
+func (c *Client) ListNotificationsContext(ctx context.Context) (V1NotificationsResponse, error) {
+	var out V1NotificationsResponse
+	err := c.request(ctx, http.MethodGet, "/v1/user/notifications", nil, &out)
+	return out, err
+}
+
+// MarkNotificationRead marks one of the caller's notifications read.
+func (c *Client) MarkNotificationRead(h http.Header, notificationID int64) error {
+	return c.MarkNotificationReadContext(withHeader(context.Background(), h), notificationID)
+}
+
+// MarkNotificationReadContext is MarkNotificationRead with a context.
+func (c *Client) MarkNotificationReadContext(ctx context.Context, notificationID int64) error {
+	return c.request(ctx, http.MethodPost, fmt.Sprintf("/v1/user/notifications/%d/read", notificationID), nil, nil)
+}
+
+// MarkAllNotificationsRead marks all of the caller's notifications read.
+func (c *Client) MarkAllNotificationsRead(h http.Header) error {
+	return c.MarkAllNotificationsReadContext(withHeader(context.Background(), h))
+}
+
+// MarkAllNotificationsReadContext is MarkAllNotificationsRead with a context.
+func (c *Client) MarkAllNotificationsReadContext(ctx context.Context) error {
+	return c.request(ctx, http.MethodPost, "/v1/user/notifications/read", nil, nil)
 }
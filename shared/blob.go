@@ -2,6 +2,7 @@ package shared
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/spf13/afero"
 )
@@ -28,3 +29,39 @@ func EnsureBlobDir(fs afero.Fs, uuid string) error {
 	}
 	return nil
 }
+
+// jobArtifactDir holds files produced by background jobs, such as built
+// archives. Blob shard directories are two hex characters, so it can't
+// collide with them.
+const jobArtifactDir = "/jobs"
+
+// JobArtifactPath returns where the file produced by background job jobID
+// is kept until the job is cleaned up.
+func JobArtifactPath(jobID int64) string {
+	return jobArtifactDir + "/" + strconv.FormatInt(jobID, 10)
+}
+
+// EnsureJobArtifactDir creates the directory JobArtifactPath lives in, if
+// it doesn't already exist.
+func EnsureJobArtifactDir(fs afero.Fs) error {
+	return fs.MkdirAll(jobArtifactDir, os.ModePerm)
+}
+
+// SniffWriter keeps the first 512 bytes written to it for
+// http.DetectContentType, so a blob's type can be sniffed while it's
+// copied.
+type SniffWriter struct {
+	buf []byte
+}
+
+func (w *SniffWriter) Write(p []byte) (int, error) {
+	if room := 512 - len(w.buf); room > 0 {
+		w.buf = append(w.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns what has been kept.
+func (w *SniffWriter) Bytes() []byte {
+	return w.buf
+}
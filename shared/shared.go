@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/mail"
-	"os"
 	"strconv"
 	"time"
 )
@@ -12,71 +11,16 @@ import (
 type cookieStr string
 
 const (
-	SESSIONCOOKIENAME  cookieStr = "session_id"
-	USERCOOKIENAME     cookieStr = "user_id"
-	USERCOOKIEVALUE    cookieStr = "test"
-	ROOTFOLDERID                 = "c32af1cc-aba9-4878-a305-5006dc7a5b76"
-	DEFAULTMAXFILESIZE int64     = 209715200
+	SESSIONCOOKIENAME cookieStr = "session_id"
+	USERCOOKIENAME    cookieStr = "user_id"
+	USERCOOKIEVALUE   cookieStr = "test"
+	ROOTFOLDERID                = "c32af1cc-aba9-4878-a305-5006dc7a5b76"
 
 	DEFAULTPAGE      = 1
 	DEFAULTPAGELIMIT = 50
 	MAXPAGELIMIT     = 200
 )
 
-func GetEnvBool(key string, defaultVal bool) bool {
-	envKey := os.Getenv(key)
-	if envKey == "" {
-		return defaultVal
-	}
-	val, err := strconv.ParseBool(envKey)
-	if err != nil {
-		return defaultVal
-	}
-	return val
-}
-
-func GetEnvInt64(key string, defaultVal int64) int64 {
-	envKey := os.Getenv(key)
-
-	if envKey == "" {
-		return defaultVal
-	}
-
-	castedKey, err := strconv.ParseInt(envKey, 10, 64)
-	if err != nil {
-		fmt.Printf("error parsing int: %s", err.Error())
-		return defaultVal
-	}
-	return castedKey
-}
-
-// GetEnvDuration parses key as a Go duration string (e.g. "5m", "720h"),
-// falling back to defaultVal (also parsed as a duration) if key is unset or
-// invalid.
-func GetEnvDuration(key string, defaultVal string) time.Duration {
-	envKey := os.Getenv(key)
-	if envKey == "" {
-		envKey = defaultVal
-	}
-
-	dur, err := time.ParseDuration(envKey)
-	if err != nil {
-		fmt.Printf("error parsing duration: %s", err.Error())
-		dur, _ = time.ParseDuration(defaultVal)
-	}
-	return dur
-}
-
-func GetEnv(key string, defaultVal string) string {
-	envKey := os.Getenv(key)
-
-	if envKey == "" {
-		return defaultVal
-	}
-
-	return envKey
-}
-
 func IsValidEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil
@@ -122,3 +66,36 @@ func GetSessionIDFromContext(ctx context.Context) (string, error) {
 
 	return fmt.Sprint(val), nil
 }
+
+// RetryBackoff is the delay before retrying after the given (1-based)
+// attempt: base, 2*base, 4*base, ... capped at max.
+func RetryBackoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// FormatBytes renders n in binary units for people, e.g. "1.5 GiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -1,6 +1,9 @@
 package shared
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestParsePagination(t *testing.T) {
 	tests := []struct {
@@ -84,3 +87,46 @@ func TestParsePagination(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryBackoff(t *testing.T) {
+	base := 30 * time.Second
+	max := time.Hour
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: 30 * time.Second},
+		{attempt: 1, want: 30 * time.Second},
+		{attempt: 2, want: time.Minute},
+		{attempt: 3, want: 2 * time.Minute},
+		{attempt: 7, want: 32 * time.Minute},
+		{attempt: 8, want: time.Hour},
+		{attempt: 100, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := RetryBackoff(tt.attempt, base, max); got != tt.want {
+			t.Errorf("RetryBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{80 << 30, "80.0 GiB"},
+		{3 << 40, "3.0 TiB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.n); got != tt.want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -11,11 +11,9 @@ const userDataDefault: User = {
     quota: 0,
     spaceUsed: 0,
     createdAt: '',
-    deletedAt: null,
     email: '',
-    firstName: null,
-    lastName: null,
-    updatedAt: null,
+    firstName: '',
+    lastName: '',
 }
 
 export const useUsersStore = defineStore('users', () => {
@@ -118,11 +116,19 @@ export const useUsersStore = defineStore('users', () => {
 
         return response;
     }
+    // updateUser PATCHes the user req.id names (the logged in user when
+    // it's unset); the server rejects a path id that doesn't match the body.
     async function updateUser(req: Partial<User>) {
+        const id = req.id ?? userData.value.data.id;
+        const own = `${id}` === `${userData.value.data.id}`;
+
         userData.value.loading = true;
-        const response = await api({ url: `v1/user/${userData.value.data.id}`, method: 'PATCH', json: req})
+        const response = await api({ url: `v1/user/${id}`, method: 'PATCH', json: req})
         userData.value.loading = false;
 
+        if (!own) {
+            return response;
+        }
         if (response.ok) {
             userData.value.data = response.body as User;
         } else {
@@ -0,0 +1,609 @@
+// Code generated by cmd/openapi-gen from openapi/openapi.yaml. DO NOT EDIT.
+
+export interface File {
+  id: number;
+  uuid: string;
+  name: string;
+  extension: string;
+  mimeType: string;
+  file_size: number;
+  checksum?: string;
+  parent: string;
+  created_by: number;
+  created_at: string;
+  deleted_at?: string | null;
+}
+
+export interface Folder {
+  id: number;
+  uuid: string;
+  name: string;
+  parent_id: number;
+  owner_id: number;
+  deleted_at?: string | null;
+}
+
+// FolderQuota caps the total size of the files in a folder's subtree,
+// whoever uploaded them. Used doesn't count trashed files.
+export interface FolderQuota {
+  folderId: string;
+  name: string;
+  quota: number;
+  used: number;
+}
+
+// Group is a set of users whose storage is pooled under one quota. Used is
+// the members' combined usage. Once it reaches SoftQuota the members are
+// warned by email; uploads are refused once it would pass Quota. Either
+// quota is 0 when unset.
+export interface Group {
+  id: number;
+  name: string;
+  quota: number;
+  softQuota: number;
+  used: number;
+  members: number;
+  createdAt: string;
+}
+
+// QuotaStatus is how much of their quota a user has used. Limit is what
+// uploads are actually held to: Quota, or during a grace period, Quota
+// plus the allowed overage. GraceEndsAt is set while they're over their
+// quota and a grace period applies.
+export interface QuotaStatus {
+  quota: number;
+  used: number;
+  limit: number;
+  warnedPercent: number;
+  overQuotaSince?: string | null;
+  graceEndsAt?: string | null;
+}
+
+// Notification is an in-app message for a user. ReadAt is set once they've
+// marked it read.
+export interface Notification {
+  id: number;
+  kind: string;
+  message: string;
+  readAt?: string | null;
+  createdAt: string;
+}
+
+// V1NotificationsResponse lists a user's notifications, newest first,
+// along with how many are unread.
+export interface V1NotificationsResponse {
+  notifications: Notification[];
+  unread: number;
+}
+
+// FolderItem is a single row from a unified folder+file listing query —
+// used wherever folders and files must be paginated together as one
+// deterministically-ordered set (drive listings, trash listings) instead of
+// as two separately-paginated result sets. Type is either "folder" or
+// "file"; fields that don't apply to a folder row (Extension, MimeType,
+// Checksum, CreatedBy) are simply left zero-valued.
+export interface FolderItem {
+  type: 'folder' | 'file';
+  id: number;
+  uuid: string;
+  name: string;
+  extension?: string;
+  mimeType?: string;
+  file_size: number;
+  checksum?: string;
+  parent_id?: number;
+  owner_id?: number;
+  created_by?: number;
+  created_at: string;
+  deleted_at?: string | null;
+}
+
+export interface User {
+  id: number;
+  email: string;
+  firstName: string;
+  lastName: string;
+  canLogin: boolean;
+  isAdmin: boolean;
+  quota: number;
+  spaceUsed: number;
+  createdAt: string;
+}
+
+export interface ShareLink {
+  id: number;
+  token: string;
+  file_id: string;
+  created_by: number;
+  expires_at: string | null;
+  created_at: string;
+  require_login: boolean;
+  last_accessed: string | null;
+}
+
+export interface ShareLinkWithFileName {
+  id: number;
+  token: string;
+  file_id: string;
+  file_name: string;
+  created_by: number;
+  expires_at: string | null;
+  created_at: string;
+  require_login: boolean;
+  last_accessed: string | null;
+}
+
+export interface ShareFolderLink {
+  id: number;
+  token: string;
+  folder_uuid: string;
+  folder_name: string;
+  created_by: number;
+  expires_at: string | null;
+  created_at: string;
+  require_login: boolean;
+  allow_upload: boolean;
+  max_file_size: number;
+  last_accessed: string | null;
+}
+
+export interface SessionInfo {
+  id: number;
+  createdAt: string;
+  expiresAt: string;
+  userAgent: string;
+  ipAddress: string;
+  isCurrent: boolean;
+}
+
+// UserIdentity is an external (SSO) identity linked to an Avenue account.
+export interface UserIdentity {
+  id: number;
+  userId: number;
+  provider: string;
+  subject: string;
+  email: string;
+  createdAt: string;
+}
+
+// LoginProvider is an SSO provider advertised on the login page. LoginURL
+// is where the browser should be sent to start signing in with it.
+export interface LoginProvider {
+  id: string;
+  name: string;
+  login_url: string;
+}
+
+export interface Breadcrumb {
+  label: string;
+  folder_id: string;
+}
+
+export interface V1LoginResponse {
+  Message: string;
+  'User-Id': number;
+  session_id: string;
+  user_data: User;
+}
+
+export interface V1LoginMetaResponse {
+  registration_enabled: string;
+  password_login_enabled: boolean;
+  providers: LoginProvider[];
+}
+
+// V1IdentityLinkResponse is returned when starting to link a new SSO
+// identity: the caller should send the browser to URL to finish linking.
+export interface V1IdentityLinkResponse {
+  url: string;
+}
+
+export interface V1DashboardResponse {
+  maxFileSize: number;
+  fileSharingEnabled: boolean;
+  folderSharingEnabled: boolean;
+}
+
+// V1AdminConfigResponse is the server's effective configuration. Config is
+// shaped like the config file, with every secret that is set replaced by
+// "REDACTED"; File is the config file that was loaded, if any.
+export interface V1AdminConfigResponse {
+  file: string;
+  config: Record<string, unknown>;
+}
+
+// V1FolderContentsResponse lists the contents of a folder as a single,
+// unified, already-paginated Items list (folders and files interleaved by
+// the requested sort), so the caller doesn't need to reconcile two
+// separately-paginated result sets to know what page it's on.
+export interface V1FolderContentsResponse {
+  items: FolderItem[];
+  breadcrumbs: Breadcrumb[];
+  page: number;
+  limit: number;
+  total: number;
+  // Quotas are the quotas on the folder and its ancestors that limit
+  // what can be added to it, nearest first.
+  quotas: FolderQuota[];
+}
+
+// V1TrashResponse lists the top-level trashed items for a user, i.e. the
+// files and folders the user explicitly trashed, as a single unified,
+// already-paginated Items list. Items that are only in the trash because an
+// ancestor folder was trashed are not listed separately — they come back
+// along with their parent on restore.
+export interface V1TrashResponse {
+  items: FolderItem[];
+  // RetentionDays is how many days an item sits in the trash before the
+  // sweeper permanently deletes it.
+  retentionDays: number;
+  page: number;
+  limit: number;
+  total: number;
+}
+
+// V1RestoreResponse is returned by the file/folder restore endpoints
+// (single and bulk) so the UI can refresh its trash pagination totals
+// without re-fetching the whole list.
+export interface V1RestoreResponse {
+  message: string;
+  total: number;
+}
+
+export interface V1ShareLinkResponse {
+  token: string;
+  expires_at: string | null;
+  created_at: string;
+}
+
+export interface V1ShareLinkMetaResponse {
+  file_name: string;
+  file_size: number;
+  mime_type: string;
+  expires_at: string | null;
+  token: string;
+}
+
+export interface V1SharedFolderContentsResponse {
+  folder_name: string;
+  folder_uuid: string;
+  files: File[];
+  folders: Folder[];
+  allow_upload: boolean;
+  max_file_size: number;
+}
+
+// MessageResponse is the generic {message, error} envelope used by several
+// endpoints (handlers.Response).
+export interface MessageResponse {
+  message: string;
+  error: string;
+  // Code says what kind of error this is, where clients may want to
+  // tell it apart from others with the same status: "quota_exceeded"
+  // when an upload or copy would go over a quota.
+  code?: 'quota_exceeded';
+}
+
+// V1EmailTemplatesResponse lists the email templates an admin can preview.
+export interface V1EmailTemplatesResponse {
+  templates: string[];
+}
+
+// EmailTemplatePreview is an email template rendered with sample data.
+export interface EmailTemplatePreview {
+  name: string;
+  subject: string;
+  html: string;
+  text: string;
+}
+
+// OutboxEmail is a message in the outbound email queue. Its bodies are
+// never returned, since they can hold password reset links.
+export interface OutboxEmail {
+  id: number;
+  dedupKey?: string;
+  to: string;
+  subject: string;
+  status: string;
+  attempts: number;
+  lastError?: string;
+  nextAttemptAt: string;
+  createdAt: string;
+  sentAt?: string | null;
+}
+
+// V1OutboxEmailsResponse is a page of the outbound email queue.
+export interface V1OutboxEmailsResponse {
+  emails: OutboxEmail[];
+  page: number;
+  limit: number;
+  total: number;
+}
+
+// AccountDeletionSummary reports what deleting an account removed. In trash
+// mode Files and Folders are the items moved to the trash; in purge mode
+// they're the items permanently deleted.
+export interface AccountDeletionSummary {
+  userId: number;
+  mode: string;
+  files: number;
+  folders: number;
+  sharesRevoked: number;
+}
+
+// ExportFolder is a folder in an account export. Path is slash-separated
+// from the drive root and matches the folder's directory under files/ in
+// the archive.
+export interface ExportFolder {
+  uuid: string;
+  name: string;
+  path: string;
+  createdAt: string;
+}
+
+// ExportFile is a file in an account export. Folder is the containing
+// folder's path ("" for the root) and ArchivePath the entry name the file
+// was written under.
+export interface ExportFile {
+  uuid: string;
+  name: string;
+  folder: string;
+  archivePath: string;
+  mimeType: string;
+  fileSize: number;
+  checksum?: string;
+  createdBy: number;
+  createdAt: string;
+}
+
+// ExportError records a file that couldn't be written to an account export
+// or download archive.
+export interface ExportError {
+  uuid: string;
+  path: string;
+  error: string;
+}
+
+// ExportManifest is written as manifest.json at the end of an account
+// export archive. Shares include both active and expired links.
+export interface ExportManifest {
+  version: number;
+  exportedAt: string;
+  user: User;
+  folders: ExportFolder[];
+  files: ExportFile[];
+  fileShares: ShareLinkWithFileName[];
+  folderShares: ShareFolderLink[];
+  errors: ExportError[];
+}
+
+// ArchiveEntry is a file written to a download archive.
+export interface ArchiveEntry {
+  path: string;
+  uuid: string;
+  size: number;
+  modified: string;
+}
+
+// ArchiveManifest is written as manifest.json at the end of every download
+// archive. Files that couldn't be added are listed under Errors (and in
+// errors.txt) rather than silently left out.
+export interface ArchiveManifest {
+  createdAt: string;
+  entries: ArchiveEntry[];
+  errors: ExportError[];
+}
+
+// Job is a unit of work on the background job queue. ProgressDone and
+// ProgressTotal are in whatever unit suits the kind (files, items, bytes)
+// and are refreshed every few seconds while it runs. Result holds the
+// kind's result once it's done. UserID is 0 for system jobs, which only
+// admins can see.
+export interface Job {
+  id: number;
+  userId?: number;
+  kind: string;
+  status: string;
+  progressDone: number;
+  progressTotal: number;
+  attempts: number;
+  maxAttempts: number;
+  cancelRequested?: boolean;
+  error?: string;
+  payload?: unknown;
+  result?: unknown;
+  runAt: string;
+  createdAt: string;
+  startedAt?: string | null;
+  finishedAt?: string | null;
+}
+
+// V1JobsResponse is a page of background jobs.
+export interface V1JobsResponse {
+  jobs: Job[];
+  page: number;
+  limit: number;
+  total: number;
+}
+
+// PurgeResult is the result of a trash.empty or folder.purge job.
+export interface PurgeResult {
+  files: number;
+  folders: number;
+  bytes: number;
+}
+
+// ArchiveResult is the result of a files.archive job. The archive is
+// fetched with DownloadJobArchive until the job is cleaned up.
+export interface ArchiveResult {
+  name: string;
+  contentType: string;
+  size: number;
+  entries: number;
+  errors: number;
+}
+
+// CopyResult is the result of a files.copy job.
+export interface CopyResult {
+  files: number;
+  bytes: number;
+}
+
+// ExtractResult is the result of a files.extract job. Symlinks and other
+// special entries are never extracted and are counted in Skipped.
+export interface ExtractResult {
+  entries: number;
+  skipped: number;
+  bytes: number;
+}
+
+// ImportRequest imports Path, a directory under the server's import_dir,
+// into UserID's drive: into Folder, or the root of their drive if it's
+// empty. Link hard-links blobs to the source files instead of copying
+// them where the filesystem allows. IgnoreQuota imports even past the
+// user's quota.
+export interface ImportRequest {
+  userId: number;
+  path: string;
+  folder?: string;
+  link?: boolean;
+  ignoreQuota?: boolean;
+}
+
+// ImportResult is the result of a files.import job. Skipped counts files
+// an earlier run of the same import already created; Failed counts those
+// that couldn't be read or stored, which running the import again retries.
+export interface ImportResult {
+  folders: number;
+  files: number;
+  skipped: number;
+  failed: number;
+  bytes: number;
+}
+
+export interface LoginRequest {
+  email: string;
+  password: string;
+}
+
+export interface RegisterRequest {
+  password: string;
+  firstName: string;
+  lastName: string;
+  email: string;
+}
+
+export interface CreateUserRequest {
+  email: string;
+  password?: string | null;
+  firstName: string;
+  lastName: string;
+  isAdmin: boolean;
+  sendEmail: boolean;
+}
+
+export interface UpdateProfileRequest {
+  id: number;
+  email?: string | null;
+  isAdmin?: boolean | null;
+  password?: string | null;
+  currentPassword?: string | null;
+  firstName?: string | null;
+  lastName?: string | null;
+  quota?: number | null;
+}
+
+export interface UpdatePasswordRequest {
+  password: string;
+  currentPassword: string;
+}
+
+// DeleteAccountRequest confirms an account deletion. ConfirmEmail must match
+// the target account's email. Password is required when users delete their
+// own account, unless they sign in through a linked SSO identity. Purge
+// deletes the user's files immediately instead of leaving them in the trash.
+export interface DeleteAccountRequest {
+  confirmEmail: string;
+  password: string;
+  purge: boolean;
+}
+
+export interface ForgotPasswordRequest {
+  email: string;
+}
+
+export interface ResetPasswordRequest {
+  token: string;
+  newPassword: string;
+}
+
+export interface CreateFolderRequest {
+  name: string;
+  parent: string;
+}
+
+export interface MoveFileRequest {
+  parent: string;
+}
+
+export interface BulkDeleteRequest {
+  fileIds: string[];
+  folderIds: string[];
+}
+
+export interface BulkRestoreRequest {
+  fileIds: string[];
+  folderIds: string[];
+}
+
+export interface BulkMoveRequest {
+  fileIds: string[];
+  folderIds: string[];
+  parent: string;
+}
+
+// BulkCopyRequest copies files and whole folder subtrees into Parent (the
+// drive root when empty).
+export interface BulkCopyRequest {
+  fileIds: string[];
+  folderIds: string[];
+  parent: string;
+}
+
+// DownloadFilesZipRequest selects what to bundle into an archive download.
+// Any mix of files and folders may be requested; folders keep their
+// directory structure. Format is one of the ArchiveFormat* constants and
+// defaults to ArchiveFormatZip. It's sent as query params to
+// DownloadFilesZip and as a JSON body to CreateArchiveJob.
+export interface DownloadFilesZipRequest {
+  fileIds: string[];
+  folderIds: string[];
+  format: string;
+}
+
+export interface CreateShareLinkRequest {
+  expires_at?: string | null;
+  require_login: boolean;
+  allow_upload: boolean;
+  max_file_size: number;
+}
+
+// ExtractArchiveRequest selects where a stored archive is unpacked. An empty
+// Folder extracts into the drive root.
+export interface ExtractArchiveRequest {
+  folder: string;
+}
+
+// SetFolderQuotaRequest sets the quota on a folder's subtree, in bytes. 0
+// removes it.
+export interface SetFolderQuotaRequest {
+  quota: number;
+}
+
+// GroupRequest creates or replaces a group. Quota and SoftQuota are in
+// bytes; 0 leaves them unset.
+export interface GroupRequest {
+  name: string;
+  quota: number;
+  softQuota: number;
+}
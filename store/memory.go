@@ -0,0 +1,337 @@
+package store
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"avenue/backend/db"
+	"avenue/backend/sdk"
+
+	"github.com/google/uuid"
+)
+
+// passwordResetTTL matches the expires_at default of password_reset_tokens.
+const passwordResetTTL = time.Hour
+
+// NewMemory returns an empty store that keeps everything in process, for
+// tests. It follows the Postgres store's rules (ownership checks, trash
+// cascades, unique emails) but not its usage hooks: UpdateUsage doesn't
+// send quota warnings. Users belong to no groups.
+func NewMemory() Store {
+	m := &memory{
+		users:        map[int64]*memUser{},
+		resetTokens:  map[string]memResetToken{},
+		sessions:     map[string]*db.Session{},
+		files:        map[string]*memFile{},
+		folders:      map[string]*memFolder{},
+		shareLinks:   map[string]*memShareLink{},
+		folderShares: map[string]*memFolderShare{},
+		jobs:         map[int64]*memJob{},
+		schedules:    map[string]*memSchedule{},
+	}
+	return Store{Users: m, Sessions: m, Files: m, Folders: m, Shares: m, Jobs: m}
+}
+
+// memory implements every interface over maps guarded by one mutex. Rows
+// are stored by pointer and copied on the way out, so callers can't
+// change them behind its back.
+type memory struct {
+	mu     sync.Mutex
+	lastID int64
+
+	users        map[int64]*memUser
+	resetTokens  map[string]memResetToken
+	sessions     map[string]*db.Session
+	files        map[string]*memFile
+	folders      map[string]*memFolder
+	shareLinks   map[string]*memShareLink
+	folderShares map[string]*memFolderShare
+	jobs         map[int64]*memJob
+	schedules    map[string]*memSchedule
+}
+
+type memUser struct {
+	sdk.User
+	warnedPercent  int
+	overQuotaSince *time.Time
+}
+
+type memResetToken struct {
+	userID    int64
+	expiresAt time.Time
+}
+
+// nextID hands out row IDs. They're unique across every kind of row,
+// which Postgres doesn't promise but nothing may rely on either way.
+func (m *memory) nextID() int64 {
+	m.lastID++
+	return m.lastID
+}
+
+// parseID parses an ID passed as a string the way a ::BIGINT cast would,
+// except that a malformed one matches nothing instead of failing.
+func parseID(s string) int64 {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+func now() *time.Time {
+	t := time.Now()
+	return &t
+}
+
+// -- users -- //
+
+func (m *memory) GetUserByID(id int64) (sdk.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return sdk.User{}, sql.ErrNoRows
+	}
+	return u.User, nil
+}
+
+func (m *memory) GetUserByIDStr(id string) (sdk.User, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return sdk.User{}, err
+	}
+	return m.GetUserByID(n)
+}
+
+func (m *memory) GetUserByEmail(email string) (sdk.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u := m.userByEmail(email); u != nil {
+		return u.User, nil
+	}
+	return sdk.User{}, sql.ErrNoRows
+}
+
+func (m *memory) userByEmail(email string) *memUser {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u
+		}
+	}
+	return nil
+}
+
+func (m *memory) GetUsers() ([]sdk.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var users []sdk.User
+	for _, u := range m.users {
+		user := u.User
+		user.Password = ""
+		users = append(users, user)
+	}
+	slices.SortFunc(users, func(a, b sdk.User) int { return cmp.Compare(a.ID, b.ID) })
+	return users, nil
+}
+
+func (m *memory) CreateUser(email, password, firstName, lastName string, isAdmin bool) (sdk.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.userByEmail(email) != nil {
+		return sdk.User{}, errors.New("store: duplicate email " + email)
+	}
+	u := &memUser{User: sdk.User{
+		ID:        m.nextID(),
+		Email:     email,
+		FirstName: firstName,
+		LastName:  lastName,
+		Password:  password,
+		CanLogin:  true,
+		IsAdmin:   isAdmin,
+		CreatedAt: time.Now(),
+	}}
+	m.users[u.ID] = u
+	return u.User, nil
+}
+
+func (m *memory) UpdateUser(user sdk.User) (sdk.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[user.ID]; ok {
+		if other := m.userByEmail(user.Email); other != nil && other != u {
+			return user, errors.New("store: duplicate email " + user.Email)
+		}
+		u.IsAdmin, u.FirstName, u.LastName = user.IsAdmin, user.FirstName, user.LastName
+		u.Email, u.Password, u.CanLogin, u.Quota = user.Email, user.Password, user.CanLogin, user.Quota
+	}
+	return user, nil
+}
+
+func (m *memory) HasOtherAdmins(user sdk.User) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.IsAdmin && u.ID != user.ID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memory) IsUniqueEmail(email string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.userByEmail(email) == nil
+}
+
+func (m *memory) UpdatePassword(userID int64, hashedPassword string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[userID]; ok {
+		u.Password = hashedPassword
+	}
+	return nil
+}
+
+func (m *memory) CreatePasswordResetToken(userID int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok {
+		return "", errors.New("store: no user " + strconv.FormatInt(userID, 10))
+	}
+	token := uuid.NewString()
+	m.resetTokens[token] = memResetToken{userID: userID, expiresAt: time.Now().Add(passwordResetTTL)}
+	return token, nil
+}
+
+func (m *memory) ConsumePasswordResetToken(token string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.resetTokens[token]
+	if !ok || !t.expiresAt.After(time.Now()) {
+		return 0, sql.ErrNoRows
+	}
+	delete(m.resetTokens, token)
+	return t.userID, nil
+}
+
+func (m *memory) UpdateUsage(userID int64, delta int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return nil
+	}
+	u.SpaceUsed = max(0, u.SpaceUsed+delta)
+	switch {
+	case u.Quota == 0 || u.SpaceUsed <= u.Quota:
+		u.overQuotaSince = nil
+	case u.overQuotaSince == nil:
+		u.overQuotaSince = now()
+	}
+	return nil
+}
+
+func (m *memory) GetQuotaStatus(userID int64) (sdk.QuotaStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return sdk.QuotaStatus{}, sql.ErrNoRows
+	}
+	return sdk.QuotaStatus{
+		Quota:          u.Quota,
+		Used:           u.SpaceUsed,
+		WarnedPercent:  u.warnedPercent,
+		OverQuotaSince: u.overQuotaSince,
+	}, nil
+}
+
+func (m *memory) ListUserGroups(int64) ([]sdk.Group, error) {
+	return nil, nil
+}
+
+// -- sessions -- //
+
+func (m *memory) CreateSession(userID int64, userAgent, ipAddress string) (db.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := &db.Session{
+		ID:        m.nextID(),
+		SessionID: uuid.NewString(),
+		ExpiresAt: time.Now().Add(15 * time.Minute).Unix(),
+		IsValid:   true,
+		UserId:    userID,
+		CreatedAt: time.Now(),
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+	}
+	m.sessions[s.SessionID] = s
+	return *s, nil
+}
+
+func (m *memory) IsValidSession(token string) (db.Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[token]
+	if !ok {
+		return db.Session{}, false
+	}
+	return *s, s.IsValid && s.ExpiresAt >= time.Now().Unix()
+}
+
+func (m *memory) UpdateSession(session db.Session) (db.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sessions {
+		if s.ID == session.ID {
+			s.ExpiresAt = session.ExpiresAt
+		}
+	}
+	return session, nil
+}
+
+func (m *memory) ListSessionsForUser(userID int64) ([]db.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sessions []db.Session
+	for _, s := range m.sessions {
+		if s.UserId == userID && s.IsValid && s.ExpiresAt >= time.Now().Unix() {
+			sessions = append(sessions, *s)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b db.Session) int { return cmp.Compare(b.ID, a.ID) })
+	return sessions, nil
+}
+
+func (m *memory) DeleteSession(token string) error {
+	return m.deleteSessions(func(s *db.Session) bool { return s.SessionID == token })
+}
+
+func (m *memory) DeleteSessionByIDForUser(id, userID int64) error {
+	return m.deleteSessions(func(s *db.Session) bool { return s.ID == id && s.UserId == userID })
+}
+
+func (m *memory) DeleteOtherSessions(userID int64, exceptToken string) error {
+	return m.deleteSessions(func(s *db.Session) bool { return s.UserId == userID && s.SessionID != exceptToken })
+}
+
+func (m *memory) DeleteSessionsForUser(userID int64) error {
+	return m.deleteSessions(func(s *db.Session) bool { return s.UserId == userID })
+}
+
+func (m *memory) deleteSessions(match func(*db.Session) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for token, s := range m.sessions {
+		if match(s) {
+			delete(m.sessions, token)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"cmp"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"avenue/backend/db"
+	"avenue/backend/sdk"
+
+	"github.com/google/uuid"
+)
+
+// memFile is a file row. Parent is the UUID of the folder it's in, or ""
+// for the root, and is only handed out where the db query joins it in.
+type memFile struct {
+	sdk.File
+}
+
+// out copies f for returning, with its parent if withParent is set.
+func (f *memFile) out(withParent bool) *sdk.File {
+	file := f.File
+	if !withParent {
+		file.Parent = ""
+	}
+	return &file
+}
+
+// parentFolder returns the folder f is in, or nil at the root.
+func (m *memory) parentFolder(f *memFile) *memFolder {
+	return m.folders[f.Parent]
+}
+
+// canEditFile reports whether userID created f or owns the folder it's
+// in, which is who may rename, move, trash or purge it.
+func (m *memory) canEditFile(f *memFile, userID int64) bool {
+	if f.CreatedBy == userID {
+		return true
+	}
+	p := m.parentFolder(f)
+	return p != nil && p.OwnerID == userID
+}
+
+// topLevelTrashed reports whether f was trashed itself, rather than only
+// along with the folder it's in.
+func (m *memory) topLevelTrashed(f *memFile) bool {
+	p := m.parentFolder(f)
+	return f.DeletedAt != nil && (p == nil || p.DeletedAt == nil)
+}
+
+// filesWhere copies out the files match accepts, oldest first.
+func (m *memory) filesWhere(withParent bool, match func(*memFile) bool) []sdk.File {
+	var files []sdk.File
+	for _, f := range m.files {
+		if match(f) {
+			files = append(files, *f.out(withParent))
+		}
+	}
+	slices.SortFunc(files, func(a, b sdk.File) int { return cmp.Compare(a.ID, b.ID) })
+	return files
+}
+
+// childFileOf reports whether f is directly in parentID, a folder UUID,
+// owned by ownerID; parentID "" means ownerID's own files at the root.
+func (m *memory) childFileOf(f *memFile, parentID string, ownerID int64) bool {
+	if parentID == "" {
+		return f.Parent == "" && f.CreatedBy == ownerID
+	}
+	p := m.parentFolder(f)
+	return p != nil && p.UUID == parentID && p.OwnerID == ownerID
+}
+
+func (m *memory) CreateFile(file *sdk.File) (string, error) {
+	m.mu.Lock()
+	if file.UUID == "" {
+		file.UUID = uuid.NewString()
+	}
+	if _, ok := m.folders[file.Parent]; !ok {
+		file.Parent = ""
+	}
+
+	existing := map[string]struct{}{}
+	for _, f := range m.files {
+		if f.Parent == file.Parent && f.Extension == file.Extension && f.DeletedAt == nil {
+			existing[f.Name] = struct{}{}
+		}
+	}
+	file.Name = db.NextAvailableName(existing, file.Name, file.Extension)
+	file.ID = m.nextID()
+	file.CreatedAt = time.Now()
+	file.DeletedAt = nil
+	m.files[file.UUID] = &memFile{File: *file}
+	m.mu.Unlock()
+
+	if err := m.UpdateUsage(file.CreatedBy, file.FileSize); err != nil {
+		return "", err
+	}
+	return file.UUID, nil
+}
+
+func (m *memory) GetFileByID(id, creatorID string) (*sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok || f.DeletedAt != nil || f.CreatedBy != parseID(creatorID) {
+		return nil, sql.ErrNoRows
+	}
+	return f.out(false), nil
+}
+
+func (m *memory) GetFileByIDForUser(id, userID string) (*sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok || f.DeletedAt != nil || !m.canEditFile(f, parseID(userID)) {
+		return nil, sql.ErrNoRows
+	}
+	return f.out(true), nil
+}
+
+func (m *memory) GetFileByIDPublic(id string) (*sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok || f.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	return f.out(false), nil
+}
+
+func (m *memory) ListFiles(creatorID string) ([]sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(creatorID)
+	return m.filesWhere(false, func(f *memFile) bool {
+		return f.CreatedBy == id && f.DeletedAt == nil
+	}), nil
+}
+
+func (m *memory) ListChildFilePublic(parentID string) ([]sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.filesWhere(false, func(f *memFile) bool {
+		return parentID != "" && f.Parent == parentID && f.DeletedAt == nil
+	}), nil
+}
+
+func (m *memory) CountChildFiles(parentID, ownerID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(ownerID)
+	return len(m.filesWhere(false, func(f *memFile) bool {
+		return m.childFileOf(f, parentID, id) && f.DeletedAt == nil
+	})), nil
+}
+
+func (m *memory) SearchChildFiles(parentID, ownerID, namePrefix string) ([]sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(ownerID)
+	return m.filesWhere(false, func(f *memFile) bool {
+		return m.childFileOf(f, parentID, id) && strings.HasPrefix(f.Name, namePrefix) && f.DeletedAt == nil
+	}), nil
+}
+
+func (m *memory) UpdateFile(f sdk.File, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.files[f.UUID]
+	if !ok || !m.canEditFile(file, parseID(userID)) {
+		return sql.ErrNoRows
+	}
+	if _, ok := m.folders[f.Parent]; !ok {
+		f.Parent = ""
+	}
+	file.Name, file.Extension, file.MimeType = f.Name, f.Extension, f.MimeType
+	file.FileSize, file.Checksum, file.Parent = f.FileSize, f.Checksum, f.Parent
+	return nil
+}
+
+func (m *memory) DeleteFile(id, creatorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.files[id]; ok && f.CreatedBy == parseID(creatorID) {
+		m.removeFile(f)
+	}
+	return nil
+}
+
+// removeFile deletes f along with the links to it, as the share_links
+// foreign key would.
+func (m *memory) removeFile(f *memFile) {
+	delete(m.files, f.UUID)
+	m.revokeFileShares(f.UUID)
+}
+
+func (m *memory) TrashFileForUser(id, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok || f.DeletedAt != nil || !m.canEditFile(f, parseID(userID)) {
+		return sql.ErrNoRows
+	}
+	f.DeletedAt = now()
+	m.revokeFileShares(id)
+	return nil
+}
+
+func (m *memory) RestoreFileForUser(id, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok || f.DeletedAt == nil || !m.canEditFile(f, parseID(userID)) {
+		return sql.ErrNoRows
+	}
+	f.DeletedAt = nil
+	return nil
+}
+
+func (m *memory) PurgeFileForUser(id, userID string) (*sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok || f.DeletedAt == nil || !m.canEditFile(f, parseID(userID)) {
+		return nil, sql.ErrNoRows
+	}
+	m.removeFile(f)
+	return &sdk.File{UUID: f.UUID, CreatedBy: f.CreatedBy, FileSize: f.FileSize}, nil
+}
+
+func (m *memory) ListTrashedFiles(userID string) ([]sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(userID)
+	files := m.filesWhere(false, func(f *memFile) bool {
+		return f.CreatedBy == id && m.topLevelTrashed(f)
+	})
+	slices.SortStableFunc(files, func(a, b sdk.File) int { return b.DeletedAt.Compare(*a.DeletedAt) })
+	return files, nil
+}
+
+func (m *memory) CountTrashedFiles(userID string) (int, error) {
+	files, err := m.ListTrashedFiles(userID)
+	return len(files), err
+}
@@ -0,0 +1,589 @@
+package store
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"avenue/backend/db"
+	"avenue/backend/sdk"
+	"avenue/backend/shared"
+
+	"github.com/google/uuid"
+)
+
+// memFolder is a folder row. ParentID is 0 at the root.
+type memFolder struct {
+	sdk.Folder
+	createdAt time.Time
+	quota     int64
+}
+
+func (f *memFolder) out() *sdk.Folder {
+	folder := f.Folder
+	return &folder
+}
+
+func (m *memory) folderByID(id int64) *memFolder {
+	for _, f := range m.folders {
+		if f.ID == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// ownedFolder returns ownerID's folder folderID if it's in the trash or
+// not, as trashed says, or nil.
+func (m *memory) ownedFolder(folderID string, ownerID int64, trashed bool) *memFolder {
+	f, ok := m.folders[folderID]
+	if !ok || f.OwnerID != ownerID || (f.DeletedAt != nil) != trashed {
+		return nil
+	}
+	return f
+}
+
+// subtree returns the IDs of root and every folder under it. With live
+// set it doesn't descend into trashed folders.
+func (m *memory) subtree(live bool, roots ...*memFolder) map[int64]bool {
+	ids := map[int64]bool{}
+	queue := slices.Clone(roots)
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if f == nil || ids[f.ID] {
+			continue
+		}
+		ids[f.ID] = true
+		for _, child := range m.folders {
+			if child.ParentID == f.ID && (!live || child.DeletedAt == nil) {
+				queue = append(queue, child)
+			}
+		}
+	}
+	return ids
+}
+
+// inFolders reports whether f is directly in one of the folders ids.
+func (m *memory) inFolders(f *memFile, ids map[int64]bool) bool {
+	p := m.parentFolder(f)
+	return p != nil && ids[p.ID]
+}
+
+// setTrashed trashes or restores the folders ids and the files in them.
+// Trashing revokes every link into them, as TrashFolder does.
+func (m *memory) setTrashed(ids map[int64]bool, trash bool) {
+	at := now()
+	for _, f := range m.folders {
+		if !ids[f.ID] {
+			continue
+		}
+		if !trash {
+			f.DeletedAt = nil
+		} else if f.DeletedAt == nil {
+			f.DeletedAt = at
+		}
+		if trash {
+			m.revokeFolderShares(f.ID)
+		}
+	}
+	for _, f := range m.files {
+		if !m.inFolders(f, ids) {
+			continue
+		}
+		if !trash {
+			f.DeletedAt = nil
+		} else if f.DeletedAt == nil {
+			f.DeletedAt = at
+		}
+		if trash {
+			m.revokeFileShares(f.UUID)
+		}
+	}
+}
+
+func (m *memory) CreateFolder(f *sdk.Folder) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f.UUID == "" {
+		f.UUID = uuid.NewString()
+	}
+	if _, ok := m.folders[f.UUID]; ok {
+		return "", errors.New("store: duplicate folder " + f.UUID)
+	}
+	if f.ParentID != 0 && m.folderByID(f.ParentID) == nil {
+		return "", errors.New("store: no parent folder")
+	}
+	f.ID = m.nextID()
+	m.folders[f.UUID] = &memFolder{
+		Folder:    sdk.Folder{ID: f.ID, UUID: f.UUID, Name: f.Name, ParentID: f.ParentID, OwnerID: f.OwnerID},
+		createdAt: time.Now(),
+	}
+	return f.UUID, nil
+}
+
+func (m *memory) GetFolder(folderID, userID string) (*sdk.Folder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.ownedFolder(folderID, parseID(userID), false)
+	if f == nil {
+		return nil, sql.ErrNoRows
+	}
+	folder := f.out()
+	folder.DeletedAt = nil
+	return folder, nil
+}
+
+func (m *memory) GetTrashedFolder(folderID, userID string) (*sdk.Folder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.ownedFolder(folderID, parseID(userID), true)
+	if f == nil {
+		return nil, sql.ErrNoRows
+	}
+	folder := f.out()
+	folder.DeletedAt = nil
+	return folder, nil
+}
+
+func (m *memory) UpdateFolder(f sdk.Folder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if folder, ok := m.folders[f.UUID]; ok && folder.OwnerID == f.OwnerID {
+		folder.Name = f.Name
+	}
+	return nil
+}
+
+// childFolders returns ownerID's live folders directly in parentID, a
+// folder UUID, or at the root for "" or the root sentinel.
+func (m *memory) childFolders(parentID string, ownerID int64) []*memFolder {
+	var parent int64
+	if parentID != "" && parentID != shared.ROOTFOLDERID {
+		p, ok := m.folders[parentID]
+		if !ok || p.OwnerID != ownerID {
+			return nil
+		}
+		parent = p.ID
+	}
+	var folders []*memFolder
+	for _, f := range m.folders {
+		if f.ParentID == parent && f.OwnerID == ownerID && f.DeletedAt == nil {
+			folders = append(folders, f)
+		}
+	}
+	return folders
+}
+
+func (m *memory) ListChildFolder(parentID, ownerID string, sortDir sdk.SortDirection, limit, offset int) ([]sdk.Folder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	children := m.childFolders(parentID, parseID(ownerID))
+	slices.SortFunc(children, func(a, b *memFolder) int {
+		return directed(sortDir, strings.Compare(a.Name, b.Name))
+	})
+	var folders []sdk.Folder
+	for _, f := range page(children, limit, offset) {
+		folders = append(folders, *f.out())
+	}
+	return folders, nil
+}
+
+func (m *memory) CountChildFolders(parentID, ownerID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.childFolders(parentID, parseID(ownerID))), nil
+}
+
+func (m *memory) ListFolderParents(folderID, ownerID string) ([]sdk.Folder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.folders[folderID]
+	if !ok || f.OwnerID != parseID(ownerID) {
+		return nil, nil
+	}
+	var folders []sdk.Folder
+	for ; f != nil; f = m.folderByID(f.ParentID) {
+		folder := f.out()
+		folder.DeletedAt = nil
+		folders = append(folders, *folder)
+	}
+	return folders, nil
+}
+
+func (m *memory) ListFolderItems(parentID, ownerID, sortBy string, sortDir sdk.SortDirection, limit, offset int, itemType string) ([]sdk.FolderItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	owner := parseID(ownerID)
+	if parentID == shared.ROOTFOLDERID {
+		parentID = ""
+	}
+
+	var items []sdk.FolderItem
+	if itemType == "" || itemType == sdk.FolderItemTypeFolder {
+		for _, f := range m.childFolders(parentID, owner) {
+			items = append(items, folderItem(f))
+		}
+	}
+	if itemType == "" || itemType == sdk.FolderItemTypeFile {
+		for _, f := range m.files {
+			if m.childFileOf(f, parentID, owner) && f.DeletedAt == nil {
+				items = append(items, m.fileItem(f))
+			}
+		}
+	}
+	sortItems(items, true, sortBy, sortDir)
+	return page(items, limit, offset), nil
+}
+
+func (m *memory) ListTrashedItems(userID, sortBy string, sortDir sdk.SortDirection, limit, offset int) ([]sdk.FolderItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(userID)
+
+	var items []sdk.FolderItem
+	for _, f := range m.folders {
+		if f.OwnerID == id && m.topLevelTrashedFolder(f) {
+			items = append(items, folderItem(f))
+		}
+	}
+	for _, f := range m.files {
+		if f.CreatedBy == id && m.topLevelTrashed(f) {
+			items = append(items, m.fileItem(f))
+		}
+	}
+	sortItems(items, false, sortBy, sortDir)
+	return page(items, limit, offset), nil
+}
+
+func folderItem(f *memFolder) sdk.FolderItem {
+	return sdk.FolderItem{
+		Type:      sdk.FolderItemTypeFolder,
+		ID:        f.ID,
+		UUID:      f.UUID,
+		Name:      f.Name,
+		ParentID:  f.ParentID,
+		OwnerID:   f.OwnerID,
+		CreatedAt: f.createdAt,
+		DeletedAt: f.DeletedAt,
+	}
+}
+
+func (m *memory) fileItem(f *memFile) sdk.FolderItem {
+	item := sdk.FolderItem{
+		Type:      sdk.FolderItemTypeFile,
+		ID:        f.ID,
+		UUID:      f.UUID,
+		Name:      f.Name,
+		Extension: f.Extension,
+		MimeType:  f.MimeType,
+		FileSize:  f.FileSize,
+		Checksum:  f.Checksum,
+		CreatedBy: f.CreatedBy,
+		CreatedAt: f.CreatedAt,
+		DeletedAt: f.DeletedAt,
+	}
+	if p := m.parentFolder(f); p != nil {
+		item.ParentID = p.ID
+	}
+	return item
+}
+
+// sortItems orders items as ListFolderItems and ListTrashedItems do: by
+// sortBy in sortDir, folders first if foldersFirst is set, ties broken by
+// UUID.
+func sortItems(items []sdk.FolderItem, foldersFirst bool, sortBy string, sortDir sdk.SortDirection) {
+	slices.SortFunc(items, func(a, b sdk.FolderItem) int {
+		if foldersFirst && a.Type != b.Type {
+			if a.Type == sdk.FolderItemTypeFolder {
+				return -1
+			}
+			return 1
+		}
+		var c int
+		switch sortBy {
+		case "file_size":
+			c = cmp.Compare(a.FileSize, b.FileSize)
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "deleted_at":
+			c = a.DeletedAt.Compare(*b.DeletedAt)
+		default:
+			c = strings.Compare(a.Name, b.Name)
+		}
+		return cmp.Or(directed(sortDir, c), strings.Compare(a.UUID, b.UUID))
+	})
+}
+
+// directed flips the comparison c for a descending sort.
+func directed(dir sdk.SortDirection, c int) int {
+	if dir == sdk.SortDesc {
+		return -c
+	}
+	return c
+}
+
+// page applies LIMIT limit OFFSET offset to s.
+func page[T any](s []T, limit, offset int) []T {
+	if offset >= len(s) {
+		return nil
+	}
+	s = s[offset:]
+	if limit < len(s) {
+		s = s[:limit]
+	}
+	return s
+}
+
+func (m *memory) ListFolderFilesForZip(folderID, ownerID string) ([]db.ZipFileEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root := m.ownedFolder(folderID, parseID(ownerID), false)
+	if root == nil {
+		return nil, nil
+	}
+	ids := m.subtree(true, root)
+
+	var entries []db.ZipFileEntry
+	for _, f := range m.files {
+		if f.DeletedAt != nil || !m.inFolders(f, ids) {
+			continue
+		}
+		var dirs []string
+		for p := m.parentFolder(f); p != nil; p = m.folderByID(p.ParentID) {
+			dirs = append(dirs, p.Name)
+			if p == root {
+				break
+			}
+		}
+		slices.Reverse(dirs)
+		entries = append(entries, db.ZipFileEntry{
+			UUID:      f.UUID,
+			Name:      f.Name,
+			CreatedBy: f.CreatedBy,
+			CreatedAt: f.CreatedAt,
+			DirPath:   strings.Join(dirs, "/"),
+		})
+	}
+	return entries, nil
+}
+
+func (m *memory) TrashFolder(folderID, ownerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root := m.ownedFolder(folderID, parseID(ownerID), false)
+	if root == nil {
+		return sql.ErrNoRows
+	}
+	m.setTrashed(m.subtree(false, root), true)
+	return nil
+}
+
+func (m *memory) RestoreFolder(folderID, ownerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root := m.ownedFolder(folderID, parseID(ownerID), true)
+	if root == nil {
+		return sql.ErrNoRows
+	}
+	m.setTrashed(m.subtree(false, root), false)
+	return nil
+}
+
+func (m *memory) PurgeFolder(folderID, ownerID string) ([]sdk.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root := m.ownedFolder(folderID, parseID(ownerID), true)
+	if root == nil {
+		return nil, sql.ErrNoRows
+	}
+	ids := m.subtree(false, root)
+
+	var files []sdk.File
+	for _, f := range m.files {
+		if m.inFolders(f, ids) {
+			files = append(files, sdk.File{UUID: f.UUID, CreatedBy: f.CreatedBy, FileSize: f.FileSize})
+			m.removeFile(f)
+		}
+	}
+	for key, f := range m.folders {
+		if ids[f.ID] {
+			delete(m.folders, key)
+			m.revokeFolderShares(f.ID)
+		}
+	}
+	return files, nil
+}
+
+// topLevelTrashedFolder reports whether f was trashed itself, rather than
+// only along with an ancestor.
+func (m *memory) topLevelTrashedFolder(f *memFolder) bool {
+	p := m.folderByID(f.ParentID)
+	return f.DeletedAt != nil && (p == nil || p.DeletedAt == nil)
+}
+
+func (m *memory) ListTrashedFolders(ownerID string) ([]sdk.Folder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(ownerID)
+	var folders []sdk.Folder
+	for _, f := range m.folders {
+		if f.OwnerID == id && m.topLevelTrashedFolder(f) {
+			folders = append(folders, *f.out())
+		}
+	}
+	slices.SortFunc(folders, func(a, b sdk.Folder) int {
+		return cmp.Or(b.DeletedAt.Compare(*a.DeletedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return folders, nil
+}
+
+func (m *memory) CountTrashedFolders(ownerID string) (int, error) {
+	folders, err := m.ListTrashedFolders(ownerID)
+	return len(folders), err
+}
+
+// ownedFolders returns userID's folders among folderIDs that are trashed
+// or not, as trashed says.
+func (m *memory) ownedFolders(folderIDs []string, userID int64, trashed bool) []*memFolder {
+	var folders []*memFolder
+	for _, id := range folderIDs {
+		if f := m.ownedFolder(id, userID, trashed); f != nil {
+			folders = append(folders, f)
+		}
+	}
+	return folders
+}
+
+func (m *memory) BulkTrash(fileIDs, folderIDs []string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(userID)
+	m.setTrashed(m.subtree(true, m.ownedFolders(folderIDs, id, false)...), true)
+	for _, fileID := range fileIDs {
+		if f, ok := m.files[fileID]; ok && f.DeletedAt == nil && m.canEditFile(f, id) {
+			f.DeletedAt = now()
+		}
+		m.revokeFileShares(fileID)
+	}
+	return nil
+}
+
+func (m *memory) BulkRestore(fileIDs, folderIDs []string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(userID)
+	m.setTrashed(m.subtree(false, m.ownedFolders(folderIDs, id, true)...), false)
+	for _, fileID := range fileIDs {
+		if f, ok := m.files[fileID]; ok && f.DeletedAt != nil && m.canEditFile(f, id) {
+			f.DeletedAt = nil
+		}
+	}
+	return nil
+}
+
+func (m *memory) BulkMove(fileIDs, folderIDs []string, parent, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(userID)
+
+	var dest *memFolder
+	if parent != "" {
+		if dest = m.ownedFolder(parent, id, false); dest == nil {
+			return sql.ErrNoRows
+		}
+		var moved []*memFolder
+		for _, folderID := range folderIDs {
+			if f, ok := m.folders[folderID]; ok && f.OwnerID == id {
+				moved = append(moved, f)
+			}
+		}
+		if m.subtree(false, moved...)[dest.ID] {
+			return errors.New("cannot move a folder into itself or one of its own subfolders")
+		}
+	}
+
+	for _, f := range m.ownedFolders(folderIDs, id, false) {
+		f.ParentID = 0
+		if dest != nil {
+			f.ParentID = dest.ID
+		}
+	}
+	for _, fileID := range fileIDs {
+		if f, ok := m.files[fileID]; ok && f.DeletedAt == nil && m.canEditFile(f, id) {
+			f.Parent = parent
+		}
+	}
+	return nil
+}
+
+func (m *memory) SetFolderQuota(folderID, ownerID string, quota int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.ownedFolder(folderID, parseID(ownerID), false)
+	if f == nil {
+		return sql.ErrNoRows
+	}
+	f.quota = quota
+	return nil
+}
+
+func (m *memory) ListFolderQuotas(folderID string) ([]sdk.FolderQuota, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	quotas := []sdk.FolderQuota{}
+	f, ok := m.folders[folderID]
+	for ok && f != nil {
+		if f.quota > 0 {
+			quotas = append(quotas, sdk.FolderQuota{
+				FolderID: f.UUID,
+				Name:     f.Name,
+				Quota:    f.quota,
+				Used:     m.bytesIn(m.subtree(true, f)),
+			})
+		}
+		f = m.folderByID(f.ParentID)
+	}
+	return quotas, nil
+}
+
+// bytesIn totals the live files in the folders ids.
+func (m *memory) bytesIn(ids map[int64]bool) int64 {
+	var n int64
+	for _, f := range m.files {
+		if f.DeletedAt == nil && m.inFolders(f, ids) {
+			n += f.FileSize
+		}
+	}
+	return n
+}
+
+func (m *memory) BytesEnteringFolder(folderID string, fileIDs, folderIDs []string, userID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(userID)
+	target := m.subtree(false, m.folders[folderID])
+
+	var roots []*memFolder
+	for _, f := range m.ownedFolders(folderIDs, id, false) {
+		if !target[f.ID] {
+			roots = append(roots, f)
+		}
+	}
+	moved := m.subtree(true, roots...)
+
+	var size int64
+	for _, f := range m.files {
+		if f.DeletedAt != nil {
+			continue
+		}
+		if m.inFolders(f, moved) ||
+			(slices.Contains(fileIDs, f.UUID) && m.canEditFile(f, id) && !m.inFolders(f, target)) {
+			size += f.FileSize
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"cmp"
+	"database/sql"
+	"slices"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+// memJob is a job and the lease of the worker running it.
+type memJob struct {
+	sdk.Job
+	lockedUntil time.Time
+}
+
+// memSchedule is a row of job_schedules.
+type memSchedule struct {
+	nextRunAt time.Time
+	lastJobID int64
+}
+
+func (j *memJob) active() bool {
+	return j.Status == sdk.JobStatusPending || j.Status == sdk.JobStatusRunning
+}
+
+// end marks j finished as status.
+func (j *memJob) end(status string) {
+	j.Status = status
+	j.lockedUntil = time.Time{}
+	j.FinishedAt = now()
+}
+
+func (m *memory) newJob(userID int64, kind string, payload []byte, maxAttempts int) *memJob {
+	t := time.Now()
+	j := &memJob{Job: sdk.Job{
+		ID:          m.nextID(),
+		UserID:      userID,
+		Kind:        kind,
+		Status:      sdk.JobStatusPending,
+		MaxAttempts: maxAttempts,
+		Payload:     payload,
+		RunAt:       t,
+		CreatedAt:   t,
+	}}
+	m.jobs[j.ID] = j
+	return j
+}
+
+func (m *memory) EnqueueJob(userID int64, kind string, payload []byte, maxAttempts int) (sdk.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.newJob(userID, kind, payload, maxAttempts).Job, nil
+}
+
+func (m *memory) EnqueueScheduledJob(kind string, interval time.Duration, maxAttempts int) (int64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sch, ok := m.schedules[kind]
+	if !ok {
+		sch = &memSchedule{}
+		m.schedules[kind] = sch
+	}
+	if sch.nextRunAt.After(time.Now()) {
+		return 0, false, nil
+	}
+	if last, ok := m.jobs[sch.lastJobID]; ok && last.active() {
+		return 0, false, nil
+	}
+	j := m.newJob(0, kind, nil, maxAttempts)
+	sch.nextRunAt, sch.lastJobID = time.Now().Add(interval), j.ID
+	return j.ID, true, nil
+}
+
+func (m *memory) ClaimDueJobs(limit int, lease time.Duration) ([]sdk.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := time.Now()
+
+	var due []*memJob
+	for _, j := range m.jobs {
+		expired := j.Status == sdk.JobStatusRunning && j.lockedUntil.Before(t)
+		if expired && (j.Attempts >= j.MaxAttempts || j.CancelRequested) {
+			if j.CancelRequested {
+				j.Error = ""
+				j.end(sdk.JobStatusCanceled)
+			} else {
+				j.Error = "worker stopped before the job finished"
+				j.end(sdk.JobStatusFailed)
+			}
+			continue
+		}
+		if expired || (j.Status == sdk.JobStatusPending && !j.RunAt.After(t)) {
+			due = append(due, j)
+		}
+	}
+	slices.SortFunc(due, func(a, b *memJob) int {
+		return cmp.Or(a.RunAt.Compare(b.RunAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	var claimed []sdk.Job
+	for _, j := range page(due, limit, 0) {
+		j.Status = sdk.JobStatusRunning
+		j.Attempts++
+		j.lockedUntil = t.Add(lease)
+		if j.StartedAt == nil {
+			j.StartedAt = now()
+		}
+		claimed = append(claimed, j.Job)
+	}
+	return claimed, nil
+}
+
+func (m *memory) HeartbeatJob(id, progressDone, progressTotal int64, lease time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || j.Status != sdk.JobStatusRunning {
+		return false, sql.ErrNoRows
+	}
+	j.ProgressDone, j.ProgressTotal = progressDone, progressTotal
+	j.lockedUntil = time.Now().Add(lease)
+	return j.CancelRequested, nil
+}
+
+func (m *memory) CompleteJob(id, progressDone, progressTotal int64, result []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j, ok := m.jobs[id]; ok {
+		j.ProgressDone, j.ProgressTotal = progressDone, progressTotal
+		j.Result, j.Error = nil, ""
+		if len(result) > 0 {
+			j.Result = result
+		}
+		j.end(sdk.JobStatusDone)
+	}
+	return nil
+}
+
+func (m *memory) FailJob(id, progressDone, progressTotal int64, jobErr string, retryAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil
+	}
+	j.ProgressDone, j.ProgressTotal, j.Error = progressDone, progressTotal, jobErr
+	if retryAt != nil {
+		j.Status, j.RunAt, j.lockedUntil = sdk.JobStatusPending, *retryAt, time.Time{}
+		return nil
+	}
+	j.end(sdk.JobStatusFailed)
+	return nil
+}
+
+func (m *memory) MarkJobCanceled(id, progressDone, progressTotal int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j, ok := m.jobs[id]; ok {
+		j.ProgressDone, j.ProgressTotal = progressDone, progressTotal
+		j.end(sdk.JobStatusCanceled)
+	}
+	return nil
+}
+
+func (m *memory) RequestJobCancel(id, userID int64) (sdk.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || j.UserID != userID || !j.active() {
+		return sdk.Job{}, sql.ErrNoRows
+	}
+	j.CancelRequested = true
+	if j.Status == sdk.JobStatusPending {
+		j.Status, j.FinishedAt = sdk.JobStatusCanceled, now()
+	}
+	return j.Job, nil
+}
+
+func (m *memory) GetJob(id, userID int64) (sdk.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || j.UserID != userID {
+		return sdk.Job{}, sql.ErrNoRows
+	}
+	return j.Job, nil
+}
+
+func (m *memory) GetJobByID(id int64) (sdk.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return sdk.Job{}, sql.ErrNoRows
+	}
+	return j.Job, nil
+}
+
+// jobsWhere copies out userID's jobs (every job for 0) with status (any
+// for ""), newest first.
+func (m *memory) jobsWhere(userID int64, status string) []sdk.Job {
+	var list []sdk.Job
+	for _, j := range m.jobs {
+		if (userID == 0 || j.UserID == userID) && (status == "" || j.Status == status) {
+			list = append(list, j.Job)
+		}
+	}
+	slices.SortFunc(list, func(a, b sdk.Job) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.ID, a.ID))
+	})
+	return list
+}
+
+func (m *memory) ListJobs(userID int64, status string, limit, offset int) ([]sdk.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return page(m.jobsWhere(userID, status), limit, offset), nil
+}
+
+func (m *memory) CountJobs(userID int64, status string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.jobsWhere(userID, status)), nil
+}
+
+func (m *memory) DeleteFinishedJobsBefore(cutoff time.Time) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []int64
+	for id, j := range m.jobs {
+		if j.FinishedAt != nil && j.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"cmp"
+	"crypto/rand"
+	"database/sql"
+	"slices"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+// memShareLink is a file link. Its file's name is looked up when listed,
+// since the file may have been renamed since.
+type memShareLink struct {
+	sdk.ShareLink
+}
+
+// memFolderShare is a folder link, with the same caveat for the folder's
+// UUID and name.
+type memFolderShare struct {
+	sdk.ShareFolderLink
+}
+
+// live reports whether a link expiring at expiresAt still works.
+func live(expiresAt *time.Time) bool {
+	return expiresAt == nil || expiresAt.After(time.Now())
+}
+
+// revokeFileShares deletes every link to the file fileID.
+func (m *memory) revokeFileShares(fileID string) {
+	for token, l := range m.shareLinks {
+		if l.FileID == fileID {
+			delete(m.shareLinks, token)
+		}
+	}
+}
+
+// revokeFolderShares deletes every link to the folder folderID.
+func (m *memory) revokeFolderShares(folderID int64) {
+	for token, l := range m.folderShares {
+		if l.FolderIntID == folderID {
+			delete(m.folderShares, token)
+		}
+	}
+}
+
+func (m *memory) CreateShareLink(fileID, createdBy string, expiresAt *time.Time, requireLogin bool) (sdk.ShareLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[fileID]; !ok {
+		return sdk.ShareLink{}, sql.ErrNoRows
+	}
+	l := &memShareLink{sdk.ShareLink{
+		ID:           m.nextID(),
+		Token:        rand.Text(),
+		FileID:       fileID,
+		CreatedBy:    parseID(createdBy),
+		ExpiresAt:    expiresAt,
+		CreatedAt:    time.Now(),
+		RequireLogin: requireLogin,
+	}}
+	m.shareLinks[l.Token] = l
+	return l.ShareLink, nil
+}
+
+func (m *memory) GetShareLink(token string) (sdk.ShareLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.shareLinks[token]
+	if !ok || !live(l.ExpiresAt) {
+		return sdk.ShareLink{}, sql.ErrNoRows
+	}
+	link := l.ShareLink
+	l.LastAccessed = now()
+	return link, nil
+}
+
+// shareLinksWhere copies out the file links match accepts, newest first.
+func (m *memory) shareLinksWhere(match func(*memShareLink) bool) []sdk.ShareLink {
+	var links []sdk.ShareLink
+	for _, l := range m.shareLinks {
+		if match(l) {
+			links = append(links, l.ShareLink)
+		}
+	}
+	slices.SortFunc(links, func(a, b sdk.ShareLink) int { return cmp.Compare(b.ID, a.ID) })
+	return links
+}
+
+func (m *memory) withFileNames(links []sdk.ShareLink) []sdk.ShareLinkWithFileName {
+	var out []sdk.ShareLinkWithFileName
+	for _, l := range links {
+		w := sdk.ShareLinkWithFileName{
+			ID:           l.ID,
+			Token:        l.Token,
+			CreatedBy:    l.CreatedBy,
+			ExpiresAt:    l.ExpiresAt,
+			CreatedAt:    l.CreatedAt,
+			RequireLogin: l.RequireLogin,
+			LastAccessed: l.LastAccessed,
+		}
+		if f, ok := m.files[l.FileID]; ok {
+			w.FileID, w.FileName = f.UUID, f.Name
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+func (m *memory) ListSharesByFile(fileID, createdBy string) ([]sdk.ShareLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(createdBy)
+	return m.shareLinksWhere(func(l *memShareLink) bool {
+		return l.FileID == fileID && l.CreatedBy == id && live(l.ExpiresAt)
+	}), nil
+}
+
+func (m *memory) ListSharesByUser(createdBy string) ([]sdk.ShareLinkWithFileName, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(createdBy)
+	return m.withFileNames(m.shareLinksWhere(func(l *memShareLink) bool {
+		return l.CreatedBy == id && live(l.ExpiresAt)
+	})), nil
+}
+
+func (m *memory) ListExpiredSharesByUser(createdBy string) ([]sdk.ShareLinkWithFileName, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(createdBy)
+	links := m.shareLinksWhere(func(l *memShareLink) bool {
+		return l.CreatedBy == id && !live(l.ExpiresAt)
+	})
+	slices.SortStableFunc(links, func(a, b sdk.ShareLink) int { return b.ExpiresAt.Compare(*a.ExpiresAt) })
+	return m.withFileNames(links), nil
+}
+
+func (m *memory) DeleteShareLink(token, createdBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.shareLinks[token]; ok && l.CreatedBy == parseID(createdBy) {
+		delete(m.shareLinks, token)
+	}
+	return nil
+}
+
+func (m *memory) CreateShareFolderLink(folderUUID, createdBy string, expiresAt *time.Time, requireLogin, allowUpload bool, maxFileSize int64) (sdk.ShareFolderLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.folders[folderUUID]
+	if !ok {
+		return sdk.ShareFolderLink{}, sql.ErrNoRows
+	}
+	l := &memFolderShare{sdk.ShareFolderLink{
+		ID:           m.nextID(),
+		Token:        rand.Text(),
+		FolderIntID:  f.ID,
+		CreatedBy:    parseID(createdBy),
+		ExpiresAt:    expiresAt,
+		CreatedAt:    time.Now(),
+		RequireLogin: requireLogin,
+		AllowUpload:  allowUpload,
+		MaxFileSize:  maxFileSize,
+	}}
+	m.folderShares[l.Token] = l
+	return m.folderShareOut(l), nil
+}
+
+// folderShareOut copies l with its folder's current UUID and name.
+func (m *memory) folderShareOut(l *memFolderShare) sdk.ShareFolderLink {
+	link := l.ShareFolderLink
+	if f := m.folderByID(l.FolderIntID); f != nil {
+		link.FolderUUID, link.FolderName = f.UUID, f.Name
+	}
+	return link
+}
+
+func (m *memory) GetShareFolderLink(token string) (sdk.ShareFolderLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.folderShares[token]
+	if !ok || !live(l.ExpiresAt) {
+		return sdk.ShareFolderLink{}, sql.ErrNoRows
+	}
+	link := m.folderShareOut(l)
+	l.LastAccessed = now()
+	return link, nil
+}
+
+// folderSharesWhere copies out the folder links match accepts, newest
+// first.
+func (m *memory) folderSharesWhere(match func(*memFolderShare) bool) []sdk.ShareFolderLink {
+	var links []sdk.ShareFolderLink
+	for _, l := range m.folderShares {
+		if match(l) {
+			links = append(links, m.folderShareOut(l))
+		}
+	}
+	slices.SortFunc(links, func(a, b sdk.ShareFolderLink) int { return cmp.Compare(b.ID, a.ID) })
+	return links
+}
+
+func (m *memory) ListShareFoldersByFolder(folderUUID, createdBy string) ([]sdk.ShareFolderLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(createdBy)
+	return m.folderSharesWhere(func(l *memFolderShare) bool {
+		f := m.folderByID(l.FolderIntID)
+		return f != nil && f.UUID == folderUUID && l.CreatedBy == id && live(l.ExpiresAt)
+	}), nil
+}
+
+func (m *memory) ListShareFoldersByUser(createdBy string) ([]sdk.ShareFolderLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(createdBy)
+	return m.folderSharesWhere(func(l *memFolderShare) bool {
+		return l.CreatedBy == id && live(l.ExpiresAt)
+	}), nil
+}
+
+func (m *memory) ListExpiredShareFoldersByUser(createdBy string) ([]sdk.ShareFolderLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := parseID(createdBy)
+	links := m.folderSharesWhere(func(l *memFolderShare) bool {
+		return l.CreatedBy == id && !live(l.ExpiresAt)
+	})
+	slices.SortStableFunc(links, func(a, b sdk.ShareFolderLink) int { return b.ExpiresAt.Compare(*a.ExpiresAt) })
+	return links, nil
+}
+
+func (m *memory) DeleteShareFolderLink(token, createdBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.folderShares[token]; ok && l.CreatedBy == parseID(createdBy) {
+		delete(m.folderShares, token)
+	}
+	return nil
+}
+
+func (m *memory) IsFolderInSubtree(rootFolderID int64, candidateUUID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.folders[candidateUUID]
+	return ok && m.subtree(false, m.folderByID(rootFolderID))[f.ID], nil
+}
+
+func (m *memory) IsFileInSubtree(rootFolderID int64, fileUUID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fileUUID]
+	return ok && m.inFolders(f, m.subtree(false, m.folderByID(rootFolderID))), nil
+}
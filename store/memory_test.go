@@ -0,0 +1,166 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"avenue/backend/sdk"
+)
+
+// tree creates a user owning /a/b with a file in b, in a fresh store.
+func tree(t *testing.T) (st Store, owner string, a, b, file string) {
+	t.Helper()
+	st = NewMemory()
+	u, err := st.Users.CreateUser("owner@example.com", "x", "O", "W", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner = strconv.FormatInt(u.ID, 10)
+
+	fa := &sdk.Folder{Name: "a", OwnerID: u.ID}
+	if a, err = st.Folders.CreateFolder(fa); err != nil {
+		t.Fatal(err)
+	}
+	fb := &sdk.Folder{Name: "b", OwnerID: u.ID, ParentID: fa.ID}
+	if b, err = st.Folders.CreateFolder(fb); err != nil {
+		t.Fatal(err)
+	}
+	if file, err = st.Files.CreateFile(&sdk.File{Name: "f.txt", Extension: "txt", FileSize: 10, Parent: b, CreatedBy: u.ID}); err != nil {
+		t.Fatal(err)
+	}
+	return st, owner, a, b, file
+}
+
+func TestMemoryBulkMove(t *testing.T) {
+	tests := []struct {
+		name    string
+		folder  func(a, b string) string
+		dest    func(a, b string) string
+		wantErr bool
+	}{
+		{name: "into own subfolder", folder: func(a, _ string) string { return a }, dest: func(_, b string) string { return b }, wantErr: true},
+		{name: "into itself", folder: func(a, _ string) string { return a }, dest: func(a, _ string) string { return a }, wantErr: true},
+		{name: "to the root", folder: func(_, b string) string { return b }, dest: func(string, string) string { return "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, owner, a, b, _ := tree(t)
+			err := st.Folders.BulkMove(nil, []string{tt.folder(a, b)}, tt.dest(a, b), owner)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BulkMove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryTrashFolderCascades(t *testing.T) {
+	st, owner, a, b, file := tree(t)
+
+	if err := st.Folders.TrashFolder(a, owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Folders.GetFolder(b, owner); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFolder(subfolder) error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := st.Files.GetFileByIDForUser(file, owner); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFileByIDForUser() error = %v, want sql.ErrNoRows", err)
+	}
+	items, err := st.Folders.ListTrashedItems(owner, "deleted_at", sdk.SortDesc, 50, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].UUID != a {
+		t.Errorf("trash = %+v, want only the top folder", items)
+	}
+
+	if err := st.Folders.RestoreFolder(a, owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Files.GetFileByIDForUser(file, owner); err != nil {
+		t.Errorf("file not restored with its folder: %v", err)
+	}
+
+	purged, err := st.Folders.PurgeFolder(a, owner)
+	if err == nil {
+		t.Errorf("PurgeFolder() of a live folder purged %d files", len(purged))
+	}
+}
+
+func TestMemoryUpdateUsage(t *testing.T) {
+	st := NewMemory()
+	u, err := st.Users.CreateUser("u@example.com", "x", "U", "S", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Quota = 100
+	if _, err := st.Users.UpdateUser(u); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, delta := range []int64{150, -500} {
+		if err := st.Users.UpdateUsage(u.ID, delta); err != nil {
+			t.Fatal(err)
+		}
+		status, err := st.Users.GetQuotaStatus(u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		over := status.Used > status.Quota
+		if over != (status.OverQuotaSince != nil) {
+			t.Errorf("after %+d: used %d of %d, OverQuotaSince = %v", delta, status.Used, status.Quota, status.OverQuotaSince)
+		}
+		if status.Used < 0 {
+			t.Errorf("after %+d: used = %d, want >= 0", delta, status.Used)
+		}
+	}
+}
+
+func TestMemoryJobQueue(t *testing.T) {
+	st := NewMemory()
+	first, err := st.Jobs.EnqueueJob(7, "kind", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := st.Jobs.EnqueueJob(7, "kind", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := st.Jobs.ClaimDueJobs(1, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != first.ID || claimed[0].Attempts != 1 {
+		t.Fatalf("claimed %+v, want job %d on its first attempt", claimed, first.ID)
+	}
+
+	// The first job's lease has run out with no attempts left, so it fails
+	// and the second is claimed in its place.
+	claimed, err = st.Jobs.ClaimDueJobs(2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != second.ID {
+		t.Fatalf("claimed %+v, want only job %d", claimed, second.ID)
+	}
+	if job, _ := st.Jobs.GetJobByID(first.ID); job.Status != sdk.JobStatusFailed {
+		t.Errorf("job whose worker died is %s, want %s", job.Status, sdk.JobStatusFailed)
+	}
+
+	if _, err := st.Jobs.RequestJobCancel(second.ID, 8); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("canceling another user's job: err = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := st.Jobs.RequestJobCancel(second.ID, 7); err != nil {
+		t.Fatal(err)
+	}
+	cancelRequested, err := st.Jobs.HeartbeatJob(second.ID, 1, 2, time.Minute)
+	if err != nil || !cancelRequested {
+		t.Errorf("HeartbeatJob = %v, %v; want the cancel request", cancelRequested, err)
+	}
+	if n, _ := st.Jobs.CountJobs(7, sdk.JobStatusRunning); n != 1 {
+		t.Errorf("%d running jobs, want 1", n)
+	}
+}
@@ -0,0 +1,364 @@
+package store
+
+import (
+	"time"
+
+	"avenue/backend/db"
+	"avenue/backend/sdk"
+)
+
+// postgres implements every interface by calling the db function of the
+// same name.
+type postgres struct{}
+
+// -- users -- //
+
+func (postgres) GetUserByID(id int64) (sdk.User, error) {
+	return db.GetUserByID(id)
+}
+
+func (postgres) GetUserByIDStr(id string) (sdk.User, error) {
+	return db.GetUserByIDStr(id)
+}
+
+func (postgres) GetUserByEmail(email string) (sdk.User, error) {
+	return db.GetUserByEmail(email)
+}
+
+func (postgres) GetUsers() ([]sdk.User, error) {
+	return db.GetUsers()
+}
+
+func (postgres) CreateUser(email, password, firstName, lastName string, isAdmin bool) (sdk.User, error) {
+	return db.CreateUser(email, password, firstName, lastName, isAdmin)
+}
+
+func (postgres) UpdateUser(user sdk.User) (sdk.User, error) {
+	return db.UpdateUser(user)
+}
+
+func (postgres) HasOtherAdmins(user sdk.User) (bool, error) {
+	return db.HasOtherAdmins(user)
+}
+
+func (postgres) IsUniqueEmail(email string) bool {
+	return db.IsUniqueEmail(email)
+}
+
+func (postgres) UpdatePassword(userID int64, hashedPassword string) error {
+	return db.UpdatePassword(userID, hashedPassword)
+}
+
+func (postgres) CreatePasswordResetToken(userID int64) (string, error) {
+	return db.CreatePasswordResetToken(userID)
+}
+
+func (postgres) ConsumePasswordResetToken(token string) (int64, error) {
+	return db.ConsumePasswordResetToken(token)
+}
+
+func (postgres) UpdateUsage(userID int64, delta int64) error {
+	return db.UpdateUsage(userID, delta)
+}
+
+func (postgres) GetQuotaStatus(userID int64) (sdk.QuotaStatus, error) {
+	return db.GetQuotaStatus(userID)
+}
+
+func (postgres) ListUserGroups(userID int64) ([]sdk.Group, error) {
+	return db.ListUserGroups(userID)
+}
+
+// -- sessions -- //
+
+func (postgres) CreateSession(userID int64, userAgent, ipAddress string) (db.Session, error) {
+	return db.CreateSession(userID, userAgent, ipAddress)
+}
+
+func (postgres) IsValidSession(token string) (db.Session, bool) {
+	return db.IsValidSession(token)
+}
+
+func (postgres) UpdateSession(session db.Session) (db.Session, error) {
+	return db.UpdateSession(session)
+}
+
+func (postgres) ListSessionsForUser(userID int64) ([]db.Session, error) {
+	return db.ListSessionsForUser(userID)
+}
+
+func (postgres) DeleteSession(token string) error {
+	return db.DeleteSession(token)
+}
+
+func (postgres) DeleteSessionByIDForUser(id, userID int64) error {
+	return db.DeleteSessionByIDForUser(id, userID)
+}
+
+func (postgres) DeleteOtherSessions(userID int64, exceptToken string) error {
+	return db.DeleteOtherSessions(userID, exceptToken)
+}
+
+func (postgres) DeleteSessionsForUser(userID int64) error {
+	return db.DeleteSessionsForUser(userID)
+}
+
+// -- files -- //
+
+func (postgres) CreateFile(file *sdk.File) (string, error) {
+	return db.CreateFile(file)
+}
+
+func (postgres) GetFileByID(id, creatorID string) (*sdk.File, error) {
+	return db.GetFileByID(id, creatorID)
+}
+
+func (postgres) GetFileByIDForUser(id, userID string) (*sdk.File, error) {
+	return db.GetFileByIDForUser(id, userID)
+}
+
+func (postgres) GetFileByIDPublic(id string) (*sdk.File, error) {
+	return db.GetFileByIDPublic(id)
+}
+
+func (postgres) ListFiles(creatorID string) ([]sdk.File, error) {
+	return db.ListFiles(creatorID)
+}
+
+func (postgres) ListChildFilePublic(parentID string) ([]sdk.File, error) {
+	return db.ListChildFilePublic(parentID)
+}
+
+func (postgres) CountChildFiles(parentID, ownerID string) (int, error) {
+	return db.CountChildFiles(parentID, ownerID)
+}
+
+func (postgres) SearchChildFiles(parentID, ownerID, namePrefix string) ([]sdk.File, error) {
+	return db.SearchChildFiles(parentID, ownerID, namePrefix)
+}
+
+func (postgres) UpdateFile(f sdk.File, userID string) error {
+	return db.UpdateFile(f, userID)
+}
+
+func (postgres) DeleteFile(id, creatorID string) error {
+	return db.DeleteFile(id, creatorID)
+}
+
+func (postgres) TrashFileForUser(id, userID string) error {
+	return db.TrashFileForUser(id, userID)
+}
+
+func (postgres) RestoreFileForUser(id, userID string) error {
+	return db.RestoreFileForUser(id, userID)
+}
+
+func (postgres) PurgeFileForUser(id, userID string) (*sdk.File, error) {
+	return db.PurgeFileForUser(id, userID)
+}
+
+func (postgres) ListTrashedFiles(userID string) ([]sdk.File, error) {
+	return db.ListTrashedFiles(userID)
+}
+
+func (postgres) CountTrashedFiles(userID string) (int, error) {
+	return db.CountTrashedFiles(userID)
+}
+
+// -- folders -- //
+
+func (postgres) CreateFolder(f *sdk.Folder) (string, error) {
+	return db.CreateFolder(f)
+}
+
+func (postgres) GetFolder(folderID, userID string) (*sdk.Folder, error) {
+	return db.GetFolder(folderID, userID)
+}
+
+func (postgres) GetTrashedFolder(folderID, userID string) (*sdk.Folder, error) {
+	return db.GetTrashedFolder(folderID, userID)
+}
+
+func (postgres) UpdateFolder(f sdk.Folder) error {
+	return db.UpdateFolder(f)
+}
+
+func (postgres) ListChildFolder(parentID, ownerID string, sortDir sdk.SortDirection, limit, offset int) ([]sdk.Folder, error) {
+	return db.ListChildFolder(parentID, ownerID, sortDir, limit, offset)
+}
+
+func (postgres) CountChildFolders(parentID, ownerID string) (int, error) {
+	return db.CountChildFolders(parentID, ownerID)
+}
+
+func (postgres) ListFolderParents(folderID, ownerID string) ([]sdk.Folder, error) {
+	return db.ListFolderParents(folderID, ownerID)
+}
+
+func (postgres) ListFolderItems(parentID, ownerID, sortBy string, sortDir sdk.SortDirection, limit, offset int, itemType string) ([]sdk.FolderItem, error) {
+	return db.ListFolderItems(parentID, ownerID, sortBy, sortDir, limit, offset, itemType)
+}
+
+func (postgres) ListFolderFilesForZip(folderID, ownerID string) ([]db.ZipFileEntry, error) {
+	return db.ListFolderFilesForZip(folderID, ownerID)
+}
+
+func (postgres) TrashFolder(folderID, ownerID string) error {
+	return db.TrashFolder(folderID, ownerID)
+}
+
+func (postgres) RestoreFolder(folderID, ownerID string) error {
+	return db.RestoreFolder(folderID, ownerID)
+}
+
+func (postgres) PurgeFolder(folderID, ownerID string) ([]sdk.File, error) {
+	return db.PurgeFolder(folderID, ownerID)
+}
+
+func (postgres) ListTrashedFolders(ownerID string) ([]sdk.Folder, error) {
+	return db.ListTrashedFolders(ownerID)
+}
+
+func (postgres) CountTrashedFolders(ownerID string) (int, error) {
+	return db.CountTrashedFolders(ownerID)
+}
+
+func (postgres) ListTrashedItems(userID, sortBy string, sortDir sdk.SortDirection, limit, offset int) ([]sdk.FolderItem, error) {
+	return db.ListTrashedItems(userID, sortBy, sortDir, limit, offset)
+}
+
+func (postgres) BulkTrash(fileIDs, folderIDs []string, userID string) error {
+	return db.BulkTrash(fileIDs, folderIDs, userID)
+}
+
+func (postgres) BulkRestore(fileIDs, folderIDs []string, userID string) error {
+	return db.BulkRestore(fileIDs, folderIDs, userID)
+}
+
+func (postgres) BulkMove(fileIDs, folderIDs []string, parent, userID string) error {
+	return db.BulkMove(fileIDs, folderIDs, parent, userID)
+}
+
+func (postgres) SetFolderQuota(folderID, ownerID string, quota int64) error {
+	return db.SetFolderQuota(folderID, ownerID, quota)
+}
+
+func (postgres) ListFolderQuotas(folderID string) ([]sdk.FolderQuota, error) {
+	return db.ListFolderQuotas(folderID)
+}
+
+func (postgres) BytesEnteringFolder(folderID string, fileIDs, folderIDs []string, userID string) (int64, error) {
+	return db.BytesEnteringFolder(folderID, fileIDs, folderIDs, userID)
+}
+
+// -- shares -- //
+
+func (postgres) CreateShareLink(fileID, createdBy string, expiresAt *time.Time, requireLogin bool) (sdk.ShareLink, error) {
+	return db.CreateShareLink(fileID, createdBy, expiresAt, requireLogin)
+}
+
+func (postgres) GetShareLink(token string) (sdk.ShareLink, error) {
+	return db.GetShareLink(token)
+}
+
+func (postgres) ListSharesByFile(fileID, createdBy string) ([]sdk.ShareLink, error) {
+	return db.ListSharesByFile(fileID, createdBy)
+}
+
+func (postgres) ListSharesByUser(createdBy string) ([]sdk.ShareLinkWithFileName, error) {
+	return db.ListSharesByUser(createdBy)
+}
+
+func (postgres) ListExpiredSharesByUser(createdBy string) ([]sdk.ShareLinkWithFileName, error) {
+	return db.ListExpiredSharesByUser(createdBy)
+}
+
+func (postgres) DeleteShareLink(token, createdBy string) error {
+	return db.DeleteShareLink(token, createdBy)
+}
+
+func (postgres) CreateShareFolderLink(folderUUID, createdBy string, expiresAt *time.Time, requireLogin, allowUpload bool, maxFileSize int64) (sdk.ShareFolderLink, error) {
+	return db.CreateShareFolderLink(folderUUID, createdBy, expiresAt, requireLogin, allowUpload, maxFileSize)
+}
+
+func (postgres) GetShareFolderLink(token string) (sdk.ShareFolderLink, error) {
+	return db.GetShareFolderLink(token)
+}
+
+func (postgres) ListShareFoldersByFolder(folderUUID, createdBy string) ([]sdk.ShareFolderLink, error) {
+	return db.ListShareFoldersByFolder(folderUUID, createdBy)
+}
+
+func (postgres) ListShareFoldersByUser(createdBy string) ([]sdk.ShareFolderLink, error) {
+	return db.ListShareFoldersByUser(createdBy)
+}
+
+func (postgres) ListExpiredShareFoldersByUser(createdBy string) ([]sdk.ShareFolderLink, error) {
+	return db.ListExpiredShareFoldersByUser(createdBy)
+}
+
+func (postgres) DeleteShareFolderLink(token, createdBy string) error {
+	return db.DeleteShareFolderLink(token, createdBy)
+}
+
+func (postgres) IsFolderInSubtree(rootFolderID int64, candidateUUID string) (bool, error) {
+	return db.IsFolderInSubtree(rootFolderID, candidateUUID)
+}
+
+func (postgres) IsFileInSubtree(rootFolderID int64, fileUUID string) (bool, error) {
+	return db.IsFileInSubtree(rootFolderID, fileUUID)
+}
+
+// -- jobs -- //
+
+func (postgres) EnqueueJob(userID int64, kind string, payload []byte, maxAttempts int) (sdk.Job, error) {
+	return db.EnqueueJob(userID, kind, payload, maxAttempts)
+}
+
+func (postgres) EnqueueScheduledJob(kind string, interval time.Duration, maxAttempts int) (int64, bool, error) {
+	return db.EnqueueScheduledJob(kind, interval, maxAttempts)
+}
+
+func (postgres) ClaimDueJobs(limit int, lease time.Duration) ([]sdk.Job, error) {
+	return db.ClaimDueJobs(limit, lease)
+}
+
+func (postgres) HeartbeatJob(id, progressDone, progressTotal int64, lease time.Duration) (bool, error) {
+	return db.HeartbeatJob(id, progressDone, progressTotal, lease)
+}
+
+func (postgres) CompleteJob(id, progressDone, progressTotal int64, result []byte) error {
+	return db.CompleteJob(id, progressDone, progressTotal, result)
+}
+
+func (postgres) FailJob(id, progressDone, progressTotal int64, jobErr string, retryAt *time.Time) error {
+	return db.FailJob(id, progressDone, progressTotal, jobErr, retryAt)
+}
+
+func (postgres) MarkJobCanceled(id, progressDone, progressTotal int64) error {
+	return db.MarkJobCanceled(id, progressDone, progressTotal)
+}
+
+func (postgres) RequestJobCancel(id, userID int64) (sdk.Job, error) {
+	return db.RequestJobCancel(id, userID)
+}
+
+func (postgres) GetJob(id, userID int64) (sdk.Job, error) {
+	return db.GetJob(id, userID)
+}
+
+func (postgres) GetJobByID(id int64) (sdk.Job, error) {
+	return db.GetJobByID(id)
+}
+
+func (postgres) ListJobs(userID int64, status string, limit, offset int) ([]sdk.Job, error) {
+	return db.ListJobs(userID, status, limit, offset)
+}
+
+func (postgres) CountJobs(userID int64, status string) (int, error) {
+	return db.CountJobs(userID, status)
+}
+
+func (postgres) DeleteFinishedJobsBefore(cutoff time.Time) ([]int64, error) {
+	return db.DeleteFinishedJobsBefore(cutoff)
+}
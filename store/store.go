@@ -0,0 +1,147 @@
+// Package store is the data access the HTTP handlers and the job worker
+// need for users, sessions, files, folders, shares and jobs. Postgres is what the server runs
+// on; NewMemory keeps everything in process, so the REST API can be
+// tested with httptest and no database.
+//
+// Each method does what the db function of the same name does, down to
+// returning sql.ErrNoRows for a missing row, so handlers can switch
+// between the two without caring which they have. The rest of the
+// handlers' data (groups, identities, the email outbox, audit
+// events) is still read and written through package db directly, so
+// routes built on it need Postgres.
+package store
+
+import (
+	"time"
+
+	"avenue/backend/db"
+	"avenue/backend/sdk"
+)
+
+// Users are accounts, their password reset tokens and their quota usage.
+type Users interface {
+	GetUserByID(id int64) (sdk.User, error)
+	GetUserByIDStr(id string) (sdk.User, error)
+	GetUserByEmail(email string) (sdk.User, error)
+	GetUsers() ([]sdk.User, error)
+	CreateUser(email, password, firstName, lastName string, isAdmin bool) (sdk.User, error)
+	UpdateUser(user sdk.User) (sdk.User, error)
+	HasOtherAdmins(user sdk.User) (bool, error)
+	IsUniqueEmail(email string) bool
+	UpdatePassword(userID int64, hashedPassword string) error
+	CreatePasswordResetToken(userID int64) (string, error)
+	ConsumePasswordResetToken(token string) (int64, error)
+	UpdateUsage(userID int64, delta int64) error
+	GetQuotaStatus(userID int64) (sdk.QuotaStatus, error)
+	ListUserGroups(userID int64) ([]sdk.Group, error)
+}
+
+// Sessions are login sessions, keyed by their token.
+type Sessions interface {
+	CreateSession(userID int64, userAgent, ipAddress string) (db.Session, error)
+	IsValidSession(token string) (db.Session, bool)
+	UpdateSession(session db.Session) (db.Session, error)
+	ListSessionsForUser(userID int64) ([]db.Session, error)
+	DeleteSession(token string) error
+	DeleteSessionByIDForUser(id, userID int64) error
+	DeleteOtherSessions(userID int64, exceptToken string) error
+	DeleteSessionsForUser(userID int64) error
+}
+
+// Files are file records; their contents live in the blob store.
+type Files interface {
+	CreateFile(file *sdk.File) (string, error)
+	GetFileByID(id, creatorID string) (*sdk.File, error)
+	GetFileByIDForUser(id, userID string) (*sdk.File, error)
+	GetFileByIDPublic(id string) (*sdk.File, error)
+	ListFiles(creatorID string) ([]sdk.File, error)
+	ListChildFilePublic(parentID string) ([]sdk.File, error)
+	CountChildFiles(parentID, ownerID string) (int, error)
+	SearchChildFiles(parentID, ownerID, namePrefix string) ([]sdk.File, error)
+	UpdateFile(f sdk.File, userID string) error
+	DeleteFile(id, creatorID string) error
+	TrashFileForUser(id, userID string) error
+	RestoreFileForUser(id, userID string) error
+	PurgeFileForUser(id, userID string) (*sdk.File, error)
+	ListTrashedFiles(userID string) ([]sdk.File, error)
+	CountTrashedFiles(userID string) (int, error)
+}
+
+// Folders are the folder tree, including listings and moves that span
+// files and folders, and folder quotas.
+type Folders interface {
+	CreateFolder(f *sdk.Folder) (string, error)
+	GetFolder(folderID, userID string) (*sdk.Folder, error)
+	GetTrashedFolder(folderID, userID string) (*sdk.Folder, error)
+	UpdateFolder(f sdk.Folder) error
+	ListChildFolder(parentID, ownerID string, sortDir sdk.SortDirection, limit, offset int) ([]sdk.Folder, error)
+	CountChildFolders(parentID, ownerID string) (int, error)
+	ListFolderParents(folderID, ownerID string) ([]sdk.Folder, error)
+	ListFolderItems(parentID, ownerID, sortBy string, sortDir sdk.SortDirection, limit, offset int, itemType string) ([]sdk.FolderItem, error)
+	ListFolderFilesForZip(folderID, ownerID string) ([]db.ZipFileEntry, error)
+	TrashFolder(folderID, ownerID string) error
+	RestoreFolder(folderID, ownerID string) error
+	PurgeFolder(folderID, ownerID string) ([]sdk.File, error)
+	ListTrashedFolders(ownerID string) ([]sdk.Folder, error)
+	CountTrashedFolders(ownerID string) (int, error)
+	ListTrashedItems(userID, sortBy string, sortDir sdk.SortDirection, limit, offset int) ([]sdk.FolderItem, error)
+	BulkTrash(fileIDs, folderIDs []string, userID string) error
+	BulkRestore(fileIDs, folderIDs []string, userID string) error
+	BulkMove(fileIDs, folderIDs []string, parent, userID string) error
+	SetFolderQuota(folderID, ownerID string, quota int64) error
+	ListFolderQuotas(folderID string) ([]sdk.FolderQuota, error)
+	BytesEnteringFolder(folderID string, fileIDs, folderIDs []string, userID string) (int64, error)
+}
+
+// Shares are public links to files and folders.
+type Shares interface {
+	CreateShareLink(fileID, createdBy string, expiresAt *time.Time, requireLogin bool) (sdk.ShareLink, error)
+	GetShareLink(token string) (sdk.ShareLink, error)
+	ListSharesByFile(fileID, createdBy string) ([]sdk.ShareLink, error)
+	ListSharesByUser(createdBy string) ([]sdk.ShareLinkWithFileName, error)
+	ListExpiredSharesByUser(createdBy string) ([]sdk.ShareLinkWithFileName, error)
+	DeleteShareLink(token, createdBy string) error
+	CreateShareFolderLink(folderUUID, createdBy string, expiresAt *time.Time, requireLogin, allowUpload bool, maxFileSize int64) (sdk.ShareFolderLink, error)
+	GetShareFolderLink(token string) (sdk.ShareFolderLink, error)
+	ListShareFoldersByFolder(folderUUID, createdBy string) ([]sdk.ShareFolderLink, error)
+	ListShareFoldersByUser(createdBy string) ([]sdk.ShareFolderLink, error)
+	ListExpiredShareFoldersByUser(createdBy string) ([]sdk.ShareFolderLink, error)
+	DeleteShareFolderLink(token, createdBy string) error
+	IsFolderInSubtree(rootFolderID int64, candidateUUID string) (bool, error)
+	IsFileInSubtree(rootFolderID int64, fileUUID string) (bool, error)
+}
+
+// Jobs is the background job queue package jobs runs.
+type Jobs interface {
+	EnqueueJob(userID int64, kind string, payload []byte, maxAttempts int) (sdk.Job, error)
+	EnqueueScheduledJob(kind string, interval time.Duration, maxAttempts int) (id int64, enqueued bool, err error)
+	ClaimDueJobs(limit int, lease time.Duration) ([]sdk.Job, error)
+	HeartbeatJob(id, progressDone, progressTotal int64, lease time.Duration) (cancelRequested bool, err error)
+	CompleteJob(id, progressDone, progressTotal int64, result []byte) error
+	FailJob(id, progressDone, progressTotal int64, jobErr string, retryAt *time.Time) error
+	MarkJobCanceled(id, progressDone, progressTotal int64) error
+	RequestJobCancel(id, userID int64) (sdk.Job, error)
+	GetJob(id, userID int64) (sdk.Job, error)
+	GetJobByID(id int64) (sdk.Job, error)
+	ListJobs(userID int64, status string, limit, offset int) ([]sdk.Job, error)
+	CountJobs(userID int64, status string) (int, error)
+	DeleteFinishedJobsBefore(cutoff time.Time) ([]int64, error)
+}
+
+// Store bundles one implementation of each interface. They're separate so
+// a test can swap out one, e.g. to inject a failure, and keep the rest.
+type Store struct {
+	Users    Users
+	Sessions Sessions
+	Files    Files
+	Folders  Folders
+	Shares   Shares
+	Jobs     Jobs
+}
+
+// Postgres returns the store backed by package db, which must be
+// connected.
+func Postgres() Store {
+	p := postgres{}
+	return Store{Users: p, Sessions: p, Files: p, Folders: p, Shares: p, Jobs: p}
+}
@@ -0,0 +1,27 @@
+// LDAP sync: periodically re-reads LDAP-linked accounts from the directory
+// so users removed there (or from the login group) lose access to Avenue
+// without having to wait for them to try logging in.
+package sweeper
+
+import (
+	"context"
+	"time"
+
+	"avenue/backend/jobs"
+	"avenue/backend/sdk"
+)
+
+// DirectorySyncer is implemented by login backends backed by an external
+// directory, e.g. auth.LDAPAuthenticator.
+type DirectorySyncer interface {
+	Sync() error
+}
+
+// RegisterLDAPSync schedules the ldap.sync job, which calls syncer.Sync
+// every interval.
+func RegisterLDAPSync(syncer DirectorySyncer, interval time.Duration) {
+	jobs.Register(sdk.JobKindLDAPSync, 1, func(context.Context, *jobs.Run, struct{}) (any, error) {
+		return nil, syncer.Sync()
+	})
+	jobs.Schedule(sdk.JobKindLDAPSync, interval)
+}
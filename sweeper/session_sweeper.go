@@ -1,45 +1,62 @@
 // Session sweeping: periodically deletes expired/invalid rows from the
-// sessions table so it doesn't grow unbounded.
+// sessions table, abandoned SSO login attempts and aged-out rate limit
+// attempts, so they don't grow unbounded.
 package sweeper
 
 import (
-	"time"
+	"context"
+	"fmt"
 
+	"avenue/backend/config"
 	"avenue/backend/db"
+	"avenue/backend/jobs"
 	"avenue/backend/logger"
-	"avenue/backend/shared"
+	"avenue/backend/sdk"
 )
 
-const (
-	sessionSweepIntervalEnvKey  = "SESSION_SWEEP_INTERVAL"
-	defaultSessionSweepInterval = "1h"
-)
+// sessionSweepResult is the result of a sessions.sweep job.
+type sessionSweepResult struct {
+	Sessions      int64 `json:"sessions"`
+	LoginStates   int64 `json:"loginStates"`
+	RateLimitHits int64 `json:"rateLimitHits"`
+}
+
+// RegisterSessionSweep schedules the sessions.sweep job, which deletes
+// expired or invalidated sessions every cfg.SessionSweepInterval.
+func RegisterSessionSweep(cfg config.Sweepers) {
+	interval := cfg.SessionSweepInterval
 
-// StartSessionSweeper launches a background goroutine that deletes expired
-// or invalidated sessions on a fixed interval, configurable via the
-// SESSION_SWEEP_INTERVAL env var (a Go duration string, e.g. "1h"; default
-// "1h").
-func StartSessionSweeper() {
-	interval := shared.GetEnvDuration(sessionSweepIntervalEnvKey, defaultSessionSweepInterval)
-
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		sweepSessions()
-		for range ticker.C {
-			sweepSessions()
-		}
-	}()
+	jobs.Register(sdk.JobKindSessionSweep, 1, func(context.Context, *jobs.Run, struct{}) (any, error) {
+		return sweepSessions()
+	})
+	jobs.Schedule(sdk.JobKindSessionSweep, interval)
 }
 
-func sweepSessions() {
+func sweepSessions() (sessionSweepResult, error) {
+	var result sessionSweepResult
+
 	removed, err := db.DeleteExpiredSessions()
 	if err != nil {
-		logger.Errorf("session sweeper: delete expired sessions: %v", err)
-		return
+		return result, fmt.Errorf("delete expired sessions: %w", err)
 	}
+	result.Sessions = removed
 	if removed > 0 {
 		logger.Infof("session sweeper: removed %d expired session(s)", removed)
 	}
+
+	removed, err = db.DeleteExpiredOIDCLoginStates()
+	if err != nil {
+		return result, fmt.Errorf("delete expired sso login states: %w", err)
+	}
+	result.LoginStates = removed
+	if removed > 0 {
+		logger.Infof("session sweeper: removed %d expired sso login state(s)", removed)
+	}
+
+	removed, err = db.DeleteExpiredRateLimitHits()
+	if err != nil {
+		return result, fmt.Errorf("delete expired rate limit hits: %w", err)
+	}
+	result.RateLimitHits = removed
+	return result, nil
 }
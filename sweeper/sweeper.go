@@ -1,13 +1,20 @@
-// Package sweeper periodically hard-deletes files and folders that have
-// been sitting in the trash longer than a configured retention period.
+// Package sweeper registers the periodic maintenance jobs: hard-deleting
+// files and folders that have been sitting in the trash longer than a
+// configured retention period, clearing out expired sessions, and
+// re-syncing LDAP accounts. They run on the jobs scheduler, so each shows
+// up as a system job and runs once per interval across all instances.
 package sweeper
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
+	"avenue/backend/config"
 	"avenue/backend/db"
+	"avenue/backend/jobs"
 	"avenue/backend/logger"
 	"avenue/backend/sdk"
 	"avenue/backend/shared"
@@ -15,66 +22,66 @@ import (
 	"github.com/spf13/afero"
 )
 
-const (
-	retentionEnvKey      = "TRASH_RETENTION"
-	defaultRetention     = "720h" // 30 days
-	intervalEnvKey       = "TRASH_SWEEP_INTERVAL"
-	defaultSweepInterval = "5m"
-)
+// RegisterTrashSweep schedules the trash.sweep job, which hard-deletes
+// anything trashed for longer than cfg.TrashRetention, every
+// cfg.TrashSweepInterval. It runs on the job queue's scheduler, so only one
+// instance sweeps at a time.
+func RegisterTrashSweep(fs afero.Fs, cfg config.Sweepers) {
+	interval := cfg.TrashSweepInterval
+	retention := cfg.TrashRetention
 
-// Retention returns the configured trash retention period — how long an
-// item sits in the trash before the sweeper permanently deletes it.
-func Retention() time.Duration {
-	return shared.GetEnvDuration(retentionEnvKey, defaultRetention)
+	jobs.Register(sdk.JobKindTrashSweep, 1, func(ctx context.Context, run *jobs.Run, _ struct{}) (any, error) {
+		return sweep(ctx, run, fs, retention)
+	})
+	jobs.Schedule(sdk.JobKindTrashSweep, interval)
 }
 
-// Start launches a background goroutine that sweeps the trash on a fixed
-// interval, hard-deleting anything trashed for longer than the retention
-// period. Both are configurable via env vars (Go duration strings, e.g.
-// "5m", "720h"): TRASH_SWEEP_INTERVAL (default 5m) and TRASH_RETENTION
-// (default 720h, i.e. 30 days).
-func Start(fs afero.Fs) {
-	interval := shared.GetEnvDuration(intervalEnvKey, defaultSweepInterval)
-	retention := Retention()
-
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		sweep(fs, retention)
-		for range ticker.C {
-			sweep(fs, retention)
-		}
-	}()
-}
-
-func sweep(fs afero.Fs, retention time.Duration) {
+func sweep(ctx context.Context, run *jobs.Run, fs afero.Fs, retention time.Duration) (sdk.PurgeResult, error) {
+	var result sdk.PurgeResult
 	cutoff := time.Now().Add(-retention)
 
 	folders, err := db.ListExpiredTrashedFolders(cutoff)
 	if err != nil {
-		logger.Errorf("trash sweeper: list expired folders: %v", err)
+		return result, fmt.Errorf("list expired folders: %w", err)
 	}
+	run.SetTotal(int64(len(folders)))
+
 	for _, folder := range folders {
-		files, err := db.PurgeFolder(folder.UUID, strconv.FormatInt(folder.OwnerID, 10))
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		run.Add(1)
+
+		purgedFiles, err := db.PurgeFolder(folder.UUID, strconv.FormatInt(folder.OwnerID, 10))
 		if err != nil {
 			logger.Errorf("trash sweeper: purge folder %s: %v", folder.UUID, err)
 			continue
 		}
-		for _, f := range files {
+		result.Folders++
+		for _, f := range purgedFiles {
 			removeBlob(fs, f)
 			if err := db.UpdateUsage(f.CreatedBy, -f.FileSize); err != nil {
 				logger.Errorf("trash sweeper: update usage for user %d: %v", f.CreatedBy, err)
 			}
+			result.Files++
+			result.Bytes += f.FileSize
 		}
 	}
 
+	// Listed only now, so files inside the folders purged above aren't
+	// picked up a second time.
 	files, err := db.ListExpiredTrashedFiles(cutoff)
 	if err != nil {
-		logger.Errorf("trash sweeper: list expired files: %v", err)
-		return
+		return result, fmt.Errorf("list expired files: %w", err)
 	}
+	run.SetTotal(int64(len(folders) + len(files)))
+
 	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		run.Add(1)
+
 		purged, err := db.PurgeFileForUser(f.UUID, strconv.FormatInt(f.CreatedBy, 10))
 		if err != nil {
 			logger.Errorf("trash sweeper: purge file %s: %v", f.UUID, err)
@@ -84,7 +91,14 @@ func sweep(fs afero.Fs, retention time.Duration) {
 		if err := db.UpdateUsage(purged.CreatedBy, -purged.FileSize); err != nil {
 			logger.Errorf("trash sweeper: update usage for user %d: %v", purged.CreatedBy, err)
 		}
+		result.Files++
+		result.Bytes += purged.FileSize
+	}
+
+	if result.Files > 0 || result.Folders > 0 {
+		logger.Infof("trash sweeper: purged %d file(s) and %d folder(s)", result.Files, result.Folders)
 	}
+	return result, nil
 }
 
 func removeBlob(fs afero.Fs, f sdk.File) {